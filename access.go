@@ -0,0 +1,284 @@
+package s3
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// AccessAllOperations grants every operation
+	AccessAllOperations = "*"
+
+	// AccessReadOperations grants every operation that does not modify storage or plugin state
+	AccessReadOperations = "read"
+
+	// AccessWriteOperations grants every operation that modifies storage or plugin state
+	AccessWriteOperations = "write"
+)
+
+// Caller identifies who issued an RPC request; it is embedded in every request
+// and only consulted when access control is configured
+type Caller struct {
+	// Role is the access control role the caller acts as
+	Role string `json:"role,omitempty"`
+
+	// Token authenticates the role when the role is configured with a token
+	Token string `json:"token,omitempty"`
+
+	// Metadata describes the calling application for logs and audit records (optional)
+	Metadata *CallerMetadata `json:"caller,omitempty"`
+}
+
+func (c Caller) callerMetadata() *CallerMetadata {
+	return c.Metadata
+}
+
+// AccessConfig maps caller roles to allowed operations, buckets and key prefixes
+type AccessConfig struct {
+	// DefaultRole is applied to requests without a role; empty rejects such requests
+	DefaultRole string `mapstructure:"default_role"`
+
+	// Roles contains role definitions by name
+	Roles map[string]*RoleConfig `mapstructure:"roles"`
+}
+
+// RoleConfig describes the permissions of a single role
+type RoleConfig struct {
+	// Token, if set, must be sent with the request for the role to be accepted
+	Token string `mapstructure:"token"`
+
+	// Operations lists allowed RPC methods (e.g., "Read", "ListObjects")
+	// or the groups "read", "write" and "*"
+	Operations []string `mapstructure:"operations"`
+
+	// Buckets restricts the role to these buckets (empty allows all)
+	Buckets []string `mapstructure:"buckets"`
+
+	// Prefixes restricts the role to pathnames under these prefixes (empty allows all)
+	Prefixes []string `mapstructure:"prefixes"`
+}
+
+// Validate validates the access control configuration
+func (ac *AccessConfig) Validate() error {
+	if len(ac.Roles) == 0 {
+		return fmt.Errorf("at least one role must be configured")
+	}
+
+	if ac.DefaultRole != "" {
+		if _, exists := ac.Roles[ac.DefaultRole]; !exists {
+			return fmt.Errorf("default_role '%s' not found in roles", ac.DefaultRole)
+		}
+	}
+
+	for name, role := range ac.Roles {
+		if role == nil {
+			return fmt.Errorf("role '%s' is empty", name)
+		}
+
+		if len(role.Operations) == 0 {
+			return fmt.Errorf("role '%s' must allow at least one operation", name)
+		}
+	}
+
+	return nil
+}
+
+// AccessControl enforces role permissions on operations
+type AccessControl struct {
+	config *AccessConfig
+	log    *zap.Logger
+}
+
+// NewAccessControl creates an access control layer; a nil config disables it
+func NewAccessControl(config *AccessConfig, log *zap.Logger) *AccessControl {
+	if config == nil {
+		return nil
+	}
+
+	return &AccessControl{
+		config: config,
+		log:    log,
+	}
+}
+
+// Check verifies that the caller may run the operation on the bucket and every given pathname.
+// An empty bucket skips bucket and prefix checks (used for operations not bound to a bucket).
+func (ac *AccessControl) Check(caller Caller, operation, bucket string, pathnames ...string) error {
+	if ac == nil {
+		return nil
+	}
+
+	if reason := ac.check(caller, operation, bucket, pathnames); reason != "" {
+		return ac.deny(caller, operation, bucket, reason)
+	}
+
+	return nil
+}
+
+// Allows reports whether Check would succeed, without logging; used to filter listings
+func (ac *AccessControl) Allows(caller Caller, operation, bucket string, pathnames ...string) bool {
+	return ac == nil || ac.check(caller, operation, bucket, pathnames) == ""
+}
+
+// check returns the reason the request is denied, or empty when it is allowed
+func (ac *AccessControl) check(caller Caller, operation, bucket string, pathnames []string) string {
+	roleName := caller.Role
+	if roleName == "" {
+		roleName = ac.config.DefaultRole
+	}
+
+	role, exists := ac.config.Roles[roleName]
+	if !exists {
+		return "unknown role"
+	}
+
+	if role.Token != "" && subtle.ConstantTimeCompare([]byte(role.Token), []byte(caller.Token)) != 1 {
+		return "invalid token"
+	}
+
+	if !role.allowsOperation(operation) {
+		return "operation not allowed"
+	}
+
+	if bucket == "" {
+		return ""
+	}
+
+	if len(role.Buckets) > 0 && !slices.Contains(role.Buckets, bucket) {
+		return "bucket not allowed"
+	}
+
+	for _, pathname := range pathnames {
+		if !role.allowsPathname(pathname) {
+			return "pathname not allowed: " + pathname
+		}
+	}
+
+	return ""
+}
+
+// deny logs the rejected request and returns a permission denied error
+func (ac *AccessControl) deny(caller Caller, operation, bucket, reason string) error {
+	ac.log.Warn("access denied",
+		zap.String("role", caller.Role),
+		zap.String("operation", operation),
+		zap.String("bucket", bucket),
+		zap.String("reason", reason),
+	)
+	return NewPermissionDeniedError(operation)
+}
+
+// allowsOperation reports whether the role grants the RPC method
+func (rc *RoleConfig) allowsOperation(operation string) bool {
+	for _, allowed := range rc.Operations {
+		switch allowed {
+		case AccessAllOperations:
+			return true
+		case AccessReadOperations:
+			if !mutatingOperations[operation] {
+				return true
+			}
+		case AccessWriteOperations:
+			if mutatingOperations[operation] {
+				return true
+			}
+		default:
+			if allowed == operation {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsPathname reports whether the pathname lies under one of the role prefixes
+func (rc *RoleConfig) allowsPathname(pathname string) bool {
+	if len(rc.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range rc.Prefixes {
+		if strings.HasPrefix(pathname, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizedKey marks a context whose operation was already authorized by a composite operation
+type authorizedKey struct{}
+
+// authorize checks the caller against the access control layer, recording denials in metrics
+func (o *Operations) authorize(ctx context.Context, caller Caller, operation, metricOp, bucket string, pathnames ...string) error {
+	if ctx.Value(authorizedKey{}) != nil {
+		return nil
+	}
+
+	if err := o.plugin.access.Check(caller, operation, bucket, pathnames...); err != nil {
+		o.plugin.metrics.RecordOperation(bucket, metricOp, "error")
+		o.plugin.metrics.RecordError(bucket, ErrPermissionDenied)
+		return err
+	}
+
+	return nil
+}
+
+// authorizeIn resolves a bucket by name and checks access to it
+func (o *Operations) authorizeIn(ctx context.Context, caller Caller, operation, metricOp, bucketName string, pathnames ...string) error {
+	if o.plugin.access == nil {
+		return nil
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(bucketName)
+	if err != nil {
+		return NewBucketNotFoundError(bucketName)
+	}
+
+	return o.authorize(ctx, caller, operation, metricOp, bucket.Name, pathnames...)
+}
+
+// authorizeJob checks access to every bucket a job reads or writes
+func (o *Operations) authorizeJob(ctx context.Context, caller Caller, operation, metricOp string, buckets []string) error {
+	for _, bucket := range buckets {
+		if err := o.authorize(ctx, caller, operation, metricOp, bucket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowsJob reports whether authorizeJob would succeed, without logging; used to filter job listings
+func (o *Operations) allowsJob(caller Caller, operation string, buckets []string) bool {
+	for _, bucket := range buckets {
+		if !o.plugin.access.Allows(caller, operation, bucket) {
+			return false
+		}
+	}
+	return true
+}
+
+// authorizeMove checks a move against both objects before it runs as a pre-authorized copy and delete
+func (o *Operations) authorizeMove(ctx context.Context, req *MoveRequest) error {
+	if o.plugin.access == nil {
+		return nil
+	}
+
+	// Normalize first so the prefix check sees the same keys the copy will use
+	if err := o.validatePathname(&req.SourcePathname); err != nil {
+		return err
+	}
+	if err := o.validatePathname(&req.DestPathname); err != nil {
+		return err
+	}
+
+	if err := o.authorizeIn(ctx, req.Caller, "Move", "move", req.SourceBucket, req.SourcePathname); err != nil {
+		return err
+	}
+
+	return o.authorizeIn(ctx, req.Caller, "Move", "move", req.DestBucket, req.DestPathname)
+}
@@ -0,0 +1,83 @@
+package s3
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// accessPointPattern matches S3 Access Point ARNs, and Multi-Region Access Point ARNs which have no region:
+// arn:<partition>:s3:<region>:<account>:accesspoint/<name>
+var accessPointPattern = regexp.MustCompile(`^arn:(aws[a-z-]*):s3:([a-z0-9-]*):(\d{12}):accesspoint/([a-zA-Z0-9.-]+)$`)
+
+// accessPointARN is a parsed S3 Access Point or Multi-Region Access Point ARN
+type accessPointARN struct {
+	partition string
+	region    string
+	account   string
+
+	// name is the access point name, or the alias ("<id>.mrap") of a Multi-Region Access Point
+	name string
+}
+
+// parseAccessPointARN parses bucket as an access point ARN; it reports false for plain bucket names
+func parseAccessPointARN(bucket string) (accessPointARN, bool) {
+	m := accessPointPattern.FindStringSubmatch(bucket)
+	if m == nil {
+		return accessPointARN{}, false
+	}
+	return accessPointARN{partition: m[1], region: m[2], account: m[3], name: m[4]}, true
+}
+
+// multiRegion reports whether the ARN is a Multi-Region Access Point, signed with SigV4A
+func (a accessPointARN) multiRegion() bool {
+	return a.region == ""
+}
+
+// endpoint returns the base URL of objects served through the access point
+func (a accessPointARN) endpoint() string {
+	suffix := "amazonaws.com"
+	if strings.HasPrefix(a.partition, "aws-cn") {
+		suffix = "amazonaws.com.cn"
+	}
+
+	if a.multiRegion() {
+		return fmt.Sprintf("https://%s.accesspoint.s3-global.%s", strings.TrimSuffix(a.name, ".mrap"), suffix)
+	}
+	return fmt.Sprintf("https://%s-%s.s3-accesspoint.%s.%s", a.name, a.account, a.region, suffix)
+}
+
+// accessPoint returns the access point the bucket is reached through, if its bucket is an ARN
+func (bc *BucketConfig) accessPoint() (accessPointARN, bool) {
+	return parseAccessPointARN(bc.Bucket)
+}
+
+// validateAccessPoint checks the options of a bucket addressed by access point ARN.
+// Bucket-level configuration such as lifecycle rules cannot be managed through an access point.
+func (bc *BucketConfig) validateAccessPoint(server *ServerConfig) error {
+	if !strings.HasPrefix(bc.Bucket, "arn:") {
+		return nil
+	}
+	if _, ok := bc.accessPoint(); !ok {
+		return fmt.Errorf("bucket '%s' is not a valid access point or Multi-Region Access Point ARN", bc.Bucket)
+	}
+
+	if server.Compatibility != CompatibilityAWS || server.Endpoint != "" {
+		return fmt.Errorf("access point ARNs require an AWS server without endpoint")
+	}
+
+	switch {
+	case bc.DirectoryBucket:
+		return fmt.Errorf("access point ARNs cannot be combined with directory_bucket")
+	case bc.Expiration != nil:
+		return fmt.Errorf("access point ARNs cannot be combined with expiration")
+	case bc.Temporary != nil:
+		return fmt.Errorf("access point ARNs cannot be combined with temporary")
+	case bc.Batch != nil:
+		return fmt.Errorf("access point ARNs cannot be combined with batch")
+	case bc.CreateIfMissing:
+		return fmt.Errorf("access point ARNs cannot be combined with create_if_missing")
+	}
+
+	return nil
+}
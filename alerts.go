@@ -0,0 +1,325 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// AlertTriggered is emitted when a bucket crosses the error threshold
+	AlertTriggered = "alert_triggered"
+
+	// AlertResolved is emitted when a firing bucket drops back below the threshold
+	AlertResolved = "alert_resolved"
+
+	// QuotaThresholdCrossed is emitted when a bucket's usage reaches a write_policy.quota_alerts threshold
+	QuotaThresholdCrossed = "quota_threshold_crossed"
+
+	// QuotaThresholdResolved is emitted when a bucket's usage drops back below a crossed threshold
+	QuotaThresholdResolved = "quota_threshold_resolved"
+)
+
+// AlertConfig configures lightweight error-rate alerting for deployments without Prometheus alerting
+type AlertConfig struct {
+	// Window is the sliding window the error rate is computed over (default: 5m)
+	Window time.Duration `mapstructure:"window"`
+
+	// CheckInterval is how often thresholds are evaluated (default: 30s)
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+
+	// ErrorRate triggers an alert when errors/operations in the window reaches this fraction (0-1)
+	ErrorRate float64 `mapstructure:"error_rate"`
+
+	// ErrorCount triggers an alert when the number of errors in the window reaches this value
+	ErrorCount int64 `mapstructure:"error_count"`
+
+	// MinOperations is the minimum number of operations in the window before ErrorRate applies (default: 10)
+	MinOperations int64 `mapstructure:"min_operations"`
+
+	// Webhook is an optional URL receiving alert events as JSON POST requests
+	Webhook string `mapstructure:"webhook"`
+
+	// WebhookTimeout bounds each webhook request (default: 5s)
+	WebhookTimeout time.Duration `mapstructure:"webhook_timeout"`
+}
+
+// Validate validates the alert configuration and sets defaults
+func (ac *AlertConfig) Validate() error {
+	if ac.ErrorRate <= 0 && ac.ErrorCount <= 0 {
+		return fmt.Errorf("error_rate or error_count must be set")
+	}
+
+	if ac.ErrorRate > 1 {
+		return fmt.Errorf("error_rate must be between 0 and 1, got %v", ac.ErrorRate)
+	}
+
+	if ac.Window <= 0 {
+		ac.Window = 5 * time.Minute
+	}
+
+	if ac.CheckInterval <= 0 {
+		ac.CheckInterval = 30 * time.Second
+	}
+
+	if ac.CheckInterval > ac.Window {
+		return fmt.Errorf("check_interval cannot exceed window")
+	}
+
+	if ac.MinOperations <= 0 {
+		ac.MinOperations = 10
+	}
+
+	if ac.WebhookTimeout <= 0 {
+		ac.WebhookTimeout = 5 * time.Second
+	}
+
+	return nil
+}
+
+// AlertEvent is the structured payload logged and sent to the webhook
+type AlertEvent struct {
+	Event      string  `json:"event"`
+	Bucket     string  `json:"bucket"`
+	Operations int64   `json:"operations"`
+	Errors     int64   `json:"errors"`
+	ErrorRate  float64 `json:"error_rate"`
+	Window     string  `json:"window"`
+	Timestamp  int64   `json:"timestamp"`
+
+	// Quota events report the threshold and the measured usage instead of error counts
+	Threshold float64 `json:"threshold,omitempty"`
+	Used      int64   `json:"used,omitempty"`
+	Quota     int64   `json:"quota,omitempty"`
+}
+
+// alertMonitor counts operations per bucket in time slots and evaluates thresholds periodically
+type alertMonitor struct {
+	config *AlertConfig
+	client *http.Client
+	log    *zap.Logger
+
+	// Per-bucket ring of slots covering the window
+	buckets map[string]*alertRing
+
+	// Buckets currently above the threshold
+	firing map[string]bool
+
+	mu sync.Mutex
+}
+
+// alertRing holds operation counters for consecutive check intervals
+type alertRing struct {
+	slots []alertSlot
+}
+
+// alertSlot counts operations within a single check interval
+type alertSlot struct {
+	index  int64
+	ops    int64
+	errors int64
+}
+
+// newAlertMonitor creates an alert monitor; a nil config disables alerting
+func newAlertMonitor(config *AlertConfig, log *zap.Logger) *alertMonitor {
+	if config == nil {
+		return nil
+	}
+
+	return &alertMonitor{
+		config:  config,
+		client:  &http.Client{Timeout: config.WebhookTimeout},
+		log:     log,
+		buckets: make(map[string]*alertRing),
+		firing:  make(map[string]bool),
+	}
+}
+
+// observe records the outcome of a single operation on a registered bucket
+func (am *alertMonitor) observe(bucket, status string) {
+	if am == nil {
+		return
+	}
+
+	index := am.slotIndex(time.Now())
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	ring, exists := am.buckets[bucket]
+	if !exists {
+		ring = &alertRing{slots: make([]alertSlot, am.slotCount())}
+		am.buckets[bucket] = ring
+	}
+
+	slot := &ring.slots[index%int64(len(ring.slots))]
+	if slot.index != index {
+		*slot = alertSlot{index: index}
+	}
+
+	slot.ops++
+	if status == "error" {
+		slot.errors++
+	}
+}
+
+// run evaluates thresholds every check interval until ctx is cancelled
+func (am *alertMonitor) run(ctx context.Context, wg *sync.WaitGroup) {
+	if am == nil {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(am.config.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, event := range am.evaluate(now) {
+					am.emit(ctx, wg, event)
+				}
+			}
+		}
+	}()
+}
+
+// evaluate sums the window per bucket and returns state changes
+func (am *alertMonitor) evaluate(now time.Time) []AlertEvent {
+	current := am.slotIndex(now)
+	oldest := current - am.slotCount() + 1
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	var events []AlertEvent
+	for bucket, ring := range am.buckets {
+		var ops, errs int64
+		for _, slot := range ring.slots {
+			if slot.index >= oldest && slot.index <= current {
+				ops += slot.ops
+				errs += slot.errors
+			}
+		}
+
+		var rate float64
+		if ops > 0 {
+			rate = float64(errs) / float64(ops)
+		}
+
+		crossed := (am.config.ErrorCount > 0 && errs >= am.config.ErrorCount) ||
+			(am.config.ErrorRate > 0 && ops >= am.config.MinOperations && rate >= am.config.ErrorRate)
+
+		if crossed == am.firing[bucket] {
+			continue
+		}
+
+		event := AlertEvent{
+			Event:      AlertResolved,
+			Bucket:     bucket,
+			Operations: ops,
+			Errors:     errs,
+			ErrorRate:  rate,
+			Window:     am.config.Window.String(),
+			Timestamp:  now.Unix(),
+		}
+		if crossed {
+			event.Event = AlertTriggered
+			am.firing[bucket] = true
+		} else {
+			delete(am.firing, bucket)
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// emit logs the event and delivers it to the webhook in the background
+func (am *alertMonitor) emit(ctx context.Context, wg *sync.WaitGroup, event AlertEvent) {
+	fields := []zap.Field{
+		zap.String("event", event.Event),
+		zap.String("bucket", event.Bucket),
+		zap.Int64("operations", event.Operations),
+		zap.Int64("errors", event.Errors),
+		zap.Float64("error_rate", event.ErrorRate),
+		zap.String("window", event.Window),
+	}
+	if event.Event == AlertTriggered {
+		am.log.Warn("error threshold crossed", fields...)
+	} else {
+		am.log.Info("error threshold resolved", fields...)
+	}
+
+	am.deliver(ctx, wg, event)
+}
+
+// deliver sends the event to the webhook in the background, if one is configured
+func (am *alertMonitor) deliver(ctx context.Context, wg *sync.WaitGroup, event AlertEvent) {
+	if am == nil || am.config.Webhook == "" {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := am.sendWebhook(ctx, event); err != nil {
+			am.log.Warn("failed to deliver alert webhook",
+				zap.String("bucket", event.Bucket),
+				zap.String("event", event.Event),
+				zap.Error(err),
+			)
+		}
+	}()
+}
+
+// sendWebhook posts the event as JSON to the configured webhook
+func (am *alertMonitor) sendWebhook(ctx context.Context, event AlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, am.config.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// slotIndex returns the check interval number a point in time falls into
+func (am *alertMonitor) slotIndex(t time.Time) int64 {
+	return t.UnixNano() / int64(am.config.CheckInterval)
+}
+
+// slotCount returns the number of check intervals covering the window
+func (am *alertMonitor) slotCount() int64 {
+	n := int64(am.config.Window / am.config.CheckInterval)
+	if am.config.Window%am.config.CheckInterval != 0 {
+		n++
+	}
+	return n
+}
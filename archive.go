@@ -0,0 +1,799 @@
+package s3
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// extractJobType identifies archive extraction jobs
+	extractJobType = "extract"
+
+	// createArchiveJobType identifies archive creation jobs
+	createArchiveJobType = "create_archive"
+
+	// ArchiveFormatZip is a zip archive
+	ArchiveFormatZip = "zip"
+
+	// ArchiveFormatTar is an uncompressed tar archive
+	ArchiveFormatTar = "tar"
+
+	// ArchiveFormatTarGz is a gzip-compressed tar archive
+	ArchiveFormatTarGz = "tar.gz"
+)
+
+// errArchiveLimit stops an extraction that exceeds the configured limits
+var errArchiveLimit = errors.New("archive exceeds configured limits")
+
+// ArchiveConfig configures server-side archive handling
+type ArchiveConfig struct {
+	// Roots lists local directories archives may be read from or written to (empty allows S3 only)
+	Roots []string `mapstructure:"roots"`
+
+	// MaxEntries bounds the number of entries extracted into or added to one archive (default: 100000)
+	MaxEntries int `mapstructure:"max_entries"`
+
+	// MaxSize bounds the total uncompressed bytes extracted into or added to one archive (default: 10GB)
+	MaxSize int64 `mapstructure:"max_size"`
+
+	// Concurrency is the default number of parallel entry uploads (default: 4)
+	Concurrency int `mapstructure:"concurrency"`
+}
+
+// Validate validates the archive configuration and sets defaults
+func (ac *ArchiveConfig) Validate() error {
+	roots, err := absRoots(ac.Roots)
+	if err != nil {
+		return err
+	}
+	ac.Roots = roots
+
+	if ac.MaxEntries <= 0 {
+		ac.MaxEntries = 100000
+	}
+
+	if ac.MaxSize <= 0 {
+		ac.MaxSize = 10 * 1024 * 1024 * 1024
+	}
+
+	if ac.Concurrency <= 0 {
+		ac.Concurrency = 4
+	}
+
+	return nil
+}
+
+// archiveFormat returns the explicit format or detects it from the file name
+func archiveFormat(format, name string) (string, bool) {
+	switch format {
+	case ArchiveFormatZip, ArchiveFormatTar, ArchiveFormatTarGz:
+		return format, true
+	case "":
+	default:
+		return "", false
+	}
+
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveFormatZip, true
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveFormatTarGz, true
+	case strings.HasSuffix(lower, ".tar"):
+		return ArchiveFormatTar, true
+	default:
+		return "", false
+	}
+}
+
+// StartExtractArchive validates an extraction request and launches it as an async job
+func (o *Operations) StartExtractArchive(ctx context.Context, req *ExtractArchiveRequest, resp *StartJobResponse) error {
+	ac := o.plugin.config.Archives
+
+	if (req.Pathname == "") == (req.LocalPath == "") {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "extract", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, ErrInvalidRequest)
+		return NewInvalidRequestError("exactly one of pathname or local_path must be set")
+	}
+
+	name := req.Pathname + req.LocalPath
+	format, ok := archiveFormat(req.Format, name)
+	if !ok {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "extract", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, ErrInvalidRequest)
+		return NewInvalidRequestError("format must be 'zip', 'tar' or 'tar.gz' (or detectable from the file name)")
+	}
+
+	if strings.Contains(req.DestPrefix, "..") || req.Concurrency < 0 {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "extract", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, ErrInvalidRequest)
+		return NewInvalidRequestError("dest_prefix cannot contain '..' and concurrency cannot be negative")
+	}
+
+	// Get destination bucket
+	destBucket, err := o.plugin.buckets.GetBucket(req.DestBucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "extract", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.DestBucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "ExtractArchive", "extract", destBucket.Name, req.DestPrefix); err != nil {
+		return err
+	}
+
+	if err := destBucket.requireAppendOnly("ExtractArchive"); err != nil {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "extract", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, err.Code)
+		return err
+	}
+
+	localPath := ""
+	if req.LocalPath != "" {
+		var allowed bool
+		localPath, allowed = allowedLocalPath(ac.Roots, req.LocalPath)
+		if !allowed {
+			o.plugin.metrics.RecordOperation(req.DestBucket, "extract", "error")
+			o.plugin.metrics.RecordError(req.DestBucket, ErrPermissionDenied)
+			return NewS3Error(ErrPermissionDenied, "local_path is outside the configured archive roots", req.LocalPath)
+		}
+	} else {
+		if err := o.validatePathname(&req.Pathname); err != nil {
+			o.plugin.metrics.RecordOperation(req.DestBucket, "extract", "error")
+			o.plugin.metrics.RecordError(req.DestBucket, ErrInvalidPathname)
+			return err
+		}
+		if err := o.authorizeIn(ctx, req.Caller, "ExtractArchive", "extract", req.Bucket, req.Pathname); err != nil {
+			return err
+		}
+		if _, err := o.plugin.buckets.GetBucket(req.Bucket); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "extract", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+			return NewBucketNotFoundError(req.Bucket)
+		}
+	}
+
+	concurrency := req.Concurrency
+	if concurrency == 0 {
+		concurrency = ac.Concurrency
+	}
+
+	extraction := &archiveExtraction{
+		ops:         o,
+		config:      ac,
+		bucket:      destBucket.Name,
+		prefix:      req.DestPrefix,
+		concurrency: concurrency,
+	}
+
+	buckets := []string{destBucket.Name}
+	if localPath == "" {
+		buckets = append(buckets, o.plugin.buckets.resolve(req.Bucket))
+	}
+
+	sourceBucket, pathname := req.Bucket, req.Pathname
+	job, err := o.plugin.jobs.Start(ctx, extractJobType, "", buckets, req, func(ctx context.Context, job *Job) error {
+		extraction.job = job
+		return extraction.run(ctx, format, sourceBucket, pathname, localPath)
+	})
+	if err != nil {
+		return asS3Error("start extraction", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// archiveExtraction writes the entries of one archive as objects under a prefix
+type archiveExtraction struct {
+	ops         *Operations
+	config      *ArchiveConfig
+	job         *Job
+	bucket      string
+	prefix      string
+	concurrency int
+
+	// Limits accounting
+	entries int
+	size    int64
+}
+
+// run opens the archive source and extracts it in the given format
+func (ae *archiveExtraction) run(ctx context.Context, format, sourceBucket, pathname, localPath string) error {
+	if localPath == "" {
+		// zip needs random access, so the object is spooled to a temporary file
+		if format == ArchiveFormatZip {
+			tmp, err := ae.download(ctx, sourceBucket, pathname)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(tmp)
+			localPath = tmp
+		} else {
+			body, err := ae.open(ctx, sourceBucket, pathname)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			return ae.extractTar(ctx, body, format == ArchiveFormatTarGz)
+		}
+	}
+
+	if format == ArchiveFormatZip {
+		return ae.extractZip(ctx, localPath)
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ae.extractTar(ctx, f, format == ArchiveFormatTarGz)
+}
+
+// open returns the body of an archive object
+func (ae *archiveExtraction) open(ctx context.Context, name, pathname string) (io.ReadCloser, error) {
+	bucket, err := ae.ops.plugin.buckets.GetBucket(name)
+	if err != nil {
+		return nil, NewBucketNotFoundError(name)
+	}
+
+	result, err := bucket.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.ObjectKey(pathname)),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, NewFileNotFoundError(pathname)
+		}
+		return nil, NewS3OperationError("download archive", err)
+	}
+	return result.Body, nil
+}
+
+// download spools an archive object to a temporary file and returns its path
+func (ae *archiveExtraction) download(ctx context.Context, name, pathname string) (string, error) {
+	body, err := ae.open(ctx, name, pathname)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "rr-s3-archive-*")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to spool archive: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// extractZip uploads zip entries with bounded concurrency
+func (ae *archiveExtraction) extractZip(ctx context.Context, localPath string) error {
+	zr, err := zip.OpenReader(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer zr.Close()
+
+	sem := make(chan struct{}, ae.concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		key, err := ae.reserve(file.Name, int64(file.UncompressedSize64))
+		if err != nil {
+			if errors.Is(err, errArchiveLimit) {
+				return err
+			}
+			ae.job.AddFailure(file.Name, err)
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(file *zip.File, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			rc, err := file.Open()
+			if err != nil {
+				ae.job.AddFailure(file.Name, err)
+				return
+			}
+			defer rc.Close()
+
+			// The declared size is untrusted; never read more than was reserved
+			limited := io.LimitReader(rc, int64(file.UncompressedSize64))
+			ae.upload(ctx, file.Name, key, limited, int64(file.UncompressedSize64))
+		}(file, key)
+	}
+
+	return nil
+}
+
+// extractTar uploads tar entries in archive order; tar is read sequentially so entries stream one at a time
+func (ae *archiveExtraction) extractTar(ctx context.Context, r io.Reader, gzipped bool) error {
+	if gzipped {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Links, devices and directories are not objects
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		key, err := ae.reserve(header.Name, header.Size)
+		if err != nil {
+			if errors.Is(err, errArchiveLimit) {
+				return err
+			}
+			ae.job.AddFailure(header.Name, err)
+			continue
+		}
+
+		ae.upload(ctx, header.Name, key, tr, header.Size)
+	}
+}
+
+// reserve validates an entry name and accounts it against the limits, returning the object key
+func (ae *archiveExtraction) reserve(name string, size int64) (string, error) {
+	// Reject absolute paths and "../" traversal (zip slip)
+	cleaned := path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "./"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("entry path escapes the destination prefix")
+	}
+
+	pathname := ae.prefix + cleaned
+	if err := checkPathname(pathname); err != nil {
+		return "", err
+	}
+
+	ae.entries++
+	ae.size += size
+	if ae.entries > ae.config.MaxEntries || ae.size > ae.config.MaxSize {
+		return "", fmt.Errorf("%w (max_entries: %d, max_size: %d)", errArchiveLimit, ae.config.MaxEntries, ae.config.MaxSize)
+	}
+
+	return pathname, nil
+}
+
+// upload writes one entry to the destination bucket and records the outcome on the job
+func (ae *archiveExtraction) upload(ctx context.Context, name, pathname string, body io.Reader, size int64) {
+	bucket, err := ae.ops.plugin.buckets.GetBucket(ae.bucket)
+	if err != nil {
+		ae.job.AddFailure(name, NewBucketNotFoundError(ae.bucket))
+		return
+	}
+
+	if err := bucket.checkBlobPathname(pathname); err != nil {
+		ae.job.AddFailure(name, err)
+		return
+	}
+	if err := ae.ops.enforcePolicy(ctx, bucket, "extract", pathname, max(size, 0)); err != nil {
+		ae.job.AddFailure(name, err)
+		return
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		ae.job.AddFailure(name, err)
+		return
+	}
+	defer bucket.Release()
+
+	counter := &countingReader{r: body}
+	uploader := bucket.NewUploader(size)
+
+	key := bucket.ObjectKey(pathname)
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket.Config.Bucket),
+		Key:         aws.String(key),
+		Body:        counter,
+		ACL:         bucket.ObjectACL(""),
+		ContentType: aws.String(ae.ops.detectContentType(pathname, nil)),
+	})
+	if err != nil {
+		ae.ops.abortInterruptedUpload(ctx, bucket, key, err)
+		loggerFor(ctx, ae.ops.log).Warn("failed to extract archive entry",
+			zap.String("job", ae.job.ID),
+			zap.String("bucket", ae.bucket),
+			zap.String("entry", name),
+			zap.Error(err),
+		)
+		ae.ops.plugin.metrics.RecordOperation(ae.bucket, "extract", "error")
+		ae.ops.plugin.metrics.RecordError(ae.bucket, ErrS3Operation)
+		ae.job.AddFailure(name, err)
+		return
+	}
+
+	bucket.filter.add(key)
+	ae.ops.plugin.index.update(bucket, pathname)
+	ae.ops.plugin.metrics.RecordOperation(ae.bucket, "extract", "success")
+	ae.job.AddProgress(counter.n)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// archiveWriter adds entries to an archive being streamed
+type archiveWriter interface {
+	add(name string, size int64, modified time.Time, r io.Reader) error
+	Close() error
+}
+
+// zipArchiveWriter writes deflated zip entries
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (zaw *zipArchiveWriter) add(name string, _ int64, modified time.Time, r io.Reader) error {
+	w, err := zaw.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (zaw *zipArchiveWriter) Close() error {
+	return zaw.zw.Close()
+}
+
+// tarGzArchiveWriter writes a gzip-compressed tar stream
+type tarGzArchiveWriter struct {
+	tw *tar.Writer
+	gw *gzip.Writer
+}
+
+func (taw *tarGzArchiveWriter) add(name string, size int64, modified time.Time, r io.Reader) error {
+	if err := taw.tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  modified,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(taw.tw, r, size)
+	return err
+}
+
+func (taw *tarGzArchiveWriter) Close() error {
+	if err := taw.tw.Close(); err != nil {
+		return err
+	}
+	return taw.gw.Close()
+}
+
+// newArchiveWriter creates a writer for the given format
+func newArchiveWriter(format string, w io.Writer) archiveWriter {
+	if format == ArchiveFormatZip {
+		return &zipArchiveWriter{zw: zip.NewWriter(w)}
+	}
+
+	gw := gzip.NewWriter(w)
+	return &tarGzArchiveWriter{tw: tar.NewWriter(gw), gw: gw}
+}
+
+// StartCreateArchive validates an archive request and launches it as an async job
+func (o *Operations) StartCreateArchive(ctx context.Context, req *CreateArchiveRequest, resp *StartJobResponse) error {
+	ac := o.plugin.config.Archives
+
+	format, ok := archiveFormat(req.Format, req.DestPathname+req.LocalPath)
+	if !ok || format == ArchiveFormatTar {
+		o.plugin.metrics.RecordOperation(req.Bucket, "create_archive", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("format must be 'zip' or 'tar.gz' (or detectable from the destination name)")
+	}
+
+	if (req.DestPathname == "") == (req.LocalPath == "") {
+		o.plugin.metrics.RecordOperation(req.Bucket, "create_archive", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("exactly one of dest_pathname or local_path must be set")
+	}
+
+	if strings.Contains(req.Prefix, "..") {
+		o.plugin.metrics.RecordOperation(req.Bucket, "create_archive", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("prefix cannot contain '..'")
+	}
+
+	// Get source bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "create_archive", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "CreateArchive", "create_archive", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	// Archived objects are listed by prefix
+	if err := bucket.requireUnsharded("archive creation"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "create_archive", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	localPath := ""
+	if req.LocalPath != "" {
+		var allowed bool
+		localPath, allowed = allowedLocalPath(ac.Roots, req.LocalPath)
+		if !allowed {
+			o.plugin.metrics.RecordOperation(req.Bucket, "create_archive", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrPermissionDenied)
+			return NewS3Error(ErrPermissionDenied, "local_path is outside the configured archive roots", req.LocalPath)
+		}
+	} else {
+		if req.DestBucket == "" {
+			req.DestBucket = req.Bucket
+		}
+		if err := o.validatePathname(&req.DestPathname); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "create_archive", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+			return err
+		}
+		dest, err := o.plugin.buckets.GetBucket(req.DestBucket)
+		if err != nil {
+			o.plugin.metrics.RecordOperation(req.DestBucket, "create_archive", "error")
+			o.plugin.metrics.RecordError(req.DestBucket, ErrBucketNotFound)
+			return NewBucketNotFoundError(req.DestBucket)
+		}
+		if err := dest.requireAppendOnly("CreateArchive"); err != nil {
+			o.plugin.metrics.RecordOperation(req.DestBucket, "create_archive", "error")
+			o.plugin.metrics.RecordError(req.DestBucket, err.Code)
+			return err
+		}
+		if err := dest.checkBlobPathname(req.DestPathname); err != nil {
+			o.plugin.metrics.RecordOperation(req.DestBucket, "create_archive", "error")
+			o.plugin.metrics.RecordError(req.DestBucket, err.Code)
+			return err
+		}
+		// Archives are streamed with an unknown size, which requires multipart uploads
+		if !dest.Capabilities.Multipart {
+			o.plugin.metrics.RecordOperation(req.DestBucket, "create_archive", "error")
+			o.plugin.metrics.RecordError(req.DestBucket, ErrNotSupported)
+			return NewNotSupportedError("multipart upload", req.DestBucket)
+		}
+		if err := o.authorizeIn(ctx, req.Caller, "CreateArchive", "create_archive", req.DestBucket, req.DestPathname); err != nil {
+			return err
+		}
+	}
+
+	buckets := []string{bucket.Name}
+	if localPath == "" {
+		buckets = append(buckets, o.plugin.buckets.resolve(req.DestBucket))
+	}
+
+	prefix, destBucket, destPathname := req.Prefix, req.DestBucket, req.DestPathname
+	job, err := o.plugin.jobs.Start(ctx, createArchiveJobType, "", buckets, req, func(ctx context.Context, job *Job) error {
+		if localPath != "" {
+			return o.createLocalArchive(ctx, job, ac, format, bucket.Name, prefix, localPath)
+		}
+		return o.createObjectArchive(ctx, job, ac, format, bucket.Name, prefix, destBucket, destPathname)
+	})
+	if err != nil {
+		return asS3Error("start archive", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// createLocalArchive writes the archive next to the target path and renames it into place once complete
+func (o *Operations) createLocalArchive(ctx context.Context, job *Job, ac *ArchiveConfig, format, name, prefix, localPath string) error {
+	tmp := localPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := o.writeArchive(ctx, job, ac, newArchiveWriter(format, f), name, prefix); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, localPath)
+}
+
+// createObjectArchive streams the archive into a multipart upload without buffering it
+func (o *Operations) createObjectArchive(ctx context.Context, job *Job, ac *ArchiveConfig, format, name, prefix, destName, destPathname string) error {
+	destBucket, err := o.plugin.buckets.GetBucket(destName)
+	if err != nil {
+		return NewBucketNotFoundError(destName)
+	}
+
+	contentType := "application/zip"
+	if format == ArchiveFormatTarGz {
+		contentType = "application/gzip"
+	}
+
+	return o.uploadStream(ctx, destBucket, destPathname, contentType, func(w io.Writer) error {
+		return o.writeArchive(ctx, job, ac, newArchiveWriter(format, w), name, prefix)
+	})
+}
+
+// uploadStream uploads what write produces to destPathname as a multipart upload without buffering it.
+// An error of write aborts the upload instead of completing a truncated object.
+func (o *Operations) uploadStream(ctx context.Context, destBucket *Bucket, destPathname, contentType string, write func(w io.Writer) error) error {
+	pr, pw := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		// The size is unknown while streaming
+		uploader := destBucket.NewUploader(-1)
+		key := destBucket.ObjectKey(destPathname)
+		_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(destBucket.Config.Bucket),
+			Key:         aws.String(key),
+			Body:        pr,
+			ACL:         destBucket.ObjectACL(""),
+			ContentType: aws.String(contentType),
+		})
+		if err != nil {
+			o.abortInterruptedUpload(ctx, destBucket, key, err)
+		} else {
+			destBucket.filter.add(key)
+			o.plugin.index.update(destBucket, destPathname)
+		}
+		// Unblock the writer if the upload stops early
+		pr.CloseWithError(err)
+		uploaded <- err
+	}()
+
+	err := write(pw)
+	pw.CloseWithError(err)
+
+	if uploadErr := <-uploaded; err == nil && uploadErr != nil {
+		err = fmt.Errorf("failed to upload '%s': %w", destPathname, uploadErr)
+	}
+	return err
+}
+
+// writeArchive adds every object under prefix to the archive and closes it
+func (o *Operations) writeArchive(ctx context.Context, job *Job, ac *ArchiveConfig, aw archiveWriter, name, prefix string) error {
+	bucket, err := o.plugin.buckets.GetBucket(name)
+	if err != nil {
+		return NewBucketNotFoundError(name)
+	}
+
+	listPrefix := bucket.GetFullPath(prefix)
+	blobPrefix := bucket.GetFullPath(bucket.Config.DedupPrefix)
+	var entries int
+	var size int64
+
+	err = o.walkObjects(ctx, bucket, listPrefix, func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		// Directory markers are not files
+		if strings.HasSuffix(key, "/") {
+			return nil
+		}
+		// Blobs are archived as the content of the pointers referencing them
+		if bucket.Config.Dedup && strings.HasPrefix(key, blobPrefix) {
+			return nil
+		}
+
+		if err := bucket.Acquire(ctx); err != nil {
+			return err
+		}
+		defer bucket.Release()
+
+		// Pointer objects are always empty, their content is read from the blob
+		source, objSize := key, aws.ToInt64(obj.Size)
+		if bucket.Config.Dedup && objSize == 0 {
+			blob, pointer, err := o.dedupResolve(ctx, bucket, bucket.Config.pathnameOf(key))
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return fmt.Errorf("failed to read '%s': %w", key, err)
+			}
+			if pointer != nil {
+				source = bucket.ObjectKey(blob)
+				_, objSize = dedupPointer(pointer.Metadata)
+			}
+		}
+
+		entries++
+		size += objSize
+		if entries > ac.MaxEntries || size > ac.MaxSize {
+			return fmt.Errorf("%w (max_entries: %d, max_size: %d)", errArchiveLimit, ac.MaxEntries, ac.MaxSize)
+		}
+
+		result, err := bucket.Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    aws.String(source),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// A missing entry must not silently produce an incomplete archive
+			return fmt.Errorf("failed to read '%s': %w", key, err)
+		}
+		defer result.Body.Close()
+
+		entry := strings.TrimPrefix(key, listPrefix)
+		if err := aw.add(entry, aws.ToInt64(result.ContentLength), aws.ToTime(obj.LastModified), result.Body); err != nil {
+			return fmt.Errorf("failed to add '%s': %w", entry, err)
+		}
+
+		o.plugin.metrics.RecordOperation(name, "create_archive", "success")
+		job.AddProgress(aws.ToInt64(result.ContentLength))
+		job.SetCheckpoint(entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
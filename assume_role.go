@@ -0,0 +1,97 @@
+package s3
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+const (
+	// defaultRoleSessionName identifies plugin sessions in CloudTrail
+	defaultRoleSessionName = "roadrunner-s3"
+
+	// minRoleDuration and maxRoleDuration are the STS limits for AssumeRole sessions
+	minRoleDuration = 15 * time.Minute
+	maxRoleDuration = 12 * time.Hour
+)
+
+var (
+	// roleARNPattern matches IAM role ARNs in any partition
+	roleARNPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/.+$`)
+
+	// roleSessionNamePattern matches the characters STS accepts in session names
+	roleSessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+)
+
+// AssumeRoleConfig makes a bucket use a role assumed with the server credentials,
+// e.g. a cross-account role giving access to a tenant's bucket
+type AssumeRoleConfig struct {
+	// RoleARN is the role to assume (e.g., "arn:aws:iam::123456789012:role/tenant-storage")
+	RoleARN string `mapstructure:"role_arn" json:"role_arn"`
+
+	// ExternalID is passed to STS when the role's trust policy requires it (optional)
+	ExternalID string `mapstructure:"external_id" json:"-"`
+
+	// SessionName identifies the session in CloudTrail (default: "roadrunner-s3")
+	SessionName string `mapstructure:"session_name" json:"session_name"`
+
+	// SessionTags are attached to the session, for ABAC policies keyed on aws:PrincipalTag (optional)
+	SessionTags map[string]string `mapstructure:"session_tags" json:"session_tags,omitempty"`
+
+	// TransitiveTags lists session tags that persist through role chaining (optional)
+	TransitiveTags []string `mapstructure:"transitive_tags" json:"transitive_tags,omitempty"`
+
+	// Duration is the session duration (default: 15m, max: 12h; the role's maximum applies)
+	Duration time.Duration `mapstructure:"duration" json:"duration"`
+}
+
+// Validate validates the role configuration and applies defaults
+func (rc *AssumeRoleConfig) Validate() error {
+	if !roleARNPattern.MatchString(rc.RoleARN) {
+		return fmt.Errorf("assume_role.role_arn must be an IAM role ARN, got '%s'", rc.RoleARN)
+	}
+
+	if rc.SessionName == "" {
+		rc.SessionName = defaultRoleSessionName
+	}
+	if !roleSessionNamePattern.MatchString(rc.SessionName) {
+		return fmt.Errorf("assume_role.session_name must be 2-64 characters of letters, digits and +=,.@_-")
+	}
+
+	if rc.Duration == 0 {
+		rc.Duration = minRoleDuration
+	}
+	if rc.Duration < minRoleDuration || rc.Duration > maxRoleDuration {
+		return fmt.Errorf("assume_role.duration must be between %s and %s", minRoleDuration, maxRoleDuration)
+	}
+
+	for _, key := range rc.TransitiveTags {
+		if _, exists := rc.SessionTags[key]; !exists {
+			return fmt.Errorf("assume_role.transitive_tags references unknown session tag '%s'", key)
+		}
+	}
+
+	return nil
+}
+
+// credentials returns a cached provider of the role's credentials, assumed with the base configuration
+func (rc *AssumeRoleConfig) credentials(base aws.Config) aws.CredentialsProvider {
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(base), rc.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = rc.SessionName
+		o.Duration = rc.Duration
+		o.TransitiveTagKeys = rc.TransitiveTags
+		if rc.ExternalID != "" {
+			o.ExternalID = aws.String(rc.ExternalID)
+		}
+		for key, value := range rc.SessionTags {
+			o.Tags = append(o.Tags, ststypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+	})
+
+	return aws.NewCredentialsCache(provider)
+}
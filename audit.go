@@ -0,0 +1,416 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// auditSequenceMetadata is the user metadata key holding the position of an object in the chain
+	auditSequenceMetadata = "audit-sequence"
+
+	// auditPreviousMetadata is the user metadata key holding the hash of the preceding object
+	auditPreviousMetadata = "audit-previous"
+
+	// auditContentMetadata is the user metadata key holding the hex sha256 of the content
+	auditContentMetadata = "audit-sha256"
+
+	// auditHashMetadata is the user metadata key holding the chain hash of the object
+	auditHashMetadata = "audit-hash"
+
+	// defaultAuditHeadPathname is the pathname of the chain head object
+	defaultAuditHeadPathname = ".audit/head.json"
+
+	// auditGenesis is the previous hash of the first object of a chain
+	auditGenesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	// maxChainProblems bounds the problems reported by VerifyChain
+	maxChainProblems = 1000
+
+	// auditRollbackTimeout bounds removing an appended object whose head update failed
+	auditRollbackTimeout = 30 * time.Second
+)
+
+// AuditConfig makes a bucket an append-only audit log: every Write gets the next sequence
+// number and the hash of the previous object in its metadata, objects cannot be overwritten,
+// deleted or moved, and VerifyChain detects gaps, edits and reordering.
+//
+// With state_encryption the chain hashes are HMACs keyed with the state key, so only holders of
+// the key can rewrite a consistent chain. Without it they are plain sha256, which anyone with
+// write access to the bucket can recompute; tamper evidence then requires S3 Object Lock.
+type AuditConfig struct {
+	// HeadPathname is the object recording the sequence and hash of the last object (default: ".audit/head.json")
+	HeadPathname string `mapstructure:"head_pathname" json:"head_pathname"`
+}
+
+// Validate validates the audit configuration and applies defaults
+func (ac *AuditConfig) Validate() error {
+	if ac.HeadPathname == "" {
+		ac.HeadPathname = defaultAuditHeadPathname
+	}
+	if err := checkPathname(ac.HeadPathname); err != nil {
+		return fmt.Errorf("invalid audit.head_pathname: %w", err)
+	}
+	return nil
+}
+
+// auditHead is the JSON body of the chain head object
+type auditHead struct {
+	Sequence  int64  `json:"sequence"`
+	Hash      string `json:"hash"`
+	Pathname  string `json:"pathname"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// auditEntry is the chain position of an object, as recorded in its metadata
+type auditEntry struct {
+	Sequence int64
+	Previous string
+	Content  string
+	Hash     string
+}
+
+// auditChainKey derives the HMAC key of audit chains from the state key
+func auditChainKey(stateKey string) []byte {
+	mac := hmac.New(sha256.New, []byte(stateKey))
+	mac.Write([]byte("s3-audit-chain"))
+	return mac.Sum(nil)
+}
+
+// chainHash returns the hash linking an object to its predecessor, an HMAC when key is set. It
+// covers the pathname, so renaming an object breaks the chain as much as editing it.
+func chainHash(key []byte, sequence int64, pathname, content, previous string) string {
+	message := []byte(strconv.FormatInt(sequence, 10) + "\n" + pathname + "\n" + content + "\n" + previous)
+	if key == nil {
+		sum := sha256.Sum256(message)
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditEntryOf parses the chain position from object metadata; ok is false for objects that are not chained
+func auditEntryOf(metadata map[string]string) (auditEntry, bool) {
+	sequence, err := strconv.ParseInt(metadata[auditSequenceMetadata], 10, 64)
+	if err != nil {
+		return auditEntry{}, false
+	}
+	return auditEntry{
+		Sequence: sequence,
+		Previous: metadata[auditPreviousMetadata],
+		Content:  metadata[auditContentMetadata],
+		Hash:     metadata[auditHashMetadata],
+	}, true
+}
+
+// requireAppendOnly rejects operations that would replace, remove or bypass the chain of an audit bucket
+func (b *Bucket) requireAppendOnly(operation string) *S3Error {
+	if b.Config.Audit == nil {
+		return nil
+	}
+	return NewS3Error(ErrPermissionDenied, "Audit buckets are append-only",
+		fmt.Sprintf("operation: %s, bucket: %s", operation, b.Name))
+}
+
+// readAuditHead returns the chain head and its ETag; a nil head means the chain is empty.
+// The caller holds the bucket semaphore.
+func (o *Operations) readAuditHead(ctx context.Context, bucket *Bucket) (*auditHead, string, error) {
+	result, err := bucket.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.ObjectKey(bucket.Config.Audit.HeadPathname)),
+	})
+	if exists, err := headExists(bucket, err); err != nil || !exists {
+		return nil, "", err
+	}
+	defer result.Body.Close()
+
+	var head auditHead
+	if err := json.NewDecoder(result.Body).Decode(&head); err != nil {
+		return nil, "", fmt.Errorf("invalid audit head: %w", err)
+	}
+	return &head, aws.ToString(result.ETag), nil
+}
+
+// appendAudit writes the object of putInput as the next link of the chain and advances the head.
+// Appends are serialized per bucket; the object is created with If-None-Match and the head is
+// replaced with If-Match, so an append racing another instance fails instead of forking the chain.
+// The caller holds the bucket semaphore.
+func (o *Operations) appendAudit(ctx context.Context, bucket *Bucket, pathname string, content []byte, putInput *s3.PutObjectInput) (*string, error) {
+	bucket.audit.Lock()
+	defer bucket.audit.Unlock()
+
+	head, headETag, err := o.readAuditHead(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, sequence := auditGenesis, int64(1)
+	if head != nil {
+		previous, sequence = head.Hash, head.Sequence+1
+	}
+
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+	hash := chainHash(o.plugin.auditKey, sequence, pathname, sum, previous)
+
+	if putInput.Metadata == nil {
+		putInput.Metadata = make(map[string]string)
+	}
+	putInput.Metadata[auditSequenceMetadata] = strconv.FormatInt(sequence, 10)
+	putInput.Metadata[auditPreviousMetadata] = previous
+	putInput.Metadata[auditContentMetadata] = sum
+	putInput.Metadata[auditHashMetadata] = hash
+
+	// The chain records who appended each object, encoded like other metadata
+	if caller := CallerMetadataFrom(ctx).auditMetadata(); len(caller) > 0 {
+		encoded, err := bucket.Config.encodeMetadata(caller)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range encoded {
+			putInput.Metadata[key] = value
+		}
+	}
+	putInput.IfNoneMatch = aws.String("*")
+
+	result, err := bucket.Client.PutObject(ctx, putInput)
+	if err != nil {
+		if isConditionalConflict(err) {
+			return nil, NewS3Error(ErrPermissionDenied, "Audit buckets are write-once", "pathname: "+pathname)
+		}
+		return nil, err
+	}
+
+	body, err := json.Marshal(&auditHead{Sequence: sequence, Hash: hash, Pathname: pathname, UpdatedAt: time.Now().Unix()})
+	if err != nil {
+		return nil, err
+	}
+	headInput := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket.Config.Bucket),
+		Key:         aws.String(bucket.ObjectKey(bucket.Config.Audit.HeadPathname)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	}
+	if head == nil {
+		headInput.IfNoneMatch = aws.String("*")
+	} else {
+		headInput.IfMatch = aws.String(headETag)
+	}
+
+	if _, err := bucket.Client.PutObject(ctx, headInput); err != nil {
+		// The object claims a sequence the head does not point to; take it back, even when the
+		// head update failed because the request was cancelled
+		rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditRollbackTimeout)
+		_, derr := bucket.Client.DeleteObject(rollbackCtx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    putInput.Key,
+		})
+		cancel()
+		if derr != nil {
+			loggerFor(ctx, o.log).Error("failed to remove audit object after head update failed",
+				zap.String("bucket", bucket.Name),
+				zap.String("pathname", pathname),
+				zap.Int64("sequence", sequence),
+				zap.Error(derr),
+			)
+		}
+		if isConditionalConflict(err) {
+			return nil, NewObjectChangedError(bucket.Config.Audit.HeadPathname)
+		}
+		return nil, err
+	}
+
+	o.log.Debug("audit object appended",
+		zap.String("bucket", bucket.Name),
+		zap.String("pathname", pathname),
+		zap.Int64("sequence", sequence),
+	)
+	return result.ETag, nil
+}
+
+// chainedObject is an object of an audit bucket as seen by VerifyChain
+type chainedObject struct {
+	pathname string
+	entry    auditEntry
+}
+
+// VerifyChain reads every object of an audit bucket and checks that the content matches its
+// recorded sha256, that every recorded hash is correct, that sequences are contiguous, that each
+// object links to its predecessor and that the head points to the last object
+func (o *Operations) VerifyChain(ctx context.Context, req *VerifyChainRequest, resp *VerifyChainResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "verify_chain", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "VerifyChain", "verify_chain", bucket.Name, ""); err != nil {
+		return err
+	}
+
+	if bucket.Config.Audit == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "verify_chain", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("bucket '%s' is not an audit bucket", bucket.Name))
+	}
+
+	// The head is read first: objects appended while verifying come after it
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	head, _, err := o.readAuditHead(ctx, bucket)
+	bucket.Release()
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "verify_chain", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("read audit head", err)
+	}
+
+	report := func(sequence int64, pathname, reason string) {
+		if len(resp.Problems) < maxChainProblems {
+			resp.Problems = append(resp.Problems, ChainProblem{Sequence: sequence, Pathname: pathname, Reason: reason})
+		}
+		resp.ProblemCount++
+	}
+
+	headKey := bucket.ObjectKey(bucket.Config.Audit.HeadPathname)
+	var chained []chainedObject
+	err = o.walkObjects(ctx, bucket, bucket.GetFullPath(""), func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		if key == headKey || strings.HasSuffix(key, "/") {
+			return nil
+		}
+		pathname := bucket.Config.pathnameOf(key)
+
+		entry, sum, err := o.readChained(ctx, bucket, key)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s': %w", pathname, err)
+		}
+		switch {
+		case entry == nil:
+			report(0, pathname, "object is not part of the chain")
+			return nil
+		case sum != entry.Content:
+			report(entry.Sequence, pathname, "content does not match its recorded sha256")
+		case chainHash(o.plugin.auditKey, entry.Sequence, pathname, entry.Content, entry.Previous) != entry.Hash:
+			report(entry.Sequence, pathname, "recorded hash does not match pathname, content and previous hash")
+		}
+
+		chained = append(chained, chainedObject{pathname: pathname, entry: *entry})
+		resp.Objects++
+		return nil
+	})
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "verify_chain", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("verify chain", err)
+	}
+
+	sort.Slice(chained, func(i, j int) bool { return chained[i].entry.Sequence < chained[j].entry.Sequence })
+
+	previous, expected := auditGenesis, int64(1)
+	for i, obj := range chained {
+		// Objects appended after the head was read are checked by the next verification
+		if head != nil && obj.entry.Sequence > head.Sequence {
+			break
+		}
+
+		switch {
+		case i > 0 && obj.entry.Sequence == chained[i-1].entry.Sequence:
+			report(obj.entry.Sequence, obj.pathname, "sequence appears more than once")
+			continue
+		case obj.entry.Sequence > expected:
+			report(expected, "", missingSequences(expected, obj.entry.Sequence-1))
+		case obj.entry.Previous != previous:
+			report(obj.entry.Sequence, obj.pathname, "previous hash does not match the preceding object")
+		}
+		previous, expected = obj.entry.Hash, obj.entry.Sequence+1
+
+		if head != nil && obj.entry.Sequence == head.Sequence && obj.entry.Hash != head.Hash {
+			report(obj.entry.Sequence, obj.pathname, "hash does not match the chain head")
+		}
+	}
+
+	if head != nil {
+		resp.HeadSequence = head.Sequence
+		resp.HeadHash = head.Hash
+		if expected <= head.Sequence {
+			report(head.Sequence, head.Pathname, missingSequences(expected, head.Sequence)+" (recorded by the head)")
+		}
+	} else if len(chained) > 0 {
+		report(0, bucket.Config.Audit.HeadPathname, "chain head is missing")
+	}
+
+	resp.Valid = resp.ProblemCount == 0
+	if !resp.Valid {
+		o.log.Warn("audit chain verification failed",
+			zap.String("bucket", bucket.Name),
+			zap.Int64("objects", resp.Objects),
+			zap.Int("problems", resp.ProblemCount),
+		)
+	}
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "verify_chain", "success")
+	return nil
+}
+
+// missingSequences describes a gap of the chain
+func missingSequences(from, to int64) string {
+	if from == to {
+		return fmt.Sprintf("sequence %d is missing", from)
+	}
+	return fmt.Sprintf("sequences %d to %d are missing", from, to)
+}
+
+// readChained returns the chain position recorded for key and the sha256 of its content; a nil
+// entry means the object carries no chain metadata
+func (o *Operations) readChained(ctx context.Context, bucket *Bucket, key string) (*auditEntry, string, error) {
+	if err := bucket.Acquire(ctx); err != nil {
+		return nil, "", err
+	}
+	defer bucket.Release()
+
+	result, err := bucket.Reader().GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer result.Body.Close()
+
+	entry, ok := auditEntryOf(result.Metadata)
+	if !ok {
+		return nil, "", nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, result.Body); err != nil {
+		return nil, "", err
+	}
+	return &entry, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,461 @@
+package s3
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3control"
+	s3ctypes "github.com/aws/aws-sdk-go-v2/service/s3control/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// BatchOperationCopy copies every object into a destination bucket
+	BatchOperationCopy = "copy"
+
+	// BatchOperationTag replaces the tag set of every object
+	BatchOperationTag = "tag"
+
+	// BatchOperationACL sets the canned ACL of every object
+	BatchOperationACL = "acl"
+
+	// defaultBatchManifestPrefix holds manifests and completion reports of batch jobs
+	defaultBatchManifestPrefix = ".batch/"
+
+	// defaultBatchPriority is the job priority among the account's batch jobs
+	defaultBatchPriority = 10
+
+	// maxBatchTags is the S3 limit of tags per object
+	maxBatchTags = 10
+
+	// maxBatchDescription is the S3 limit of job description characters
+	maxBatchDescription = 256
+)
+
+// accountIDPattern matches AWS account IDs
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// BatchConfig enables S3 Batch Operations jobs on a bucket, for changes to more objects than the
+// plugin should touch request by request. Jobs run in S3 under the given IAM role.
+type BatchConfig struct {
+	// AccountID is the AWS account owning the bucket and the jobs
+	AccountID string `mapstructure:"account_id" json:"account_id"`
+
+	// RoleARN is the role S3 Batch Operations assumes to read the manifest and change objects
+	RoleARN string `mapstructure:"role_arn" json:"role_arn"`
+
+	// ManifestPrefix is the bucket-relative prefix for manifests and reports (default: ".batch/")
+	ManifestPrefix string `mapstructure:"manifest_prefix" json:"manifest_prefix"`
+
+	// Priority is the job priority, higher runs first (default: 10)
+	Priority int32 `mapstructure:"priority" json:"priority"`
+}
+
+// Validate validates the batch configuration and applies defaults
+func (bc *BatchConfig) Validate() error {
+	if !accountIDPattern.MatchString(bc.AccountID) {
+		return fmt.Errorf("batch.account_id must be a 12-digit AWS account ID")
+	}
+
+	if !roleARNPattern.MatchString(bc.RoleARN) {
+		return fmt.Errorf("batch.role_arn must be an IAM role ARN, got '%s'", bc.RoleARN)
+	}
+
+	if bc.ManifestPrefix == "" {
+		bc.ManifestPrefix = defaultBatchManifestPrefix
+	}
+	if !strings.HasSuffix(bc.ManifestPrefix, "/") {
+		return fmt.Errorf("batch.manifest_prefix must end with '/'")
+	}
+
+	if bc.Priority == 0 {
+		bc.Priority = defaultBatchPriority
+	}
+	if bc.Priority < 0 {
+		return fmt.Errorf("batch.priority must not be negative")
+	}
+
+	return nil
+}
+
+// bucketARN returns the ARN of an S3 bucket in the partition of the job role
+func (bc *BatchConfig) bucketARN(bucket string) string {
+	partition := strings.SplitN(bc.RoleARN, ":", 3)[1]
+	return fmt.Sprintf("arn:%s:s3:::%s", partition, bucket)
+}
+
+// SubmitBatchJob lists the objects under a prefix into a manifest and creates an S3 Batch
+// Operations job applying one operation to all of them
+func (o *Operations) SubmitBatchJob(ctx context.Context, req *SubmitBatchJobRequest, resp *SubmitBatchJobResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	start := time.Now()
+
+	// Validate request; an empty prefix selects the whole bucket
+	if req.Prefix != "" {
+		if err := o.validatePathname(&req.Prefix); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+			return err
+		}
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "SubmitBatchJob", "batch_submit", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	bc := bucket.Config.Batch
+	if bc == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("bucket '%s' has no batch configured", req.Bucket))
+	}
+
+	// Objects under a sharded prefix do not share a key prefix
+	if err := bucket.requireUnsharded("batch operations"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	// Copies need the destination bucket, resolved and checked like the source
+	var destBucket *Bucket
+	if req.Operation == BatchOperationCopy {
+		if req.DestPrefix != "" {
+			if err := o.validatePathname(&req.DestPrefix); err != nil {
+				o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+				o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+				return err
+			}
+		}
+		if destBucket, err = o.plugin.buckets.GetBucket(req.DestBucket); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+			return NewBucketNotFoundError(req.DestBucket)
+		}
+		if err := o.authorize(ctx, req.Caller, "SubmitBatchJob", "batch_submit", destBucket.Name, req.DestPrefix); err != nil {
+			return err
+		}
+	}
+
+	operation, s3err := batchOperation(req, bucket, destBucket)
+	if s3err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+		o.plugin.metrics.RecordError(req.Bucket, s3err.Code)
+		return s3err
+	}
+
+	id, err := newRandomID()
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("generate job token", err)
+	}
+
+	manifestPathname := bc.ManifestPrefix + "manifests/" + id + ".csv"
+	manifestKey := bucket.GetFullPath(manifestPathname)
+
+	count, etag, err := o.writeBatchManifest(ctx, bucket, req.Prefix, manifestKey)
+	if err != nil {
+		o.log.Error("failed to write batch manifest",
+			zap.String("bucket", req.Bucket),
+			zap.String("prefix", req.Prefix),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("write manifest", err)
+	}
+
+	if count == 0 {
+		o.deleteBatchManifest(ctx, bucket, manifestKey)
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("no objects under prefix '%s'", req.Prefix))
+	}
+
+	description := req.Description
+	if description == "" {
+		description = fmt.Sprintf("roadrunner %s %s/%s", req.Operation, bucket.Name, req.Prefix)
+		if len(description) > maxBatchDescription {
+			description = description[:maxBatchDescription]
+		}
+	}
+
+	result, err := s3control.NewFromConfig(bucket.awsConfig).CreateJob(ctx, &s3control.CreateJobInput{
+		AccountId:            aws.String(bc.AccountID),
+		ClientRequestToken:   aws.String(id),
+		RoleArn:              aws.String(bc.RoleARN),
+		Priority:             aws.Int32(bc.Priority),
+		ConfirmationRequired: aws.Bool(false),
+		Description:          aws.String(description),
+		Operation:            operation,
+		Manifest: &s3ctypes.JobManifest{
+			Spec: &s3ctypes.JobManifestSpec{
+				Format: s3ctypes.JobManifestFormatS3BatchOperationsCsv20180820,
+				Fields: []s3ctypes.JobManifestFieldName{s3ctypes.JobManifestFieldNameBucket, s3ctypes.JobManifestFieldNameKey},
+			},
+			Location: &s3ctypes.JobManifestLocation{
+				ObjectArn: aws.String(bc.bucketARN(bucket.Config.Bucket) + "/" + manifestKey),
+				ETag:      aws.String(etag),
+			},
+		},
+		Report: &s3ctypes.JobReport{
+			Enabled:     true,
+			Bucket:      aws.String(bc.bucketARN(bucket.Config.Bucket)),
+			Prefix:      aws.String(strings.TrimSuffix(bucket.GetFullPath(bc.ManifestPrefix+"reports"), "/")),
+			Format:      s3ctypes.JobReportFormatReportCsv20180820,
+			ReportScope: s3ctypes.JobReportScopeFailedTasksOnly,
+		},
+	})
+	if err != nil {
+		o.deleteBatchManifest(ctx, bucket, manifestKey)
+		o.log.Error("failed to create batch job",
+			zap.String("bucket", req.Bucket),
+			zap.String("prefix", req.Prefix),
+			zap.String("operation", req.Operation),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "error")
+		if isForbidden(err) {
+			o.plugin.metrics.RecordError(req.Bucket, ErrPermissionDenied)
+			return NewS3AccessDeniedError("create job", err)
+		}
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("create job", err)
+	}
+
+	resp.JobID = aws.ToString(result.JobId)
+	resp.Objects = count
+	resp.ManifestPathname = manifestPathname
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "batch_submit", "success")
+
+	o.log.Info("batch job created",
+		zap.String("bucket", req.Bucket),
+		zap.String("prefix", req.Prefix),
+		zap.String("operation", req.Operation),
+		zap.String("job_id", resp.JobID),
+		zap.Int64("objects", count),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	return nil
+}
+
+// batchOperation builds the job operation of a request; destBucket is only set for copies
+func batchOperation(req *SubmitBatchJobRequest, bucket, destBucket *Bucket) (*s3ctypes.JobOperation, *S3Error) {
+	if len(req.Description) > maxBatchDescription {
+		return nil, NewInvalidRequestError(fmt.Sprintf("description must not exceed %d characters", maxBatchDescription))
+	}
+
+	switch req.Operation {
+	case BatchOperationCopy:
+		if err := destBucket.requireUnsharded("batch operations"); err != nil {
+			return nil, err
+		}
+		if err := destBucket.requireAppendOnly("SubmitBatchJob"); err != nil {
+			return nil, err
+		}
+		if err := destBucket.checkBlobPrefix(req.DestPrefix); err != nil {
+			return nil, err
+		}
+
+		// S3 appends the full source key to the target prefix
+		op := &s3ctypes.S3CopyObjectOperation{
+			TargetResource:          aws.String(bucket.Config.Batch.bucketARN(destBucket.Config.Bucket)),
+			CannedAccessControlList: s3ctypes.S3CannedAccessControlList(destBucket.ObjectACL(req.Visibility)),
+		}
+		if target := destBucket.GetFullPath(req.DestPrefix); target != "" {
+			op.TargetKeyPrefix = aws.String(target)
+		}
+		return &s3ctypes.JobOperation{S3PutObjectCopy: op}, nil
+
+	case BatchOperationTag:
+		if len(req.Tags) > maxBatchTags {
+			return nil, NewInvalidRequestError(fmt.Sprintf("tags must contain at most %d entries", maxBatchTags))
+		}
+		keys := make([]string, 0, len(req.Tags))
+		for key := range req.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		tags := make([]s3ctypes.S3Tag, 0, len(keys))
+		for _, key := range keys {
+			tags = append(tags, s3ctypes.S3Tag{Key: aws.String(key), Value: aws.String(req.Tags[key])})
+		}
+		return &s3ctypes.JobOperation{S3PutObjectTagging: &s3ctypes.S3SetObjectTaggingOperation{TagSet: tags}}, nil
+
+	case BatchOperationACL:
+		if bucket.ACLDisabled() {
+			return nil, NewInvalidRequestError(fmt.Sprintf("bucket '%s' has ACLs disabled", bucket.Name))
+		}
+		if req.Visibility == "" {
+			return nil, NewInvalidRequestError("visibility is required for acl")
+		}
+		return &s3ctypes.JobOperation{S3PutObjectAcl: &s3ctypes.S3SetObjectAclOperation{
+			AccessControlPolicy: &s3ctypes.S3AccessControlPolicy{
+				CannedAccessControlList: s3ctypes.S3CannedAccessControlList(bucket.ObjectACL(req.Visibility)),
+			},
+		}}, nil
+
+	default:
+		return nil, NewInvalidRequestError(fmt.Sprintf("operation must be '%s', '%s' or '%s'", BatchOperationCopy, BatchOperationTag, BatchOperationACL))
+	}
+}
+
+// writeBatchManifest streams a CSV manifest of the objects under prefix to manifestKey and returns
+// the object count and the manifest ETag. Keys under the manifest prefix are left out.
+func (o *Operations) writeBatchManifest(ctx context.Context, bucket *Bucket, prefix, manifestKey string) (int64, string, error) {
+	skip := bucket.GetFullPath(bucket.Config.Batch.ManifestPrefix)
+
+	pr, pw := io.Pipe()
+	var count int64
+	go func() {
+		w := csv.NewWriter(pw)
+		err := o.walkObjects(ctx, bucket, bucket.GetFullPath(prefix), func(obj types.Object) error {
+			key := aws.ToString(obj.Key)
+			if strings.HasPrefix(key, skip) {
+				return nil
+			}
+			count++
+			// Manifest keys are URL-encoded
+			return w.Write([]string{bucket.Config.Bucket, url.PathEscape(key)})
+		})
+		w.Flush()
+		if err == nil {
+			err = w.Error()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return 0, "", err
+	}
+	defer bucket.Release()
+
+	// The manifest size is unknown while listing
+	result, err := bucket.NewUploader(-1).Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket.Config.Bucket),
+		Key:         aws.String(manifestKey),
+		Body:        pr,
+		ContentType: aws.String("text/csv"),
+	})
+	if err != nil {
+		pr.CloseWithError(err)
+		o.abortInterruptedUpload(ctx, bucket, manifestKey, err)
+		return 0, "", err
+	}
+	bucket.filter.add(manifestKey)
+
+	return count, aws.ToString(result.ETag), nil
+}
+
+// deleteBatchManifest removes the manifest of a job that was not created
+func (o *Operations) deleteBatchManifest(ctx context.Context, bucket *Bucket, manifestKey string) {
+	if _, err := bucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(manifestKey),
+	}); err != nil {
+		o.log.Warn("failed to delete unused batch manifest",
+			zap.String("bucket", bucket.Name),
+			zap.String("key", manifestKey),
+			zap.Error(err),
+		)
+	}
+}
+
+// GetBatchJob returns the status and progress of an S3 Batch Operations job
+func (o *Operations) GetBatchJob(ctx context.Context, req *GetBatchJobRequest, resp *GetBatchJobResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	if req.JobID == "" {
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_status", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("job_id is required")
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_status", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "GetBatchJob", "batch_status", bucket.Name); err != nil {
+		return err
+	}
+
+	if bucket.Config.Batch == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_status", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("bucket '%s' has no batch configured", req.Bucket))
+	}
+
+	result, err := s3control.NewFromConfig(bucket.awsConfig).DescribeJob(ctx, &s3control.DescribeJobInput{
+		AccountId: aws.String(bucket.Config.Batch.AccountID),
+		JobId:     aws.String(req.JobID),
+	})
+	if err != nil {
+		o.log.Error("failed to describe batch job",
+			zap.String("bucket", req.Bucket),
+			zap.String("job_id", req.JobID),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "batch_status", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("describe job", err)
+	}
+
+	job := result.Job
+	resp.JobID = aws.ToString(job.JobId)
+	resp.Description = aws.ToString(job.Description)
+	resp.Status = string(job.Status)
+	resp.StatusReason = aws.ToString(job.StatusUpdateReason)
+	if job.ProgressSummary != nil {
+		resp.TotalTasks = aws.ToInt64(job.ProgressSummary.TotalNumberOfTasks)
+		resp.SucceededTasks = aws.ToInt64(job.ProgressSummary.NumberOfTasksSucceeded)
+		resp.FailedTasks = aws.ToInt64(job.ProgressSummary.NumberOfTasksFailed)
+	}
+	for _, failure := range job.FailureReasons {
+		resp.Failures = append(resp.Failures, fmt.Sprintf("%s: %s", aws.ToString(failure.FailureCode), aws.ToString(failure.FailureReason)))
+	}
+	if job.CreationTime != nil {
+		resp.CreatedAt = job.CreationTime.Format(time.RFC3339)
+	}
+	if job.TerminationDate != nil {
+		resp.FinishedAt = job.TerminationDate.Format(time.RFC3339)
+	}
+	resp.ReportPrefix = bucket.Config.Batch.ManifestPrefix + "reports/job-" + resp.JobID + "/"
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "batch_status", "success")
+	return nil
+}
@@ -2,12 +2,18 @@ package s3
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"go.uber.org/zap"
 )
@@ -17,12 +23,36 @@ type BucketManager struct {
 	// Map of bucket name to bucket instance
 	buckets map[string]*Bucket
 
+	// Map of alias to the bucket name it resolves to
+	aliases map[string]string
+
 	// Map of server configurations
 	servers map[string]*ServerConfig
 
 	// Default bucket name
 	defaultBucket string
 
+	// Limit of concurrent operations across all buckets (nil when unlimited)
+	global *globalLimiter
+
+	// Longest wait for concurrency slots of buckets registered afterwards (0: unlimited)
+	maxQueueWait time.Duration
+
+	// Receives every S3 API request of registered buckets (nil disables tracking)
+	observeRequest requestObserver
+
+	// Receives the bucket name whenever its retry budget suppresses a retry (nil disables tracking)
+	observeRetrySuppressed func(bucket string)
+
+	// Receives the outcome of hedged reads (nil disables tracking)
+	observeHedge hedgeObserver
+
+	// Receives DNS, connect, TLS and TTFB durations of S3 requests (nil disables tracing)
+	observePhase phaseObserver
+
+	// Receives the body sizes of object reads and writes (nil disables export)
+	observePayload payloadObserver
+
 	// Logger
 	log *zap.Logger
 
@@ -44,14 +74,90 @@ type Bucket struct {
 	// Client is the AWS S3 client
 	Client *s3.Client
 
+	// Client signing with read_credentials (nil without them)
+	readClient *s3.Client
+
+	// awsConfig is the configuration Client was created from, reused for other AWS services
+	awsConfig aws.Config
+
+	// Capabilities are the S3 features supported by the server, probed at registration
+	Capabilities Capabilities
+
+	// Cached usage for write_policy.quota
+	usage bucketUsage
+
+	// Bloom filter of keys for exists_filter
+	filter existsFilter
+
+	// Retry token bucket shared by the clients of the bucket
+	retries *retryBudget
+
+	// Size distributions of object reads and writes
+	payloads *payloadStats
+
+	// Presigned URLs reused by GetPublicURL (nil without presign_cache)
+	presigned *presignCache
+
+	// Serializes appends to the chain of an audit bucket
+	audit sync.Mutex
+
 	// Semaphore for limiting concurrent operations
 	sem chan struct{}
+
+	// Number of operations waiting for a semaphore slot
+	waiting atomic.Int64
+
+	// Limit shared by all buckets (nil when unlimited)
+	global *globalLimiter
+
+	// Longest wait of Acquire for the slots (0: unlimited)
+	maxQueueWait time.Duration
+}
+
+// globalLimiter bounds concurrent operations across all buckets
+type globalLimiter struct {
+	// Semaphore shared by all buckets
+	sem chan struct{}
+
+	// Number of operations waiting for a global slot
+	waiting atomic.Int64
+
+	// Receives the time spent waiting for a global slot
+	observe func(wait time.Duration)
+}
+
+// acquire blocks until a global slot is available, or returns the context error when ctx is done first
+func (gl *globalLimiter) acquire(ctx context.Context) error {
+	if gl == nil {
+		return nil
+	}
+
+	start := time.Now()
+	gl.waiting.Add(1)
+	defer gl.waiting.Add(-1)
+
+	select {
+	case gl.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	gl.observe(time.Since(start))
+	return nil
+}
+
+// release frees a global slot
+func (gl *globalLimiter) release() {
+	if gl == nil {
+		return
+	}
+	<-gl.sem
 }
 
 // NewBucketManager creates a new bucket manager
 func NewBucketManager(log *zap.Logger) *BucketManager {
 	return &BucketManager{
 		buckets: make(map[string]*Bucket),
+		aliases: make(map[string]string),
 		servers: make(map[string]*ServerConfig),
 		log:     log,
 	}
@@ -64,40 +170,196 @@ func (bm *BucketManager) SetServers(servers map[string]*ServerConfig) {
 	bm.servers = servers
 }
 
+// SetGlobalLimit bounds concurrent operations across all buckets; limit <= 0 disables the bound.
+// Must be called before buckets are registered.
+func (bm *BucketManager) SetGlobalLimit(limit int, observe func(wait time.Duration)) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if limit <= 0 {
+		bm.global = nil
+		return
+	}
+
+	bm.global = &globalLimiter{
+		sem:     make(chan struct{}, limit),
+		observe: observe,
+	}
+}
+
+// SetMaxQueueWait bounds how long operations wait for concurrency slots; wait <= 0 disables the
+// bound. Must be called before buckets are registered.
+func (bm *BucketManager) SetMaxQueueWait(wait time.Duration) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.maxQueueWait = max(wait, 0)
+}
+
+// SetRequestObserver sets the observer of S3 API requests for buckets registered afterwards
+func (bm *BucketManager) SetRequestObserver(observe requestObserver) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.observeRequest = observe
+}
+
+// SetRetryObserver sets the observer of retries suppressed by bucket retry budgets, for buckets
+// registered afterwards
+func (bm *BucketManager) SetRetryObserver(observe func(bucket string)) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.observeRetrySuppressed = observe
+}
+
+// SetPhaseObserver sets the observer of connection phases for buckets registered afterwards
+func (bm *BucketManager) SetPhaseObserver(observe phaseObserver) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.observePhase = observe
+}
+
+// SetPayloadObserver sets the observer of object read and write sizes for buckets registered afterwards
+func (bm *BucketManager) SetPayloadObserver(observe payloadObserver) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.observePayload = observe
+}
+
+// SetHedgeObserver sets the observer of hedged reads for buckets registered afterwards
+func (bm *BucketManager) SetHedgeObserver(observe hedgeObserver) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.observeHedge = observe
+}
+
+// GlobalUsage returns in-flight and queued operations and the limit across all buckets
+func (bm *BucketManager) GlobalUsage() (inFlight int, queued int64, limit int) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	if bm.global == nil {
+		return 0, 0, 0
+	}
+	return len(bm.global.sem), bm.global.waiting.Load(), cap(bm.global.sem)
+}
+
+// RegisterServer registers a new server configuration at runtime
+func (bm *BucketManager) RegisterServer(name string, serverCfg *ServerConfig) error {
+	if err := serverCfg.Validate(); err != nil {
+		return fmt.Errorf("invalid server configuration: %w", err)
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if _, exists := bm.servers[name]; exists {
+		return fmt.Errorf("server '%s' already registered", name)
+	}
+
+	bm.servers[name] = serverCfg
+
+	bm.log.Debug("server registered",
+		zap.String("name", name),
+		zap.String("region", serverCfg.Region),
+		zap.String("endpoint", serverCfg.Endpoint),
+	)
+
+	return nil
+}
+
+// ValidateBucket validates a bucket configuration against the registered servers, which
+// RegisterServer may add to concurrently
+func (bm *BucketManager) ValidateBucket(bucketCfg *BucketConfig) error {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	return bucketCfg.Validate(bm.servers)
+}
+
 // RegisterBucket registers a new bucket with S3 client initialization
 func (bm *BucketManager) RegisterBucket(ctx context.Context, name string, bucketCfg *BucketConfig) error {
 	bm.mu.Lock()
-	defer bm.mu.Unlock()
 
 	// Check if bucket already exists
 	if _, exists := bm.buckets[name]; exists {
+		bm.mu.Unlock()
 		return fmt.Errorf("bucket '%s' already registered", name)
 	}
 
 	// Get server configuration
 	serverCfg, exists := bm.servers[bucketCfg.Server]
 	if !exists {
+		bm.mu.Unlock()
 		return fmt.Errorf("server '%s' not found for bucket '%s'", bucketCfg.Server, name)
 	}
 
 	// Validate bucket configuration with server context
 	if err := bucketCfg.Validate(bm.servers); err != nil {
+		bm.mu.Unlock()
 		return fmt.Errorf("invalid bucket configuration: %w", err)
 	}
 
+	observeRequest := bm.observeRequest
+	observeRetrySuppressed := bm.observeRetrySuppressed
+	observeHedge := bm.observeHedge
+	observePhase := bm.observePhase
+	observePayload := bm.observePayload
+	bm.mu.Unlock()
+
 	// Create AWS configuration
 	awsCfg, err := bm.createAWSConfig(ctx, serverCfg)
 	if err != nil {
 		return fmt.Errorf("failed to create AWS config: %w", err)
 	}
 
-	// Create S3 client
-	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+	// Every client created from awsCfg draws retries from the same budget
+	retries := newRetryBudget(name, bucketCfg.RetryBudget, observeRetrySuppressed)
+	awsCfg.Retryer = retries.retryer(bucketCfg.RetryBudget)
+
+	if bucketCfg.AssumeRole != nil {
+		awsCfg.Credentials = bucketCfg.AssumeRole.credentials(awsCfg)
+	}
+
+	payloads := &payloadStats{}
+
+	clientOptions := func(o *s3.Options) {
 		if serverCfg.Endpoint != "" {
 			o.BaseEndpoint = aws.String(serverCfg.Endpoint)
 			o.UsePathStyle = true // Required for MinIO and some S3-compatible services
 		}
-	})
+		serverCfg.Signing.apply(o)
+		if serverCfg.Anonymous {
+			o.Credentials = aws.AnonymousCredentials{}
+			o.APIOptions = append(o.APIOptions, readOnlyMiddleware(name))
+		}
+		applyCompatibility(o, serverCfg.Compatibility)
+		if observeRequest != nil {
+			o.APIOptions = append(o.APIOptions, requestCostMiddleware(name, observeRequest))
+		}
+		o.APIOptions = append(o.APIOptions, operationStatsMiddleware(), payloadSizeMiddleware(name, payloads, observePayload))
+		if bucketCfg.DirectoryBucket {
+			o.APIOptions = append(o.APIOptions, directoryListingMiddleware())
+		}
+		// Requests go to the access point's region; Multi-Region Access Points are signed with SigV4A
+		if _, ok := bucketCfg.accessPoint(); ok {
+			o.UseARNRegion = true
+			o.DisableMultiRegionAccessPoints = false
+		}
+		// Traced below hedging, so both requests of a hedged read are measured
+		o.HTTPClient = &tracingClient{next: o.HTTPClient, bucket: name, observe: observePhase}
+		if bucketCfg.Hedging != nil {
+			o.HTTPClient = newHedgingClient(o.HTTPClient, name, bucketCfg.Hedging, observeHedge, observeRequest)
+		}
+	}
+
+	// Create S3 client
+	s3Client := s3.NewFromConfig(awsCfg, clientOptions)
+
+	var readClient *s3.Client
+	if bucketCfg.ReadCredentials != nil {
+		readClient = s3.NewFromConfig(awsCfg, clientOptions, func(o *s3.Options) {
+			o.Credentials = bucketCfg.ReadCredentials.provider()
+		})
+	}
 
 	// Create bucket instance
 	bucket := &Bucket{
@@ -105,11 +367,61 @@ func (bm *BucketManager) RegisterBucket(ctx context.Context, name string, bucket
 		Config:       bucketCfg,
 		ServerConfig: serverCfg,
 		Client:       s3Client,
+		readClient:   readClient,
+		awsConfig:    awsCfg,
+		retries:      retries,
+		payloads:     payloads,
+		presigned:    newPresignCache(bucketCfg.PresignCache),
 		sem:          make(chan struct{}, bucketCfg.MaxConcurrentOperations),
+		global:       bm.global,
+		maxQueueWait: bm.maxQueueWait,
+	}
+
+	// Provision and probe without holding the lock, so slow servers do not block other buckets
+	if bucketCfg.CreateIfMissing {
+		if err := bm.createBucketIfMissing(ctx, bucket); err != nil {
+			return err
+		}
+	}
+
+	if bucketCfg.MinIO != nil {
+		if err := bm.setupMinIO(ctx, bucket); err != nil {
+			return fmt.Errorf("failed to provision minio bucket '%s': %w", name, err)
+		}
+	}
+
+	bucket.Capabilities = bm.probeCapabilities(ctx, bucket)
+	bm.detectObjectOwnership(ctx, bucket)
+
+	if bucketCfg.Expiration != nil || bucketCfg.Temporary != nil {
+		if err := bm.installLifecycleRules(ctx, bucket); err != nil {
+			return fmt.Errorf("failed to set up lifecycle rules for bucket '%s': %w", name, err)
+		}
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if _, exists := bm.buckets[name]; exists {
+		return fmt.Errorf("bucket '%s' already registered", name)
+	}
+	if target, exists := bm.aliases[name]; exists {
+		return fmt.Errorf("bucket name '%s' is already an alias of bucket '%s'", name, target)
+	}
+	for _, alias := range bucketCfg.Aliases {
+		if _, exists := bm.buckets[alias]; exists {
+			return fmt.Errorf("alias '%s' is already a bucket name", alias)
+		}
+		if target, exists := bm.aliases[alias]; exists {
+			return fmt.Errorf("alias '%s' is already an alias of bucket '%s'", alias, target)
+		}
 	}
 
 	// Store bucket
 	bm.buckets[name] = bucket
+	for _, alias := range bucketCfg.Aliases {
+		bm.aliases[alias] = name
+	}
 
 	bm.log.Debug("bucket registered",
 		zap.String("name", name),
@@ -122,12 +434,12 @@ func (bm *BucketManager) RegisterBucket(ctx context.Context, name string, bucket
 	return nil
 }
 
-// GetBucket retrieves a bucket by name
+// GetBucket retrieves a bucket by name or alias
 func (bm *BucketManager) GetBucket(name string) (*Bucket, error) {
 	bm.mu.RLock()
 	defer bm.mu.RUnlock()
 
-	bucket, exists := bm.buckets[name]
+	bucket, exists := bm.buckets[bm.resolveLocked(name)]
 	if !exists {
 		return nil, fmt.Errorf("bucket '%s' not found", name)
 	}
@@ -157,6 +469,7 @@ func (bm *BucketManager) SetDefault(name string) error {
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
 
+	name = bm.resolveLocked(name)
 	if _, exists := bm.buckets[name]; !exists {
 		return fmt.Errorf("bucket '%s' not found", name)
 	}
@@ -179,6 +492,45 @@ func (bm *BucketManager) ListBuckets() []string {
 	return names
 }
 
+// resolve returns the bucket name an alias resolves to, or name itself
+func (bm *BucketManager) resolve(name string) string {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	return bm.resolveLocked(name)
+}
+
+// resolveLocked returns the bucket name an alias resolves to, or name itself; bm.mu must be held
+func (bm *BucketManager) resolveLocked(name string) string {
+	if target, exists := bm.aliases[name]; exists {
+		return target
+	}
+	return name
+}
+
+// registered returns the registered bucket name resolves to, following aliases; false when
+// no such bucket is registered
+func (bm *BucketManager) registered(name string) (string, bool) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	resolved := bm.resolveLocked(name)
+	_, exists := bm.buckets[resolved]
+	return resolved, exists
+}
+
+// Aliases returns a copy of the alias to bucket name mapping
+func (bm *BucketManager) Aliases() map[string]string {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	aliases := make(map[string]string, len(bm.aliases))
+	for alias, name := range bm.aliases {
+		aliases[alias] = name
+	}
+	return aliases
+}
+
 // GetDefaultBucketName returns the default bucket name
 func (bm *BucketManager) GetDefaultBucketName() string {
 	bm.mu.RLock()
@@ -195,11 +547,20 @@ func (bm *BucketManager) RemoveBucket(name string) error {
 		return fmt.Errorf("cannot remove default bucket '%s'", name)
 	}
 
+	if target, exists := bm.aliases[name]; exists {
+		return fmt.Errorf("'%s' is an alias of bucket '%s'", name, target)
+	}
+
 	if _, exists := bm.buckets[name]; !exists {
 		return fmt.Errorf("bucket '%s' not found", name)
 	}
 
 	delete(bm.buckets, name)
+	for alias, target := range bm.aliases {
+		if target == name {
+			delete(bm.aliases, alias)
+		}
+	}
 	bm.log.Debug("bucket removed", zap.String("name", name))
 	return nil
 }
@@ -222,40 +583,199 @@ func (bm *BucketManager) CloseAll() error {
 
 // createAWSConfig creates AWS configuration from server config
 func (bm *BucketManager) createAWSConfig(ctx context.Context, serverCfg *ServerConfig) (aws.Config, error) {
-	// Create credentials provider
-	credsProvider := credentials.NewStaticCredentialsProvider(
-		serverCfg.Credentials.Key,
-		serverCfg.Credentials.Secret,
-		serverCfg.Credentials.Token,
-	)
+	var opts []func(*config.LoadOptions) error
+	if serverCfg.Region != "" {
+		opts = append(opts, config.WithRegion(serverCfg.Region))
+	}
+
+	if serverCfg.Profile != "" {
+		// Credentials (static, SSO, assume-role or process) and region come from the profile
+		opts = append(opts, config.WithSharedConfigProfile(serverCfg.Profile))
+	} else if serverCfg.SSO == nil {
+		// Create credentials provider
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			serverCfg.Credentials.Key,
+			serverCfg.Credentials.Secret,
+			serverCfg.Credentials.Token,
+		)))
+	}
 
 	// Load AWS config with custom credentials
-	awsCfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(serverCfg.Region),
-		config.WithCredentialsProvider(credsProvider),
-	)
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
+	if awsCfg.Region == "" {
+		return aws.Config{}, fmt.Errorf("profile '%s' does not define a region, set region on the server", serverCfg.Profile)
+	}
+
+	if serverCfg.SSO != nil {
+		awsCfg.Credentials, err = serverCfg.SSO.provider(awsCfg)
+		if err != nil {
+			return aws.Config{}, err
+		}
+	}
+
 	return awsCfg, nil
 }
 
-// Acquire acquires a semaphore slot for the bucket
-func (b *Bucket) Acquire() {
-	b.sem <- struct{}{}
+// Acquire acquires a semaphore slot for the bucket and, when configured, a global slot.
+// The time spent waiting is attributed to the operation tracked in ctx, if any. It returns the
+// context error without holding any slot when ctx is done before the slots are free, and a
+// QUEUE_TIMEOUT error when max_queue_wait passes first.
+func (b *Bucket) Acquire(ctx context.Context) error {
+	waitCtx, cancel := b.queueWaitContext(ctx)
+	defer cancel()
+
+	if err := b.acquireBucket(waitCtx); err != nil {
+		return b.queueWaitError(ctx, err)
+	}
+
+	start := time.Now()
+	if err := b.global.acquire(waitCtx); err != nil {
+		b.releaseBucket()
+		return b.queueWaitError(ctx, err)
+	}
+	operationStatsFrom(ctx).addQueueWait(b.Name, time.Since(start))
+	return nil
+}
+
+// queueWaitContext bounds the wait for slots by max_queue_wait
+func (b *Bucket) queueWaitContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.maxQueueWait <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, b.maxQueueWait)
 }
 
-// Release releases a semaphore slot for the bucket
+// queueWaitError turns a wait cut short by max_queue_wait rather than by ctx into QUEUE_TIMEOUT
+func (b *Bucket) queueWaitError(ctx context.Context, err error) error {
+	if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return NewQueueTimeoutError(b.Name, b.maxQueueWait)
+	}
+	return err
+}
+
+// Release releases the slots acquired by Acquire
 func (b *Bucket) Release() {
+	b.global.release()
+	b.releaseBucket()
+}
+
+// acquireBucket acquires only the slot of the bucket, without a global slot
+func (b *Bucket) acquireBucket(ctx context.Context) error {
+	start := time.Now()
+	b.waiting.Add(1)
+	defer b.waiting.Add(-1)
+
+	select {
+	case b.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	operationStatsFrom(ctx).addQueueWait(b.Name, time.Since(start))
+	return nil
+}
+
+// releaseBucket releases a slot acquired with acquireBucket
+func (b *Bucket) releaseBucket() {
 	<-b.sem
 }
 
+// acquireBuckets acquires the slots of an operation spanning several buckets: the slot of every
+// distinct bucket in name order, then a single global slot. Single-bucket operations take their
+// bucket slot before the global slot too, so taking the global slot last and the bucket slots in
+// one order keeps operations from waiting on each other in a cycle. The returned function releases
+// every slot; on error no slot is held.
+func acquireBuckets(ctx context.Context, buckets ...*Bucket) (func(), error) {
+	distinct := make([]*Bucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		if !slices.Contains(distinct, bucket) {
+			distinct = append(distinct, bucket)
+		}
+	}
+	sort.Slice(distinct, func(i, j int) bool { return distinct[i].Name < distinct[j].Name })
+
+	// Every bucket shares the plugin's max_queue_wait, which bounds the wait for all slots together
+	waitCtx, cancel := distinct[0].queueWaitContext(ctx)
+	defer cancel()
+
+	held := make([]*Bucket, 0, len(distinct))
+	release := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].releaseBucket()
+		}
+	}
+	for _, bucket := range distinct {
+		if err := bucket.acquireBucket(waitCtx); err != nil {
+			release()
+			return nil, bucket.queueWaitError(ctx, err)
+		}
+		held = append(held, bucket)
+	}
+
+	// Buckets of one operation share the global limiter
+	global := distinct[0].global
+	start := time.Now()
+	if err := global.acquire(waitCtx); err != nil {
+		release()
+		return nil, distinct[0].queueWaitError(ctx, err)
+	}
+	operationStatsFrom(ctx).addQueueWait(distinct[0].Name, time.Since(start))
+
+	return func() {
+		global.release()
+		release()
+	}, nil
+}
+
+// InFlight returns the number of operations currently holding a semaphore slot
+func (b *Bucket) InFlight() int {
+	return len(b.sem)
+}
+
+// Queued returns the number of operations waiting for a semaphore slot
+func (b *Bucket) Queued() int64 {
+	return b.waiting.Load()
+}
+
 // GetFullPath returns the full S3 key including prefix
 func (b *Bucket) GetFullPath(pathname string) string {
 	return b.Config.GetFullPath(pathname)
 }
 
+// UploadMethod returns how an object of size bytes (negative when unknown) is uploaded:
+// UploadMethodSingle below multipart_threshold or without multipart support, else UploadMethodMultipart
+func (b *Bucket) UploadMethod(size int64) string {
+	if size >= 0 && (size < b.Config.MultipartThreshold || !b.Capabilities.Multipart) {
+		return UploadMethodSingle
+	}
+	return UploadMethodMultipart
+}
+
+// NewUploader creates an upload manager for an object of size bytes (negative when unknown)
+// with a part size chosen by the bucket configuration
+func (b *Bucket) NewUploader(size int64) *manager.Uploader {
+	return manager.NewUploader(b.Client, func(u *manager.Uploader) {
+		u.PartSize = b.Config.PartSizeFor(size)
+		u.Concurrency = b.Config.Concurrency
+		u.MaxUploadParts = b.Config.MaxParts
+
+		if size < 0 {
+			return
+		}
+		if b.UploadMethod(size) == UploadMethodSingle {
+			// A part bigger than the object makes the uploader send a single PutObject
+			u.PartSize = max(size+1, manager.MinUploadPartSize)
+		} else if u.PartSize >= size {
+			// Objects at the threshold are still split, into two parts of whole MiB
+			const mib = 1024 * 1024
+			u.PartSize = max((size/2+mib-1)/mib*mib, manager.MinUploadPartSize)
+		}
+	})
+}
+
 // GetVisibility returns the ACL for the bucket
 func (b *Bucket) GetVisibility() string {
 	return b.Config.GetVisibility()
@@ -0,0 +1,43 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBucketAcquireMaxQueueWait(t *testing.T) {
+	bucket := &Bucket{
+		Name:         "uploads",
+		sem:          make(chan struct{}, 1),
+		maxQueueWait: 10 * time.Millisecond,
+	}
+	if err := bucket.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer bucket.Release()
+
+	var s3Err *S3Error
+	if err := bucket.Acquire(context.Background()); !errors.As(err, &s3Err) || s3Err.Code != ErrQueueTimeout {
+		t.Errorf("Acquire() of a full bucket error = %v, want %s", err, ErrQueueTimeout)
+	}
+	if env := newErrorEnvelope(s3Err); env == nil || !env.Retryable {
+		t.Errorf("QUEUE_TIMEOUT envelope is not retryable")
+	}
+
+	// The caller's own deadline is not a queue timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bucket.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	release, err := acquireBuckets(context.Background(), bucket)
+	if err == nil {
+		release()
+	}
+	if !errors.As(err, &s3Err) || s3Err.Code != ErrQueueTimeout {
+		t.Errorf("acquireBuckets() of a full bucket error = %v, want %s", err, ErrQueueTimeout)
+	}
+}
@@ -0,0 +1,110 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxCallerMetadataLength bounds each caller metadata value, which ends up in every log line
+	maxCallerMetadataLength = 256
+
+	// Audit object metadata keys recording the caller that appended the object
+	auditAppMetadata   = "audit-app"
+	auditUserMetadata  = "audit-user"
+	auditRouteMetadata = "audit-route"
+)
+
+// CallerMetadata describes the application call behind an operation. The rpc layer attaches it
+// to the operation context, so logs, slow log entries and audit records carry it without every
+// PHP application logging the same details.
+type CallerMetadata struct {
+	App   string `json:"app,omitempty"`   // Application name, e.g. "shop-api"
+	User  string `json:"user,omitempty"`  // Opaque user identifier; pass a hash, not a user ID or email
+	Route string `json:"route,omitempty"` // Route or command that issued the call, e.g. "POST /avatars"
+}
+
+// validate rejects values too long to be logged with every operation
+func (m *CallerMetadata) validate() error {
+	for name, value := range map[string]string{"app": m.App, "user": m.User, "route": m.Route} {
+		if len(value) > maxCallerMetadataLength {
+			return NewInvalidRequestError(fmt.Sprintf("caller.%s must be at most %d bytes", name, maxCallerMetadataLength))
+		}
+	}
+	return nil
+}
+
+// fields returns the metadata as log fields, omitting empty values
+func (m *CallerMetadata) fields() []zap.Field {
+	if m == nil {
+		return nil
+	}
+
+	fields := make([]zap.Field, 0, 3)
+	if m.App != "" {
+		fields = append(fields, zap.String("caller_app", m.App))
+	}
+	if m.User != "" {
+		fields = append(fields, zap.String("caller_user", m.User))
+	}
+	if m.Route != "" {
+		fields = append(fields, zap.String("caller_route", m.Route))
+	}
+	return fields
+}
+
+// auditMetadata returns the metadata recorded on audit objects, omitting empty values
+func (m *CallerMetadata) auditMetadata() map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	metadata := make(map[string]string, 3)
+	for key, value := range map[string]string{auditAppMetadata: m.App, auditUserMetadata: m.User, auditRouteMetadata: m.Route} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// callerMetadataCarrier is implemented by requests embedding Caller
+type callerMetadataCarrier interface {
+	callerMetadata() *CallerMetadata
+}
+
+// requestCallerMetadata returns the caller metadata of a request, if any
+func requestCallerMetadata(req any) *CallerMetadata {
+	if carrier, ok := req.(callerMetadataCarrier); ok {
+		return carrier.callerMetadata()
+	}
+	return nil
+}
+
+// callerMetadataKey is the context key carrying the caller metadata of an operation
+type callerMetadataKey struct{}
+
+// withCallerMetadata attaches caller metadata to ctx; nil leaves ctx unchanged
+func withCallerMetadata(ctx context.Context, metadata *CallerMetadata) context.Context {
+	if metadata == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, callerMetadataKey{}, metadata)
+}
+
+// CallerMetadataFrom returns the caller metadata of the operation running with ctx, or nil.
+// Interceptors of other plugins use it to enrich their own logs and traces.
+func CallerMetadataFrom(ctx context.Context) *CallerMetadata {
+	metadata, _ := ctx.Value(callerMetadataKey{}).(*CallerMetadata)
+	return metadata
+}
+
+// loggerFor returns log with the caller metadata of ctx as fields
+func loggerFor(ctx context.Context, log *zap.Logger) *zap.Logger {
+	if fields := CallerMetadataFrom(ctx).fields(); len(fields) > 0 {
+		return log.With(fields...)
+	}
+	return log
+}
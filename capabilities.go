@@ -0,0 +1,172 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.uber.org/zap"
+)
+
+const (
+	// CompatibilityAWS assumes full S3 support and skips probing (default)
+	CompatibilityAWS = "aws"
+
+	// CompatibilityCeph targets Ceph RGW, which rejects the SDK's default flexible checksums
+	CompatibilityCeph = "ceph"
+
+	// CompatibilityStorj targets the Storj S3 gateway, which has no ACLs or flexible checksums
+	CompatibilityStorj = "storj"
+
+	// CompatibilityGeneric targets other partial S3 implementations
+	CompatibilityGeneric = "generic"
+
+	// CompatibilityMinIO targets MinIO and enables bucket provisioning helpers (see MinIOSetupConfig)
+	CompatibilityMinIO = "minio"
+
+	// capabilityProbeTimeout bounds the probe requests sent when a bucket is registered
+	capabilityProbeTimeout = 10 * time.Second
+)
+
+// Capabilities lists the S3 features a bucket's server supports
+type Capabilities struct {
+	Versioning bool `json:"versioning"`
+	Tagging    bool `json:"tagging"`
+	ACL        bool `json:"acl"`
+	Multipart  bool `json:"multipart"`
+	Checksums  bool `json:"checksums"`
+
+	// ResumablePut sends client-driven multipart uploads as one resumable session (GCS XML API)
+	ResumablePut bool `json:"resumable_put"`
+}
+
+// baselineCapabilities returns the capabilities assumed for a compatibility mode before probing
+func baselineCapabilities(compatibility string) Capabilities {
+	caps := Capabilities{Versioning: true, Tagging: true, ACL: true, Multipart: true, Checksums: true}
+
+	switch compatibility {
+	case CompatibilityCeph, CompatibilityGeneric:
+		caps.Checksums = false
+	case CompatibilityStorj:
+		caps.ACL = false
+		caps.Checksums = false
+	}
+
+	return caps
+}
+
+// validateCompatibility validates and defaults a server compatibility mode
+func validateCompatibility(compatibility *string) error {
+	switch *compatibility {
+	case "":
+		*compatibility = CompatibilityAWS
+	case CompatibilityAWS, CompatibilityCeph, CompatibilityStorj, CompatibilityGeneric, CompatibilityMinIO:
+	default:
+		return fmt.Errorf("compatibility must be '%s', '%s', '%s', '%s' or '%s', got '%s'",
+			CompatibilityAWS, CompatibilityCeph, CompatibilityStorj, CompatibilityGeneric, CompatibilityMinIO, *compatibility)
+	}
+	return nil
+}
+
+// applyCompatibility configures an S3 client for the server's compatibility mode
+func applyCompatibility(o *s3.Options, compatibility string) {
+	if !baselineCapabilities(compatibility).Checksums {
+		// Only send and validate checksums when an operation requires them
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	}
+}
+
+// probeCapabilities narrows the baseline capabilities of a bucket by sending read-only requests.
+// Only explicit "not implemented" answers disable a feature; other failures (e.g., access denied)
+// leave it enabled so a restrictive policy does not hide supported features.
+func (bm *BucketManager) probeCapabilities(ctx context.Context, bucket *Bucket) Capabilities {
+	if bucket.Config.DirectoryBucket {
+		return directoryCapabilities
+	}
+
+	caps := baselineCapabilities(bucket.ServerConfig.Compatibility)
+	// Only the GCS XML API accepts Content-Range uploads to a resumable session
+	caps.ResumablePut = bucket.ServerConfig.Preset == PresetGCS
+	if bucket.ServerConfig.Compatibility == CompatibilityAWS {
+		return caps
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, capabilityProbeTimeout)
+	defer cancel()
+
+	name := aws.String(bucket.Config.Bucket)
+	probes := []struct {
+		feature string
+		enabled *bool
+		probe   func() error
+	}{
+		{"versioning", &caps.Versioning, func() error {
+			_, err := bucket.Client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: name})
+			return err
+		}},
+		{"tagging", &caps.Tagging, func() error {
+			_, err := bucket.Client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{Bucket: name})
+			return err
+		}},
+		{"acl", &caps.ACL, func() error {
+			_, err := bucket.Client.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: name})
+			return err
+		}},
+		{"multipart", &caps.Multipart, func() error {
+			_, err := bucket.Client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: name, MaxUploads: aws.Int32(1)})
+			return err
+		}},
+	}
+
+	for _, p := range probes {
+		if !*p.enabled {
+			continue
+		}
+
+		if err := p.probe(); notImplemented(err) {
+			*p.enabled = false
+			bm.log.Info("s3 feature not supported by server, degrading",
+				zap.String("bucket", bucket.Name),
+				zap.String("feature", p.feature),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return caps
+}
+
+// notImplemented reports whether err means the server does not implement the requested API
+func notImplemented(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotImplemented", "MethodNotAllowed", "UnsupportedOperation":
+			return true
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		status := respErr.HTTPStatusCode()
+		return status == http.StatusNotImplemented || status == http.StatusMethodNotAllowed
+	}
+
+	return false
+}
+
+// ACLDisabled reports whether ACLs are omitted for the bucket, by configuration or lack of support
+func (b *Bucket) ACLDisabled() bool {
+	return b.Config.DisableACL || !b.Capabilities.ACL
+}
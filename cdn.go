@@ -0,0 +1,208 @@
+package s3
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// CDNProviderCloudFront invalidates paths of an AWS CloudFront distribution
+	CDNProviderCloudFront = "cloudfront"
+
+	// maxInvalidationPaths is the CloudFront limit of paths per invalidation request
+	maxInvalidationPaths = 3000
+)
+
+// CDNConfig describes the CDN serving a bucket
+type CDNConfig struct {
+	// Provider is the CDN provider (default: "cloudfront")
+	Provider string `mapstructure:"provider"`
+
+	// DistributionID is the CloudFront distribution serving the bucket
+	DistributionID string `mapstructure:"distribution_id"`
+
+	// URL is the CDN base URL returned by GetPublicURL instead of the S3 endpoint (optional)
+	URL string `mapstructure:"url"`
+
+	// OriginPath is the distribution origin path, stripped from keys when building CDN paths (optional)
+	OriginPath string `mapstructure:"origin_path"`
+
+	// KeyPairID is the CloudFront public key ID signing CDN URLs with source_ip conditions (optional)
+	KeyPairID string `mapstructure:"key_pair_id"`
+
+	// PrivateKeyFile is the PEM file holding the private key of KeyPairID (optional)
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+
+	// Key loaded from PrivateKeyFile
+	signingKey *rsa.PrivateKey
+}
+
+// Validate validates the CDN configuration
+func (cc *CDNConfig) Validate() error {
+	if cc.Provider == "" {
+		cc.Provider = CDNProviderCloudFront
+	}
+
+	if cc.Provider != CDNProviderCloudFront {
+		return fmt.Errorf("unsupported cdn provider '%s', must be '%s'", cc.Provider, CDNProviderCloudFront)
+	}
+
+	if cc.DistributionID == "" {
+		return fmt.Errorf("cdn.distribution_id is required")
+	}
+
+	cc.URL = strings.TrimSuffix(cc.URL, "/")
+	cc.OriginPath = strings.Trim(cc.OriginPath, "/")
+
+	return cc.loadSigningKey()
+}
+
+// cdnPath returns the escaped path of an S3 key as seen through the CDN
+func (cc *CDNConfig) cdnPath(key string) string {
+	if cc.OriginPath != "" {
+		key = strings.TrimPrefix(key, cc.OriginPath+"/")
+	}
+	return "/" + escapeKeyPath(key)
+}
+
+// cdnInvalidator purges cached paths from a CDN
+type cdnInvalidator interface {
+	// Invalidate purges paths and returns the provider's invalidation ID and status
+	Invalidate(ctx context.Context, paths []string) (string, string, error)
+}
+
+// cloudFrontInvalidator issues CloudFront invalidations with the credentials of the bucket's server
+type cloudFrontInvalidator struct {
+	client         *cloudfront.Client
+	distributionID string
+}
+
+func (ci *cloudFrontInvalidator) Invalidate(ctx context.Context, paths []string) (string, string, error) {
+	result, err := ci.client.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(ci.distributionID),
+		InvalidationBatch: &cftypes.InvalidationBatch{
+			CallerReference: aws.String("rr-s3-" + strconv.FormatInt(time.Now().UnixNano(), 10)),
+			Paths: &cftypes.Paths{
+				Items:    paths,
+				Quantity: aws.Int32(int32(len(paths))),
+			},
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return aws.ToString(result.Invalidation.Id), aws.ToString(result.Invalidation.Status), nil
+}
+
+// newCDNInvalidator creates the invalidator of a bucket's CDN, with the bucket's credentials
+func newCDNInvalidator(bucket *Bucket) cdnInvalidator {
+	return &cloudFrontInvalidator{
+		client:         cloudfront.NewFromConfig(bucket.awsConfig),
+		distributionID: bucket.Config.CDN.DistributionID,
+	}
+}
+
+// InvalidateCDN purges pathnames from the CDN serving the bucket. A pathname ending with "*"
+// invalidates everything under it.
+func (o *Operations) InvalidateCDN(ctx context.Context, req *InvalidateCDNRequest, resp *InvalidateCDNResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	if len(req.Pathnames) == 0 || len(req.Pathnames) > maxInvalidationPaths {
+		o.plugin.metrics.RecordOperation(req.Bucket, "invalidate_cdn", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("pathnames must contain 1 to %d entries", maxInvalidationPaths))
+	}
+
+	// Validate request; a trailing wildcard is allowed
+	for i, pathname := range req.Pathnames {
+		base, wildcard := strings.CutSuffix(pathname, "*")
+		if base == "" && wildcard {
+			continue
+		}
+		if err := o.validatePathname(&base); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "invalidate_cdn", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+			return err
+		}
+		if wildcard {
+			base += "*"
+		}
+		req.Pathnames[i] = base
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "invalidate_cdn", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "InvalidateCDN", "invalidate_cdn", bucket.Name, req.Pathnames...); err != nil {
+		return err
+	}
+
+	if bucket.Config.CDN == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "invalidate_cdn", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("bucket '%s' has no cdn configured", req.Bucket))
+	}
+
+	paths := make([]string, 0, len(req.Pathnames))
+	for _, pathname := range req.Pathnames {
+		base, wildcard := strings.CutSuffix(pathname, "*")
+		if !wildcard {
+			paths = append(paths, bucket.Config.CDN.cdnPath(bucket.ObjectKey(base)))
+			continue
+		}
+
+		// Objects under a sharded prefix are spread over every shard
+		if err := bucket.requireUnsharded("wildcard invalidation"); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "invalidate_cdn", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return err
+		}
+		paths = append(paths, bucket.Config.CDN.cdnPath(bucket.GetFullPath(base))+"*")
+	}
+
+	id, status, err := newCDNInvalidator(bucket).Invalidate(ctx, paths)
+	if err != nil {
+		o.log.Error("failed to invalidate cdn paths",
+			zap.String("bucket", req.Bucket),
+			zap.String("distribution_id", bucket.Config.CDN.DistributionID),
+			zap.Int("paths", len(paths)),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "invalidate_cdn", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("create invalidation", err)
+	}
+
+	resp.InvalidationID = id
+	resp.Status = status
+	resp.Paths = paths
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "invalidate_cdn", "success")
+
+	o.log.Debug("cdn invalidation created",
+		zap.String("bucket", req.Bucket),
+		zap.String("invalidation_id", id),
+		zap.Int("paths", len(paths)),
+	)
+
+	return nil
+}
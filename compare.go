@@ -0,0 +1,310 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxCompareSamples bounds the number of ranged reads per object
+	maxCompareSamples = 64
+
+	// defaultCompareSampleSize is used when sampling is requested without a sample size
+	defaultCompareSampleSize int64 = 64 * 1024 // 64KB
+)
+
+// CompareObjects compares two objects by size, ETag, checksum and optional byte-range sampling
+func (o *Operations) CompareObjects(ctx context.Context, req *CompareObjectsRequest, resp *CompareObjectsResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	start := time.Now()
+
+	// Validate request
+	if err := o.validatePathname(&req.SourcePathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.SourceBucket, "compare", "error")
+		o.plugin.metrics.RecordError(req.SourceBucket, ErrInvalidPathname)
+		return err
+	}
+	if err := o.validatePathname(&req.DestPathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "compare", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, ErrInvalidPathname)
+		return err
+	}
+	if req.SampleCount < 0 || req.SampleCount > maxCompareSamples || req.SampleSize < 0 {
+		o.plugin.metrics.RecordOperation(req.SourceBucket, "compare", "error")
+		o.plugin.metrics.RecordError(req.SourceBucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("sample_count must be between 0 and %d and sample_size >= 0", maxCompareSamples))
+	}
+
+	// Get source bucket
+	sourceBucket, err := o.plugin.buckets.GetBucket(req.SourceBucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.SourceBucket, "compare", "error")
+		o.plugin.metrics.RecordError(req.SourceBucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.SourceBucket)
+	}
+
+	// Get destination bucket
+	destBucket, err := o.plugin.buckets.GetBucket(req.DestBucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "compare", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.DestBucket)
+	}
+
+	// Check access to both objects
+	if err := o.authorize(ctx, req.Caller, "CompareObjects", "compare", sourceBucket.Name, req.SourcePathname); err != nil {
+		return err
+	}
+	if err := o.authorize(ctx, req.Caller, "CompareObjects", "compare", destBucket.Name, req.DestPathname); err != nil {
+		return err
+	}
+
+	// Acquire semaphores
+	release, err := acquireBuckets(ctx, sourceBucket, destBucket)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	sourceKey := sourceBucket.ObjectKey(req.SourcePathname)
+	destKey := destBucket.ObjectKey(req.DestPathname)
+
+	sourceHead, err := o.headForCompare(ctx, sourceBucket, sourceKey)
+	if err != nil {
+		return o.compareError(req.SourceBucket, req.SourcePathname, err)
+	}
+
+	destHead, err := o.headForCompare(ctx, destBucket, destKey)
+	if err != nil {
+		return o.compareError(req.DestBucket, req.DestPathname, err)
+	}
+
+	resp.SourceSize = aws.ToInt64(sourceHead.ContentLength)
+	resp.DestSize = aws.ToInt64(destHead.ContentLength)
+	resp.SourceETag = aws.ToString(sourceHead.ETag)
+	resp.DestETag = aws.ToString(destHead.ETag)
+
+	resp.SizeMatch = resp.SourceSize == resp.DestSize
+	resp.ETagMatch = resp.SourceETag != "" && resp.SourceETag == resp.DestETag
+
+	identical := resp.SizeMatch
+	if !resp.SizeMatch {
+		resp.Differences = append(resp.Differences, "size")
+	}
+
+	// Checksums are only comparable when both objects use the same algorithm and checksum type
+	inconclusive := false
+	algorithm, sourceChecksum, destChecksum := commonChecksum(sourceHead, destHead)
+	if algorithm != "" {
+		resp.ChecksumAlgorithm = algorithm
+		resp.ChecksumCompared = true
+		resp.ChecksumMatch = sourceChecksum == destChecksum
+		identical = identical && resp.ChecksumMatch
+		if !resp.ChecksumMatch {
+			resp.Differences = append(resp.Differences, "checksum")
+		}
+	} else {
+		// Without checksums fall back to ETags. Multipart ETags depend on the part layout, so
+		// differing ones prove nothing either way.
+		if !resp.ETagMatch {
+			if isMultipartETag(resp.SourceETag) || isMultipartETag(resp.DestETag) {
+				inconclusive = true
+				resp.Differences = append(resp.Differences, "etag (multipart, inconclusive)")
+			} else {
+				identical = false
+				resp.Differences = append(resp.Differences, "etag")
+			}
+		}
+	}
+
+	// Byte-range sampling catches differences that metadata alone can't prove
+	if req.SampleCount > 0 && resp.SizeMatch && resp.SourceSize > 0 {
+		sampleSize := req.SampleSize
+		if sampleSize == 0 {
+			sampleSize = defaultCompareSampleSize
+		}
+
+		match, compared, err := o.compareSamples(ctx, sourceBucket, sourceKey, destBucket, destKey, resp.SourceSize, req.SampleCount, sampleSize)
+		if err != nil {
+			o.log.Error("failed to sample objects for comparison",
+				zap.String("source_bucket", req.SourceBucket),
+				zap.String("source_pathname", req.SourcePathname),
+				zap.String("dest_bucket", req.DestBucket),
+				zap.String("dest_pathname", req.DestPathname),
+				zap.Error(err),
+			)
+			o.plugin.metrics.RecordOperation(req.SourceBucket, "compare", "error")
+			o.plugin.metrics.RecordError(req.SourceBucket, ErrS3Operation)
+			return NewS3OperationError("sample object ranges", err)
+		}
+
+		resp.SamplesCompared = compared
+		resp.SamplesMatch = match
+		identical = identical && match
+		if !match {
+			resp.Differences = append(resp.Differences, "content")
+			inconclusive = false
+		} else if int64(compared)*min(sampleSize, resp.SourceSize) >= resp.SourceSize {
+			// The samples covered the whole content
+			inconclusive = false
+		}
+	}
+
+	// A size difference settles the comparison; matching samples of part of the content do not
+	inconclusive = inconclusive && resp.SizeMatch
+	if inconclusive {
+		identical = false
+	}
+
+	resp.Identical = identical
+	resp.Inconclusive = inconclusive
+
+	o.plugin.metrics.RecordOperation(req.SourceBucket, "compare", "success")
+
+	o.log.Debug("objects compared",
+		zap.String("source_bucket", req.SourceBucket),
+		zap.String("source_pathname", req.SourcePathname),
+		zap.String("dest_bucket", req.DestBucket),
+		zap.String("dest_pathname", req.DestPathname),
+		zap.Bool("identical", identical),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	return nil
+}
+
+// headForCompare fetches object metadata including stored checksums
+func (o *Operations) headForCompare(ctx context.Context, bucket *Bucket, key string) (*s3.HeadObjectOutput, error) {
+	return bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucket.Config.Bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+}
+
+// compareError maps a HeadObject failure during comparison to a structured error
+func (o *Operations) compareError(bucketName, pathname string, err error) error {
+	o.plugin.metrics.RecordOperation(bucketName, "compare", "error")
+
+	var nsk *types.NoSuchKey
+	var nf *types.NotFound
+	if errors.As(err, &nsk) || errors.As(err, &nf) {
+		o.plugin.metrics.RecordError(bucketName, ErrFileNotFound)
+		return NewFileNotFoundError(pathname)
+	}
+
+	o.log.Error("failed to get object metadata for comparison",
+		zap.String("bucket", bucketName),
+		zap.String("pathname", pathname),
+		zap.Error(err),
+	)
+	o.plugin.metrics.RecordError(bucketName, ErrS3Operation)
+	return NewS3OperationError("head object", err)
+}
+
+// compareSamples reads evenly spaced byte ranges from both objects and compares their digests
+func (o *Operations) compareSamples(ctx context.Context, sourceBucket *Bucket, sourceKey string, destBucket *Bucket, destKey string, size int64, count int, sampleSize int64) (bool, int, error) {
+	if sampleSize > size {
+		sampleSize = size
+	}
+
+	// Spread samples between the first and the last possible offset
+	span := size - sampleSize
+	compared := 0
+	for i := 0; i < count; i++ {
+		var offset int64
+		if count > 1 {
+			offset = span * int64(i) / int64(count-1)
+		}
+
+		rangeHeader := fmt.Sprintf("bytes=%d-%d", offset, offset+sampleSize-1)
+
+		sourceDigest, err := rangeDigest(ctx, sourceBucket, sourceKey, rangeHeader)
+		if err != nil {
+			return false, compared, err
+		}
+
+		destDigest, err := rangeDigest(ctx, destBucket, destKey, rangeHeader)
+		if err != nil {
+			return false, compared, err
+		}
+
+		compared++
+		if !bytes.Equal(sourceDigest, destDigest) {
+			return false, compared, nil
+		}
+
+		// A single sample covers the whole object
+		if span == 0 {
+			break
+		}
+	}
+
+	return true, compared, nil
+}
+
+// rangeDigest returns the SHA-256 digest of a byte range of an object
+func rangeDigest(ctx context.Context, bucket *Bucket, key, rangeHeader string) ([]byte, error) {
+	result, err := bucket.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(rangeHeader),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, result.Body); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// commonChecksum returns the first checksum algorithm present on both objects with the same checksum type
+func commonChecksum(a, b *s3.HeadObjectOutput) (string, string, string) {
+	if a.ChecksumType != b.ChecksumType {
+		return "", "", ""
+	}
+
+	candidates := []struct {
+		name string
+		a, b *string
+	}{
+		{"SHA256", a.ChecksumSHA256, b.ChecksumSHA256},
+		{"SHA1", a.ChecksumSHA1, b.ChecksumSHA1},
+		{"CRC64NVME", a.ChecksumCRC64NVME, b.ChecksumCRC64NVME},
+		{"CRC32C", a.ChecksumCRC32C, b.ChecksumCRC32C},
+		{"CRC32", a.ChecksumCRC32, b.ChecksumCRC32},
+	}
+
+	for _, c := range candidates {
+		if aws.ToString(c.a) != "" && aws.ToString(c.b) != "" {
+			return c.name, *c.a, *c.b
+		}
+	}
+
+	return "", "", ""
+}
+
+// isMultipartETag reports whether an ETag was produced by a multipart upload
+func isMultipartETag(etag string) bool {
+	return strings.Contains(etag, "-")
+}
@@ -0,0 +1,135 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+)
+
+// EncodingGzip is the only response encoding supported for large payloads
+const EncodingGzip = "gzip"
+
+// Compression is embedded in requests whose responses may carry large payloads.
+// Clients opt in by setting accept_encoding to "gzip"; payloads larger than
+// compression_threshold are then gzip-compressed and the response's content_encoding is set.
+type Compression struct {
+	AcceptEncoding string `json:"accept_encoding,omitempty"`
+}
+
+func (c Compression) acceptsGzip() bool {
+	return c.AcceptEncoding == EncodingGzip
+}
+
+// compressionNegotiator is implemented by requests embedding Compression
+type compressionNegotiator interface {
+	acceptsGzip() bool
+}
+
+// compressibleResponse is implemented by responses able to compress their payload
+type compressibleResponse interface {
+	compress(threshold int64) error
+}
+
+// compressResponse compresses the response payload when the client accepts gzip
+func compressResponse(req, resp any, threshold int64) error {
+	negotiator, ok := req.(compressionNegotiator)
+	if !ok || !negotiator.acceptsGzip() {
+		return nil
+	}
+
+	compressible, ok := resp.(compressibleResponse)
+	if !ok {
+		return nil
+	}
+
+	return compressible.compress(threshold)
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressListing replaces listing entries with a gzip-compressed JSON document
+// {"objects": [...], "common_prefixes": [...]} when it exceeds threshold
+func compressListing(objects *[]ObjectInfo, prefixes *[]CommonPrefix, threshold int64) ([]byte, error) {
+	data, err := json.Marshal(struct {
+		Objects        []ObjectInfo   `json:"objects"`
+		CommonPrefixes []CommonPrefix `json:"common_prefixes,omitempty"`
+	}{*objects, *prefixes})
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) <= threshold {
+		return nil, nil
+	}
+
+	compressed, err := gzipBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	*objects, *prefixes = nil, nil
+	return compressed, nil
+}
+
+func (r *ReadResponse) compress(threshold int64) error {
+	if int64(len(r.Content)) <= threshold {
+		return nil
+	}
+
+	compressed, err := gzipBytes(r.Content)
+	if err != nil {
+		return err
+	}
+
+	r.Content = compressed
+	r.ContentEncoding = EncodingGzip
+	return nil
+}
+
+func (r *FetchDownloadChunkResponse) compress(threshold int64) error {
+	if int64(len(r.Content)) <= threshold {
+		return nil
+	}
+
+	compressed, err := gzipBytes(r.Content)
+	if err != nil {
+		return err
+	}
+
+	r.Content = compressed
+	r.ContentEncoding = EncodingGzip
+	return nil
+}
+
+func (r *ListObjectsResponse) compress(threshold int64) error {
+	compressed, err := compressListing(&r.Objects, &r.CommonPrefixes, threshold)
+	if err != nil || compressed == nil {
+		return err
+	}
+
+	r.Compressed = compressed
+	r.ContentEncoding = EncodingGzip
+	return nil
+}
+
+func (r *FetchNextResponse) compress(threshold int64) error {
+	compressed, err := compressListing(&r.Objects, &r.CommonPrefixes, threshold)
+	if err != nil || compressed == nil {
+		return err
+	}
+
+	r.Compressed = compressed
+	r.ContentEncoding = EncodingGzip
+	return nil
+}
@@ -1,7 +1,26 @@
 package s3
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DirectoryMarkersInclude returns directory marker objects as regular objects
+	DirectoryMarkersInclude = "include"
+
+	// DirectoryMarkersSkip omits directory marker objects from listings
+	DirectoryMarkersSkip = "skip"
+
+	// DirectoryMarkersAsPrefix reports directory marker objects as common prefixes
+	DirectoryMarkersAsPrefix = "as_prefix"
 )
 
 // Config represents the plugin configuration from .rr.yaml
@@ -9,15 +28,122 @@ type Config struct {
 	// Default bucket name to use when none specified
 	Default string `mapstructure:"default"`
 
+	// MaxConcurrentOperations limits concurrent operations across all buckets (default: 0, unlimited)
+	MaxConcurrentOperations int `mapstructure:"max_concurrent_operations"`
+
+	// MaxQueueWait bounds how long an operation waits for its bucket and global slots before it
+	// fails with the retryable QUEUE_TIMEOUT error (default: 0, unlimited)
+	MaxQueueWait time.Duration `mapstructure:"max_queue_wait"`
+
 	// Servers contains S3 server definitions (credentials and endpoints)
 	Servers map[string]*ServerConfig `mapstructure:"servers"`
 
 	// Buckets contains bucket definitions that reference servers
 	Buckets map[string]*BucketConfig `mapstructure:"buckets"`
+
+	// Routes defines virtual buckets routing pathnames to buckets by key prefix (optional)
+	Routes map[string]*RouteConfig `mapstructure:"routes"`
+
+	// SlowOperationThreshold logs a warning and increments rr_s3_slow_operations_total for
+	// operations whose queue wait or total duration reaches it (default: 0, disabled)
+	SlowOperationThreshold time.Duration `mapstructure:"slow_operation_threshold"`
+
+	// DownloadSessionTTL is how long an idle download session is kept (default: 30m)
+	DownloadSessionTTL time.Duration `mapstructure:"download_session_ttl"`
+
+	// ShutdownTimeout is how long in-flight operations may drain on stop before they are cancelled
+	// and their multipart uploads aborted (default: 30s)
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	// ListCursorTTL is how long an idle listing cursor is kept (default: 10m)
+	ListCursorTTL time.Duration `mapstructure:"list_cursor_ttl"`
+
+	// MaxListResponseSize caps the approximate size in bytes of a single listing response (default: 8MB)
+	MaxListResponseSize int64 `mapstructure:"max_list_response_size"`
+
+	// CompressionThreshold is the payload size in bytes above which responses are gzip-compressed
+	// for clients sending accept_encoding "gzip" (default: 64KB)
+	CompressionThreshold int64 `mapstructure:"compression_threshold"`
+
+	// ErrorFormat selects how RPC errors are encoded: "text" (default) or "json" (see ErrorEnvelope)
+	ErrorFormat string `mapstructure:"error_format"`
+
+	// StateDir is a local directory for persistent plugin state such as job checkpoints
+	// Leave empty to disable persistence
+	StateDir string `mapstructure:"state_dir"`
+
+	// JobHistorySize is how many finished jobs GetJobHistory keeps, persisted under state_dir (default: 1000)
+	JobHistorySize int `mapstructure:"job_history_size"`
+
+	// JobCallbacks enables webhooks notified when async jobs finish; omit to disable
+	JobCallbacks *JobCallbackConfig `mapstructure:"job_callbacks"`
+
+	// NormalizePaths strips leading slashes, "./" segments and duplicate slashes from
+	// pathnames instead of rejecting them (Flysystem compatibility)
+	NormalizePaths bool `mapstructure:"normalize_paths"`
+
+	// Interceptors lists built-in interceptors applied to every operation, in order
+	// Supported: "log", "read_only"
+	Interceptors []string `mapstructure:"interceptors"`
+
+	// Access configures optional role-based access control; omit to allow every caller
+	Access *AccessConfig `mapstructure:"access"`
+
+	// Alerts configures optional error threshold alerting; omit to disable
+	Alerts *AlertConfig `mapstructure:"alerts"`
+
+	// SlowLog writes operations over a threshold to a dedicated JSON log file; omit to disable
+	SlowLog *SlowLogConfig `mapstructure:"slow_log"`
+
+	// ShortLinks enables CreateShortLink and the HTTP middleware serving them; omit to disable
+	ShortLinks *ShortLinksConfig `mapstructure:"short_links"`
+
+	// Index keeps object metadata searchable with SearchObjects; omit to disable
+	Index *IndexConfig `mapstructure:"index"`
+
+	// Exports enables StartExport bundles with signed manifests; omit to disable
+	Exports *ExportConfig `mapstructure:"exports"`
+
+	// Coordination runs scheduled jobs on one elected instance of a fleet; omit to run them on every instance
+	Coordination *CoordinationConfig `mapstructure:"coordination"`
+
+	// URLFetch enables WriteFromURL for the allowed hosts; omit to disable
+	URLFetch *URLFetchConfig `mapstructure:"url_fetch"`
+
+	// AllowDynamicServers enables the RegisterServer RPC, letting callers supply
+	// endpoints and credentials at runtime (default: false)
+	AllowDynamicServers bool `mapstructure:"allow_dynamic_servers"`
+
+	// PersistDynamic stores servers and buckets registered via RPC under state_dir
+	// and restores them on start (default: false)
+	PersistDynamic bool `mapstructure:"persist_dynamic"`
+
+	// Publish configures PublishDirectory (local roots, header rules, compression); omit to disable
+	Publish *PublishConfig `mapstructure:"publish"`
+
+	// Archives configures ExtractArchive limits and local roots
+	Archives *ArchiveConfig `mapstructure:"archives"`
+
+	// StateEncryption selects the source of the master key encrypting persisted credentials and
+	// resumable upload sessions (env, file or kms); required when persist_dynamic is enabled
+	StateEncryption *EncryptionKeyConfig `mapstructure:"state_encryption"`
+
+	// Metrics configures the metric name prefix and constant labels
+	Metrics *MetricsConfig `mapstructure:"metrics"`
+
+	// Placeholders maps placeholders in bucket names (e.g., "myapp-{env}") to environment variables;
+	// a placeholder without a mapping reads the upper-cased placeholder name ({env} reads ENV)
+	Placeholders map[string]string `mapstructure:"placeholders"`
 }
 
 // ServerConfig represents S3 server configuration (credentials and endpoint)
 type ServerConfig struct {
+	// Preset fills in the S3 client settings of a service: "s3" (default) or "gcs" for Google Cloud Storage HMAC interoperability
+	Preset string `mapstructure:"preset"`
+
+	// Driver is rejected: every operation uses the S3 API and there are no drivers for other storage services
+	Driver string `mapstructure:"driver"`
+
 	// Region is the AWS region (e.g., "us-east-1", "fra1" for DigitalOcean)
 	Region string `mapstructure:"region"`
 
@@ -28,6 +154,31 @@ type ServerConfig struct {
 
 	// Credentials contains authentication credentials for this server
 	Credentials ServerCredentials `mapstructure:"credentials"`
+
+	// Anonymous mounts public buckets read-only without credentials (e.g., open datasets)
+	Anonymous bool `mapstructure:"anonymous"`
+
+	// Profile loads credentials and region from the shared AWS config and credentials files
+	// (~/.aws/config, ~/.aws/credentials), including SSO and assume-role profiles
+	Profile string `mapstructure:"profile"`
+
+	// SSO obtains temporary credentials through IAM Identity Center (optional)
+	SSO *SSOConfig `mapstructure:"sso"`
+
+	// AllowBucketCreation permits create_if_missing on buckets of this server; local endpoints
+	// (localhost, localstack, minio, private addresses) and MinIO servers allow it without this flag
+	AllowBucketCreation bool `mapstructure:"allow_bucket_creation"`
+
+	// CopySourceEncoding controls how the CopySource header is encoded:
+	// "url" (default) percent-encodes the key, "raw" sends it as-is for non-standard providers
+	CopySourceEncoding string `mapstructure:"copy_source_encoding"`
+
+	// Signing overrides request signing for gateways with non-default expectations (optional)
+	Signing *SigningConfig `mapstructure:"signing"`
+
+	// Compatibility selects the S3 implementation: "aws" (default), "ceph", "storj" or "generic".
+	// Modes other than "aws" probe each bucket for supported features at registration.
+	Compatibility string `mapstructure:"compatibility"`
 }
 
 // ServerCredentials contains S3 authentication credentials
@@ -42,6 +193,31 @@ type ServerCredentials struct {
 	Token string `mapstructure:"token"`
 }
 
+// String implements fmt.Stringer so credentials never reach logs or error messages in plain text
+func (c ServerCredentials) String() string {
+	return "{key: " + redactKey(c.Key) + ", secret: " + redactedValue + "}"
+}
+
+// GoString implements fmt.GoStringer for %#v
+func (c ServerCredentials) GoString() string {
+	return c.String()
+}
+
+// MarshalJSON redacts credentials in any JSON encoding (including JSON log encoders)
+func (c ServerCredentials) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"key":    redactKey(c.Key),
+		"secret": redactedValue,
+	})
+}
+
+// MarshalLogObject redacts credentials when logged with zap.Object/zap.Any
+func (c ServerCredentials) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("key", redactKey(c.Key))
+	enc.AddString("secret", redactedValue)
+	return nil
+}
+
 // BucketConfig represents a single bucket configuration
 type BucketConfig struct {
 	// Server is the reference to a server defined in the servers section
@@ -60,11 +236,128 @@ type BucketConfig struct {
 	// MaxConcurrentOperations limits concurrent operations per bucket (default: 100)
 	MaxConcurrentOperations int `mapstructure:"max_concurrent_operations"`
 
-	// PartSize defines multipart upload part size in bytes (default: 5MB)
+	// PartSize defines multipart upload part size in bytes for uploads of unknown size,
+	// such as streamed archives (default: 5MB)
 	PartSize int64 `mapstructure:"part_size"`
 
+	// MinPartSize is the smallest part size for uploads of known size; larger objects get larger
+	// parts so they fit into MaxParts parts (default: part_size)
+	MinPartSize int64 `mapstructure:"min_part_size"`
+
+	// MaxParts caps the number of parts of an upload of known size (default and max: 10000)
+	MaxParts int32 `mapstructure:"max_parts"`
+
+	// MultipartThreshold is the size from which uploads of known size are split into parts;
+	// smaller writes are sent as a single PutObject (default: min_part_size)
+	MultipartThreshold int64 `mapstructure:"multipart_threshold"`
+
 	// Concurrency defines number of goroutines for multipart uploads (default: 5)
 	Concurrency int `mapstructure:"concurrency"`
+
+	// DirectoryMarkers controls zero-byte "dir/" marker objects in listings:
+	// "include" (default) returns them as objects, "skip" hides them,
+	// "as_prefix" reports them as common prefixes (directories)
+	DirectoryMarkers string `mapstructure:"directory_markers"`
+
+	// SkipPostWriteHead fills Write responses from the upload instead of a HeadObject request:
+	// size is the uploaded size and last_modified the local upload time (default: false)
+	SkipPostWriteHead bool `mapstructure:"skip_post_write_head"`
+
+	// MetadataEncoding stores user metadata values "none" (default, values must be ASCII),
+	// "rfc2047" (non-ASCII values as encoded-words) or "percent" (percent-encoded); values are
+	// decoded again by GetMetadata
+	MetadataEncoding string `mapstructure:"metadata_encoding"`
+
+	// TreatForbiddenAsMissing reports objects as missing when HeadObject is denied: without
+	// s3:ListBucket, S3 answers 403 instead of 404 for missing keys (default: false)
+	TreatForbiddenAsMissing bool `mapstructure:"treat_forbidden_as_missing"`
+
+	// Dedup stores written content once per sha256 under DedupPrefix and writes
+	// zero-byte pointer objects at the requested pathnames (default: false)
+	Dedup bool `mapstructure:"dedup"`
+
+	// DedupPrefix is the bucket-relative prefix holding content-addressed blobs (default: ".blobs/")
+	DedupPrefix string `mapstructure:"dedup_prefix"`
+
+	// DerivedPrefix enables derived objects (thumbnails, variants) stored at
+	// <derived_prefix><source>/<variant>; they are deleted when the source is overwritten or deleted
+	DerivedPrefix string `mapstructure:"derived_prefix"`
+
+	// DisableACL omits ACLs from all requests, for providers and buckets without ACL support
+	// (Cloudflare R2, MinIO, buckets with ObjectOwnership=BucketOwnerEnforced); visibility is emulated
+	DisableACL bool `mapstructure:"disable_acl"`
+
+	// PublicMode selects the visibility emulation when ACLs are disabled: "prefix" (default)
+	// treats objects under PublicPrefix as public, "presign" serves public URLs as presigned URLs
+	PublicMode string `mapstructure:"public_mode"`
+
+	// PublicPrefix is the bucket-relative prefix readable by everyone in "prefix" mode (default: "public/")
+	PublicPrefix string `mapstructure:"public_prefix"`
+
+	// PublicURLTTL is the validity of URLs returned as public URLs in "presign" mode (default and max: 168h)
+	PublicURLTTL time.Duration `mapstructure:"public_url_ttl"`
+
+	// CDN describes the CDN in front of the bucket, used by InvalidateCDN and GetPublicURL (optional)
+	CDN *CDNConfig `mapstructure:"cdn"`
+
+	// AssumeRole accesses the bucket through a role assumed with the server credentials (optional)
+	AssumeRole *AssumeRoleConfig `mapstructure:"assume_role"`
+
+	// ReadCredentials signs reads or presigned URLs with a second, narrower key pair (optional)
+	ReadCredentials *ReadCredentialsConfig `mapstructure:"read_credentials"`
+
+	// MinIO provisions the bucket at registration on servers with compatibility "minio" (optional)
+	MinIO *MinIOSetupConfig `mapstructure:"minio"`
+
+	// CreateIfMissing creates the S3 bucket at registration when it does not exist (dev/test servers only)
+	CreateIfMissing bool `mapstructure:"create_if_missing"`
+
+	// LockSuffix names lock sidecar objects used by AcquireFileLock (default: ".lock")
+	LockSuffix string `mapstructure:"lock_suffix"`
+
+	// Expiration enables expires_in/expires_at on Write through plugin-managed lifecycle rules (optional)
+	Expiration *ExpirationConfig `mapstructure:"expiration"`
+
+	// Temporary enables WriteTemporary/Promote and staged writes, with automatic cleanup of unpromoted uploads (optional)
+	Temporary *TemporaryConfig `mapstructure:"temporary"`
+
+	// WritePolicy restricts extensions, object size and total usage of writes (optional)
+	WritePolicy *WritePolicyConfig `mapstructure:"write_policy"`
+
+	// Sharding spreads objects over hash-derived key prefixes for request rates above the
+	// per-prefix S3 limits (optional)
+	Sharding *ShardingConfig `mapstructure:"sharding"`
+
+	// ExistsFilter answers Exists for missing objects from a periodically rebuilt bloom filter
+	// of all keys instead of HeadObject (optional)
+	ExistsFilter *ExistsFilterConfig `mapstructure:"exists_filter"`
+
+	// Batch enables S3 Batch Operations jobs through SubmitBatchJob, AWS only (optional)
+	Batch *BatchConfig `mapstructure:"batch"`
+
+	// RetryBudget limits SDK retries with a token bucket shared by the bucket's clients
+	// (default: 500 tokens, 5 per retry, 1 back per success, 3 attempts)
+	RetryBudget *RetryBudgetConfig `mapstructure:"retry_budget"`
+
+	// Hedging sends a second GetObject/HeadObject when the first is slower than recent reads (optional)
+	Hedging *HedgingConfig `mapstructure:"hedging"`
+
+	// PresignCache reuses presigned GetPublicURL URLs until near expiry (optional)
+	PresignCache *PresignCacheConfig `mapstructure:"presign_cache"`
+
+	// Retention makes Delete and Move refuse objects with protecting tags or Object Lock retention (optional)
+	Retention *RetentionConfig `mapstructure:"retention"`
+
+	// Audit makes the bucket an append-only, hash-chained audit log checked by VerifyChain (optional)
+	Audit *AuditConfig `mapstructure:"audit"`
+
+	// DirectoryBucket marks an S3 Express One Zone directory bucket (name ending in "--x-s3"):
+	// requests use session auth and the zonal endpoint, ACLs are off and listings are unordered
+	DirectoryBucket bool `mapstructure:"directory_bucket"`
+
+	// Aliases are additional names resolving to this bucket, e.g. legacy names kept after
+	// consolidating configurations (optional)
+	Aliases []string `mapstructure:"aliases"`
 }
 
 // Validate validates the configuration
@@ -85,34 +378,244 @@ func (c *Config) Validate() error {
 	}
 
 	// Validate each bucket configuration
+	aliases := make(map[string]string)
 	for name, bucket := range c.Buckets {
+		for _, alias := range bucket.Aliases {
+			if _, exists := c.Buckets[alias]; exists {
+				return fmt.Errorf("invalid configuration for bucket '%s': alias '%s' is already a bucket name", name, alias)
+			}
+			if other, exists := aliases[alias]; exists {
+				return fmt.Errorf("invalid configuration for bucket '%s': alias '%s' is already used by bucket '%s'", name, alias, other)
+			}
+			aliases[alias] = name
+		}
+
+		resolved, err := resolvePlaceholders(bucket.Bucket, c.Placeholders)
+		if err != nil {
+			return fmt.Errorf("invalid configuration for bucket '%s': %w", name, err)
+		}
+		bucket.Bucket = resolved
+
 		if err := bucket.Validate(c.Servers); err != nil {
 			return fmt.Errorf("invalid configuration for bucket '%s': %w", name, err)
 		}
 	}
 
+	// Validate virtual buckets; they resolve per pathname, so they cannot be aliased or made default
+	for name, route := range c.Routes {
+		if _, exists := c.Buckets[name]; exists || aliases[name] != "" {
+			return fmt.Errorf("invalid configuration for route '%s': name is already a bucket name or alias", name)
+		}
+		if route == nil {
+			return fmt.Errorf("invalid configuration for route '%s': at least one rule is required", name)
+		}
+		if err := route.Validate(func(b string) bool {
+			_, exists := c.Buckets[b]
+			return exists || aliases[b] != ""
+		}); err != nil {
+			return fmt.Errorf("invalid configuration for route '%s': %w", name, err)
+		}
+	}
+
 	// Validate default bucket exists if specified
 	if c.Default != "" {
-		if _, exists := c.Buckets[c.Default]; !exists {
+		if _, exists := c.Buckets[c.Default]; !exists && aliases[c.Default] == "" {
 			return fmt.Errorf("default bucket '%s' not found in configuration", c.Default)
 		}
 	}
 
+	// Validate access control
+	if c.Access != nil {
+		if err := c.Access.Validate(); err != nil {
+			return fmt.Errorf("invalid access configuration: %w", err)
+		}
+	}
+
+	// Validate directory publishing
+	if c.Publish != nil {
+		if err := c.Publish.Validate(); err != nil {
+			return fmt.Errorf("invalid publish configuration: %w", err)
+		}
+	}
+
+	// Validate archive handling; extraction from S3 works without configuration
+	if c.Archives == nil {
+		c.Archives = &ArchiveConfig{}
+	}
+	if err := c.Archives.Validate(); err != nil {
+		return fmt.Errorf("invalid archives configuration: %w", err)
+	}
+
+	// Validate metrics naming
+	if c.Metrics == nil {
+		c.Metrics = &MetricsConfig{}
+	}
+	if err := c.Metrics.Validate(); err != nil {
+		return fmt.Errorf("invalid metrics configuration: %w", err)
+	}
+
+	// Validate alerting
+	if c.Alerts != nil {
+		if err := c.Alerts.Validate(); err != nil {
+			return fmt.Errorf("invalid alerts configuration: %w", err)
+		}
+	}
+
+	// Validate the slow operation log
+	if c.SlowLog != nil {
+		if err := c.SlowLog.Validate(c.SlowOperationThreshold); err != nil {
+			return fmt.Errorf("invalid slow_log configuration: %w", err)
+		}
+	}
+
+	// Validate short links
+	if c.ShortLinks != nil {
+		if err := c.ShortLinks.Validate(); err != nil {
+			return fmt.Errorf("invalid short_links configuration: %w", err)
+		}
+	}
+
+	// Validate object index
+	if c.Index != nil {
+		if err := c.Index.Validate(); err != nil {
+			return fmt.Errorf("invalid index configuration: %w", err)
+		}
+	}
+
+	// Validate export bundles
+	if c.Exports != nil {
+		if err := c.Exports.Validate(c.Servers); err != nil {
+			return fmt.Errorf("invalid exports configuration: %w", err)
+		}
+	}
+
+	// Validate job completion webhooks
+	if c.JobCallbacks != nil {
+		if err := c.JobCallbacks.Validate(); err != nil {
+			return fmt.Errorf("invalid job_callbacks configuration: %w", err)
+		}
+	}
+
+	// Validate server-side URL fetching
+	if c.URLFetch != nil {
+		if err := c.URLFetch.Validate(); err != nil {
+			return fmt.Errorf("invalid url_fetch configuration: %w", err)
+		}
+	}
+
+	// Validate leader election
+	if c.Coordination != nil {
+		if err := c.Coordination.Validate(c.Buckets); err != nil {
+			return fmt.Errorf("invalid coordination configuration: %w", err)
+		}
+	}
+
+	// Validate persistence of dynamic registrations
+	if c.PersistDynamic {
+		if c.StateDir == "" {
+			return fmt.Errorf("persist_dynamic requires state_dir")
+		}
+		if c.StateEncryption == nil {
+			return fmt.Errorf("persist_dynamic requires state_encryption")
+		}
+	}
+	if c.StateEncryption != nil {
+		if err := c.StateEncryption.Validate(c.Servers); err != nil {
+			return fmt.Errorf("invalid state_encryption: %w", err)
+		}
+	}
+
+	// Set defaults
+	if c.DownloadSessionTTL <= 0 {
+		c.DownloadSessionTTL = 30 * time.Minute
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	if c.ListCursorTTL <= 0 {
+		c.ListCursorTTL = 10 * time.Minute
+	}
+
+	if c.JobHistorySize <= 0 {
+		c.JobHistorySize = defaultJobHistorySize
+	}
+
+	if c.MaxListResponseSize <= 0 {
+		c.MaxListResponseSize = 8 * 1024 * 1024
+	}
+
+	if c.CompressionThreshold <= 0 {
+		c.CompressionThreshold = 64 * 1024
+	}
+
+	switch c.ErrorFormat {
+	case "":
+		c.ErrorFormat = ErrorFormatText
+	case ErrorFormatText, ErrorFormatJSON:
+	default:
+		return fmt.Errorf("error_format must be '%s' or '%s', got '%s'", ErrorFormatText, ErrorFormatJSON, c.ErrorFormat)
+	}
+
 	return nil
 }
 
 // Validate validates a server configuration
 func (sc *ServerConfig) Validate() error {
-	if sc.Region == "" {
+	if err := sc.applyPreset(); err != nil {
+		return err
+	}
+
+	// A profile may provide the region
+	if sc.Region == "" && sc.Profile == "" {
 		return fmt.Errorf("region is required")
 	}
 
-	if sc.Credentials.Key == "" {
-		return fmt.Errorf("credentials.key is required")
+	if sc.Signing != nil {
+		if err := sc.Signing.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if sc.Anonymous && sc.Signing != nil {
+		return fmt.Errorf("signing cannot be combined with anonymous")
+	}
+
+	if sc.Profile != "" && (sc.Anonymous || sc.Credentials.Key != "" || sc.Credentials.Secret != "") {
+		return fmt.Errorf("profile cannot be combined with credentials or anonymous")
+	}
+
+	if sc.SSO != nil {
+		if sc.Profile != "" || sc.Anonymous || sc.Credentials.Key != "" || sc.Credentials.Secret != "" {
+			return fmt.Errorf("sso cannot be combined with profile, credentials or anonymous")
+		}
+		if err := sc.SSO.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Anonymous requests carry no credentials; profiles and SSO bring their own
+	if !sc.Anonymous && !sc.Signing.anonymous() && sc.Profile == "" && sc.SSO == nil {
+		if sc.Credentials.Key == "" {
+			return fmt.Errorf("credentials.key is required")
+		}
+
+		if sc.Credentials.Secret == "" {
+			return fmt.Errorf("credentials.secret is required")
+		}
+	}
+
+	switch sc.CopySourceEncoding {
+	case "":
+		sc.CopySourceEncoding = CopySourceEncodingURL
+	case CopySourceEncodingURL, CopySourceEncodingRaw:
+	default:
+		return fmt.Errorf("copy_source_encoding must be 'url' or 'raw', got '%s'", sc.CopySourceEncoding)
 	}
 
-	if sc.Credentials.Secret == "" {
-		return fmt.Errorf("credentials.secret is required")
+	if err := validateCompatibility(&sc.Compatibility); err != nil {
+		return err
 	}
 
 	return nil
@@ -137,6 +640,10 @@ func (bc *BucketConfig) Validate(servers map[string]*ServerConfig) error {
 		return fmt.Errorf("visibility must be 'public' or 'private', got '%s'", bc.Visibility)
 	}
 
+	if !isValidDirectoryMarkersMode(bc.DirectoryMarkers) {
+		return fmt.Errorf("directory_markers must be 'include', 'skip' or 'as_prefix', got '%s'", bc.DirectoryMarkers)
+	}
+
 	// Set defaults
 	if bc.Visibility == "" {
 		bc.Visibility = "private"
@@ -150,11 +657,272 @@ func (bc *BucketConfig) Validate(servers map[string]*ServerConfig) error {
 		bc.PartSize = 5 * 1024 * 1024 // 5MB default
 	}
 
+	if bc.MinPartSize <= 0 {
+		bc.MinPartSize = bc.PartSize
+	}
+
+	if bc.MaxParts <= 0 {
+		bc.MaxParts = manager.MaxUploadParts
+	}
+	if bc.MaxParts > manager.MaxUploadParts {
+		return fmt.Errorf("max_parts must not exceed %d, got %d", manager.MaxUploadParts, bc.MaxParts)
+	}
+
+	if bc.MultipartThreshold <= 0 {
+		bc.MultipartThreshold = bc.MinPartSize
+	}
+
 	if bc.Concurrency <= 0 {
 		bc.Concurrency = 5
 	}
 
-	return nil
+	if bc.DirectoryMarkers == "" {
+		bc.DirectoryMarkers = DirectoryMarkersInclude
+	}
+
+	if bc.LockSuffix == "" {
+		bc.LockSuffix = defaultLockSuffix
+	}
+	if strings.Contains(bc.LockSuffix, "/") {
+		return fmt.Errorf("lock_suffix must not contain '/'")
+	}
+
+	if bc.DerivedPrefix != "" && !strings.HasSuffix(bc.DerivedPrefix, "/") {
+		return fmt.Errorf("derived_prefix must end with '/', got '%s'", bc.DerivedPrefix)
+	}
+
+	if err := bc.validateMetadataEncoding(); err != nil {
+		return err
+	}
+
+	if bc.DirectoryBucket {
+		if err := bc.validateDirectoryBucket(servers[bc.Server]); err != nil {
+			return err
+		}
+	}
+
+	if err := bc.validateAccessPoint(servers[bc.Server]); err != nil {
+		return err
+	}
+
+	if bc.DisableACL {
+		switch bc.PublicMode {
+		case "":
+			bc.PublicMode = PublicModePrefix
+		case PublicModePrefix, PublicModePresign:
+		default:
+			return fmt.Errorf("invalid public_mode '%s', must be '%s' or '%s'", bc.PublicMode, PublicModePrefix, PublicModePresign)
+		}
+
+		if bc.PublicPrefix == "" {
+			bc.PublicPrefix = "public/"
+		}
+		if !strings.HasSuffix(bc.PublicPrefix, "/") {
+			return fmt.Errorf("public_prefix must end with '/', got '%s'", bc.PublicPrefix)
+		}
+
+		if bc.PublicURLTTL <= 0 {
+			bc.PublicURLTTL = maxPresignTTL
+		}
+		if bc.PublicURLTTL > maxPresignTTL {
+			return fmt.Errorf("public_url_ttl must not exceed %s", maxPresignTTL)
+		}
+	}
+
+	if bc.CDN != nil {
+		if err := bc.CDN.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.AssumeRole != nil {
+		if servers[bc.Server].Anonymous {
+			return fmt.Errorf("assume_role requires a server with credentials")
+		}
+		if err := bc.AssumeRole.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.ReadCredentials != nil {
+		if servers[bc.Server].Anonymous {
+			return fmt.Errorf("read_credentials requires a server with credentials")
+		}
+		if err := bc.ReadCredentials.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.CreateIfMissing && !servers[bc.Server].allowsBucketCreation() {
+		return fmt.Errorf("create_if_missing is limited to local dev/test endpoints, set allow_bucket_creation on server '%s' to use it", bc.Server)
+	}
+
+	if bc.Expiration != nil {
+		if servers[bc.Server].Anonymous {
+			return fmt.Errorf("expiration requires a server with credentials")
+		}
+		if err := bc.Expiration.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.Temporary != nil {
+		if servers[bc.Server].Anonymous {
+			return fmt.Errorf("temporary uploads require a server with credentials")
+		}
+		if err := bc.Temporary.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.WritePolicy != nil {
+		if err := bc.WritePolicy.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.Sharding != nil {
+		if err := bc.Sharding.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.ExistsFilter != nil {
+		if err := bc.ExistsFilter.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.RetryBudget == nil {
+		bc.RetryBudget = &RetryBudgetConfig{}
+	}
+	if err := bc.RetryBudget.Validate(); err != nil {
+		return err
+	}
+
+	if bc.Hedging != nil {
+		if err := bc.Hedging.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.PresignCache != nil {
+		if err := bc.PresignCache.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.Retention != nil {
+		if err := bc.Retention.Validate(); err != nil {
+			return fmt.Errorf("invalid retention: %w", err)
+		}
+	}
+
+	if bc.Audit != nil {
+		if servers[bc.Server].Anonymous {
+			return fmt.Errorf("audit requires a server with credentials")
+		}
+		// Each of these writes or removes objects outside the chain
+		if bc.Dedup {
+			return fmt.Errorf("audit cannot be combined with dedup")
+		}
+		if bc.Temporary != nil {
+			return fmt.Errorf("audit cannot be combined with temporary uploads")
+		}
+		if bc.Expiration != nil {
+			return fmt.Errorf("audit cannot be combined with expiration")
+		}
+		if err := bc.Audit.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.Batch != nil {
+		if servers[bc.Server].Compatibility != CompatibilityAWS {
+			return fmt.Errorf("batch requires a server with compatibility '%s'", CompatibilityAWS)
+		}
+		if err := bc.Batch.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.MinIO != nil {
+		if servers[bc.Server].Compatibility != CompatibilityMinIO {
+			return fmt.Errorf("minio setup requires a server with compatibility '%s'", CompatibilityMinIO)
+		}
+		if err := bc.MinIO.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if bc.Dedup {
+		if bc.DedupPrefix == "" {
+			bc.DedupPrefix = ".blobs/"
+		}
+		if !strings.HasSuffix(bc.DedupPrefix, "/") {
+			return fmt.Errorf("dedup_prefix must end with '/', got '%s'", bc.DedupPrefix)
+		}
+	}
+
+	return bc.checkCombinations()
+}
+
+// resolvePlaceholders replaces {name} placeholders in a bucket name with environment variable values
+func resolvePlaceholders(template string, vars map[string]string) (string, error) {
+	var sb strings.Builder
+	rest := template
+
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			if strings.IndexByte(rest, '}') >= 0 {
+				return "", fmt.Errorf("unmatched '}' in bucket name '%s'", template)
+			}
+			sb.WriteString(rest)
+			return sb.String(), nil
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unmatched '{' in bucket name '%s'", template)
+		}
+		end += start
+
+		name := rest[start+1 : end]
+		if name == "" {
+			return "", fmt.Errorf("empty placeholder in bucket name '%s'", template)
+		}
+
+		env, ok := vars[name]
+		if !ok {
+			env = strings.ToUpper(name)
+		}
+
+		value := os.Getenv(env)
+		if value == "" {
+			return "", fmt.Errorf("unresolved placeholder '{%s}' in bucket name '%s': environment variable '%s' is not set", name, template, env)
+		}
+
+		sb.WriteString(rest[:start])
+		sb.WriteString(value)
+		rest = rest[end+1:]
+	}
+}
+
+// PartSizeFor returns the multipart part size for an object of size bytes (negative when unknown):
+// at least min_part_size, rounded up to whole MiB, and large enough to stay within max_parts parts
+func (bc *BucketConfig) PartSizeFor(size int64) int64 {
+	if size < 0 {
+		return bc.PartSize
+	}
+	return max(bc.requiredPartSize(size), bc.MinPartSize)
+}
+
+// requiredPartSize returns the smallest whole-MiB part size fitting size bytes into max_parts parts
+func (bc *BucketConfig) requiredPartSize(size int64) int64 {
+	const mib = 1024 * 1024
+	partSize := (size + int64(bc.MaxParts) - 1) / int64(bc.MaxParts)
+	return (partSize + mib - 1) / mib * mib
 }
 
 // GetVisibility returns the ACL string for S3 operations
@@ -181,3 +949,141 @@ func (bc *BucketConfig) GetServerConfig(servers map[string]*ServerConfig) (*Serv
 	}
 	return server, nil
 }
+
+// isValidDirectoryMarkersMode reports whether mode is a known directory marker mode (empty means default)
+func isValidDirectoryMarkersMode(mode string) bool {
+	switch mode {
+	case "", DirectoryMarkersInclude, DirectoryMarkersSkip, DirectoryMarkersAsPrefix:
+		return true
+	default:
+		return false
+	}
+}
+
+// redactedValue replaces secrets in configuration dumps
+const redactedValue = "[REDACTED]"
+
+// Info returns the server configuration with credentials redacted;
+// the access key keeps its first four characters so operators can tell keys apart
+func (sc *ServerConfig) Info() ServerConfigInfo {
+	info := ServerConfigInfo{
+		Preset:             sc.Preset,
+		Region:             sc.Region,
+		Endpoint:           sc.Endpoint,
+		CopySourceEncoding: sc.CopySourceEncoding,
+		Signing:            sc.Signing,
+		Compatibility:      sc.Compatibility,
+		Anonymous:          sc.Anonymous,
+		Profile:            sc.Profile,
+		BucketCreation:     sc.allowsBucketCreation(),
+	}
+
+	if sc.SSO != nil {
+		info.SSOAccountID = sc.SSO.AccountID
+		info.SSORoleName = sc.SSO.RoleName
+	}
+
+	if sc.Anonymous || sc.Profile != "" || sc.SSO != nil {
+		return info
+	}
+
+	info.CredentialsKey = redactKey(sc.Credentials.Key)
+	info.CredentialsSecret = redactedValue
+
+	if sc.Credentials.Token != "" {
+		info.CredentialsToken = redactedValue
+	}
+
+	return info
+}
+
+// redactKey keeps the first four characters of long access keys so operators can tell them apart
+func redactKey(key string) string {
+	if len(key) > 8 {
+		return key[:4] + redactedValue
+	}
+	return redactedValue
+}
+
+// Info returns the effective bucket configuration
+func (bc *BucketConfig) Info() BucketConfigInfo {
+	return BucketConfigInfo{
+		Server:                  bc.Server,
+		Bucket:                  bc.Bucket,
+		Prefix:                  bc.Prefix,
+		Visibility:              bc.Visibility,
+		MaxConcurrentOperations: bc.MaxConcurrentOperations,
+		PartSize:                bc.PartSize,
+		MinPartSize:             bc.MinPartSize,
+		MaxParts:                bc.MaxParts,
+		MultipartThreshold:      bc.MultipartThreshold,
+		Concurrency:             bc.Concurrency,
+		DirectoryMarkers:        bc.DirectoryMarkers,
+		Dedup:                   bc.Dedup,
+		DedupPrefix:             bc.DedupPrefix,
+		DerivedPrefix:           bc.DerivedPrefix,
+		DisableACL:              bc.DisableACL,
+		PublicMode:              bc.PublicMode,
+		PublicPrefix:            bc.PublicPrefix,
+		CDN:                     bc.CDN,
+		AssumeRole:              bc.AssumeRole,
+		ReadCredentials:         bc.ReadCredentials,
+		MinIO:                   bc.MinIO,
+		CreateIfMissing:         bc.CreateIfMissing,
+		LockSuffix:              bc.LockSuffix,
+		Expiration:              bc.Expiration,
+		Temporary:               bc.Temporary,
+		WritePolicy:             bc.WritePolicy,
+		TreatForbiddenAsMissing: bc.TreatForbiddenAsMissing,
+		SkipPostWriteHead:       bc.SkipPostWriteHead,
+		MetadataEncoding:        bc.MetadataEncoding,
+		Sharding:                bc.Sharding,
+		ExistsFilter:            bc.ExistsFilter,
+		Batch:                   bc.Batch,
+		RetryBudget:             bc.RetryBudget,
+		Hedging:                 bc.Hedging,
+		PresignCache:            bc.PresignCache,
+		Retention:               bc.Retention,
+		Audit:                   bc.Audit,
+		DirectoryBucket:         bc.DirectoryBucket,
+		Aliases:                 bc.Aliases,
+	}
+}
+
+// effectiveConfig fills resp with the loaded configuration, including dynamically registered buckets
+func (p *Plugin) effectiveConfig(resp *GetConfigResponse) {
+	resp.Default = p.buckets.GetDefaultBucketName()
+	resp.MaxConcurrent = p.config.MaxConcurrentOperations
+	resp.DownloadSessionTTL = p.config.DownloadSessionTTL.String()
+	resp.ListCursorTTL = p.config.ListCursorTTL.String()
+	resp.ShutdownTimeout = p.config.ShutdownTimeout.String()
+	resp.MaxListResponse = p.config.MaxListResponseSize
+	resp.CompressionThreshold = p.config.CompressionThreshold
+	resp.ErrorFormat = p.config.ErrorFormat
+	resp.StateDir = p.config.StateDir
+	resp.NormalizePaths = p.config.NormalizePaths
+	resp.Interceptors = p.interceptors.Names()
+	resp.AlertsEnabled = p.config.Alerts != nil
+	resp.DynamicServers = p.config.AllowDynamicServers
+	resp.Routes = p.config.Routes
+
+	if p.config.Access != nil {
+		for name := range p.config.Access.Roles {
+			resp.AccessRoles = append(resp.AccessRoles, name)
+		}
+		sort.Strings(resp.AccessRoles)
+	}
+
+	p.buckets.mu.RLock()
+	defer p.buckets.mu.RUnlock()
+
+	resp.Servers = make(map[string]ServerConfigInfo, len(p.buckets.servers))
+	for name, server := range p.buckets.servers {
+		resp.Servers[name] = server.Info()
+	}
+
+	resp.Buckets = make(map[string]BucketConfigInfo, len(p.buckets.buckets))
+	for name, bucket := range p.buckets.buckets {
+		resp.Buckets[name] = bucket.Config.Info()
+	}
+}
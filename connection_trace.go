@@ -0,0 +1,100 @@
+package s3
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// PhaseDNS is the DNS lookup of a new connection
+	PhaseDNS = "dns"
+
+	// PhaseConnect is the TCP connect of a new connection
+	PhaseConnect = "connect"
+
+	// PhaseTLS is the TLS handshake of a new connection
+	PhaseTLS = "tls"
+
+	// PhaseTTFB is the time from the request being written to the first response byte
+	PhaseTTFB = "ttfb"
+)
+
+// phaseObserver receives the duration of a phase of an HTTP request to S3
+type phaseObserver func(bucket, phase string, d time.Duration)
+
+// tracingClient reports connection phases of the requests of a bucket, and adds them and the
+// transferred sizes to the stats of the operation. DNS, connect and TLS are only observed for new
+// connections; requests on reused connections report TTFB alone.
+type tracingClient struct {
+	next    aws.HTTPClient
+	bucket  string
+	observe phaseObserver
+}
+
+// phase records a phase duration in the operation stats and reports it to the observer, if any
+func (c *tracingClient) phase(stats *operationStats, phase string, d time.Duration) {
+	if stats != nil {
+		stats.addPhase(phase, d)
+	}
+	if c.observe != nil {
+		c.observe(c.bucket, phase, d)
+	}
+}
+
+func (c *tracingClient) Do(req *http.Request) (*http.Response, error) {
+	stats := operationStatsFrom(req.Context())
+
+	// Dual-stack dialing connects to several addresses in parallel, and the request is written
+	// and read by different goroutines
+	var (
+		dnsStart, tlsStart time.Time
+		mu                 sync.Mutex
+		connectStart       = make(map[string]time.Time)
+		wrote              atomic.Int64
+	)
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			c.phase(stats, PhaseDNS, time.Since(dnsStart))
+		},
+		ConnectStart: func(_, addr string) {
+			mu.Lock()
+			connectStart[addr] = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(_, addr string, err error) {
+			mu.Lock()
+			start := connectStart[addr]
+			mu.Unlock()
+			// Only the successful attempt counts
+			if err == nil {
+				c.phase(stats, PhaseConnect, time.Since(start))
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				c.phase(stats, PhaseTLS, time.Since(tlsStart))
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { wrote.Store(time.Now().UnixNano()) },
+		GotFirstResponseByte: func() {
+			if at := wrote.Load(); at != 0 {
+				c.phase(stats, PhaseTTFB, time.Since(time.Unix(0, at)))
+			}
+		},
+	}
+
+	resp, err := c.next.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if stats != nil && err == nil {
+		stats.addTransfer(req.ContentLength, resp.ContentLength)
+	}
+	return resp, err
+}
@@ -0,0 +1,100 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxVisibilityWait bounds how long a single request waits for the provider to catch up
+	maxVisibilityWait = time.Minute
+
+	// firstVisibilityPoll and maxVisibilityPoll bound the backoff between visibility checks
+	firstVisibilityPoll = 100 * time.Millisecond
+	maxVisibilityPoll   = 2 * time.Second
+)
+
+// VisibilityWait is embedded in Write and Delete requests for providers with eventually consistent
+// reads or listings: the response is delayed until the change is observable or the wait expires.
+type VisibilityWait struct {
+	// WaitForVisibility is the longest time to wait, e.g. "5s" (empty: do not wait)
+	WaitForVisibility string `json:"wait_for_visibility,omitempty"`
+}
+
+// timeout parses the wait duration
+func (w VisibilityWait) timeout() (time.Duration, error) {
+	if w.WaitForVisibility == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(w.WaitForVisibility)
+	if err != nil || d <= 0 || d > maxVisibilityWait {
+		return 0, NewInvalidRequestError(fmt.Sprintf("wait_for_visibility must be a duration between 0 and %s", maxVisibilityWait))
+	}
+	return d, nil
+}
+
+// awaitVisibility polls HeadObject and a listing until the object at key is visible to both, or gone
+// from both when present is false. It reports whether that happened within timeout; failed checks
+// count as not yet visible.
+func (o *Operations) awaitVisibility(ctx context.Context, bucket *Bucket, key string, present bool, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	delay := firstVisibilityPoll
+	for attempt := 1; ; attempt++ {
+		inHead, inListing, err := objectVisibility(ctx, bucket, key)
+		if err == nil && inHead == present && inListing == present {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			o.log.Warn("change not visible before wait_for_visibility expired",
+				zap.String("bucket", bucket.Name),
+				zap.String("key", key),
+				zap.Bool("present", present),
+				zap.Int("attempts", attempt),
+				zap.Duration("waited", time.Since(start)),
+			)
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxVisibilityPoll)
+	}
+}
+
+// objectVisibility reports whether the object at key is returned by HeadObject and by a listing
+func objectVisibility(ctx context.Context, bucket *Bucket, key string) (bool, bool, error) {
+	inHead, err := objectExists(ctx, bucket, key)
+	if err != nil {
+		return false, false, err
+	}
+
+	// Directory buckets list consistently with reads, and not from an arbitrary key prefix
+	if bucket.Config.DirectoryBucket {
+		return inHead, inHead, nil
+	}
+
+	page, err := bucket.Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket.Config.Bucket),
+		Prefix:  aws.String(key),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return false, false, err
+	}
+	inListing := len(page.Contents) > 0 && aws.ToString(page.Contents[0].Key) == key
+
+	return inHead, inListing, nil
+}
@@ -0,0 +1,256 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultCoordinationPathname is the bucket-relative pathname of the leader lease
+	defaultCoordinationPathname = ".rr-s3/leader.lock"
+
+	// defaultCoordinationTTL is how long a lease is valid without being renewed
+	defaultCoordinationTTL = 30 * time.Second
+
+	// minCoordinationTTL keeps renewals (every third of the TTL) from flooding the bucket
+	minCoordinationTTL = 3 * time.Second
+)
+
+// CoordinationConfig elects one leader among the RoadRunner instances sharing a configuration, so
+// scheduled work (reindexes, quota alerts) runs once across the fleet instead of once per instance.
+// The leader holds a lease object in an S3 bucket, renewed with conditional writes.
+type CoordinationConfig struct {
+	// Bucket is the configured bucket holding the lease object (required)
+	Bucket string `mapstructure:"bucket"`
+
+	// Pathname of the lease object in the bucket (default: ".rr-s3/leader.lock")
+	Pathname string `mapstructure:"pathname"`
+
+	// TTL is how long a lease is valid; the leader renews it every third of it (default: 30s)
+	TTL time.Duration `mapstructure:"ttl"`
+
+	// Instance names this instance in the lease and logs (default: "<hostname>-<pid>")
+	Instance string `mapstructure:"instance"`
+}
+
+// Validate validates the coordination configuration and applies defaults
+func (cc *CoordinationConfig) Validate(buckets map[string]*BucketConfig) error {
+	bc, ok := buckets[cc.Bucket]
+	if !ok {
+		return fmt.Errorf("bucket '%s' is not a configured bucket", cc.Bucket)
+	}
+	if bc.Audit != nil {
+		return fmt.Errorf("bucket '%s' is an audit bucket, the lease is overwritten", cc.Bucket)
+	}
+
+	if cc.Pathname == "" {
+		cc.Pathname = defaultCoordinationPathname
+	}
+
+	if cc.TTL == 0 {
+		cc.TTL = defaultCoordinationTTL
+	}
+	if cc.TTL < minCoordinationTTL {
+		return fmt.Errorf("ttl must be at least %s", minCoordinationTTL)
+	}
+
+	if cc.Instance == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "rr"
+		}
+		cc.Instance = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	return nil
+}
+
+// coordinator holds or competes for the leader lease. Only its run loop writes the lease.
+type coordinator struct {
+	cfg *CoordinationConfig
+	ops *Operations
+	log *zap.Logger
+
+	// Token identifying the leases written by this instance
+	token string
+
+	// ETag of the lease held by this instance (run loop only)
+	etag string
+
+	// Expiry of the held lease in Unix nanoseconds, read by isLeader outside the run loop
+	expires atomic.Int64
+
+	leading atomic.Bool
+}
+
+// newCoordinator creates the coordinator of this instance; it does not lead until run takes the lease
+func newCoordinator(cfg *CoordinationConfig, ops *Operations, log *zap.Logger) (*coordinator, error) {
+	token, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+	return &coordinator{cfg: cfg, ops: ops, log: log, token: token}, nil
+}
+
+// isLeader reports whether this instance runs scheduled work. Without coordination every instance does.
+// A lease past its expiry no longer counts, even while a stalled run loop has not stepped down yet.
+func (c *coordinator) isLeader() bool {
+	return c == nil || (c.leading.Load() && time.Now().UnixNano() < c.expires.Load())
+}
+
+// run takes the lease when it is free or expired and renews it while held. The lease is released
+// when ctx is cancelled, so another instance takes over without waiting for it to expire.
+func (c *coordinator) run(ctx context.Context, wg *sync.WaitGroup) {
+	if c == nil {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(c.cfg.TTL / 3)
+		defer ticker.Stop()
+
+		for {
+			c.tick(ctx)
+
+			select {
+			case <-ctx.Done():
+				c.release()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// tick renews the held lease or tries to take it
+func (c *coordinator) tick(ctx context.Context) {
+	bucket, err := c.ops.plugin.buckets.GetBucket(c.cfg.Bucket)
+	if err != nil {
+		c.setLeading(false)
+		c.log.Warn("coordination bucket is not registered", zap.String("bucket", c.cfg.Bucket))
+		return
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return
+	}
+	defer bucket.Release()
+
+	now := time.Now()
+	key := bucket.ObjectKey(c.cfg.Pathname)
+	lease := fileLock{
+		Token:      c.token,
+		Owner:      c.cfg.Instance,
+		AcquiredAt: now.Unix(),
+		ExpiresAt:  now.Add(c.cfg.TTL).Unix(),
+	}
+
+	var etag string
+	if c.leading.Load() {
+		etag, err = c.ops.putLock(ctx, bucket, key, &lease, &s3.PutObjectInput{IfMatch: aws.String(c.etag)})
+		if err != nil && isConditionalConflict(err) {
+			c.setLeading(false)
+			c.log.Warn("leader lease was taken over", zap.String("instance", c.cfg.Instance))
+			return
+		}
+	} else {
+		etag, err = c.take(ctx, bucket, key, &lease, now)
+		if etag == "" && err == nil {
+			return
+		}
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		c.log.Warn("failed to write leader lease", zap.String("instance", c.cfg.Instance), zap.Error(err))
+		// Step down before the lease may have expired and another instance taken it
+		if c.leading.Load() && time.Now().Add(c.cfg.TTL/3).UnixNano() > c.expires.Load() {
+			c.setLeading(false)
+		}
+		return
+	}
+
+	c.etag = etag
+	c.expires.Store(now.Add(c.cfg.TTL).UnixNano())
+	c.setLeading(true)
+}
+
+// take creates the lease if there is none, or takes over an expired one. An empty ETag without
+// error means another instance holds it.
+func (c *coordinator) take(ctx context.Context, bucket *Bucket, key string, lease *fileLock, now time.Time) (string, error) {
+	etag, err := c.ops.putLock(ctx, bucket, key, lease, &s3.PutObjectInput{IfNoneMatch: aws.String("*")})
+	if err == nil || !isConditionalConflict(err) {
+		return etag, err
+	}
+
+	held, heldETag, err := c.ops.readLock(ctx, bucket, key)
+	switch {
+	case err != nil:
+		return "", err
+	case held == nil:
+		// Released in between; compete for it again
+		etag, err = c.ops.putLock(ctx, bucket, key, lease, &s3.PutObjectInput{IfNoneMatch: aws.String("*")})
+	case held.Token == c.token || held.expired(now):
+		// A lease of this instance outlived a failed renewal, or its holder stopped renewing
+		etag, err = c.ops.putLock(ctx, bucket, key, lease, &s3.PutObjectInput{IfMatch: aws.String(heldETag)})
+	default:
+		return "", nil
+	}
+
+	if err != nil && isConditionalConflict(err) {
+		// Another instance won the race
+		return "", nil
+	}
+	return etag, err
+}
+
+// release deletes the held lease. It runs after the plugin context is cancelled, so it uses its own.
+func (c *coordinator) release() {
+	if !c.leading.Load() {
+		return
+	}
+	c.setLeading(false)
+
+	bucket, err := c.ops.plugin.buckets.GetBucket(c.cfg.Bucket)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// If-Match keeps a lease taken over by another instance
+	_, err = bucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:  aws.String(bucket.Config.Bucket),
+		Key:     aws.String(bucket.ObjectKey(c.cfg.Pathname)),
+		IfMatch: aws.String(c.etag),
+	})
+	if err != nil && !isConditionalConflict(err) {
+		c.log.Warn("failed to release leader lease", zap.String("instance", c.cfg.Instance), zap.Error(err))
+	}
+}
+
+// setLeading records a change of leadership and logs it
+func (c *coordinator) setLeading(leading bool) {
+	if c.leading.Swap(leading) == leading {
+		return
+	}
+	if leading {
+		c.log.Info("became leader, running scheduled jobs", zap.String("instance", c.cfg.Instance))
+	} else {
+		c.log.Info("no longer leader, scheduled jobs run elsewhere", zap.String("instance", c.cfg.Instance))
+	}
+}
@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"context"
+	"strings"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	// RequestClassA covers PUT, COPY, POST and LIST requests (the higher-priced S3 tier)
+	RequestClassA = "A"
+
+	// RequestClassB covers GET, HEAD, SELECT and all other requests
+	RequestClassB = "B"
+
+	// RequestClassFree covers DELETE and multipart abort requests, which S3 does not bill
+	RequestClassFree = "free"
+)
+
+// requestObserver receives every S3 API request sent for a bucket: its API operation,
+// pricing class and the number of bytes downloaded by the response
+type requestObserver func(bucket, api, class string, egress int64)
+
+// requestClass maps an S3 API operation name to its pricing class
+func requestClass(api string) string {
+	switch {
+	case api == "DeleteObject", api == "DeleteObjects", api == "AbortMultipartUpload":
+		return RequestClassFree
+	case strings.HasPrefix(api, "Put"), strings.HasPrefix(api, "List"),
+		api == "CopyObject", api == "UploadPart", api == "UploadPartCopy",
+		api == "CreateMultipartUpload", api == "CompleteMultipartUpload", api == "RestoreObject":
+		return RequestClassA
+	default:
+		return RequestClassB
+	}
+}
+
+// requestCostMiddleware reports each request attempt (retries are billed too) to observe.
+// It sits in the deserialize step, which presigning skips, so presigned URLs are not counted.
+func requestCostMiddleware(bucket string, observe requestObserver) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("S3RequestCost",
+			func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleDeserialize(ctx, in)
+
+				api := awsmiddleware.GetOperationName(ctx)
+
+				var egress int64
+				if resp, ok := out.RawResponse.(*smithyhttp.Response); ok && api == "GetObject" &&
+					resp.StatusCode >= 200 && resp.StatusCode < 300 && resp.ContentLength > 0 {
+					egress = resp.ContentLength
+				}
+
+				observe(bucket, api, requestClass(api), egress)
+				return out, metadata, err
+			}), middleware.Before)
+	}
+}
@@ -0,0 +1,76 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Deadline is embedded in every request. A positive deadline_ms bounds how long the operation
+// may run, so S3 transfers of abandoned PHP requests are cancelled instead of running to completion.
+type Deadline struct {
+	DeadlineMS int64 `json:"deadline_ms,omitempty"`
+}
+
+func (d Deadline) deadline() time.Duration {
+	return time.Duration(d.DeadlineMS) * time.Millisecond
+}
+
+// deadlineCarrier is implemented by requests embedding Deadline
+type deadlineCarrier interface {
+	deadline() time.Duration
+}
+
+// withRequestDeadline bounds ctx by the request's deadline_ms, if any
+func withRequestDeadline(ctx context.Context, req any) (context.Context, context.CancelFunc, time.Duration, error) {
+	carrier, ok := req.(deadlineCarrier)
+	if !ok {
+		return ctx, func() {}, 0, nil
+	}
+
+	deadline := carrier.deadline()
+	switch {
+	case deadline < 0:
+		return ctx, func() {}, 0, NewInvalidRequestError("deadline_ms must not be negative")
+	case deadline == 0:
+		return ctx, func() {}, 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	return ctx, cancel, deadline, nil
+}
+
+// contextError maps the error of an operation aborted by its context to OPERATION_TIMEOUT or
+// CANCELLED with the elapsed time, instead of the S3 failure the aborted request surfaced as
+func contextError(ctx context.Context, operation string, deadline, elapsed time.Duration, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return NewOperationTimeoutError(operation, deadline, elapsed)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return NewOperationCancelledError(operation, elapsed)
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewOperationTimeoutError(operation, deadline, elapsed)
+	}
+	return err
+}
+
+// NewOperationTimeoutError creates an error for an operation that exceeded its deadline
+func NewOperationTimeoutError(operation string, deadline, elapsed time.Duration) *S3Error {
+	return NewS3Error(
+		ErrOperationTimeout,
+		"Operation exceeded its deadline",
+		"operation: "+operation+
+			", deadline_ms: "+strconv.FormatInt(deadline.Milliseconds(), 10)+
+			", elapsed_ms: "+strconv.FormatInt(elapsed.Milliseconds(), 10),
+	)
+}
+
+// NewOperationCancelledError creates a retryable error for an operation aborted before completion
+func NewOperationCancelledError(operation string, elapsed time.Duration) *S3Error {
+	return NewS3Error(
+		ErrCancelled,
+		"Operation was cancelled",
+		"operation: "+operation+", elapsed_ms: "+strconv.FormatInt(elapsed.Milliseconds(), 10),
+	)
+}
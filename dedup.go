@@ -0,0 +1,387 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// dedupGCJobType identifies dedup garbage collection jobs
+	dedupGCJobType = "dedup_gc"
+
+	// dedupHashMetadata is the user metadata key of a pointer object holding the blob hash
+	dedupHashMetadata = "dedup-sha256"
+
+	// dedupSizeMetadata is the user metadata key of a pointer object holding the content size
+	dedupSizeMetadata = "dedup-size"
+
+	// defaultDedupGCGrace protects blobs uploaded by writes whose pointer is not stored yet
+	defaultDedupGCGrace = time.Hour
+)
+
+// dedupBlobPath returns the bucket-relative path of the blob with the given hex sha256
+func dedupBlobPath(prefix, sum string) string {
+	return prefix + sum[:2] + "/" + sum
+}
+
+// checkBlobPathname rejects pathnames inside the blob prefix of a dedup bucket. Blobs are shared
+// by every pointer to the same content, so only deduplicated writes may create them.
+func (b *Bucket) checkBlobPathname(pathname string) *S3Error {
+	if b.Config.Dedup && strings.HasPrefix(pathname, b.Config.DedupPrefix) {
+		return NewInvalidPathnameError(pathname, "pathname is inside the dedup blob prefix")
+	}
+	return nil
+}
+
+// checkBlobPrefix rejects destination prefixes overlapping the blob prefix of a dedup bucket, for
+// operations that create objects whose final pathnames are only known under the prefix
+func (b *Bucket) checkBlobPrefix(prefix string) *S3Error {
+	if b.Config.Dedup && prefixesOverlap(prefix, b.Config.DedupPrefix) {
+		return NewInvalidRequestError(fmt.Sprintf("prefix '%s' overlaps the dedup blob prefix", prefix))
+	}
+	return nil
+}
+
+// dedupPointer returns the blob hash and content size recorded in pointer metadata;
+// an empty hash means the object is a regular object
+func dedupPointer(metadata map[string]string) (string, int64) {
+	sum := metadata[dedupHashMetadata]
+	if sum == "" {
+		return "", 0
+	}
+
+	size, _ := strconv.ParseInt(metadata[dedupSizeMetadata], 10, 64)
+	return sum, size
+}
+
+// writeDedup stores content once under its sha256 and writes a zero-byte pointer object at the pathname.
+// The caller holds the bucket semaphore.
+// Only the pointer expires; the blob is swept by dedup GC once unreferenced.
+func (o *Operations) writeDedup(ctx context.Context, bucket *Bucket, req *WriteRequest, resp *WriteResponse, contentType string, expiry *objectExpiry) error {
+	digest := sha256.Sum256(req.Content)
+	sum := hex.EncodeToString(digest[:])
+	blobKey := bucket.GetFullPath(dedupBlobPath(bucket.Config.DedupPrefix, sum))
+
+	// Upload the blob only when no identical content is stored yet
+	head, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(blobKey),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		var nf *types.NotFound
+		if !errors.As(err, &nsk) && !errors.As(err, &nf) {
+			o.log.Error("failed to check dedup blob",
+				zap.String("bucket", req.Bucket),
+				zap.String("pathname", req.Pathname),
+				zap.Error(err),
+			)
+			o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+			return NewS3OperationError("head blob", err)
+		}
+
+		uploader := bucket.NewUploader(int64(len(req.Content)))
+		req.Transfer.tune(uploader, bucket, int64(len(req.Content)))
+
+		if _, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket:       aws.String(bucket.Config.Bucket),
+			Key:          aws.String(blobKey),
+			Body:         bytes.NewReader(req.Content),
+			ContentType:  aws.String(contentType),
+			StorageClass: types.StorageClass(req.StorageClass),
+		}); err != nil {
+			o.abortInterruptedUpload(ctx, bucket, blobKey, err)
+			o.log.Error("failed to upload dedup blob",
+				zap.String("bucket", req.Bucket),
+				zap.String("pathname", req.Pathname),
+				zap.Error(err),
+			)
+			o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+			return NewS3OperationError("upload blob", err)
+		}
+	} else {
+		// Copying the blob onto itself moves LastModified into the GC grace period, so a sweep
+		// that counted references before this pointer exists cannot delete the blob
+		if _, err := bucket.Client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(bucket.Config.Bucket),
+			Key:               aws.String(blobKey),
+			CopySource:        aws.String(buildCopySource(bucket.Config.Bucket, blobKey, bucket.ServerConfig.CopySourceEncoding)),
+			ContentType:       head.ContentType,
+			Metadata:          head.Metadata,
+			MetadataDirective: types.MetadataDirectiveReplace,
+			StorageClass:      head.StorageClass,
+		}); err != nil {
+			o.log.Error("failed to refresh dedup blob",
+				zap.String("bucket", req.Bucket),
+				zap.String("pathname", req.Pathname),
+				zap.Error(err),
+			)
+			o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+			return NewS3OperationError("refresh blob", err)
+		}
+		o.plugin.metrics.RecordDedupHit(req.Bucket, int64(len(req.Content)))
+	}
+
+	metadata := make(map[string]string, len(req.Config)+2)
+	for k, v := range req.Config {
+		metadata[k] = v
+	}
+	metadata[dedupHashMetadata] = sum
+	metadata[dedupSizeMetadata] = strconv.Itoa(len(req.Content))
+
+	pointer := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket.Config.Bucket),
+		Key:         aws.String(bucket.ObjectKey(req.Pathname)),
+		Body:        bytes.NewReader(nil),
+		ACL:         bucket.ObjectACL(req.Visibility),
+		ContentType: aws.String(contentType),
+		Metadata:    metadata,
+	}
+	if req.Grants != nil {
+		req.Grants.apply(pointer)
+	}
+	if expiry != nil {
+		expiry.apply(pointer)
+		resp.ExpirationDays = expiry.days
+	}
+
+	if _, err := bucket.Client.PutObject(ctx, pointer); err != nil {
+		o.log.Error("failed to write dedup pointer",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("upload pointer", err)
+	}
+
+	bucket.filter.add(bucket.ObjectKey(req.Pathname))
+	o.invalidateDerived(ctx, bucket, req.Pathname)
+	o.plugin.index.update(bucket, req.Pathname)
+
+	resp.Success = true
+	resp.Pathname = req.Pathname
+	resp.Size = int64(len(req.Content))
+	resp.LastModified = time.Now().Unix()
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "write", "success")
+
+	o.log.Debug("file stored deduplicated",
+		zap.String("bucket", req.Bucket),
+		zap.String("pathname", req.Pathname),
+		zap.String("sha256", sum),
+		zap.Int64("size", resp.Size),
+	)
+
+	return nil
+}
+
+// dedupSource returns the bucket-relative path holding the content of pathname:
+// the blob for pointer objects, pathname itself otherwise
+func (o *Operations) dedupSource(ctx context.Context, bucket *Bucket, pathname string) (string, error) {
+	source, _, err := o.dedupResolve(ctx, bucket, pathname)
+	return source, err
+}
+
+// dedupResolve works like dedupSource and also returns the head of pointer objects
+// (nil otherwise), whose content type and metadata belong to the resolved content
+func (o *Operations) dedupResolve(ctx context.Context, bucket *Bucket, pathname string) (string, *s3.HeadObjectOutput, error) {
+	head, err := bucket.Reader().HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.ObjectKey(pathname)),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if sum, _ := dedupPointer(head.Metadata); sum != "" {
+		return dedupBlobPath(bucket.Config.DedupPrefix, sum), head, nil
+	}
+	return pathname, nil, nil
+}
+
+// pointerMetadata returns the user metadata of a pointer object without the dedup keys,
+// as carried over to a standalone copy of its content
+func pointerMetadata(metadata map[string]string) map[string]string {
+	out := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if k != dedupHashMetadata && k != dedupSizeMetadata {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// StartDedupGC launches a job deleting blobs no longer referenced by any pointer object
+func (o *Operations) StartDedupGC(ctx context.Context, req *DedupGCRequest, resp *StartJobResponse) error {
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "dedup_gc", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "StartDedupGC", "dedup_gc", bucket.Name); err != nil {
+		return err
+	}
+
+	if !bucket.Config.Dedup {
+		o.plugin.metrics.RecordOperation(req.Bucket, "dedup_gc", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("bucket '%s' does not have dedup enabled", req.Bucket))
+	}
+
+	grace := defaultDedupGCGrace
+	if req.GracePeriod != "" {
+		grace, err = time.ParseDuration(req.GracePeriod)
+		if err != nil || grace < 0 {
+			o.plugin.metrics.RecordOperation(req.Bucket, "dedup_gc", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return NewInvalidRequestError("grace_period must be a non-negative duration (e.g., \"1h\")")
+		}
+	}
+
+	job, err := o.plugin.jobs.Start(ctx, dedupGCJobType, "", []string{bucket.Name}, req, func(ctx context.Context, job *Job) error {
+		return o.runDedupGC(ctx, job, bucket.Name, grace)
+	})
+	if err != nil {
+		return asS3Error("start dedup gc", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// runDedupGC counts references from pointer objects, then deletes unreferenced blobs older than grace
+func (o *Operations) runDedupGC(ctx context.Context, job *Job, name string, grace time.Duration) error {
+	bucket, err := o.plugin.buckets.GetBucket(name)
+	if err != nil {
+		return NewBucketNotFoundError(name)
+	}
+
+	blobPrefix := bucket.GetFullPath(bucket.Config.DedupPrefix)
+	refs := make(map[string]int)
+
+	// Blobs created after this point may belong to writes still in progress
+	cutoff := time.Now().Add(-grace)
+
+	job.SetStatus(JobRunning, "counting references")
+	err = o.walkObjects(ctx, bucket, bucket.GetFullPath(""), func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		// Pointer objects are always empty
+		if strings.HasPrefix(key, blobPrefix) || aws.ToInt64(obj.Size) != 0 {
+			return nil
+		}
+
+		if err := bucket.Acquire(ctx); err != nil {
+			return err
+		}
+		head, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    aws.String(key),
+		})
+		bucket.Release()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// An unreadable pointer could reference any blob, so sweeping is unsafe
+			return fmt.Errorf("failed to inspect '%s': %w", key, err)
+		}
+
+		if sum, _ := dedupPointer(head.Metadata); sum != "" {
+			refs[sum]++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	job.SetStatus(JobRunning, fmt.Sprintf("sweeping blobs (%d referenced)", len(refs)))
+	return o.walkObjects(ctx, bucket, blobPrefix, func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		if refs[path.Base(key)] > 0 || aws.ToTime(obj.LastModified).After(cutoff) {
+			return nil
+		}
+
+		if err := bucket.Acquire(ctx); err != nil {
+			return err
+		}
+		// A blob replaced since it was listed may be referenced by a newer write
+		_, err := bucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket:  aws.String(bucket.Config.Bucket),
+			Key:     aws.String(key),
+			IfMatch: obj.ETag,
+		})
+		bucket.Release()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if isConditionalConflict(err) {
+				return nil
+			}
+			o.plugin.metrics.RecordOperation(name, "dedup_gc", "error")
+			o.plugin.metrics.RecordError(name, ErrS3Operation)
+			job.AddFailure(key, err)
+			return nil
+		}
+
+		o.plugin.metrics.RecordOperation(name, "dedup_gc", "success")
+		job.AddProgress(aws.ToInt64(obj.Size))
+		job.SetCheckpoint(key)
+		return nil
+	})
+}
+
+// walkObjects calls fn for every object under prefix, page by page
+func (o *Operations) walkObjects(ctx context.Context, bucket *Bucket, prefix string, fn func(obj types.Object) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket.Config.Bucket),
+		MaxKeys: aws.Int32(maxListKeys),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	for {
+		if err := bucket.Acquire(ctx); err != nil {
+			return err
+		}
+		page, err := bucket.Client.ListObjectsV2(ctx, input)
+		bucket.Release()
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+
+		if !aws.ToBool(page.IsTruncated) || page.NextContinuationToken == nil {
+			return nil
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+}
@@ -0,0 +1,115 @@
+package s3
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+// derivedPathname returns the deterministic bucket-relative path of a derived object
+// (thumbnail, resized or re-encoded variant): <derived_prefix><source>/<variant>
+func derivedPathname(prefix, source, variant string) string {
+	return prefix + source + "/" + variant
+}
+
+// checkDerivedVariant validates a derived variant name
+func checkDerivedVariant(variant string) *S3Error {
+	if variant == "" || strings.Contains(variant, "/") || strings.Contains(variant, "..") {
+		return NewInvalidRequestError("variant must be a non-empty name without '/' or '..'")
+	}
+	return nil
+}
+
+// invalidateDerived deletes all derived objects of a source after it was overwritten or deleted.
+// Failures are logged only: the source operation already succeeded. The caller holds the bucket semaphore.
+func (o *Operations) invalidateDerived(ctx context.Context, bucket *Bucket, source string) {
+	prefix := bucket.Config.DerivedPrefix
+	if prefix == "" || strings.HasPrefix(source, prefix) {
+		return
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Prefix: aws.String(bucket.GetFullPath(derivedPathname(prefix, source, ""))),
+	}
+
+	var deleted int
+	for {
+		page, err := bucket.Client.ListObjectsV2(ctx, input)
+		if err != nil {
+			o.log.Warn("failed to list derived objects",
+				zap.String("bucket", bucket.Name),
+				zap.String("pathname", source),
+				zap.Error(err),
+			)
+			return
+		}
+
+		if len(page.Contents) > 0 {
+			objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+			for _, obj := range page.Contents {
+				objects = append(objects, types.ObjectIdentifier{Key: obj.Key})
+			}
+
+			if _, err := bucket.Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket.Config.Bucket),
+				Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+			}); err != nil {
+				o.log.Warn("failed to delete derived objects",
+					zap.String("bucket", bucket.Name),
+					zap.String("pathname", source),
+					zap.Error(err),
+				)
+				return
+			}
+			deleted += len(objects)
+		}
+
+		if !aws.ToBool(page.IsTruncated) || page.NextContinuationToken == nil {
+			break
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+
+	if deleted > 0 {
+		o.log.Debug("derived objects invalidated",
+			zap.String("bucket", bucket.Name),
+			zap.String("pathname", source),
+			zap.Int("count", deleted),
+		)
+	}
+}
+
+// GetDerivedPathname returns where a derived variant of a source object is stored
+func (o *Operations) GetDerivedPathname(ctx context.Context, req *GetDerivedPathnameRequest, resp *GetDerivedPathnameResponse) error {
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		return err
+	}
+
+	if err := checkDerivedVariant(req.Variant); err != nil {
+		return err
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "GetDerivedPathname", "get_derived_pathname", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if bucket.Config.DerivedPrefix == "" {
+		return NewInvalidRequestError("bucket '" + req.Bucket + "' has no derived_prefix configured")
+	}
+
+	resp.Pathname = derivedPathname(bucket.Config.DerivedPrefix, req.Pathname, req.Variant)
+	return nil
+}
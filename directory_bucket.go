@@ -0,0 +1,121 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// directoryBucketPattern matches S3 Express One Zone bucket names: <base>--<zone-id>--x-s3
+var directoryBucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*[a-z0-9]--[a-z0-9-]+--x-s3$`)
+
+// directoryCapabilities are the features of S3 Express One Zone directory buckets: no versioning,
+// tagging or ACLs (object ownership is always BucketOwnerEnforced)
+var directoryCapabilities = Capabilities{Multipart: true, Checksums: true}
+
+// validateDirectoryBucket checks the options of a directory bucket and applies its defaults.
+// Directory buckets are never public, so visibility is emulated with presigned URLs.
+func (bc *BucketConfig) validateDirectoryBucket(server *ServerConfig) error {
+	if !directoryBucketPattern.MatchString(bc.Bucket) {
+		return fmt.Errorf("directory_bucket requires a bucket name of the form '<name>--<zone-id>--x-s3', got '%s'", bc.Bucket)
+	}
+	if server.Compatibility != CompatibilityAWS || server.Endpoint != "" {
+		return fmt.Errorf("directory_bucket requires an AWS server without endpoint, the zonal endpoint is derived from the bucket name")
+	}
+	if server.Anonymous {
+		return fmt.Errorf("directory_bucket requires a server with credentials")
+	}
+
+	if bc.PublicMode == PublicModePrefix {
+		return fmt.Errorf("directory buckets cannot be public, use public_mode '%s'", PublicModePresign)
+	}
+	bc.DisableACL = true
+	bc.PublicMode = PublicModePresign
+
+	// Unordered listings cannot be merged across shards; lifecycle rules cannot filter by tag
+	switch {
+	case bc.Sharding != nil:
+		return fmt.Errorf("directory_bucket cannot be combined with sharding")
+	case bc.Expiration != nil:
+		return fmt.Errorf("directory_bucket cannot be combined with expiration")
+	case bc.Temporary != nil:
+		return fmt.Errorf("directory_bucket cannot be combined with temporary")
+	case bc.Batch != nil:
+		return fmt.Errorf("directory_bucket cannot be combined with batch")
+	case bc.CreateIfMissing:
+		return fmt.Errorf("directory_bucket cannot be combined with create_if_missing")
+	}
+
+	return nil
+}
+
+// directoryListingMiddleware adapts ListObjectsV2 to directory buckets, which only accept "/" as
+// delimiter and prefixes ending in "/". Other prefixes are listed from their parent directory and
+// filtered, so callers see the same keys as from a general purpose bucket, although not in order.
+func directoryListingMiddleware() func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("S3DirectoryListing",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				input, ok := in.Parameters.(*s3.ListObjectsV2Input)
+				if !ok {
+					return next.HandleInitialize(ctx, in)
+				}
+
+				if d := aws.ToString(input.Delimiter); d != "" && d != "/" {
+					return middleware.InitializeOutput{}, middleware.Metadata{}, fmt.Errorf("directory buckets only support the '/' delimiter, got '%s'", d)
+				}
+				if input.StartAfter != nil {
+					return middleware.InitializeOutput{}, middleware.Metadata{}, fmt.Errorf("directory buckets do not support start_after, listings are unordered")
+				}
+
+				prefix := aws.ToString(input.Prefix)
+				if prefix == "" || strings.HasSuffix(prefix, "/") {
+					return next.HandleInitialize(ctx, in)
+				}
+
+				adjusted := *input
+				adjusted.Prefix = nil
+				if i := strings.LastIndexByte(prefix, '/'); i >= 0 {
+					adjusted.Prefix = aws.String(prefix[:i+1])
+				}
+				in.Parameters = &adjusted
+
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				if err != nil {
+					return out, metadata, err
+				}
+
+				if page, ok := out.Result.(*s3.ListObjectsV2Output); ok {
+					filterDirectoryPage(page, prefix)
+				}
+				return out, metadata, err
+			}), middleware.Before)
+	}
+}
+
+// filterDirectoryPage drops the entries of a listing page that do not start with prefix
+func filterDirectoryPage(page *s3.ListObjectsV2Output, prefix string) {
+	contents := page.Contents[:0]
+	for _, obj := range page.Contents {
+		if strings.HasPrefix(aws.ToString(obj.Key), prefix) {
+			contents = append(contents, obj)
+		}
+	}
+	page.Contents = contents
+
+	prefixes := page.CommonPrefixes[:0]
+	for _, cp := range page.CommonPrefixes {
+		if strings.HasPrefix(aws.ToString(cp.Prefix), prefix) {
+			prefixes = append(prefixes, cp)
+		}
+	}
+	page.CommonPrefixes = prefixes
+
+	page.Prefix = aws.String(prefix)
+	page.KeyCount = aws.Int32(int32(len(contents) + len(prefixes)))
+}
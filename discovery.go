@@ -0,0 +1,99 @@
+package s3
+
+import (
+	"reflect"
+	"runtime/debug"
+	"sort"
+)
+
+// APIVersion is the version of the RPC contract; it changes only on incompatible request/response changes
+const APIVersion = 1
+
+// Version is the plugin release, overridable at build time with
+// -ldflags "-X github.com/roadrunner-plugins/s3-storage.Version=v1.2.3"
+var Version = ""
+
+// modulePath is used to find the plugin release in the binary's build info
+const modulePath = "github.com/roadrunner-plugins/s3-storage"
+
+// CapabilityOptions describes optional features and request limits supported by the plugin
+type CapabilityOptions struct {
+	ErrorFormats           []string `json:"error_formats"`
+	ErrorFormat            string   `json:"error_format"`
+	Compression            []string `json:"compression"`
+	PresignMethods         []string `json:"presign_methods"`
+	ListingCursors         bool     `json:"listing_cursors"`
+	DownloadSessions       bool     `json:"download_sessions"`
+	MaxListKeys            int32    `json:"max_list_keys"`
+	MaxUploadParts         int      `json:"max_upload_parts"`
+	MaxPartSize            int64    `json:"max_part_size"`
+	MaxTransferConcurrency int      `json:"max_transfer_concurrency"`
+	MaxInvalidationPaths   int      `json:"max_invalidation_paths"`
+	MaxCompareSamples      int      `json:"max_compare_samples"`
+	MaxLockTTL             string   `json:"max_lock_ttl"`
+	MaxPresignTTL          string   `json:"max_presign_ttl"`
+}
+
+// pluginVersion returns the release set via ldflags, falling back to the module version from build info
+func pluginVersion() string {
+	if Version != "" {
+		return Version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == modulePath && info.Main.Version != "" {
+			return info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				return dep.Version
+			}
+		}
+	}
+
+	return "dev"
+}
+
+// rpcOperations lists the RPC methods exposed to PHP, sorted by name
+func rpcOperations() []string {
+	t := reflect.TypeOf(&rpc{})
+	ops := make([]string, 0, t.NumMethod())
+	for i := 0; i < t.NumMethod(); i++ {
+		ops = append(ops, t.Method(i).Name)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// capabilities fills resp with the plugin version, operations, options and per-bucket provider capabilities
+func (p *Plugin) capabilities(resp *GetCapabilitiesResponse) {
+	resp.Version = pluginVersion()
+	resp.APIVersion = APIVersion
+	resp.Operations = rpcOperations()
+	resp.Options = CapabilityOptions{
+		ErrorFormats:           []string{ErrorFormatText, ErrorFormatJSON},
+		ErrorFormat:            p.config.ErrorFormat,
+		Compression:            []string{EncodingGzip},
+		PresignMethods:         []string{"GET", "POST"},
+		ListingCursors:         true,
+		DownloadSessions:       true,
+		MaxListKeys:            maxListKeys,
+		MaxUploadParts:         maxUploadParts,
+		MaxPartSize:            maxPartSize,
+		MaxTransferConcurrency: maxTransferConcurrency,
+		MaxInvalidationPaths:   maxInvalidationPaths,
+		MaxCompareSamples:      maxCompareSamples,
+		MaxLockTTL:             maxLockTTL.String(),
+		MaxPresignTTL:          maxPresignTTL.String(),
+	}
+
+	resp.Buckets = make(map[string]Capabilities)
+	for _, name := range p.buckets.ListBuckets() {
+		bucket, err := p.buckets.GetBucket(name)
+		if err != nil {
+			// Removed concurrently
+			continue
+		}
+		resp.Buckets[name] = bucket.Capabilities
+	}
+}
@@ -0,0 +1,437 @@
+package s3
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultDownloadChunkSize is used when neither the session nor the fetch request specify a chunk size
+	defaultDownloadChunkSize int64 = 1024 * 1024 // 1MB
+
+	// maxDownloadChunkSize bounds a single chunk to keep RPC payloads reasonable
+	maxDownloadChunkSize int64 = 64 * 1024 * 1024 // 64MB
+)
+
+// DownloadSessionManager keeps track of resumable download sessions
+type DownloadSessionManager struct {
+	// Map of session ID to session
+	sessions map[string]*downloadSession
+
+	// Idle time after which a session is discarded
+	ttl time.Duration
+
+	// Mutex for thread-safe access
+	mu sync.Mutex
+}
+
+// downloadSession represents a sequential, offset-tracked read of a single object
+type downloadSession struct {
+	id        string
+	bucket    string
+	pathname  string
+	source    string // Path the content is read from (differs from pathname for dedup pointers)
+	etag      string
+	size      int64
+	offset    int64
+	chunkSize int64
+	lastUsed  time.Time
+
+	// Serializes chunk fetches so offsets advance in order
+	mu sync.Mutex
+}
+
+// NewDownloadSessionManager creates a new download session manager
+func NewDownloadSessionManager(ttl time.Duration) *DownloadSessionManager {
+	return &DownloadSessionManager{
+		sessions: make(map[string]*downloadSession),
+		ttl:      ttl,
+	}
+}
+
+// create stores a new session, discarding expired ones
+func (dm *DownloadSessionManager) create(session *downloadSession) error {
+	id, err := newRandomID()
+	if err != nil {
+		return err
+	}
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	dm.pruneLocked()
+
+	session.id = id
+	session.lastUsed = time.Now()
+	dm.sessions[id] = session
+	return nil
+}
+
+// get returns an active session and refreshes its idle timer
+func (dm *DownloadSessionManager) get(id string) (*downloadSession, bool) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	session, exists := dm.sessions[id]
+	if !exists {
+		return nil, false
+	}
+
+	if time.Since(session.lastUsed) > dm.ttl {
+		delete(dm.sessions, id)
+		return nil, false
+	}
+
+	session.lastUsed = time.Now()
+	return session, true
+}
+
+// remove deletes a session, reporting whether it existed
+func (dm *DownloadSessionManager) remove(id string) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if _, exists := dm.sessions[id]; !exists {
+		return false
+	}
+
+	delete(dm.sessions, id)
+	return true
+}
+
+// pruneLocked removes sessions idle for longer than the TTL; caller must hold dm.mu
+func (dm *DownloadSessionManager) pruneLocked() {
+	for id, session := range dm.sessions {
+		if time.Since(session.lastUsed) > dm.ttl {
+			delete(dm.sessions, id)
+		}
+	}
+}
+
+// newRandomID generates a random hex identifier for sessions and jobs
+func newRandomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// state copies the session into a response-friendly structure
+func (s *downloadSession) state() DownloadSessionState {
+	return DownloadSessionState{
+		SessionID: s.id,
+		Bucket:    s.bucket,
+		Pathname:  s.pathname,
+		ETag:      s.etag,
+		Size:      s.size,
+		Offset:    s.offset,
+		ChunkSize: s.chunkSize,
+		EOF:       s.offset >= s.size,
+	}
+}
+
+// StartDownloadSession opens a download session for an object, optionally at a recorded offset
+func (o *Operations) StartDownloadSession(ctx context.Context, req *StartDownloadSessionRequest, resp *DownloadSessionState) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	if req.Offset < 0 || req.ChunkSize < 0 || req.ChunkSize > maxDownloadChunkSize {
+		o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("offset must be >= 0 and chunk_size between 0 and %d", maxDownloadChunkSize))
+	}
+
+	if err := o.route(&req.Bucket, req.Pathname, "download_session_start"); err != nil {
+		return err
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "StartDownloadSession", "download_session_start", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	// Pointer objects of dedup buckets are read from their immutable blob
+	source := req.Pathname
+	if bucket.Config.Dedup {
+		source, err = o.dedupSource(ctx, bucket, req.Pathname)
+		if err != nil {
+			var nsk *types.NoSuchKey
+			var nf *types.NotFound
+			if errors.As(err, &nsk) || errors.As(err, &nf) {
+				o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "error")
+				o.plugin.metrics.RecordError(req.Bucket, ErrFileNotFound)
+				return NewFileNotFoundError(req.Pathname)
+			}
+			o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+			return NewS3OperationError("head object", err)
+		}
+	}
+
+	// Get full S3 key
+	key := bucket.ObjectKey(source)
+
+	headInput := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+	}
+	// When resuming, make sure the object is still the one we started with
+	if req.ETag != "" {
+		headInput.IfMatch = aws.String(req.ETag)
+	}
+
+	head, err := bucket.Reader().HeadObject(ctx, headInput)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "error")
+		if isPreconditionFailed(err) {
+			o.plugin.metrics.RecordError(req.Bucket, ErrObjectChanged)
+			return NewObjectChangedError(req.Pathname)
+		}
+		var nsk *types.NoSuchKey
+		var nf *types.NotFound
+		if errors.As(err, &nsk) || errors.As(err, &nf) {
+			o.plugin.metrics.RecordError(req.Bucket, ErrFileNotFound)
+			return NewFileNotFoundError(req.Pathname)
+		}
+		o.log.Error("failed to start download session",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("head object", err)
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	if req.Offset > size {
+		o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("offset %d exceeds object size %d", req.Offset, size))
+	}
+
+	chunkSize := req.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = defaultDownloadChunkSize
+	}
+
+	session := &downloadSession{
+		bucket:    req.Bucket,
+		pathname:  req.Pathname,
+		source:    source,
+		etag:      aws.ToString(head.ETag),
+		size:      size,
+		offset:    req.Offset,
+		chunkSize: chunkSize,
+	}
+
+	if err := o.plugin.downloads.create(session); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("create download session", err)
+	}
+
+	*resp = session.state()
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "download_session_start", "success")
+
+	o.log.Debug("download session started",
+		zap.String("session", session.id),
+		zap.String("bucket", req.Bucket),
+		zap.String("pathname", req.Pathname),
+		zap.Int64("offset", session.offset),
+		zap.Int64("size", session.size),
+	)
+
+	return nil
+}
+
+// FetchDownloadChunk reads the next chunk of a session and advances its offset
+func (o *Operations) FetchDownloadChunk(ctx context.Context, req *FetchDownloadChunkRequest, resp *FetchDownloadChunkResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	session, exists := o.plugin.downloads.get(req.SessionID)
+	if !exists {
+		return NewSessionNotFoundError(req.SessionID)
+	}
+
+	// Check access
+	if err := o.authorizeIn(ctx, req.Caller, "FetchDownloadChunk", "download_session_fetch", session.bucket, session.pathname); err != nil {
+		return err
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if req.ChunkSize < 0 || req.ChunkSize > maxDownloadChunkSize {
+		o.plugin.metrics.RecordOperation(session.bucket, "download_session_fetch", "error")
+		o.plugin.metrics.RecordError(session.bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("chunk_size must be between 0 and %d", maxDownloadChunkSize))
+	}
+
+	resp.Offset = session.offset
+
+	// Nothing left to read
+	if session.offset >= session.size {
+		resp.NextOffset = session.offset
+		resp.EOF = true
+		o.plugin.metrics.RecordOperation(session.bucket, "download_session_fetch", "success")
+		return nil
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(session.bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(session.bucket, "download_session_fetch", "error")
+		o.plugin.metrics.RecordError(session.bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(session.bucket)
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	chunkSize := session.chunkSize
+	if req.ChunkSize > 0 {
+		chunkSize = req.ChunkSize
+	}
+
+	end := session.offset + chunkSize - 1
+	if end >= session.size {
+		end = session.size - 1
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.ObjectKey(session.source)),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", session.offset, end)),
+	}
+	if session.etag != "" {
+		input.IfMatch = aws.String(session.etag)
+	}
+
+	result, err := bucket.Reader().GetObject(ctx, input)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(session.bucket, "download_session_fetch", "error")
+		if isPreconditionFailed(err) {
+			o.plugin.metrics.RecordError(session.bucket, ErrObjectChanged)
+			return NewObjectChangedError(session.pathname)
+		}
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			o.plugin.metrics.RecordError(session.bucket, ErrFileNotFound)
+			return NewFileNotFoundError(session.pathname)
+		}
+		o.log.Error("failed to fetch download chunk",
+			zap.String("session", session.id),
+			zap.String("bucket", session.bucket),
+			zap.String("pathname", session.pathname),
+			zap.Int64("offset", session.offset),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordError(session.bucket, ErrS3Operation)
+		return NewS3OperationError("get object range", err)
+	}
+	defer result.Body.Close()
+
+	content, err := io.ReadAll(result.Body)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(session.bucket, "download_session_fetch", "error")
+		o.plugin.metrics.RecordError(session.bucket, ErrS3Operation)
+		return NewS3OperationError("read chunk content", err)
+	}
+
+	// Only advance once the whole chunk has been read
+	session.offset += int64(len(content))
+
+	resp.Content = content
+	resp.NextOffset = session.offset
+	resp.EOF = session.offset >= session.size
+
+	o.plugin.metrics.RecordOperation(session.bucket, "download_session_fetch", "success")
+
+	return nil
+}
+
+// GetDownloadSession returns the current state of a session, e.g. to resume after a worker restart
+func (o *Operations) GetDownloadSession(req *DownloadSessionRequest, resp *DownloadSessionState) error {
+	session, exists := o.plugin.downloads.get(req.SessionID)
+	if !exists {
+		return NewSessionNotFoundError(req.SessionID)
+	}
+
+	// Check access
+	if err := o.authorizeIn(o.plugin.ctx, req.Caller, "GetDownloadSession", "download_session_get", session.bucket, session.pathname); err != nil {
+		return err
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	*resp = session.state()
+	return nil
+}
+
+// CloseDownloadSession discards a session
+func (o *Operations) CloseDownloadSession(req *DownloadSessionRequest, resp *CloseDownloadSessionResponse) error {
+	session, exists := o.plugin.downloads.get(req.SessionID)
+	if !exists {
+		return NewSessionNotFoundError(req.SessionID)
+	}
+
+	// Check access
+	if err := o.authorizeIn(o.plugin.ctx, req.Caller, "CloseDownloadSession", "download_session_close", session.bucket, session.pathname); err != nil {
+		return err
+	}
+
+	if !o.plugin.downloads.remove(req.SessionID) {
+		return NewSessionNotFoundError(req.SessionID)
+	}
+
+	resp.Success = true
+	return nil
+}
+
+// isPreconditionFailed reports whether an S3 error is a failed If-Match/If-None-Match condition
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed"
+}
@@ -0,0 +1,373 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// duplicateScanJobType identifies duplicate detection jobs
+	duplicateScanJobType = "duplicate_scan"
+
+	// DuplicateCompareETag groups objects by size and ETag from the listing alone
+	DuplicateCompareETag = "etag"
+
+	// DuplicateCompareChecksum groups objects by size and a sha256 of their content, reading every
+	// object whose size is shared with another
+	DuplicateCompareChecksum = "checksum"
+
+	// DuplicateActionReport only reports duplicates
+	DuplicateActionReport = "report"
+
+	// DuplicateActionDelete deletes every duplicate but the first pathname of each group
+	DuplicateActionDelete = "delete"
+
+	// DuplicateActionDedup replaces every object of a group with a pointer to one blob (dedup buckets only)
+	DuplicateActionDedup = "dedup"
+
+	// defaultDuplicateGroups bounds the groups returned in a duplicate report
+	defaultDuplicateGroups = 1000
+)
+
+// duplicateCandidate is a listed object that may have duplicates
+type duplicateCandidate struct {
+	pathname string
+	key      string
+	etag     string
+}
+
+// duplicateKey identifies content: objects of the same size and checksum or ETag
+type duplicateKey struct {
+	size int64
+	sum  string
+}
+
+// StartDuplicateScan launches a job finding objects with identical content under a prefix; GetJob
+// returns a *DuplicateReport as its result once the job completed
+func (o *Operations) StartDuplicateScan(ctx context.Context, req *DuplicateScanRequest, resp *StartJobResponse) error {
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "duplicate_scan", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "StartDuplicateScan", "duplicate_scan", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+	// Removing duplicates deletes objects, and dedup rewrites them as pointers, so the caller
+	// needs the operations the job runs on their behalf
+	switch req.Action {
+	case DuplicateActionDelete:
+		if err := o.authorize(ctx, req.Caller, "Delete", "duplicate_scan", bucket.Name, req.Prefix); err != nil {
+			return err
+		}
+	case DuplicateActionDedup:
+		if err := o.authorize(ctx, req.Caller, "Write", "duplicate_scan", bucket.Name, req.Prefix); err != nil {
+			return err
+		}
+		if err := o.authorize(ctx, req.Caller, "Delete", "duplicate_scan", bucket.Name, req.Prefix); err != nil {
+			return err
+		}
+	}
+
+	if err := validateDuplicateScan(bucket, req); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "duplicate_scan", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	scan := *req
+	scan.Caller = Caller{}
+
+	job, err := o.plugin.jobs.Start(ctx, duplicateScanJobType, "", []string{bucket.Name}, req, func(ctx context.Context, job *Job) error {
+		report, err := o.runDuplicateScan(ctx, job, bucket, &scan)
+		if report != nil {
+			job.SetResult(report)
+		}
+		return err
+	})
+	if err != nil {
+		return asS3Error("start duplicate scan", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// validateDuplicateScan defaults and checks the options of a duplicate scan
+func validateDuplicateScan(bucket *Bucket, req *DuplicateScanRequest) *S3Error {
+	switch req.Compare {
+	case "":
+		req.Compare = DuplicateCompareETag
+	case DuplicateCompareETag, DuplicateCompareChecksum:
+	default:
+		return NewInvalidRequestError(fmt.Sprintf("compare must be '%s' or '%s'", DuplicateCompareETag, DuplicateCompareChecksum))
+	}
+
+	switch req.Action {
+	case "":
+		req.Action = DuplicateActionReport
+	case DuplicateActionReport:
+	case DuplicateActionDelete:
+		if err := bucket.requireAppendOnly("StartDuplicateScan"); err != nil {
+			return err
+		}
+	case DuplicateActionDedup:
+		if !bucket.Config.Dedup {
+			return NewInvalidRequestError(fmt.Sprintf("bucket '%s' does not have dedup enabled", bucket.Name))
+		}
+		// Blobs are addressed by the sha256 of their content
+		if req.Compare != DuplicateCompareChecksum {
+			return NewInvalidRequestError("action 'dedup' requires compare 'checksum'")
+		}
+	default:
+		return NewInvalidRequestError(fmt.Sprintf("action must be '%s', '%s' or '%s'", DuplicateActionReport, DuplicateActionDelete, DuplicateActionDedup))
+	}
+
+	if req.MinSize < 0 || req.MaxGroups < 0 {
+		return NewInvalidRequestError("min_size and max_groups must not be negative")
+	}
+	if req.MaxGroups == 0 {
+		req.MaxGroups = defaultDuplicateGroups
+	}
+	return nil
+}
+
+// runDuplicateScan groups the objects under the prefix by size, then by ETag or checksum, and
+// applies the action to every group with more than one object
+func (o *Operations) runDuplicateScan(ctx context.Context, job *Job, bucket *Bucket, req *DuplicateScanRequest) (*DuplicateReport, error) {
+	var blobPrefix string
+	if bucket.Config.Dedup {
+		blobPrefix = bucket.GetFullPath(bucket.Config.DedupPrefix)
+	}
+
+	job.SetStatus(JobRunning, "listing objects")
+	bySize := make(map[int64][]duplicateCandidate)
+	err := o.walkObjects(ctx, bucket, bucket.listPrefix(req.Prefix), func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		pathname := bucket.Config.pathnameOf(key)
+		size := aws.ToInt64(obj.Size)
+
+		// Empty objects, dedup pointers among them, are never worth deduplicating
+		if size == 0 || size < req.MinSize || !strings.HasPrefix(pathname, req.Prefix) ||
+			strings.HasSuffix(pathname, "/") || (blobPrefix != "" && strings.HasPrefix(key, blobPrefix)) {
+			return nil
+		}
+
+		bySize[size] = append(bySize[size], duplicateCandidate{pathname: pathname, key: key, etag: aws.ToString(obj.ETag)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	job.SetStatus(JobRunning, "comparing objects")
+	groups := make(map[duplicateKey][]duplicateCandidate)
+	for size, candidates := range bySize {
+		if len(candidates) < 2 {
+			continue
+		}
+
+		for _, c := range candidates {
+			sum := strings.Trim(c.etag, `"`)
+			if req.Compare == DuplicateCompareChecksum {
+				sum, err = o.contentChecksum(ctx, bucket, c.key)
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					job.AddFailure(c.pathname, err)
+					continue
+				}
+			}
+			job.AddProgress(size)
+
+			id := duplicateKey{size: size, sum: sum}
+			groups[id] = append(groups[id], c)
+		}
+	}
+
+	report := &DuplicateReport{Bucket: bucket.Name, Prefix: req.Prefix, Compare: req.Compare, Action: req.Action}
+	members := make(map[string][]duplicateCandidate)
+	for id, candidates := range groups {
+		if len(candidates) < 2 {
+			continue
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].pathname < candidates[j].pathname })
+
+		group := DuplicateGroup{
+			Checksum:  id.sum,
+			Size:      id.size,
+			Pathnames: make([]string, len(candidates)),
+			Savings:   id.size * int64(len(candidates)-1),
+		}
+		for i, c := range candidates {
+			group.Pathnames[i] = c.pathname
+		}
+		members[group.Pathnames[0]] = candidates
+
+		report.Groups = append(report.Groups, group)
+		report.DuplicateObjects += int64(len(candidates) - 1)
+		report.PotentialSavings += group.Savings
+	}
+
+	// Largest savings first
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].Savings != report.Groups[j].Savings {
+			return report.Groups[i].Savings > report.Groups[j].Savings
+		}
+		return report.Groups[i].Pathnames[0] < report.Groups[j].Pathnames[0]
+	})
+	report.GroupCount = len(report.Groups)
+
+	switch req.Action {
+	case DuplicateActionDelete:
+		job.SetStatus(JobRunning, "deleting duplicates")
+		// The caller was authorized when the job started
+		deleteCtx := context.WithValue(ctx, authorizedKey{}, true)
+		for _, group := range report.Groups {
+			for _, pathname := range group.Pathnames[1:] {
+				if err := o.Delete(deleteCtx, &DeleteRequest{Bucket: bucket.Name, Pathname: pathname}, &DeleteResponse{}); err != nil {
+					if ctx.Err() != nil {
+						return report, ctx.Err()
+					}
+					job.AddFailure(pathname, err)
+					continue
+				}
+				report.Deleted++
+			}
+		}
+	case DuplicateActionDedup:
+		job.SetStatus(JobRunning, "replacing duplicates with pointers")
+		for _, group := range report.Groups {
+			if err := o.dedupGroup(ctx, job, bucket, &group, members[group.Pathnames[0]], report); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if len(report.Groups) > req.MaxGroups {
+		report.Groups = report.Groups[:req.MaxGroups]
+		report.Truncated = true
+	}
+
+	o.plugin.metrics.RecordOperation(bucket.Name, "duplicate_scan", "success")
+	return report, nil
+}
+
+// contentChecksum returns the hex sha256 of the content of key
+func (o *Operations) contentChecksum(ctx context.Context, bucket *Bucket, key string) (string, error) {
+	if err := bucket.Acquire(ctx); err != nil {
+		return "", err
+	}
+	defer bucket.Release()
+
+	result, err := bucket.Reader().GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer result.Body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, result.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// dedupGroup stores the content of a group once as a dedup blob and replaces every object of the
+// group with a pointer to it. Objects changed since they were listed are left alone.
+func (o *Operations) dedupGroup(ctx context.Context, job *Job, bucket *Bucket, group *DuplicateGroup, candidates []duplicateCandidate, report *DuplicateReport) error {
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	blobKey := bucket.GetFullPath(dedupBlobPath(bucket.Config.DedupPrefix, group.Checksum))
+	_, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(blobKey),
+	})
+	if exists, err := headExists(bucket, err); err != nil {
+		job.AddFailure(group.Pathnames[0], err)
+		return ctx.Err()
+	} else if !exists {
+		source := buildCopySource(bucket.Config.Bucket, candidates[0].key, bucket.ServerConfig.CopySourceEncoding)
+		if _, err := bucket.Client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(bucket.Config.Bucket),
+			Key:               aws.String(blobKey),
+			CopySource:        aws.String(source),
+			CopySourceIfMatch: aws.String(candidates[0].etag),
+		}); err != nil {
+			job.AddFailure(group.Pathnames[0], err)
+			return ctx.Err()
+		}
+	}
+
+	for _, c := range candidates {
+		pathname := c.pathname
+		head, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:  aws.String(bucket.Config.Bucket),
+			Key:     aws.String(c.key),
+			IfMatch: aws.String(c.etag),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			job.AddFailure(pathname, err)
+			continue
+		}
+
+		metadata := make(map[string]string, len(head.Metadata)+2)
+		for k, v := range head.Metadata {
+			metadata[k] = v
+		}
+		metadata[dedupHashMetadata] = group.Checksum
+		metadata[dedupSizeMetadata] = strconv.FormatInt(group.Size, 10)
+
+		// IfMatch keeps a write racing the scan from being replaced by the listed content
+		if _, err := bucket.Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucket.Config.Bucket),
+			Key:         aws.String(c.key),
+			Body:        bytes.NewReader(nil),
+			ACL:         bucket.ObjectACL(""),
+			ContentType: head.ContentType,
+			Metadata:    metadata,
+			IfMatch:     aws.String(c.etag),
+		}); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			loggerFor(ctx, o.log).Warn("failed to replace duplicate with dedup pointer",
+				zap.String("bucket", bucket.Name),
+				zap.String("pathname", pathname),
+				zap.Error(err),
+			)
+			job.AddFailure(pathname, err)
+			continue
+		}
+
+		o.plugin.index.update(bucket, pathname)
+		report.Replaced++
+	}
+
+	return nil
+}
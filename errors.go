@@ -1,5 +1,16 @@
 package s3
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
 // ErrorCode represents structured error codes for S3 operations
 type ErrorCode string
 
@@ -30,8 +41,88 @@ const (
 
 	// ErrOperationTimeout indicates operation exceeded timeout
 	ErrOperationTimeout ErrorCode = "OPERATION_TIMEOUT"
+
+	// ErrCancelled indicates the operation was aborted before completion, e.g. on shutdown
+	ErrCancelled ErrorCode = "CANCELLED"
+
+	// ErrInvalidRequest indicates malformed or inconsistent request parameters
+	ErrInvalidRequest ErrorCode = "INVALID_REQUEST"
+
+	// ErrSessionNotFound indicates the requested session doesn't exist or has expired
+	ErrSessionNotFound ErrorCode = "SESSION_NOT_FOUND"
+
+	// ErrObjectChanged indicates the object was modified since the operation started
+	ErrObjectChanged ErrorCode = "OBJECT_CHANGED"
+
+	// ErrJobNotFound indicates the requested async job doesn't exist
+	ErrJobNotFound ErrorCode = "JOB_NOT_FOUND"
+
+	// ErrUploadNotFound indicates the requested multipart upload doesn't exist
+	ErrUploadNotFound ErrorCode = "UPLOAD_NOT_FOUND"
+
+	// ErrShuttingDown indicates the plugin is stopping; the operation can be retried on another instance
+	ErrShuttingDown ErrorCode = "SHUTTING_DOWN"
+
+	// ErrNotSupported indicates the bucket's server does not implement a required S3 feature
+	ErrNotSupported ErrorCode = "NOT_SUPPORTED"
+
+	// ErrChecksumMismatch indicates content does not match the expected checksum
+	ErrChecksumMismatch ErrorCode = "CHECKSUM_MISMATCH"
+
+	// ErrQuotaExceeded indicates a write would exceed the bucket quota
+	ErrQuotaExceeded ErrorCode = "QUOTA_EXCEEDED"
+
+	// ErrRetentionActive indicates a delete or move of an object protected by the bucket's retention rules
+	ErrRetentionActive ErrorCode = "RETENTION_ACTIVE"
+
+	// ErrFetchFailed indicates a remote URL could not be downloaded
+	ErrFetchFailed ErrorCode = "FETCH_FAILED"
+
+	// ErrQueueTimeout indicates an operation waited max_queue_wait for a concurrency slot; it can be retried
+	ErrQueueTimeout ErrorCode = "QUEUE_TIMEOUT"
+
+	// ErrInternal is reported in error envelopes for errors without a structured code
+	ErrInternal ErrorCode = "INTERNAL_ERROR"
+)
+
+const (
+	// ErrorFormatText returns RPC errors as "CODE: message (details)" strings (default)
+	ErrorFormatText = "text"
+
+	// ErrorFormatJSON returns RPC errors as a JSON ErrorEnvelope
+	ErrorFormatJSON = "json"
+
+	// errorEnvelopeVersion is bumped on incompatible changes to ErrorEnvelope
+	errorEnvelopeVersion = 1
 )
 
+// errorHTTPStatus maps error codes to the closest HTTP status for clients mapping errors to responses
+var errorHTTPStatus = map[ErrorCode]int{
+	ErrBucketNotFound:      http.StatusNotFound,
+	ErrFileNotFound:        http.StatusNotFound,
+	ErrInvalidConfig:       http.StatusBadRequest,
+	ErrS3Operation:         http.StatusBadGateway,
+	ErrPermissionDenied:    http.StatusForbidden,
+	ErrInvalidPathname:     http.StatusBadRequest,
+	ErrBucketAlreadyExists: http.StatusConflict,
+	ErrInvalidVisibility:   http.StatusBadRequest,
+	ErrOperationTimeout:    http.StatusGatewayTimeout,
+	ErrCancelled:           http.StatusServiceUnavailable,
+	ErrInvalidRequest:      http.StatusBadRequest,
+	ErrSessionNotFound:     http.StatusNotFound,
+	ErrObjectChanged:       http.StatusPreconditionFailed,
+	ErrJobNotFound:         http.StatusNotFound,
+	ErrUploadNotFound:      http.StatusNotFound,
+	ErrShuttingDown:        http.StatusServiceUnavailable,
+	ErrNotSupported:        http.StatusNotImplemented,
+	ErrChecksumMismatch:    http.StatusUnprocessableEntity,
+	ErrQuotaExceeded:       http.StatusInsufficientStorage,
+	ErrRetentionActive:     http.StatusLocked,
+	ErrFetchFailed:         http.StatusBadGateway,
+	ErrQueueTimeout:        http.StatusServiceUnavailable,
+	ErrInternal:            http.StatusInternalServerError,
+}
+
 // S3Error represents a structured error returned to PHP
 type S3Error struct {
 	// Code is the error code
@@ -42,6 +133,12 @@ type S3Error struct {
 
 	// Details contains additional error context (optional)
 	Details string `json:"details,omitempty"`
+
+	// RequestID is the S3 request ID of a failed S3 call, for support cases (optional)
+	RequestID string `json:"request_id,omitempty"`
+
+	// upstreamStatus is the HTTP status S3 answered a failed call with (0 when unknown)
+	upstreamStatus int
 }
 
 // Error implements the error interface
@@ -88,13 +185,56 @@ func NewInvalidConfigError(reason string) *S3Error {
 	)
 }
 
-// NewS3OperationError creates an S3 operation error
+// NewS3OperationError creates an S3 operation error, keeping the S3 request ID and status of err
 func NewS3OperationError(operation string, err error) *S3Error {
-	return NewS3Error(
+	e := NewS3Error(
 		ErrS3Operation,
 		"S3 operation failed: "+operation,
 		err.Error(),
 	)
+	if isRetrySuppressed(err) {
+		e.Details = "retry suppressed, bucket retry budget exhausted: " + e.Details
+	}
+
+	// Requests aborted by their context did not fail on the S3 side
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Code = ErrOperationTimeout
+	case errors.Is(err, context.Canceled):
+		e.Code = ErrCancelled
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		e.RequestID = respErr.ServiceRequestID()
+		e.upstreamStatus = respErr.HTTPStatusCode()
+	}
+
+	return e
+}
+
+// NewS3AccessDeniedError creates a permission denied error for a request S3 refused with 403,
+// keeping the S3 request ID of err
+func NewS3AccessDeniedError(operation string, err error) *S3Error {
+	e := NewS3Error(
+		ErrPermissionDenied,
+		"S3 denied access: "+operation,
+		err.Error(),
+	)
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		e.RequestID = respErr.ServiceRequestID()
+		e.upstreamStatus = respErr.HTTPStatusCode()
+	}
+
+	return e
+}
+
+// isForbidden reports whether S3 refused a request with 403
+func isForbidden(err error) bool {
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusForbidden
 }
 
 // NewPermissionDeniedError creates a permission denied error
@@ -114,3 +254,177 @@ func NewInvalidPathnameError(pathname string, reason string) *S3Error {
 		"pathname: "+pathname+", reason: "+reason,
 	)
 }
+
+// NewInvalidRequestError creates an invalid request error
+func NewInvalidRequestError(reason string) *S3Error {
+	return NewS3Error(
+		ErrInvalidRequest,
+		"Invalid request",
+		reason,
+	)
+}
+
+// NewSessionNotFoundError creates a session not found error
+func NewSessionNotFoundError(sessionID string) *S3Error {
+	return NewS3Error(
+		ErrSessionNotFound,
+		"Session not found",
+		"session: "+sessionID,
+	)
+}
+
+// NewObjectChangedError creates an object changed error
+func NewObjectChangedError(pathname string) *S3Error {
+	return NewS3Error(
+		ErrObjectChanged,
+		"Object was modified",
+		"pathname: "+pathname,
+	)
+}
+
+// NewJobNotFoundError creates a job not found error
+func NewJobNotFoundError(jobID string) *S3Error {
+	return NewS3Error(
+		ErrJobNotFound,
+		"Job not found",
+		"job: "+jobID,
+	)
+}
+
+// NewUploadNotFoundError creates an upload not found error
+func NewUploadNotFoundError(uploadID string) *S3Error {
+	return NewS3Error(
+		ErrUploadNotFound,
+		"Multipart upload not found",
+		"upload: "+uploadID,
+	)
+}
+
+// NewNotSupportedError creates an error for features the bucket's server does not implement
+func NewNotSupportedError(feature, bucket string) *S3Error {
+	return NewS3Error(
+		ErrNotSupported,
+		feature+" is not supported by the server",
+		"bucket: "+bucket,
+	)
+}
+
+// NewChecksumMismatchError creates an error for content not matching the expected checksum
+func NewChecksumMismatchError(pathname string) *S3Error {
+	return NewS3Error(
+		ErrChecksumMismatch,
+		"Checksum mismatch",
+		"pathname: "+pathname,
+	)
+}
+
+// NewQuotaExceededError creates an error for writes that would exceed the bucket quota
+func NewQuotaExceededError(bucket string, used, quota int64) *S3Error {
+	return NewS3Error(
+		ErrQuotaExceeded,
+		"Bucket quota exceeded",
+		fmt.Sprintf("bucket: %s, used: %d, quota: %d", bucket, used, quota),
+	)
+}
+
+// NewRetentionActiveError creates an error for deletes of an object protected by retention rules
+func NewRetentionActiveError(pathname, reason string) *S3Error {
+	return NewS3Error(
+		ErrRetentionActive,
+		"Object is under retention",
+		fmt.Sprintf("pathname: %s, %s", pathname, reason),
+	)
+}
+
+// NewFetchFailedError creates an error for a remote URL that could not be downloaded, keeping
+// the status the remote server answered with (0 when it did not answer)
+func NewFetchFailedError(host string, status int, reason string) *S3Error {
+	e := NewS3Error(
+		ErrFetchFailed,
+		"Failed to fetch URL",
+		fmt.Sprintf("host: %s, %s", host, reason),
+	)
+	e.upstreamStatus = status
+	return e
+}
+
+// NewQueueTimeoutError creates a retryable error for operations that waited max_queue_wait for a slot
+func NewQueueTimeoutError(bucket string, wait time.Duration) *S3Error {
+	return NewS3Error(
+		ErrQueueTimeout,
+		fmt.Sprintf("No concurrency slot for bucket '%s' within %s", bucket, wait),
+		"retry later or raise max_concurrent_operations",
+	)
+}
+
+// NewShuttingDownError creates a retryable error for operations rejected during shutdown
+func NewShuttingDownError() *S3Error {
+	return NewS3Error(
+		ErrShuttingDown,
+		"Plugin is shutting down",
+		"retry on another instance",
+	)
+}
+
+// ErrorEnvelope is the stable JSON form of RPC errors with error_format "json",
+// letting clients hydrate typed exceptions without parsing messages
+type ErrorEnvelope struct {
+	Version    int       `json:"version"`
+	Code       ErrorCode `json:"code"`
+	Message    string    `json:"message"`
+	Details    string    `json:"details,omitempty"`
+	Retryable  bool      `json:"retryable"`
+	HTTPStatus int       `json:"http_status"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// newErrorEnvelope describes err; errors without a structured code are reported as INTERNAL_ERROR
+func newErrorEnvelope(err error) *ErrorEnvelope {
+	var s3Err *S3Error
+	if !errors.As(err, &s3Err) {
+		s3Err = NewS3Error(ErrInternal, err.Error(), "")
+	}
+
+	env := &ErrorEnvelope{
+		Version:    errorEnvelopeVersion,
+		Code:       s3Err.Code,
+		Message:    s3Err.Message,
+		Details:    s3Err.Details,
+		HTTPStatus: errorHTTPStatus[s3Err.Code],
+		RequestID:  s3Err.RequestID,
+	}
+
+	switch s3Err.Code {
+	case ErrShuttingDown, ErrOperationTimeout, ErrCancelled, ErrQueueTimeout:
+		env.Retryable = true
+	case ErrS3Operation, ErrFetchFailed:
+		// Throttling, server errors and failures without a response (network) are worth retrying
+		status := s3Err.upstreamStatus
+		env.Retryable = status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+		if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+			env.HTTPStatus = status
+		}
+	}
+
+	return env
+}
+
+// jsonError carries an ErrorEnvelope as the RPC error message
+type jsonError struct {
+	envelope *ErrorEnvelope
+	cause    error
+}
+
+// Error implements the error interface with the JSON-encoded envelope
+func (e *jsonError) Error() string {
+	data, err := json.Marshal(e.envelope)
+	if err != nil {
+		return e.cause.Error()
+	}
+	return string(data)
+}
+
+// Unwrap returns the original error
+func (e *jsonError) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,188 @@
+package s3
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultExistsFilterRefresh is how long a built filter answers Exists before it is rebuilt
+	defaultExistsFilterRefresh = time.Hour
+
+	// minExistsFilterRefresh keeps rebuilds, which list the whole bucket, from running back to back
+	minExistsFilterRefresh = time.Minute
+
+	// defaultExistsFilterFalsePositiveRate is the share of missing keys still checked with HeadObject
+	defaultExistsFilterFalsePositiveRate = 0.01
+)
+
+// ExistsFilterConfig answers Exists for missing objects from a bloom filter of all keys, built by
+// listing the bucket. Only hits are confirmed with HeadObject. Objects written by the plugin are added
+// immediately; objects created by others (presigned uploads, other clients) are only known after the
+// next rebuild, so the filter suits buckets written through the plugin.
+type ExistsFilterConfig struct {
+	// ExpectedObjects sizes the filter; the false positive rate rises when the bucket holds more
+	ExpectedObjects int64 `mapstructure:"expected_objects" json:"expected_objects"`
+
+	// FalsePositiveRate is the targeted share of missing keys checked with HeadObject (default: 0.01)
+	FalsePositiveRate float64 `mapstructure:"false_positive_rate" json:"false_positive_rate"`
+
+	// Refresh is how often the filter is rebuilt from a listing (default: 1h, min: 1m)
+	Refresh time.Duration `mapstructure:"refresh" json:"refresh"`
+}
+
+// Validate validates the exists filter configuration and applies defaults
+func (fc *ExistsFilterConfig) Validate() error {
+	if fc.ExpectedObjects <= 0 {
+		return fmt.Errorf("exists_filter.expected_objects must be positive")
+	}
+
+	if fc.FalsePositiveRate == 0 {
+		fc.FalsePositiveRate = defaultExistsFilterFalsePositiveRate
+	}
+	if fc.FalsePositiveRate <= 0 || fc.FalsePositiveRate >= 1 {
+		return fmt.Errorf("exists_filter.false_positive_rate must be between 0 and 1, got %g", fc.FalsePositiveRate)
+	}
+
+	if fc.Refresh == 0 {
+		fc.Refresh = defaultExistsFilterRefresh
+	}
+	if fc.Refresh < minExistsFilterRefresh {
+		return fmt.Errorf("exists_filter.refresh must be at least %s", minExistsFilterRefresh)
+	}
+
+	return nil
+}
+
+// bloomFilter is a fixed-size bloom filter over S3 keys
+type bloomFilter struct {
+	bits   []uint64
+	hashes uint64
+}
+
+// newBloomFilter sizes a filter for n keys at false positive rate p
+func newBloomFilter(n int64, p float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), hashes: k}
+}
+
+// positions calls fn with the bit positions of key, derived from one 64-bit hash by double hashing
+func (b *bloomFilter) positions(key string, fn func(word uint64, mask uint64)) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.hashes; i++ {
+		pos := (h1 + i*h2) % m
+		fn(pos/64, 1<<(pos%64))
+	}
+}
+
+func (b *bloomFilter) add(key string) {
+	b.positions(key, func(word, mask uint64) { b.bits[word] |= mask })
+}
+
+func (b *bloomFilter) mayContain(key string) bool {
+	found := true
+	b.positions(key, func(word, mask uint64) {
+		if b.bits[word]&mask == 0 {
+			found = false
+		}
+	})
+	return found
+}
+
+// existsFilter holds the bloom filter of a bucket and the one being rebuilt
+type existsFilter struct {
+	mu       sync.Mutex
+	current  *bloomFilter
+	building *bloomFilter
+	builtAt  time.Time
+}
+
+// add records a key written by the plugin, in the rebuilding filter too so the rebuild cannot miss it
+func (f *existsFilter) add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.current != nil {
+		f.current.add(key)
+	}
+	if f.building != nil {
+		f.building.add(key)
+	}
+}
+
+// excludes reports whether key is certainly not in the bucket. A stale filter triggers a rebuild in
+// the background and keeps answering until the rebuild completes.
+func (o *Operations) excludes(bucket *Bucket, key string) bool {
+	fc := bucket.Config.ExistsFilter
+	if fc == nil {
+		return false
+	}
+
+	f := &bucket.filter
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.building == nil && (f.builtAt.IsZero() || time.Since(f.builtAt) >= fc.Refresh) {
+		f.building = newBloomFilter(fc.ExpectedObjects, fc.FalsePositiveRate)
+		go o.rebuildExistsFilter(bucket, f.building)
+	}
+
+	return f.current != nil && !f.current.mayContain(key)
+}
+
+// rebuildExistsFilter adds every key of the bucket to next and makes it the current filter
+func (o *Operations) rebuildExistsFilter(bucket *Bucket, next *bloomFilter) {
+	f := &bucket.filter
+	start := time.Now()
+
+	var keys int64
+	err := o.walkObjects(o.plugin.ctx, bucket, bucket.GetFullPath(""), func(obj types.Object) error {
+		f.mu.Lock()
+		next.add(aws.ToString(obj.Key))
+		f.mu.Unlock()
+		keys++
+		return nil
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.building = nil
+	// A failed rebuild is retried after the refresh interval; the previous filter stays in use
+	f.builtAt = start
+	if err != nil {
+		o.log.Warn("failed to build exists filter",
+			zap.String("bucket", bucket.Name),
+			zap.Error(err),
+		)
+		return
+	}
+	f.current = next
+
+	if keys > bucket.Config.ExistsFilter.ExpectedObjects {
+		o.log.Warn("bucket holds more objects than exists_filter.expected_objects, raise it to keep the false positive rate",
+			zap.String("bucket", bucket.Name),
+			zap.Int64("objects", keys),
+			zap.Int64("expected_objects", bucket.Config.ExistsFilter.ExpectedObjects),
+		)
+	}
+
+	o.log.Debug("exists filter built",
+		zap.String("bucket", bucket.Name),
+		zap.Int64("objects", keys),
+		zap.Duration("duration", time.Since(start)),
+	)
+}
@@ -0,0 +1,208 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultExpirationTag is the object tag holding the expiration period in days
+	defaultExpirationTag = "rr-expire-days"
+
+	// expirationRulePrefix marks lifecycle rules managed by the plugin, followed by "<bucket name>/<days>d"
+	expirationRulePrefix = "roadrunner-expire-"
+)
+
+// defaultExpirationDays are the expiration periods available when none are configured
+var defaultExpirationDays = []int32{1, 7, 30}
+
+// ExpirationConfig enables per-object TTLs on Write. Objects are tagged with an expiration period,
+// and the plugin installs one lifecycle rule per period at registration that deletes tagged objects.
+type ExpirationConfig struct {
+	// Tag is the object tag key carrying the period in days (default: "rr-expire-days")
+	Tag string `mapstructure:"tag" json:"tag"`
+
+	// Days are the supported periods; a TTL is rounded up to the next one (default: [1, 7, 30])
+	Days []int32 `mapstructure:"days" json:"days"`
+}
+
+// Validate validates the expiration configuration and applies defaults
+func (ec *ExpirationConfig) Validate() error {
+	if ec.Tag == "" {
+		ec.Tag = defaultExpirationTag
+	}
+	if len(ec.Days) == 0 {
+		ec.Days = append([]int32(nil), defaultExpirationDays...)
+	}
+
+	sort.Slice(ec.Days, func(i, j int) bool { return ec.Days[i] < ec.Days[j] })
+	for i, days := range ec.Days {
+		if days <= 0 {
+			return fmt.Errorf("expiration.days must be positive, got %d", days)
+		}
+		if i > 0 && days == ec.Days[i-1] {
+			return fmt.Errorf("expiration.days contains %d twice", days)
+		}
+	}
+
+	return nil
+}
+
+// period returns the smallest configured period in days covering a TTL of ttl
+func (ec *ExpirationConfig) period(ttl time.Duration) (int32, bool) {
+	needed := int32(math.Ceil(ttl.Hours() / 24))
+	for _, days := range ec.Days {
+		if days >= needed {
+			return days, true
+		}
+	}
+	return 0, false
+}
+
+// objectExpiry is the resolved expiration of a single write
+type objectExpiry struct {
+	at   time.Time
+	days int32
+	tag  string
+}
+
+// apply sets the Expires header and the expiration tag on a put request
+func (e *objectExpiry) apply(input *s3.PutObjectInput) {
+	input.Expires = aws.Time(e.at)
+	input.Tagging = aws.String(url.Values{e.tag: {strconv.Itoa(int(e.days))}}.Encode())
+}
+
+// resolveExpiry validates the expires_in/expires_at options of a write; nil means the object never expires
+func resolveExpiry(bucket *Bucket, req *WriteRequest) (*objectExpiry, *S3Error) {
+	if req.ExpiresIn == "" && req.ExpiresAt == 0 {
+		return nil, nil
+	}
+	if req.ExpiresIn != "" && req.ExpiresAt != 0 {
+		return nil, NewInvalidRequestError("expires_in and expires_at are mutually exclusive")
+	}
+
+	ec := bucket.Config.Expiration
+	if ec == nil {
+		return nil, NewInvalidRequestError(fmt.Sprintf("bucket '%s' does not have expiration configured", bucket.Name))
+	}
+	if !bucket.Capabilities.Tagging {
+		return nil, NewNotSupportedError("object expiration", bucket.Name)
+	}
+
+	now := time.Now()
+	at := time.Unix(req.ExpiresAt, 0)
+	if req.ExpiresIn != "" {
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil {
+			return nil, NewInvalidRequestError("expires_in must be a duration (e.g., \"24h\")")
+		}
+		at = now.Add(ttl)
+	}
+
+	if !at.After(now) {
+		return nil, NewInvalidRequestError("expiration must be in the future")
+	}
+
+	days, ok := ec.period(at.Sub(now))
+	if !ok {
+		return nil, NewInvalidRequestError(fmt.Sprintf("expiration exceeds the longest configured period of %d days", ec.Days[len(ec.Days)-1]))
+	}
+
+	return &objectExpiry{at: at, days: days, tag: ec.Tag}, nil
+}
+
+// expirationDays returns the configured periods, none when expiration is disabled
+func expirationDays(ec *ExpirationConfig) []int32 {
+	if ec == nil {
+		return nil
+	}
+	return ec.Days
+}
+
+// installLifecycleRules replaces the plugin-managed lifecycle rules of a bucket (expiration
+// periods and temporary upload cleanup), keeping rules created by other tools
+func (bm *BucketManager) installLifecycleRules(ctx context.Context, bucket *Bucket) error {
+	ec := bucket.Config.Expiration
+	if ec != nil && !bucket.Capabilities.Tagging {
+		return fmt.Errorf("expiration requires object tagging, which the server does not support")
+	}
+
+	// Rules of other plugin buckets sharing the S3 bucket are kept as well
+	owned := expirationRulePrefix + bucket.Name + "/"
+
+	var rules []types.LifecycleRule
+	current, err := bucket.Client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("failed to read lifecycle configuration: %w", err)
+		}
+	} else {
+		for _, rule := range current.Rules {
+			if !strings.HasPrefix(aws.ToString(rule.ID), owned) {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	prefix := bucket.GetFullPath("")
+	for _, days := range expirationDays(ec) {
+		tag := types.Tag{Key: aws.String(ec.Tag), Value: aws.String(strconv.Itoa(int(days)))}
+		filter := &types.LifecycleRuleFilter{Tag: &tag}
+		if prefix != "" {
+			filter = &types.LifecycleRuleFilter{And: &types.LifecycleRuleAndOperator{
+				Prefix: aws.String(prefix),
+				Tags:   []types.Tag{tag},
+			}}
+		}
+
+		rules = append(rules, types.LifecycleRule{
+			ID:         aws.String(fmt.Sprintf("%s%dd", owned, days)),
+			Status:     types.ExpirationStatusEnabled,
+			Filter:     filter,
+			Expiration: &types.LifecycleExpiration{Days: aws.Int32(days)},
+		})
+	}
+
+	if tc := bucket.Config.Temporary; tc != nil {
+		rules = append(rules, types.LifecycleRule{
+			ID:         aws.String(owned + "tmp"),
+			Status:     types.ExpirationStatusEnabled,
+			Filter:     &types.LifecycleRuleFilter{Prefix: aws.String(prefix + tc.Prefix)},
+			Expiration: &types.LifecycleExpiration{Days: aws.Int32(tc.lifecycleDays())},
+			// Parts of interrupted multipart temporary uploads are not objects, so expire them separately
+			AbortIncompleteMultipartUpload: &types.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int32(1)},
+		})
+	}
+
+	if _, err := bucket.Client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket.Config.Bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+	}); err != nil {
+		return fmt.Errorf("failed to install lifecycle rules: %w", err)
+	}
+
+	bm.log.Debug("lifecycle rules installed",
+		zap.String("name", bucket.Name),
+		zap.String("bucket", bucket.Config.Bucket),
+		zap.Int("periods", len(expirationDays(ec))),
+		zap.Bool("temporary", bucket.Config.Temporary != nil),
+	)
+
+	return nil
+}
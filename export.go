@@ -0,0 +1,423 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// exportJobType identifies export bundle jobs
+	exportJobType = "export"
+
+	// ExportFormatObjects copies every object below <dest_prefix>objects/
+	ExportFormatObjects = "objects"
+
+	// ExportFormatZip packs every object into <dest_prefix>export.zip
+	ExportFormatZip = "zip"
+
+	// exportManifestName and exportSignatureName are written below dest_prefix in both formats
+	exportManifestName  = "manifest.json"
+	exportSignatureName = "manifest.json.sig"
+
+	// exportObjectsDir holds the exported objects, in the prefix or inside the zip
+	exportObjectsDir = "objects/"
+
+	// defaultExportMaxObjects bounds the objects of one export
+	defaultExportMaxObjects = 100000
+)
+
+// ExportConfig enables StartExport, bundling selected objects with a signed manifest for legal
+// and discovery requests
+type ExportConfig struct {
+	// SigningKey is the source of the HMAC-SHA256 key signing manifests (env, file or kms)
+	SigningKey *EncryptionKeyConfig `mapstructure:"signing_key"`
+
+	// MaxObjects bounds the objects of one export (default: 100000)
+	MaxObjects int `mapstructure:"max_objects"`
+}
+
+// Validate validates the export configuration and applies defaults
+func (ec *ExportConfig) Validate(servers map[string]*ServerConfig) error {
+	if ec.SigningKey == nil {
+		return fmt.Errorf("signing_key is required")
+	}
+	if err := ec.SigningKey.Validate(servers); err != nil {
+		return fmt.Errorf("invalid signing_key: %w", err)
+	}
+
+	if ec.MaxObjects == 0 {
+		ec.MaxObjects = defaultExportMaxObjects
+	}
+	if ec.MaxObjects < 0 {
+		return fmt.Errorf("max_objects must not be negative")
+	}
+	return nil
+}
+
+// exportManifest is the signed record of an export; the signature covers its exact bytes
+type exportManifest struct {
+	Version   int              `json:"version"`
+	ExportID  string           `json:"export_id"`
+	Reference string           `json:"reference,omitempty"`
+	Bucket    string           `json:"bucket"`
+	Prefix    string           `json:"prefix,omitempty"`
+	Format    string           `json:"format"`
+	Archive   string           `json:"archive,omitempty"` // Pathname of the zip in the destination bucket
+	CreatedAt string           `json:"created_at"`        // RFC 3339, UTC
+	Algorithm string           `json:"signature_algorithm"`
+	Objects   []exportedObject `json:"objects"`
+	Missing   []string         `json:"missing,omitempty"` // Requested pathnames that did not exist
+}
+
+// exportedObject is a manifest entry
+type exportedObject struct {
+	Pathname     string            `json:"pathname"`
+	Exported     string            `json:"exported"` // Pathname in the destination bucket or entry name in the zip
+	Size         int64             `json:"size"`
+	SHA256       string            `json:"sha256"`
+	ETag         string            `json:"etag,omitempty"`
+	ContentType  string            `json:"content_type,omitempty"`
+	LastModified string            `json:"last_modified"` // RFC 3339, UTC
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// errExportSourceMissing marks an object deleted between selection and export
+var errExportSourceMissing = errors.New("object does not exist")
+
+// StartExport validates an export request and launches it as an async job; GetJob returns an
+// *ExportResult as its result once the job completed
+func (o *Operations) StartExport(ctx context.Context, req *ExportRequest, resp *StartJobResponse) error {
+	ec := o.plugin.config.Exports
+	if ec == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "export", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("exports are not enabled, configure exports")
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "export", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	if serr := o.validateExportRequest(bucket, req, ec); serr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "export", "error")
+		o.plugin.metrics.RecordError(req.Bucket, serr.Code)
+		return serr
+	}
+
+	// Check access to the exported objects and the destination
+	sources := req.Pathnames
+	if len(sources) == 0 {
+		sources = []string{req.Prefix}
+	}
+	if err := o.authorize(ctx, req.Caller, "StartExport", "export", bucket.Name, sources...); err != nil {
+		return err
+	}
+	if err := o.authorizeIn(ctx, req.Caller, "StartExport", "export", req.DestBucket, req.DestPrefix); err != nil {
+		return err
+	}
+
+	export := *req
+	export.Caller = Caller{}
+
+	job, err := o.plugin.jobs.Start(ctx, exportJobType, "", []string{bucket.Name}, req, func(ctx context.Context, job *Job) error {
+		result, err := o.runExport(ctx, job, bucket, &export)
+		if err != nil {
+			return err
+		}
+		job.SetResult(result)
+		return nil
+	})
+	if err != nil {
+		return asS3Error("start export", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// validateExportRequest checks the selection and destination of an export and applies defaults
+func (o *Operations) validateExportRequest(bucket *Bucket, req *ExportRequest, ec *ExportConfig) *S3Error {
+	if len(req.Pathnames) > 0 && (req.Prefix != "" || req.Suffix != "") {
+		return NewInvalidRequestError("pathnames cannot be combined with prefix or suffix")
+	}
+	if len(req.Pathnames) > ec.MaxObjects {
+		return NewInvalidRequestError(fmt.Sprintf("pathnames must not contain more than %d entries", ec.MaxObjects))
+	}
+	seen := make(map[string]bool, len(req.Pathnames))
+	for i := range req.Pathnames {
+		if err := o.validatePathname(&req.Pathnames[i]); err != nil {
+			return err.(*S3Error)
+		}
+		if seen[req.Pathnames[i]] {
+			return NewInvalidRequestError(fmt.Sprintf("pathname '%s' appears more than once", req.Pathnames[i]))
+		}
+		seen[req.Pathnames[i]] = true
+	}
+	if strings.Contains(req.Prefix, "..") {
+		return NewInvalidRequestError("prefix cannot contain '..'")
+	}
+
+	switch req.Format {
+	case "":
+		req.Format = ExportFormatObjects
+	case ExportFormatObjects, ExportFormatZip:
+	default:
+		return NewInvalidRequestError(fmt.Sprintf("format must be '%s' or '%s'", ExportFormatObjects, ExportFormatZip))
+	}
+
+	if req.DestBucket == "" {
+		req.DestBucket = bucket.Name
+	}
+	dest, err := o.plugin.buckets.GetBucket(req.DestBucket)
+	if err != nil {
+		return NewBucketNotFoundError(req.DestBucket)
+	}
+
+	if req.DestPrefix == "" || !strings.HasSuffix(req.DestPrefix, "/") || strings.Contains(req.DestPrefix, "..") {
+		return NewInvalidRequestError("dest_prefix must be set, end with '/' and not contain '..'")
+	}
+	if dest == bucket && strings.HasPrefix(req.DestPrefix, req.Prefix) && len(req.Pathnames) == 0 {
+		return NewInvalidRequestError("dest_prefix cannot be inside the exported prefix of the same bucket")
+	}
+
+	if err := dest.requireAppendOnly("StartExport"); err != nil {
+		return err
+	}
+	if err := dest.checkBlobPrefix(req.DestPrefix); err != nil {
+		return err
+	}
+
+	// Zips are streamed with an unknown size, which requires multipart uploads
+	if req.Format == ExportFormatZip && !dest.Capabilities.Multipart {
+		return NewNotSupportedError("multipart upload", req.DestBucket)
+	}
+	return nil
+}
+
+// runExport exports the selected objects and writes the signed manifest last, so a manifest only
+// exists for complete exports
+func (o *Operations) runExport(ctx context.Context, job *Job, bucket *Bucket, req *ExportRequest) (*ExportResult, error) {
+	dest, err := o.plugin.buckets.GetBucket(req.DestBucket)
+	if err != nil {
+		return nil, NewBucketNotFoundError(req.DestBucket)
+	}
+
+	job.SetStatus(JobRunning, "selecting objects")
+	pathnames, err := o.exportSelection(ctx, bucket, req)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &exportManifest{
+		Version:   1,
+		ExportID:  job.ID,
+		Reference: req.Reference,
+		Bucket:    bucket.Name,
+		Prefix:    req.Prefix,
+		Format:    req.Format,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Algorithm: "HMAC-SHA256",
+		Objects:   make([]exportedObject, 0, len(pathnames)),
+	}
+
+	job.SetStatus(JobRunning, "exporting objects")
+	if req.Format == ExportFormatZip {
+		manifest.Archive = req.DestPrefix + "export.zip"
+		err = o.uploadStream(ctx, dest, manifest.Archive, "application/zip", func(w io.Writer) error {
+			aw := newArchiveWriter(ArchiveFormatZip, w)
+			for _, pathname := range pathnames {
+				err := o.exportObject(ctx, job, bucket, nil, pathname, manifest, func(entry *exportedObject, body io.Reader) error {
+					entry.Exported = exportObjectsDir + pathname
+					return aw.add(entry.Exported, entry.Size, time.Now(), body)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return aw.Close()
+		})
+	} else {
+		for _, pathname := range pathnames {
+			err = o.exportObject(ctx, job, bucket, dest, pathname, manifest, func(entry *exportedObject, body io.Reader) error {
+				entry.Exported = req.DestPrefix + exportObjectsDir + pathname
+				return o.putExportObject(ctx, dest, entry.Exported, entry.ContentType, body)
+			})
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, o.plugin.exportKey)
+	mac.Write(data)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	result := &ExportResult{
+		Manifest:  req.DestPrefix + exportManifestName,
+		Signature: req.DestPrefix + exportSignatureName,
+		Archive:   manifest.Archive,
+		Objects:   len(manifest.Objects),
+		Missing:   manifest.Missing,
+	}
+	if err := dest.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer dest.Release()
+	if err := o.putExportObject(ctx, dest, result.Manifest, "application/json", bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := o.putExportObject(ctx, dest, result.Signature, "text/plain", strings.NewReader(signature)); err != nil {
+		return nil, fmt.Errorf("failed to write manifest signature: %w", err)
+	}
+
+	o.plugin.metrics.RecordOperation(bucket.Name, "export", "success")
+	loggerFor(ctx, o.log).Info("export completed",
+		zap.String("job", job.ID),
+		zap.String("bucket", bucket.Name),
+		zap.String("reference", req.Reference),
+		zap.Int("objects", result.Objects),
+		zap.Int("missing", len(result.Missing)),
+	)
+	return result, nil
+}
+
+// exportSelection returns the requested pathnames, or those under prefix ending with suffix
+func (o *Operations) exportSelection(ctx context.Context, bucket *Bucket, req *ExportRequest) ([]string, error) {
+	if len(req.Pathnames) > 0 {
+		return req.Pathnames, nil
+	}
+
+	var blobPrefix string
+	if bucket.Config.Dedup {
+		blobPrefix = bucket.GetFullPath(bucket.Config.DedupPrefix)
+	}
+
+	limit := o.plugin.config.Exports.MaxObjects
+	var pathnames []string
+	err := o.walkObjects(ctx, bucket, bucket.listPrefix(req.Prefix), func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		pathname := bucket.Config.pathnameOf(key)
+		if !strings.HasPrefix(pathname, req.Prefix) || !strings.HasSuffix(pathname, req.Suffix) ||
+			strings.HasSuffix(pathname, "/") || (blobPrefix != "" && strings.HasPrefix(key, blobPrefix)) {
+			return nil
+		}
+
+		if len(pathnames) == limit {
+			return fmt.Errorf("more than %d objects selected (exports.max_objects)", limit)
+		}
+		pathnames = append(pathnames, pathname)
+		return nil
+	})
+	return pathnames, err
+}
+
+// exportObject reads an object, passes its content to store while hashing it and records it in the
+// manifest. Objects that no longer exist are recorded as missing; other failures stop the export,
+// an incomplete bundle must not look complete. The slot of dest, when given, is held alongside the
+// source slot for store to upload into it.
+func (o *Operations) exportObject(ctx context.Context, job *Job, bucket, dest *Bucket, pathname string, manifest *exportManifest, store func(entry *exportedObject, body io.Reader) error) error {
+	buckets := []*Bucket{bucket}
+	if dest != nil {
+		buckets = append(buckets, dest)
+	}
+	release, err := acquireBuckets(ctx, buckets...)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	head, err := bucket.Reader().HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.ObjectKey(pathname)),
+	})
+	if exists, err := headExists(bucket, err); err != nil {
+		return fmt.Errorf("failed to read '%s': %w", pathname, err)
+	} else if !exists {
+		manifest.Missing = append(manifest.Missing, pathname)
+		return nil
+	}
+
+	// Pointers of dedup buckets are exported with the content of their blob
+	key := bucket.ObjectKey(pathname)
+	if sum, _ := dedupPointer(head.Metadata); sum != "" && bucket.Config.Dedup {
+		key = bucket.GetFullPath(dedupBlobPath(bucket.Config.DedupPrefix, sum))
+	}
+
+	result, err := bucket.Reader().GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if exists, err := headExists(bucket, err); err != nil {
+		return fmt.Errorf("failed to read '%s': %w", pathname, err)
+	} else if !exists {
+		manifest.Missing = append(manifest.Missing, pathname)
+		return nil
+	}
+	defer result.Body.Close()
+
+	entry := exportedObject{
+		Pathname:     pathname,
+		Size:         aws.ToInt64(result.ContentLength),
+		ETag:         aws.ToString(head.ETag),
+		ContentType:  contentTypeOf(head.ContentType),
+		LastModified: aws.ToTime(head.LastModified).UTC().Format(time.RFC3339),
+		Metadata:     bucket.Config.decodeMetadata(head.Metadata),
+	}
+
+	hash := sha256.New()
+	if err := store(&entry, io.TeeReader(result.Body, hash)); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to export '%s': %w", pathname, err)
+	}
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	manifest.Objects = append(manifest.Objects, entry)
+	job.AddProgress(entry.Size)
+	job.SetCheckpoint(pathname)
+	return nil
+}
+
+// putExportObject uploads body to pathname of the destination bucket; the caller holds its slot
+func (o *Operations) putExportObject(ctx context.Context, dest *Bucket, pathname, contentType string, body io.Reader) error {
+	key := dest.ObjectKey(pathname)
+	_, err := dest.NewUploader(-1).Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(dest.Config.Bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ACL:         dest.ObjectACL(""),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		o.abortInterruptedUpload(ctx, dest, key, err)
+		return err
+	}
+	dest.filter.add(key)
+	o.plugin.index.update(dest, pathname)
+	return nil
+}
@@ -0,0 +1,226 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap/zapcore"
+)
+
+// ExternalSource describes an object outside the configured buckets, read once by CopyExternal:
+// either a presigned URL, or an S3 bucket and key with inline credentials
+type ExternalSource struct {
+	// URL is a presigned GET URL; exclusive with the S3 fields below
+	URL string `json:"url,omitempty"`
+
+	// Endpoint of an S3-compatible service; empty for AWS S3, which requires Region
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	Key      string `json:"key,omitempty"`
+
+	// Credentials of the source; omitted for public objects
+	AccessKey    string `json:"access_key,omitempty"`
+	Secret       string `json:"secret,omitempty"`
+	SessionToken string `json:"session_token,omitempty"`
+}
+
+// MarshalLogObject redacts the credentials and presigned URL signature when the source is logged
+func (s *ExternalSource) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if s.URL != "" {
+		if u, err := url.Parse(s.URL); err == nil {
+			enc.AddString("url", u.Scheme+"://"+u.Host+u.Path)
+		}
+		return nil
+	}
+	enc.AddString("endpoint", s.Endpoint)
+	enc.AddString("region", s.Region)
+	enc.AddString("bucket", s.Bucket)
+	enc.AddString("key", s.Key)
+	if s.AccessKey != "" {
+		enc.AddString("access_key", redactKey(s.AccessKey))
+		enc.AddString("secret", redactedValue)
+	}
+	return nil
+}
+
+// host returns the host the source is read from, checked against url_fetch.allowed_hosts
+func (s *ExternalSource) host() (*url.URL, error) {
+	raw := s.URL
+	if raw == "" {
+		raw = s.Endpoint
+	}
+	if raw == "" {
+		// Path-style AWS endpoint; virtual-hosted requests go to subdomains of it
+		raw = "https://s3." + s.Region + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, errors.New("source url and endpoint must be absolute http(s) URLs")
+	}
+	return u, nil
+}
+
+// CopyExternal streams an object from another S3 account or provider into a bucket without
+// registering the source as a server, e.g. for one-off migrations. Sources are subject to the
+// url_fetch host allowlist and size limit, like WriteFromURL.
+func (o *Operations) CopyExternal(ctx context.Context, req *CopyExternalRequest, resp *WriteResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "copy_external", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	if err := o.route(&req.Bucket, req.Pathname, "copy_external"); err != nil {
+		return err
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "copy_external", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "CopyExternal", "copy_external", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := req.Transfer.validate(); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "copy_external", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	fc := o.plugin.config.URLFetch
+	source, serr := validateCopyExternal(bucket, req, fc)
+	if serr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "copy_external", "error")
+		o.plugin.metrics.RecordError(req.Bucket, serr.Code)
+		return serr
+	}
+
+	// Metadata travels in HTTP headers, stored in the bucket's metadata_encoding
+	metadata, err := bucket.Config.encodeMetadata(req.Config)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "copy_external", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fc.Timeout)
+	defer cancel()
+
+	obj := &externalObject{host: source.Host, metadata: metadata}
+	if req.Source.URL != "" {
+		httpResp, serr := o.fetchURL(ctx, source)
+		if serr != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "copy_external", "error")
+			o.plugin.metrics.RecordError(req.Bucket, serr.Code)
+			return serr
+		}
+		defer httpResp.Body.Close()
+
+		obj.body = httpResp.Body
+		obj.size = httpResp.ContentLength
+		obj.contentType = httpResp.Header.Get("Content-Type")
+	} else {
+		result, err := o.externalClient(&req.Source).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(req.Source.Bucket),
+			Key:    aws.String(req.Source.Key),
+		})
+		if err != nil {
+			status := 0
+			var respErr *awshttp.ResponseError
+			if errors.As(err, &respErr) {
+				status = respErr.HTTPStatusCode()
+			}
+			o.plugin.metrics.RecordOperation(req.Bucket, "copy_external", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrFetchFailed)
+			return NewFetchFailedError(source.Host, status, err.Error())
+		}
+		defer result.Body.Close()
+
+		obj.body = result.Body
+		obj.size = aws.ToInt64(result.ContentLength)
+		obj.contentType = aws.ToString(result.ContentType)
+		// Without explicit metadata the source's is kept, as migrations do
+		if len(req.Config) == 0 {
+			obj.metadata = result.Metadata
+		}
+	}
+	if req.ContentType != "" {
+		obj.contentType = req.ContentType
+	}
+
+	return o.storeExternal(ctx, "copy_external", bucket, req.Pathname, req.Visibility, req.Transfer, obj, resp)
+}
+
+// validateCopyExternal checks the configuration, bucket and source of a CopyExternal and returns
+// the URL of the source host
+func validateCopyExternal(bucket *Bucket, req *CopyExternalRequest, fc *URLFetchConfig) (*url.URL, *S3Error) {
+	if err := checkExternalTarget(bucket, req.Pathname, "CopyExternal", fc); err != nil {
+		return nil, err
+	}
+
+	src := &req.Source
+	switch {
+	case src.URL != "" && (src.Bucket != "" || src.Key != "" || src.Endpoint != ""):
+		return nil, NewInvalidRequestError("source requires either url or bucket and key, not both")
+	case src.URL == "" && (src.Bucket == "" || src.Key == ""):
+		return nil, NewInvalidRequestError("source requires either url or bucket and key")
+	case src.URL == "" && src.Endpoint == "" && src.Region == "":
+		return nil, NewInvalidRequestError("source region is required for AWS S3 sources")
+	case (src.AccessKey == "") != (src.Secret == ""):
+		return nil, NewInvalidRequestError("source access_key and secret must be set together")
+	}
+
+	source, err := src.host()
+	if err != nil {
+		return nil, NewInvalidRequestError(err.Error())
+	}
+	if err := fc.checkURL(source); err != nil {
+		return nil, NewInvalidRequestError("source " + err.Error())
+	}
+
+	return source, nil
+}
+
+// externalClient creates a client for one CopyExternal. It uses the url_fetch HTTP client, so
+// connections to private addresses are refused like for WriteFromURL.
+func (o *Operations) externalClient(src *ExternalSource) *s3.Client {
+	region := src.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	var provider aws.CredentialsProvider = aws.AnonymousCredentials{}
+	if src.AccessKey != "" {
+		provider = credentials.NewStaticCredentialsProvider(src.AccessKey, src.Secret, src.SessionToken)
+	}
+
+	return s3.New(s3.Options{
+		Region:      region,
+		Credentials: provider,
+		HTTPClient:  o.plugin.fetchClient,
+	}, func(opts *s3.Options) {
+		if src.Endpoint != "" {
+			opts.BaseEndpoint = aws.String(src.Endpoint)
+			opts.UsePathStyle = true // Required for MinIO and some S3-compatible services
+		}
+	})
+}
@@ -0,0 +1,422 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultFetchMaxSize bounds the content of one WriteFromURL
+	defaultFetchMaxSize = 5 * 1024 * 1024 * 1024
+
+	// defaultFetchTimeout bounds one WriteFromURL, download and upload together
+	defaultFetchTimeout = 10 * time.Minute
+
+	// maxFetchRedirects bounds the redirects followed by WriteFromURL
+	maxFetchRedirects = 5
+)
+
+// errFetchTooLarge stops an upload whose remote content exceeds the size limit
+var errFetchTooLarge = errors.New("remote content exceeds the size limit")
+
+// URLFetchConfig enables WriteFromURL, which downloads remote content in Go and streams it into a bucket
+type URLFetchConfig struct {
+	// AllowedHosts lists the hosts content may be fetched from; "*.example.com" matches subdomains, the only wildcard form (required)
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+
+	// MaxSize bounds the fetched content in bytes (default: 5GB)
+	MaxSize int64 `mapstructure:"max_size"`
+
+	// Timeout bounds a whole WriteFromURL (default: 10m)
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// AllowPrivateNetworks permits hosts resolving to loopback, private, link-local and carrier-grade NAT addresses (default: false)
+	AllowPrivateNetworks bool `mapstructure:"allow_private_networks"`
+}
+
+// Validate validates the URL fetch configuration and sets defaults
+func (fc *URLFetchConfig) Validate() error {
+	if len(fc.AllowedHosts) == 0 {
+		return fmt.Errorf("allowed_hosts is required")
+	}
+	for i, host := range fc.AllowedHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" || host == "*" || strings.ContainsAny(host, "/:") {
+			return fmt.Errorf("invalid allowed_hosts entry '%s', expected a host name", fc.AllowedHosts[i])
+		}
+		// "*example.com" would also match "evilexample.com"
+		if strings.Contains(host, "*") && (!strings.HasPrefix(host, "*.") || strings.Count(host, "*") > 1 || len(host) < 3) {
+			return fmt.Errorf("invalid allowed_hosts entry '%s', wildcards must start with '*.'", fc.AllowedHosts[i])
+		}
+		fc.AllowedHosts[i] = host
+	}
+
+	if fc.MaxSize == 0 {
+		fc.MaxSize = defaultFetchMaxSize
+	}
+	if fc.MaxSize < 0 {
+		return fmt.Errorf("max_size must not be negative")
+	}
+
+	if fc.Timeout == 0 {
+		fc.Timeout = defaultFetchTimeout
+	}
+	if fc.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	return nil
+}
+
+// allows reports whether content may be fetched from host
+func (fc *URLFetchConfig) allows(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range fc.AllowedHosts {
+		if domain, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkURL validates a URL to fetch or to follow a redirect to
+func (fc *URLFetchConfig) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url must be http or https")
+	}
+	if u.User != nil {
+		return fmt.Errorf("url must not contain credentials")
+	}
+	if !fc.allows(u.Hostname()) {
+		return fmt.Errorf("host '%s' is not in url_fetch.allowed_hosts", u.Hostname())
+	}
+	return nil
+}
+
+// nonPublicNetworks are IPv4 ranges that are neither private nor loopback for net.IP but still do
+// not reach the public internet: "this network" (0.0.0.0/8) and carrier-grade NAT (100.64.0.0/10)
+var nonPublicNetworks = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+}
+
+// publiclyRoutable reports whether fetches may connect to ip
+func publiclyRoutable(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newFetchClient creates the HTTP client of WriteFromURL. Redirects are checked against the allowed
+// hosts, and connections to private addresses are refused after DNS resolution, so an allowed
+// name pointing inside the network is not followed either.
+func newFetchClient(fc *URLFetchConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if !fc.AllowPrivateNetworks {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !publiclyRoutable(net.ParseIP(host)) {
+				return fmt.Errorf("address %s is not publicly routable", host)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			return fc.checkURL(req.URL)
+		},
+	}
+}
+
+// fetchReader fails once more than limit bytes were read, instead of truncating silently, and
+// keeps the download error so it is not reported as an S3 failure
+type fetchReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+	err   error
+}
+
+func (fr *fetchReader) Read(p []byte) (int, error) {
+	n, err := fr.r.Read(p)
+	fr.read += int64(n)
+	if fr.read > fr.limit {
+		return n, errFetchTooLarge
+	}
+	if err != nil && err != io.EOF {
+		fr.err = err
+	}
+	return n, err
+}
+
+// externalObject is remote content streamed into a bucket by WriteFromURL and CopyExternal
+type externalObject struct {
+	body        io.Reader
+	size        int64             // -1 when the source does not announce it
+	contentType string            // Generic or missing types are detected from the pathname
+	metadata    map[string]string // Already encoded for the bucket
+	host        string            // Reported in errors and logs
+}
+
+// WriteFromURL downloads a remote URL and streams it into a bucket, so imports from URLs never pass
+// through PHP. The content is not buffered: uploads of unknown size go through multipart uploads.
+func (o *Operations) WriteFromURL(ctx context.Context, req *WriteFromURLRequest, resp *WriteResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_from_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	if err := o.route(&req.Bucket, req.Pathname, "write_from_url"); err != nil {
+		return err
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_from_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "WriteFromURL", "write_from_url", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := req.Transfer.validate(); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_from_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	fc := o.plugin.config.URLFetch
+	source, serr := validateWriteFromURL(bucket, req, fc)
+	if serr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_from_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, serr.Code)
+		return serr
+	}
+
+	// Metadata travels in HTTP headers, stored in the bucket's metadata_encoding
+	metadata, err := bucket.Config.encodeMetadata(req.Config)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_from_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fc.Timeout)
+	defer cancel()
+
+	httpResp, serr := o.fetchURL(ctx, source)
+	if serr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_from_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, serr.Code)
+		return serr
+	}
+	defer httpResp.Body.Close()
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = httpResp.Header.Get("Content-Type")
+	}
+
+	return o.storeExternal(ctx, "write_from_url", bucket, req.Pathname, req.Visibility, req.Transfer, &externalObject{
+		body:        httpResp.Body,
+		size:        httpResp.ContentLength,
+		contentType: contentType,
+		metadata:    metadata,
+		host:        source.Host,
+	}, resp)
+}
+
+// validateWriteFromURL checks the configuration, bucket and URL of a WriteFromURL
+func validateWriteFromURL(bucket *Bucket, req *WriteFromURLRequest, fc *URLFetchConfig) (*url.URL, *S3Error) {
+	if err := checkExternalTarget(bucket, req.Pathname, "WriteFromURL", fc); err != nil {
+		return nil, err
+	}
+
+	source, err := url.Parse(req.URL)
+	if err != nil || source.Host == "" {
+		return nil, NewInvalidRequestError("url must be an absolute http(s) URL")
+	}
+	if err := fc.checkURL(source); err != nil {
+		return nil, NewInvalidRequestError(err.Error())
+	}
+
+	return source, nil
+}
+
+// checkExternalTarget checks that remote content can be streamed to pathname
+func checkExternalTarget(bucket *Bucket, pathname, operation string, fc *URLFetchConfig) *S3Error {
+	if fc == nil {
+		return NewInvalidRequestError("url fetching is not enabled, configure url_fetch")
+	}
+
+	// Dedup blobs and audit chains are written from the whole content, which is not held in memory here
+	if bucket.Config.Dedup || bucket.Config.Audit != nil {
+		return NewInvalidRequestError(fmt.Sprintf("%s is not available on dedup or audit bucket '%s'", operation, bucket.Name))
+	}
+	if tc := bucket.Config.Temporary; tc != nil && strings.HasPrefix(pathname, tc.Prefix) {
+		return NewInvalidPathnameError(pathname, "pathname is inside the temporary upload prefix")
+	}
+
+	return nil
+}
+
+// fetchURL sends a GET request to source; the caller closes the body of the returned response
+func (o *Operations) fetchURL(ctx context.Context, source *url.URL) (*http.Response, *S3Error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		return nil, NewInvalidRequestError("invalid url: " + err.Error())
+	}
+	httpResp, err := o.plugin.fetchClient.Do(httpReq)
+	if err != nil {
+		return nil, NewFetchFailedError(source.Host, 0, err.Error())
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		httpResp.Body.Close()
+		return nil, NewFetchFailedError(source.Host, httpResp.StatusCode, "status "+httpResp.Status)
+	}
+
+	return httpResp, nil
+}
+
+// storeExternal streams remote content to pathname. The size is bounded by url_fetch.max_size and
+// the bucket's write policy, also when the source does not announce it.
+func (o *Operations) storeExternal(ctx context.Context, op string, bucket *Bucket, pathname, visibility string, transfer Transfer, obj *externalObject, resp *WriteResponse) error {
+	limit := o.plugin.config.URLFetch.MaxSize
+	if wp := bucket.Config.WritePolicy; wp != nil && wp.MaxSize > 0 && wp.MaxSize < limit {
+		limit = wp.MaxSize
+	}
+	if obj.size > limit {
+		o.plugin.metrics.RecordOperation(bucket.Name, op, "error")
+		o.plugin.metrics.RecordError(bucket.Name, ErrInvalidRequest)
+		return NewS3Error(ErrInvalidRequest, "File is too large", fmt.Sprintf("size: %d, max_size: %d", obj.size, limit))
+	}
+	if err := o.enforcePolicy(ctx, bucket, op, pathname, max(obj.size, 0)); err != nil {
+		return err
+	}
+
+	contentType := obj.contentType
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType == "application/octet-stream" {
+		contentType = o.detectContentType(pathname, nil)
+	}
+
+	// Acquire semaphore
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	key := bucket.ObjectKey(pathname)
+	body := &fetchReader{r: obj.body, limit: limit}
+	putInput := &s3.PutObjectInput{
+		Bucket:       aws.String(bucket.Config.Bucket),
+		Key:          aws.String(key),
+		Body:         body,
+		ACL:          bucket.ObjectACL(visibility),
+		ContentType:  aws.String(contentType),
+		StorageClass: types.StorageClass(transfer.StorageClass),
+		Metadata:     obj.metadata,
+	}
+	if obj.size >= 0 {
+		putInput.ContentLength = aws.Int64(obj.size)
+	}
+
+	// Without a known size the content is streamed in parts of the bucket's part size
+	uploader := bucket.NewUploader(obj.size)
+	transfer.tune(uploader, bucket, obj.size)
+	result, err := uploader.Upload(ctx, putInput)
+	if err != nil {
+		o.abortInterruptedUpload(ctx, bucket, key, err)
+		o.plugin.metrics.RecordOperation(bucket.Name, op, "error")
+		switch {
+		case errors.Is(err, errFetchTooLarge):
+			o.plugin.metrics.RecordError(bucket.Name, ErrInvalidRequest)
+			return NewS3Error(ErrInvalidRequest, "File is too large", fmt.Sprintf("max_size: %d", limit))
+		case body.err != nil && ctx.Err() == nil:
+			o.plugin.metrics.RecordError(bucket.Name, ErrFetchFailed)
+			return NewFetchFailedError(obj.host, 0, "download interrupted: "+body.err.Error())
+		}
+		loggerFor(ctx, o.log).Error("failed to upload remote content",
+			zap.String("bucket", bucket.Name),
+			zap.String("pathname", pathname),
+			zap.String("host", obj.host),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordError(bucket.Name, ErrS3Operation)
+		return NewS3OperationError("upload", err)
+	}
+
+	o.plugin.metrics.RecordUpload(bucket.Name, bucket.UploadMethod(obj.size))
+	bucket.filter.add(key)
+
+	// Derived objects of the previous content are stale now
+	o.invalidateDerived(ctx, bucket, pathname)
+	o.plugin.index.update(bucket, pathname)
+
+	// Overwrites are counted twice until the next quota measurement
+	bucket.usage.add(body.read)
+
+	resp.Success = true
+	resp.Pathname = pathname
+	resp.Size = body.read
+	resp.ETag = aws.ToString(result.ETag)
+	resp.LastModified = time.Now().Unix()
+
+	o.plugin.metrics.RecordOperation(bucket.Name, op, "success")
+	o.log.Debug("stored remote content",
+		zap.String("bucket", bucket.Name),
+		zap.String("pathname", pathname),
+		zap.String("host", obj.host),
+		zap.Int64("size", body.read),
+	)
+
+	return nil
+}
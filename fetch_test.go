@@ -0,0 +1,45 @@
+package s3
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPubliclyRoutable(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"100.63.255.255", true},
+		{"100.128.0.0", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"::ffff:100.64.0.1", false},
+		{"::ffff:0.1.2.3", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := publiclyRoutable(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("publiclyRoutable(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	if publiclyRoutable(nil) {
+		t.Error("publiclyRoutable(nil) = true, want false")
+	}
+}
@@ -0,0 +1,225 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+)
+
+const (
+	// defaultHedgePercentile is the read latency percentile after which a hedge is sent
+	defaultHedgePercentile = 95
+
+	// defaultHedgeMinDelay and defaultHedgeMaxDelay bound the hedge delay
+	defaultHedgeMinDelay = 10 * time.Millisecond
+	defaultHedgeMaxDelay = time.Second
+
+	// hedgeWindow is the number of recent read latencies the delay is computed from
+	hedgeWindow = 256
+
+	// hedgeRecompute is the number of new samples after which the delay is recomputed
+	hedgeRecompute = 32
+)
+
+// hedgeObserver receives the outcome of each hedged request: whether the hedge answered first
+type hedgeObserver func(bucket, api string, won bool)
+
+// HedgingConfig sends a second GetObject or HeadObject when the first has not answered within a
+// percentile of recent read latencies, and uses whichever answers first. Hedges are billed like any
+// request; with the default p95 about one read in twenty is sent twice.
+type HedgingConfig struct {
+	// Percentile of recent read latencies to wait before hedging, 50 to 99.9 (default: 95)
+	Percentile float64 `mapstructure:"percentile" json:"percentile"`
+
+	// MinDelay is the shortest wait before hedging (default: 10ms)
+	MinDelay time.Duration `mapstructure:"min_delay" json:"min_delay"`
+
+	// MaxDelay is the longest wait before hedging, used until enough latencies are known (default: 1s)
+	MaxDelay time.Duration `mapstructure:"max_delay" json:"max_delay"`
+}
+
+// Validate validates the hedging configuration and applies defaults
+func (hc *HedgingConfig) Validate() error {
+	if hc.Percentile == 0 {
+		hc.Percentile = defaultHedgePercentile
+	}
+	if hc.Percentile < 50 || hc.Percentile > 99.9 {
+		return fmt.Errorf("hedging.percentile must be between 50 and 99.9, got %g", hc.Percentile)
+	}
+
+	if hc.MinDelay == 0 {
+		hc.MinDelay = defaultHedgeMinDelay
+	}
+	if hc.MaxDelay == 0 {
+		hc.MaxDelay = defaultHedgeMaxDelay
+	}
+	if hc.MinDelay < 0 || hc.MaxDelay < hc.MinDelay {
+		return fmt.Errorf("hedging.min_delay must be positive and not exceed hedging.max_delay")
+	}
+
+	return nil
+}
+
+// latencyWindow keeps recent read latencies and the hedge delay derived from them
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [hedgeWindow]time.Duration
+	count   int
+	current time.Duration
+	config  *HedgingConfig
+}
+
+// record adds the latency of an answered read
+func (w *latencyWindow) record(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.count%hedgeWindow] = d
+	w.count++
+	if w.count%hedgeRecompute != 0 {
+		return
+	}
+
+	sorted := slices.Clone(w.samples[:min(w.count, hedgeWindow)])
+	slices.Sort(sorted)
+	i := int(math.Ceil(w.config.Percentile/100*float64(len(sorted)))) - 1
+	w.current = min(max(sorted[max(i, 0)], w.config.MinDelay), w.config.MaxDelay)
+}
+
+// delay returns how long to wait before hedging
+func (w *latencyWindow) delay() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.current == 0 {
+		return w.config.MaxDelay
+	}
+	return w.current
+}
+
+// hedgingClient hedges object reads of a bucket; other requests pass through
+type hedgingClient struct {
+	next           aws.HTTPClient
+	bucket         string
+	latency        *latencyWindow
+	observe        hedgeObserver
+	observeRequest requestObserver
+}
+
+// newHedgingClient wraps next; observe and observeRequest may be nil
+func newHedgingClient(next aws.HTTPClient, bucket string, hc *HedgingConfig, observe hedgeObserver, observeRequest requestObserver) *hedgingClient {
+	return &hedgingClient{
+		next:           next,
+		bucket:         bucket,
+		latency:        &latencyWindow{config: hc},
+		observe:        observe,
+		observeRequest: observeRequest,
+	}
+}
+
+// hedgeAttempt is the outcome of one of the requests of a hedged read
+type hedgeAttempt struct {
+	resp  *http.Response
+	err   error
+	hedge bool
+	took  time.Duration
+}
+
+// Do sends the request and, for GetObject and HeadObject, a hedge once the delay passes. The first
+// response wins, failed attempts give way to the one still running, and the loser is cancelled.
+func (c *hedgingClient) Do(req *http.Request) (*http.Response, error) {
+	api := awsmiddleware.GetOperationName(req.Context())
+	if api != "GetObject" && api != "HeadObject" {
+		return c.next.Do(req)
+	}
+
+	// Index 0 is the first request, index 1 the hedge
+	var cancels [2]context.CancelFunc
+	results := make(chan hedgeAttempt, 2)
+	send := func(hedge bool) {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels[boolIndex(hedge)] = cancel
+		start := time.Now()
+		go func() {
+			resp, err := c.next.Do(req.Clone(ctx))
+			results <- hedgeAttempt{resp: resp, err: err, hedge: hedge, took: time.Since(start)}
+		}()
+	}
+
+	send(false)
+	timer := time.NewTimer(c.latency.delay())
+	defer timer.Stop()
+
+	pending, hedged := 1, false
+	for {
+		select {
+		case <-timer.C:
+			send(true)
+			pending, hedged = pending+1, true
+			// Hedges are billed but not seen by the request cost middleware
+			if c.observeRequest != nil {
+				c.observeRequest(c.bucket, api, requestClass(api), 0)
+			}
+
+		case a := <-results:
+			pending--
+			own := cancels[boolIndex(a.hedge)]
+			if a.err != nil {
+				own()
+				if pending > 0 {
+					continue
+				}
+				if hedged && c.observe != nil {
+					c.observe(c.bucket, api, a.hedge)
+				}
+				return nil, a.err
+			}
+
+			if hedged && c.observe != nil {
+				c.observe(c.bucket, api, a.hedge)
+			}
+			c.latency.record(a.took)
+			if pending > 0 {
+				cancels[boolIndex(!a.hedge)]()
+				go discardAttempt(results)
+			}
+			a.resp.Body = &cancelOnClose{ReadCloser: a.resp.Body, cancel: own}
+			return a.resp, nil
+		}
+	}
+}
+
+// discardAttempt releases the response of the cancelled losing request
+func discardAttempt(results <-chan hedgeAttempt) {
+	if a := <-results; a.resp != nil {
+		a.resp.Body.Close()
+	}
+}
+
+// boolIndex maps false to 0 and true to 1
+func boolIndex(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// cancelOnClose keeps the winning request's context alive until its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
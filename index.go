@@ -0,0 +1,580 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// indexStateDir is the sub-directory of state_dir holding index snapshots
+	indexStateDir = "index"
+
+	// defaultIndexQueueSize bounds the writes waiting to be indexed
+	defaultIndexQueueSize = 10000
+
+	// defaultIndexFlushInterval is how often changed indexes are written to state_dir
+	defaultIndexFlushInterval = 5 * time.Second
+
+	// defaultIndexMaxResults bounds the objects returned by one SearchObjects call
+	defaultIndexMaxResults = 1000
+
+	// defaultIndexMaxObjects bounds the objects indexed per bucket
+	defaultIndexMaxObjects = 1000000
+)
+
+// errIndexFull rejects a new entry of a bucket holding max_objects indexed objects
+var errIndexFull = errors.New("object index of the bucket is full, raise index.max_objects")
+
+// IndexConfig enables the object metadata index answering SearchObjects without listing buckets.
+// Every write and delete going through the plugin updates it, jobs included; objects changed by
+// other clients or by S3 Batch Operations jobs are only picked up by a reindex.
+//
+// The index lives in memory and every flush rewrites the whole JSON snapshot of each changed
+// bucket, so memory and flush cost grow with the number of objects; max_objects caps it per bucket.
+type IndexConfig struct {
+	// Buckets lists the indexed buckets; empty indexes all of them
+	Buckets []string `mapstructure:"buckets"`
+
+	// Tags also indexes object tags, one GetObjectTagging per write (default: false)
+	Tags bool `mapstructure:"tags"`
+
+	// QueueSize bounds the writes waiting to be indexed; writes beyond it are not indexed (default: 10000)
+	QueueSize int `mapstructure:"queue_size"`
+
+	// FlushInterval is how often changed indexes are written to state_dir (default: 5s)
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+
+	// MaxResults bounds the objects returned by one SearchObjects call (default: 1000)
+	MaxResults int `mapstructure:"max_results"`
+
+	// MaxObjects bounds the objects indexed per bucket; new objects beyond it are not indexed (default: 1000000)
+	MaxObjects int `mapstructure:"max_objects"`
+
+	// ReindexInterval starts a full reindex of every indexed bucket this often; 0 disables (default: 0)
+	ReindexInterval time.Duration `mapstructure:"reindex_interval"`
+
+	// ReindexWindowStart and ReindexWindowEnd limit scheduled reindexes to a local "HH:MM" window (optional)
+	ReindexWindowStart string `mapstructure:"reindex_window_start"`
+	ReindexWindowEnd   string `mapstructure:"reindex_window_end"`
+}
+
+// Validate validates the index configuration and applies defaults
+func (ic *IndexConfig) Validate() error {
+	if ic.QueueSize == 0 {
+		ic.QueueSize = defaultIndexQueueSize
+	}
+	if ic.QueueSize < 0 {
+		return fmt.Errorf("queue_size must not be negative")
+	}
+
+	if ic.FlushInterval == 0 {
+		ic.FlushInterval = defaultIndexFlushInterval
+	}
+	if ic.FlushInterval < 0 {
+		return fmt.Errorf("flush_interval must not be negative")
+	}
+
+	if ic.MaxResults == 0 {
+		ic.MaxResults = defaultIndexMaxResults
+	}
+	if ic.MaxResults < 0 {
+		return fmt.Errorf("max_results must not be negative")
+	}
+
+	if ic.MaxObjects == 0 {
+		ic.MaxObjects = defaultIndexMaxObjects
+	}
+	if ic.MaxObjects < 0 {
+		return fmt.Errorf("max_objects must not be negative")
+	}
+
+	if ic.ReindexInterval < 0 {
+		return fmt.Errorf("reindex_interval must not be negative")
+	}
+	if (ic.ReindexWindowStart == "") != (ic.ReindexWindowEnd == "") {
+		return fmt.Errorf("reindex_window_start and reindex_window_end must be set together")
+	}
+	if ic.ReindexWindowStart != "" {
+		if _, err := parseClock(ic.ReindexWindowStart); err != nil {
+			return fmt.Errorf("reindex_window_start: %w", err)
+		}
+		if _, err := parseClock(ic.ReindexWindowEnd); err != nil {
+			return fmt.Errorf("reindex_window_end: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// IndexedObject is the indexed state of an object
+type IndexedObject struct {
+	Pathname     string            `json:"pathname"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"content_type"`
+	LastModified int64             `json:"last_modified"`
+	ETag         string            `json:"etag,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"` // User metadata, decoded per metadata_encoding
+	Tags         map[string]string `json:"tags,omitempty"`
+	IndexedAt    int64             `json:"indexed_at"` // Unix timestamp of the HeadObject
+}
+
+// indexUpdate is a written object waiting to be indexed
+type indexUpdate struct {
+	bucket   *Bucket
+	pathname string
+}
+
+// ObjectIndex keeps the indexed objects of every bucket in memory and snapshots them under state_dir
+type ObjectIndex struct {
+	cfg *IndexConfig
+
+	// Map of bucket name to pathname to object
+	objects map[string]map[string]*IndexedObject
+
+	// Buckets changed since their last snapshot
+	dirty map[string]bool
+
+	// Writes waiting for the worker to read their metadata
+	queue chan indexUpdate
+
+	// Directory for snapshots (empty disables persistence)
+	dir string
+
+	// Logger
+	log *zap.Logger
+
+	// Metrics exporter for index updates
+	metrics *metricsExporter
+
+	// Mutex for thread-safe access
+	mu sync.RWMutex
+}
+
+// NewObjectIndex creates the object index, or nil when cfg is nil
+func NewObjectIndex(cfg *IndexConfig, stateDir string, metrics *metricsExporter, log *zap.Logger) *ObjectIndex {
+	if cfg == nil {
+		return nil
+	}
+
+	idx := &ObjectIndex{
+		cfg:     cfg,
+		objects: make(map[string]map[string]*IndexedObject),
+		dirty:   make(map[string]bool),
+		queue:   make(chan indexUpdate, cfg.QueueSize),
+		log:     log,
+		metrics: metrics,
+	}
+	if stateDir != "" {
+		idx.dir = filepath.Join(stateDir, indexStateDir)
+	}
+	return idx
+}
+
+// Load restores the snapshots written by a previous plugin run
+func (idx *ObjectIndex) Load() {
+	if idx == nil || idx.dir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(idx.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			idx.log.Warn("failed to read object index", zap.String("dir", idx.dir), zap.Error(err))
+		}
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var count int
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		name, err := url.PathUnescape(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(idx.dir, entry.Name()))
+		if err != nil {
+			idx.log.Warn("failed to read object index", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		var objects []*IndexedObject
+		if err := json.Unmarshal(data, &objects); err != nil {
+			idx.log.Warn("invalid object index", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		byPathname := make(map[string]*IndexedObject, len(objects))
+		for _, obj := range objects {
+			byPathname[obj.Pathname] = obj
+		}
+		idx.objects[name] = byPathname
+		count += len(objects)
+	}
+
+	if count > 0 {
+		idx.log.Info("restored object index", zap.Int("buckets", len(idx.objects)), zap.Int("objects", count))
+	}
+}
+
+// indexes reports whether writes to bucket are indexed
+func (idx *ObjectIndex) indexes(bucket *Bucket) bool {
+	return idx != nil && (len(idx.cfg.Buckets) == 0 || slices.Contains(idx.cfg.Buckets, bucket.Name))
+}
+
+// update queues a written object to be indexed by the worker, without blocking the write
+func (idx *ObjectIndex) update(bucket *Bucket, pathname string) {
+	if !idx.indexes(bucket) {
+		return
+	}
+
+	select {
+	case idx.queue <- indexUpdate{bucket: bucket, pathname: pathname}:
+	default:
+		idx.metrics.RecordIndexUpdate(bucket.Name, "dropped")
+		idx.log.Warn("object index queue is full, write not indexed",
+			zap.String("bucket", bucket.Name),
+			zap.String("pathname", pathname),
+		)
+	}
+}
+
+// remove drops a deleted object from the index
+func (idx *ObjectIndex) remove(bucket *Bucket, pathname string) {
+	if !idx.indexes(bucket) {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, exists := idx.objects[bucket.Name][pathname]; exists {
+		delete(idx.objects[bucket.Name], pathname)
+		idx.dirty[bucket.Name] = true
+	}
+	idx.metrics.RecordIndexUpdate(bucket.Name, "removed")
+}
+
+// put stores the indexed state of an object; a new object of a bucket holding max_objects entries
+// is rejected with errIndexFull, updates of indexed objects always succeed
+func (idx *ObjectIndex) put(bucket string, obj *IndexedObject) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	objects := idx.objects[bucket]
+	if objects == nil {
+		objects = make(map[string]*IndexedObject)
+		idx.objects[bucket] = objects
+	}
+	if _, exists := objects[obj.Pathname]; !exists && len(objects) >= idx.cfg.MaxObjects {
+		return errIndexFull
+	}
+	objects[obj.Pathname] = obj
+	idx.dirty[bucket] = true
+	return nil
+}
+
+// touch marks the entry of pathname as seen by a reindex without changing it
+func (idx *ObjectIndex) touch(bucket, pathname string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if obj, exists := idx.objects[bucket][pathname]; exists {
+		obj.IndexedAt = time.Now().Unix()
+	}
+}
+
+// prune drops the entries under prefix indexed before the given Unix time, returning their number
+func (idx *ObjectIndex) prune(bucket, prefix string, before int64) int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var removed int
+	for pathname, obj := range idx.objects[bucket] {
+		if strings.HasPrefix(pathname, prefix) && obj.IndexedAt < before {
+			delete(idx.objects[bucket], pathname)
+			removed++
+		}
+	}
+	if removed > 0 {
+		idx.dirty[bucket] = true
+	}
+	return removed
+}
+
+// run indexes queued writes with describe and snapshots changed buckets every flush_interval
+func (idx *ObjectIndex) run(ctx context.Context, wg *sync.WaitGroup, describe func(ctx context.Context, bucket *Bucket, pathname string) (*IndexedObject, error)) {
+	if idx == nil {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(idx.cfg.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				idx.save()
+				return
+			case <-ticker.C:
+				idx.save()
+			case u := <-idx.queue:
+				obj, err := describe(ctx, u.bucket, u.pathname)
+				if err != nil {
+					idx.metrics.RecordIndexUpdate(u.bucket.Name, "failed")
+					idx.log.Warn("failed to index object",
+						zap.String("bucket", u.bucket.Name),
+						zap.String("pathname", u.pathname),
+						zap.Error(err),
+					)
+					continue
+				}
+
+				// Deleted again before it was indexed
+				if obj == nil {
+					idx.remove(u.bucket, u.pathname)
+					continue
+				}
+
+				if err := idx.put(u.bucket.Name, obj); err != nil {
+					idx.metrics.RecordIndexUpdate(u.bucket.Name, "rejected")
+					idx.log.Warn("object not indexed",
+						zap.String("bucket", u.bucket.Name),
+						zap.String("pathname", u.pathname),
+						zap.Int("max_objects", idx.cfg.MaxObjects),
+						zap.Error(err),
+					)
+					continue
+				}
+				idx.metrics.RecordIndexUpdate(u.bucket.Name, "indexed")
+			}
+		}
+	}()
+}
+
+// save writes the snapshot of every bucket changed since its last one
+func (idx *ObjectIndex) save() {
+	if idx == nil || idx.dir == "" {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for name := range idx.dirty {
+		if err := idx.saveLocked(name); err != nil {
+			idx.log.Warn("failed to persist object index", zap.String("bucket", name), zap.Error(err))
+			continue
+		}
+		delete(idx.dirty, name)
+	}
+}
+
+// saveLocked writes the snapshot of a bucket; caller must hold idx.mu
+func (idx *ObjectIndex) saveLocked(name string) error {
+	if err := os.MkdirAll(idx.dir, 0o700); err != nil {
+		return err
+	}
+
+	objects := make([]*IndexedObject, 0, len(idx.objects[name]))
+	for _, obj := range idx.objects[name] {
+		objects = append(objects, obj)
+	}
+
+	data, err := json.Marshal(objects)
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a crash never leaves a truncated file behind
+	file := filepath.Join(idx.dir, url.PathEscape(name)+".json")
+	if err := os.WriteFile(file+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// each calls fn for every indexed object of bucket under prefix, holding the read lock
+func (idx *ObjectIndex) each(bucket, prefix string, fn func(obj *IndexedObject)) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	for pathname, obj := range idx.objects[bucket] {
+		if strings.HasPrefix(pathname, prefix) {
+			fn(obj)
+		}
+	}
+}
+
+// search returns the objects of bucket matching req in pathname order, after req.After
+func (idx *ObjectIndex) search(bucket string, req *SearchObjectsRequest, limit int) ([]IndexedObject, bool) {
+	idx.mu.RLock()
+	matches := make([]IndexedObject, 0)
+	for _, obj := range idx.objects[bucket] {
+		if obj.Pathname > req.After && req.matches(obj) {
+			matches = append(matches, *obj)
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Pathname < matches[j].Pathname })
+
+	if len(matches) > limit {
+		return matches[:limit], true
+	}
+	return matches, false
+}
+
+// matches reports whether obj passes every filter of the request
+func (req *SearchObjectsRequest) matches(obj *IndexedObject) bool {
+	if !strings.HasPrefix(obj.Pathname, req.Prefix) || !strings.HasSuffix(obj.Pathname, req.Suffix) {
+		return false
+	}
+
+	// A content type ending with "/" matches every subtype, e.g. "image/"
+	if req.ContentType != "" && obj.ContentType != req.ContentType &&
+		!(strings.HasSuffix(req.ContentType, "/") && strings.HasPrefix(obj.ContentType, req.ContentType)) {
+		return false
+	}
+
+	if obj.Size < req.MinSize || (req.MaxSize > 0 && obj.Size > req.MaxSize) {
+		return false
+	}
+
+	for k, v := range req.Metadata {
+		if value, ok := obj.Metadata[k]; !ok || value != v {
+			return false
+		}
+	}
+	for k, v := range req.Tags {
+		if value, ok := obj.Tags[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// describeObject reads the indexed state of an object from S3; nil without error means it does not exist
+func (o *Operations) describeObject(ctx context.Context, bucket *Bucket, pathname string) (*IndexedObject, error) {
+	if err := bucket.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer bucket.Release()
+
+	key := bucket.ObjectKey(pathname)
+	result, err := bucket.Reader().HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if exists, err := headExists(bucket, err); !exists {
+		return nil, err
+	}
+
+	obj := &IndexedObject{
+		Pathname:     pathname,
+		Size:         aws.ToInt64(result.ContentLength),
+		ContentType:  contentTypeOf(result.ContentType),
+		LastModified: unixTime(result.LastModified),
+		ETag:         aws.ToString(result.ETag),
+		Metadata:     bucket.Config.decodeMetadata(result.Metadata),
+		IndexedAt:    time.Now().Unix(),
+	}
+	if sum, size := dedupPointer(result.Metadata); sum != "" && bucket.Config.Dedup {
+		obj.Size = size
+	}
+
+	if o.plugin.config.Index.Tags && bucket.Capabilities.Tagging {
+		tagging, err := bucket.Reader().GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(tagging.TagSet) > 0 {
+			obj.Tags = make(map[string]string, len(tagging.TagSet))
+			for _, tag := range tagging.TagSet {
+				obj.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		}
+	}
+
+	return obj, nil
+}
+
+// SearchObjects returns indexed objects of a bucket matching metadata, tag, pathname and size filters
+func (o *Operations) SearchObjects(ctx context.Context, req *SearchObjectsRequest, resp *SearchObjectsResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	idx := o.plugin.index
+	if idx == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "search", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("the object index is not enabled, configure index")
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "search", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	if !idx.indexes(bucket) {
+		o.plugin.metrics.RecordOperation(req.Bucket, "search", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrNotSupported)
+		return NewNotSupportedError("search of a bucket not listed in index.buckets", req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "SearchObjects", "search", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	if req.MinSize < 0 || req.MaxSize < 0 || req.Limit < 0 || (req.MaxSize > 0 && req.MinSize > req.MaxSize) {
+		o.plugin.metrics.RecordOperation(req.Bucket, "search", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("min_size, max_size and limit must not be negative and min_size must not exceed max_size")
+	}
+
+	limit := idx.cfg.MaxResults
+	if req.Limit > 0 && req.Limit < limit {
+		limit = req.Limit
+	}
+
+	resp.Objects, resp.Truncated = idx.search(bucket.Name, req, limit)
+	if resp.Truncated {
+		resp.NextAfter = resp.Objects[len(resp.Objects)-1].Pathname
+	}
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "search", "success")
+	return nil
+}
@@ -0,0 +1,37 @@
+package s3
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestObjectIndexMaxObjects(t *testing.T) {
+	cfg := &IndexConfig{MaxObjects: 2}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	idx := NewObjectIndex(cfg, "", nil, zap.NewNop())
+
+	for _, pathname := range []string{"a", "b"} {
+		if err := idx.put("uploads", &IndexedObject{Pathname: pathname}); err != nil {
+			t.Fatalf("put(%q) error = %v", pathname, err)
+		}
+	}
+
+	if err := idx.put("uploads", &IndexedObject{Pathname: "c"}); !errors.Is(err, errIndexFull) {
+		t.Errorf("put beyond max_objects error = %v, want %v", err, errIndexFull)
+	}
+	if err := idx.put("uploads", &IndexedObject{Pathname: "a", Size: 10}); err != nil {
+		t.Errorf("update of an indexed object error = %v", err)
+	}
+	if err := idx.put("other", &IndexedObject{Pathname: "c"}); err != nil {
+		t.Errorf("put into another bucket error = %v", err)
+	}
+
+	idx.remove(&Bucket{Name: "uploads"}, "b")
+	if err := idx.put("uploads", &IndexedObject{Pathname: "c"}); err != nil {
+		t.Errorf("put after remove error = %v", err)
+	}
+}
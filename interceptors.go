@@ -0,0 +1,192 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// InterceptorLog logs every operation with its duration and outcome
+	InterceptorLog = "log"
+
+	// InterceptorReadOnly rejects every operation that modifies storage or plugin state
+	InterceptorReadOnly = "read_only"
+)
+
+// Call describes a single RPC operation passing through the interceptor chain.
+// Interceptors may inspect or modify Request before calling next and Response after it.
+type Call struct {
+	// Operation is the RPC method name (e.g., "Write", "ListObjects")
+	Operation string
+
+	// Request is the pointer to the RPC request struct (e.g., *WriteRequest)
+	Request any
+
+	// Response is the pointer to the RPC response struct (e.g., *WriteResponse)
+	Response any
+}
+
+// Handler executes a call; interceptors receive the next handler in the chain
+type Handler func(ctx context.Context, call *Call) error
+
+// Interceptor observes or modifies every operation handled by the plugin.
+// Other RoadRunner plugins implementing this interface are collected automatically.
+type Interceptor interface {
+	// Name returns the interceptor name used in logs
+	Name() string
+
+	// Intercept handles the call, usually by calling next; returning without calling next
+	// short-circuits the operation
+	Intercept(ctx context.Context, call *Call, next Handler) error
+}
+
+// InterceptorChain holds interceptors in execution order (first registered runs outermost)
+type InterceptorChain struct {
+	interceptors []Interceptor
+	mu           sync.RWMutex
+}
+
+// Add appends interceptors to the end of the chain
+func (ic *InterceptorChain) Add(interceptors ...Interceptor) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	ic.interceptors = append(ic.interceptors, interceptors...)
+}
+
+// Prepend inserts interceptors at the start of the chain so they run first
+func (ic *InterceptorChain) Prepend(interceptors ...Interceptor) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	ic.interceptors = append(append([]Interceptor(nil), interceptors...), ic.interceptors...)
+}
+
+// Names returns the names of all registered interceptors in execution order
+func (ic *InterceptorChain) Names() []string {
+	ic.mu.RLock()
+	defer ic.mu.RUnlock()
+
+	names := make([]string, 0, len(ic.interceptors))
+	for _, interceptor := range ic.interceptors {
+		names = append(names, interceptor.Name())
+	}
+	return names
+}
+
+// Run passes the call through all interceptors and finally to the operation itself
+func (ic *InterceptorChain) Run(ctx context.Context, call *Call, final Handler) error {
+	ic.mu.RLock()
+	interceptors := ic.interceptors
+	ic.mu.RUnlock()
+
+	handler := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, call *Call) error {
+			return interceptor.Intercept(ctx, call, next)
+		}
+	}
+
+	return handler(ctx, call)
+}
+
+// newBuiltinInterceptor creates a config-declared built-in interceptor by name
+func newBuiltinInterceptor(name string, log *zap.Logger) (Interceptor, error) {
+	switch name {
+	case InterceptorLog:
+		return &logInterceptor{log: log}, nil
+	case InterceptorReadOnly:
+		return &readOnlyInterceptor{}, nil
+	default:
+		return nil, fmt.Errorf("unknown interceptor '%s'", name)
+	}
+}
+
+// logInterceptor logs every operation with its duration and outcome
+type logInterceptor struct {
+	log *zap.Logger
+}
+
+func (li *logInterceptor) Name() string {
+	return InterceptorLog
+}
+
+func (li *logInterceptor) Intercept(ctx context.Context, call *Call, next Handler) error {
+	start := time.Now()
+	err := next(ctx, call)
+
+	fields := []zap.Field{
+		zap.String("operation", call.Operation),
+		zap.Duration("duration", time.Since(start)),
+	}
+	fields = append(fields, CallerMetadataFrom(ctx).fields()...)
+	if err != nil {
+		li.log.Warn("operation failed", append(fields, zap.Error(err))...)
+		return err
+	}
+
+	li.log.Info("operation completed", fields...)
+	return nil
+}
+
+// mutatingOperations lists RPC operations that modify storage or plugin state
+var mutatingOperations = map[string]bool{
+	"RegisterBucket":          true,
+	"RegisterServer":          true,
+	"PurgeRegistrations":      true,
+	"Write":                   true,
+	"WriteFromURL":            true,
+	"CopyExternal":            true,
+	"Delete":                  true,
+	"Copy":                    true,
+	"Move":                    true,
+	"SetVisibility":           true,
+	"GetPresignedPost":        true,
+	"StartMigration":          true,
+	"StartReindex":            true,
+	"StartDedupGC":            true,
+	"StartExport":             true,
+	"StartInventory":          true,
+	"PublishDirectory":        true,
+	"ExtractArchive":          true,
+	"CreateArchive":           true,
+	"InvalidateCDN":           true,
+	"SetBucketNotifications":  true,
+	"SubmitBatchJob":          true,
+	"CancelJob":               true,
+	"StartMultipartUpload":    true,
+	"UploadPart":              true,
+	"CompleteMultipartUpload": true,
+	"AbortMultipartUpload":    true,
+	"AcquireFileLock":         true,
+	"ReleaseFileLock":         true,
+	"WriteTemporary":          true,
+	"Promote":                 true,
+	"WriteTransaction":        true,
+	"StageWrite":              true,
+	"CommitWrite":             true,
+	"AbortWrite":              true,
+	"CreateShortLink":         true,
+	"DeleteShortLink":         true,
+}
+
+// readOnlyInterceptor rejects mutating operations with a permission denied error
+type readOnlyInterceptor struct{}
+
+func (ri *readOnlyInterceptor) Name() string {
+	return InterceptorReadOnly
+}
+
+func (ri *readOnlyInterceptor) Intercept(ctx context.Context, call *Call, next Handler) error {
+	if mutatingOperations[call.Operation] {
+		return NewPermissionDeniedError(call.Operation)
+	}
+	// Duplicate scans only modify storage when asked to remove duplicates
+	if scan, ok := call.Request.(*DuplicateScanRequest); ok && scan.Action != "" && scan.Action != DuplicateActionReport {
+		return NewPermissionDeniedError(call.Operation)
+	}
+	return next(ctx, call)
+}
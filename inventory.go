@@ -0,0 +1,333 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// inventoryJobType identifies inventory jobs
+	inventoryJobType = "inventory"
+
+	// InventoryFormatCSV writes gzipped CSV files in the S3 Inventory layout
+	InventoryFormatCSV = "csv"
+
+	// defaultInventoryID names the inventory configuration in the output layout
+	defaultInventoryID = "rr-inventory"
+
+	// inventoryFileRows bounds the objects listed in one data file
+	inventoryFileRows = 100000
+
+	// inventoryManifestVersion is the S3 Inventory manifest version the output follows
+	inventoryManifestVersion = "2016-11-30"
+)
+
+// inventoryFields are the optional S3 Inventory fields, in the order S3 writes them
+var inventoryFields = []string{"Size", "LastModifiedDate", "ETag", "StorageClass", "IsMultipartUploaded", "ChecksumAlgorithm"}
+
+// defaultInventoryFields are written when a request names no fields
+var defaultInventoryFields = []string{"Size", "LastModifiedDate", "ETag", "StorageClass"}
+
+// inventoryIDPattern matches the configuration IDs S3 accepts
+var inventoryIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// inventoryManifest is the manifest.json of an S3 Inventory report
+type inventoryManifest struct {
+	SourceBucket      string          `json:"sourceBucket"`
+	DestinationBucket string          `json:"destinationBucket"`
+	Version           string          `json:"version"`
+	CreationTimestamp string          `json:"creationTimestamp"`
+	FileFormat        string          `json:"fileFormat"`
+	FileSchema        string          `json:"fileSchema"`
+	Files             []inventoryFile `json:"files"`
+}
+
+// inventoryFile describes one data file in the manifest
+type inventoryFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5Checksum string `json:"MD5checksum"`
+}
+
+// StartInventory launches a job listing the objects under a prefix into files laid out like an
+// S3 Inventory report, so Athena tables and scripts written for S3 Inventory read them unchanged
+func (o *Operations) StartInventory(ctx context.Context, req *InventoryRequest, resp *StartJobResponse) error {
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "inventory", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	if serr := o.validateInventoryRequest(bucket, req); serr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "inventory", "error")
+		o.plugin.metrics.RecordError(req.Bucket, serr.Code)
+		return serr
+	}
+
+	// Check access to the listed prefix and the destination
+	if err := o.authorize(ctx, req.Caller, "StartInventory", "inventory", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+	if err := o.authorizeIn(ctx, req.Caller, "StartInventory", "inventory", req.DestBucket, req.DestPrefix); err != nil {
+		return err
+	}
+
+	inventory := *req
+	inventory.Caller = Caller{}
+
+	job, err := o.plugin.jobs.Start(ctx, inventoryJobType, "", []string{bucket.Name}, req, func(ctx context.Context, job *Job) error {
+		result, err := o.runInventory(ctx, job, bucket, &inventory)
+		if err != nil {
+			return err
+		}
+		job.SetResult(result)
+		return nil
+	})
+	if err != nil {
+		return asS3Error("start inventory", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// validateInventoryRequest checks the format, fields and destination of an inventory and applies defaults
+func (o *Operations) validateInventoryRequest(bucket *Bucket, req *InventoryRequest) *S3Error {
+	if strings.Contains(req.Prefix, "..") {
+		return NewInvalidRequestError("prefix cannot contain '..'")
+	}
+
+	switch strings.ToLower(req.Format) {
+	case "", InventoryFormatCSV:
+		req.Format = InventoryFormatCSV
+	default:
+		return NewInvalidRequestError(fmt.Sprintf("format '%s' is not supported, only '%s'", req.Format, InventoryFormatCSV))
+	}
+
+	if req.ID == "" {
+		req.ID = defaultInventoryID
+	}
+	if !inventoryIDPattern.MatchString(req.ID) {
+		return NewInvalidRequestError("id must be 1-64 letters, digits, '.', '_' or '-'")
+	}
+
+	// Fields are written in the order S3 uses, whatever the order requested
+	if len(req.Fields) == 0 {
+		req.Fields = defaultInventoryFields
+	}
+	for _, requested := range req.Fields {
+		if !containsFold(inventoryFields, requested) {
+			return NewInvalidRequestError(fmt.Sprintf("unknown field '%s', expected one of %s", requested, strings.Join(inventoryFields, ", ")))
+		}
+	}
+	fields := make([]string, 0, len(req.Fields))
+	for _, field := range inventoryFields {
+		if containsFold(req.Fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	req.Fields = fields
+
+	if req.DestBucket == "" {
+		req.DestBucket = bucket.Name
+	}
+	dest, err := o.plugin.buckets.GetBucket(req.DestBucket)
+	if err != nil {
+		return NewBucketNotFoundError(req.DestBucket)
+	}
+
+	if req.DestPrefix == "" || !strings.HasSuffix(req.DestPrefix, "/") || strings.Contains(req.DestPrefix, "..") {
+		return NewInvalidRequestError("dest_prefix must be set, end with '/' and not contain '..'")
+	}
+	if dest == bucket && strings.HasPrefix(req.DestPrefix, req.Prefix) {
+		return NewInvalidRequestError("dest_prefix cannot be inside the listed prefix of the same bucket")
+	}
+
+	if err := dest.checkBlobPrefix(req.DestPrefix); err != nil {
+		return err
+	}
+	return dest.requireAppendOnly("StartInventory")
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// runInventory writes the data files while listing and the manifest last, so a manifest only
+// exists for complete inventories
+func (o *Operations) runInventory(ctx context.Context, job *Job, bucket *Bucket, req *InventoryRequest) (*InventoryResult, error) {
+	dest, err := o.plugin.buckets.GetBucket(req.DestBucket)
+	if err != nil {
+		return nil, NewBucketNotFoundError(req.DestBucket)
+	}
+
+	now := time.Now().UTC()
+	base := req.DestPrefix + bucket.Config.Bucket + "/" + req.ID + "/"
+	manifest := &inventoryManifest{
+		SourceBucket:      bucket.Config.Bucket,
+		DestinationBucket: "arn:aws:s3:::" + dest.Config.Bucket,
+		Version:           inventoryManifestVersion,
+		CreationTimestamp: strconv.FormatInt(now.UnixMilli(), 10),
+		FileFormat:        "CSV",
+		FileSchema:        strings.Join(append([]string{"Bucket", "Key"}, req.Fields...), ", "),
+		Files:             []inventoryFile{},
+	}
+	result := &InventoryResult{
+		Manifest: base + now.Format("2006-01-02T15-04Z") + "/manifest.json",
+		Checksum: base + now.Format("2006-01-02T15-04Z") + "/manifest.checksum",
+	}
+
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	var rows int
+
+	// flush uploads the open data file
+	flush := func() error {
+		if gz == nil {
+			return nil
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		gz = nil
+
+		id, err := newRandomID()
+		if err != nil {
+			return err
+		}
+		pathname := base + "data/" + id + ".csv.gz"
+		sum := md5.Sum(buf.Bytes())
+		file := inventoryFile{
+			Key:         dest.ObjectKey(pathname),
+			Size:        int64(buf.Len()),
+			MD5Checksum: hex.EncodeToString(sum[:]),
+		}
+		if err := o.putExportObject(ctx, dest, pathname, "application/gzip", bytes.NewReader(buf.Bytes())); err != nil {
+			return fmt.Errorf("failed to write inventory file: %w", err)
+		}
+		manifest.Files = append(manifest.Files, file)
+		result.Files = append(result.Files, pathname)
+		return nil
+	}
+
+	job.SetStatus(JobRunning, "listing objects")
+	err = o.walkObjects(ctx, bucket, bucket.listPrefix(req.Prefix), func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		if !strings.HasPrefix(bucket.Config.pathnameOf(key), req.Prefix) {
+			return nil
+		}
+
+		if gz == nil {
+			buf.Reset()
+			gz = gzip.NewWriter(&buf)
+			rows = 0
+		}
+		if _, err := gz.Write(inventoryRow(bucket.Config.Bucket, obj, req.Fields)); err != nil {
+			return err
+		}
+		rows++
+
+		job.AddProgress(aws.ToInt64(obj.Size))
+		job.SetCheckpoint(key)
+		result.Objects++
+		result.Bytes += aws.ToInt64(obj.Size)
+
+		if rows >= inventoryFileRows {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(data)
+	if err := o.putExportObject(ctx, dest, result.Manifest, "application/json", bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := o.putExportObject(ctx, dest, result.Checksum, "text/plain", strings.NewReader(hex.EncodeToString(sum[:]))); err != nil {
+		return nil, fmt.Errorf("failed to write manifest checksum: %w", err)
+	}
+
+	o.plugin.metrics.RecordOperation(bucket.Name, "inventory", "success")
+	loggerFor(ctx, o.log).Info("inventory completed",
+		zap.String("job", job.ID),
+		zap.String("bucket", bucket.Name),
+		zap.String("manifest", result.Manifest),
+		zap.Int64("objects", result.Objects),
+		zap.Int("files", len(result.Files)),
+	)
+	return result, nil
+}
+
+// inventoryRow formats an object as an S3 Inventory CSV line: every value quoted, keys URL-encoded
+func inventoryRow(bucketName string, obj types.Object, fields []string) []byte {
+	values := make([]string, 0, 2+len(fields))
+	values = append(values, bucketName, url.QueryEscape(aws.ToString(obj.Key)))
+
+	etag := strings.Trim(aws.ToString(obj.ETag), `"`)
+	for _, field := range fields {
+		switch field {
+		case "Size":
+			values = append(values, strconv.FormatInt(aws.ToInt64(obj.Size), 10))
+		case "LastModifiedDate":
+			values = append(values, aws.ToTime(obj.LastModified).UTC().Format("2006-01-02T15:04:05.000Z"))
+		case "ETag":
+			values = append(values, etag)
+		case "StorageClass":
+			class := string(obj.StorageClass)
+			if class == "" {
+				class = string(types.ObjectStorageClassStandard)
+			}
+			values = append(values, class)
+		case "IsMultipartUploaded":
+			values = append(values, strconv.FormatBool(strings.Contains(etag, "-")))
+		case "ChecksumAlgorithm":
+			algorithms := make([]string, 0, len(obj.ChecksumAlgorithm))
+			for _, algorithm := range obj.ChecksumAlgorithm {
+				algorithms = append(algorithms, string(algorithm))
+			}
+			values = append(values, strings.Join(algorithms, ","))
+		}
+	}
+
+	var line strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		line.WriteByte('"')
+		line.WriteString(strings.ReplaceAll(value, `"`, `""`))
+		line.WriteByte('"')
+	}
+	line.WriteByte('\n')
+	return []byte(line.String())
+}
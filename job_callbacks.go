@@ -0,0 +1,295 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultJobCallbackTimeout bounds each webhook request
+	defaultJobCallbackTimeout = 10 * time.Second
+
+	// defaultJobCallbackAttempts is how often a webhook is tried before the completion is dropped
+	defaultJobCallbackAttempts = 3
+)
+
+// JobCallbackConfig enables completion webhooks of async jobs. Webhook URLs come from requests,
+// so only those under an allowed prefix are accepted.
+type JobCallbackConfig struct {
+	// AllowedURLs are the URL prefixes webhooks may point to, ending with "/" (required, e.g. "http://app.internal/hooks/")
+	AllowedURLs []string `mapstructure:"allowed_urls"`
+
+	// Headers are sent with every webhook request, e.g. an Authorization token the receiver checks
+	Headers map[string]string `mapstructure:"headers"`
+
+	// Timeout bounds each webhook request (default: 10s)
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Attempts is how often a failed webhook is tried, with exponential backoff from 1s (default: 3)
+	Attempts int `mapstructure:"attempts"`
+}
+
+// Validate validates the job callback configuration and sets defaults
+func (jc *JobCallbackConfig) Validate() error {
+	if len(jc.AllowedURLs) == 0 {
+		return fmt.Errorf("allowed_urls is required")
+	}
+	for _, prefix := range jc.AllowedURLs {
+		u, err := url.Parse(prefix)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid allowed_urls entry '%s', expected an http(s) URL", prefix)
+		}
+		// Without a path, "http://app" would also allow "http://app.example.com"
+		if !strings.HasSuffix(prefix, "/") {
+			return fmt.Errorf("allowed_urls entry '%s' must end with '/'", prefix)
+		}
+	}
+
+	if jc.Timeout == 0 {
+		jc.Timeout = defaultJobCallbackTimeout
+	}
+	if jc.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	if jc.Attempts == 0 {
+		jc.Attempts = defaultJobCallbackAttempts
+	}
+	if jc.Attempts < 0 {
+		return fmt.Errorf("attempts must not be negative")
+	}
+
+	return nil
+}
+
+// allows reports whether a webhook URL lies under one of the allowed URLs. Scheme and host must
+// match exactly and the path must stay under the allowed path; URLs with credentials, dot segments,
+// empty segments or encoded slashes are refused, as servers may resolve them outside the prefix.
+func (jc *JobCallbackConfig) allows(u *url.URL) bool {
+	if u.User != nil || strings.ContainsRune(u.Path, '\\') || strings.Contains(u.Path, "//") {
+		return false
+	}
+	escaped := strings.ToLower(u.EscapedPath())
+	if strings.Contains(escaped, "%2f") || strings.Contains(escaped, "%5c") {
+		return false
+	}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+	cleaned := path.Clean("/"+u.Path) + "/"
+
+	for _, prefix := range jc.AllowedURLs {
+		allowed, err := url.Parse(prefix)
+		if err != nil {
+			continue
+		}
+		if u.Scheme == allowed.Scheme && strings.EqualFold(u.Host, allowed.Host) && strings.HasPrefix(cleaned, allowed.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// JobCallback is where the summary of a finished job is delivered: a webhook URL or a queue pipeline
+type JobCallback struct {
+	URL      string `json:"url,omitempty"`      // Receives the job summary as a JSON POST request
+	Pipeline string `json:"pipeline,omitempty"` // Receives the job summary through a JobPublisher
+}
+
+// Completion is embedded in requests starting async jobs. Callback is notified once the job
+// finished, so callers do not need to poll GetJob.
+type Completion struct {
+	Callback *JobCallback `json:"callback,omitempty"`
+}
+
+func (c Completion) completionCallback() *JobCallback {
+	return c.Callback
+}
+
+// callbackCarrier is implemented by requests embedding Completion
+type callbackCarrier interface {
+	completionCallback() *JobCallback
+}
+
+// jobCallback returns the callback of a request starting a job, if any
+func jobCallback(req any) *JobCallback {
+	if carrier, ok := req.(callbackCarrier); ok {
+		return carrier.completionCallback()
+	}
+	return nil
+}
+
+// JobPublisher is implemented by plugins delivering job completions to a queue (e.g., a bridge to
+// the RoadRunner jobs plugin). Callbacks naming a pipeline are published through it.
+type JobPublisher interface {
+	PublishJobCompletion(ctx context.Context, pipeline string, payload []byte) error
+}
+
+// jobNotifier delivers the summaries of finished jobs to their callbacks
+type jobNotifier struct {
+	config    *JobCallbackConfig // nil disables webhooks
+	publisher JobPublisher       // nil disables pipelines
+	client    *http.Client
+	log       *zap.Logger
+
+	// Deliveries drain with in-flight operations on shutdown
+	ctx context.Context
+	wg  *sync.WaitGroup
+}
+
+// newJobNotifier creates the notifier of job callbacks
+func newJobNotifier(ctx context.Context, wg *sync.WaitGroup, config *JobCallbackConfig, publisher JobPublisher, log *zap.Logger) *jobNotifier {
+	jn := &jobNotifier{
+		config:    config,
+		publisher: publisher,
+		log:       log,
+		ctx:       ctx,
+		wg:        wg,
+	}
+	if config != nil {
+		jn.client = &http.Client{
+			Timeout: config.Timeout,
+			// A redirect would carry the configured headers to a URL outside allowed_urls
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	return jn
+}
+
+// validate checks the callback of a request before its job starts
+func (jn *jobNotifier) validate(req any) error {
+	callback := jobCallback(req)
+	if callback == nil {
+		return nil
+	}
+	if jn == nil {
+		return NewInvalidRequestError("job callbacks are not available before the plugin serves")
+	}
+
+	switch {
+	case (callback.URL == "") == (callback.Pipeline == ""):
+		return NewInvalidRequestError("callback requires either url or pipeline")
+	case callback.Pipeline != "":
+		if jn.publisher == nil {
+			return NewInvalidRequestError("callback pipelines require a plugin implementing JobPublisher")
+		}
+	case jn.config == nil:
+		return NewInvalidRequestError("job callback webhooks are not enabled, configure job_callbacks")
+	default:
+		return jn.checkURL(callback.URL)
+	}
+
+	return nil
+}
+
+// checkURL rejects webhook URLs that are not http(s) URLs under allowed_urls
+func (jn *jobNotifier) checkURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return NewInvalidRequestError("callback url must be an http(s) URL")
+	}
+	if !jn.config.allows(u) {
+		return NewInvalidRequestError("callback url is not under job_callbacks.allowed_urls")
+	}
+	return nil
+}
+
+// notify delivers the summary of a finished job to its callback in the background
+func (jn *jobNotifier) notify(callback *JobCallback, summary JobSummary) {
+	if jn == nil || callback == nil {
+		return
+	}
+
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		jn.log.Warn("failed to encode job callback", zap.String("id", summary.ID), zap.Error(err))
+		return
+	}
+
+	jn.wg.Add(1)
+	go func() {
+		defer jn.wg.Done()
+
+		var err error
+		switch {
+		case callback.Pipeline != "" && jn.publisher != nil:
+			err = jn.publisher.PublishJobCompletion(jn.ctx, callback.Pipeline, payload)
+		case callback.URL != "" && jn.config != nil:
+			// Checkpoints may restore callbacks validated against an older allowed_urls
+			if err = jn.checkURL(callback.URL); err != nil {
+				break
+			}
+			err = jn.post(callback.URL, payload)
+		default:
+			// Restored from a checkpoint written under another configuration
+			err = fmt.Errorf("job callbacks of this kind are not enabled")
+		}
+
+		if err != nil {
+			jn.log.Warn("failed to deliver job callback",
+				zap.String("id", summary.ID),
+				zap.String("type", summary.Type),
+				zap.String("url", callback.URL),
+				zap.String("pipeline", callback.Pipeline),
+				zap.Error(err),
+			)
+		}
+	}()
+}
+
+// post sends the payload to the webhook, retrying failed attempts with exponential backoff
+func (jn *jobNotifier) post(target string, payload []byte) error {
+	backoff := time.Second
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = jn.postOnce(target, payload); err == nil || attempt >= jn.config.Attempts {
+			return err
+		}
+
+		select {
+		case <-jn.ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// postOnce sends one webhook request
+func (jn *jobNotifier) postOnce(target string, payload []byte) error {
+	req, err := http.NewRequestWithContext(jn.ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range jn.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := jn.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
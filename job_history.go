@@ -0,0 +1,184 @@
+package s3
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// jobHistoryFile is the state_dir file holding the summaries of finished jobs
+	jobHistoryFile = "job_history.json"
+
+	// defaultJobHistorySize is how many finished jobs are kept
+	defaultJobHistorySize = 1000
+
+	// maxJobHistoryResults bounds the summaries returned by one GetJobHistory call
+	maxJobHistoryResults = 1000
+)
+
+// JobSummary is the record of a finished job, kept after the job itself is gone
+type JobSummary struct {
+	ID               string          `json:"id"`
+	Type             string          `json:"type"`
+	Status           string          `json:"status"`
+	Message          string          `json:"message,omitempty"`
+	Error            string          `json:"error,omitempty"`
+	Params           json.RawMessage `json:"params,omitempty"`  // Request that started the job, without credentials
+	Buckets          []string        `json:"buckets,omitempty"` // Buckets the job read or wrote, checked on access
+	ObjectsProcessed int64           `json:"objects_processed"`
+	BytesProcessed   int64           `json:"bytes_processed"`
+	ObjectsFailed    int64           `json:"objects_failed"`
+	Failures         []string        `json:"failures,omitempty"`
+	Result           json.RawMessage `json:"result,omitempty"`
+	CreatedAt        int64           `json:"created_at"`
+	FinishedAt       int64           `json:"finished_at"`
+	DurationMS       int64           `json:"duration_ms"`
+}
+
+// info returns the summary as reported by GetJob for jobs no longer tracked
+func (s JobSummary) info() JobInfo {
+	info := JobInfo{
+		ID:               s.ID,
+		Type:             s.Type,
+		Status:           s.Status,
+		Message:          s.Message,
+		Error:            s.Error,
+		ObjectsProcessed: s.ObjectsProcessed,
+		BytesProcessed:   s.BytesProcessed,
+		ObjectsFailed:    s.ObjectsFailed,
+		Failures:         s.Failures,
+		CreatedAt:        s.CreatedAt,
+		FinishedAt:       s.FinishedAt,
+	}
+	if s.Result != nil {
+		info.Result = s.Result
+	}
+	return info
+}
+
+// jobHistory keeps the summaries of the most recent finished jobs, oldest first, and persists them
+// to state_dir so they survive restarts
+type jobHistory struct {
+	entries []JobSummary
+	size    int
+	path    string // Empty keeps the history in memory only
+	log     *zap.Logger
+	mu      sync.RWMutex
+}
+
+// newJobHistory creates a history of up to size summaries
+func newJobHistory(size int, stateDir string, log *zap.Logger) *jobHistory {
+	h := &jobHistory{size: size, log: log}
+	if stateDir != "" {
+		h.path = filepath.Join(stateDir, jobHistoryFile)
+	}
+	return h
+}
+
+// Load restores the history written by a previous plugin run
+func (h *jobHistory) Load() {
+	if h.path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			h.log.Warn("failed to read job history", zap.String("file", h.path), zap.Error(err))
+		}
+		return
+	}
+
+	var entries []JobSummary
+	if err := json.Unmarshal(data, &entries); err != nil {
+		h.log.Warn("invalid job history", zap.String("file", h.path), zap.Error(err))
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = h.trim(entries)
+}
+
+// add records a finished job, dropping the oldest summaries beyond the history size
+func (h *jobHistory) add(summary JobSummary) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = h.trim(append(h.entries, summary))
+	if h.path == "" {
+		return
+	}
+
+	data, err := json.Marshal(h.entries)
+	if err != nil {
+		h.log.Warn("failed to encode job history", zap.Error(err))
+		return
+	}
+	if err := writeFileAtomic(h.path, data); err != nil {
+		h.log.Warn("failed to save job history", zap.String("file", h.path), zap.Error(err))
+	}
+}
+
+// trim drops the oldest entries beyond the history size; the caller holds mu or owns entries
+func (h *jobHistory) trim(entries []JobSummary) []JobSummary {
+	if over := len(entries) - h.size; over > 0 {
+		entries = append([]JobSummary(nil), entries[over:]...)
+	}
+	return entries
+}
+
+// list returns up to limit summaries, newest first, matching the type and status when set
+// and accepted by allowed
+func (h *jobHistory) list(jobType, status string, limit int, allowed func(JobSummary) bool) []JobSummary {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	summaries := make([]JobSummary, 0, min(limit, len(h.entries)))
+	for i := len(h.entries) - 1; i >= 0 && len(summaries) < limit; i-- {
+		entry := h.entries[i]
+		if (jobType == "" || entry.Type == jobType) && (status == "" || entry.Status == status) && allowed(entry) {
+			summaries = append(summaries, entry)
+		}
+	}
+	return summaries
+}
+
+// get returns the latest summary of a job; fixed IDs (scheduled reindexes) have several
+func (h *jobHistory) get(id string) (JobSummary, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].ID == id {
+			return h.entries[i], true
+		}
+	}
+	return JobSummary{}, false
+}
+
+// jobParams encodes the request that started a job for its summary. The caller's token and the
+// request deadline are dropped; they are credentials and transport details, not parameters.
+func jobParams(req any) json.RawMessage {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return data
+	}
+	delete(fields, "token")
+	delete(fields, "deadline_ms")
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,453 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// JobStatus represents the lifecycle state of an asynchronous job
+type JobStatus string
+
+const (
+	// JobRunning indicates the job is actively processing
+	JobRunning JobStatus = "running"
+
+	// JobWaiting indicates the job is idle until its schedule window opens
+	JobWaiting JobStatus = "waiting"
+
+	// JobCompleted indicates the job finished successfully
+	JobCompleted JobStatus = "completed"
+
+	// JobFailed indicates the job stopped because of an error
+	JobFailed JobStatus = "failed"
+
+	// JobCancelled indicates the job was cancelled via RPC
+	JobCancelled JobStatus = "cancelled"
+
+	// JobInterrupted indicates the job was stopped by plugin shutdown and may be resumed
+	JobInterrupted JobStatus = "interrupted"
+)
+
+const (
+	// maxJobFailures bounds the number of failed items remembered per job
+	maxJobFailures = 100
+
+	// finishedJobRetention is how long a finished job stays listed by ListJobs; its summary is kept
+	// in the job history afterwards
+	finishedJobRetention = time.Hour
+
+	// maxFinishedJobs bounds the finished jobs kept listed, dropping the oldest first
+	maxFinishedJobs = 1000
+)
+
+// JobFunc is the body of an asynchronous job; it must return when ctx is cancelled
+type JobFunc func(ctx context.Context, job *Job) error
+
+// JobManager runs and tracks asynchronous background jobs
+type JobManager struct {
+	// Map of job ID to job
+	jobs map[string]*Job
+
+	// Parent context, cancelled on plugin shutdown
+	ctx context.Context
+
+	// WaitGroup shared with the plugin for graceful shutdown
+	wg *sync.WaitGroup
+
+	// Logger
+	log *zap.Logger
+
+	// Summaries of finished jobs (nil keeps none)
+	history *jobHistory
+
+	// Delivery of job completions to request callbacks (nil until the plugin serves)
+	notifier *jobNotifier
+
+	// Set by Close once the plugin is stopping; no job starts afterwards
+	closed bool
+
+	// Mutex for thread-safe access
+	mu sync.RWMutex
+}
+
+// Job represents a single asynchronous job and its progress
+type Job struct {
+	// ID is the unique job identifier
+	ID string
+
+	// Type is the job type (e.g., "migration")
+	Type string
+
+	// Params is the encoded request that started the job, recorded in its summary
+	Params json.RawMessage
+
+	// Callback is notified with the summary once the job finished (nil: none)
+	Callback *JobCallback
+
+	// Buckets the job reads or writes; access to the job is checked against each of them
+	Buckets []string
+
+	// Progress counters, updated atomically by the job body
+	objects atomic.Int64
+	bytes   atomic.Int64
+	failed  atomic.Int64
+
+	// Cancels the job context
+	cancel context.CancelFunc
+
+	// Mutable state guarded by mu
+	status     JobStatus
+	message    string
+	checkpoint string
+	err        string
+	failures   []string
+	result     any
+	createdAt  time.Time
+	finishedAt time.Time
+	cancelled  bool
+	mu         sync.Mutex
+}
+
+// NewJobManager creates a new job manager bound to the plugin lifecycle
+func NewJobManager(ctx context.Context, wg *sync.WaitGroup, log *zap.Logger) *JobManager {
+	return &JobManager{
+		jobs: make(map[string]*Job),
+		ctx:  ctx,
+		wg:   wg,
+		log:  log,
+	}
+}
+
+// Start launches a job in the background; an empty id generates a new one. params is the request
+// that started the job, recorded in the job history. The job outlives ctx, which only passes its
+// caller metadata on to the job context and logs.
+func (jm *JobManager) Start(ctx context.Context, jobType, id string, buckets []string, params any, fn JobFunc) (*Job, error) {
+	if id == "" {
+		var err error
+		id, err = newRandomID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	jm.mu.Lock()
+	if jm.closed {
+		jm.mu.Unlock()
+		return nil, NewShuttingDownError()
+	}
+	if existing, exists := jm.jobs[id]; exists && !existing.finished() {
+		jm.mu.Unlock()
+		return nil, fmt.Errorf("job '%s' is already running", id)
+	}
+	jm.evictLocked(time.Now())
+
+	ctx, cancel := context.WithCancel(withCallerMetadata(jm.ctx, CallerMetadataFrom(ctx)))
+	log := loggerFor(ctx, jm.log)
+	job := &Job{
+		ID:        id,
+		Type:      jobType,
+		Params:    jobParams(params),
+		Callback:  jobCallback(params),
+		Buckets:   buckets,
+		cancel:    cancel,
+		status:    JobRunning,
+		createdAt: time.Now(),
+	}
+	jm.jobs[id] = job
+	// Added under mu so Close orders every started job before the plugin waits for the group
+	jm.wg.Add(1)
+	jm.mu.Unlock()
+
+	go func() {
+		defer jm.wg.Done()
+		defer cancel()
+
+		err := fn(ctx, job)
+		job.finish(err)
+
+		summary := job.Summary()
+		if jm.history != nil {
+			jm.history.add(summary)
+		}
+		jm.notifier.notify(job.Callback, summary)
+
+		log.Info("job finished",
+			zap.String("id", job.ID),
+			zap.String("type", job.Type),
+			zap.String("status", string(job.Status())),
+			zap.Int64("objects", job.objects.Load()),
+			zap.Int64("bytes", job.bytes.Load()),
+			zap.Int64("failed", job.failed.Load()),
+			zap.Error(err),
+		)
+	}()
+
+	log.Debug("job started", zap.String("id", id), zap.String("type", jobType))
+
+	return job, nil
+}
+
+// Close rejects jobs started from now on with a shutting down error; running jobs are left to the
+// plugin context
+func (jm *JobManager) Close() {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	jm.closed = true
+}
+
+// evictLocked drops finished jobs older than finishedJobRetention and the oldest ones beyond
+// maxFinishedJobs; jm.mu must be held
+func (jm *JobManager) evictLocked(now time.Time) {
+	var finished []*Job
+	for id, job := range jm.jobs {
+		finishedAt, ok := job.finishedTime()
+		if !ok {
+			continue
+		}
+		if now.Sub(finishedAt) > finishedJobRetention {
+			delete(jm.jobs, id)
+			continue
+		}
+		finished = append(finished, job)
+	}
+
+	if over := len(finished) - maxFinishedJobs; over > 0 {
+		sort.Slice(finished, func(i, j int) bool {
+			a, _ := finished[i].finishedTime()
+			b, _ := finished[j].finishedTime()
+			return a.Before(b)
+		})
+		for _, job := range finished[:over] {
+			delete(jm.jobs, job.ID)
+		}
+	}
+}
+
+// Get retrieves a job by ID
+func (jm *JobManager) Get(id string) (*Job, bool) {
+	jm.mu.RLock()
+	defer jm.mu.RUnlock()
+
+	job, exists := jm.jobs[id]
+	return job, exists
+}
+
+// List returns all known jobs ordered by creation time
+func (jm *JobManager) List() []*Job {
+	jm.mu.RLock()
+	defer jm.mu.RUnlock()
+
+	jobs := make([]*Job, 0, len(jm.jobs))
+	for _, job := range jm.jobs {
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].createdAt.Before(jobs[j].createdAt)
+	})
+
+	return jobs
+}
+
+// History returns the summaries of finished jobs (nil when not kept)
+func (jm *JobManager) History() *jobHistory {
+	return jm.history
+}
+
+// SetHistory makes finished jobs recorded in history
+func (jm *JobManager) SetHistory(history *jobHistory) {
+	jm.history = history
+}
+
+// SetNotifier makes finished jobs delivered to the callbacks of their requests
+func (jm *JobManager) SetNotifier(notifier *jobNotifier) {
+	jm.notifier = notifier
+}
+
+// Cancel requests cancellation of a running job
+func (jm *JobManager) Cancel(id string) error {
+	job, exists := jm.Get(id)
+	if !exists {
+		return fmt.Errorf("job '%s' not found", id)
+	}
+
+	job.mu.Lock()
+	job.cancelled = true
+	job.mu.Unlock()
+
+	job.cancel()
+	return nil
+}
+
+// SetStatus updates the job status and an optional human-readable message
+func (j *Job) SetStatus(status JobStatus, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = status
+	j.message = message
+}
+
+// SetCheckpoint records the last fully processed position of the job
+func (j *Job) SetCheckpoint(checkpoint string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.checkpoint = checkpoint
+}
+
+// SetResult attaches the outcome of a job, reported by GetJob once it completed
+func (j *Job) SetResult(result any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.result = result
+}
+
+// AddProgress records a processed object and its size
+func (j *Job) AddProgress(bytes int64) {
+	j.objects.Add(1)
+	j.bytes.Add(bytes)
+}
+
+// Progress returns the processed, byte and failure counters
+func (j *Job) Progress() (objects, bytes, failed int64) {
+	return j.objects.Load(), j.bytes.Load(), j.failed.Load()
+}
+
+// Restore seeds the counters and checkpoint of a job resumed from persisted state
+func (j *Job) Restore(objects, bytes, failed int64, checkpoint string) {
+	j.objects.Store(objects)
+	j.bytes.Store(bytes)
+	j.failed.Store(failed)
+	j.SetCheckpoint(checkpoint)
+}
+
+// AddFailure records a failed item, remembering up to maxJobFailures entries
+func (j *Job) AddFailure(item string, err error) {
+	j.failed.Add(1)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.failures) < maxJobFailures {
+		j.failures = append(j.failures, item+": "+err.Error())
+	}
+}
+
+// Status returns the current job status
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// Cancelled reports whether the job was cancelled via RPC (as opposed to plugin shutdown)
+func (j *Job) Cancelled() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.cancelled
+}
+
+// finished reports whether the job reached a terminal state
+func (j *Job) finished() bool {
+	switch j.Status() {
+	case JobCompleted, JobFailed, JobCancelled, JobInterrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// finishedTime returns when the job finished; false while it is running
+func (j *Job) finishedTime() (time.Time, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.finishedAt, !j.finishedAt.IsZero()
+}
+
+// finish moves the job into its terminal state based on the returned error
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finishedAt = time.Now()
+	switch {
+	case err == nil:
+		j.status = JobCompleted
+		j.message = ""
+	case j.cancelled:
+		j.status = JobCancelled
+		j.message = ""
+	case errors.Is(err, context.Canceled):
+		j.status = JobInterrupted
+		j.message = "stopped by plugin shutdown"
+	default:
+		j.status = JobFailed
+		j.err = err.Error()
+	}
+}
+
+// Info returns a snapshot of the job for RPC responses
+func (j *Job) Info() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := JobInfo{
+		ID:               j.ID,
+		Type:             j.Type,
+		Status:           string(j.status),
+		Message:          j.message,
+		Checkpoint:       j.checkpoint,
+		Error:            j.err,
+		ObjectsProcessed: j.objects.Load(),
+		BytesProcessed:   j.bytes.Load(),
+		ObjectsFailed:    j.failed.Load(),
+		Failures:         append([]string(nil), j.failures...),
+		Result:           j.result,
+		CreatedAt:        j.createdAt.Unix(),
+	}
+	if !j.finishedAt.IsZero() {
+		info.FinishedAt = j.finishedAt.Unix()
+	}
+
+	return info
+}
+
+// Summary returns the record of a finished job kept in the job history
+func (j *Job) Summary() JobSummary {
+	info := j.Info()
+
+	summary := JobSummary{
+		ID:               info.ID,
+		Type:             info.Type,
+		Status:           info.Status,
+		Message:          info.Message,
+		Error:            info.Error,
+		Params:           j.Params,
+		Buckets:          j.Buckets,
+		ObjectsProcessed: info.ObjectsProcessed,
+		BytesProcessed:   info.BytesProcessed,
+		ObjectsFailed:    info.ObjectsFailed,
+		Failures:         info.Failures,
+		CreatedAt:        info.CreatedAt,
+		FinishedAt:       info.FinishedAt,
+	}
+
+	j.mu.Lock()
+	summary.DurationMS = j.finishedAt.Sub(j.createdAt).Milliseconds()
+	j.mu.Unlock()
+
+	if info.Result != nil {
+		if data, err := json.Marshal(info.Result); err == nil {
+			summary.Result = data
+		}
+	}
+
+	return summary
+}
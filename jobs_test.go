@@ -0,0 +1,86 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestJobStartAfterStop(t *testing.T) {
+	uploads, err := NewUploadManager("", "", zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Plugin{
+		log:     zap.NewNop(),
+		config:  &Config{ShutdownTimeout: time.Second},
+		buckets: NewBucketManager(zap.NewNop()),
+		uploads: uploads,
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.opsCtx, p.cancelOps = context.WithCancel(context.Background())
+	p.operations = NewOperations(p, p.log)
+	p.jobs = NewJobManager(p.ctx, &p.wg, p.log)
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	ran := false
+	job, err := p.jobs.Start(context.Background(), "test", "", nil, nil, func(context.Context, *Job) error {
+		ran = true
+		return nil
+	})
+	if job != nil {
+		t.Errorf("Start() after Stop returned job %q", job.ID)
+	}
+
+	var s3Err *S3Error
+	if !errors.As(err, &s3Err) || s3Err.Code != ErrShuttingDown {
+		t.Fatalf("Start() after Stop error = %v, want %s", err, ErrShuttingDown)
+	}
+	if got := asS3Error("start test", err); got.Code != ErrShuttingDown {
+		t.Errorf("asS3Error() code = %s, want %s", got.Code, ErrShuttingDown)
+	}
+
+	p.wg.Wait()
+	if ran {
+		t.Error("job body ran after Stop")
+	}
+}
+
+func TestJobCarriesCallerMetadata(t *testing.T) {
+	var wg sync.WaitGroup
+	jm := NewJobManager(context.Background(), &wg, zap.NewNop())
+
+	caller := &CallerMetadata{App: "shop-api", Route: "POST /exports"}
+	reqCtx, cancel := context.WithCancel(withCallerMetadata(context.Background(), caller))
+
+	released := make(chan struct{})
+	var metadata *CallerMetadata
+	var jobErr error
+	if _, err := jm.Start(reqCtx, "test", "", nil, nil, func(ctx context.Context, _ *Job) error {
+		<-released
+		metadata, jobErr = CallerMetadataFrom(ctx), ctx.Err()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The job outlives the request that started it
+	cancel()
+	close(released)
+	wg.Wait()
+
+	if metadata != caller {
+		t.Errorf("job caller metadata = %+v, want %+v", metadata, caller)
+	}
+	if jobErr != nil {
+		t.Errorf("job context error after the request ended = %v", jobErr)
+	}
+}
@@ -0,0 +1,88 @@
+package s3
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// CopySourceEncodingURL percent-encodes the CopySource header as required by AWS S3
+	CopySourceEncodingURL = "url"
+
+	// CopySourceEncodingRaw sends the CopySource header unencoded for providers that expect raw keys
+	CopySourceEncodingRaw = "raw"
+)
+
+// escapeKeyPath percent-encodes every segment of an object key while keeping '/' separators,
+// so keys with spaces, '+', '%', '?', '#' or unicode survive in URLs and headers
+func escapeKeyPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		// PathEscape leaves '+' alone, which S3 would decode as a space
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
+
+// buildCopySource builds the CopySource value for CopyObject/UploadPartCopy; sources reached
+// through an access point are addressed as <arn>/object/<key>
+func buildCopySource(bucket, key, encoding string) string {
+	if _, ok := parseAccessPointARN(bucket); ok {
+		if encoding == CopySourceEncodingRaw {
+			return bucket + "/object/" + key
+		}
+		return bucket + "/object/" + escapeKeyPath(key)
+	}
+	if encoding == CopySourceEncodingRaw {
+		return bucket + "/" + key
+	}
+	return escapeKeyPath(bucket) + "/" + escapeKeyPath(key)
+}
+
+// validateKeyCharacters rejects keys that are not valid UTF-8 or contain control characters,
+// which S3 either rejects or silently mangles
+func validateKeyCharacters(key string) string {
+	if !utf8.ValidString(key) {
+		return "pathname must be valid UTF-8"
+	}
+
+	for _, r := range key {
+		if unicode.IsControl(r) {
+			return "pathname cannot contain control characters"
+		}
+	}
+
+	if len(key) > 1024 {
+		return "pathname cannot exceed 1024 bytes"
+	}
+
+	return ""
+}
+
+// normalizePathname strips leading slashes, removes "./" segments and collapses "//"
+// while keeping a trailing slash; it reports false if the path escapes the bucket root
+func normalizePathname(pathname string) (string, bool) {
+	if pathname == "" {
+		return "", true
+	}
+
+	trailingSlash := strings.HasSuffix(pathname, "/")
+
+	cleaned := path.Clean(strings.TrimLeft(pathname, "/"))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+
+	if cleaned == "." {
+		return "", true
+	}
+
+	if trailingSlash {
+		cleaned += "/"
+	}
+
+	return cleaned, true
+}
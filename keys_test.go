@@ -0,0 +1,96 @@
+package s3
+
+import "testing"
+
+func TestEscapeKeyPath(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{"plain", "images/photo.jpg", "images/photo.jpg"},
+		{"space", "my docs/report 1.pdf", "my%20docs/report%201.pdf"},
+		{"plus", "a+b/c+d.txt", "a%2Bb/c%2Bd.txt"},
+		{"percent", "100%/done%20.txt", "100%25/done%2520.txt"},
+		{"question mark", "what?.txt", "what%3F.txt"},
+		{"hash", "notes/#1.txt", "notes/%231.txt"},
+		{"unicode", "фото/日本.png", "%D1%84%D0%BE%D1%82%D0%BE/%E6%97%A5%E6%9C%AC.png"},
+		{"trailing slash", "dir/", "dir/"},
+		{"empty segments", "a//b", "a//b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeKeyPath(tt.key); got != tt.want {
+				t.Errorf("escapeKeyPath(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildCopySource(t *testing.T) {
+	const accessPoint = "arn:aws:s3:us-east-1:123456789012:accesspoint/uploads"
+
+	tests := []struct {
+		name     string
+		bucket   string
+		key      string
+		encoding string
+		want     string
+	}{
+		{"url plain", "media", "a/b.txt", CopySourceEncodingURL, "media/a/b.txt"},
+		{"url space", "media", "a b/c d.txt", CopySourceEncodingURL, "media/a%20b/c%20d.txt"},
+		{"url plus", "media", "a+b.txt", CopySourceEncodingURL, "media/a%2Bb.txt"},
+		{"url percent", "media", "50%.txt", CopySourceEncodingURL, "media/50%25.txt"},
+		{"url question mark and hash", "media", "q?/#x", CopySourceEncodingURL, "media/q%3F/%23x"},
+		{"url unicode", "media", "ü.txt", CopySourceEncodingURL, "media/%C3%BC.txt"},
+		{"default encoding", "media", "a b.txt", "", "media/a%20b.txt"},
+		{"raw space", "media", "a b/c d.txt", CopySourceEncodingRaw, "media/a b/c d.txt"},
+		{"raw plus and percent", "media", "a+b%.txt", CopySourceEncodingRaw, "media/a+b%.txt"},
+		{"raw question mark and hash", "media", "q?/#x", CopySourceEncodingRaw, "media/q?/#x"},
+		{"raw unicode", "media", "ü.txt", CopySourceEncodingRaw, "media/ü.txt"},
+		{"access point url", accessPoint, "a b+c.txt", CopySourceEncodingURL, accessPoint + "/object/a%20b%2Bc.txt"},
+		{"access point raw", accessPoint, "a b+c.txt", CopySourceEncodingRaw, accessPoint + "/object/a b+c.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildCopySource(tt.bucket, tt.key, tt.encoding); got != tt.want {
+				t.Errorf("buildCopySource(%q, %q, %q) = %q, want %q", tt.bucket, tt.key, tt.encoding, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateKeyCharacters(t *testing.T) {
+	long := make([]byte, 1025)
+	for i := range long {
+		long[i] = 'a'
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{"plain", "images/photo.jpg", ""},
+		{"space", "my docs/report 1.pdf", ""},
+		{"plus and percent", "a+b/100%.txt", ""},
+		{"question mark and hash", "what?/#1.txt", ""},
+		{"unicode", "фото/日本.png", ""},
+		{"max length", string(long[:1024]), ""},
+		{"invalid utf8", "bad\xff.txt", "pathname must be valid UTF-8"},
+		{"newline", "a\nb.txt", "pathname cannot contain control characters"},
+		{"nul", "a\x00b", "pathname cannot contain control characters"},
+		{"del", "a\x7fb", "pathname cannot contain control characters"},
+		{"too long", string(long), "pathname cannot exceed 1024 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateKeyCharacters(tt.key); got != tt.want {
+				t.Errorf("validateKeyCharacters(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package s3
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+)
+
+// ConfigWarning describes a valid but most likely unintended option combination
+type ConfigWarning struct {
+	// Scope is the configuration section the warning refers to, e.g. "bucket 'uploads'"
+	Scope string
+
+	// Message explains the problem and how to resolve it
+	Message string
+}
+
+// prefixesOverlap reports whether one prefix contains the other
+func prefixesOverlap(a, b string) bool {
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// checkCombinations rejects bucket option combinations that cannot work together.
+// It runs after defaults are applied.
+func (bc *BucketConfig) checkCombinations() error {
+	if bc.PartSize < manager.MinUploadPartSize {
+		return fmt.Errorf("part_size must be at least %d bytes (S3 minimum part size), got %d", manager.MinUploadPartSize, bc.PartSize)
+	}
+
+	if bc.MinPartSize < manager.MinUploadPartSize {
+		return fmt.Errorf("min_part_size must be at least %d bytes (S3 minimum part size), got %d", manager.MinUploadPartSize, bc.MinPartSize)
+	}
+
+	if bc.MultipartThreshold < manager.MinUploadPartSize || bc.MultipartThreshold > maxPartSize {
+		return fmt.Errorf("multipart_threshold must be between %d bytes (S3 minimum part size) and %d bytes (S3 maximum PutObject size), got %d",
+			manager.MinUploadPartSize, int64(maxPartSize), bc.MultipartThreshold)
+	}
+
+	if bc.Dedup && bc.DerivedPrefix != "" && prefixesOverlap(bc.DedupPrefix, bc.DerivedPrefix) {
+		return fmt.Errorf("derived_prefix '%s' and dedup_prefix '%s' overlap: derived objects would be rejected as blob writes", bc.DerivedPrefix, bc.DedupPrefix)
+	}
+
+	if bc.DisableACL && bc.PublicMode == PublicModePrefix && bc.Dedup && prefixesOverlap(bc.DedupPrefix, bc.PublicPrefix) {
+		return fmt.Errorf("dedup_prefix '%s' and public_prefix '%s' overlap: blobs would be publicly readable or public writes rejected", bc.DedupPrefix, bc.PublicPrefix)
+	}
+
+	// Audit chains need every object written through Write and kept forever
+	if bc.Audit != nil {
+		if bc.Dedup {
+			return fmt.Errorf("audit cannot be combined with dedup")
+		}
+		if bc.Expiration != nil {
+			return fmt.Errorf("audit cannot be combined with expiration")
+		}
+		if bc.Temporary != nil {
+			return fmt.Errorf("audit cannot be combined with temporary")
+		}
+	}
+
+	// These features find objects by key prefix, which sharding spreads over every shard
+	if bc.Sharding != nil {
+		if bc.Dedup {
+			return fmt.Errorf("sharding cannot be combined with dedup")
+		}
+		if bc.DerivedPrefix != "" {
+			return fmt.Errorf("sharding cannot be combined with derived_prefix")
+		}
+		if bc.DisableACL && bc.PublicMode == PublicModePrefix {
+			return fmt.Errorf("sharding cannot be combined with disable_acl in public_mode 'prefix', use public_mode 'presign'")
+		}
+	}
+
+	return nil
+}
+
+// lint returns warnings about bucket options that are ignored or have no effect
+func (bc *BucketConfig) lint() []string {
+	var warnings []string
+
+	if bc.DisableACL {
+		if bc.PublicMode == PublicModePrefix && bc.Visibility == "public" {
+			warnings = append(warnings, fmt.Sprintf("visibility 'public' has no effect with disable_acl in prefix mode: only objects under '%s' are public", bc.PublicPrefix))
+		}
+		if bc.PublicMode == PublicModePresign && bc.CDN != nil && bc.CDN.URL != "" {
+			warnings = append(warnings, "cdn.url is never returned: public URLs are presigned with public_mode 'presign'")
+		}
+	} else if bc.PublicMode != "" || bc.PublicPrefix != "" || bc.PublicURLTTL != 0 {
+		warnings = append(warnings, "public_mode, public_prefix and public_url_ttl are ignored unless disable_acl is enabled")
+	}
+
+	if !bc.Dedup && bc.DedupPrefix != "" {
+		warnings = append(warnings, "dedup_prefix is ignored unless dedup is enabled")
+	}
+
+	return warnings
+}
+
+// Lint returns warnings about valid but questionable option combinations, to be logged at startup.
+// It expects a validated configuration.
+func (c *Config) Lint() []ConfigWarning {
+	var warnings []ConfigWarning
+
+	servers := make([]string, 0, len(c.Servers))
+	for name := range c.Servers {
+		servers = append(servers, name)
+	}
+	sort.Strings(servers)
+
+	for _, name := range servers {
+		sc := c.Servers[name]
+		if sc.Endpoint == "" && sc.CopySourceEncoding == CopySourceEncodingRaw {
+			warnings = append(warnings, ConfigWarning{
+				Scope:   fmt.Sprintf("server '%s'", name),
+				Message: "copy_source_encoding 'raw' breaks copies of keys with special characters on AWS S3; use 'url'",
+			})
+		}
+		if sc.Endpoint == "" && sc.Signing != nil && (sc.Signing.Region != "" || sc.Signing.Name != "") {
+			warnings = append(warnings, ConfigWarning{
+				Scope:   fmt.Sprintf("server '%s'", name),
+				Message: "signing.region and signing.name overrides are rejected by AWS S3; they are meant for S3-compatible gateways",
+			})
+		}
+		if sc.Signing.anonymous() && sc.Credentials.Key != "" {
+			warnings = append(warnings, ConfigWarning{
+				Scope:   fmt.Sprintf("server '%s'", name),
+				Message: "credentials are ignored with signing.version 'none'",
+			})
+		}
+		if sc.Anonymous && sc.Credentials.Key != "" {
+			warnings = append(warnings, ConfigWarning{
+				Scope:   fmt.Sprintf("server '%s'", name),
+				Message: "credentials are ignored for anonymous servers",
+			})
+		}
+	}
+
+	buckets := make([]string, 0, len(c.Buckets))
+	for name := range c.Buckets {
+		buckets = append(buckets, name)
+	}
+	sort.Strings(buckets)
+
+	for _, name := range buckets {
+		for _, message := range c.Buckets[name].lint() {
+			warnings = append(warnings, ConfigWarning{
+				Scope:   fmt.Sprintf("bucket '%s'", name),
+				Message: message,
+			})
+		}
+	}
+
+	if slices.Contains(c.Interceptors, InterceptorReadOnly) {
+		if c.AllowDynamicServers {
+			warnings = append(warnings, ConfigWarning{
+				Scope:   "interceptors",
+				Message: "allow_dynamic_servers has no effect: the read_only interceptor rejects RegisterServer",
+			})
+		}
+		if c.Publish != nil {
+			warnings = append(warnings, ConfigWarning{
+				Scope:   "interceptors",
+				Message: "publish has no effect: the read_only interceptor rejects PublishDirectory",
+			})
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,421 @@
+package s3
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxListKeys is the largest page requested from S3 (the S3 API limit)
+	maxListKeys int32 = 1000
+
+	// defaultFetchNextKeys is used when a FetchNext request doesn't specify max_keys
+	defaultFetchNextKeys = 1000
+
+	// listEntryOverhead approximates the encoded size of an entry beyond its strings
+	listEntryOverhead = 64
+)
+
+// ListCursorManager keeps track of streaming listing cursors
+type ListCursorManager struct {
+	// Map of cursor ID to cursor
+	cursors map[string]*listCursor
+
+	// Idle time after which a cursor is discarded
+	ttl time.Duration
+
+	// Mutex for thread-safe access
+	mu sync.Mutex
+}
+
+// listCursor walks a prefix page by page; at most one S3 page is buffered at a time
+type listCursor struct {
+	id        string
+	bucket    string
+	prefix    string
+	delimiter string
+	markers   string
+	lastUsed  time.Time
+
+	// Continuation token of the next S3 page
+	token string
+
+	// No more S3 pages to fetch
+	exhausted bool
+
+	// Entries of the current S3 page not yet delivered
+	objects  []ObjectInfo
+	prefixes []CommonPrefix
+
+	// Serializes fetches so entries are delivered in order
+	mu sync.Mutex
+}
+
+// NewListCursorManager creates a new listing cursor manager
+func NewListCursorManager(ttl time.Duration) *ListCursorManager {
+	return &ListCursorManager{
+		cursors: make(map[string]*listCursor),
+		ttl:     ttl,
+	}
+}
+
+// create stores a new cursor, discarding expired ones
+func (lm *ListCursorManager) create(cursor *listCursor) error {
+	id, err := newRandomID()
+	if err != nil {
+		return err
+	}
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for existing, c := range lm.cursors {
+		if time.Since(c.lastUsed) > lm.ttl {
+			delete(lm.cursors, existing)
+		}
+	}
+
+	cursor.id = id
+	cursor.lastUsed = time.Now()
+	lm.cursors[id] = cursor
+	return nil
+}
+
+// get returns an active cursor and refreshes its idle timer
+func (lm *ListCursorManager) get(id string) (*listCursor, bool) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	cursor, exists := lm.cursors[id]
+	if !exists {
+		return nil, false
+	}
+
+	if time.Since(cursor.lastUsed) > lm.ttl {
+		delete(lm.cursors, id)
+		return nil, false
+	}
+
+	cursor.lastUsed = time.Now()
+	return cursor, true
+}
+
+// remove deletes a cursor, reporting whether it existed
+func (lm *ListCursorManager) remove(id string) bool {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if _, exists := lm.cursors[id]; !exists {
+		return false
+	}
+
+	delete(lm.cursors, id)
+	return true
+}
+
+// done reports whether every entry has been delivered
+func (c *listCursor) done() bool {
+	return c.exhausted && len(c.objects) == 0 && len(c.prefixes) == 0
+}
+
+// encodedSize approximates the number of bytes an object entry adds to an RPC response
+func (oi ObjectInfo) encodedSize() int64 {
+	return int64(len(oi.Key)+len(oi.ETag)+len(oi.StorageClass)) + listEntryOverhead
+}
+
+// listPageEntries converts an S3 listing page into response entries, stripping the bucket prefix
+// and applying the directory marker mode; seen de-duplicates prefixes reported from markers
+func listPageEntries(bucket *Bucket, result *s3.ListObjectsV2Output, markers, listedPrefix string, seen map[string]bool) ([]ObjectInfo, []CommonPrefix) {
+	objects := make([]ObjectInfo, 0, len(result.Contents))
+	var prefixes []CommonPrefix
+
+	for _, obj := range result.Contents {
+		// Remove bucket prefix and shard from key if present
+		key := bucket.Config.pathnameOf(aws.ToString(obj.Key))
+
+		if markers != DirectoryMarkersInclude && isDirectoryMarker(key, obj.Size) {
+			// The marker of the listed directory itself is never a child entry
+			if markers == DirectoryMarkersAsPrefix && key != listedPrefix && !seen[key] {
+				seen[key] = true
+				prefixes = append(prefixes, CommonPrefix{Prefix: key})
+			}
+			continue
+		}
+
+		objectInfo := ObjectInfo{
+			Key:          key,
+			Size:         aws.ToInt64(obj.Size),
+			LastModified: unixTime(obj.LastModified),
+			ETag:         aws.ToString(obj.ETag),
+		}
+
+		if obj.StorageClass != "" {
+			objectInfo.StorageClass = string(obj.StorageClass)
+		}
+
+		objects = append(objects, objectInfo)
+	}
+
+	// Process common prefixes (directories)
+	for _, cp := range result.CommonPrefixes {
+		// Remove bucket prefix and shard if present
+		prefix := bucket.Config.pathnameOf(aws.ToString(cp.Prefix))
+
+		// Skip prefixes already reported from directory markers
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+
+		prefixes = append(prefixes, CommonPrefix{
+			Prefix: prefix,
+		})
+	}
+
+	return objects, prefixes
+}
+
+// OpenListing opens a cursor over all objects under a prefix; entries are delivered by FetchNext
+func (o *Operations) OpenListing(ctx context.Context, req *OpenListingRequest, resp *ListCursorState) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	if !isValidDirectoryMarkersMode(req.DirectoryMarkers) {
+		o.plugin.metrics.RecordOperation(req.Bucket, "list_open", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("directory_markers must be 'include', 'skip' or 'as_prefix'")
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "list_open", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "OpenListing", "list_open", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	markers := bucket.Config.DirectoryMarkers
+	if req.DirectoryMarkers != "" {
+		markers = req.DirectoryMarkers
+	}
+
+	cursor := &listCursor{
+		bucket:    bucket.Name,
+		prefix:    req.Prefix,
+		delimiter: req.Delimiter,
+		markers:   markers,
+	}
+
+	if err := o.plugin.listings.create(cursor); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "list_open", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("create listing cursor", err)
+	}
+
+	*resp = ListCursorState{
+		CursorID:  cursor.id,
+		Bucket:    cursor.bucket,
+		Prefix:    cursor.prefix,
+		Delimiter: cursor.delimiter,
+	}
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "list_open", "success")
+
+	o.log.Debug("listing cursor opened",
+		zap.String("cursor", cursor.id),
+		zap.String("bucket", cursor.bucket),
+		zap.String("prefix", cursor.prefix),
+	)
+
+	return nil
+}
+
+// FetchNext delivers the next batch of a cursor, bounded by max_keys and max_list_response_size
+func (o *Operations) FetchNext(ctx context.Context, req *FetchNextRequest, resp *FetchNextResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	cursor, exists := o.plugin.listings.get(req.CursorID)
+	if !exists {
+		return NewSessionNotFoundError(req.CursorID)
+	}
+
+	// Check access
+	if err := o.authorizeIn(ctx, req.Caller, "FetchNext", "list_fetch", cursor.bucket, cursor.prefix); err != nil {
+		return err
+	}
+
+	cursor.mu.Lock()
+	defer cursor.mu.Unlock()
+
+	if req.MaxKeys < 0 {
+		o.plugin.metrics.RecordOperation(cursor.bucket, "list_fetch", "error")
+		o.plugin.metrics.RecordError(cursor.bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("max_keys must be >= 0")
+	}
+
+	maxKeys := req.MaxKeys
+	if maxKeys == 0 {
+		maxKeys = defaultFetchNextKeys
+	}
+	maxBytes := o.plugin.config.MaxListResponseSize
+
+	resp.Objects = make([]ObjectInfo, 0)
+
+	var size int64
+	for !cursor.done() && int(resp.KeyCount) < maxKeys && size < maxBytes {
+		if len(cursor.objects) == 0 && len(cursor.prefixes) == 0 {
+			if err := o.fetchListPage(ctx, cursor); err != nil {
+				// Entries already taken from the buffer are delivered; the next call retries the page
+				if resp.KeyCount > 0 {
+					loggerFor(ctx, o.log).Warn("listing page failed, delivering a partial batch",
+						zap.String("cursor", cursor.id),
+						zap.String("bucket", cursor.bucket),
+						zap.Error(err),
+					)
+					break
+				}
+				return err
+			}
+			continue
+		}
+
+		// Always deliver at least one entry so a single large key cannot stall the cursor
+		if len(cursor.prefixes) > 0 {
+			entry := cursor.prefixes[0]
+			entrySize := int64(len(entry.Prefix)) + listEntryOverhead
+			if size > 0 && size+entrySize > maxBytes {
+				break
+			}
+			cursor.prefixes = cursor.prefixes[1:]
+			resp.CommonPrefixes = append(resp.CommonPrefixes, entry)
+			size += entrySize
+		} else {
+			entry := cursor.objects[0]
+			if size > 0 && size+entry.encodedSize() > maxBytes {
+				break
+			}
+			cursor.objects = cursor.objects[1:]
+			resp.Objects = append(resp.Objects, entry)
+			size += entry.encodedSize()
+		}
+		resp.KeyCount++
+	}
+
+	resp.Done = cursor.done()
+
+	o.plugin.metrics.RecordOperation(cursor.bucket, "list_fetch", "success")
+
+	return nil
+}
+
+// fetchListPage loads the next S3 page into the cursor buffer; caller must hold cursor.mu
+func (o *Operations) fetchListPage(ctx context.Context, cursor *listCursor) error {
+	bucket, err := o.plugin.buckets.GetBucket(cursor.bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(cursor.bucket, "list_fetch", "error")
+		o.plugin.metrics.RecordError(cursor.bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(cursor.bucket)
+	}
+
+	if bucket.Config.Sharding != nil {
+		return o.fetchMergedListPage(ctx, bucket, cursor)
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket.Config.Bucket),
+		MaxKeys: aws.Int32(maxListKeys),
+	}
+	if prefix := bucket.GetFullPath(cursor.prefix); prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if cursor.delimiter != "" {
+		input.Delimiter = aws.String(cursor.delimiter)
+	}
+	if cursor.token != "" {
+		input.ContinuationToken = aws.String(cursor.token)
+	}
+
+	result, err := bucket.Reader().ListObjectsV2(ctx, input)
+	if err != nil {
+		o.log.Error("failed to fetch listing page",
+			zap.String("cursor", cursor.id),
+			zap.String("bucket", cursor.bucket),
+			zap.String("prefix", cursor.prefix),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(cursor.bucket, "list_fetch", "error")
+		o.plugin.metrics.RecordError(cursor.bucket, ErrS3Operation)
+		return NewS3OperationError("list objects", err)
+	}
+
+	// Prefix de-duplication is per page so memory stays bounded on huge listings
+	cursor.objects, cursor.prefixes = listPageEntries(bucket, result, cursor.markers, cursor.prefix, make(map[string]bool))
+
+	cursor.token = aws.ToString(result.NextContinuationToken)
+	cursor.exhausted = !aws.ToBool(result.IsTruncated) || cursor.token == ""
+
+	return nil
+}
+
+// fetchMergedListPage loads the next page of a sharded bucket into the cursor buffer, with the last
+// delivered pathname as token; caller must hold cursor.mu
+func (o *Operations) fetchMergedListPage(ctx context.Context, bucket *Bucket, cursor *listCursor) error {
+	page, err := o.listMerged(ctx, listSources(bucket), cursor.prefix, cursor.delimiter, cursor.markers, maxListKeys, cursor.token, nil)
+	if err != nil {
+		o.log.Error("failed to fetch listing page",
+			zap.String("cursor", cursor.id),
+			zap.String("bucket", cursor.bucket),
+			zap.String("prefix", cursor.prefix),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(cursor.bucket, "list_fetch", "error")
+		o.plugin.metrics.RecordError(cursor.bucket, ErrS3Operation)
+		return NewS3OperationError("list objects", err)
+	}
+
+	cursor.objects, cursor.prefixes = page.objects, page.prefixes
+	cursor.token = page.next
+	cursor.exhausted = !page.truncated
+
+	return nil
+}
+
+// CloseListing discards a cursor
+func (o *Operations) CloseListing(req *CloseListingRequest, resp *CloseListingResponse) error {
+	cursor, exists := o.plugin.listings.get(req.CursorID)
+	if !exists {
+		return NewSessionNotFoundError(req.CursorID)
+	}
+
+	// Check access
+	if err := o.authorizeIn(o.plugin.ctx, req.Caller, "CloseListing", "list_close", cursor.bucket, cursor.prefix); err != nil {
+		return err
+	}
+
+	if !o.plugin.listings.remove(req.CursorID) {
+		return NewSessionNotFoundError(req.CursorID)
+	}
+
+	resp.Success = true
+	return nil
+}
@@ -0,0 +1,241 @@
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+// afterDelimiter follows a common prefix so a resumed listing skips the keys rolled up into it
+const afterDelimiter = "\U0010FFFF"
+
+// listSource is one key range of a merged listing: a bucket, or one shard of a sharded bucket
+type listSource struct {
+	bucket *Bucket
+	shard  string
+}
+
+// listSources returns the key ranges holding the objects of bucket
+func listSources(bucket *Bucket) []listSource {
+	if bucket.Config.Sharding == nil {
+		return []listSource{{bucket: bucket}}
+	}
+
+	shards := bucket.Config.Sharding.shards()
+	sources := make([]listSource, len(shards))
+	for i, shard := range shards {
+		sources[i] = listSource{bucket: bucket, shard: shard}
+	}
+	return sources
+}
+
+// key returns the S3 key of pathname within the source
+func (s listSource) key(pathname string) string {
+	if s.shard == "" {
+		return s.bucket.GetFullPath(pathname)
+	}
+	return s.bucket.GetFullPath(s.shard + "/" + pathname)
+}
+
+// mergedEntry is an object or common prefix of a merged listing, ordered by name
+type mergedEntry struct {
+	name   string
+	object *ObjectInfo
+}
+
+// mergedPage is one page of a merged listing
+type mergedPage struct {
+	objects   []ObjectInfo
+	prefixes  []CommonPrefix
+	next      string
+	truncated bool
+}
+
+// encodeListToken and decodeListToken convert the last pathname of a merged page to an opaque
+// continuation token and back
+func encodeListToken(pathname string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pathname))
+}
+
+func decodeListToken(token string) (string, error) {
+	pathname, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid continuation token")
+	}
+	return string(pathname), nil
+}
+
+// listMerged lists up to maxKeys entries after the pathname after across sources, in pathname order.
+// Every source resumes right after the same pathname, so pages neither repeat nor skip entries.
+// keep, when set, drops objects a source holds that belong elsewhere. Sources acquire their bucket
+// semaphore per request, so the caller must not hold it.
+func (o *Operations) listMerged(ctx context.Context, sources []listSource, prefix, delimiter, markers string, maxKeys int32, after string, keep func(*Bucket, string) bool) (*mergedPage, error) {
+	startAfter := after
+	if delimiter != "" && after != prefix && strings.HasSuffix(after, delimiter) {
+		startAfter += afterDelimiter
+	}
+
+	var entries []mergedEntry
+	seen := make(map[string]bool)
+	bound, bounded := "", false
+
+	for _, source := range sources {
+		input := &s3.ListObjectsV2Input{
+			Bucket:  aws.String(source.bucket.Config.Bucket),
+			MaxKeys: aws.Int32(maxKeys),
+		}
+		if p := source.key(prefix); p != "" {
+			input.Prefix = aws.String(p)
+		}
+		if delimiter != "" {
+			input.Delimiter = aws.String(delimiter)
+		}
+		if startAfter != "" {
+			input.StartAfter = aws.String(source.key(startAfter))
+		}
+
+		if err := source.bucket.Acquire(ctx); err != nil {
+			return nil, err
+		}
+		result, err := source.bucket.Reader().ListObjectsV2(ctx, input)
+		source.bucket.Release()
+		if err != nil {
+			return nil, err
+		}
+
+		// Entries past the end of a truncated page are unknown, nothing after them can be returned yet
+		if aws.ToBool(result.IsTruncated) {
+			if last := lastPageEntry(source.bucket, result); !bounded || last < bound {
+				bound, bounded = last, true
+			}
+		}
+
+		sourceMarkers := markers
+		if sourceMarkers == "" {
+			sourceMarkers = source.bucket.Config.DirectoryMarkers
+		}
+		objects, prefixes := listPageEntries(source.bucket, result, sourceMarkers, prefix, seen)
+		for i := range objects {
+			if keep == nil || keep(source.bucket, objects[i].Key) {
+				entries = append(entries, mergedEntry{name: objects[i].Key, object: &objects[i]})
+			}
+		}
+		for _, cp := range prefixes {
+			entries = append(entries, mergedEntry{name: cp.Prefix})
+		}
+	}
+
+	if bounded {
+		filtered := entries[:0]
+		for _, entry := range entries {
+			if entry.name <= bound {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	page := &mergedPage{objects: make([]ObjectInfo, 0, min(len(entries), int(maxKeys)))}
+	if len(entries) > int(maxKeys) {
+		entries = entries[:maxKeys]
+		page.truncated = true
+		page.next = entries[len(entries)-1].name
+	} else if bounded {
+		page.truncated = true
+		page.next = bound
+	}
+
+	for _, entry := range entries {
+		if entry.object != nil {
+			page.objects = append(page.objects, *entry.object)
+		} else {
+			page.prefixes = append(page.prefixes, CommonPrefix{Prefix: entry.name})
+		}
+	}
+
+	return page, nil
+}
+
+// lastPageEntry returns the pathname of the last key or common prefix of a listing page
+func lastPageEntry(bucket *Bucket, result *s3.ListObjectsV2Output) string {
+	var last string
+	if n := len(result.Contents); n > 0 {
+		last = bucket.Config.pathnameOf(aws.ToString(result.Contents[n-1].Key))
+	}
+	if n := len(result.CommonPrefixes); n > 0 {
+		if p := bucket.Config.pathnameOf(aws.ToString(result.CommonPrefixes[n-1].Prefix)); p > last {
+			last = p
+		}
+	}
+	return last
+}
+
+// listMergedObjects serves ListObjects from several sources; req.Bucket labels metrics and logs
+func (o *Operations) listMergedObjects(ctx context.Context, req *ListObjectsRequest, resp *ListObjectsResponse, sources []listSource, keep func(*Bucket, string) bool, start time.Time) error {
+	maxKeys := req.MaxKeys
+	if maxKeys <= 0 || maxKeys > maxListKeys {
+		maxKeys = maxListKeys
+	}
+
+	var after string
+	if req.ContinuationToken != "" {
+		var err error
+		if after, err = decodeListToken(req.ContinuationToken); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "list", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return NewInvalidRequestError(err.Error())
+		}
+	}
+
+	page, err := o.listMerged(ctx, sources, req.Prefix, req.Delimiter, req.DirectoryMarkers, maxKeys, after, keep)
+	if err != nil {
+		o.log.Error("failed to list objects",
+			zap.String("bucket", req.Bucket),
+			zap.String("prefix", req.Prefix),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "list", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("list objects", err)
+	}
+
+	// Refuse pages too large to deliver in a single response
+	var size int64
+	for _, obj := range page.objects {
+		size += obj.encodedSize()
+	}
+	if size > o.plugin.config.MaxListResponseSize {
+		o.plugin.metrics.RecordOperation(req.Bucket, "list", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("listing exceeds max_list_response_size, lower max_keys or use OpenListing")
+	}
+
+	resp.Objects = page.objects
+	resp.CommonPrefixes = page.prefixes
+	resp.IsTruncated = page.truncated
+	if page.truncated {
+		resp.NextContinuationToken = encodeListToken(page.next)
+	}
+	resp.KeyCount = int32(len(page.objects) + len(page.prefixes))
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "list", "success")
+
+	o.log.Debug("objects listed successfully",
+		zap.String("bucket", req.Bucket),
+		zap.String("prefix", req.Prefix),
+		zap.Int("sources", len(sources)),
+		zap.Int32("count", resp.KeyCount),
+		zap.Bool("truncated", resp.IsTruncated),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultLockSuffix is appended to a pathname to name its lock sidecar object
+	defaultLockSuffix = ".lock"
+
+	// defaultLockTTL bounds how long a lock is held when the owner never releases it
+	defaultLockTTL = time.Minute
+
+	// maxLockTTL is the longest lease a caller may request
+	maxLockTTL = 24 * time.Hour
+)
+
+// fileLock is the JSON body of a lock sidecar object
+type fileLock struct {
+	Token      string `json:"token"`
+	Owner      string `json:"owner,omitempty"`
+	AcquiredAt int64  `json:"acquired_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// expired reports whether the lock lease has run out
+func (l *fileLock) expired(now time.Time) bool {
+	return now.Unix() >= l.ExpiresAt
+}
+
+// lockPathname returns the pathname of the lock sidecar of pathname
+func (bc *BucketConfig) lockPathname(pathname string) string {
+	return pathname + bc.LockSuffix
+}
+
+// isConditionalConflict reports whether a conditional write lost a race with a concurrent one
+func isConditionalConflict(err error) bool {
+	var apiErr smithy.APIError
+	return isPreconditionFailed(err) || (errors.As(err, &apiErr) && apiErr.ErrorCode() == "ConditionalRequestConflict")
+}
+
+// AcquireFileLock takes an advisory lock on a pathname by atomically creating its lock sidecar
+// object (If-None-Match). An expired lock is taken over with If-Match on its ETag, so two callers
+// never both acquire it. A lock held by someone else is reported with Acquired=false, not an error.
+func (o *Operations) AcquireFileLock(ctx context.Context, req *AcquireFileLockRequest, resp *FileLockResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_acquire", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	ttl := defaultLockTTL
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 || ttl > maxLockTTL {
+			o.plugin.metrics.RecordOperation(req.Bucket, "lock_acquire", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return NewInvalidRequestError(fmt.Sprintf("ttl must be a positive duration up to %s (e.g., \"30s\")", maxLockTTL))
+		}
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_acquire", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "AcquireFileLock", "lock_acquire", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	// Lock sidecars are overwritten and deleted
+	if err := bucket.requireAppendOnly("AcquireFileLock"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_acquire", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	token, err := newRandomID()
+	if err != nil {
+		return NewS3OperationError("generate lock token", err)
+	}
+
+	now := time.Now()
+	lock := fileLock{
+		Token:      token,
+		Owner:      req.Owner,
+		AcquiredAt: now.Unix(),
+		ExpiresAt:  now.Add(ttl).Unix(),
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	key := bucket.ObjectKey(bucket.Config.lockPathname(req.Pathname))
+
+	// Fast path: nobody holds the lock
+	_, err = o.putLock(ctx, bucket, key, &lock, &s3.PutObjectInput{IfNoneMatch: aws.String("*")})
+	if err != nil && isConditionalConflict(err) {
+		// Someone holds or held the lock; take it over only if the lease ran out
+		var held *fileLock
+		var etag string
+		held, etag, err = o.readLock(ctx, bucket, key)
+		switch {
+		case err != nil:
+		case held != nil && !held.expired(now):
+			resp.Acquired = false
+			resp.Owner = held.Owner
+			resp.ExpiresAt = held.ExpiresAt
+			o.plugin.metrics.RecordOperation(req.Bucket, "lock_acquire", "success")
+			return nil
+		case held == nil:
+			// Released in between; compete for it again
+			_, err = o.putLock(ctx, bucket, key, &lock, &s3.PutObjectInput{IfNoneMatch: aws.String("*")})
+		default:
+			_, err = o.putLock(ctx, bucket, key, &lock, &s3.PutObjectInput{IfMatch: aws.String(etag)})
+		}
+
+		if err != nil && isConditionalConflict(err) {
+			// Another caller won the race
+			resp.Acquired = false
+			o.plugin.metrics.RecordOperation(req.Bucket, "lock_acquire", "success")
+			return nil
+		}
+	}
+
+	if err != nil {
+		o.log.Error("failed to acquire file lock",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_acquire", "error")
+		if notImplemented(err) {
+			o.plugin.metrics.RecordError(req.Bucket, ErrNotSupported)
+			return NewNotSupportedError("conditional write", req.Bucket)
+		}
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("acquire lock", err)
+	}
+
+	resp.Acquired = true
+	resp.Token = lock.Token
+	resp.Owner = lock.Owner
+	resp.ExpiresAt = lock.ExpiresAt
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "lock_acquire", "success")
+
+	o.log.Debug("file lock acquired",
+		zap.String("bucket", req.Bucket),
+		zap.String("pathname", req.Pathname),
+		zap.Duration("ttl", ttl),
+	)
+
+	return nil
+}
+
+// ReleaseFileLock deletes the lock sidecar of a pathname if it is still held with the given token
+func (o *Operations) ReleaseFileLock(ctx context.Context, req *ReleaseFileLockRequest, resp *ReleaseFileLockResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_release", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	if req.Token == "" {
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_release", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("token is required")
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_release", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "ReleaseFileLock", "lock_release", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	key := bucket.ObjectKey(bucket.Config.lockPathname(req.Pathname))
+
+	held, etag, err := o.readLock(ctx, bucket, key)
+	if err == nil && (held == nil || held.Token != req.Token) {
+		// Expired and taken over, or already released: the caller no longer holds it
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_release", "success")
+		return nil
+	}
+
+	if err == nil {
+		// If-Match keeps a takeover that happened after the read
+		_, err = bucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket:  aws.String(bucket.Config.Bucket),
+			Key:     aws.String(key),
+			IfMatch: aws.String(etag),
+		})
+		if err != nil && isConditionalConflict(err) {
+			o.plugin.metrics.RecordOperation(req.Bucket, "lock_release", "success")
+			return nil
+		}
+	}
+
+	if err != nil {
+		o.log.Error("failed to release file lock",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "lock_release", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("release lock", err)
+	}
+
+	resp.Released = true
+	o.plugin.metrics.RecordOperation(req.Bucket, "lock_release", "success")
+
+	o.log.Debug("file lock released",
+		zap.String("bucket", req.Bucket),
+		zap.String("pathname", req.Pathname),
+	)
+
+	return nil
+}
+
+// putLock writes a lock sidecar with the conditions set on input and returns its ETag
+func (o *Operations) putLock(ctx context.Context, bucket *Bucket, key string, lock *fileLock, input *s3.PutObjectInput) (string, error) {
+	body, err := json.Marshal(lock)
+	if err != nil {
+		return "", err
+	}
+
+	input.Bucket = aws.String(bucket.Config.Bucket)
+	input.Key = aws.String(key)
+	input.Body = strings.NewReader(string(body))
+	input.ContentType = aws.String("application/json")
+
+	result, err := bucket.Client.PutObject(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(result.ETag), nil
+}
+
+// readLock returns the lock stored at key and its ETag; a nil lock means no lock exists
+func (o *Operations) readLock(ctx context.Context, bucket *Bucket, key string) (*fileLock, string, error) {
+	result, err := bucket.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(result.Body, 64*1024))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var lock fileLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		// Not written by this plugin: treat as an expired lock so it can be taken over
+		return &fileLock{}, aws.ToString(result.ETag), nil
+	}
+
+	return &lock, aws.ToString(result.ETag), nil
+}
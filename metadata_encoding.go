@@ -0,0 +1,143 @@
+package s3
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MetadataEncodingNone stores metadata values as given; values must be ASCII
+	MetadataEncodingNone = "none"
+
+	// MetadataEncodingRFC2047 stores non-ASCII values as RFC 2047 encoded-words (=?utf-8?q?...?=),
+	// the form S3 itself reports non-ASCII metadata in
+	MetadataEncodingRFC2047 = "rfc2047"
+
+	// MetadataEncodingPercent stores values percent-encoded
+	MetadataEncodingPercent = "percent"
+)
+
+// metadataCodec converts user metadata values to the ASCII form carried in x-amz-meta-* headers and back
+type metadataCodec interface {
+	encode(value string) string
+	decode(value string) string
+}
+
+// metadataCodecs holds the codecs selectable with metadata_encoding
+var metadataCodecs = map[string]metadataCodec{
+	MetadataEncodingRFC2047: rfc2047Codec{},
+	MetadataEncodingPercent: percentCodec{},
+}
+
+// internalMetadata are the user metadata keys written by the plugin itself, hidden from GetMetadata
+var internalMetadata = map[string]bool{
+	dedupHashMetadata:        true,
+	dedupSizeMetadata:        true,
+	stagedPathnameMetadata:   true,
+	stagedVisibilityMetadata: true,
+	temporaryHashMetadata:    true,
+}
+
+type rfc2047Codec struct{}
+
+func (rfc2047Codec) encode(value string) string {
+	// Values of printable ASCII are returned as they are
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+func (rfc2047Codec) decode(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+type percentCodec struct{}
+
+func (percentCodec) encode(value string) string {
+	return url.PathEscape(value)
+}
+
+func (percentCodec) decode(value string) string {
+	decoded, err := url.PathUnescape(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// validateMetadataEncoding defaults and checks metadata_encoding
+func (bc *BucketConfig) validateMetadataEncoding() error {
+	switch bc.MetadataEncoding {
+	case "":
+		bc.MetadataEncoding = MetadataEncodingNone
+	case MetadataEncodingNone, MetadataEncodingRFC2047, MetadataEncodingPercent:
+	default:
+		return fmt.Errorf("invalid metadata_encoding '%s', must be '%s', '%s' or '%s'",
+			bc.MetadataEncoding, MetadataEncodingNone, MetadataEncodingRFC2047, MetadataEncodingPercent)
+	}
+	return nil
+}
+
+// encodeMetadata returns a copy of metadata with values encoded by the bucket's metadata_encoding.
+// Keys must be ASCII with every encoding, as must values without one: S3 rejects other headers
+// with a signature mismatch that hides the cause.
+func (bc *BucketConfig) encodeMetadata(metadata map[string]string) (map[string]string, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	codec := metadataCodecs[bc.MetadataEncoding]
+	encoded := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if !isASCII(k) {
+			return nil, NewInvalidRequestError(fmt.Sprintf("metadata key '%s' must be ASCII", k))
+		}
+		if !utf8.ValidString(v) {
+			return nil, NewInvalidRequestError(fmt.Sprintf("metadata value of '%s' must be valid UTF-8", k))
+		}
+		if codec == nil {
+			if !isASCII(v) {
+				return nil, NewInvalidRequestError(fmt.Sprintf("metadata value of '%s' must be ASCII, or set metadata_encoding", k))
+			}
+			encoded[k] = v
+			continue
+		}
+		encoded[k] = codec.encode(v)
+	}
+	return encoded, nil
+}
+
+// decodeMetadata returns the user metadata of an object with values decoded by the bucket's
+// metadata_encoding, leaving out the keys written by the plugin
+func (bc *BucketConfig) decodeMetadata(metadata map[string]string) map[string]string {
+	codec := metadataCodecs[bc.MetadataEncoding]
+	decoded := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if internalMetadata[strings.ToLower(k)] {
+			continue
+		}
+		if codec != nil {
+			v = codec.decode(v)
+		}
+		decoded[k] = v
+	}
+	if len(decoded) == 0 {
+		return nil
+	}
+	return decoded
+}
+
+// isASCII reports whether s holds ASCII characters only
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,13 @@
 package s3
 
 import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -11,17 +18,175 @@ type metricsExporter struct {
 
 	// errorsTotal tracks errors by bucket and error type
 	errorsTotal *prometheus.CounterVec
+
+	// globalQueueWait tracks time spent waiting for a global concurrency slot
+	globalQueueWait prometheus.Histogram
+
+	// slowOperationsTotal counts operations exceeding slow_operation_threshold
+	slowOperationsTotal *prometheus.CounterVec
+
+	// dedupHitsTotal counts writes whose content was already stored in a dedup bucket
+	dedupHitsTotal *prometheus.CounterVec
+
+	// dedupBytesSavedTotal counts bytes not uploaded thanks to deduplication
+	dedupBytesSavedTotal *prometheus.CounterVec
+
+	// requestsTotal counts S3 API requests by bucket, API operation and pricing class
+	requestsTotal *prometheus.CounterVec
+
+	// egressBytesTotal counts bytes downloaded from S3 per bucket
+	egressBytesTotal *prometheus.CounterVec
+
+	// retriesSuppressedTotal counts failed requests not retried because the retry budget was empty
+	retriesSuppressedTotal *prometheus.CounterVec
+
+	// connectionPhaseSeconds tracks DNS, connect, TLS and time-to-first-byte durations per bucket
+	connectionPhaseSeconds *prometheus.HistogramVec
+
+	// hedgedRequestsTotal counts hedged reads by bucket, API operation and which request answered first
+	hedgedRequestsTotal *prometheus.CounterVec
+
+	// uploadsTotal counts Write uploads by bucket and upload method (single or multipart)
+	uploadsTotal *prometheus.CounterVec
+
+	// presignCacheTotal counts GetPublicURL presign cache lookups by bucket and status (hit or miss)
+	presignCacheTotal *prometheus.CounterVec
+
+	// indexUpdatesTotal counts object index updates by bucket and status (indexed, removed, dropped, rejected or failed)
+	indexUpdatesTotal *prometheus.CounterVec
+
+	// quotaUsageRatio is the measured share of write_policy.quota in use per bucket
+	quotaUsageRatio *prometheus.GaugeVec
+
+	// quotaAlertsTotal counts crossed quota_alerts thresholds by bucket and threshold
+	quotaAlertsTotal *prometheus.CounterVec
+
+	// payloadSizeBytes tracks body sizes of GetObject, PutObject and UploadPart per bucket
+	payloadSizeBytes *prometheus.HistogramVec
+
+	// alerts evaluates error thresholds from recorded operations (nil when disabled)
+	alerts *alertMonitor
+
+	// health tracks the latest outcome per bucket for status reporting
+	health *healthTracker
+
+	// registered resolves a caller-supplied bucket name to a registered bucket, so per-bucket state
+	// is kept once per bucket and not for aliases or unknown names
+	registered func(name string) (string, bool)
+
+	// Bucket label values: every name (nil), listed names only (others as "_other"), or "_all"
+	labeledBuckets map[string]bool
+	bucketLabel    bool
+
+	// Metric families not exported, by name without prefix
+	disabled map[string]bool
+}
+
+// MetricsConfig configures the names and constant labels of exported metrics
+type MetricsConfig struct {
+	// Prefix is prepended to every metric name (default: "rr_s3")
+	Prefix string `mapstructure:"prefix"`
+
+	// Labels are constant labels attached to every metric (e.g., instance, app)
+	Labels map[string]string `mapstructure:"labels"`
+
+	// DisableBucketLabel reports every bucket as "_all", for deployments with many dynamic buckets
+	DisableBucketLabel bool `mapstructure:"disable_bucket_label"`
+
+	// BucketAllowlist limits the bucket label to these buckets; others are reported as "_other"
+	BucketAllowlist []string `mapstructure:"bucket_allowlist"`
+
+	// Disable lists metric families not to export, by name without prefix (e.g., "payload_size_bytes")
+	Disable []string `mapstructure:"disable"`
+}
+
+const (
+	// otherBucketLabel is the bucket label of buckets missing from metrics.bucket_allowlist
+	otherBucketLabel = "_other"
+
+	// allBucketsLabel is the bucket label of every bucket with metrics.disable_bucket_label
+	allBucketsLabel = "_all"
+)
+
+// metricFamilies are the names, without prefix, of the metric families the plugin exports
+var metricFamilies = []string{
+	"operations_total", "errors_total", "global_queue_wait_seconds", "slow_operations_total",
+	"dedup_hits_total", "dedup_bytes_saved_total", "requests_total", "egress_bytes_total",
+	"retries_suppressed_total", "connection_phase_seconds", "hedged_requests_total", "uploads_total",
+	"presign_cache_total", "index_updates_total", "quota_usage_ratio", "quota_alerts_total",
+	"payload_size_bytes",
+}
+
+var (
+	metricPrefixPattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	metricLabelPattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// reservedMetricLabels are the variable labels used by the plugin's metrics
+var reservedMetricLabels = []string{"operation", "bucket", "status", "error_type", "api", "class", "winner", "phase", "method"}
+
+// Validate validates the metrics configuration
+func (mc *MetricsConfig) Validate() error {
+	if mc.Prefix == "" {
+		mc.Prefix = "rr_s3"
+	}
+
+	if !metricPrefixPattern.MatchString(mc.Prefix) {
+		return fmt.Errorf("invalid prefix '%s'", mc.Prefix)
+	}
+
+	for name := range mc.Labels {
+		if !metricLabelPattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name '%s'", name)
+		}
+		if slices.Contains(reservedMetricLabels, name) {
+			return fmt.Errorf("label '%s' is reserved", name)
+		}
+	}
+
+	if mc.DisableBucketLabel && len(mc.BucketAllowlist) > 0 {
+		return fmt.Errorf("bucket_allowlist has no effect with disable_bucket_label")
+	}
+
+	for _, family := range mc.Disable {
+		if !slices.Contains(metricFamilies, family) {
+			return fmt.Errorf("unknown metric '%s' in disable, expected one of %s", family, strings.Join(metricFamilies, ", "))
+		}
+	}
+
+	return nil
+}
+
+// bucket returns the bucket label value of a bucket
+func (m *metricsExporter) bucket(name string) string {
+	switch {
+	case !m.bucketLabel:
+		return allBucketsLabel
+	case m.labeledBuckets != nil && !m.labeledBuckets[name]:
+		return otherBucketLabel
+	}
+	return name
 }
 
-// newMetricsExporter creates a new metrics exporter for S3 operations
-// Returns error if metrics registration fails
-func newMetricsExporter() (*metricsExporter, error) {
+// newMetricsExporter creates a new metrics exporter for S3 operations.
+// Collectors are registered by the metrics plugin through MetricsCollector, never with the
+// default registry, so several plugin instances in one binary don't collide.
+func newMetricsExporter(cfg *MetricsConfig) *metricsExporter {
+	name := func(suffix string) string {
+		return cfg.Prefix + "_" + suffix
+	}
+	labels := prometheus.Labels(cfg.Labels)
+
 	m := &metricsExporter{
+		bucketLabel: !cfg.DisableBucketLabel,
+		disabled:    make(map[string]bool, len(cfg.Disable)),
+
 		// Operation counter with labels: operation, bucket, status
 		operationsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
-				Name: "rr_s3_operations_total",
-				Help: "Total number of S3 operations by type, bucket, and status",
+				Name:        name("operations_total"),
+				Help:        "Total number of S3 operations by type, bucket, and status",
+				ConstLabels: labels,
 			},
 			[]string{"operation", "bucket", "status"},
 		),
@@ -29,30 +194,177 @@ func newMetricsExporter() (*metricsExporter, error) {
 		// Error counter with labels: bucket, error_type
 		errorsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
-				Name: "rr_s3_errors_total",
-				Help: "Total number of S3 errors by bucket and error type",
+				Name:        name("errors_total"),
+				Help:        "Total number of S3 errors by bucket and error type",
+				ConstLabels: labels,
 			},
 			[]string{"bucket", "error_type"},
 		),
-	}
 
-	// Register metrics with Prometheus default registry
-	// This ensures metrics are available even if MetricsCollector() isn't called
-	if err := prometheus.Register(m.operationsTotal); err != nil {
-		// Check if already registered (happens on plugin reload)
-		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
-			return nil, err
-		}
+		// Global queue wait histogram
+		globalQueueWait: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:        name("global_queue_wait_seconds"),
+				Help:        "Time operations wait for a slot of the global concurrency limit",
+				Buckets:     []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+				ConstLabels: labels,
+			},
+		),
+
+		// Slow operation counter with labels: operation, bucket
+		slowOperationsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("slow_operations_total"),
+				Help:        "Total number of operations whose queue wait or duration exceeded the slow operation threshold",
+				ConstLabels: labels,
+			},
+			[]string{"operation", "bucket"},
+		),
+
+		// Dedup hit counter with labels: bucket
+		dedupHitsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("dedup_hits_total"),
+				Help:        "Total number of writes whose content was already stored in a dedup bucket",
+				ConstLabels: labels,
+			},
+			[]string{"bucket"},
+		),
+
+		// Dedup saved bytes counter with labels: bucket
+		dedupBytesSavedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("dedup_bytes_saved_total"),
+				Help:        "Total number of bytes not uploaded because identical content was already stored",
+				ConstLabels: labels,
+			},
+			[]string{"bucket"},
+		),
+
+		// S3 API request counter with labels: bucket, api, class
+		requestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("requests_total"),
+				Help:        "Total number of S3 API requests (including retries) by bucket, API operation and pricing class",
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "api", "class"},
+		),
+
+		// Egress counter with labels: bucket
+		egressBytesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("egress_bytes_total"),
+				Help:        "Total number of bytes downloaded from S3 by GetObject requests",
+				ConstLabels: labels,
+			},
+			[]string{"bucket"},
+		),
+
+		// Suppressed retry counter with labels: bucket
+		retriesSuppressedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("retries_suppressed_total"),
+				Help:        "Total number of failed S3 requests not retried because the bucket's retry budget was exhausted",
+				ConstLabels: labels,
+			},
+			[]string{"bucket"},
+		),
+
+		// Connection phase histogram with labels: bucket, phase
+		connectionPhaseSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        name("connection_phase_seconds"),
+				Help:        "Duration of HTTP request phases to S3: dns, connect and tls for new connections, ttfb for every request",
+				Buckets:     []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "phase"},
+		),
+
+		// Hedged read counter with labels: bucket, api, winner
+		hedgedRequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("hedged_requests_total"),
+				Help:        "Total number of reads sent a second time by hedging, by which request answered first",
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "api", "winner"},
+		),
+
+		// Upload counter with labels: bucket, method
+		uploadsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("uploads_total"),
+				Help:        "Total number of Write uploads sent as a single PutObject or as a multipart upload",
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "method"},
+		),
+
+		// Presign cache counter with labels: bucket, status
+		presignCacheTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("presign_cache_total"),
+				Help:        "Total number of presigned URL cache lookups by GetPublicURL, by whether a cached URL was reused",
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "status"},
+		),
+
+		// Index update counter with labels: bucket, status
+		indexUpdatesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("index_updates_total"),
+				Help:        "Total number of object index updates by written and deleted objects",
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "status"},
+		),
+
+		// Quota usage gauge with label: bucket
+		quotaUsageRatio: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        name("quota_usage_ratio"),
+				Help:        "Measured bytes stored divided by write_policy.quota",
+				ConstLabels: labels,
+			},
+			[]string{"bucket"},
+		),
+
+		// Quota alert counter with labels: bucket, threshold
+		quotaAlertsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        name("quota_alerts_total"),
+				Help:        "Total number of write_policy.quota_alerts thresholds crossed",
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "threshold"},
+		),
+
+		// Payload size histogram with labels: bucket, api
+		payloadSizeBytes: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        name("payload_size_bytes"),
+				Help:        "Body size of object reads (GetObject) and writes (PutObject, UploadPart)",
+				Buckets:     prometheus.ExponentialBuckets(1<<10, 4, len(payloadSizeBounds)),
+				ConstLabels: labels,
+			},
+			[]string{"bucket", "api"},
+		),
 	}
 
-	if err := prometheus.Register(m.errorsTotal); err != nil {
-		// Check if already registered (happens on plugin reload)
-		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
-			return nil, err
+	if len(cfg.BucketAllowlist) > 0 {
+		m.labeledBuckets = make(map[string]bool, len(cfg.BucketAllowlist))
+		for _, bucket := range cfg.BucketAllowlist {
+			m.labeledBuckets[bucket] = true
 		}
 	}
+	for _, family := range cfg.Disable {
+		m.disabled[family] = true
+	}
 
-	return m, nil
+	return m
 }
 
 // RecordOperation increments the operation counter
@@ -63,7 +375,11 @@ func (m *metricsExporter) RecordOperation(bucket, operation, status string) {
 	if m == nil {
 		return
 	}
-	m.operationsTotal.WithLabelValues(operation, bucket, status).Inc()
+	m.operationsTotal.WithLabelValues(operation, m.bucket(bucket), status).Inc()
+	if name, ok := m.registeredBucket(bucket); ok {
+		m.alerts.observe(name, status)
+		m.health.recordOperation(name, status)
+	}
 }
 
 // RecordError increments the error counter
@@ -73,16 +389,171 @@ func (m *metricsExporter) RecordError(bucket string, errorType ErrorCode) {
 	if m == nil {
 		return
 	}
-	m.errorsTotal.WithLabelValues(bucket, string(errorType)).Inc()
+	m.errorsTotal.WithLabelValues(m.bucket(bucket), string(errorType)).Inc()
+	if name, ok := m.registeredBucket(bucket); ok {
+		m.health.recordError(name, errorType)
+	}
+}
+
+// registeredBucket returns the registered bucket a name resolves to; false for unknown names
+func (m *metricsExporter) registeredBucket(name string) (string, bool) {
+	if m.registered == nil {
+		return "", false
+	}
+	return m.registered(name)
+}
+
+// ObserveGlobalQueueWait records the time an operation waited for a global slot
+func (m *metricsExporter) ObserveGlobalQueueWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.globalQueueWait.Observe(wait.Seconds())
+}
+
+// RecordSlowOperation increments the slow operation counter
+func (m *metricsExporter) RecordSlowOperation(bucket, operation string) {
+	if m == nil {
+		return
+	}
+	m.slowOperationsTotal.WithLabelValues(operation, m.bucket(bucket)).Inc()
+}
+
+// RecordDedupHit records a write whose content was already stored
+func (m *metricsExporter) RecordDedupHit(bucket string, size int64) {
+	if m == nil {
+		return
+	}
+	m.dedupHitsTotal.WithLabelValues(m.bucket(bucket)).Inc()
+	m.dedupBytesSavedTotal.WithLabelValues(m.bucket(bucket)).Add(float64(size))
+}
+
+// RecordRequest counts an S3 API request and the bytes it downloaded
+func (m *metricsExporter) RecordRequest(bucket, api, class string, egress int64) {
+	if m == nil {
+		return
+	}
+	m.requestsTotal.WithLabelValues(m.bucket(bucket), api, class).Inc()
+	if egress > 0 {
+		m.egressBytesTotal.WithLabelValues(m.bucket(bucket)).Add(float64(egress))
+	}
+}
+
+// RecordRetrySuppressed counts a retry refused by an exhausted retry budget
+func (m *metricsExporter) RecordRetrySuppressed(bucket string) {
+	if m == nil {
+		return
+	}
+	m.retriesSuppressedTotal.WithLabelValues(m.bucket(bucket)).Inc()
+}
+
+// ObservePhase records the duration of a connection phase
+func (m *metricsExporter) ObservePhase(bucket, phase string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.connectionPhaseSeconds.WithLabelValues(m.bucket(bucket), phase).Observe(d.Seconds())
+}
+
+// RecordHedge counts a hedged read; won reports whether the hedge answered first
+func (m *metricsExporter) RecordHedge(bucket, api string, won bool) {
+	if m == nil {
+		return
+	}
+	winner := "primary"
+	if won {
+		winner = "hedge"
+	}
+	m.hedgedRequestsTotal.WithLabelValues(m.bucket(bucket), api, winner).Inc()
+}
+
+// RecordUpload counts a Write upload by method
+func (m *metricsExporter) RecordUpload(bucket, method string) {
+	if m == nil {
+		return
+	}
+	m.uploadsTotal.WithLabelValues(m.bucket(bucket), method).Inc()
+}
+
+// RecordPresignCache counts a presigned URL cache lookup
+func (m *metricsExporter) RecordPresignCache(bucket string, hit bool) {
+	if m == nil {
+		return
+	}
+	status := "miss"
+	if hit {
+		status = "hit"
+	}
+	m.presignCacheTotal.WithLabelValues(m.bucket(bucket), status).Inc()
+}
+
+// RecordIndexUpdate counts an object index update
+func (m *metricsExporter) RecordIndexUpdate(bucket, status string) {
+	if m == nil {
+		return
+	}
+	m.indexUpdatesTotal.WithLabelValues(m.bucket(bucket), status).Inc()
+}
+
+// SetQuotaUsage records the measured share of a bucket quota in use
+func (m *metricsExporter) SetQuotaUsage(bucket string, ratio float64) {
+	if m == nil {
+		return
+	}
+	// Buckets sharing a label value would overwrite each other's ratio
+	if label := m.bucket(bucket); label == bucket {
+		m.quotaUsageRatio.WithLabelValues(label).Set(ratio)
+	}
+}
+
+// RecordQuotaAlert counts a crossed quota threshold
+func (m *metricsExporter) RecordQuotaAlert(bucket string, threshold float64) {
+	if m == nil {
+		return
+	}
+	m.quotaAlertsTotal.WithLabelValues(m.bucket(bucket), strconv.FormatFloat(threshold, 'f', -1, 64)).Inc()
+}
+
+// ObservePayload records the body size of an object read or write
+func (m *metricsExporter) ObservePayload(bucket, api string, size int64) {
+	if m == nil {
+		return
+	}
+	m.payloadSizeBytes.WithLabelValues(m.bucket(bucket), api).Observe(float64(size))
 }
 
-// getCollectors returns all Prometheus collectors for registration
+// getCollectors returns the Prometheus collectors of the enabled metric families for registration
 func (m *metricsExporter) getCollectors() []prometheus.Collector {
 	if m == nil {
 		return nil
 	}
-	return []prometheus.Collector{
+
+	// In the order of metricFamilies
+	all := []prometheus.Collector{
 		m.operationsTotal,
 		m.errorsTotal,
+		m.globalQueueWait,
+		m.slowOperationsTotal,
+		m.dedupHitsTotal,
+		m.dedupBytesSavedTotal,
+		m.requestsTotal,
+		m.egressBytesTotal,
+		m.retriesSuppressedTotal,
+		m.connectionPhaseSeconds,
+		m.hedgedRequestsTotal,
+		m.uploadsTotal,
+		m.presignCacheTotal,
+		m.indexUpdatesTotal,
+		m.quotaUsageRatio,
+		m.quotaAlertsTotal,
+		m.payloadSizeBytes,
+	}
+
+	collectors := make([]prometheus.Collector, 0, len(all))
+	for i, collector := range all {
+		if !m.disabled[metricFamilies[i]] {
+			collectors = append(collectors, collector)
+		}
 	}
+	return collectors
 }
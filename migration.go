@@ -0,0 +1,547 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// migrationJobType identifies prefix migration jobs
+	migrationJobType = "migration"
+
+	// migrationStateDir is the sub-directory of state_dir holding migration checkpoints
+	migrationStateDir = "migrations"
+
+	// migrationPageSize is the number of keys listed per request
+	migrationPageSize = 1000
+)
+
+// migrationCheckpoint is the persisted state used to resume a migration after a restart
+type migrationCheckpoint struct {
+	JobID     string           `json:"job_id"`
+	Request   MigrationRequest `json:"request"`
+	LastKey   string           `json:"last_key"`
+	Objects   int64            `json:"objects"`
+	Bytes     int64            `json:"bytes"`
+	Failed    int64            `json:"failed"`
+	UpdatedAt int64            `json:"updated_at"`
+
+	// PageToken resumes listings of directory buckets, which cannot start after LastKey;
+	// the objects of the interrupted page are migrated again
+	PageToken string `json:"page_token,omitempty"`
+}
+
+// StartMigration validates a migration request and launches it as an async job
+func (o *Operations) StartMigration(ctx context.Context, req *MigrationRequest, resp *StartJobResponse) error {
+	if err := o.validateMigrationRequest(req); err != nil {
+		o.plugin.metrics.RecordOperation(req.SourceBucket, "migrate", "error")
+		o.plugin.metrics.RecordError(req.SourceBucket, err.Code)
+		return err
+	}
+
+	// Check access to both prefixes
+	if err := o.authorizeIn(ctx, req.Caller, "StartMigration", "migrate", req.SourceBucket, req.SourcePrefix); err != nil {
+		return err
+	}
+	if err := o.authorizeIn(ctx, req.Caller, "StartMigration", "migrate", req.DestBucket, req.DestPrefix); err != nil {
+		return err
+	}
+
+	// Credentials are not persisted with the checkpoint; the caller metadata is, for the logs of a resumed job
+	checkpoint := &migrationCheckpoint{Request: *req}
+	checkpoint.Request.Caller.Token = ""
+
+	job, err := o.startMigrationJob(ctx, "", checkpoint)
+	if err != nil {
+		return asS3Error("start migration", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// ResumeMigrations restarts migrations that were interrupted by a previous shutdown
+func (o *Operations) ResumeMigrations() {
+	dir := o.migrationStatePath()
+	if dir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			o.log.Warn("failed to read migration checkpoints", zap.String("dir", dir), zap.Error(err))
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			o.log.Warn("failed to read migration checkpoint", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		var checkpoint migrationCheckpoint
+		if err := json.Unmarshal(data, &checkpoint); err != nil {
+			o.log.Warn("invalid migration checkpoint", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		ctx := withCallerMetadata(o.plugin.ctx, checkpoint.Request.Metadata)
+		if _, err := o.startMigrationJob(ctx, checkpoint.JobID, &checkpoint); err != nil {
+			o.log.Warn("failed to resume migration", zap.String("id", checkpoint.JobID), zap.Error(err))
+			continue
+		}
+
+		o.log.Info("migration resumed from checkpoint",
+			zap.String("id", checkpoint.JobID),
+			zap.String("last_key", checkpoint.LastKey),
+		)
+	}
+}
+
+// startMigrationJob launches the migration body for a fresh or restored checkpoint
+func (o *Operations) startMigrationJob(ctx context.Context, id string, checkpoint *migrationCheckpoint) (*Job, error) {
+	buckets := []string{o.plugin.buckets.resolve(checkpoint.Request.SourceBucket), o.plugin.buckets.resolve(checkpoint.Request.DestBucket)}
+	return o.plugin.jobs.Start(ctx, migrationJobType, id, buckets, checkpoint.Request, func(ctx context.Context, job *Job) error {
+		checkpoint.JobID = job.ID
+		job.Restore(checkpoint.Objects, checkpoint.Bytes, checkpoint.Failed, checkpoint.LastKey)
+
+		err := o.runMigration(ctx, job, checkpoint)
+		// Keep the checkpoint only when the job can be resumed later
+		if err == nil || job.Cancelled() {
+			o.removeMigrationCheckpoint(job.ID)
+		}
+		return err
+	})
+}
+
+// runMigration copies every object under the source prefix, honoring the schedule window and bandwidth budget
+func (o *Operations) runMigration(ctx context.Context, job *Job, checkpoint *migrationCheckpoint) error {
+	req := checkpoint.Request
+
+	var limiter *bandwidthLimiter
+	if req.BandwidthLimit > 0 {
+		limiter = newBandwidthLimiter(req.BandwidthLimit)
+	}
+
+	for {
+		if err := waitForWindow(ctx, job, req.WindowStart, req.WindowEnd); err != nil {
+			return err
+		}
+
+		sourceBucket, err := o.plugin.buckets.GetBucket(req.SourceBucket)
+		if err != nil {
+			return NewBucketNotFoundError(req.SourceBucket)
+		}
+
+		listPrefix := sourceBucket.GetFullPath(req.SourcePrefix)
+		input := &s3.ListObjectsV2Input{
+			Bucket:  aws.String(sourceBucket.Config.Bucket),
+			Prefix:  aws.String(listPrefix),
+			MaxKeys: aws.Int32(migrationPageSize),
+		}
+		if sourceBucket.Config.DirectoryBucket {
+			if checkpoint.PageToken != "" {
+				input.ContinuationToken = aws.String(checkpoint.PageToken)
+			}
+		} else if checkpoint.LastKey != "" {
+			input.StartAfter = aws.String(checkpoint.LastKey)
+		}
+
+		page, err := sourceBucket.Client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to list source objects: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			// Finish the current object, then pause until the next window
+			if err := waitForWindow(ctx, job, req.WindowStart, req.WindowEnd); err != nil {
+				return err
+			}
+
+			key := aws.ToString(obj.Key)
+			relative := strings.TrimPrefix(key, listPrefix)
+
+			// Blobs are migrated as the content of the pointers referencing them
+			if sourceBucket.Config.Dedup && strings.HasPrefix(key, sourceBucket.GetFullPath(sourceBucket.Config.DedupPrefix)) {
+				checkpoint.LastKey = key
+				continue
+			}
+
+			size, err := o.migrateObject(ctx, &req, key, relative, aws.ToInt64(obj.Size), limiter)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				loggerFor(ctx, o.log).Warn("failed to migrate object",
+					zap.String("job", job.ID),
+					zap.String("source_bucket", req.SourceBucket),
+					zap.String("key", key),
+					zap.Error(err),
+				)
+				o.plugin.metrics.RecordOperation(req.SourceBucket, "migrate", "error")
+				o.plugin.metrics.RecordError(req.SourceBucket, ErrS3Operation)
+				job.AddFailure(key, err)
+			} else {
+				o.plugin.metrics.RecordOperation(req.SourceBucket, "migrate", "success")
+				job.AddProgress(size)
+			}
+
+			checkpoint.LastKey = key
+			job.SetCheckpoint(key)
+			o.saveMigrationCheckpoint(job, checkpoint)
+		}
+
+		if page.IsTruncated == nil || !*page.IsTruncated {
+			return nil
+		}
+		if sourceBucket.Config.DirectoryBucket {
+			// Filtered pages of directory buckets may be empty before the listing ends
+			checkpoint.PageToken = aws.ToString(page.NextContinuationToken)
+			o.saveMigrationCheckpoint(job, checkpoint)
+		} else if len(page.Contents) == 0 {
+			return nil
+		}
+	}
+}
+
+// migrateObject streams one object of the listed size from the source to the destination and optionally deletes the source
+func (o *Operations) migrateObject(ctx context.Context, req *MigrationRequest, key, relative string, size int64, limiter *bandwidthLimiter) (int64, error) {
+	sourceBucket, err := o.plugin.buckets.GetBucket(req.SourceBucket)
+	if err != nil {
+		return 0, err
+	}
+
+	destBucket, err := o.plugin.buckets.GetBucket(req.DestBucket)
+	if err != nil {
+		return 0, err
+	}
+
+	// Pointer objects are read from their blob and keep their own content type and metadata
+	sourceKey := key
+	var pointer *s3.HeadObjectOutput
+	if sourceBucket.Config.Dedup {
+		if err := sourceBucket.Acquire(ctx); err != nil {
+			return 0, err
+		}
+		var source string
+		source, pointer, err = o.dedupResolve(ctx, sourceBucket, sourceBucket.Config.pathnameOf(key))
+		sourceBucket.Release()
+		if err != nil {
+			return 0, fmt.Errorf("head object: %w", err)
+		}
+		sourceKey = sourceBucket.ObjectKey(source)
+		if pointer != nil {
+			_, size = dedupPointer(pointer.Metadata)
+		}
+	}
+
+	if err := o.enforcePolicy(ctx, destBucket, "migrate", req.DestPrefix+relative, size); err != nil {
+		return 0, err
+	}
+
+	release, err := acquireBuckets(ctx, sourceBucket, destBucket)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	// Retained objects are neither copied nor deleted, so they stay in one place
+	if req.DeleteSource {
+		if err := o.checkRetention(ctx, sourceBucket, sourceBucket.Config.pathnameOf(key)); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := sourceBucket.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(sourceBucket.Config.Bucket),
+		Key:    aws.String(sourceKey),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("get object: %w", err)
+	}
+	defer result.Body.Close()
+
+	contentType, metadata := result.ContentType, result.Metadata
+	if pointer != nil {
+		contentType, metadata = pointer.ContentType, pointerMetadata(pointer.Metadata)
+	}
+
+	var body io.Reader = result.Body
+	if limiter != nil {
+		body = &rateLimitedReader{ctx: ctx, r: result.Body, limiter: limiter}
+	}
+
+	uploader := destBucket.NewUploader(aws.ToInt64(result.ContentLength))
+
+	destKey := destBucket.ObjectKey(req.DestPrefix + relative)
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(destBucket.Config.Bucket),
+		Key:          aws.String(destKey),
+		Body:         body,
+		ContentType:  contentType,
+		CacheControl: result.CacheControl,
+		Metadata:     metadata,
+	})
+	if err != nil {
+		o.abortInterruptedUpload(ctx, destBucket, destKey, err)
+		return 0, fmt.Errorf("upload: %w", err)
+	}
+	destBucket.filter.add(destKey)
+	o.plugin.index.update(destBucket, req.DestPrefix+relative)
+
+	if req.DeleteSource {
+		if _, err := sourceBucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(sourceBucket.Config.Bucket),
+			Key:    aws.String(key),
+		}); err != nil {
+			return 0, fmt.Errorf("copy succeeded but delete failed: %w", err)
+		}
+		o.plugin.index.remove(sourceBucket, sourceBucket.Config.pathnameOf(key))
+	}
+
+	return aws.ToInt64(result.ContentLength), nil
+}
+
+// validateMigrationRequest checks buckets, prefixes and schedule of a migration request
+func (o *Operations) validateMigrationRequest(req *MigrationRequest) *S3Error {
+	sourceBucket, err := o.plugin.buckets.GetBucket(req.SourceBucket)
+	if err != nil {
+		return NewBucketNotFoundError(req.SourceBucket)
+	}
+
+	destBucket, err := o.plugin.buckets.GetBucket(req.DestBucket)
+	if err != nil {
+		return NewBucketNotFoundError(req.DestBucket)
+	}
+
+	// Source objects are listed by prefix
+	if err := sourceBucket.requireUnsharded("migration"); err != nil {
+		return err
+	}
+
+	if err := destBucket.requireAppendOnly("StartMigration"); err != nil {
+		return err
+	}
+	if req.DeleteSource {
+		if err := sourceBucket.requireAppendOnly("StartMigration"); err != nil {
+			return err
+		}
+	}
+
+	if strings.Contains(req.SourcePrefix, "..") || strings.Contains(req.DestPrefix, "..") {
+		return NewInvalidRequestError("prefixes cannot contain '..'")
+	}
+
+	if sourceBucket == destBucket && strings.HasPrefix(req.DestPrefix, req.SourcePrefix) {
+		return NewInvalidRequestError("destination prefix cannot be inside the source prefix of the same bucket")
+	}
+
+	if err := destBucket.checkBlobPrefix(req.DestPrefix); err != nil {
+		return err
+	}
+
+	if req.BandwidthLimit < 0 {
+		return NewInvalidRequestError("bandwidth_limit cannot be negative")
+	}
+
+	if (req.WindowStart == "") != (req.WindowEnd == "") {
+		return NewInvalidRequestError("window_start and window_end must be set together")
+	}
+
+	if req.WindowStart != "" {
+		if _, err := parseClock(req.WindowStart); err != nil {
+			return NewInvalidRequestError("window_start: " + err.Error())
+		}
+		if _, err := parseClock(req.WindowEnd); err != nil {
+			return NewInvalidRequestError("window_end: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// migrationStatePath returns the checkpoint directory, or empty when persistence is disabled
+func (o *Operations) migrationStatePath() string {
+	if o.plugin.stateDir == "" {
+		return ""
+	}
+	return filepath.Join(o.plugin.stateDir, migrationStateDir)
+}
+
+// saveMigrationCheckpoint persists the checkpoint atomically (write + rename)
+func (o *Operations) saveMigrationCheckpoint(job *Job, checkpoint *migrationCheckpoint) {
+	dir := o.migrationStatePath()
+	if dir == "" {
+		return
+	}
+
+	checkpoint.Objects, checkpoint.Bytes, checkpoint.Failed = job.Progress()
+	checkpoint.UpdatedAt = time.Now().Unix()
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		o.log.Warn("failed to encode migration checkpoint", zap.String("id", job.ID), zap.Error(err))
+		return
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, job.ID+".json"), data); err != nil {
+		o.log.Warn("failed to save migration checkpoint", zap.String("id", job.ID), zap.Error(err))
+	}
+}
+
+// removeMigrationCheckpoint deletes the checkpoint of a finished migration
+func (o *Operations) removeMigrationCheckpoint(id string) {
+	dir := o.migrationStatePath()
+	if dir == "" {
+		return
+	}
+
+	if err := os.Remove(filepath.Join(dir, id+".json")); err != nil && !os.IsNotExist(err) {
+		o.log.Warn("failed to remove migration checkpoint", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over the target
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// parseClock parses a "HH:MM" time of day into minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got '%s'", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// waitForWindow blocks until the current local time is inside the [start, end) window
+func waitForWindow(ctx context.Context, job *Job, start, end string) error {
+	if start == "" {
+		return ctx.Err()
+	}
+
+	// Validated when the job was created
+	startMin, _ := parseClock(start)
+	endMin, _ := parseClock(end)
+
+	for {
+		now := time.Now()
+		minute := now.Hour()*60 + now.Minute()
+
+		var inside bool
+		switch {
+		case startMin == endMin:
+			inside = true
+		case startMin < endMin:
+			inside = minute >= startMin && minute < endMin
+		default:
+			// Window crosses midnight, e.g. 22:00-06:00
+			inside = minute >= startMin || minute < endMin
+		}
+
+		if inside {
+			if job.Status() == JobWaiting {
+				job.SetStatus(JobRunning, "")
+			}
+			return ctx.Err()
+		}
+
+		job.SetStatus(JobWaiting, fmt.Sprintf("waiting for window %s-%s", start, end))
+
+		// Re-check every minute; the window has minute granularity
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Minute - time.Duration(now.Second())*time.Second):
+		}
+	}
+}
+
+// bandwidthLimiter paces reads to a maximum number of bytes per second
+type bandwidthLimiter struct {
+	rate int64
+	next time.Time
+	mu   sync.Mutex
+}
+
+// newBandwidthLimiter creates a limiter for the given bytes per second
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	return &bandwidthLimiter{rate: bytesPerSecond}
+}
+
+// wait blocks until n more bytes may be transferred
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitedReader applies a bandwidth limiter to an underlying reader
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+// Read reads at most 32KB at a time and waits for the limiter before returning
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
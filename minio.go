@@ -0,0 +1,177 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// AnonymousAccessNone removes the anonymous access policy
+	AnonymousAccessNone = "none"
+
+	// AnonymousAccessDownload lets anyone list and read objects under the bucket prefix
+	AnonymousAccessDownload = "download"
+
+	// AnonymousAccessUpload lets anyone write objects under the bucket prefix
+	AnonymousAccessUpload = "upload"
+
+	// AnonymousAccessPublic combines download and upload
+	AnonymousAccessPublic = "public"
+)
+
+// MinIOSetupConfig provisions a bucket on a MinIO server when it is registered,
+// so local and dev environments need no manual `mc` setup
+type MinIOSetupConfig struct {
+	// CreateBucket creates the bucket when it does not exist
+	CreateBucket bool `mapstructure:"create_bucket" json:"create_bucket"`
+
+	// AnonymousAccess sets the anonymous policy: "download", "upload", "public" or "none";
+	// empty leaves the current policy untouched
+	AnonymousAccess string `mapstructure:"anonymous_access" json:"anonymous_access,omitempty"`
+
+	// Notifications replace the bucket notification configuration (optional)
+	Notifications []MinIONotification `mapstructure:"notifications" json:"notifications,omitempty"`
+}
+
+// MinIONotification sends bucket events to a notification target configured on the MinIO server
+type MinIONotification struct {
+	// ARN is the target ARN reported by `mc admin info --json` (e.g., "arn:minio:sqs::primary:webhook")
+	ARN string `mapstructure:"arn" json:"arn"`
+
+	// Events are the S3 event types (default: "s3:ObjectCreated:*" and "s3:ObjectRemoved:*")
+	Events []string `mapstructure:"events" json:"events"`
+
+	// Prefix limits events to keys under the prefix, relative to the bucket prefix (optional)
+	Prefix string `mapstructure:"prefix" json:"prefix,omitempty"`
+
+	// Suffix limits events to keys with the suffix, e.g. ".jpg" (optional)
+	Suffix string `mapstructure:"suffix" json:"suffix,omitempty"`
+}
+
+// Validate validates the setup configuration and applies defaults
+func (mc *MinIOSetupConfig) Validate() error {
+	switch mc.AnonymousAccess {
+	case "", AnonymousAccessNone, AnonymousAccessDownload, AnonymousAccessUpload, AnonymousAccessPublic:
+	default:
+		return fmt.Errorf("minio.anonymous_access must be '%s', '%s', '%s' or '%s', got '%s'",
+			AnonymousAccessNone, AnonymousAccessDownload, AnonymousAccessUpload, AnonymousAccessPublic, mc.AnonymousAccess)
+	}
+
+	for i := range mc.Notifications {
+		n := &mc.Notifications[i]
+		if !strings.HasPrefix(n.ARN, "arn:minio:sqs:") {
+			return fmt.Errorf("minio.notifications[%d].arn must be a MinIO target ARN (arn:minio:sqs::<id>:<type>)", i)
+		}
+		if len(n.Events) == 0 {
+			n.Events = []string{string(types.EventS3ObjectCreated), string(types.EventS3ObjectRemoved)}
+		}
+		for _, event := range n.Events {
+			if !strings.HasPrefix(event, "s3:") {
+				return fmt.Errorf("minio.notifications[%d] has invalid event '%s'", i, event)
+			}
+		}
+	}
+
+	return nil
+}
+
+// setupMinIO creates the bucket and applies its anonymous policy and notifications
+func (bm *BucketManager) setupMinIO(ctx context.Context, bucket *Bucket) error {
+	mc := bucket.Config.MinIO
+	name := aws.String(bucket.Config.Bucket)
+
+	if mc.CreateBucket && !bucket.Config.CreateIfMissing {
+		if err := bm.createBucketIfMissing(ctx, bucket); err != nil {
+			return err
+		}
+	}
+
+	switch mc.AnonymousAccess {
+	case "":
+	case AnonymousAccessNone:
+		if _, err := bucket.Client.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{Bucket: name}); err != nil {
+			return fmt.Errorf("failed to remove anonymous policy: %w", err)
+		}
+	default:
+		policy, err := anonymousPolicy(bucket.Config.Bucket, bucket.GetFullPath(""), mc.AnonymousAccess)
+		if err != nil {
+			return err
+		}
+		if _, err := bucket.Client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{Bucket: name, Policy: aws.String(policy)}); err != nil {
+			return fmt.Errorf("failed to set anonymous policy: %w", err)
+		}
+	}
+
+	if len(mc.Notifications) > 0 {
+		notifications := make([]BucketNotification, len(mc.Notifications))
+		for i, n := range mc.Notifications {
+			notifications[i] = BucketNotification{Target: n.ARN, Events: n.Events, Prefix: n.Prefix, Suffix: n.Suffix}
+		}
+
+		if _, err := bucket.Client.PutBucketNotificationConfiguration(ctx, &s3.PutBucketNotificationConfigurationInput{
+			Bucket:                    name,
+			NotificationConfiguration: notificationConfiguration(bucket, notifications, false),
+		}); err != nil {
+			return fmt.Errorf("failed to configure notifications: %w", err)
+		}
+	}
+
+	bm.log.Debug("minio bucket provisioned",
+		zap.String("bucket", bucket.Name),
+		zap.String("anonymous_access", mc.AnonymousAccess),
+		zap.Int("notifications", len(mc.Notifications)),
+	)
+
+	return nil
+}
+
+// anonymousPolicy builds the bucket policy `mc anonymous set` would apply to prefix
+func anonymousPolicy(bucketName, prefix, access string) (string, error) {
+	type statement struct {
+		Effect    string         `json:"Effect"`
+		Principal map[string]any `json:"Principal"`
+		Action    []string       `json:"Action"`
+		Resource  []string       `json:"Resource"`
+		Condition map[string]any `json:"Condition,omitempty"`
+	}
+
+	bucketARN := "arn:aws:s3:::" + bucketName
+	objectsARN := bucketARN + "/" + prefix + "*"
+	anyone := map[string]any{"AWS": []string{"*"}}
+
+	var statements []statement
+	bucketActions := []string{"s3:GetBucketLocation"}
+	var objectActions []string
+
+	if access == AnonymousAccessDownload || access == AnonymousAccessPublic {
+		objectActions = append(objectActions, "s3:GetObject")
+		listing := statement{Effect: "Allow", Principal: anyone, Action: []string{"s3:ListBucket"}, Resource: []string{bucketARN}}
+		if prefix != "" {
+			listing.Condition = map[string]any{"StringLike": map[string]any{"s3:prefix": []string{prefix + "*"}}}
+		}
+		statements = append(statements, listing)
+	}
+
+	if access == AnonymousAccessUpload || access == AnonymousAccessPublic {
+		bucketActions = append(bucketActions, "s3:ListBucketMultipartUploads")
+		objectActions = append(objectActions, "s3:PutObject", "s3:AbortMultipartUpload", "s3:DeleteObject", "s3:ListMultipartUploadParts")
+	}
+
+	statements = append(statements,
+		statement{Effect: "Allow", Principal: anyone, Action: bucketActions, Resource: []string{bucketARN}},
+		statement{Effect: "Allow", Principal: anyone, Action: objectActions, Resource: []string{objectsARN}},
+	)
+
+	policy, err := json.Marshal(map[string]any{"Version": "2012-10-17", "Statement": statements})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode anonymous policy: %w", err)
+	}
+	return string(policy), nil
+}
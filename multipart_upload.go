@@ -0,0 +1,849 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// uploadStateDir is the sub-directory of state_dir holding multipart upload state
+	uploadStateDir = "uploads"
+
+	// maxUploadParts is the S3 limit of parts per multipart upload
+	maxUploadParts = 10000
+)
+
+// UploadManager tracks client-driven multipart uploads and persists their state
+type UploadManager struct {
+	// Map of upload ID to upload state
+	uploads map[string]*multipartUpload
+
+	// Directory for persisted state (empty disables persistence)
+	dir string
+
+	// Encrypts persisted session URLs (nil stores them in plain text)
+	box *secretBox
+
+	// Logger
+	log *zap.Logger
+
+	// Mutex for thread-safe access
+	mu sync.RWMutex
+}
+
+// multipartUpload is the persisted state of a single multipart upload
+type multipartUpload struct {
+	ID         string         `json:"id"`
+	Bucket     string         `json:"bucket"`
+	Pathname   string         `json:"pathname"`
+	Key        string         `json:"key"`
+	S3UploadID string         `json:"s3_upload_id"`
+	Parts      []uploadedPart `json:"parts"`
+	CreatedAt  int64          `json:"created_at"`
+	PartSize   int64          `json:"part_size,omitempty"` // Recommended part size, 0 for the bucket default
+
+	// SessionURL is the resumable session replacing S3UploadID on servers with resumable_put. It
+	// authorizes uploads on its own, like a presigned URL.
+	SessionURL string `json:"session_url,omitempty"`
+	Size       int64  `json:"size,omitempty"`      // Expected size; the part reaching it finalizes a resumable upload
+	Finalized  bool   `json:"finalized,omitempty"` // The resumable session received its last part
+	ETag       string `json:"etag,omitempty"`      // ETag of a finalized resumable upload
+
+	// Highest part number handed out to a part sent without one, possibly still uploading
+	reserved int32
+
+	// Serializes part bookkeeping and persistence
+	mu sync.Mutex
+}
+
+// persistedUpload is the on-disk format of an upload. With a state encryption key the session URL
+// is stored sealed instead of in plain text.
+type persistedUpload struct {
+	*multipartUpload
+
+	SessionURL       string `json:"session_url,omitempty"`
+	SealedSessionURL string `json:"sealed_session_url,omitempty"`
+}
+
+// uploadedPart describes a successfully uploaded part
+type uploadedPart struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// NewUploadManager creates a new upload manager persisting state under stateDir. Session URLs are
+// encrypted with encryptionKey unless it is empty.
+func NewUploadManager(stateDir string, encryptionKey string, log *zap.Logger) (*UploadManager, error) {
+	um := &UploadManager{
+		uploads: make(map[string]*multipartUpload),
+		log:     log,
+	}
+	if stateDir != "" {
+		um.dir = filepath.Join(stateDir, uploadStateDir)
+	}
+
+	if encryptionKey != "" {
+		box, err := newSecretBox(encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		um.box = box
+	}
+	return um, nil
+}
+
+// Load restores uploads persisted by a previous plugin run
+func (um *UploadManager) Load() {
+	if um.dir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(um.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			um.log.Warn("failed to read multipart upload state", zap.String("dir", um.dir), zap.Error(err))
+		}
+		return
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(um.dir, entry.Name()))
+		if err != nil {
+			um.log.Warn("failed to read multipart upload state", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		upload := &multipartUpload{}
+		state := persistedUpload{multipartUpload: upload}
+		if err := json.Unmarshal(data, &state); err != nil {
+			um.log.Warn("invalid multipart upload state", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		upload.SessionURL = state.SessionURL
+		if state.SealedSessionURL != "" {
+			if um.box == nil {
+				um.log.Warn("multipart upload state is encrypted but state_encryption is not configured", zap.String("file", entry.Name()))
+				continue
+			}
+			session, err := um.box.open(state.SealedSessionURL)
+			if err != nil {
+				um.log.Warn("failed to decrypt multipart upload state", zap.String("file", entry.Name()), zap.Error(err))
+				continue
+			}
+			upload.SessionURL = string(session)
+		}
+
+		um.uploads[upload.ID] = upload
+	}
+
+	if len(um.uploads) > 0 {
+		um.log.Info("restored multipart uploads", zap.Int("count", len(um.uploads)))
+	}
+}
+
+// add registers and persists a new upload
+func (um *UploadManager) add(upload *multipartUpload) {
+	um.mu.Lock()
+	um.uploads[upload.ID] = upload
+	um.mu.Unlock()
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+	um.persistLocked(upload)
+}
+
+// get retrieves an upload by ID
+func (um *UploadManager) get(id string) (*multipartUpload, bool) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	upload, exists := um.uploads[id]
+	return upload, exists
+}
+
+// list returns all tracked uploads ordered by creation time
+func (um *UploadManager) list() []*multipartUpload {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	uploads := make([]*multipartUpload, 0, len(um.uploads))
+	for _, upload := range um.uploads {
+		uploads = append(uploads, upload)
+	}
+
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].CreatedAt < uploads[j].CreatedAt
+	})
+
+	return uploads
+}
+
+// remove forgets an upload and deletes its persisted state
+func (um *UploadManager) remove(id string) {
+	um.mu.Lock()
+	delete(um.uploads, id)
+	um.mu.Unlock()
+
+	if um.dir == "" {
+		return
+	}
+
+	if err := os.Remove(filepath.Join(um.dir, id+".json")); err != nil && !os.IsNotExist(err) {
+		um.log.Warn("failed to remove multipart upload state", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// persistLocked writes the upload state to disk; caller must hold upload.mu
+func (um *UploadManager) persistLocked(upload *multipartUpload) {
+	if um.dir == "" {
+		return
+	}
+
+	state := persistedUpload{multipartUpload: upload, SessionURL: upload.SessionURL}
+	if um.box != nil && upload.SessionURL != "" {
+		sealed, err := um.box.seal([]byte(upload.SessionURL))
+		if err != nil {
+			um.log.Warn("failed to encrypt multipart upload state", zap.String("id", upload.ID), zap.Error(err))
+			return
+		}
+		state.SessionURL, state.SealedSessionURL = "", sealed
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		um.log.Warn("failed to encode multipart upload state", zap.String("id", upload.ID), zap.Error(err))
+		return
+	}
+
+	if err := writeFileAtomic(filepath.Join(um.dir, upload.ID+".json"), data); err != nil {
+		um.log.Warn("failed to save multipart upload state", zap.String("id", upload.ID), zap.Error(err))
+	}
+}
+
+// setPartLocked records a part, replacing a previous upload of the same number; caller must hold upload.mu
+func (u *multipartUpload) setPartLocked(part uploadedPart) {
+	for i := range u.Parts {
+		if u.Parts[i].Number == part.Number {
+			u.Parts[i] = part
+			return
+		}
+	}
+
+	u.Parts = append(u.Parts, part)
+	sort.Slice(u.Parts, func(i, j int) bool {
+		return u.Parts[i].Number < u.Parts[j].Number
+	})
+}
+
+// infoLocked builds the RPC representation of the upload; caller must hold upload.mu
+func (u *multipartUpload) infoLocked() MultipartUploadInfo {
+	info := MultipartUploadInfo{
+		UploadID:       u.ID,
+		Bucket:         u.Bucket,
+		Pathname:       u.Pathname,
+		Parts:          make([]MultipartPartInfo, 0, len(u.Parts)),
+		NextPartNumber: 1,
+		CreatedAt:      u.CreatedAt,
+		Resumable:      u.resumable(),
+	}
+
+	for _, part := range u.Parts {
+		info.Parts = append(info.Parts, MultipartPartInfo{
+			PartNumber: part.Number,
+			ETag:       part.ETag,
+			Size:       part.Size,
+		})
+		info.UploadedBytes += part.Size
+		if part.Number >= info.NextPartNumber {
+			info.NextPartNumber = part.Number + 1
+		}
+	}
+	if u.reserved >= info.NextPartNumber {
+		info.NextPartNumber = u.reserved + 1
+	}
+
+	return info
+}
+
+// reservePartLocked returns the number of a part sent without one. S3 parts may arrive concurrently,
+// so the number is reserved at once; resumable sessions take parts in order and reserve nothing.
+// Caller must hold upload.mu.
+func (u *multipartUpload) reservePartLocked() int32 {
+	next := u.infoLocked().NextPartNumber
+	if !u.resumable() {
+		u.reserved = next
+	}
+	return next
+}
+
+// StartMultipartUpload creates a multipart upload whose parts are sent by the client one by one
+func (o *Operations) StartMultipartUpload(ctx context.Context, req *StartMultipartUploadRequest, resp *MultipartUploadInfo) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	if err := o.route(&req.Bucket, req.Pathname, "multipart_start"); err != nil {
+		return err
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "StartMultipartUpload", "multipart_start", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := bucket.requireAppendOnly("StartMultipartUpload"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+	if err := bucket.checkBlobPathname(req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	if !bucket.Capabilities.Multipart && !bucket.Capabilities.ResumablePut {
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrNotSupported)
+		return NewNotSupportedError("multipart upload", req.Bucket)
+	}
+
+	if err := req.Transfer.validate(); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	metadata, err := bucket.Config.encodeMetadata(req.Config)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+	req.Config = metadata
+
+	// The size is checked again on completion, when the parts are known
+	if err := o.enforcePolicy(ctx, bucket, "multipart_start", req.Pathname, max(req.Size, 0)); err != nil {
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	key := bucket.ObjectKey(req.Pathname)
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = o.detectContentType(req.Pathname, nil)
+	}
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(bucket.Config.Bucket),
+		Key:          aws.String(key),
+		ACL:          bucket.ObjectACL(req.Visibility),
+		ContentType:  aws.String(contentType),
+		StorageClass: types.StorageClass(req.StorageClass),
+	}
+	if len(req.Config) > 0 {
+		input.Metadata = req.Config
+	}
+
+	// Servers with resumable PUT append parts to one session instead of tracking S3 parts
+	var uploadID, session string
+	if bucket.Capabilities.ResumablePut {
+		session, err = o.startResumableUpload(ctx, bucket, input)
+	} else {
+		var result *s3.CreateMultipartUploadOutput
+		if result, err = bucket.Client.CreateMultipartUpload(ctx, input); err == nil {
+			uploadID = aws.ToString(result.UploadId)
+		}
+	}
+	if err != nil {
+		o.log.Error("failed to create multipart upload",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("create multipart upload", err)
+	}
+
+	id, err := newRandomID()
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("create multipart upload", err)
+	}
+
+	upload := &multipartUpload{
+		ID:         id,
+		Bucket:     bucket.Name,
+		Pathname:   req.Pathname,
+		Key:        key,
+		S3UploadID: uploadID,
+		SessionURL: session,
+		Size:       req.Size,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if req.Size > 0 {
+		upload.PartSize = bucket.Config.PartSizeFor(req.Size)
+	}
+	if req.Transfer.PartSize > 0 {
+		upload.PartSize = req.Transfer.PartSize
+		if req.Size > 0 {
+			upload.PartSize = max(req.Transfer.PartSize, bucket.Config.requiredPartSize(req.Size))
+		}
+	}
+	if session != "" && upload.PartSize > 0 {
+		upload.PartSize = alignResumableChunk(upload.PartSize)
+	}
+	o.plugin.uploads.add(upload)
+
+	upload.mu.Lock()
+	*resp = upload.infoLocked()
+	upload.mu.Unlock()
+	resp.PartSize = upload.PartSize
+	if resp.PartSize == 0 {
+		resp.PartSize = bucket.Config.PartSize
+		if session != "" {
+			resp.PartSize = alignResumableChunk(resp.PartSize)
+		}
+	}
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "multipart_start", "success")
+
+	o.log.Debug("multipart upload started",
+		zap.String("upload_id", id),
+		zap.String("bucket", req.Bucket),
+		zap.String("pathname", req.Pathname),
+	)
+
+	return nil
+}
+
+// UploadPart uploads a single part of a multipart upload
+func (o *Operations) UploadPart(ctx context.Context, req *UploadPartRequest, resp *UploadPartResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	upload, exists := o.plugin.uploads.get(req.UploadID)
+	if !exists {
+		return NewUploadNotFoundError(req.UploadID)
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(upload.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_part", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(upload.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "UploadPart", "multipart_part", bucket.Name, upload.Pathname); err != nil {
+		return err
+	}
+
+	partNumber := req.PartNumber
+	if partNumber == 0 {
+		upload.mu.Lock()
+		partNumber = upload.reservePartLocked()
+		upload.mu.Unlock()
+	}
+
+	if partNumber < 1 || partNumber > maxUploadParts {
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_part", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("part_number must be between 1 and %d", maxUploadParts))
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	if upload.resumable() {
+		return o.uploadResumablePart(ctx, bucket, upload, partNumber, req.Content, resp)
+	}
+
+	result, err := bucket.Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucket.Config.Bucket),
+		Key:        aws.String(upload.Key),
+		UploadId:   aws.String(upload.S3UploadID),
+		PartNumber: aws.Int32(partNumber),
+		Body:       bytes.NewReader(req.Content),
+	})
+	if err != nil {
+		o.log.Error("failed to upload part",
+			zap.String("upload_id", upload.ID),
+			zap.String("bucket", upload.Bucket),
+			zap.String("pathname", upload.Pathname),
+			zap.Int32("part_number", partNumber),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_part", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrS3Operation)
+		return NewS3OperationError("upload part", err)
+	}
+
+	part := uploadedPart{
+		Number: partNumber,
+		ETag:   aws.ToString(result.ETag),
+		Size:   int64(len(req.Content)),
+	}
+
+	upload.mu.Lock()
+	upload.setPartLocked(part)
+	o.plugin.uploads.persistLocked(upload)
+	next := upload.infoLocked().NextPartNumber
+	upload.mu.Unlock()
+
+	resp.PartNumber = partNumber
+	resp.ETag = part.ETag
+	resp.NextPartNumber = next
+
+	o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_part", "success")
+
+	return nil
+}
+
+// GetMultipartUpload returns the state of an upload, reconciled with the parts S3 actually holds
+func (o *Operations) GetMultipartUpload(ctx context.Context, req *MultipartUploadRequest, resp *MultipartUploadInfo) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	upload, exists := o.plugin.uploads.get(req.UploadID)
+	if !exists {
+		return NewUploadNotFoundError(req.UploadID)
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(upload.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_get", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(upload.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "GetMultipartUpload", "multipart_get", bucket.Name, upload.Pathname); err != nil {
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	var parts []uploadedPart
+	if upload.resumable() {
+		parts, err = o.resumableParts(ctx, bucket, upload)
+	} else {
+		parts, err = o.listUploadedParts(ctx, bucket, upload)
+	}
+	if err != nil {
+		var nsu *types.NoSuchUpload
+		if errors.As(err, &nsu) || errors.Is(err, errResumableSessionGone) {
+			// The upload was completed or aborted outside of the plugin
+			o.plugin.uploads.remove(upload.ID)
+			return NewUploadNotFoundError(req.UploadID)
+		}
+		o.log.Error("failed to list uploaded parts",
+			zap.String("upload_id", upload.ID),
+			zap.String("bucket", upload.Bucket),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_get", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrS3Operation)
+		return NewS3OperationError("list parts", err)
+	}
+
+	upload.mu.Lock()
+	upload.Parts = parts
+	o.plugin.uploads.persistLocked(upload)
+	*resp = upload.infoLocked()
+	upload.mu.Unlock()
+	resp.PartSize = upload.PartSize
+	if resp.PartSize == 0 {
+		resp.PartSize = bucket.Config.PartSize
+		if upload.resumable() {
+			resp.PartSize = alignResumableChunk(resp.PartSize)
+		}
+	}
+
+	o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_get", "success")
+
+	return nil
+}
+
+// ListMultipartUploads returns all uploads tracked by the plugin
+func (o *Operations) ListMultipartUploads(req *ListMultipartUploadsRequest, resp *ListMultipartUploadsResponse) error {
+	// Check access
+	if err := o.authorize(o.plugin.ctx, req.Caller, "ListMultipartUploads", "multipart_list", ""); err != nil {
+		return err
+	}
+
+	// Uploads record the bucket name, so resolve aliases
+	if req.Bucket != "" {
+		if bucket, err := o.plugin.buckets.GetBucket(req.Bucket); err == nil {
+			req.Bucket = bucket.Name
+		}
+	}
+
+	uploads := o.plugin.uploads.list()
+
+	resp.Uploads = make([]MultipartUploadInfo, 0, len(uploads))
+	for _, upload := range uploads {
+		if req.Bucket != "" && upload.Bucket != req.Bucket {
+			continue
+		}
+
+		// Only list uploads the caller may access
+		if !o.plugin.access.Allows(req.Caller, "ListMultipartUploads", upload.Bucket, upload.Pathname) {
+			continue
+		}
+
+		upload.mu.Lock()
+		resp.Uploads = append(resp.Uploads, upload.infoLocked())
+		upload.mu.Unlock()
+	}
+
+	return nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the final object
+func (o *Operations) CompleteMultipartUpload(ctx context.Context, req *MultipartUploadRequest, resp *WriteResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	upload, exists := o.plugin.uploads.get(req.UploadID)
+	if !exists {
+		return NewUploadNotFoundError(req.UploadID)
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(upload.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_complete", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(upload.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "CompleteMultipartUpload", "multipart_complete", bucket.Name, upload.Pathname); err != nil {
+		return err
+	}
+
+	upload.mu.Lock()
+	completed := make([]types.CompletedPart, 0, len(upload.Parts))
+	var size int64
+	for _, part := range upload.Parts {
+		completed = append(completed, types.CompletedPart{
+			PartNumber: aws.Int32(part.Number),
+			ETag:       aws.String(part.ETag),
+		})
+		size += part.Size
+	}
+	upload.mu.Unlock()
+
+	if len(completed) == 0 {
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_complete", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("upload has no parts")
+	}
+
+	// The upload stays open, so it can still be aborted
+	if err := o.enforcePolicy(ctx, bucket, "multipart_complete", upload.Pathname, size); err != nil {
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	if upload.resumable() {
+		err = o.finalizeResumable(ctx, bucket, upload, size)
+	} else {
+		_, err = bucket.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(bucket.Config.Bucket),
+			Key:             aws.String(upload.Key),
+			UploadId:        aws.String(upload.S3UploadID),
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		})
+	}
+	if err != nil {
+		if errors.Is(err, errResumableSessionGone) {
+			o.plugin.uploads.remove(upload.ID)
+			return NewUploadNotFoundError(req.UploadID)
+		}
+		o.log.Error("failed to complete multipart upload",
+			zap.String("upload_id", upload.ID),
+			zap.String("bucket", upload.Bucket),
+			zap.String("pathname", upload.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_complete", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrS3Operation)
+		return NewS3OperationError("complete multipart upload", err)
+	}
+
+	bucket.filter.add(upload.Key)
+	o.invalidateDerived(ctx, bucket, upload.Pathname)
+	o.plugin.index.update(bucket, upload.Pathname)
+
+	o.plugin.uploads.remove(upload.ID)
+
+	resp.Success = true
+	resp.Pathname = upload.Pathname
+	resp.Size = size
+	resp.LastModified = time.Now().Unix()
+
+	o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_complete", "success")
+
+	o.log.Debug("multipart upload completed",
+		zap.String("upload_id", upload.ID),
+		zap.String("bucket", upload.Bucket),
+		zap.String("pathname", upload.Pathname),
+		zap.Int64("size", size),
+	)
+
+	return nil
+}
+
+// AbortMultipartUpload aborts an upload and discards its uploaded parts
+func (o *Operations) AbortMultipartUpload(ctx context.Context, req *MultipartUploadRequest, resp *DeleteResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	upload, exists := o.plugin.uploads.get(req.UploadID)
+	if !exists {
+		return NewUploadNotFoundError(req.UploadID)
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(upload.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_abort", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(upload.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "AbortMultipartUpload", "multipart_abort", bucket.Name, upload.Pathname); err != nil {
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	if upload.resumable() {
+		err = cancelResumable(ctx, bucket, upload.SessionURL)
+	} else {
+		_, err = bucket.Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket.Config.Bucket),
+			Key:      aws.String(upload.Key),
+			UploadId: aws.String(upload.S3UploadID),
+		})
+	}
+	if err != nil {
+		var nsu *types.NoSuchUpload
+		if !errors.As(err, &nsu) && !errors.Is(err, errResumableSessionGone) {
+			o.log.Error("failed to abort multipart upload",
+				zap.String("upload_id", upload.ID),
+				zap.String("bucket", upload.Bucket),
+				zap.String("pathname", upload.Pathname),
+				zap.Error(err),
+			)
+			o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_abort", "error")
+			o.plugin.metrics.RecordError(upload.Bucket, ErrS3Operation)
+			return NewS3OperationError("abort multipart upload", err)
+		}
+	}
+
+	o.plugin.uploads.remove(upload.ID)
+
+	resp.Success = true
+	o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_abort", "success")
+
+	return nil
+}
+
+// listUploadedParts pages through ListParts to get the authoritative part list
+func (o *Operations) listUploadedParts(ctx context.Context, bucket *Bucket, upload *multipartUpload) ([]uploadedPart, error) {
+	parts := make([]uploadedPart, 0)
+
+	paginator := s3.NewListPartsPaginator(bucket.Client, &s3.ListPartsInput{
+		Bucket:   aws.String(bucket.Config.Bucket),
+		Key:      aws.String(upload.Key),
+		UploadId: aws.String(upload.S3UploadID),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range page.Parts {
+			parts = append(parts, uploadedPart{
+				Number: aws.ToInt32(part.PartNumber),
+				ETag:   aws.ToString(part.ETag),
+				Size:   aws.ToInt64(part.Size),
+			})
+		}
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,203 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// Target services, taken from the service field of the target ARN
+	notificationQueue  = "sqs"
+	notificationTopic  = "sns"
+	notificationLambda = "lambda"
+
+	// maxBucketNotifications bounds the notifications of one configuration
+	maxBucketNotifications = 100
+)
+
+// BucketNotification sends bucket events to an SQS queue, SNS topic, Lambda function or MinIO target
+type BucketNotification struct {
+	// ID names the configuration; S3 generates one when empty
+	ID string `json:"id,omitempty"`
+
+	// Target is the ARN of the queue, topic or function. MinIO targets, webhooks included, use the
+	// ARN reported by `mc admin info --json` (e.g., "arn:minio:sqs::primary:webhook").
+	Target string `json:"target"`
+
+	// Events are the S3 event types (default: "s3:ObjectCreated:*" and "s3:ObjectRemoved:*")
+	Events []string `json:"events,omitempty"`
+
+	// Prefix limits events to keys under the prefix, relative to the bucket prefix (optional)
+	Prefix string `json:"prefix,omitempty"`
+
+	// Suffix limits events to keys with the suffix, e.g. ".jpg" (optional)
+	Suffix string `json:"suffix,omitempty"`
+}
+
+// service returns the service of the target ARN, or empty when it is not a supported target
+func (n *BucketNotification) service() string {
+	// arn:partition:service:region:account:resource
+	parts := strings.SplitN(n.Target, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+
+	switch parts[2] {
+	case notificationQueue, notificationTopic, notificationLambda:
+		return parts[2]
+	}
+	return ""
+}
+
+// validateNotifications checks the targets and events of notifications and applies defaults
+func validateNotifications(bucket *Bucket, notifications []BucketNotification) *S3Error {
+	if len(notifications) > maxBucketNotifications {
+		return NewInvalidRequestError(fmt.Sprintf("at most %d notifications are allowed", maxBucketNotifications))
+	}
+
+	for i := range notifications {
+		n := &notifications[i]
+		if n.service() == "" {
+			return NewInvalidRequestError(fmt.Sprintf("notifications[%d].target must be an SQS, SNS, Lambda or MinIO target ARN", i))
+		}
+		if n.Prefix != "" {
+			// Objects under a sharded prefix are spread over every shard
+			if err := bucket.requireUnsharded("notification prefix filter"); err != nil {
+				return err
+			}
+		}
+		if len(n.Events) == 0 {
+			n.Events = []string{string(types.EventS3ObjectCreated), string(types.EventS3ObjectRemoved)}
+		}
+		for _, event := range n.Events {
+			if !strings.HasPrefix(event, "s3:") {
+				return NewInvalidRequestError(fmt.Sprintf("notifications[%d] has invalid event '%s'", i, event))
+			}
+		}
+	}
+
+	return nil
+}
+
+// notificationConfiguration converts notifications into the configuration of bucket. Prefix
+// filters are relative to the bucket prefix, so every filter includes it.
+func notificationConfiguration(bucket *Bucket, notifications []BucketNotification, eventBridge bool) *types.NotificationConfiguration {
+	config := &types.NotificationConfiguration{}
+	if eventBridge {
+		config.EventBridgeConfiguration = &types.EventBridgeConfiguration{}
+	}
+
+	for _, n := range notifications {
+		events := make([]types.Event, len(n.Events))
+		for i, event := range n.Events {
+			events[i] = types.Event(event)
+		}
+
+		var rules []types.FilterRule
+		if prefix := bucket.GetFullPath(n.Prefix); prefix != "" {
+			rules = append(rules, types.FilterRule{Name: types.FilterRuleNamePrefix, Value: aws.String(prefix)})
+		}
+		if n.Suffix != "" {
+			rules = append(rules, types.FilterRule{Name: types.FilterRuleNameSuffix, Value: aws.String(n.Suffix)})
+		}
+		var filter *types.NotificationConfigurationFilter
+		if len(rules) > 0 {
+			filter = &types.NotificationConfigurationFilter{Key: &types.S3KeyFilter{FilterRules: rules}}
+		}
+
+		var id *string
+		if n.ID != "" {
+			id = aws.String(n.ID)
+		}
+
+		switch n.service() {
+		case notificationQueue:
+			config.QueueConfigurations = append(config.QueueConfigurations, types.QueueConfiguration{
+				Id: id, QueueArn: aws.String(n.Target), Events: events, Filter: filter,
+			})
+		case notificationTopic:
+			config.TopicConfigurations = append(config.TopicConfigurations, types.TopicConfiguration{
+				Id: id, TopicArn: aws.String(n.Target), Events: events, Filter: filter,
+			})
+		case notificationLambda:
+			config.LambdaFunctionConfigurations = append(config.LambdaFunctionConfigurations, types.LambdaFunctionConfiguration{
+				Id: id, LambdaFunctionArn: aws.String(n.Target), Events: events, Filter: filter,
+			})
+		}
+	}
+
+	return config
+}
+
+// SetBucketNotifications replaces the event notification configuration of a bucket, so event
+// consumers can be provisioned during deployment. An empty list removes every notification.
+// The configuration belongs to the S3 bucket, shared by every bucket entry pointing at it.
+func (o *Operations) SetBucketNotifications(ctx context.Context, req *SetBucketNotificationsRequest, resp *SetBucketNotificationsResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "set_bucket_notifications", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "SetBucketNotifications", "set_bucket_notifications", bucket.Name); err != nil {
+		return err
+	}
+
+	if err := validateNotifications(bucket, req.Notifications); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "set_bucket_notifications", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	input := &s3.PutBucketNotificationConfigurationInput{
+		Bucket:                    aws.String(bucket.Config.Bucket),
+		NotificationConfiguration: notificationConfiguration(bucket, req.Notifications, req.EventBridge),
+	}
+	// S3 checks that it may publish to every target; skipping lets targets be authorized afterwards
+	if req.SkipDestinationValidation {
+		input.SkipDestinationValidation = aws.Bool(true)
+	}
+
+	if _, err := bucket.Client.PutBucketNotificationConfiguration(ctx, input); err != nil {
+		loggerFor(ctx, o.log).Error("failed to configure bucket notifications",
+			zap.String("bucket", req.Bucket),
+			zap.Int("notifications", len(req.Notifications)),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "set_bucket_notifications", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("put bucket notification configuration", err)
+	}
+
+	resp.Success = true
+	resp.Notifications = len(req.Notifications)
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "set_bucket_notifications", "success")
+
+	o.log.Info("bucket notifications configured",
+		zap.String("bucket", req.Bucket),
+		zap.Int("notifications", len(req.Notifications)),
+		zap.Bool("event_bridge", req.EventBridge),
+	)
+
+	return nil
+}
@@ -33,18 +33,29 @@ func NewOperations(plugin *Plugin, log *zap.Logger) *Operations {
 // Write uploads a file to S3
 func (o *Operations) Write(ctx context.Context, req *WriteRequest, resp *WriteResponse) error {
 	// Track operation for graceful shutdown
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
+	return o.write(ctx, req, resp)
+}
+
+// write is Write for operations already tracked, which must finish their writes during shutdown
+func (o *Operations) write(ctx context.Context, req *WriteRequest, resp *WriteResponse) error {
 	start := time.Now()
 
 	// Validate request
-	if err := o.validatePathname(req.Pathname); err != nil {
+	if err := o.validatePathname(&req.Pathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
 		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
 		return err
 	}
 
+	if err := o.route(&req.Bucket, req.Pathname, "write"); err != nil {
+		return err
+	}
+
 	// Get bucket
 	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
 	if err != nil {
@@ -53,29 +64,98 @@ func (o *Operations) Write(ctx context.Context, req *WriteRequest, resp *WriteRe
 		return NewBucketNotFoundError(req.Bucket)
 	}
 
-	// Acquire semaphore
-	bucket.Acquire()
-	defer bucket.Release()
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "Write", "write", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := req.Transfer.validate(); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	if err := bucket.checkGrants(req.Grants, req.Visibility); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	wait, err := req.VisibilityWait.timeout()
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
 
-	// Determine visibility
-	visibility := req.Visibility
-	if visibility == "" {
-		visibility = bucket.GetVisibility()
+	// The blob prefix of a dedup bucket is managed by the plugin
+	if err := bucket.checkBlobPathname(req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+	if err := bucket.checkTemporaryPathname(req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+	if ac := bucket.Config.Audit; ac != nil && req.Pathname == ac.HeadPathname {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return NewInvalidPathnameError(req.Pathname, "pathname is the audit chain head")
 	}
 
+	if err := o.enforcePolicy(ctx, bucket, "write", req.Pathname, int64(len(req.Content))); err != nil {
+		return err
+	}
+
+	expiry, expiryErr := resolveExpiry(bucket, req)
+	if expiryErr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, expiryErr.Code)
+		return expiryErr
+	}
+
+	// Metadata travels in HTTP headers, stored in the bucket's metadata_encoding
+	metadata, err := bucket.Config.encodeMetadata(req.Config)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+	req.Config = metadata
+
+	// Acquire semaphore
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
 	// Get full S3 key
-	key := bucket.GetFullPath(req.Pathname)
+	key := bucket.ObjectKey(req.Pathname)
 
 	// Detect content type
 	contentType := o.detectContentType(req.Pathname, req.Content)
 
+	if bucket.Config.Dedup {
+		if err := o.writeDedup(ctx, bucket, req, resp, contentType, expiry); err != nil {
+			return err
+		}
+		resp.VisibilityTimeout = !o.awaitVisibility(ctx, bucket, key, true, wait)
+		return nil
+	}
+
 	// Prepare upload input
 	putInput := &s3.PutObjectInput{
-		Bucket:      aws.String(bucket.Config.Bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(req.Content),
-		ACL:         types.ObjectCannedACL(visibility),
-		ContentType: aws.String(contentType),
+		Bucket:       aws.String(bucket.Config.Bucket),
+		Key:          aws.String(key),
+		Body:         bytes.NewReader(req.Content),
+		ACL:          bucket.ObjectACL(req.Visibility),
+		ContentType:  aws.String(contentType),
+		StorageClass: types.StorageClass(req.StorageClass),
+	}
+	if req.Grants != nil {
+		req.Grants.apply(putInput)
 	}
 
 	// Add custom metadata if provided
@@ -87,16 +167,41 @@ func (o *Operations) Write(ctx context.Context, req *WriteRequest, resp *WriteRe
 		putInput.Metadata = metadata
 	}
 
-	// Use upload manager for better performance with large files
-	uploader := manager.NewUploader(bucket.Client, func(u *manager.Uploader) {
-		u.PartSize = bucket.Config.PartSize
-		u.Concurrency = bucket.Config.Concurrency
-	})
+	if expiry != nil {
+		expiry.apply(putInput)
+		resp.ExpirationDays = expiry.days
+	}
 
-	// Upload file
-	result, err := uploader.Upload(ctx, putInput)
+	// Small payloads skip the upload manager, large ones are split into parts uploaded concurrently
+	size := int64(len(req.Content))
+	method := bucket.UploadMethod(size)
+	var etag *string
+	if bucket.Config.Audit != nil {
+		// Audit objects are chained to their predecessor and created with a single conditional PutObject
+		method = UploadMethodSingle
+		etag, err = o.appendAudit(ctx, bucket, req.Pathname, req.Content, putInput)
+	} else if method == UploadMethodSingle {
+		var result *s3.PutObjectOutput
+		if result, err = bucket.Client.PutObject(ctx, putInput); err == nil {
+			etag = result.ETag
+		}
+	} else {
+		uploader := bucket.NewUploader(size)
+		req.Transfer.tune(uploader, bucket, size)
+		var result *manager.UploadOutput
+		if result, err = uploader.Upload(ctx, putInput); err == nil {
+			etag = result.ETag
+		}
+	}
 	if err != nil {
-		o.log.Error("failed to upload file",
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) {
+			o.plugin.metrics.RecordOperation(req.Bucket, "write", "error")
+			o.plugin.metrics.RecordError(req.Bucket, s3Err.Code)
+			return s3Err
+		}
+		o.abortInterruptedUpload(ctx, bucket, key, err)
+		loggerFor(ctx, o.log).Error("failed to upload file",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.Error(err),
@@ -106,13 +211,36 @@ func (o *Operations) Write(ctx context.Context, req *WriteRequest, resp *WriteRe
 		return NewS3OperationError("upload", err)
 	}
 
+	o.plugin.metrics.RecordUpload(req.Bucket, method)
+	bucket.filter.add(key)
+
+	// Derived objects of the previous content are stale now
+	o.invalidateDerived(ctx, bucket, req.Pathname)
+	o.plugin.index.update(bucket, req.Pathname)
+
+	// Overwrites are counted twice until the next quota measurement
+	bucket.usage.add(int64(len(req.Content)))
+
+	resp.VisibilityTimeout = !o.awaitVisibility(ctx, bucket, key, true, wait)
+	resp.ETag = aws.ToString(etag)
+
+	// The uploaded size is known; the modification time is the local time of the upload
+	if bucket.Config.SkipPostWriteHead {
+		resp.Success = true
+		resp.Pathname = req.Pathname
+		resp.Size = size
+		resp.LastModified = time.Now().Unix()
+		o.plugin.metrics.RecordOperation(req.Bucket, "write", "success")
+		return nil
+	}
+
 	// Get metadata for response
 	headResult, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(bucket.Config.Bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		o.log.Warn("failed to get object metadata after upload",
+		loggerFor(ctx, o.log).Warn("failed to get object metadata after upload",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.Error(err),
@@ -128,8 +256,8 @@ func (o *Operations) Write(ctx context.Context, req *WriteRequest, resp *WriteRe
 
 	resp.Success = true
 	resp.Pathname = req.Pathname
-	resp.Size = *headResult.ContentLength
-	resp.LastModified = headResult.LastModified.Unix()
+	resp.Size = contentLengthOr(headResult.ContentLength, size)
+	resp.LastModified = unixTime(headResult.LastModified)
 
 	o.plugin.metrics.RecordOperation(req.Bucket, "write", "success")
 
@@ -140,25 +268,29 @@ func (o *Operations) Write(ctx context.Context, req *WriteRequest, resp *WriteRe
 		zap.Duration("duration", time.Since(start)),
 	)
 
-	_ = result // Use result to avoid unused variable warning
-
 	return nil
 }
 
 // Read downloads a file from S3
 func (o *Operations) Read(ctx context.Context, req *ReadRequest, resp *ReadResponse) error {
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
 	start := time.Now()
 
 	// Validate request
-	if err := o.validatePathname(req.Pathname); err != nil {
+	if err := o.validatePathname(&req.Pathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.Bucket, "read", "error")
 		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
 		return err
 	}
 
+	if err := o.route(&req.Bucket, req.Pathname, "read"); err != nil {
+		return err
+	}
+
 	// Get bucket
 	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
 	if err != nil {
@@ -167,14 +299,21 @@ func (o *Operations) Read(ctx context.Context, req *ReadRequest, resp *ReadRespo
 		return NewBucketNotFoundError(req.Bucket)
 	}
 
-	bucket.Acquire()
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "Read", "read", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
 	defer bucket.Release()
 
 	// Get full S3 key
-	key := bucket.GetFullPath(req.Pathname)
+	key := bucket.ObjectKey(req.Pathname)
 
 	// Download file
-	result, err := bucket.Client.GetObject(ctx, &s3.GetObjectInput{
+	result, err := bucket.Reader().GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucket.Config.Bucket),
 		Key:    aws.String(key),
 	})
@@ -185,7 +324,7 @@ func (o *Operations) Read(ctx context.Context, req *ReadRequest, resp *ReadRespo
 			o.plugin.metrics.RecordError(req.Bucket, ErrFileNotFound)
 			return NewFileNotFoundError(req.Pathname)
 		}
-		o.log.Error("failed to download file",
+		loggerFor(ctx, o.log).Error("failed to download file",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.Error(err),
@@ -196,10 +335,32 @@ func (o *Operations) Read(ctx context.Context, req *ReadRequest, resp *ReadRespo
 	}
 	defer result.Body.Close()
 
+	// Pointer objects of dedup buckets redirect to the content-addressed blob
+	if sum, _ := dedupPointer(result.Metadata); sum != "" && bucket.Config.Dedup {
+		blob, err := bucket.Reader().GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    aws.String(bucket.GetFullPath(dedupBlobPath(bucket.Config.DedupPrefix, sum))),
+		})
+		if err != nil {
+			loggerFor(ctx, o.log).Error("failed to download dedup blob",
+				zap.String("bucket", req.Bucket),
+				zap.String("pathname", req.Pathname),
+				zap.String("sha256", sum),
+				zap.Error(err),
+			)
+			o.plugin.metrics.RecordOperation(req.Bucket, "read", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+			return NewS3OperationError("download blob", err)
+		}
+		defer blob.Body.Close()
+
+		result.Body, result.ContentLength = blob.Body, blob.ContentLength
+	}
+
 	// Read content
 	content, err := io.ReadAll(result.Body)
 	if err != nil {
-		o.log.Error("failed to read file content",
+		loggerFor(ctx, o.log).Error("failed to read file content",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.Error(err),
@@ -210,9 +371,9 @@ func (o *Operations) Read(ctx context.Context, req *ReadRequest, resp *ReadRespo
 	}
 
 	resp.Content = content
-	resp.Size = *result.ContentLength
-	resp.MimeType = *result.ContentType
-	resp.LastModified = result.LastModified.Unix()
+	resp.Size = contentLengthOr(result.ContentLength, int64(len(content)))
+	resp.MimeType = contentTypeOf(result.ContentType)
+	resp.LastModified = unixTime(result.LastModified)
 
 	o.plugin.metrics.RecordOperation(req.Bucket, "read", "success")
 
@@ -228,16 +389,22 @@ func (o *Operations) Read(ctx context.Context, req *ReadRequest, resp *ReadRespo
 
 // Exists checks if a file exists in S3
 func (o *Operations) Exists(ctx context.Context, req *ExistsRequest, resp *ExistsResponse) error {
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
 	// Validate request
-	if err := o.validatePathname(req.Pathname); err != nil {
+	if err := o.validatePathname(&req.Pathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.Bucket, "exists", "error")
 		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
 		return err
 	}
 
+	if err := o.route(&req.Bucket, req.Pathname, "exists"); err != nil {
+		return err
+	}
+
 	// Get bucket
 	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
 	if err != nil {
@@ -246,54 +413,99 @@ func (o *Operations) Exists(ctx context.Context, req *ExistsRequest, resp *Exist
 		return NewBucketNotFoundError(req.Bucket)
 	}
 
-	bucket.Acquire()
-	defer bucket.Release()
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "Exists", "exists", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
 
 	// Get full S3 key
-	key := bucket.GetFullPath(req.Pathname)
+	key := bucket.ObjectKey(req.Pathname)
 
-	// Check if object exists
-	_, err = bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(bucket.Config.Bucket),
-		Key:    aws.String(key),
-	})
+	// A negative answer of the exists filter is definite, only possible hits need HeadObject
+	if o.excludes(bucket, key) {
+		resp.Exists = false
+		o.plugin.metrics.RecordOperation(req.Bucket, "exists", "success")
+		return nil
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
 
+	// Check if object exists
+	exists, err := objectExists(ctx, bucket, key)
 	if err != nil {
-		var nsk *types.NoSuchKey
-		var nf *types.NotFound
-		if errors.As(err, &nsk) || errors.As(err, &nf) {
-			resp.Exists = false
-			o.plugin.metrics.RecordOperation(req.Bucket, "exists", "success")
-			return nil
-		}
 		// Other errors should be returned
-		o.log.Error("failed to check file existence",
+		loggerFor(ctx, o.log).Error("failed to check file existence",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.Error(err),
 		)
 		o.plugin.metrics.RecordOperation(req.Bucket, "exists", "error")
+		if isForbidden(err) {
+			o.plugin.metrics.RecordError(req.Bucket, ErrPermissionDenied)
+			return NewS3AccessDeniedError("head object", err)
+		}
 		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
 		return NewS3OperationError("head object", err)
 	}
 
-	resp.Exists = true
+	resp.Exists = exists
 	o.plugin.metrics.RecordOperation(req.Bucket, "exists", "success")
 	return nil
 }
 
+// objectExists classifies a HeadObject of key like the SDK ObjectExists waiter: 404 means missing,
+// and so does 403 on buckets treating forbidden as missing. Other errors are returned.
+func objectExists(ctx context.Context, bucket *Bucket, key string) (bool, error) {
+	_, err := bucket.Reader().HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	return headExists(bucket, err)
+}
+
+// headExists classifies the error of a HeadObject like objectExists
+func headExists(bucket *Bucket, err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+
+	var nsk *types.NoSuchKey
+	var nf *types.NotFound
+	if errors.As(err, &nsk) || errors.As(err, &nf) {
+		return false, nil
+	}
+	if bucket.Config.TreatForbiddenAsMissing && isForbidden(err) {
+		return false, nil
+	}
+	return false, err
+}
+
 // Delete deletes a file from S3
 func (o *Operations) Delete(ctx context.Context, req *DeleteRequest, resp *DeleteResponse) error {
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
+	return o.delete(ctx, req, resp)
+}
+
+// delete is Delete for operations already tracked, which must finish their deletes during shutdown
+func (o *Operations) delete(ctx context.Context, req *DeleteRequest, resp *DeleteResponse) error {
 	// Validate request
-	if err := o.validatePathname(req.Pathname); err != nil {
+	if err := o.validatePathname(&req.Pathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.Bucket, "delete", "error")
 		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
 		return err
 	}
 
+	if err := o.route(&req.Bucket, req.Pathname, "delete"); err != nil {
+		return err
+	}
+
 	// Get bucket
 	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
 	if err != nil {
@@ -302,11 +514,37 @@ func (o *Operations) Delete(ctx context.Context, req *DeleteRequest, resp *Delet
 		return NewBucketNotFoundError(req.Bucket)
 	}
 
-	bucket.Acquire()
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "Delete", "delete", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := bucket.requireAppendOnly("Delete"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "delete", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	wait, err := req.VisibilityWait.timeout()
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "delete", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
 	defer bucket.Release()
 
 	// Get full S3 key
-	key := bucket.GetFullPath(req.Pathname)
+	key := bucket.ObjectKey(req.Pathname)
+
+	if err := o.checkRetention(ctx, bucket, req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "delete", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
 
 	// Delete object
 	_, err = bucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -314,7 +552,7 @@ func (o *Operations) Delete(ctx context.Context, req *DeleteRequest, resp *Delet
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		o.log.Error("failed to delete file",
+		loggerFor(ctx, o.log).Error("failed to delete file",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.Error(err),
@@ -324,7 +562,11 @@ func (o *Operations) Delete(ctx context.Context, req *DeleteRequest, resp *Delet
 		return NewS3OperationError("delete", err)
 	}
 
+	o.invalidateDerived(ctx, bucket, req.Pathname)
+	o.plugin.index.remove(bucket, req.Pathname)
+
 	resp.Success = true
+	resp.VisibilityTimeout = !o.awaitVisibility(ctx, bucket, key, false, wait)
 	o.plugin.metrics.RecordOperation(req.Bucket, "delete", "success")
 
 	o.log.Debug("file deleted successfully",
@@ -337,23 +579,29 @@ func (o *Operations) Delete(ctx context.Context, req *DeleteRequest, resp *Delet
 
 // Copy copies a file within or between buckets
 func (o *Operations) Copy(ctx context.Context, req *CopyRequest, resp *CopyResponse) error {
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
 	start := time.Now()
 
 	// Validate request
-	if err := o.validatePathname(req.SourcePathname); err != nil {
+	if err := o.validatePathname(&req.SourcePathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.SourceBucket, "copy", "error")
 		o.plugin.metrics.RecordError(req.SourceBucket, ErrInvalidPathname)
 		return err
 	}
-	if err := o.validatePathname(req.DestPathname); err != nil {
+	if err := o.validatePathname(&req.DestPathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.DestBucket, "copy", "error")
 		o.plugin.metrics.RecordError(req.DestBucket, ErrInvalidPathname)
 		return err
 	}
 
+	if err := o.route(&req.SourceBucket, req.SourcePathname, "copy"); err != nil {
+		return err
+	}
+
 	// Get source bucket
 	sourceBucket, err := o.plugin.buckets.GetBucket(req.SourceBucket)
 	if err != nil {
@@ -362,6 +610,10 @@ func (o *Operations) Copy(ctx context.Context, req *CopyRequest, resp *CopyRespo
 		return NewBucketNotFoundError(req.SourceBucket)
 	}
 
+	if err := o.route(&req.DestBucket, req.DestPathname, "copy"); err != nil {
+		return err
+	}
+
 	// Get destination bucket
 	destBucket, err := o.plugin.buckets.GetBucket(req.DestBucket)
 	if err != nil {
@@ -370,36 +622,110 @@ func (o *Operations) Copy(ctx context.Context, req *CopyRequest, resp *CopyRespo
 		return NewBucketNotFoundError(req.DestBucket)
 	}
 
+	// Check access to both objects
+	if err := o.authorize(ctx, req.Caller, "Copy", "copy", sourceBucket.Name, req.SourcePathname); err != nil {
+		return err
+	}
+	if err := o.authorize(ctx, req.Caller, "Copy", "copy", destBucket.Name, req.DestPathname); err != nil {
+		return err
+	}
+
+	// Copies would enter an audit chain without a sequence
+	if err := destBucket.requireAppendOnly("Copy"); err != nil {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "copy", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, err.Code)
+		return err
+	}
+
+	// Blobs are only written by deduplicated writes
+	if err := destBucket.checkBlobPathname(req.DestPathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "copy", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, err.Code)
+		return err
+	}
+	if err := destBucket.checkTemporaryPathname(req.DestPathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "copy", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, err.Code)
+		return err
+	}
+
+	if err := destBucket.checkGrants(req.Grants, req.Visibility); err != nil {
+		o.plugin.metrics.RecordOperation(req.DestBucket, "copy", "error")
+		o.plugin.metrics.RecordError(req.DestBucket, err.Code)
+		return err
+	}
+
+	// The destination's write policy applies to the copied content
+	if destBucket.Config.WritePolicy != nil {
+		size, err := o.contentSize(ctx, sourceBucket, req.SourcePathname)
+		if err != nil {
+			var nsk *types.NoSuchKey
+			var nf *types.NotFound
+			if errors.As(err, &nsk) || errors.As(err, &nf) {
+				o.plugin.metrics.RecordOperation(req.SourceBucket, "copy", "error")
+				o.plugin.metrics.RecordError(req.SourceBucket, ErrFileNotFound)
+				return NewFileNotFoundError(req.SourcePathname)
+			}
+			o.plugin.metrics.RecordOperation(req.SourceBucket, "copy", "error")
+			o.plugin.metrics.RecordError(req.SourceBucket, ErrS3Operation)
+			return NewS3OperationError("head object", err)
+		}
+		if err := o.enforcePolicy(ctx, destBucket, "copy", req.DestPathname, size); err != nil {
+			return err
+		}
+	}
+
 	// Acquire semaphores
-	sourceBucket.Acquire()
-	defer sourceBucket.Release()
-	if req.SourceBucket != req.DestBucket {
-		destBucket.Acquire()
-		defer destBucket.Release()
+	release, err := acquireBuckets(ctx, sourceBucket, destBucket)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Pointers only resolve within their own bucket, other buckets get a copy of the blob
+	source := req.SourcePathname
+	var pointer *s3.HeadObjectOutput
+	if sourceBucket.Config.Dedup && sourceBucket != destBucket {
+		source, pointer, err = o.dedupResolve(ctx, sourceBucket, req.SourcePathname)
+		if err != nil {
+			var nsk *types.NoSuchKey
+			var nf *types.NotFound
+			if errors.As(err, &nsk) || errors.As(err, &nf) {
+				o.plugin.metrics.RecordOperation(req.SourceBucket, "copy", "error")
+				o.plugin.metrics.RecordError(req.SourceBucket, ErrFileNotFound)
+				return NewFileNotFoundError(req.SourcePathname)
+			}
+			o.plugin.metrics.RecordOperation(req.SourceBucket, "copy", "error")
+			o.plugin.metrics.RecordError(req.SourceBucket, ErrS3Operation)
+			return NewS3OperationError("head object", err)
+		}
 	}
 
 	// Get full S3 keys
-	sourceKey := sourceBucket.GetFullPath(req.SourcePathname)
-	destKey := destBucket.GetFullPath(req.DestPathname)
+	sourceKey := sourceBucket.ObjectKey(source)
+	destKey := destBucket.ObjectKey(req.DestPathname)
 
 	// Prepare copy source
-	copySource := fmt.Sprintf("%s/%s", sourceBucket.Config.Bucket, sourceKey)
-
-	// Determine visibility
-	visibility := req.Visibility
-	if visibility == "" {
-		visibility = destBucket.GetVisibility()
-	}
+	copySource := buildCopySource(sourceBucket.Config.Bucket, sourceKey, sourceBucket.ServerConfig.CopySourceEncoding)
 
 	// Copy object
-	_, err = destBucket.Client.CopyObject(ctx, &s3.CopyObjectInput{
+	copyInput := &s3.CopyObjectInput{
 		Bucket:     aws.String(destBucket.Config.Bucket),
 		Key:        aws.String(destKey),
 		CopySource: aws.String(copySource),
-		ACL:        types.ObjectCannedACL(visibility),
-	})
+		ACL:        destBucket.ObjectACL(req.Visibility),
+	}
+	if pointer != nil {
+		copyInput.MetadataDirective = types.MetadataDirectiveReplace
+		copyInput.ContentType = pointer.ContentType
+		copyInput.Metadata = pointerMetadata(pointer.Metadata)
+	}
+	if req.Grants != nil {
+		req.Grants.applyCopy(copyInput)
+	}
+	_, err = destBucket.Client.CopyObject(ctx, copyInput)
 	if err != nil {
-		o.log.Error("failed to copy file",
+		loggerFor(ctx, o.log).Error("failed to copy file",
 			zap.String("source_bucket", req.SourceBucket),
 			zap.String("source_pathname", req.SourcePathname),
 			zap.String("dest_bucket", req.DestBucket),
@@ -411,14 +737,18 @@ func (o *Operations) Copy(ctx context.Context, req *CopyRequest, resp *CopyRespo
 		return NewS3OperationError("copy", err)
 	}
 
+	destBucket.filter.add(destKey)
+	o.invalidateDerived(ctx, destBucket, req.DestPathname)
+	o.plugin.index.update(destBucket, req.DestPathname)
+
 	// Get metadata for response
 	headResult, err := destBucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(destBucket.Config.Bucket),
 		Key:    aws.String(destKey),
 	})
 	if err == nil {
-		resp.Size = *headResult.ContentLength
-		resp.LastModified = headResult.LastModified.Unix()
+		resp.Size = aws.ToInt64(headResult.ContentLength)
+		resp.LastModified = unixTime(headResult.LastModified)
 	}
 
 	resp.Success = true
@@ -439,6 +769,43 @@ func (o *Operations) Copy(ctx context.Context, req *CopyRequest, resp *CopyRespo
 
 // Move moves a file within or between buckets (copy + delete)
 func (o *Operations) Move(ctx context.Context, req *MoveRequest, resp *MoveResponse) error {
+	// Resolve virtual buckets up front, so access is checked where the objects live
+	if err := o.validatePathname(&req.SourcePathname); err != nil {
+		return err
+	}
+	if err := o.validatePathname(&req.DestPathname); err != nil {
+		return err
+	}
+	if err := o.route(&req.SourceBucket, req.SourcePathname, "move"); err != nil {
+		return err
+	}
+	if err := o.route(&req.DestBucket, req.DestPathname, "move"); err != nil {
+		return err
+	}
+
+	// Check access once for the whole move; the copy and delete below run pre-authorized
+	if err := o.authorizeMove(ctx, req); err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, authorizedKey{}, true)
+
+	// Refuse before copying: the source of a move is deleted
+	if source, err := o.plugin.buckets.GetBucket(req.SourceBucket); err == nil {
+		if err := source.requireAppendOnly("Move"); err != nil {
+			return err
+		}
+
+		if err := source.Acquire(ctx); err != nil {
+			return err
+		}
+		err := o.checkRetention(ctx, source, req.SourcePathname)
+		source.Release()
+		if err != nil {
+			o.plugin.metrics.RecordError(source.Name, err.Code)
+			return err
+		}
+	}
+
 	// First, copy the file
 	copyReq := &CopyRequest{
 		SourceBucket:   req.SourceBucket,
@@ -462,7 +829,7 @@ func (o *Operations) Move(ctx context.Context, req *MoveRequest, resp *MoveRespo
 	deleteResp := &DeleteResponse{}
 
 	if err := o.Delete(ctx, deleteReq, deleteResp); err != nil {
-		o.log.Error("failed to delete source file after copy",
+		loggerFor(ctx, o.log).Error("failed to delete source file after copy",
 			zap.String("bucket", req.SourceBucket),
 			zap.String("pathname", req.SourcePathname),
 			zap.Error(err),
@@ -481,16 +848,22 @@ func (o *Operations) Move(ctx context.Context, req *MoveRequest, resp *MoveRespo
 
 // GetMetadata retrieves file metadata
 func (o *Operations) GetMetadata(ctx context.Context, req *GetMetadataRequest, resp *GetMetadataResponse) error {
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
 	// Validate request
-	if err := o.validatePathname(req.Pathname); err != nil {
+	if err := o.validatePathname(&req.Pathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.Bucket, "get_metadata", "error")
 		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
 		return err
 	}
 
+	if err := o.route(&req.Bucket, req.Pathname, "get_metadata"); err != nil {
+		return err
+	}
+
 	// Get bucket
 	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
 	if err != nil {
@@ -499,14 +872,21 @@ func (o *Operations) GetMetadata(ctx context.Context, req *GetMetadataRequest, r
 		return NewBucketNotFoundError(req.Bucket)
 	}
 
-	bucket.Acquire()
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "GetMetadata", "get_metadata", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
 	defer bucket.Release()
 
 	// Get full S3 key
-	key := bucket.GetFullPath(req.Pathname)
+	key := bucket.ObjectKey(req.Pathname)
 
 	// Get object metadata
-	result, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+	result, err := bucket.Reader().HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(bucket.Config.Bucket),
 		Key:    aws.String(key),
 	})
@@ -518,7 +898,7 @@ func (o *Operations) GetMetadata(ctx context.Context, req *GetMetadataRequest, r
 			o.plugin.metrics.RecordError(req.Bucket, ErrFileNotFound)
 			return NewFileNotFoundError(req.Pathname)
 		}
-		o.log.Error("failed to get file metadata",
+		loggerFor(ctx, o.log).Error("failed to get file metadata",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.Error(err),
@@ -528,17 +908,20 @@ func (o *Operations) GetMetadata(ctx context.Context, req *GetMetadataRequest, r
 		return NewS3OperationError("head object", err)
 	}
 
-	resp.Size = *result.ContentLength
-	if result.ContentType != nil {
-		resp.MimeType = *result.ContentType
-	}
-	resp.LastModified = result.LastModified.Unix()
-	if result.ETag != nil {
-		resp.ETag = *result.ETag
+	resp.Size = aws.ToInt64(result.ContentLength)
+	if sum, size := dedupPointer(result.Metadata); sum != "" && bucket.Config.Dedup {
+		resp.Size = size
 	}
+	resp.MimeType = contentTypeOf(result.ContentType)
+	resp.LastModified = unixTime(result.LastModified)
+	resp.ETag = aws.ToString(result.ETag)
+	resp.Metadata = bucket.Config.decodeMetadata(result.Metadata)
 
 	// Determine visibility from ACL (if available)
 	resp.Visibility = "private" // Default
+	if bucket.ACLDisabled() {
+		resp.Visibility = bucket.Config.emulatedVisibility(req.Pathname)
+	}
 
 	o.plugin.metrics.RecordOperation(req.Bucket, "get_metadata", "success")
 
@@ -547,22 +930,29 @@ func (o *Operations) GetMetadata(ctx context.Context, req *GetMetadataRequest, r
 
 // SetVisibility changes file visibility (ACL)
 func (o *Operations) SetVisibility(ctx context.Context, req *SetVisibilityRequest, resp *SetVisibilityResponse) error {
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
 	// Validate request
-	if err := o.validatePathname(req.Pathname); err != nil {
+	if err := o.validatePathname(&req.Pathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.Bucket, "set_visibility", "error")
 		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
 		return err
 	}
 
-	if req.Visibility != "public" && req.Visibility != "private" {
+	// Grants replace the visibility, which is then omitted
+	if req.Grants == nil && req.Visibility != "public" && req.Visibility != "private" {
 		o.plugin.metrics.RecordOperation(req.Bucket, "set_visibility", "error")
 		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidVisibility)
 		return NewS3Error(ErrInvalidVisibility, "visibility must be 'public' or 'private'", req.Visibility)
 	}
 
+	if err := o.route(&req.Bucket, req.Pathname, "set_visibility"); err != nil {
+		return err
+	}
+
 	// Get bucket
 	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
 	if err != nil {
@@ -571,26 +961,50 @@ func (o *Operations) SetVisibility(ctx context.Context, req *SetVisibilityReques
 		return NewBucketNotFoundError(req.Bucket)
 	}
 
-	bucket.Acquire()
-	defer bucket.Release()
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "SetVisibility", "set_visibility", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
 
-	// Get full S3 key
-	key := bucket.GetFullPath(req.Pathname)
+	if err := bucket.checkGrants(req.Grants, req.Visibility); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "set_visibility", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	// Without ACLs visibility is emulated, nothing to change on the object
+	if bucket.ACLDisabled() {
+		if err := bucket.Config.checkEmulatedVisibility(req.Pathname, req.Visibility); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "set_visibility", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return err
+		}
+
+		resp.Success = true
+		o.plugin.metrics.RecordOperation(req.Bucket, "set_visibility", "success")
+		return nil
+	}
 
-	// Map visibility to ACL
-	acl := types.ObjectCannedACLPrivate
-	if req.Visibility == "public" {
-		acl = types.ObjectCannedACLPublicRead
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
 	}
+	defer bucket.Release()
+
+	// Get full S3 key
+	key := bucket.ObjectKey(req.Pathname)
 
 	// Set ACL
-	_, err = bucket.Client.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+	aclInput := &s3.PutObjectAclInput{
 		Bucket: aws.String(bucket.Config.Bucket),
 		Key:    aws.String(key),
-		ACL:    acl,
-	})
+		ACL:    bucket.ObjectACL(req.Visibility),
+	}
+	if req.Grants != nil {
+		req.Grants.applyACL(aclInput)
+	}
+	_, err = bucket.Client.PutObjectAcl(ctx, aclInput)
 	if err != nil {
-		o.log.Error("failed to set file visibility",
+		loggerFor(ctx, o.log).Error("failed to set file visibility",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.String("visibility", req.Visibility),
@@ -616,16 +1030,22 @@ func (o *Operations) SetVisibility(ctx context.Context, req *SetVisibilityReques
 
 // GetPublicURL generates a public or presigned URL for a file
 func (o *Operations) GetPublicURL(ctx context.Context, req *GetPublicURLRequest, resp *GetPublicURLResponse) error {
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
 	// Validate request
-	if err := o.validatePathname(req.Pathname); err != nil {
+	if err := o.validatePathname(&req.Pathname); err != nil {
 		o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
 		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
 		return err
 	}
 
+	if err := o.route(&req.Bucket, req.Pathname, "get_url"); err != nil {
+		return err
+	}
+
 	// Get bucket
 	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
 	if err != nil {
@@ -634,31 +1054,130 @@ func (o *Operations) GetPublicURL(ctx context.Context, req *GetPublicURLRequest,
 		return NewBucketNotFoundError(req.Bucket)
 	}
 
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "GetPublicURL", "get_url", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
 	// Get full S3 key
-	key := bucket.GetFullPath(req.Pathname)
+	key := bucket.ObjectKey(req.Pathname)
+
+	expires := time.Duration(req.ExpiresIn) * time.Second
+
+	// Without ACLs only the public prefix is anonymously readable; presign mode hands out long-lived URLs instead
+	if expires == 0 && bucket.ACLDisabled() {
+		if bucket.Config.PublicMode == PublicModePresign {
+			expires = bucket.Config.PublicURLTTL
+		} else if bucket.Config.emulatedVisibility(req.Pathname) != "public" {
+			o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return NewInvalidRequestError("object is not under public_prefix '" + bucket.Config.PublicPrefix + "', request a presigned URL with expires_in")
+		}
+	}
+
+	// Pointer objects are empty; their content is served from the private blob through a signed URL
+	if bucket.Config.Dedup {
+		if err := bucket.Acquire(ctx); err != nil {
+			return err
+		}
+		source, err := o.dedupSource(ctx, bucket, req.Pathname)
+		bucket.Release()
+		if err != nil {
+			var nsk *types.NoSuchKey
+			var nf *types.NotFound
+			if errors.As(err, &nsk) || errors.As(err, &nf) {
+				o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+				o.plugin.metrics.RecordError(req.Bucket, ErrFileNotFound)
+				return NewFileNotFoundError(req.Pathname)
+			}
+			o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+			return NewS3OperationError("head object", err)
+		}
+
+		if source != req.Pathname {
+			if expires == 0 {
+				o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+				o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+				return NewInvalidRequestError("deduplicated objects have no permanent URL, request a presigned URL with expires_in")
+			}
+			key = bucket.ObjectKey(source)
+		}
+	}
+
+	// Conditions are carried by signatures, which permanent URLs do not have
+	if expires == 0 && (req.SourceIP != "" || len(req.SignedHeaders) > 0) {
+		o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("source_ip and signed_headers require expires_in")
+	}
+
+	// S3 presigned URLs cannot carry IP conditions, CloudFront custom policies can
+	if req.SourceIP != "" {
+		return o.signCDNURL(bucket, req, resp, key, expires)
+	}
+
+	if err := validateSignedHeaders(req.SignedHeaders); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
 
 	// If no expiration, generate permanent public URL
-	if req.ExpiresIn == 0 {
+	if expires == 0 {
+		// Objects behind a CDN are served through it
+		if cdn := bucket.Config.CDN; cdn != nil && cdn.URL != "" {
+			resp.URL = cdn.URL + cdn.cdnPath(key)
+			o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "success")
+			return nil
+		}
+
+		// Objects behind an access point are served from its own host
+		if ap, ok := bucket.Config.accessPoint(); ok {
+			resp.URL = ap.endpoint() + "/" + escapeKeyPath(key)
+			o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "success")
+			return nil
+		}
+
 		// Generate public URL (assuming public-read ACL)
 		endpoint := bucket.ServerConfig.Endpoint
 		if endpoint == "" {
-			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", bucket.ServerConfig.Region)
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", bucket.Client.Options().Region)
 		}
-		resp.URL = fmt.Sprintf("%s/%s/%s", endpoint, bucket.Config.Bucket, key)
+		resp.URL = fmt.Sprintf("%s/%s/%s", endpoint, bucket.Config.Bucket, escapeKeyPath(key))
 		o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "success")
 		return nil
 	}
 
+	// Pages embedding many signed URLs ask for the same ones on every render
+	cacheable := bucket.presigned != nil && len(req.SignedHeaders) == 0
+	if cacheable {
+		cached, hit := bucket.presigned.get(key, expires, time.Now())
+		o.plugin.metrics.RecordPresignCache(req.Bucket, hit)
+		if hit {
+			resp.URL = cached.url
+			resp.ExpiresAt = cached.expiresAt.Unix()
+			o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "success")
+			return nil
+		}
+	}
+
 	// Generate presigned URL
-	presignClient := s3.NewPresignClient(bucket.Client)
+	signedAt := time.Now()
+	presignClient := s3.NewPresignClient(bucket.Presigner())
 	presignResult, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucket.Config.Bucket),
 		Key:    aws.String(key),
 	}, func(opts *s3.PresignOptions) {
-		opts.Expires = time.Duration(req.ExpiresIn) * time.Second
+		opts.Expires = expires
+		if len(req.SignedHeaders) > 0 {
+			opts.ClientOptions = append(opts.ClientOptions, func(o *s3.Options) {
+				o.APIOptions = append(o.APIOptions, signedHeadersMiddleware(req.SignedHeaders))
+			})
+		}
 	})
 	if err != nil {
-		o.log.Error("failed to generate presigned URL",
+		loggerFor(ctx, o.log).Error("failed to generate presigned URL",
 			zap.String("bucket", req.Bucket),
 			zap.String("pathname", req.Pathname),
 			zap.Error(err),
@@ -669,7 +1188,11 @@ func (o *Operations) GetPublicURL(ctx context.Context, req *GetPublicURLRequest,
 	}
 
 	resp.URL = presignResult.URL
-	resp.ExpiresAt = time.Now().Add(time.Duration(req.ExpiresIn) * time.Second).Unix()
+	resp.ExpiresAt = signedAt.Add(expires).Unix()
+	resp.SignedHeaders = requiredHeaders(presignResult.SignedHeader)
+	if cacheable {
+		bucket.presigned.put(key, expires, presignedURL{url: resp.URL, expiresAt: signedAt.Add(expires)})
+	}
 
 	o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "success")
 
@@ -678,11 +1201,26 @@ func (o *Operations) GetPublicURL(ctx context.Context, req *GetPublicURLRequest,
 
 // ListObjects lists objects in a bucket with optional filtering and pagination
 func (o *Operations) ListObjects(ctx context.Context, req *ListObjectsRequest, resp *ListObjectsResponse) error {
-	o.plugin.TrackOperation()
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
 	defer o.plugin.CompleteOperation()
 
 	start := time.Now()
 
+	if !isValidDirectoryMarkersMode(req.DirectoryMarkers) {
+		o.plugin.metrics.RecordOperation(req.Bucket, "list", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("directory_markers must be 'include', 'skip' or 'as_prefix'")
+	}
+
+	// A prefix spanning routes to several buckets is listed from all of them
+	if rc, targets, err := o.routeListing(&req.Bucket, req.Prefix, "list"); err != nil {
+		return err
+	} else if len(targets) > 1 {
+		return o.listRouted(ctx, req, resp, rc, targets, start)
+	}
+
 	// Get bucket
 	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
 	if err != nil {
@@ -691,13 +1229,25 @@ func (o *Operations) ListObjects(ctx context.Context, req *ListObjectsRequest, r
 		return NewBucketNotFoundError(req.Bucket)
 	}
 
-	bucket.Acquire()
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "ListObjects", "list", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	// Shards are merged into one listing ordered by pathname
+	if bucket.Config.Sharding != nil {
+		return o.listMergedObjects(ctx, req, resp, listSources(bucket), nil, start)
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
 	defer bucket.Release()
 
-	// Set default max keys if not specified
+	// Set default max keys if not specified, never more than S3 returns per page
 	maxKeys := req.MaxKeys
-	if maxKeys <= 0 {
-		maxKeys = 1000
+	if maxKeys <= 0 || maxKeys > maxListKeys {
+		maxKeys = maxListKeys
 	}
 
 	// Prepare prefix - include bucket prefix if configured
@@ -723,9 +1273,9 @@ func (o *Operations) ListObjects(ctx context.Context, req *ListObjectsRequest, r
 	}
 
 	// List objects
-	result, err := bucket.Client.ListObjectsV2(ctx, input)
+	result, err := bucket.Reader().ListObjectsV2(ctx, input)
 	if err != nil {
-		o.log.Error("failed to list objects",
+		loggerFor(ctx, o.log).Error("failed to list objects",
 			zap.String("bucket", req.Bucket),
 			zap.String("prefix", req.Prefix),
 			zap.Error(err),
@@ -735,54 +1285,32 @@ func (o *Operations) ListObjects(ctx context.Context, req *ListObjectsRequest, r
 		return NewS3OperationError("list objects", err)
 	}
 
-	// Convert results to response format
-	resp.Objects = make([]ObjectInfo, 0, len(result.Contents))
-	for _, obj := range result.Contents {
-		// Remove bucket prefix from key if present
-		key := *obj.Key
-		if bucket.Config.Prefix != "" && strings.HasPrefix(key, bucket.Config.Prefix) {
-			key = strings.TrimPrefix(key, bucket.Config.Prefix)
-		}
-
-		objectInfo := ObjectInfo{
-			Key:          key,
-			Size:         *obj.Size,
-			LastModified: obj.LastModified.Unix(),
-		}
-
-		if obj.ETag != nil {
-			objectInfo.ETag = *obj.ETag
-		}
-
-		if obj.StorageClass != "" {
-			objectInfo.StorageClass = string(obj.StorageClass)
-		}
-
-		resp.Objects = append(resp.Objects, objectInfo)
+	// Determine how zero-byte directory markers are reported
+	markers := bucket.Config.DirectoryMarkers
+	if req.DirectoryMarkers != "" {
+		markers = req.DirectoryMarkers
 	}
 
-	// Process common prefixes (directories)
-	if len(result.CommonPrefixes) > 0 {
-		resp.CommonPrefixes = make([]CommonPrefix, 0, len(result.CommonPrefixes))
-		for _, cp := range result.CommonPrefixes {
-			prefix := *cp.Prefix
-			// Remove bucket prefix if present
-			if bucket.Config.Prefix != "" && strings.HasPrefix(prefix, bucket.Config.Prefix) {
-				prefix = strings.TrimPrefix(prefix, bucket.Config.Prefix)
-			}
+	// Convert results to response format
+	resp.Objects, resp.CommonPrefixes = listPageEntries(bucket, result, markers, req.Prefix, make(map[string]bool))
 
-			resp.CommonPrefixes = append(resp.CommonPrefixes, CommonPrefix{
-				Prefix: prefix,
-			})
-		}
+	// Refuse pages too large to deliver in a single response
+	var size int64
+	for _, obj := range resp.Objects {
+		size += obj.encodedSize()
+	}
+	if size > o.plugin.config.MaxListResponseSize {
+		resp.Objects, resp.CommonPrefixes = nil, nil
+		o.plugin.metrics.RecordOperation(req.Bucket, "list", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("listing exceeds max_list_response_size, lower max_keys or use OpenListing")
 	}
 
 	// Set pagination info
-	resp.IsTruncated = result.IsTruncated != nil && *result.IsTruncated
-	if result.NextContinuationToken != nil {
-		resp.NextContinuationToken = *result.NextContinuationToken
-	}
-	resp.KeyCount = *result.KeyCount
+	resp.IsTruncated = aws.ToBool(result.IsTruncated)
+	resp.NextContinuationToken = aws.ToString(result.NextContinuationToken)
+	// Directory markers may have been dropped or folded into prefixes, so count what is returned
+	resp.KeyCount = int32(len(resp.Objects) + len(resp.CommonPrefixes))
 
 	o.plugin.metrics.RecordOperation(req.Bucket, "list", "success")
 
@@ -797,8 +1325,26 @@ func (o *Operations) ListObjects(ctx context.Context, req *ListObjectsRequest, r
 	return nil
 }
 
-// validatePathname validates a file pathname
-func (o *Operations) validatePathname(pathname string) error {
+// isDirectoryMarker reports whether an object is a zero-byte "directory/" placeholder
+func isDirectoryMarker(key string, size *int64) bool {
+	return strings.HasSuffix(key, "/") && (size == nil || *size == 0)
+}
+
+// validatePathname validates a file pathname, normalizing it in place when normalize_paths is enabled
+func (o *Operations) validatePathname(pathname *string) error {
+	if o.plugin.normalizePaths {
+		normalized, ok := normalizePathname(*pathname)
+		if !ok {
+			return NewInvalidPathnameError(*pathname, "pathname cannot traverse above the bucket root")
+		}
+		*pathname = normalized
+	}
+
+	return checkPathname(*pathname)
+}
+
+// checkPathname applies the pathname rules shared by all operations
+func checkPathname(pathname string) error {
 	if pathname == "" {
 		return NewInvalidPathnameError(pathname, "pathname cannot be empty")
 	}
@@ -811,6 +1357,10 @@ func (o *Operations) validatePathname(pathname string) error {
 		return NewInvalidPathnameError(pathname, "pathname cannot contain '..'")
 	}
 
+	if reason := validateKeyCharacters(pathname); reason != "" {
+		return NewInvalidPathnameError(pathname, reason)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+// detectObjectOwnership turns ACLs off for buckets with Object Ownership "BucketOwnerEnforced",
+// which reject every request carrying an ACL, so writes do not fail on the ACL header. Public
+// objects of such buckets are served through presigned URLs. Buckets without ownership controls
+// (created before 2023) or whose controls cannot be read keep their ACLs.
+func (bm *BucketManager) detectObjectOwnership(ctx context.Context, bucket *Bucket) {
+	if !bucket.Capabilities.ACL || bucket.Config.DisableACL || bucket.ServerConfig.Anonymous {
+		return
+	}
+	if _, ok := bucket.Config.accessPoint(); ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, capabilityProbeTimeout)
+	defer cancel()
+
+	result, err := bucket.Client.GetBucketOwnershipControls(ctx, &s3.GetBucketOwnershipControlsInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "OwnershipControlsNotFoundError" {
+			bm.log.Debug("failed to read object ownership, keeping ACLs",
+				zap.String("bucket", bucket.Name),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+
+	if result.OwnershipControls == nil {
+		return
+	}
+	for _, rule := range result.OwnershipControls.Rules {
+		if rule.ObjectOwnership != types.ObjectOwnershipBucketOwnerEnforced {
+			continue
+		}
+
+		bucket.Capabilities.ACL = false
+		// Prefix mode needs a bucket policy, which only an explicit disable_acl promises
+		bucket.Config.PublicMode = PublicModePresign
+		if bucket.Config.PublicURLTTL <= 0 {
+			bucket.Config.PublicURLTTL = maxPresignTTL
+		}
+
+		bm.log.Info("bucket enforces object ownership, ACLs disabled",
+			zap.String("bucket", bucket.Name),
+			zap.String("public_mode", bucket.Config.PublicMode),
+		)
+		if bucket.Config.Visibility == "public" {
+			bm.log.Warn("visibility 'public' cannot be applied with ACLs disabled by object ownership, public URLs are presigned",
+				zap.String("bucket", bucket.Name),
+				zap.Duration("public_url_ttl", bucket.Config.PublicURLTTL),
+			)
+		}
+		return
+	}
+}
@@ -0,0 +1,146 @@
+package s3
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// payloadSizeBounds are the upper bounds of the payload size buckets: 1KiB to 4GiB by powers of four
+var payloadSizeBounds = [...]int64{
+	1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10,
+	1 << 20, 4 << 20, 16 << 20, 64 << 20, 256 << 20,
+	1 << 30, 4 << 30,
+}
+
+// payloadObserver receives the body size of each successful GetObject, PutObject and UploadPart
+type payloadObserver func(bucket, api string, size int64)
+
+// PayloadSizeBucket is the number of payloads up to a size
+type PayloadSizeBucket struct {
+	// LE is the upper bound in bytes; 0 counts payloads larger than the last bound
+	LE    int64  `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// PayloadSizes is the size distribution of the payloads of one S3 API operation
+type PayloadSizes struct {
+	API   string `json:"api"`
+	Count uint64 `json:"count"`
+	Bytes int64  `json:"bytes"`
+
+	// Buckets are non-cumulative, ordered by bound, with the overflow bucket last
+	Buckets []PayloadSizeBucket `json:"buckets"`
+}
+
+// payloadHistogram counts payload sizes of one API operation
+type payloadHistogram struct {
+	counts [len(payloadSizeBounds) + 1]uint64
+	count  uint64
+	bytes  int64
+}
+
+// payloadStats keeps the payload size distributions of a bucket for GetStatus
+type payloadStats struct {
+	mu         sync.Mutex
+	histograms map[string]*payloadHistogram
+}
+
+// observe adds a payload of the given API operation
+func (ps *payloadStats) observe(api string, size int64) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.histograms == nil {
+		ps.histograms = make(map[string]*payloadHistogram)
+	}
+	h, exists := ps.histograms[api]
+	if !exists {
+		h = &payloadHistogram{}
+		ps.histograms[api] = h
+	}
+
+	i := 0
+	for i < len(payloadSizeBounds) && size > payloadSizeBounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.bytes += size
+}
+
+// snapshot returns the distributions ordered as GetObject, PutObject, UploadPart; APIs without
+// payloads yet are omitted
+func (ps *payloadStats) snapshot() []PayloadSizes {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	var sizes []PayloadSizes
+	for _, api := range []string{"GetObject", "PutObject", "UploadPart"} {
+		h, exists := ps.histograms[api]
+		if !exists {
+			continue
+		}
+
+		s := PayloadSizes{API: api, Count: h.count, Bytes: h.bytes}
+		for i, count := range h.counts {
+			var le int64
+			if i < len(payloadSizeBounds) {
+				le = payloadSizeBounds[i]
+			}
+			s.Buckets = append(s.Buckets, PayloadSizeBucket{LE: le, Count: count})
+		}
+		sizes = append(sizes, s)
+	}
+	return sizes
+}
+
+// payloadSize returns the body size of a successful object transfer, or false for other requests
+// and unknown sizes
+func payloadSize(api string, req, result any) (int64, bool) {
+	switch api {
+	case "PutObject", "UploadPart":
+		r, ok := req.(*smithyhttp.Request)
+		if !ok {
+			break
+		}
+		// Streams of unknown length are sent without a content length
+		if r.ContentLength > 0 || r.GetStream() == nil {
+			return r.ContentLength, true
+		}
+	case "GetObject":
+		if out, ok := result.(*s3.GetObjectOutput); ok && out.ContentLength != nil {
+			return aws.ToInt64(out.ContentLength), true
+		}
+	}
+	return 0, false
+}
+
+// payloadSizeMiddleware records the size of each object transfer in the bucket's stats, and with
+// observe when set. It sits in the build step, after the content length is computed and before
+// the retry loop, so each transfer is recorded once whatever the number of attempts.
+func payloadSizeMiddleware(bucket string, stats *payloadStats, observe payloadObserver) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Build.Add(middleware.BuildMiddlewareFunc("S3PayloadSize",
+			func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleBuild(ctx, in)
+				if err != nil {
+					return out, metadata, err
+				}
+
+				api := awsmiddleware.GetOperationName(ctx)
+				if size, ok := payloadSize(api, in.Request, out.Result); ok {
+					stats.observe(api, size)
+					if observe != nil {
+						observe(bucket, api, size)
+					}
+				}
+				return out, metadata, err
+			}), middleware.After)
+	}
+}
@@ -3,7 +3,10 @@ package s3
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/roadrunner-server/endure/v2/dep"
@@ -21,6 +24,9 @@ type Plugin struct {
 	// Configuration provider
 	cfg Configurer
 
+	// Effective configuration after defaulting and validation
+	config *Config
+
 	// Logger
 	log *zap.Logger
 
@@ -30,16 +36,84 @@ type Plugin struct {
 	// Operations handler for S3 operations
 	operations *Operations
 
+	// Download sessions for resumable chunked reads
+	downloads *DownloadSessionManager
+
+	// Listing cursors for streaming large listings
+	listings *ListCursorManager
+
+	// Job manager for asynchronous background jobs
+	jobs *JobManager
+
+	// Client-driven multipart uploads, persisted across restarts
+	uploads *UploadManager
+
+	// Directory for persistent state (empty disables persistence)
+	stateDir string
+
+	// Normalize pathnames instead of rejecting leading slashes and "./" segments
+	normalizePaths bool
+
+	// Interceptors applied to every RPC operation
+	interceptors InterceptorChain
+
+	// Access control layer (nil when not configured)
+	access *AccessControl
+
+	// Latest per-bucket outcomes for status reporting
+	health *healthTracker
+
+	// Persistence of RPC-registered servers and buckets (nil when disabled)
+	registry *Registry
+
 	// Metrics exporter for Prometheus integration
 	metrics *metricsExporter
 
+	// Dedicated log of slow operations (nil when not configured)
+	slowLog *slowLog
+
+	// Short links served by the HTTP middleware (nil when not configured)
+	shortLinks *ShortLinkManager
+
+	// Object metadata index answering SearchObjects (nil when not configured)
+	index *ObjectIndex
+
+	// HMAC key signing export manifests (nil when not configured)
+	exportKey []byte
+
+	// HMAC key of audit hash chains, derived from the state key (nil: chains use plain sha256)
+	auditKey []byte
+
+	// Leader election for scheduled jobs (nil when not configured: every instance runs them)
+	leader *coordinator
+
+	// Delivers job completions to callback pipelines (nil when no plugin provides it)
+	publisher JobPublisher
+
+	// HTTP client of WriteFromURL (nil when url_fetch is not configured)
+	fetchClient *http.Client
+
 	// Context for graceful shutdown
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	// Context of RPC operations; cancelled only when shutdown_timeout elapses
+	opsCtx    context.Context
+	cancelOps context.CancelFunc
+
+	// Number of RPC operations in flight
+	inFlight atomic.Int64
+
+	// Uploads aborted during shutdown
+	shutdown shutdownReport
+
 	// WaitGroup for tracking ongoing operations
 	wg sync.WaitGroup
 
+	// Set by Stop; new operations are rejected once it is true
+	shuttingDown bool
+	shutdownMu   sync.RWMutex
+
 	// Mutex for thread-safe operations
 	mu sync.RWMutex
 }
@@ -67,13 +141,10 @@ func (p *Plugin) Init(cfg Configurer, log Logger) error {
 	p.cfg = cfg
 	p.log = log.NamedLogger(PluginName)
 	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.opsCtx, p.cancelOps = context.WithCancel(context.Background())
 
-	// Initialize metrics exporter with explicit Prometheus registration
-	metrics, err := newMetricsExporter()
-	if err != nil {
-		return fmt.Errorf("failed to initialize metrics: %w", err)
-	}
-	p.metrics = metrics
+	// Track per-bucket health for the status plugin
+	p.health = newHealthTracker()
 
 	// Initialize bucket manager
 	p.buckets = NewBucketManager(p.log)
@@ -81,6 +152,9 @@ func (p *Plugin) Init(cfg Configurer, log Logger) error {
 	// Initialize operations handler
 	p.operations = NewOperations(p, p.log)
 
+	// Initialize job manager bound to the plugin lifecycle
+	p.jobs = NewJobManager(p.ctx, &p.wg, p.log)
+
 	// Load static configuration from .rr.yaml
 	var config Config
 	if err := cfg.UnmarshalKey(PluginName, &config); err != nil {
@@ -92,9 +166,81 @@ func (p *Plugin) Init(cfg Configurer, log Logger) error {
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	for _, warning := range config.Lint() {
+		p.log.Warn("questionable configuration",
+			zap.String("scope", warning.Scope),
+			zap.String("warning", warning.Message),
+		)
+	}
+
+	// Initialize metrics exporter; collectors are registered via MetricsCollector
+	p.metrics = newMetricsExporter(config.Metrics)
+	p.metrics.health = p.health
+	p.metrics.registered = p.buckets.registered
+
+	// Initialize download session manager
+	p.downloads = NewDownloadSessionManager(config.DownloadSessionTTL)
+
+	// Initialize listing cursor manager
+	p.listings = NewListCursorManager(config.ListCursorTTL)
+
+	p.config = &config
+	p.stateDir = config.StateDir
+	p.normalizePaths = config.NormalizePaths
+	p.access = NewAccessControl(config.Access, p.log)
+	p.metrics.alerts = newAlertMonitor(config.Alerts, p.log)
+
+	// Keep summaries of finished jobs across restarts
+	p.jobs.SetHistory(newJobHistory(config.JobHistorySize, config.StateDir, p.log))
+	p.jobs.History().Load()
+
+	slowLog, err := newSlowLog(config.SlowLog)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	p.slowLog = slowLog
+
+	// Config-declared built-in interceptors run before those provided by other plugins
+	builtins := make([]Interceptor, 0, len(config.Interceptors))
+	for _, name := range config.Interceptors {
+		interceptor, err := newBuiltinInterceptor(name, p.log)
+		if err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+		builtins = append(builtins, interceptor)
+	}
+	p.interceptors.Prepend(builtins...)
+
+	if config.ShortLinks != nil {
+		p.shortLinks = NewShortLinkManager(config.StateDir, p.log)
+		p.shortLinks.Load()
+	}
+
+	p.index = NewObjectIndex(config.Index, config.StateDir, p.metrics, p.log)
+	p.index.Load()
+
 	// Set server configurations in bucket manager
 	p.buckets.SetServers(config.Servers)
 
+	// Bound concurrency across all buckets
+	p.buckets.SetGlobalLimit(config.MaxConcurrentOperations, p.metrics.ObserveGlobalQueueWait)
+	p.buckets.SetMaxQueueWait(config.MaxQueueWait)
+
+	// Count S3 requests by pricing class and downloaded bytes
+	p.buckets.SetRequestObserver(p.metrics.RecordRequest)
+
+	// Count retries suppressed by empty retry budgets
+	p.buckets.SetRetryObserver(p.metrics.RecordRetrySuppressed)
+
+	// Break request latency down into DNS, connect, TLS and time to first byte
+	p.buckets.SetPhaseObserver(p.metrics.ObservePhase)
+
+	// Count hedged reads and how often the hedge answered first
+	p.buckets.SetHedgeObserver(p.metrics.RecordHedge)
+
+	// Track size distributions of object reads and writes
+	p.buckets.SetPayloadObserver(p.metrics.ObservePayload)
+
 	// Register buckets from static configuration
 	for name, bucketCfg := range config.Buckets {
 		p.log.Debug("registering bucket from config",
@@ -113,6 +259,53 @@ func (p *Plugin) Init(cfg Configurer, log Logger) error {
 		}
 	}
 
+	// Persisted credentials and upload sessions are encrypted with a key that may live in a bucket (kms)
+	var stateKey string
+	if config.StateEncryption != nil {
+		stateKey, err = resolveEncryptionKey(p.ctx, config.StateEncryption, p.buckets)
+		if err != nil {
+			return fmt.Errorf("failed to load state encryption key: %w", err)
+		}
+		p.auditKey = auditChainKey(stateKey)
+	}
+
+	// Restore servers and buckets registered via RPC before the last shutdown
+	if config.PersistDynamic {
+		p.registry, err = NewRegistry(config.StateDir, stateKey, p.log)
+		if err != nil {
+			return fmt.Errorf("failed to initialize registry: %w", err)
+		}
+		p.registry.Restore(p.ctx, p.buckets)
+	}
+
+	// Restore multipart uploads interrupted by a previous shutdown
+	p.uploads, err = NewUploadManager(config.StateDir, stateKey, p.log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize multipart uploads: %w", err)
+	}
+	p.uploads.Load()
+
+	// Export manifests are signed with a key that may live in a bucket (kms)
+	if config.Exports != nil {
+		key, err := resolveEncryptionKey(p.ctx, config.Exports.SigningKey, p.buckets)
+		if err != nil {
+			return fmt.Errorf("failed to load export signing key: %w", err)
+		}
+		p.exportKey = []byte(key)
+	}
+
+	if config.URLFetch != nil {
+		p.fetchClient = newFetchClient(config.URLFetch)
+	}
+
+	// Scheduled jobs wait for this instance to be elected
+	if config.Coordination != nil {
+		p.leader, err = newCoordinator(config.Coordination, p.operations, p.log)
+		if err != nil {
+			return fmt.Errorf("failed to initialize coordination: %w", err)
+		}
+	}
+
 	// Set default bucket if specified
 	if config.Default != "" {
 		if err := p.buckets.SetDefault(config.Default); err != nil {
@@ -136,8 +329,26 @@ func (p *Plugin) Init(cfg Configurer, log Logger) error {
 func (p *Plugin) Serve() chan error {
 	errCh := make(chan error, 1)
 
-	// This plugin doesn't have background workers, but implements Service interface
-	// for proper lifecycle management
+	// Job callbacks can use publishers collected after Init
+	p.jobs.SetNotifier(newJobNotifier(p.opsCtx, &p.wg, p.config.JobCallbacks, p.publisher, p.log))
+
+	// Resume background jobs interrupted by a previous shutdown
+	p.operations.ResumeMigrations()
+	p.operations.ResumeReindexes()
+
+	// Start evaluating alert thresholds
+	p.metrics.alerts.run(p.ctx, &p.wg)
+
+	// Compete for running scheduled jobs
+	p.leader.run(p.ctx, &p.wg)
+
+	// Index writes in the background
+	p.index.run(p.ctx, &p.wg, p.operations.describeObject)
+	p.operations.scheduleReindex(p.ctx, &p.wg)
+
+	// Warn before quotas are exhausted
+	p.operations.watchQuotas(p.ctx, &p.wg)
+
 	p.log.Debug("S3 plugin serving")
 
 	return errCh
@@ -147,21 +358,58 @@ func (p *Plugin) Serve() chan error {
 func (p *Plugin) Stop(ctx context.Context) error {
 	p.log.Debug("stopping S3 plugin")
 
-	// Cancel all ongoing operations
+	// Reject new operations so callers can retry on another instance; in-flight ones drain below
+	p.shutdownMu.Lock()
+	p.shuttingDown = true
+	p.shutdownMu.Unlock()
+	p.jobs.Close()
+
+	// Background jobs checkpoint and resume on the next start, so they stop right away
 	p.cancel()
 
-	// Wait for all operations to complete or timeout
+	// Let in-flight operations drain for up to shutdown_timeout
 	done := make(chan struct{})
 	go func() {
 		p.wg.Wait()
 		close(done)
 	}()
 
+	timer := time.NewTimer(p.config.ShutdownTimeout)
+	defer timer.Stop()
+
 	select {
 	case <-done:
 		p.log.Debug("all S3 operations completed")
+	case <-timer.C:
 	case <-ctx.Done():
-		p.log.Warn("shutdown timeout reached, forcing stop")
+	}
+
+	// Cancelled transfers abort their multipart uploads instead of leaving parts behind
+	p.cancelOps()
+
+	select {
+	case <-done:
+	default:
+		p.log.Warn("shutdown timeout reached, aborting in-flight operations",
+			zap.Int64("in_flight", p.inFlight.Load()),
+			zap.Duration("shutdown_timeout", p.config.ShutdownTimeout),
+		)
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			p.log.Warn("operations did not stop in time, forcing stop")
+		}
+	}
+
+	p.operations.abortVolatileUploads(ctx)
+
+	// Deletes of draining operations changed the index after the worker stopped
+	p.index.save()
+	p.shutdown.log(p.log)
+
+	if err := p.slowLog.close(); err != nil {
+		p.log.Warn("failed to close slow log", zap.Error(err))
 	}
 
 	// Close all S3 clients
@@ -202,6 +450,12 @@ func (p *Plugin) Collects() []*dep.In {
 		dep.Fits(func(pp any) {
 			p.log = pp.(Logger).NamedLogger(PluginName)
 		}, (*Logger)(nil)),
+		dep.Fits(func(pp any) {
+			p.interceptors.Add(pp.(Interceptor))
+		}, (*Interceptor)(nil)),
+		dep.Fits(func(pp any) {
+			p.publisher = pp.(JobPublisher)
+		}, (*JobPublisher)(nil)),
 	}
 }
 
@@ -217,19 +471,36 @@ func (p *Plugin) GetContext() context.Context {
 	return p.ctx
 }
 
-// TrackOperation adds an operation to the wait group
-func (p *Plugin) TrackOperation() {
+// TrackOperation adds an operation to the wait group, or rejects it with a retryable
+// SHUTTING_DOWN error once Stop has begun
+func (p *Plugin) TrackOperation() error {
+	p.shutdownMu.RLock()
+	defer p.shutdownMu.RUnlock()
+
+	if p.shuttingDown {
+		return NewShuttingDownError()
+	}
+
 	p.wg.Add(1)
+	p.inFlight.Add(1)
+	return nil
 }
 
 // CompleteOperation marks an operation as complete
 func (p *Plugin) CompleteOperation() {
+	p.inFlight.Add(-1)
 	p.wg.Done()
 }
 
+// isShuttingDown reports whether Stop has begun
+func (p *Plugin) isShuttingDown() bool {
+	p.shutdownMu.RLock()
+	defer p.shutdownMu.RUnlock()
+	return p.shuttingDown
+}
+
 // MetricsCollector implements the StatProvider interface for Prometheus metrics integration
 // This method is called by the metrics plugin during its Serve phase to register all collectors
-// Metrics are also registered directly in Init() to ensure availability even if this method isn't called
 func (p *Plugin) MetricsCollector() []prometheus.Collector {
 	if p.metrics == nil {
 		return nil
@@ -0,0 +1,48 @@
+package s3
+
+import "fmt"
+
+const (
+	// PresetS3 talks to AWS S3 or an S3-compatible server (default)
+	PresetS3 = "s3"
+
+	// PresetGCS talks to Google Cloud Storage through its S3-interoperable XML API using HMAC keys.
+	// It presets the endpoint, region and compatibility of the S3 client; there is no native GCS backend.
+	PresetGCS = "gcs"
+
+	// gcsEndpoint is the Google Cloud Storage XML API endpoint
+	gcsEndpoint = "https://storage.googleapis.com"
+
+	// gcsRegion is the region GCS expects in signatures of interoperable requests
+	gcsRegion = "auto"
+)
+
+// applyPreset validates the server preset and fills in the S3 client settings it implies
+func (sc *ServerConfig) applyPreset() error {
+	// Every operation uses the S3 API; other storage services are not backends of their own
+	if sc.Driver != "" {
+		return fmt.Errorf("driver is not supported: there are no storage drivers (Azure Blob, SFTP and FTP are not supported), "+
+			"servers must speak the S3 API; use preset '%s' for Google Cloud Storage interoperability", PresetGCS)
+	}
+
+	switch sc.Preset {
+	case "":
+		sc.Preset = PresetS3
+	case PresetS3:
+	case PresetGCS:
+		if sc.Endpoint == "" {
+			sc.Endpoint = gcsEndpoint
+		}
+		if sc.Region == "" {
+			sc.Region = gcsRegion
+		}
+		// GCS rejects the SDK's flexible checksums and lacks some S3 APIs
+		if sc.Compatibility == "" {
+			sc.Compatibility = CompatibilityGeneric
+		}
+	default:
+		return fmt.Errorf("preset must be '%s' or '%s', got '%s'", PresetS3, PresetGCS, sc.Preset)
+	}
+
+	return nil
+}
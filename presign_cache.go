@@ -0,0 +1,121 @@
+package s3
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPresignCacheEntries bounds the cached URLs of a bucket
+	defaultPresignCacheEntries = 10000
+
+	// defaultPresignCacheMinRemaining is the share of expires_in a reused URL must still be valid for
+	defaultPresignCacheMinRemaining = 0.5
+)
+
+// PresignCacheConfig makes GetPublicURL reuse presigned URLs until near expiry, for pages
+// embedding hundreds of signed URLs that would otherwise be signed again on every render
+type PresignCacheConfig struct {
+	// MaxEntries bounds the number of cached URLs (default: 10000)
+	MaxEntries int `mapstructure:"max_entries" json:"max_entries"`
+
+	// MinRemaining is the share of expires_in a cached URL must still be valid for to be
+	// returned, 0 to 1; older URLs are signed again (default: 0.5)
+	MinRemaining float64 `mapstructure:"min_remaining" json:"min_remaining"`
+}
+
+// Validate validates the presign cache configuration and applies defaults
+func (pc *PresignCacheConfig) Validate() error {
+	if pc.MaxEntries == 0 {
+		pc.MaxEntries = defaultPresignCacheEntries
+	}
+	if pc.MaxEntries < 0 {
+		return fmt.Errorf("presign_cache.max_entries must not be negative")
+	}
+
+	if pc.MinRemaining == 0 {
+		pc.MinRemaining = defaultPresignCacheMinRemaining
+	}
+	if pc.MinRemaining < 0 || pc.MinRemaining > 1 {
+		return fmt.Errorf("presign_cache.min_remaining must be between 0 and 1, got %g", pc.MinRemaining)
+	}
+
+	return nil
+}
+
+// presignCacheKey identifies a presigned URL: the same key signed for another validity is another URL
+type presignCacheKey struct {
+	key     string
+	expires time.Duration
+}
+
+// presignedURL is a cached presigned URL
+type presignedURL struct {
+	url       string
+	expiresAt time.Time
+}
+
+// presignCache holds presigned GET URLs of a bucket
+type presignCache struct {
+	cfg *PresignCacheConfig
+
+	entries map[presignCacheKey]presignedURL
+	mu      sync.Mutex
+}
+
+func newPresignCache(cfg *PresignCacheConfig) *presignCache {
+	if cfg == nil {
+		return nil
+	}
+	return &presignCache{
+		cfg:     cfg,
+		entries: make(map[presignCacheKey]presignedURL),
+	}
+}
+
+// get returns the cached URL of key signed for expires, if it is still valid for at least
+// min_remaining of expires
+func (pc *presignCache) get(key string, expires time.Duration, now time.Time) (presignedURL, bool) {
+	if pc == nil {
+		return presignedURL{}, false
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	cached, ok := pc.entries[presignCacheKey{key: key, expires: expires}]
+	if !ok || cached.expiresAt.Sub(now) < time.Duration(float64(expires)*pc.cfg.MinRemaining) {
+		return presignedURL{}, false
+	}
+	return cached, true
+}
+
+// put caches url, making room by dropping stale URLs and, when still full, a tenth of the others
+func (pc *presignCache) put(key string, expires time.Duration, url presignedURL) {
+	if pc == nil {
+		return
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if len(pc.entries) >= pc.cfg.MaxEntries {
+		now := time.Now()
+		for k, cached := range pc.entries {
+			if cached.expiresAt.Sub(now) < time.Duration(float64(k.expires)*pc.cfg.MinRemaining) {
+				delete(pc.entries, k)
+			}
+		}
+
+		// Map iteration order is random, so this evicts arbitrary entries
+		for k := range pc.entries {
+			if len(pc.entries) < pc.cfg.MaxEntries-pc.cfg.MaxEntries/10 {
+				break
+			}
+			delete(pc.entries, k)
+		}
+	}
+
+	pc.entries[presignCacheKey{key: key, expires: expires}] = url
+}
@@ -0,0 +1,213 @@
+package s3
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.uber.org/zap"
+)
+
+// cloudFrontBase64 maps the base64 characters CloudFront does not accept in query strings
+var cloudFrontBase64 = strings.NewReplacer("+", "-", "=", "_", "/", "~")
+
+// validateSignedHeaders checks headers to be signed into a presigned URL. x-amz-* headers are moved
+// to the query string by presigning and Host is always signed, so neither can be required this way.
+func validateSignedHeaders(headers map[string]string) *S3Error {
+	for name, value := range headers {
+		lower := strings.ToLower(name)
+		switch {
+		case name == "" || strings.ContainsAny(name, " \t\r\n:()<>@,;\\\"/[]?={}") || !isASCII(name):
+			return NewInvalidRequestError(fmt.Sprintf("signed header name '%s' is not a valid header name", name))
+		case lower == "host" || lower == "authorization" || strings.HasPrefix(lower, "x-amz-"):
+			return NewInvalidRequestError(fmt.Sprintf("signed header '%s' cannot be required by a presigned URL", name))
+		case strings.ContainsAny(value, "\r\n") || !isASCII(value):
+			return NewInvalidRequestError(fmt.Sprintf("value of signed header '%s' must be ASCII on one line", name))
+		}
+	}
+	return nil
+}
+
+// signedHeadersMiddleware adds headers to a presigned request, so they are part of the signature
+// and requests without them are rejected by S3
+func signedHeadersMiddleware(headers map[string]string) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Build.Add(middleware.BuildMiddlewareFunc("SignedHeaders",
+			func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+				if req, ok := in.Request.(*smithyhttp.Request); ok {
+					for name, value := range headers {
+						req.Header.Set(name, value)
+					}
+				}
+				return next.HandleBuild(ctx, in)
+			}), middleware.After)
+	}
+}
+
+// requiredHeaders returns the signed headers a client must send with a presigned URL, without Host
+func requiredHeaders(signed http.Header) map[string]string {
+	headers := make(map[string]string, len(signed))
+	for name, values := range signed {
+		if strings.EqualFold(name, "host") || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// parseSourceIP returns source_ip as a CIDR; single addresses are widened to /32 or /128
+func parseSourceIP(sourceIP string) (string, error) {
+	if ip := net.ParseIP(sourceIP); ip != nil {
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, network, err := net.ParseCIDR(sourceIP)
+	if err != nil {
+		return "", fmt.Errorf("source_ip must be an IP address or CIDR, got '%s'", sourceIP)
+	}
+	return network.String(), nil
+}
+
+// loadSigningKey reads the CloudFront key pair's private key (PKCS#1 or PKCS#8 PEM)
+func (cc *CDNConfig) loadSigningKey() error {
+	if cc.KeyPairID == "" && cc.PrivateKeyFile == "" {
+		return nil
+	}
+	if cc.KeyPairID == "" || cc.PrivateKeyFile == "" {
+		return fmt.Errorf("cdn.key_pair_id and cdn.private_key_file must be set together")
+	}
+	if cc.URL == "" {
+		return fmt.Errorf("cdn.url is required to sign CDN URLs")
+	}
+
+	data, err := os.ReadFile(cc.PrivateKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read cdn.private_key_file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("cdn.private_key_file does not hold a PEM key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		cc.signingKey = key
+		return nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse cdn.private_key_file: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("cdn.private_key_file must hold an RSA key")
+	}
+	cc.signingKey = key
+	return nil
+}
+
+// cloudFrontPolicy is a CloudFront custom policy; field order is kept as documented
+type cloudFrontPolicy struct {
+	Statement []cloudFrontStatement `json:"Statement"`
+}
+
+type cloudFrontStatement struct {
+	Resource  string              `json:"Resource"`
+	Condition cloudFrontCondition `json:"Condition"`
+}
+
+type cloudFrontCondition struct {
+	DateLessThan map[string]int64  `json:"DateLessThan"`
+	IPAddress    map[string]string `json:"IpAddress,omitempty"`
+}
+
+// signURL returns a CloudFront signed URL for key with a custom policy limiting it to expires
+// and, when set, to clients from sourceCIDR
+func (cc *CDNConfig) signURL(key string, expires time.Time, sourceCIDR string) (string, error) {
+	resource := cc.URL + cc.cdnPath(key)
+
+	statement := cloudFrontStatement{Resource: resource}
+	statement.Condition.DateLessThan = map[string]int64{"AWS:EpochTime": expires.Unix()}
+	if sourceCIDR != "" {
+		statement.Condition.IPAddress = map[string]string{"AWS:SourceIp": sourceCIDR}
+	}
+
+	policy, err := json.Marshal(cloudFrontPolicy{Statement: []cloudFrontStatement{statement}})
+	if err != nil {
+		return "", err
+	}
+
+	// CloudFront signed URLs are defined over RSA-SHA1
+	digest := sha1.Sum(policy)
+	signature, err := rsa.SignPKCS1v15(nil, cc.signingKey, crypto.SHA1, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("Policy", cloudFrontBase64.Replace(base64.StdEncoding.EncodeToString(policy)))
+	query.Set("Signature", cloudFrontBase64.Replace(base64.StdEncoding.EncodeToString(signature)))
+	query.Set("Key-Pair-Id", cc.KeyPairID)
+
+	return resource + "?" + query.Encode(), nil
+}
+
+// signCDNURL answers GetPublicURL with a CloudFront signed URL limited to req.SourceIP
+func (o *Operations) signCDNURL(bucket *Bucket, req *GetPublicURLRequest, resp *GetPublicURLResponse, key string, expires time.Duration) error {
+	cdn := bucket.Config.CDN
+	if cdn == nil || cdn.signingKey == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrNotSupported)
+		return NewNotSupportedError("source_ip without cdn key_pair_id", req.Bucket)
+	}
+	if len(req.SignedHeaders) > 0 {
+		o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("signed_headers cannot be combined with source_ip")
+	}
+
+	cidr, err := parseSourceIP(req.SourceIP)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(err.Error())
+	}
+
+	expiresAt := time.Now().Add(expires)
+	signed, err := cdn.signURL(key, expiresAt, cidr)
+	if err != nil {
+		o.log.Error("failed to sign CDN URL",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("sign cdn url", err)
+	}
+
+	resp.URL = signed
+	resp.ExpiresAt = expiresAt.Unix()
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "get_url", "success")
+	return nil
+}
@@ -0,0 +1,226 @@
+package s3
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultPresignedPostExpiry is used when the request doesn't specify expires_in
+	defaultPresignedPostExpiry = 15 * time.Minute
+
+	// filenamePlaceholder is substituted by S3 with the name of the uploaded file
+	filenamePlaceholder = "${filename}"
+)
+
+// GetPresignedPost generates a presigned POST policy that browsers can use to upload directly to S3
+func (o *Operations) GetPresignedPost(ctx context.Context, req *GetPresignedPostRequest, resp *GetPresignedPostResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Validate request
+	if err := o.validatePresignedPostRequest(req); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	if err := o.route(&req.Bucket, req.Pathname, "presign_post"); err != nil {
+		return err
+	}
+
+	// Get bucket
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access (pathname and key_prefix are mutually exclusive, so one of them is empty)
+	if err := o.authorize(ctx, req.Caller, "GetPresignedPost", "presign_post", bucket.Name, req.Pathname+req.KeyPrefix); err != nil {
+		return err
+	}
+
+	// Browser uploads would bypass the audit chain
+	if err := bucket.requireAppendOnly("GetPresignedPost"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	// Access points do not accept browser POST uploads
+	if _, ok := bucket.Config.accessPoint(); ok {
+		o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrNotSupported)
+		return NewNotSupportedError("presigned POST through an access point", bucket.Name)
+	}
+
+	// Blobs are only written by deduplicated writes
+	blobErr := bucket.checkBlobPathname(req.Pathname)
+	if req.KeyPrefix != "" {
+		blobErr = bucket.checkBlobPrefix(req.KeyPrefix)
+	}
+	if blobErr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "error")
+		o.plugin.metrics.RecordError(req.Bucket, blobErr.Code)
+		return blobErr
+	}
+
+	// The browser picks the final key, so it cannot carry the shard
+	if req.KeyPrefix != "" {
+		if err := bucket.requireUnsharded("key_prefix"); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return err
+		}
+	}
+
+	// The browser may pick the extension of a key under key_prefix, which no extension rule can check
+	wp := bucket.Config.WritePolicy
+	if wp != nil && req.KeyPrefix != "" && (len(wp.AllowedExtensions) > 0 || len(wp.DeniedExtensions) > 0) {
+		o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("key_prefix cannot be used on buckets with write_policy extension rules")
+	}
+	if err := o.enforcePolicy(ctx, bucket, "presign_post", req.Pathname+req.KeyPrefix, req.ContentLengthMin); err != nil {
+		return err
+	}
+
+	expires := defaultPresignedPostExpiry
+	if req.ExpiresIn > 0 {
+		expires = time.Duration(req.ExpiresIn) * time.Second
+	}
+
+	// Build the policy conditions and the matching form fields
+	var key string
+	conditions := make([]any, 0, 6)
+	fields := make(map[string]string)
+
+	if req.KeyPrefix != "" {
+		keyPrefix := bucket.GetFullPath(req.KeyPrefix)
+		key = keyPrefix + filenamePlaceholder
+		conditions = append(conditions, []any{"starts-with", "$key", keyPrefix})
+	} else {
+		key = bucket.ObjectKey(req.Pathname)
+	}
+
+	// Buckets with ACLs disabled reject uploads carrying an acl field
+	if acl := bucket.ObjectACL(req.Visibility); acl != "" {
+		conditions = append(conditions, map[string]string{"acl": string(acl)})
+		fields["acl"] = string(acl)
+	}
+
+	// S3 enforces the size of the upload, so the range is capped at write_policy.max_size
+	if req.ContentLengthMin > 0 || req.ContentLengthMax > 0 || (wp != nil && wp.MaxSize > 0) {
+		maxSize := req.ContentLengthMax
+		if maxSize <= 0 {
+			maxSize = 5 * 1024 * 1024 * 1024 // 5GB single POST upload limit
+		}
+		if wp != nil && wp.MaxSize > 0 {
+			maxSize = min(maxSize, wp.MaxSize)
+		}
+		conditions = append(conditions, []any{"content-length-range", req.ContentLengthMin, maxSize})
+	}
+
+	if req.ContentType != "" {
+		conditions = append(conditions, map[string]string{"Content-Type": req.ContentType})
+		fields["Content-Type"] = req.ContentType
+	} else if req.ContentTypeStartsWith != "" {
+		conditions = append(conditions, []any{"starts-with", "$Content-Type", req.ContentTypeStartsWith})
+	}
+
+	if req.SuccessActionRedirect != "" {
+		conditions = append(conditions, map[string]string{"success_action_redirect": req.SuccessActionRedirect})
+		fields["success_action_redirect"] = req.SuccessActionRedirect
+	}
+
+	if req.SuccessActionStatus != "" {
+		conditions = append(conditions, map[string]string{"success_action_status": req.SuccessActionStatus})
+		fields["success_action_status"] = req.SuccessActionStatus
+	}
+
+	// Generate presigned POST
+	presignClient := s3.NewPresignClient(bucket.PostPresigner())
+	result, err := presignClient.PresignPostObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(key),
+	}, func(opts *s3.PresignPostOptions) {
+		opts.Expires = expires
+		opts.Conditions = conditions
+	})
+	if err != nil {
+		o.log.Error("failed to generate presigned POST",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.String("key_prefix", req.KeyPrefix),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("presign post object", err)
+	}
+
+	// Signed fields take precedence over the ones derived from conditions
+	for k, v := range result.Values {
+		fields[k] = v
+	}
+
+	resp.URL = result.URL
+	resp.Fields = fields
+	resp.ExpiresAt = time.Now().Add(expires).Unix()
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "presign_post", "success")
+
+	return nil
+}
+
+// validatePresignedPostRequest checks the key selection and condition values of a presigned POST request
+func (o *Operations) validatePresignedPostRequest(req *GetPresignedPostRequest) *S3Error {
+	if req.Pathname != "" && req.KeyPrefix != "" {
+		return NewInvalidRequestError("pathname and key_prefix are mutually exclusive")
+	}
+
+	if req.KeyPrefix != "" {
+		if err := o.validatePathname(&req.KeyPrefix); err != nil {
+			return err.(*S3Error)
+		}
+	} else if err := o.validatePathname(&req.Pathname); err != nil {
+		return err.(*S3Error)
+	}
+
+	if req.ExpiresIn < 0 || time.Duration(req.ExpiresIn)*time.Second > 7*24*time.Hour {
+		return NewInvalidRequestError("expires_in must be between 0 and 604800 seconds")
+	}
+
+	if req.ContentLengthMin < 0 || req.ContentLengthMax < 0 {
+		return NewInvalidRequestError("content length range cannot be negative")
+	}
+
+	if req.ContentLengthMax > 0 && req.ContentLengthMin > req.ContentLengthMax {
+		return NewInvalidRequestError("content_length_min cannot exceed content_length_max")
+	}
+
+	if req.ContentType != "" && req.ContentTypeStartsWith != "" {
+		return NewInvalidRequestError("content_type and content_type_starts_with are mutually exclusive")
+	}
+
+	if req.Visibility != "" && req.Visibility != "public" && req.Visibility != "private" {
+		return NewS3Error(ErrInvalidVisibility, "visibility must be 'public' or 'private'", req.Visibility)
+	}
+
+	if req.SuccessActionStatus != "" {
+		if status, err := strconv.Atoi(req.SuccessActionStatus); err != nil || (status != 200 && status != 201 && status != 204) {
+			return NewInvalidRequestError("success_action_status must be 200, 201 or 204")
+		}
+	}
+
+	return nil
+}
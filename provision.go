@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+// localEndpointHosts are host names of typical dev/test S3 services (docker compose service names)
+var localEndpointHosts = []string{"localhost", "localstack", "minio"}
+
+// isLocalEndpoint reports whether endpoint points at a local dev/test S3 service
+func isLocalEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, local := range localEndpointHosts {
+		if host == local || strings.HasSuffix(host, "."+local) {
+			return true
+		}
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate())
+}
+
+// allowsBucketCreation reports whether buckets may be created on the server at registration:
+// always for local endpoints and MinIO, elsewhere only with allow_bucket_creation
+func (sc *ServerConfig) allowsBucketCreation() bool {
+	return sc.AllowBucketCreation || sc.Compatibility == CompatibilityMinIO || isLocalEndpoint(sc.Endpoint)
+}
+
+// createBucketIfMissing creates the underlying S3 bucket unless it already exists
+func (bm *BucketManager) createBucketIfMissing(ctx context.Context, bucket *Bucket) error {
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucket.Config.Bucket)}
+
+	// us-east-1 is the default location and must not be sent as a constraint
+	if region := bucket.Client.Options().Region; region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+
+	_, err := bucket.Client.CreateBucket(ctx, input)
+	if err != nil {
+		var owned *types.BucketAlreadyOwnedByYou
+		if errors.As(err, &owned) {
+			return nil
+		}
+		return fmt.Errorf("failed to create bucket '%s': %w", bucket.Config.Bucket, err)
+	}
+
+	bm.log.Info("bucket created",
+		zap.String("name", bucket.Name),
+		zap.String("bucket", bucket.Config.Bucket),
+	)
+
+	return nil
+}
@@ -0,0 +1,548 @@
+package s3
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// publishJobType identifies directory publishing jobs
+	publishJobType = "publish"
+
+	// PublishCompressGzip produces ".gz" variants with Content-Encoding: gzip
+	PublishCompressGzip = "gzip"
+
+	// PublishCompressBrotli produces ".br" variants with Content-Encoding: br
+	PublishCompressBrotli = "br"
+)
+
+// PublishConfig configures PublishDirectory; omit to disable publishing from local directories
+type PublishConfig struct {
+	// Roots lists local directories that may be published (a source dir must be inside one)
+	Roots []string `mapstructure:"roots"`
+
+	// CacheControl is the default Cache-Control header for published files (optional)
+	CacheControl string `mapstructure:"cache_control"`
+
+	// Rules override headers per file extension; the first matching rule applies
+	Rules []PublishRule `mapstructure:"rules"`
+
+	// Compress lists pre-compressed variants to upload: "gzip", "br"
+	Compress []string `mapstructure:"compress"`
+
+	// CompressExtensions lists extensions eligible for compression
+	// (default: html, htm, css, js, mjs, json, map, svg, xml, txt)
+	CompressExtensions []string `mapstructure:"compress_extensions"`
+
+	// CompressMinSize skips compression of smaller files in bytes (default: 1024)
+	CompressMinSize int64 `mapstructure:"compress_min_size"`
+}
+
+// PublishRule sets headers for files with the given extensions
+type PublishRule struct {
+	// Extensions without the leading dot (e.g., ["html", "htm"])
+	Extensions []string `mapstructure:"extensions"`
+
+	// CacheControl overrides the default Cache-Control header
+	CacheControl string `mapstructure:"cache_control"`
+
+	// ContentType overrides the detected content type
+	ContentType string `mapstructure:"content_type"`
+}
+
+// Validate validates the publish configuration and sets defaults
+func (pc *PublishConfig) Validate() error {
+	if len(pc.Roots) == 0 {
+		return fmt.Errorf("at least one root must be configured")
+	}
+
+	roots, err := absRoots(pc.Roots)
+	if err != nil {
+		return err
+	}
+	pc.Roots = roots
+
+	for _, format := range pc.Compress {
+		if format != PublishCompressGzip && format != PublishCompressBrotli {
+			return fmt.Errorf("compress must contain only 'gzip' or 'br', got '%s'", format)
+		}
+	}
+
+	for i, rule := range pc.Rules {
+		if len(rule.Extensions) == 0 {
+			return fmt.Errorf("rule %d: extensions are required", i)
+		}
+		for j, ext := range rule.Extensions {
+			pc.Rules[i].Extensions[j] = strings.ToLower(strings.TrimPrefix(ext, "."))
+		}
+	}
+
+	if len(pc.CompressExtensions) == 0 {
+		pc.CompressExtensions = []string{"html", "htm", "css", "js", "mjs", "json", "map", "svg", "xml", "txt"}
+	}
+
+	if pc.CompressMinSize <= 0 {
+		pc.CompressMinSize = 1024
+	}
+
+	return nil
+}
+
+// rule returns the first rule matching the extension, or nil
+func (pc *PublishConfig) rule(ext string) *PublishRule {
+	for i := range pc.Rules {
+		if slices.Contains(pc.Rules[i].Extensions, ext) {
+			return &pc.Rules[i]
+		}
+	}
+	return nil
+}
+
+// absRoots resolves configured local roots to absolute paths without symlinks
+func absRoots(roots []string) ([]string, error) {
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err == nil {
+			abs, err = filepath.EvalSymlinks(abs)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid root '%s': %w", root, err)
+		}
+		resolved = append(resolved, abs)
+	}
+	return resolved, nil
+}
+
+// resolveLocalPath returns p as an absolute path without symlinks. A missing last element
+// (a file about to be created) is kept below its resolved parent directory.
+func resolveLocalPath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return resolved, err
+	}
+
+	// A dangling symlink would be followed when the file is created
+	if _, err := os.Lstat(abs); err == nil {
+		return "", fmt.Errorf("'%s' is a dangling symlink", p)
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+// allowedLocalPath resolves p and reports whether it is inside one of the roots,
+// so symlinks cannot lead outside of them
+func allowedLocalPath(roots []string, p string) (string, bool) {
+	resolved, err := resolveLocalPath(p)
+	if err != nil {
+		return "", false
+	}
+
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, true
+		}
+	}
+	return "", false
+}
+
+// publishVariant is a single object produced from a local file
+type publishVariant struct {
+	key      string
+	source   string
+	path     string // Local file holding the content, a temporary file for compressed variants
+	size     int64
+	md5      string // Hex digest compared with the ETag of the published object
+	encoding string
+}
+
+// StartPublishDirectory validates a publish request and launches it as an async job
+func (o *Operations) StartPublishDirectory(ctx context.Context, req *PublishDirectoryRequest, resp *StartJobResponse) error {
+	pc := o.plugin.config.Publish
+	if pc == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("publishing is disabled (publish is not configured)")
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "PublishDirectory", "publish", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	if err := bucket.requireAppendOnly("PublishDirectory"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	// Pruning would delete the blobs under the prefix
+	if req.DeleteRemoved {
+		if err := bucket.checkBlobPrefix(req.Prefix); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+			o.plugin.metrics.RecordError(req.Bucket, err.Code)
+			return err
+		}
+	}
+
+	// Published sites are served by key, which sharding changes
+	if err := bucket.requireUnsharded("publishing"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	if strings.Contains(req.Prefix, "..") {
+		o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("prefix cannot contain '..'")
+	}
+
+	// Never sweep a whole bucket
+	if req.DeleteRemoved && req.Prefix == "" {
+		o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("delete_removed requires a prefix")
+	}
+
+	source, allowed := allowedLocalPath(pc.Roots, req.SourceDir)
+	if !allowed {
+		o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrPermissionDenied)
+		return NewS3Error(ErrPermissionDenied, "source_dir is outside the configured publish roots", req.SourceDir)
+	}
+
+	if info, err := os.Stat(source); err != nil || !info.IsDir() {
+		o.plugin.metrics.RecordOperation(req.Bucket, "publish", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("source_dir '%s' is not a directory", req.SourceDir))
+	}
+
+	job, err := o.plugin.jobs.Start(ctx, publishJobType, "", []string{bucket.Name}, req, func(ctx context.Context, job *Job) error {
+		return o.runPublish(ctx, job, pc, bucket.Name, source, req.Prefix, req.DeleteRemoved)
+	})
+	if err != nil {
+		return asS3Error("start publish", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// runPublish uploads changed files, their compressed variants and optionally deletes removed objects
+func (o *Operations) runPublish(ctx context.Context, job *Job, pc *PublishConfig, name, source, prefix string, deleteRemoved bool) error {
+	bucket, err := o.plugin.buckets.GetBucket(name)
+	if err != nil {
+		return NewBucketNotFoundError(name)
+	}
+
+	// Existing ETags let unchanged files be skipped
+	job.SetStatus(JobRunning, "listing existing objects")
+	existing := make(map[string]string)
+	listPrefix := bucket.GetFullPath(prefix)
+	err = o.walkObjects(ctx, bucket, listPrefix, func(obj types.Object) error {
+		existing[strings.TrimPrefix(aws.ToString(obj.Key), listPrefix)] = strings.Trim(aws.ToString(obj.ETag), `"`)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	job.SetStatus(JobRunning, "uploading files")
+	published := make(map[string]bool)
+	err = filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Symlinks and special files are never published, so nothing outside the root is read
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if err := checkPathname(prefix + rel); err != nil {
+			job.AddFailure(rel, err)
+			return nil
+		}
+
+		variants, err := publishVariants(pc, path, rel)
+		if err != nil {
+			job.AddFailure(rel, err)
+			return nil
+		}
+		defer removeVariants(variants)
+
+		for _, variant := range variants {
+			published[variant.key] = true
+
+			if existing[variant.key] == variant.md5 {
+				continue
+			}
+
+			if err := bucket.checkBlobPathname(prefix + variant.key); err != nil {
+				job.AddFailure(variant.key, err)
+				continue
+			}
+			if err := o.enforcePolicy(ctx, bucket, "publish", prefix+variant.key, variant.size); err != nil {
+				job.AddFailure(variant.key, err)
+				continue
+			}
+
+			if err := o.publishObject(ctx, bucket, pc, prefix, variant); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				o.plugin.metrics.RecordOperation(name, "publish", "error")
+				o.plugin.metrics.RecordError(name, ErrS3Operation)
+				job.AddFailure(variant.key, err)
+				continue
+			}
+
+			o.plugin.metrics.RecordOperation(name, "publish", "success")
+			job.AddProgress(variant.size)
+		}
+
+		job.SetCheckpoint(rel)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk source directory: %w", err)
+	}
+
+	if !deleteRemoved {
+		return nil
+	}
+
+	job.SetStatus(JobRunning, "deleting removed files")
+	for key := range existing {
+		if published[key] {
+			continue
+		}
+
+		if err := bucket.Acquire(ctx); err != nil {
+			return err
+		}
+		_, err := bucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    aws.String(listPrefix + key),
+		})
+		bucket.Release()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			job.AddFailure(key, err)
+			continue
+		}
+		o.plugin.index.remove(bucket, prefix+key)
+
+		loggerFor(ctx, o.log).Debug("removed file unpublished",
+			zap.String("job", job.ID),
+			zap.String("bucket", name),
+			zap.String("key", key),
+		)
+	}
+
+	return nil
+}
+
+// publishObject uploads a single variant with headers from the publish rules
+func (o *Operations) publishObject(ctx context.Context, bucket *Bucket, pc *PublishConfig, prefix string, variant publishVariant) error {
+	// Variants share the headers of the original file
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(variant.source), "."))
+
+	contentType := mime.TypeByExtension("." + ext)
+	if contentType == "" {
+		contentType = o.detectContentType(variant.source, nil)
+	}
+	cacheControl := pc.CacheControl
+	if rule := pc.rule(ext); rule != nil {
+		if rule.ContentType != "" {
+			contentType = rule.ContentType
+		}
+		if rule.CacheControl != "" {
+			cacheControl = rule.CacheControl
+		}
+	}
+
+	f, err := os.Open(variant.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket.Config.Bucket),
+		Key:         aws.String(bucket.GetFullPath(prefix + variant.key)),
+		Body:        f,
+		ACL:         bucket.ObjectACL(""),
+		ContentType: aws.String(contentType),
+	}
+	if cacheControl != "" {
+		input.CacheControl = aws.String(cacheControl)
+	}
+	if variant.encoding != "" {
+		input.ContentEncoding = aws.String(variant.encoding)
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	uploader := bucket.NewUploader(variant.size)
+	if _, err := uploader.Upload(ctx, input); err != nil {
+		o.abortInterruptedUpload(ctx, bucket, aws.ToString(input.Key), err)
+		return err
+	}
+	bucket.filter.add(aws.ToString(input.Key))
+	o.plugin.index.update(bucket, prefix+variant.key)
+	return nil
+}
+
+// publishVariants hashes a file and writes its compressed variants to temporary files,
+// streaming so that large files are never held in memory. The caller removes them with removeVariants.
+func publishVariants(pc *PublishConfig, path, rel string) ([]publishVariant, error) {
+	size, sum, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := []publishVariant{{key: rel, source: rel, path: path, size: size, md5: sum}}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(rel), "."))
+	if size < pc.CompressMinSize || !slices.Contains(pc.CompressExtensions, ext) {
+		return variants, nil
+	}
+
+	for _, format := range pc.Compress {
+		variant, err := compressVariant(path, format)
+		if err != nil {
+			removeVariants(variants)
+			return nil, err
+		}
+
+		// Variants that don't save space are not worth serving
+		if variant.size >= size {
+			os.Remove(variant.path)
+			continue
+		}
+
+		suffix := ".gz"
+		if format == PublishCompressBrotli {
+			suffix = ".br"
+		}
+		variant.key, variant.source = rel+suffix, rel
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+// hashFile returns the size and hex MD5 digest of a file
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// compressVariant compresses a file in the given format into a temporary file
+func compressVariant(path, format string) (publishVariant, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return publishVariant{}, err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp("", "rr-s3-publish-*")
+	if err != nil {
+		return publishVariant{}, err
+	}
+
+	hash := md5.New()
+	out := io.MultiWriter(tmp, hash)
+	var w io.WriteCloser
+	if format == PublishCompressBrotli {
+		w = brotli.NewWriterLevel(out, brotli.BestCompression)
+	} else {
+		w, _ = gzip.NewWriterLevel(out, gzip.BestCompression)
+	}
+
+	_, err = io.Copy(w, in)
+	if err == nil {
+		err = w.Close()
+	}
+	var size int64
+	if err == nil {
+		size, err = tmp.Seek(0, io.SeekCurrent)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return publishVariant{}, err
+	}
+
+	return publishVariant{path: tmp.Name(), size: size, md5: hex.EncodeToString(hash.Sum(nil)), encoding: format}, nil
+}
+
+// removeVariants deletes the temporary files of compressed variants
+func removeVariants(variants []publishVariant) {
+	for _, variant := range variants {
+		if variant.encoding != "" {
+			os.Remove(variant.path)
+		}
+	}
+}
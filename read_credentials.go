@@ -0,0 +1,75 @@
+package s3
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	// ReadScopeRead signs reads (Read, Exists, GetMetadata, listings, download sessions) and
+	// presigned URLs with the read credentials
+	ReadScopeRead = "read"
+
+	// ReadScopePresign signs presigned URLs and presigned POST policies with the read credentials only
+	ReadScopePresign = "presign"
+)
+
+// ReadCredentialsConfig is a second, narrower credential set of a bucket for least-privilege
+// setups: presigned URLs handed to browsers carry a key that cannot write, while server-side
+// writes keep the server credentials
+type ReadCredentialsConfig struct {
+	// Scope selects what the credentials sign: "read" (default) or "presign"
+	Scope string `mapstructure:"scope" json:"scope"`
+
+	// Credentials is the access key pair used within the scope
+	Credentials ServerCredentials `mapstructure:"credentials" json:"credentials"`
+}
+
+// Validate validates the read credentials and applies defaults
+func (rc *ReadCredentialsConfig) Validate() error {
+	switch rc.Scope {
+	case "":
+		rc.Scope = ReadScopeRead
+	case ReadScopeRead, ReadScopePresign:
+	default:
+		return fmt.Errorf("invalid read_credentials.scope '%s', must be '%s' or '%s'", rc.Scope, ReadScopeRead, ReadScopePresign)
+	}
+
+	if rc.Credentials.Key == "" || rc.Credentials.Secret == "" {
+		return fmt.Errorf("read_credentials.credentials requires key and secret")
+	}
+	return nil
+}
+
+// provider returns the static credentials provider of the read credentials
+func (rc *ReadCredentialsConfig) provider() aws.CredentialsProvider {
+	return credentials.NewStaticCredentialsProvider(rc.Credentials.Key, rc.Credentials.Secret, rc.Credentials.Token)
+}
+
+// Reader returns the client for reads: the read_credentials client with scope "read", else Client
+func (b *Bucket) Reader() *s3.Client {
+	if b.readClient != nil && b.Config.ReadCredentials.Scope == ReadScopeRead {
+		return b.readClient
+	}
+	return b.Client
+}
+
+// Presigner returns the client signing presigned GET URLs: the read_credentials client if any, else Client
+func (b *Bucket) Presigner() *s3.Client {
+	if b.readClient != nil {
+		return b.readClient
+	}
+	return b.Client
+}
+
+// PostPresigner returns the client signing presigned POST uploads: the read_credentials client with
+// scope "presign", else Client, as read-only keys cannot authorize uploads
+func (b *Bucket) PostPresigner() *s3.Client {
+	if b.readClient != nil && b.Config.ReadCredentials.Scope == ReadScopePresign {
+		return b.readClient
+	}
+	return b.Client
+}
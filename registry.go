@@ -0,0 +1,275 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// registryStateFile is the file under state_dir holding dynamically registered servers and buckets
+const registryStateFile = "registry.json"
+
+// Registry persists servers and buckets registered via RPC so they survive restarts
+type Registry struct {
+	// Persisted state
+	state registryState
+
+	// Path of the state file
+	path string
+
+	// Encrypts server credentials
+	box *secretBox
+
+	// Logger
+	log *zap.Logger
+
+	// Mutex for thread-safe access
+	mu sync.Mutex
+}
+
+// registryState is the on-disk format of the registry
+type registryState struct {
+	Servers map[string]persistedServer `json:"servers"`
+	Buckets map[string]persistedBucket `json:"buckets"`
+}
+
+// persistedServer is a server configuration with encrypted credentials
+type persistedServer struct {
+	Preset             string         `json:"preset,omitempty"`
+	Region             string         `json:"region"`
+	Endpoint           string         `json:"endpoint,omitempty"`
+	CopySourceEncoding string         `json:"copy_source_encoding,omitempty"`
+	Signing            *SigningConfig `json:"signing,omitempty"`
+	Compatibility      string         `json:"compatibility,omitempty"`
+
+	// Credentials is the AES-GCM encrypted JSON of ServerCredentials
+	Credentials string `json:"credentials"`
+}
+
+// persistedBucket is a dynamically registered bucket configuration
+type persistedBucket struct {
+	Server     string `json:"server"`
+	Bucket     string `json:"bucket"`
+	Prefix     string `json:"prefix,omitempty"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// persistedCredentials is the plaintext form of encrypted server credentials
+type persistedCredentials struct {
+	Key    string `json:"key"`
+	Secret string `json:"secret"`
+	Token  string `json:"token,omitempty"`
+}
+
+// NewRegistry creates a registry persisting to stateDir with credentials encrypted by encryptionKey
+func NewRegistry(stateDir string, encryptionKey string, log *zap.Logger) (*Registry, error) {
+	box, err := newSecretBox(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Registry{
+		state: registryState{
+			Servers: make(map[string]persistedServer),
+			Buckets: make(map[string]persistedBucket),
+		},
+		path: filepath.Join(stateDir, registryStateFile),
+		box:  box,
+		log:  log,
+	}, nil
+}
+
+// Restore registers servers and buckets persisted by a previous plugin run
+func (r *Registry) Restore(ctx context.Context, buckets *BucketManager) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			r.log.Warn("failed to read registry state", zap.String("file", r.path), zap.Error(err))
+		}
+		return
+	}
+
+	var state registryState
+	if err := json.Unmarshal(data, &state); err != nil {
+		r.log.Warn("invalid registry state", zap.String("file", r.path), zap.Error(err))
+		return
+	}
+
+	// Entries that fail to restore are kept so they are not lost (e.g. after a wrong encryption key);
+	// use PurgeRegistrations to drop them
+	if state.Servers != nil {
+		r.state.Servers = state.Servers
+	}
+	if state.Buckets != nil {
+		r.state.Buckets = state.Buckets
+	}
+
+	var servers, bucketCount int
+
+	// Servers first, buckets may reference them
+	for _, name := range slices.Sorted(maps.Keys(r.state.Servers)) {
+		cfg, err := r.decodeServer(r.state.Servers[name])
+		if err == nil {
+			err = buckets.RegisterServer(name, cfg)
+		}
+		if err != nil {
+			r.log.Warn("failed to restore server", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		servers++
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(r.state.Buckets)) {
+		entry := r.state.Buckets[name]
+		cfg := &BucketConfig{
+			Server:     entry.Server,
+			Bucket:     entry.Bucket,
+			Prefix:     entry.Prefix,
+			Visibility: entry.Visibility,
+		}
+		if err := buckets.RegisterBucket(ctx, name, cfg); err != nil {
+			r.log.Warn("failed to restore bucket", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		bucketCount++
+	}
+
+	r.log.Info("dynamic registrations restored",
+		zap.Int("servers", servers),
+		zap.Int("buckets", bucketCount),
+	)
+}
+
+// AddServer persists a server registered via RPC
+func (r *Registry) AddServer(name string, cfg *ServerConfig) error {
+	if r == nil {
+		return nil
+	}
+
+	creds, err := json.Marshal(persistedCredentials{
+		Key:    cfg.Credentials.Key,
+		Secret: cfg.Credentials.Secret,
+		Token:  cfg.Credentials.Token,
+	})
+	if err != nil {
+		return err
+	}
+
+	sealed, err := r.box.seal(creds)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state.Servers[name] = persistedServer{
+		Preset:             cfg.Preset,
+		Region:             cfg.Region,
+		Endpoint:           cfg.Endpoint,
+		CopySourceEncoding: cfg.CopySourceEncoding,
+		Signing:            cfg.Signing,
+		Compatibility:      cfg.Compatibility,
+		Credentials:        sealed,
+	}
+	return r.saveLocked()
+}
+
+// AddBucket persists a bucket registered via RPC
+func (r *Registry) AddBucket(name string, cfg *BucketConfig) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state.Buckets[name] = persistedBucket{
+		Server:     cfg.Server,
+		Bucket:     cfg.Bucket,
+		Prefix:     cfg.Prefix,
+		Visibility: cfg.Visibility,
+	}
+	return r.saveLocked()
+}
+
+// Purge removes persisted entries; with all set every entry is removed.
+// Runtime registrations are kept until the next restart.
+func (r *Registry) Purge(servers, buckets []string, all bool) (purgedServers, purgedBuckets []string, err error) {
+	if r == nil {
+		return nil, nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if all {
+		servers = slices.Sorted(maps.Keys(r.state.Servers))
+		buckets = slices.Sorted(maps.Keys(r.state.Buckets))
+	}
+
+	for _, name := range servers {
+		if _, exists := r.state.Servers[name]; exists {
+			delete(r.state.Servers, name)
+			purgedServers = append(purgedServers, name)
+		}
+	}
+
+	for _, name := range buckets {
+		if _, exists := r.state.Buckets[name]; exists {
+			delete(r.state.Buckets, name)
+			purgedBuckets = append(purgedBuckets, name)
+		}
+	}
+
+	return purgedServers, purgedBuckets, r.saveLocked()
+}
+
+// decodeServer decrypts a persisted server configuration
+func (r *Registry) decodeServer(entry persistedServer) (*ServerConfig, error) {
+	plaintext, err := r.box.open(entry.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds persistedCredentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, err
+	}
+
+	return &ServerConfig{
+		Preset:             entry.Preset,
+		Region:             entry.Region,
+		Endpoint:           entry.Endpoint,
+		CopySourceEncoding: entry.CopySourceEncoding,
+		Signing:            entry.Signing,
+		Compatibility:      entry.Compatibility,
+		Credentials: ServerCredentials{
+			Key:    creds.Key,
+			Secret: creds.Secret,
+			Token:  creds.Token,
+		},
+	}, nil
+}
+
+// saveLocked writes the registry state to disk
+func (r *Registry) saveLocked() error {
+	data, err := json.Marshal(r.state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.path, data)
+}
@@ -0,0 +1,356 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// reindexJobType identifies object index rebuild jobs
+	reindexJobType = "reindex"
+
+	// reindexStateDir is the sub-directory of state_dir holding reindex checkpoints
+	reindexStateDir = "reindex"
+)
+
+// reindexCheckpoint is the persisted state used to resume a reindex after a restart
+type reindexCheckpoint struct {
+	JobID     string         `json:"job_id"`
+	Request   ReindexRequest `json:"request"`
+	LastKey   string         `json:"last_key"`
+	Objects   int64          `json:"objects"`
+	Bytes     int64          `json:"bytes"`
+	Failed    int64          `json:"failed"`
+	StartedAt int64          `json:"started_at"` // Entries indexed before it are stale once the walk completes
+	UpdatedAt int64          `json:"updated_at"`
+
+	// PageToken resumes listings of directory buckets, which cannot start after LastKey
+	PageToken string `json:"page_token,omitempty"`
+}
+
+// StartReindex validates a reindex request and launches it as an async job
+func (o *Operations) StartReindex(ctx context.Context, req *ReindexRequest, resp *StartJobResponse) error {
+	if err := o.validateReindexRequest(req); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "reindex", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	// Check access to the prefix
+	if err := o.authorizeIn(ctx, req.Caller, "StartReindex", "reindex", req.Bucket, req.Prefix); err != nil {
+		return err
+	}
+
+	// Credentials are not persisted with the checkpoint; the caller metadata is, for the logs of a resumed job
+	checkpoint := &reindexCheckpoint{Request: *req, StartedAt: time.Now().Unix()}
+	checkpoint.Request.Caller.Token = ""
+
+	job, err := o.startReindexJob(ctx, "", checkpoint)
+	if err != nil {
+		return asS3Error("start reindex", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// ResumeReindexes restarts reindexes that were interrupted by a previous shutdown
+func (o *Operations) ResumeReindexes() {
+	dir := o.reindexStatePath()
+	if dir == "" || o.plugin.index == nil {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			o.log.Warn("failed to read reindex checkpoints", zap.String("dir", dir), zap.Error(err))
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			o.log.Warn("failed to read reindex checkpoint", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		var checkpoint reindexCheckpoint
+		if err := json.Unmarshal(data, &checkpoint); err != nil {
+			o.log.Warn("invalid reindex checkpoint", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		ctx := withCallerMetadata(o.plugin.ctx, checkpoint.Request.Metadata)
+		if _, err := o.startReindexJob(ctx, checkpoint.JobID, &checkpoint); err != nil {
+			o.log.Warn("failed to resume reindex", zap.String("id", checkpoint.JobID), zap.Error(err))
+			continue
+		}
+
+		o.log.Info("reindex resumed from checkpoint",
+			zap.String("id", checkpoint.JobID),
+			zap.String("last_key", checkpoint.LastKey),
+		)
+	}
+}
+
+// startReindexJob launches the reindex body for a fresh or restored checkpoint
+func (o *Operations) startReindexJob(ctx context.Context, id string, checkpoint *reindexCheckpoint) (*Job, error) {
+	buckets := []string{o.plugin.buckets.resolve(checkpoint.Request.Bucket)}
+	return o.plugin.jobs.Start(ctx, reindexJobType, id, buckets, checkpoint.Request, func(ctx context.Context, job *Job) error {
+		checkpoint.JobID = job.ID
+		job.Restore(checkpoint.Objects, checkpoint.Bytes, checkpoint.Failed, checkpoint.LastKey)
+
+		err := o.runReindex(ctx, job, checkpoint)
+		// Keep the checkpoint only when the job can be resumed later
+		if err == nil || job.Cancelled() {
+			o.removeReindexCheckpoint(job.ID)
+		}
+		return err
+	})
+}
+
+// runReindex indexes every object under the prefix and finally drops entries of objects it did not see
+func (o *Operations) runReindex(ctx context.Context, job *Job, checkpoint *reindexCheckpoint) error {
+	req := checkpoint.Request
+
+	for {
+		if err := waitForWindow(ctx, job, req.WindowStart, req.WindowEnd); err != nil {
+			return err
+		}
+
+		bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+		if err != nil {
+			return NewBucketNotFoundError(req.Bucket)
+		}
+
+		input := &s3.ListObjectsV2Input{
+			Bucket:  aws.String(bucket.Config.Bucket),
+			Prefix:  aws.String(bucket.listPrefix(req.Prefix)),
+			MaxKeys: aws.Int32(maxListKeys),
+		}
+		if bucket.Config.DirectoryBucket {
+			if checkpoint.PageToken != "" {
+				input.ContinuationToken = aws.String(checkpoint.PageToken)
+			}
+		} else if checkpoint.LastKey != "" {
+			input.StartAfter = aws.String(checkpoint.LastKey)
+		}
+
+		if err := bucket.Acquire(ctx); err != nil {
+			return err
+		}
+		page, err := bucket.Reader().ListObjectsV2(ctx, input)
+		bucket.Release()
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			// Finish the current object, then pause until the next window
+			if err := waitForWindow(ctx, job, req.WindowStart, req.WindowEnd); err != nil {
+				return err
+			}
+
+			key := aws.ToString(obj.Key)
+			pathname := bucket.Config.pathnameOf(key)
+
+			// Directory markers are not objects of their own
+			if strings.HasPrefix(pathname, req.Prefix) && !strings.HasSuffix(pathname, "/") {
+				indexed, err := o.describeObject(ctx, bucket, pathname)
+				switch {
+				case err != nil:
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					// A failed HeadObject does not mean the object is gone, so its entry is kept
+					o.plugin.index.touch(bucket.Name, pathname)
+					loggerFor(ctx, o.log).Warn("failed to reindex object",
+						zap.String("job", job.ID),
+						zap.String("bucket", req.Bucket),
+						zap.String("pathname", pathname),
+						zap.Error(err),
+					)
+					o.plugin.metrics.RecordIndexUpdate(bucket.Name, "failed")
+					job.AddFailure(pathname, err)
+				case indexed != nil:
+					if err := o.plugin.index.put(bucket.Name, indexed); err != nil {
+						o.plugin.metrics.RecordIndexUpdate(bucket.Name, "rejected")
+						job.AddFailure(pathname, err)
+						break
+					}
+					o.plugin.metrics.RecordIndexUpdate(bucket.Name, "indexed")
+					job.AddProgress(indexed.Size)
+				}
+			}
+
+			checkpoint.LastKey = key
+			job.SetCheckpoint(key)
+			o.saveReindexCheckpoint(job, checkpoint)
+		}
+
+		if page.IsTruncated == nil || !*page.IsTruncated {
+			break
+		}
+		if bucket.Config.DirectoryBucket {
+			// Filtered pages of directory buckets may be empty before the listing ends
+			checkpoint.PageToken = aws.ToString(page.NextContinuationToken)
+			o.saveReindexCheckpoint(job, checkpoint)
+		} else if len(page.Contents) == 0 {
+			break
+		}
+	}
+
+	// Objects deleted behind the plugin's back were not seen by the walk
+	removed := o.plugin.index.prune(req.Bucket, req.Prefix, checkpoint.StartedAt)
+	loggerFor(ctx, o.log).Info("reindex pruned stale entries",
+		zap.String("job", job.ID),
+		zap.String("bucket", req.Bucket),
+		zap.Int("removed", removed),
+	)
+	return nil
+}
+
+// validateReindexRequest checks the bucket and schedule of a reindex request
+func (o *Operations) validateReindexRequest(req *ReindexRequest) *S3Error {
+	if o.plugin.index == nil {
+		return NewInvalidRequestError("the object index is not enabled, configure index")
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		return NewBucketNotFoundError(req.Bucket)
+	}
+	if !o.plugin.index.indexes(bucket) {
+		return NewNotSupportedError("reindex of a bucket not listed in index.buckets", req.Bucket)
+	}
+
+	// Checkpoints and index entries refer to the bucket, not to an alias of it
+	req.Bucket = bucket.Name
+
+	if strings.Contains(req.Prefix, "..") {
+		return NewInvalidRequestError("prefix cannot contain '..'")
+	}
+
+	if (req.WindowStart == "") != (req.WindowEnd == "") {
+		return NewInvalidRequestError("window_start and window_end must be set together")
+	}
+
+	if req.WindowStart != "" {
+		if _, err := parseClock(req.WindowStart); err != nil {
+			return NewInvalidRequestError("window_start: " + err.Error())
+		}
+		if _, err := parseClock(req.WindowEnd); err != nil {
+			return NewInvalidRequestError("window_end: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// scheduleReindex starts a full reindex of every indexed bucket each index.reindex_interval.
+// A bucket whose previous scheduled reindex is still running is skipped, and so are all of them
+// when another instance is the coordination leader.
+func (o *Operations) scheduleReindex(ctx context.Context, wg *sync.WaitGroup) {
+	cfg := o.plugin.config.Index
+	if cfg == nil || cfg.ReindexInterval <= 0 {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(cfg.ReindexInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if !o.plugin.leader.isLeader() {
+				continue
+			}
+
+			for _, name := range o.plugin.buckets.ListBuckets() {
+				bucket, err := o.plugin.buckets.GetBucket(name)
+				if err != nil || !o.plugin.index.indexes(bucket) {
+					continue
+				}
+
+				checkpoint := &reindexCheckpoint{
+					Request: ReindexRequest{
+						Bucket:      bucket.Name,
+						WindowStart: cfg.ReindexWindowStart,
+						WindowEnd:   cfg.ReindexWindowEnd,
+					},
+					StartedAt: time.Now().Unix(),
+				}
+
+				// A fixed ID makes the job manager refuse a second run while one is in progress
+				if _, err := o.startReindexJob(ctx, reindexJobType+"-"+bucket.Name, checkpoint); err != nil {
+					o.log.Debug("scheduled reindex skipped", zap.String("bucket", bucket.Name), zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// reindexStatePath returns the checkpoint directory, or empty when persistence is disabled
+func (o *Operations) reindexStatePath() string {
+	if o.plugin.stateDir == "" {
+		return ""
+	}
+	return filepath.Join(o.plugin.stateDir, reindexStateDir)
+}
+
+// saveReindexCheckpoint persists the checkpoint atomically (write + rename)
+func (o *Operations) saveReindexCheckpoint(job *Job, checkpoint *reindexCheckpoint) {
+	dir := o.reindexStatePath()
+	if dir == "" {
+		return
+	}
+
+	checkpoint.Objects, checkpoint.Bytes, checkpoint.Failed = job.Progress()
+	checkpoint.UpdatedAt = time.Now().Unix()
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		o.log.Warn("failed to encode reindex checkpoint", zap.String("id", job.ID), zap.Error(err))
+		return
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, job.ID+".json"), data); err != nil {
+		o.log.Warn("failed to save reindex checkpoint", zap.String("id", job.ID), zap.Error(err))
+	}
+}
+
+// removeReindexCheckpoint deletes the checkpoint of a finished reindex
+func (o *Operations) removeReindexCheckpoint(id string) {
+	dir := o.reindexStatePath()
+	if dir == "" {
+		return
+	}
+
+	if err := os.Remove(filepath.Join(dir, id+".json")); err != nil && !os.IsNotExist(err) {
+		o.log.Warn("failed to remove reindex checkpoint", zap.String("id", id), zap.Error(err))
+	}
+}
@@ -0,0 +1,232 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// reportJobType identifies bucket report jobs
+	reportJobType = "bucket_report"
+
+	// ReportSourceIndex marks reports computed from the object index
+	ReportSourceIndex = "index"
+
+	// ReportSourceListing marks reports computed by a listing job
+	ReportSourceListing = "listing"
+
+	// defaultReportTop is the number of largest objects reported without top
+	defaultReportTop = 10
+
+	// maxReportTop bounds top
+	maxReportTop = 1000
+)
+
+// reportBuilder accumulates a bucket report object by object
+type reportBuilder struct {
+	report *BucketReport
+	top    int
+}
+
+// newReportBuilder starts a report of the objects under prefix keeping the top largest
+func newReportBuilder(bucket, prefix, source string, top int, contentTypes bool) *reportBuilder {
+	report := &BucketReport{
+		Bucket:   bucket,
+		Prefix:   prefix,
+		Source:   source,
+		Largest:  make([]ReportObject, 0, top),
+		Prefixes: make(map[string]ReportUsage),
+	}
+	if contentTypes {
+		report.ContentTypes = make(map[string]ReportUsage)
+	}
+	return &reportBuilder{report: report, top: top}
+}
+
+// add counts an object; contentType is ignored unless the report collects content types
+func (rb *reportBuilder) add(pathname string, size int64, contentType string) {
+	r := rb.report
+	r.Objects++
+	r.Bytes += size
+
+	if r.ContentTypes != nil {
+		r.ContentTypes[contentType] = r.ContentTypes[contentType].add(size)
+	}
+
+	// Objects directly under the prefix are counted under ""
+	var top string
+	if i := strings.Index(strings.TrimPrefix(pathname, r.Prefix), "/"); i >= 0 {
+		top = pathname[:len(r.Prefix)+i+1]
+	}
+	r.Prefixes[top] = r.Prefixes[top].add(size)
+
+	// Largest is kept sorted by size, descending
+	if len(r.Largest) == rb.top && size <= r.Largest[len(r.Largest)-1].Size {
+		return
+	}
+	i := sort.Search(len(r.Largest), func(i int) bool { return r.Largest[i].Size < size })
+	if len(r.Largest) < rb.top {
+		r.Largest = append(r.Largest, ReportObject{})
+	}
+	copy(r.Largest[i+1:], r.Largest[i:])
+	r.Largest[i] = ReportObject{Pathname: pathname, Size: size, ContentType: contentType}
+}
+
+// finish stamps and returns the report
+func (rb *reportBuilder) finish() *BucketReport {
+	rb.report.GeneratedAt = time.Now().Unix()
+	return rb.report
+}
+
+// add returns the usage with one more object of size bytes
+func (u ReportUsage) add(size int64) ReportUsage {
+	return ReportUsage{Objects: u.Objects + 1, Bytes: u.Bytes + size}
+}
+
+// reportTop validates top and applies its default
+func reportTop(top int) (int, *S3Error) {
+	if top == 0 {
+		return defaultReportTop, nil
+	}
+	if top < 0 || top > maxReportTop {
+		return 0, NewInvalidRequestError(fmt.Sprintf("top must be between 1 and %d", maxReportTop))
+	}
+	return top, nil
+}
+
+// GetBucketReport reports the largest objects and the bytes per content type and top-level prefix
+// of a bucket from the object index
+func (o *Operations) GetBucketReport(ctx context.Context, req *BucketReportRequest, resp *BucketReport) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "bucket_report", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "GetBucketReport", "bucket_report", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	if !o.plugin.index.indexes(bucket) {
+		o.plugin.metrics.RecordOperation(req.Bucket, "bucket_report", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrNotSupported)
+		return NewNotSupportedError("bucket report of a bucket without object index, use StartBucketReport", req.Bucket)
+	}
+
+	top, serr := reportTop(req.Top)
+	if serr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "bucket_report", "error")
+		o.plugin.metrics.RecordError(req.Bucket, serr.Code)
+		return serr
+	}
+
+	rb := newReportBuilder(bucket.Name, req.Prefix, ReportSourceIndex, top, true)
+	o.plugin.index.each(bucket.Name, req.Prefix, func(obj *IndexedObject) {
+		rb.add(obj.Pathname, obj.Size, obj.ContentType)
+	})
+	*resp = *rb.finish()
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "bucket_report", "success")
+	return nil
+}
+
+// StartBucketReport launches a job computing a bucket report from a listing; GetJob returns the
+// report as its result once the job completed
+func (o *Operations) StartBucketReport(ctx context.Context, req *BucketReportRequest, resp *StartJobResponse) error {
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "bucket_report", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "StartBucketReport", "bucket_report", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	top, serr := reportTop(req.Top)
+	if serr != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "bucket_report", "error")
+		o.plugin.metrics.RecordError(req.Bucket, serr.Code)
+		return serr
+	}
+	prefix, contentTypes := req.Prefix, req.ContentTypes
+
+	job, err := o.plugin.jobs.Start(ctx, reportJobType, "", []string{bucket.Name}, req, func(ctx context.Context, job *Job) error {
+		report, err := o.runBucketReport(ctx, job, bucket, prefix, top, contentTypes)
+		if err != nil {
+			return err
+		}
+		job.SetResult(report)
+		return nil
+	})
+	if err != nil {
+		return asS3Error("start bucket report", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// runBucketReport walks the objects under prefix; content types need one HeadObject per object
+func (o *Operations) runBucketReport(ctx context.Context, job *Job, bucket *Bucket, prefix string, top int, contentTypes bool) (*BucketReport, error) {
+	rb := newReportBuilder(bucket.Name, prefix, ReportSourceListing, top, contentTypes)
+
+	err := o.walkObjects(ctx, bucket, bucket.listPrefix(prefix), func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		pathname := bucket.Config.pathnameOf(key)
+		if !strings.HasPrefix(pathname, prefix) || strings.HasSuffix(pathname, "/") {
+			return nil
+		}
+
+		size := aws.ToInt64(obj.Size)
+		var contentType string
+		if contentTypes {
+			described, err := o.describeObject(ctx, bucket, pathname)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				loggerFor(ctx, o.log).Warn("failed to read object for bucket report",
+					zap.String("job", job.ID),
+					zap.String("bucket", bucket.Name),
+					zap.String("pathname", pathname),
+					zap.Error(err),
+				)
+				job.AddFailure(pathname, err)
+				return nil
+			}
+			// Deleted since it was listed
+			if described == nil {
+				return nil
+			}
+			size, contentType = described.Size, described.ContentType
+		}
+
+		rb.add(pathname, size, contentType)
+		job.AddProgress(size)
+		job.SetCheckpoint(key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	o.plugin.metrics.RecordOperation(bucket.Name, "bucket_report", "success")
+	return rb.finish(), nil
+}
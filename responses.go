@@ -0,0 +1,38 @@
+package s3
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// defaultContentType is reported for objects stored or returned without a Content-Type
+const defaultContentType = "application/octet-stream"
+
+// S3-compatible servers omit optional response fields that AWS always sends: Content-Length on
+// chunked responses, Content-Type and Last-Modified. The helpers below map SDK response
+// fields to RPC response values with defaults instead of dereferencing them.
+
+// unixTime returns t as a Unix timestamp, or 0 when the server did not send it
+func unixTime(t *time.Time) int64 {
+	if t == nil || t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// contentTypeOf returns the content type, or application/octet-stream when the server did not send it
+func contentTypeOf(contentType *string) string {
+	if ct := aws.ToString(contentType); ct != "" {
+		return ct
+	}
+	return defaultContentType
+}
+
+// contentLengthOr returns the content length, or fallback when the server did not send it
+func contentLengthOr(contentLength *int64, fallback int64) int64 {
+	if contentLength == nil {
+		return fallback
+	}
+	return *contentLength
+}
@@ -0,0 +1,116 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestUnixTime(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		head *s3.HeadObjectOutput
+		want int64
+	}{
+		{"missing", &s3.HeadObjectOutput{}, 0},
+		{"zero", &s3.HeadObjectOutput{LastModified: &time.Time{}}, 0},
+		{"set", &s3.HeadObjectOutput{LastModified: &modified}, modified.Unix()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unixTime(tt.head.LastModified); got != tt.want {
+				t.Errorf("unixTime() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentTypeOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *s3.GetObjectOutput
+		want   string
+	}{
+		{"missing", &s3.GetObjectOutput{}, defaultContentType},
+		{"empty", &s3.GetObjectOutput{ContentType: aws.String("")}, defaultContentType},
+		{"set", &s3.GetObjectOutput{ContentType: aws.String("image/png")}, "image/png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentTypeOf(tt.result.ContentType); got != tt.want {
+				t.Errorf("contentTypeOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentLengthOr(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   *s3.GetObjectOutput
+		fallback int64
+		want     int64
+	}{
+		{"missing", &s3.GetObjectOutput{}, 42, 42},
+		{"zero", &s3.GetObjectOutput{ContentLength: aws.Int64(0)}, 42, 0},
+		{"set", &s3.GetObjectOutput{ContentLength: aws.Int64(1024)}, 42, 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentLengthOr(tt.result.ContentLength, tt.fallback); got != tt.want {
+				t.Errorf("contentLengthOr() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListPageEntriesMissingFields(t *testing.T) {
+	bucket := &Bucket{Config: &BucketConfig{Prefix: "app/"}}
+
+	// A page as sent by servers omitting KeyCount, IsTruncated, Size and LastModified
+	page := &s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: aws.String("app/docs/")},
+			{Key: aws.String("app/docs/a.txt")},
+			{Key: aws.String("app/docs/b.txt"), Size: aws.Int64(5)},
+		},
+		CommonPrefixes: []types.CommonPrefix{{Prefix: aws.String("app/docs/img/")}},
+	}
+
+	tests := []struct {
+		name     string
+		markers  string
+		keys     []string
+		prefixes int
+	}{
+		{"include", DirectoryMarkersInclude, []string{"docs/", "docs/a.txt", "docs/b.txt"}, 1},
+		{"skip", DirectoryMarkersSkip, []string{"docs/a.txt", "docs/b.txt"}, 1},
+		{"as prefix", DirectoryMarkersAsPrefix, []string{"docs/a.txt", "docs/b.txt"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, prefixes := listPageEntries(bucket, page, tt.markers, "", make(map[string]bool))
+			if len(objects) != len(tt.keys) || len(prefixes) != tt.prefixes {
+				t.Fatalf("listPageEntries() = %d objects, %d prefixes, want %d, %d", len(objects), len(prefixes), len(tt.keys), tt.prefixes)
+			}
+
+			for i, obj := range objects {
+				if obj.Key != tt.keys[i] {
+					t.Errorf("objects[%d].Key = %q, want %q", i, obj.Key, tt.keys[i])
+				}
+				if obj.LastModified != 0 {
+					t.Errorf("%s: LastModified = %d, want 0", obj.Key, obj.LastModified)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// resumableChunkAlignment is the size every chunk of a resumable upload except the last must be a multiple of
+	resumableChunkAlignment = 256 * 1024
+
+	// statusResumeIncomplete is the status GCS answers chunks of unfinished uploads with
+	statusResumeIncomplete = http.StatusPermanentRedirect
+
+	// statusClientClosed is the status GCS answers a cancelled upload session with
+	statusClientClosed = 499
+
+	// emptyPayloadHash is the SHA-256 of an empty body, signed for the session start request
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// errResumableSessionGone is returned when a resumable session expired or was cancelled
+var errResumableSessionGone = errors.New("resumable upload session expired or was cancelled")
+
+// resumable reports whether the upload is a resumable session rather than an S3 multipart upload
+func (u *multipartUpload) resumable() bool {
+	return u.SessionURL != ""
+}
+
+// reconcileLocked matches the parts with the bytes a resumable session holds: parts beyond it are
+// dropped or shortened, bytes without a part become one; caller must hold upload.mu
+func (u *multipartUpload) reconcileLocked(persisted int64) {
+	var offset int64
+	for i, part := range u.Parts {
+		if offset+part.Size > persisted {
+			u.Parts = u.Parts[:i]
+			if remaining := persisted - offset; remaining > 0 {
+				part.Size = remaining
+				u.Parts = append(u.Parts, part)
+			}
+			return
+		}
+		offset += part.Size
+	}
+
+	if offset < persisted {
+		u.setPartLocked(uploadedPart{Number: u.infoLocked().NextPartNumber, Size: persisted - offset})
+	}
+}
+
+// alignResumableChunk rounds a part size up to the chunk alignment of resumable uploads
+func alignResumableChunk(size int64) int64 {
+	return (size + resumableChunkAlignment - 1) / resumableChunkAlignment * resumableChunkAlignment
+}
+
+// startResumableUpload opens a GCS resumable upload session for the object described by input and
+// returns the session URL. Only the start request is signed: the session URL authorizes the chunks,
+// so it is kept out of responses and logs.
+func (o *Operations) startResumableUpload(ctx context.Context, bucket *Bucket, input *s3.CreateMultipartUploadInput) (string, error) {
+	opts := bucket.Client.Options()
+
+	endpoint, err := url.Parse(aws.ToString(opts.BaseEndpoint))
+	if err != nil || endpoint.Host == "" {
+		return "", errors.New("resumable uploads require a server endpoint")
+	}
+
+	// Path-style object URL, escaped as SigV4 signs it
+	base := strings.TrimSuffix(endpoint.Path, "/") + "/" + bucket.Config.Bucket + "/"
+	endpoint.Path = base + aws.ToString(input.Key)
+	endpoint.RawPath = base + escapeKeyPath(aws.ToString(input.Key))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("x-goog-resumable", "start")
+	req.Header.Set("Content-Type", aws.ToString(input.ContentType))
+	if input.ACL != "" {
+		req.Header.Set("x-amz-acl", string(input.ACL))
+	}
+	if input.StorageClass != "" {
+		req.Header.Set("x-amz-storage-class", string(input.StorageClass))
+	}
+	for k, v := range input.Metadata {
+		req.Header.Set("x-amz-meta-"+k, v)
+	}
+
+	if sc := bucket.ServerConfig.Signing; !sc.anonymous() && opts.Credentials != nil {
+		creds, err := opts.Credentials.Retrieve(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to retrieve credentials: %w", err)
+		}
+
+		service, region := "s3", opts.Region
+		if sc != nil && sc.Name != "" {
+			service = sc.Name
+		}
+		if sc != nil && sc.Region != "" {
+			region = sc.Region
+		}
+
+		req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+		signer := v4.NewSigner(func(so *v4.SignerOptions) {
+			so.DisableURIPathEscaping = true // The path is already escaped, as for S3
+		})
+		if err := signer.SignHTTP(ctx, creds, req, emptyPayloadHash, service, region, time.Now()); err != nil {
+			return "", fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", unexpectedResumableStatus(resp)
+	}
+
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return "", errors.New("server returned no resumable session URL")
+	}
+	return session, nil
+}
+
+// putResumable sends content for contentRange to a resumable session. It returns the bytes the
+// session holds, whether the object is complete, and its ETag once it is.
+func putResumable(ctx context.Context, bucket *Bucket, session string, content []byte, contentRange string) (int64, bool, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, session, bytes.NewReader(content))
+	if err != nil {
+		return 0, false, "", err
+	}
+	req.Header.Set("Content-Range", contentRange)
+
+	resp, err := bucket.Client.Options().HTTPClient.Do(req)
+	if err != nil {
+		return 0, false, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return 0, true, resp.Header.Get("ETag"), nil
+	case statusResumeIncomplete:
+		// "Range: bytes=0-<last>" lists the bytes held; no header means none
+		var persisted int64
+		if _, last, ok := strings.Cut(resp.Header.Get("Range"), "-"); ok {
+			if n, err := strconv.ParseInt(last, 10, 64); err == nil {
+				persisted = n + 1
+			}
+		}
+		return persisted, false, "", nil
+	case http.StatusNotFound, http.StatusGone:
+		return 0, false, "", errResumableSessionGone
+	}
+
+	return 0, false, "", unexpectedResumableStatus(resp)
+}
+
+// cancelResumable cancels a resumable session, discarding the bytes it holds
+func cancelResumable(ctx context.Context, bucket *Bucket, session string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, session, http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := bucket.Client.Options().HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == statusClientClosed, resp.StatusCode < http.StatusMultipleChoices:
+		return nil
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone:
+		return errResumableSessionGone
+	}
+	return unexpectedResumableStatus(resp)
+}
+
+// unexpectedResumableStatus describes an unexpected response of the resumable upload protocol
+func unexpectedResumableStatus(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// uploadResumablePart appends a part to a resumable session. Parts are appended in order; a part
+// whose size is not a multiple of 256KiB, or that reaches the expected size, finalizes the object.
+func (o *Operations) uploadResumablePart(ctx context.Context, bucket *Bucket, upload *multipartUpload, partNumber int32, content []byte, resp *UploadPartResponse) error {
+	upload.mu.Lock()
+	info := upload.infoLocked()
+	finalized := upload.Finalized
+	upload.mu.Unlock()
+
+	var serr *S3Error
+	switch {
+	case finalized:
+		serr = NewInvalidRequestError("upload already received its last part, complete it")
+	case partNumber != info.NextPartNumber:
+		serr = NewInvalidRequestError(fmt.Sprintf("resumable uploads take parts in order, next is %d", info.NextPartNumber))
+	case len(content) == 0:
+		serr = NewInvalidRequestError("part is empty")
+	}
+	if serr != nil {
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_part", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, serr.Code)
+		return serr
+	}
+
+	offset := info.UploadedBytes
+	end := offset + int64(len(content))
+	total := "*"
+	if len(content)%resumableChunkAlignment != 0 || (upload.Size > 0 && end >= upload.Size) {
+		total = strconv.FormatInt(end, 10)
+	}
+
+	persisted, complete, etag, err := putResumable(ctx, bucket, upload.SessionURL, content, fmt.Sprintf("bytes %d-%d/%s", offset, end-1, total))
+	if err != nil {
+		if errors.Is(err, errResumableSessionGone) {
+			o.plugin.uploads.remove(upload.ID)
+			return NewUploadNotFoundError(upload.ID)
+		}
+		loggerFor(ctx, o.log).Error("failed to upload resumable chunk",
+			zap.String("upload_id", upload.ID),
+			zap.String("bucket", upload.Bucket),
+			zap.String("pathname", upload.Pathname),
+			zap.Int32("part_number", partNumber),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_part", "error")
+		o.plugin.metrics.RecordError(upload.Bucket, ErrS3Operation)
+		return NewS3OperationError("upload part", err)
+	}
+	if complete {
+		persisted = end
+	}
+
+	// The session may keep fewer bytes than sent; clients resume from uploaded_bytes
+	upload.mu.Lock()
+	upload.reconcileLocked(persisted)
+	upload.Finalized = complete
+	upload.ETag = etag
+	o.plugin.uploads.persistLocked(upload)
+	info = upload.infoLocked()
+	upload.mu.Unlock()
+
+	resp.PartNumber = partNumber
+	resp.ETag = etag
+	resp.NextPartNumber = info.NextPartNumber
+	resp.UploadedBytes = info.UploadedBytes
+
+	o.plugin.metrics.RecordOperation(upload.Bucket, "multipart_part", "success")
+
+	return nil
+}
+
+// resumableParts returns the parts of a resumable upload reconciled with the bytes its session holds
+func (o *Operations) resumableParts(ctx context.Context, bucket *Bucket, upload *multipartUpload) ([]uploadedPart, error) {
+	persisted, complete, _, err := putResumable(ctx, bucket, upload.SessionURL, nil, "bytes */*")
+	if err != nil {
+		return nil, err
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	if complete {
+		upload.Finalized = true
+		return upload.Parts, nil
+	}
+
+	reconciled := &multipartUpload{Parts: append([]uploadedPart(nil), upload.Parts...)}
+	reconciled.reconcileLocked(persisted)
+	return reconciled.Parts, nil
+}
+
+// finalizeResumable completes a resumable upload holding size bytes, unless its last part did
+func (o *Operations) finalizeResumable(ctx context.Context, bucket *Bucket, upload *multipartUpload, size int64) error {
+	upload.mu.Lock()
+	finalized := upload.Finalized
+	upload.mu.Unlock()
+	if finalized {
+		return nil
+	}
+
+	persisted, complete, _, err := putResumable(ctx, bucket, upload.SessionURL, nil, fmt.Sprintf("bytes */%d", size))
+	if err != nil {
+		return err
+	}
+	if !complete {
+		return fmt.Errorf("session holds %d of %d bytes", persisted, size)
+	}
+	return nil
+}
+
+// abortResumableDetached cancels a resumable session with a fresh context, like abortUploadDetached
+func (o *Operations) abortResumableDetached(ctx context.Context, bucket *Bucket, upload *multipartUpload) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortUploadTimeout)
+	defer cancel()
+
+	err := cancelResumable(ctx, bucket, upload.SessionURL)
+	if errors.Is(err, errResumableSessionGone) {
+		err = nil
+	}
+	if err != nil {
+		o.log.Warn("failed to cancel resumable upload",
+			zap.String("bucket", bucket.Name),
+			zap.String("key", upload.Key),
+			zap.String("upload_id", upload.ID),
+			zap.Error(err),
+		)
+	}
+
+	if o.plugin.isShuttingDown() {
+		o.plugin.shutdown.record(bucket.Name, upload.Key, err)
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// RetentionConfig makes Delete and Move refuse objects that must be kept. The rules are checked by
+// the plugin before any delete is sent, so they also hold on servers without Object Lock.
+type RetentionConfig struct {
+	// Tags protects objects carrying any of these tags, as "key=value" or "key" for any value
+	// (e.g., ["retention=legal-hold", "litigation"])
+	Tags []string `mapstructure:"tags" json:"tags,omitempty"`
+
+	// ObjectLock protects objects under an S3 Object Lock retention date or legal hold (default: false)
+	ObjectLock bool `mapstructure:"object_lock" json:"object_lock"`
+
+	// tags is Tags by key; an empty value list matches every value
+	tags map[string][]string
+}
+
+// Validate validates the retention rules
+func (rc *RetentionConfig) Validate() error {
+	if len(rc.Tags) == 0 && !rc.ObjectLock {
+		return fmt.Errorf("retention requires tags or object_lock")
+	}
+
+	rc.tags = make(map[string][]string, len(rc.Tags))
+	for _, rule := range rc.Tags {
+		key, value, hasValue := strings.Cut(rule, "=")
+		if key == "" {
+			return fmt.Errorf("invalid retention tag '%s', expected 'key=value' or 'key'", rule)
+		}
+		if !hasValue {
+			// A key without value protects any value, even when other rules name values
+			rc.tags[key] = []string{}
+			continue
+		}
+		if values, ok := rc.tags[key]; !ok || len(values) > 0 {
+			rc.tags[key] = append(values, value)
+		}
+	}
+
+	return nil
+}
+
+// protects reports whether a tag matches one of the rules
+func (rc *RetentionConfig) protects(key, value string) bool {
+	values, ok := rc.tags[key]
+	return ok && (len(values) == 0 || slices.Contains(values, value))
+}
+
+// checkRetention returns an ErrRetentionActive error when the bucket's retention rules protect the
+// object at pathname. Objects that do not exist are not protected. Rules that cannot be evaluated
+// refuse the delete, so a failed lookup never removes a protected object.
+// The caller holds the bucket semaphore.
+func (o *Operations) checkRetention(ctx context.Context, bucket *Bucket, pathname string) *S3Error {
+	rc := bucket.Config.Retention
+	if rc == nil {
+		return nil
+	}
+	key := aws.String(bucket.ObjectKey(pathname))
+
+	if len(rc.tags) > 0 {
+		if !bucket.Capabilities.Tagging {
+			return NewNotSupportedError("retention tags", bucket.Name)
+		}
+		tagging, err := bucket.Client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    key,
+		})
+		if exists, err := headExists(bucket, err); err != nil {
+			return NewS3OperationError("get object tagging", err)
+		} else if !exists {
+			return nil
+		}
+		for _, tag := range tagging.TagSet {
+			if rc.protects(aws.ToString(tag.Key), aws.ToString(tag.Value)) {
+				return NewRetentionActiveError(pathname, fmt.Sprintf("tag %s=%s", aws.ToString(tag.Key), aws.ToString(tag.Value)))
+			}
+		}
+	}
+
+	if rc.ObjectLock {
+		hold, err := bucket.Client.GetObjectLegalHold(ctx, &s3.GetObjectLegalHoldInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    key,
+		})
+		switch {
+		case err == nil:
+			if hold.LegalHold != nil && hold.LegalHold.Status == types.ObjectLockLegalHoldStatusOn {
+				return NewRetentionActiveError(pathname, "legal hold")
+			}
+		case noObjectLock(err):
+		default:
+			if exists, err := headExists(bucket, err); err != nil {
+				return NewS3OperationError("get object legal hold", err)
+			} else if !exists {
+				return nil
+			}
+		}
+
+		retention, err := bucket.Client.GetObjectRetention(ctx, &s3.GetObjectRetentionInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    key,
+		})
+		switch {
+		case err == nil:
+			if retention.Retention != nil {
+				until := aws.ToTime(retention.Retention.RetainUntilDate)
+				if until.After(time.Now()) {
+					return NewRetentionActiveError(pathname, fmt.Sprintf("%s retention until %s",
+						strings.ToLower(string(retention.Retention.Mode)), until.UTC().Format(time.RFC3339)))
+				}
+			}
+		case noObjectLock(err):
+		default:
+			if _, err := headExists(bucket, err); err != nil {
+				return NewS3OperationError("get object retention", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// noObjectLock reports whether S3 answered that the object or its bucket has no Object Lock settings
+func noObjectLock(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "NoSuchObjectLockConfiguration", "ObjectLockConfigurationNotFoundError", "InvalidRequest":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+const (
+	// defaultRetryBudgetCapacity, defaultRetryCost and defaultRetrySuccessRefund match the SDK's
+	// per-client retry quota
+	defaultRetryBudgetCapacity = 500
+	defaultRetryCost           = 5
+	defaultRetrySuccessRefund  = 1
+
+	// defaultRetryMaxAttempts is the SDK default of attempts per request
+	defaultRetryMaxAttempts = 3
+
+	// maxRetryMaxAttempts keeps a single request from retrying for minutes
+	maxRetryMaxAttempts = 10
+)
+
+// RetryBudgetConfig sizes the token bucket shared by all SDK clients of a bucket. Each retry spends
+// retry_cost tokens (twice that after a timeout), each request succeeding at once returns
+// success_refund, and a successful retry returns its cost. While the budget is empty, failed requests
+// are returned without retrying, so a provider brownout is not amplified by retries.
+type RetryBudgetConfig struct {
+	// Capacity is the number of tokens in a full budget (default: 500)
+	Capacity uint `mapstructure:"capacity" json:"capacity"`
+
+	// RetryCost is the number of tokens a retry spends (default: 5)
+	RetryCost uint `mapstructure:"retry_cost" json:"retry_cost"`
+
+	// SuccessRefund is the number of tokens returned by a request succeeding on the first attempt (default: 1)
+	SuccessRefund uint `mapstructure:"success_refund" json:"success_refund"`
+
+	// MaxAttempts is the number of attempts per request, including the first (default: 3, max: 10)
+	MaxAttempts int `mapstructure:"max_attempts" json:"max_attempts"`
+}
+
+// Validate validates the retry budget configuration and applies defaults
+func (rc *RetryBudgetConfig) Validate() error {
+	if rc.Capacity == 0 {
+		rc.Capacity = defaultRetryBudgetCapacity
+	}
+	if rc.RetryCost == 0 {
+		rc.RetryCost = defaultRetryCost
+	}
+	if rc.SuccessRefund == 0 {
+		rc.SuccessRefund = defaultRetrySuccessRefund
+	}
+	if rc.RetryCost > rc.Capacity {
+		return fmt.Errorf("retry_budget.retry_cost must not exceed retry_budget.capacity")
+	}
+
+	if rc.MaxAttempts == 0 {
+		rc.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if rc.MaxAttempts < 1 || rc.MaxAttempts > maxRetryMaxAttempts {
+		return fmt.Errorf("retry_budget.max_attempts must be between 1 and %d", maxRetryMaxAttempts)
+	}
+
+	return nil
+}
+
+// retryBudget is the retry token bucket of a bucket, reporting when it suppresses a retry
+type retryBudget struct {
+	bucket    string
+	tokens    *ratelimit.TokenRateLimit
+	exhausted func(bucket string)
+}
+
+// newRetryBudget creates a full budget; exhausted may be nil
+func newRetryBudget(bucket string, rc *RetryBudgetConfig, exhausted func(bucket string)) *retryBudget {
+	return &retryBudget{
+		bucket:    bucket,
+		tokens:    ratelimit.NewTokenRateLimit(rc.Capacity),
+		exhausted: exhausted,
+	}
+}
+
+// GetToken spends cost tokens for a retry
+func (rb *retryBudget) GetToken(ctx context.Context, cost uint) (func() error, error) {
+	release, err := rb.tokens.GetToken(ctx, cost)
+	if isRetrySuppressed(err) && rb.exhausted != nil {
+		rb.exhausted(rb.bucket)
+	}
+	return release, err
+}
+
+// AddTokens returns tokens after a request succeeded
+func (rb *retryBudget) AddTokens(v uint) error {
+	return rb.tokens.AddTokens(v)
+}
+
+// Remaining returns the number of tokens left
+func (rb *retryBudget) Remaining() uint {
+	return rb.tokens.Remaining()
+}
+
+// retryer returns the retryer factory of an aws.Config drawing from the budget
+func (rb *retryBudget) retryer(rc *RetryBudgetConfig) func() aws.Retryer {
+	return func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			o.MaxAttempts = rc.MaxAttempts
+			o.RateLimiter = rb
+			o.RetryCost = rc.RetryCost
+			o.RetryTimeoutCost = 2 * rc.RetryCost
+			o.NoRetryIncrement = rc.SuccessRefund
+		})
+	}
+}
+
+// isRetrySuppressed reports whether a request failed without retrying because the budget was empty
+func isRetrySuppressed(err error) bool {
+	var quota ratelimit.QuotaExceededError
+	return errors.As(err, &quota)
+}
@@ -0,0 +1,166 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RouteConfig defines a virtual bucket that sends each pathname to a physical bucket by key prefix.
+// Pathnames are stored unchanged in the target bucket.
+type RouteConfig struct {
+	// Rules map key prefixes to buckets; the longest matching prefix wins
+	Rules []RouteRule `mapstructure:"rules" json:"rules"`
+
+	// Default is the bucket for pathnames no rule matches (optional; unmatched pathnames are rejected)
+	Default string `mapstructure:"default" json:"default,omitempty"`
+}
+
+// RouteRule sends pathnames under Prefix to Bucket
+type RouteRule struct {
+	// Prefix is the key prefix, e.g. "images/"
+	Prefix string `mapstructure:"prefix" json:"prefix"`
+
+	// Bucket is a configured bucket name or alias
+	Bucket string `mapstructure:"bucket" json:"bucket"`
+}
+
+// Validate checks that every rule targets a configured bucket and orders rules by precedence
+func (rc *RouteConfig) Validate(exists func(bucket string) bool) error {
+	if len(rc.Rules) == 0 {
+		return fmt.Errorf("at least one rule is required")
+	}
+
+	seen := make(map[string]bool, len(rc.Rules))
+	for i, rule := range rc.Rules {
+		if rule.Prefix == "" {
+			return fmt.Errorf("rules[%d].prefix is required, use default for other pathnames", i)
+		}
+		if seen[rule.Prefix] {
+			return fmt.Errorf("rules[%d] repeats prefix '%s'", i, rule.Prefix)
+		}
+		seen[rule.Prefix] = true
+
+		if !exists(rule.Bucket) {
+			return fmt.Errorf("rules[%d].bucket '%s' not found", i, rule.Bucket)
+		}
+	}
+
+	if rc.Default != "" && !exists(rc.Default) {
+		return fmt.Errorf("default bucket '%s' not found", rc.Default)
+	}
+
+	// Longest prefix first, so the first match is the most specific one
+	sort.SliceStable(rc.Rules, func(i, j int) bool {
+		return len(rc.Rules[i].Prefix) > len(rc.Rules[j].Prefix)
+	})
+
+	return nil
+}
+
+// target returns the bucket serving pathname, or empty when no rule matches and there is no default
+func (rc *RouteConfig) target(pathname string) string {
+	for _, rule := range rc.Rules {
+		if strings.HasPrefix(pathname, rule.Prefix) {
+			return rule.Bucket
+		}
+	}
+	return rc.Default
+}
+
+// listTargets returns the buckets that may hold objects under prefix
+func (rc *RouteConfig) listTargets(prefix string) []string {
+	for _, rule := range rc.Rules {
+		if strings.HasPrefix(prefix, rule.Prefix) {
+			return []string{rule.Bucket}
+		}
+	}
+
+	var targets []string
+	for _, rule := range rc.Rules {
+		if strings.HasPrefix(rule.Prefix, prefix) && !slices.Contains(targets, rule.Bucket) {
+			targets = append(targets, rule.Bucket)
+		}
+	}
+	if rc.Default != "" && !slices.Contains(targets, rc.Default) {
+		targets = append(targets, rc.Default)
+	}
+
+	return targets
+}
+
+// route replaces a virtual bucket name with the bucket serving pathname; other names are left as is.
+// Pathnames must be validated first so routing sees normalized keys.
+func (o *Operations) route(bucket *string, pathname string, metricOp string) error {
+	rc, virtual := o.plugin.config.Routes[*bucket]
+	if !virtual {
+		return nil
+	}
+
+	target := rc.target(pathname)
+	if target == "" {
+		o.plugin.metrics.RecordOperation(*bucket, metricOp, "error")
+		o.plugin.metrics.RecordError(*bucket, ErrInvalidPathname)
+		return NewInvalidPathnameError(pathname, fmt.Sprintf("no route of virtual bucket '%s' matches", *bucket))
+	}
+
+	*bucket = target
+	return nil
+}
+
+// routeListing replaces a virtual bucket name with the bucket holding all objects under prefix.
+// When the prefix spans routes to several buckets, the name is kept and all of them are returned.
+func (o *Operations) routeListing(bucket *string, prefix string, metricOp string) (*RouteConfig, []string, error) {
+	rc, virtual := o.plugin.config.Routes[*bucket]
+	if !virtual {
+		return nil, nil, nil
+	}
+
+	targets := rc.listTargets(prefix)
+	switch len(targets) {
+	case 0:
+		o.plugin.metrics.RecordOperation(*bucket, metricOp, "error")
+		o.plugin.metrics.RecordError(*bucket, ErrInvalidRequest)
+		return nil, nil, NewInvalidRequestError(fmt.Sprintf("no route of virtual bucket '%s' matches prefix '%s'", *bucket, prefix))
+	case 1:
+		*bucket = targets[0]
+	}
+
+	return rc, targets, nil
+}
+
+// listRouted lists a prefix of a virtual bucket from every bucket it routes to, merged by pathname.
+// Objects are only reported by the bucket their pathname routes to.
+func (o *Operations) listRouted(ctx context.Context, req *ListObjectsRequest, resp *ListObjectsResponse, rc *RouteConfig, targets []string, start time.Time) error {
+	resolved := make(map[string]*Bucket, len(targets))
+	var sources []listSource
+	for _, name := range targets {
+		bucket, err := o.plugin.buckets.GetBucket(name)
+		if err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "list", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+			return NewBucketNotFoundError(name)
+		}
+		resolved[name] = bucket
+
+		// An alias and its bucket are listed once
+		if slices.ContainsFunc(sources, func(s listSource) bool { return s.bucket == bucket }) {
+			continue
+		}
+
+		// Check access
+		if err := o.authorize(ctx, req.Caller, "ListObjects", "list", bucket.Name, req.Prefix); err != nil {
+			return err
+		}
+		sources = append(sources, listSources(bucket)...)
+	}
+
+	keep := func(bucket *Bucket, pathname string) bool {
+		return resolved[rc.target(pathname)] == bucket
+	}
+
+	return o.listMergedObjects(ctx, req, resp, sources, keep, start)
+}
@@ -1,7 +1,11 @@
 package s3
 
 import (
+	"context"
+	"time"
+
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // rpc implements the RPC interface exposed to PHP via goridge
@@ -10,8 +14,64 @@ type rpc struct {
 	log    *zap.Logger
 }
 
+// intercept runs an RPC operation through the interceptor chain
+func (r *rpc) intercept(operation string, req, resp any, fn func(ctx context.Context) error) error {
+	call := &Call{
+		Operation: operation,
+		Request:   req,
+		Response:  resp,
+	}
+
+	ctx, cancel, deadline, err := withRequestDeadline(r.plugin.opsCtx, req)
+	if err != nil {
+		return r.encodeError(err)
+	}
+	defer cancel()
+
+	if err := r.plugin.jobs.notifier.validate(req); err != nil {
+		return r.encodeError(err)
+	}
+
+	caller := requestCallerMetadata(req)
+	if caller != nil {
+		if err := caller.validate(); err != nil {
+			return r.encodeError(err)
+		}
+		ctx = withCallerMetadata(ctx, caller)
+	}
+
+	ctx, stats := withOperationStats(ctx)
+	start := time.Now()
+
+	err = r.plugin.interceptors.Run(ctx, call, func(ctx context.Context, _ *Call) error {
+		return fn(ctx)
+	})
+	if err != nil {
+		err = contextError(ctx, operation, deadline, time.Since(start), err)
+	}
+	if err == nil {
+		if cerr := compressResponse(req, resp, r.plugin.config.CompressionThreshold); cerr != nil {
+			err = NewS3OperationError("compress response", cerr)
+		}
+	}
+
+	r.plugin.reportSlowOperation(operation, caller, stats, time.Since(start), err)
+	return r.encodeError(err)
+}
+
+// encodeError converts an operation error to the configured error_format
+func (r *rpc) encodeError(err error) error {
+	if err == nil || r.plugin.config.ErrorFormat != ErrorFormatJSON {
+		return err
+	}
+	return &jsonError{envelope: newErrorEnvelope(err), cause: err}
+}
+
 // RegisterBucketRequest represents the request to register a new bucket dynamically
 type RegisterBucketRequest struct {
+	Caller
+	Deadline
+
 	Name       string `json:"name"`
 	Server     string `json:"server"`
 	Bucket     string `json:"bucket"`
@@ -25,22 +85,95 @@ type RegisterBucketResponse struct {
 	Message string `json:"message"`
 }
 
+// RegisterServerRequest represents the request to register a new server dynamically
+type RegisterServerRequest struct {
+	Caller
+	Deadline
+
+	Name               string `json:"name"`
+	Preset             string `json:"preset,omitempty"`
+	Driver             string `json:"driver,omitempty"` // Rejected, kept to report a clear error
+	Region             string `json:"region"`
+	Endpoint           string `json:"endpoint"`
+	Key                string `json:"key"`
+	Secret             string `json:"secret"`
+	SessionToken       string `json:"session_token,omitempty"`
+	CopySourceEncoding string `json:"copy_source_encoding,omitempty"`
+
+	// Signing overrides request signing (optional, see SigningConfig)
+	Signing *SigningConfig `json:"signing,omitempty"`
+
+	// Compatibility selects the S3 implementation: "aws" (default), "ceph", "storj" or "generic"
+	Compatibility string `json:"compatibility,omitempty"`
+}
+
+// MarshalLogObject redacts the credentials when the request is logged (e.g., by interceptors)
+func (r *RegisterServerRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", r.Name)
+	enc.AddString("region", r.Region)
+	enc.AddString("endpoint", r.Endpoint)
+	enc.AddString("key", redactKey(r.Key))
+	enc.AddString("secret", redactedValue)
+	return nil
+}
+
+// RegisterServerResponse represents the response from server registration
+type RegisterServerResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// PurgeRegistrationsRequest represents the request to remove persisted dynamic registrations
+type PurgeRegistrationsRequest struct {
+	Caller
+	Deadline
+
+	Servers []string `json:"servers,omitempty"`
+	Buckets []string `json:"buckets,omitempty"`
+	All     bool     `json:"all,omitempty"`
+}
+
+// PurgeRegistrationsResponse lists the persisted entries that were removed
+type PurgeRegistrationsResponse struct {
+	Servers []string `json:"servers"`
+	Buckets []string `json:"buckets"`
+}
+
 // ListBucketsRequest represents the request to list all buckets
-type ListBucketsRequest struct{}
+type ListBucketsRequest struct {
+	Caller
+	Deadline
+
+	IncludeAliases bool `json:"include_aliases,omitempty"`
+}
 
 // ListBucketsResponse represents the response with all bucket names
 type ListBucketsResponse struct {
-	Buckets []string `json:"buckets"`
-	Default string   `json:"default"`
+	Buckets []string          `json:"buckets"`
+	Default string            `json:"default"`
+	Aliases map[string]string `json:"aliases,omitempty"` // Alias to bucket name, with include_aliases
 }
 
 // WriteRequest represents a file write/upload request
 type WriteRequest struct {
+	Caller
+	Deadline
+
 	Bucket     string            `json:"bucket"`
 	Pathname   string            `json:"pathname"`
 	Content    []byte            `json:"content"`
 	Config     map[string]string `json:"config,omitempty"`
 	Visibility string            `json:"visibility,omitempty"`
+	Grants     *ObjectGrants     `json:"grants,omitempty"` // Explicit ACL grants; exclusive with Visibility
+	Transfer
+
+	// ExpiresIn deletes the object after a duration (e.g., "24h"); requires bucket expiration
+	ExpiresIn string `json:"expires_in,omitempty"`
+
+	// ExpiresAt deletes the object after a Unix timestamp; mutually exclusive with ExpiresIn
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+
+	VisibilityWait
 }
 
 // WriteResponse represents the response from a write operation
@@ -49,24 +182,166 @@ type WriteResponse struct {
 	Pathname     string `json:"pathname"`
 	Size         int64  `json:"size"`
 	LastModified int64  `json:"last_modified"`
+	ETag         string `json:"etag,omitempty"`
+
+	// ExpirationDays is the lifecycle period the object was tagged with, when it expires
+	ExpirationDays int32 `json:"expiration_days,omitempty"`
+
+	// VisibilityTimeout is set when wait_for_visibility expired before the object was visible
+	VisibilityTimeout bool `json:"visibility_timeout,omitempty"`
+}
+
+// WriteFromURLRequest represents a request to download a URL into a bucket (requires url_fetch)
+type WriteFromURLRequest struct {
+	Caller
+	Deadline
+
+	Bucket     string            `json:"bucket"`
+	Pathname   string            `json:"pathname"`
+	URL        string            `json:"url"` // http(s) URL on a host in url_fetch.allowed_hosts
+	Config     map[string]string `json:"config,omitempty"`
+	Visibility string            `json:"visibility,omitempty"`
+	Transfer
+
+	// ContentType overrides the Content-Type of the remote response
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// CopyExternalRequest represents a request to copy an object from an unregistered source into a
+// bucket (requires url_fetch)
+type CopyExternalRequest struct {
+	Caller
+	Deadline
+
+	Source     ExternalSource    `json:"source"`
+	Bucket     string            `json:"bucket"`
+	Pathname   string            `json:"pathname"`
+	Config     map[string]string `json:"config,omitempty"` // Replaces the metadata of S3 sources
+	Visibility string            `json:"visibility,omitempty"`
+	Transfer
+
+	// ContentType overrides the content type of the source
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// MarshalLogObject redacts the source credentials when the request is logged (e.g., by interceptors)
+func (r *CopyExternalRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("bucket", r.Bucket)
+	enc.AddString("pathname", r.Pathname)
+	return enc.AddObject("source", &r.Source)
+}
+
+// StageWriteRequest represents a request to stage a write until CommitWrite or AbortWrite
+type StageWriteRequest struct {
+	Caller
+	Deadline
+
+	Bucket     string            `json:"bucket"`
+	Pathname   string            `json:"pathname"` // Final pathname written on commit
+	Content    []byte            `json:"content"`
+	Config     map[string]string `json:"config,omitempty"`
+	Visibility string            `json:"visibility,omitempty"`
+}
+
+// StageWriteResponse identifies a staged write
+type StageWriteResponse struct {
+	Token     string `json:"token"`
+	Pathname  string `json:"pathname"`
+	Size      int64  `json:"size"`
+	Checksum  string `json:"checksum"`   // Hex sha256 of the content
+	ExpiresAt int64  `json:"expires_at"` // Unix time after which CommitWrite fails
+}
+
+// StagedWriteRequest references a staged write by its token
+type StagedWriteRequest struct {
+	Caller
+	Deadline
+
+	Bucket string `json:"bucket"`
+	Token  string `json:"token"`
+}
+
+// AbortWriteResponse represents the response from aborting a staged write
+type AbortWriteResponse struct {
+	Aborted  bool   `json:"aborted"` // False when the staged write no longer existed
+	Pathname string `json:"pathname,omitempty"`
+}
+
+// WriteTransactionRequest represents a request to write a set of objects all-or-nothing
+type WriteTransactionRequest struct {
+	Caller
+	Deadline
+
+	Bucket    string              `json:"bucket"`
+	Objects   []TransactionObject `json:"objects"`
+	Overwrite bool                `json:"overwrite,omitempty"` // Allow replacing existing objects (not restored on rollback)
+}
+
+// TransactionObject is a single object of a WriteTransaction
+type TransactionObject struct {
+	Pathname   string            `json:"pathname"`
+	Content    []byte            `json:"content"`
+	Config     map[string]string `json:"config,omitempty"`
+	Visibility string            `json:"visibility,omitempty"`
+}
+
+// WriteTransactionResponse reports the outcome of every object of a transaction
+type WriteTransactionResponse struct {
+	Committed bool                `json:"committed"`
+	Results   []TransactionResult `json:"results"`
+	Error     *S3Error            `json:"error,omitempty"` // Failure that triggered the rollback
+}
+
+// TransactionResult is the outcome of a single object of a transaction
+type TransactionResult struct {
+	Pathname string   `json:"pathname"`
+	Status   string   `json:"status"` // written, failed, rolled_back, rollback_failed, overwritten or skipped
+	Size     int64    `json:"size,omitempty"`
+	Error    *S3Error `json:"error,omitempty"`
+}
+
+// CanWriteRequest describes a prospective write to check without transferring content
+type CanWriteRequest struct {
+	Caller
+	Deadline
+
+	Bucket     string `json:"bucket"`
+	Pathname   string `json:"pathname"`
+	Size       int64  `json:"size"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// CanWriteResponse reports whether the write would be accepted and every reason it would not
+type CanWriteResponse struct {
+	Allowed bool       `json:"allowed"`
+	Exists  bool       `json:"exists"` // An object already exists at the pathname
+	Reasons []*S3Error `json:"reasons,omitempty"`
 }
 
 // ReadRequest represents a file read/download request
 type ReadRequest struct {
+	Caller
+	Deadline
+	Compression
+
 	Bucket   string `json:"bucket"`
 	Pathname string `json:"pathname"`
 }
 
 // ReadResponse represents the response from a read operation
 type ReadResponse struct {
-	Content      []byte `json:"content"`
-	Size         int64  `json:"size"`
-	MimeType     string `json:"mime_type"`
-	LastModified int64  `json:"last_modified"`
+	Content         []byte `json:"content"`
+	ContentEncoding string `json:"content_encoding,omitempty"` // "gzip" when Content is compressed
+	Size            int64  `json:"size"`
+	MimeType        string `json:"mime_type"`
+	LastModified    int64  `json:"last_modified"`
 }
 
 // ExistsRequest represents a file existence check request
 type ExistsRequest struct {
+	Caller
+	Deadline
+
 	Bucket   string `json:"bucket"`
 	Pathname string `json:"pathname"`
 }
@@ -76,25 +351,55 @@ type ExistsResponse struct {
 	Exists bool `json:"exists"`
 }
 
+// WaitRequest represents a request to block until an object exists or is gone
+type WaitRequest struct {
+	Caller
+	Deadline
+
+	Bucket   string `json:"bucket"`
+	Pathname string `json:"pathname"`
+	MaxWait  string `json:"max_wait,omitempty"` // Longest wait, e.g. "2m" (default: 30s, max: 15m)
+	Interval string `json:"interval,omitempty"` // Delay between checks, e.g. "5s" (default: 1s, min: 100ms)
+}
+
+// WaitResponse reports the outcome of a wait
+type WaitResponse struct {
+	// Satisfied is false when max_wait expired first
+	Satisfied bool  `json:"satisfied"`
+	Attempts  int   `json:"attempts"`
+	WaitedMS  int64 `json:"waited_ms"`
+}
+
 // DeleteRequest represents a file deletion request
 type DeleteRequest struct {
+	Caller
+	Deadline
+
 	Bucket   string `json:"bucket"`
 	Pathname string `json:"pathname"`
+	VisibilityWait
 }
 
 // DeleteResponse represents the response from a delete operation
 type DeleteResponse struct {
 	Success bool `json:"success"`
+
+	// VisibilityTimeout is set when wait_for_visibility expired before the object was gone
+	VisibilityTimeout bool `json:"visibility_timeout,omitempty"`
 }
 
 // CopyRequest represents a file copy request
 type CopyRequest struct {
+	Caller
+	Deadline
+
 	SourceBucket   string            `json:"source_bucket"`
 	SourcePathname string            `json:"source_pathname"`
 	DestBucket     string            `json:"dest_bucket"`
 	DestPathname   string            `json:"dest_pathname"`
 	Config         map[string]string `json:"config,omitempty"`
 	Visibility     string            `json:"visibility,omitempty"`
+	Grants         *ObjectGrants     `json:"grants,omitempty"` // Explicit ACL grants; exclusive with Visibility
 }
 
 // CopyResponse represents the response from a copy operation
@@ -107,6 +412,9 @@ type CopyResponse struct {
 
 // MoveRequest represents a file move request (copy + delete)
 type MoveRequest struct {
+	Caller
+	Deadline
+
 	SourceBucket   string            `json:"source_bucket"`
 	SourcePathname string            `json:"source_pathname"`
 	DestBucket     string            `json:"dest_bucket"`
@@ -125,24 +433,32 @@ type MoveResponse struct {
 
 // GetMetadataRequest represents a request to get file metadata
 type GetMetadataRequest struct {
+	Caller
+	Deadline
+
 	Bucket   string `json:"bucket"`
 	Pathname string `json:"pathname"`
 }
 
 // GetMetadataResponse represents file metadata
 type GetMetadataResponse struct {
-	Size         int64  `json:"size"`
-	MimeType     string `json:"mime_type"`
-	LastModified int64  `json:"last_modified"`
-	Visibility   string `json:"visibility"`
-	ETag         string `json:"etag,omitempty"`
+	Size         int64             `json:"size"`
+	MimeType     string            `json:"mime_type"`
+	LastModified int64             `json:"last_modified"`
+	Visibility   string            `json:"visibility"`
+	ETag         string            `json:"etag,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"` // User metadata, decoded per metadata_encoding
 }
 
 // SetVisibilityRequest represents a request to change file visibility
 type SetVisibilityRequest struct {
-	Bucket     string `json:"bucket"`
-	Pathname   string `json:"pathname"`
-	Visibility string `json:"visibility"`
+	Caller
+	Deadline
+
+	Bucket     string        `json:"bucket"`
+	Pathname   string        `json:"pathname"`
+	Visibility string        `json:"visibility,omitempty"`
+	Grants     *ObjectGrants `json:"grants,omitempty"` // Explicit ACL grants; exclusive with Visibility
 }
 
 // SetVisibilityResponse represents the response from visibility change
@@ -152,146 +468,1732 @@ type SetVisibilityResponse struct {
 
 // GetPublicURLRequest represents a request to generate a public URL
 type GetPublicURLRequest struct {
+	Caller
+	Deadline
+
 	Bucket    string `json:"bucket"`
 	Pathname  string `json:"pathname"`
 	ExpiresIn int64  `json:"expires_in,omitempty"` // Seconds, 0 for permanent
+	// SourceIP limits the URL to clients from this IP or CIDR; needs a cdn with key_pair_id
+	SourceIP string `json:"source_ip,omitempty"`
+	// SignedHeaders are signed into the presigned URL and must be sent by the client
+	SignedHeaders map[string]string `json:"signed_headers,omitempty"`
 }
 
 // GetPublicURLResponse represents the response with a public URL
 type GetPublicURLResponse struct {
-	URL       string `json:"url"`
-	ExpiresAt int64  `json:"expires_at,omitempty"` // Unix timestamp
+	URL           string            `json:"url"`
+	ExpiresAt     int64             `json:"expires_at,omitempty"`     // Unix timestamp
+	SignedHeaders map[string]string `json:"signed_headers,omitempty"` // Headers to send with the URL
 }
 
-// ListObjectsRequest represents a request to list objects in a bucket
-type ListObjectsRequest struct {
-	Bucket            string `json:"bucket"`
-	Prefix            string `json:"prefix,omitempty"`             // Filter by prefix
-	Delimiter         string `json:"delimiter,omitempty"`          // Delimiter for grouping (e.g., "/")
-	MaxKeys           int32  `json:"max_keys,omitempty"`           // Maximum number of keys to return (default: 1000)
-	ContinuationToken string `json:"continuation_token,omitempty"` // Token for pagination
+// GetPresignedPostRequest represents a request to generate a browser POST upload policy
+type GetPresignedPostRequest struct {
+	Caller
+	Deadline
+
+	Bucket string `json:"bucket"`
+	// Pathname is the exact key the form may upload to (mutually exclusive with KeyPrefix)
+	Pathname string `json:"pathname,omitempty"`
+	// KeyPrefix allows any key starting with this prefix; the form key defaults to "<prefix>${filename}"
+	KeyPrefix             string `json:"key_prefix,omitempty"`
+	ExpiresIn             int64  `json:"expires_in,omitempty"`               // Seconds (default: 900)
+	ContentLengthMin      int64  `json:"content_length_min,omitempty"`       // Minimum upload size in bytes
+	ContentLengthMax      int64  `json:"content_length_max,omitempty"`       // Maximum upload size in bytes
+	ContentType           string `json:"content_type,omitempty"`             // Exact Content-Type required
+	ContentTypeStartsWith string `json:"content_type_starts_with,omitempty"` // Content-Type prefix, e.g. "image/"
+	SuccessActionRedirect string `json:"success_action_redirect,omitempty"`  // Redirect URL after successful upload
+	SuccessActionStatus   string `json:"success_action_status,omitempty"`    // "200", "201" or "204"
+	Visibility            string `json:"visibility,omitempty"`
 }
 
-// ObjectInfo represents information about a single S3 object
-type ObjectInfo struct {
-	Key          string `json:"key"`
-	Size         int64  `json:"size"`
-	LastModified int64  `json:"last_modified"` // Unix timestamp
-	ETag         string `json:"etag"`
-	StorageClass string `json:"storage_class,omitempty"`
+// GetPresignedPostResponse represents the form action URL and fields for a browser POST upload
+type GetPresignedPostResponse struct {
+	URL       string            `json:"url"`
+	Fields    map[string]string `json:"fields"`
+	ExpiresAt int64             `json:"expires_at"` // Unix timestamp
 }
 
-// CommonPrefix represents a common prefix (directory-like structure)
-type CommonPrefix struct {
-	Prefix string `json:"prefix"`
+// CreateShortLinkRequest represents a request to create a short download link to an object
+type CreateShortLinkRequest struct {
+	Caller
+	Deadline
+
+	Bucket       string `json:"bucket"`
+	Pathname     string `json:"pathname"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`    // Seconds (default: short_links.default_ttl)
+	MaxDownloads int64  `json:"max_downloads,omitempty"` // 0 for unlimited
+	Filename     string `json:"filename,omitempty"`      // Download name (default: base name of pathname)
+	SourceIP     string `json:"source_ip,omitempty"`     // IP or CIDR clients must come from
 }
 
-// ListObjectsResponse represents the response from list objects operation
-type ListObjectsResponse struct {
-	Objects               []ObjectInfo   `json:"objects"`
-	CommonPrefixes        []CommonPrefix `json:"common_prefixes,omitempty"`
-	IsTruncated           bool           `json:"is_truncated"`
-	NextContinuationToken string         `json:"next_continuation_token,omitempty"`
-	KeyCount              int32          `json:"key_count"`
+// CreateShortLinkResponse represents a created short link
+type CreateShortLinkResponse struct {
+	Token     string `json:"token"`
+	URL       string `json:"url"`        // <base_url><path><token>
+	ExpiresAt int64  `json:"expires_at"` // Unix timestamp
 }
 
-// RegisterBucket registers a new bucket dynamically via RPC
-// Note: The bucket must reference an existing server from configuration
-func (r *rpc) RegisterBucket(req *RegisterBucketRequest, resp *RegisterBucketResponse) error {
-	r.log.Debug("registering bucket via RPC",
-		zap.String("name", req.Name),
-		zap.String("server", req.Server),
-		zap.String("bucket", req.Bucket),
-	)
+// DeleteShortLinkRequest represents a request to revoke a short link
+type DeleteShortLinkRequest struct {
+	Caller
+	Deadline
 
-	// Create bucket configuration from request
-	cfg := &BucketConfig{
-		Server:     req.Server,
-		Bucket:     req.Bucket,
-		Prefix:     req.Prefix,
-		Visibility: req.Visibility,
-	}
+	Token string `json:"token"`
+}
 
-	// Get bucket manager to access server configs
-	bucketManager := r.plugin.GetBucketManager()
+// DeleteShortLinkResponse represents the result of revoking a short link
+type DeleteShortLinkResponse struct {
+	Success bool `json:"success"` // False when the link did not exist or had expired
+}
 
-	// Lock for reading servers map
-	bucketManager.mu.RLock()
-	servers := bucketManager.servers
-	bucketManager.mu.RUnlock()
+// StartDownloadSessionRequest represents a request to open a resumable download session
+type StartDownloadSessionRequest struct {
+	Caller
+	Deadline
 
-	// Validate configuration (this will check if server exists)
-	if err := cfg.Validate(servers); err != nil {
-		resp.Success = false
-		resp.Message = "Invalid configuration: " + err.Error()
-		return NewInvalidConfigError(err.Error())
-	}
+	Bucket    string `json:"bucket"`
+	Pathname  string `json:"pathname"`
+	ChunkSize int64  `json:"chunk_size,omitempty"` // Bytes per chunk (default: 1MB)
+	Offset    int64  `json:"offset,omitempty"`     // Recorded offset to resume from
+	ETag      string `json:"etag,omitempty"`       // Recorded ETag; fails with OBJECT_CHANGED if the object differs
+}
 
-	// Register bucket
-	if err := bucketManager.RegisterBucket(r.plugin.ctx, req.Name, cfg); err != nil {
-		resp.Success = false
-		resp.Message = "Failed to register bucket: " + err.Error()
-		return err
-	}
+// DownloadSessionState represents the current state of a download session
+type DownloadSessionState struct {
+	SessionID string `json:"session_id"`
+	Bucket    string `json:"bucket"`
+	Pathname  string `json:"pathname"`
+	ETag      string `json:"etag"`
+	Size      int64  `json:"size"`
+	Offset    int64  `json:"offset"`
+	ChunkSize int64  `json:"chunk_size"`
+	EOF       bool   `json:"eof"`
+}
 
-	resp.Success = true
-	resp.Message = "Bucket registered successfully"
-	return nil
+// FetchDownloadChunkRequest represents a request to read the next chunk of a session
+type FetchDownloadChunkRequest struct {
+	Caller
+	Deadline
+	Compression
+
+	SessionID string `json:"session_id"`
+	ChunkSize int64  `json:"chunk_size,omitempty"` // Overrides the session chunk size for this fetch
 }
 
-// ListBuckets lists all registered buckets
-func (r *rpc) ListBuckets(req *ListBucketsRequest, resp *ListBucketsResponse) error {
-	resp.Buckets = r.plugin.buckets.ListBuckets()
-	resp.Default = r.plugin.buckets.GetDefaultBucketName()
-	return nil
+// FetchDownloadChunkResponse represents a single chunk of a download session
+type FetchDownloadChunkResponse struct {
+	Content         []byte `json:"content"`
+	ContentEncoding string `json:"content_encoding,omitempty"` // "gzip" when Content is compressed
+	Offset          int64  `json:"offset"`                     // Offset of the first byte in Content
+	NextOffset      int64  `json:"next_offset"`                // Offset to record for resuming
+	EOF             bool   `json:"eof"`
 }
 
-// Write uploads a file to S3
-func (r *rpc) Write(req *WriteRequest, resp *WriteResponse) error {
-	return r.plugin.operations.Write(r.plugin.ctx, req, resp)
+// DownloadSessionRequest identifies an existing download session
+type DownloadSessionRequest struct {
+	Caller
+	Deadline
+
+	SessionID string `json:"session_id"`
 }
 
-// Read downloads a file from S3
-func (r *rpc) Read(req *ReadRequest, resp *ReadResponse) error {
-	return r.plugin.operations.Read(r.plugin.ctx, req, resp)
+// CloseDownloadSessionResponse represents the response from closing a download session
+type CloseDownloadSessionResponse struct {
+	Success bool `json:"success"`
 }
 
-// Exists checks if a file exists in S3
-func (r *rpc) Exists(req *ExistsRequest, resp *ExistsResponse) error {
-	return r.plugin.operations.Exists(r.plugin.ctx, req, resp)
+// CompareObjectsRequest represents a request to compare two objects
+type CompareObjectsRequest struct {
+	Caller
+	Deadline
+
+	SourceBucket   string `json:"source_bucket"`
+	SourcePathname string `json:"source_pathname"`
+	DestBucket     string `json:"dest_bucket"`
+	DestPathname   string `json:"dest_pathname"`
+	SampleCount    int    `json:"sample_count,omitempty"` // Number of byte ranges to compare (0 disables sampling)
+	SampleSize     int64  `json:"sample_size,omitempty"`  // Bytes per sampled range (default: 64KB)
 }
 
-// Delete deletes a file from S3
-func (r *rpc) Delete(req *DeleteRequest, resp *DeleteResponse) error {
-	return r.plugin.operations.Delete(r.plugin.ctx, req, resp)
+// CompareObjectsResponse represents the result of an object comparison
+type CompareObjectsResponse struct {
+	Identical         bool     `json:"identical"`
+	SizeMatch         bool     `json:"size_match"`
+	ETagMatch         bool     `json:"etag_match"`
+	ChecksumCompared  bool     `json:"checksum_compared"`
+	ChecksumMatch     bool     `json:"checksum_match"`
+	ChecksumAlgorithm string   `json:"checksum_algorithm,omitempty"`
+	SamplesCompared   int      `json:"samples_compared"`
+	SamplesMatch      bool     `json:"samples_match"`
+	SourceSize        int64    `json:"source_size"`
+	DestSize          int64    `json:"dest_size"`
+	SourceETag        string   `json:"source_etag"`
+	DestETag          string   `json:"dest_etag"`
+	Differences       []string `json:"differences,omitempty"`
+
+	// Inconclusive is set when multipart ETags differ and neither checksums nor the whole content
+	// could be compared; identical is false then
+	Inconclusive bool `json:"inconclusive"`
 }
 
-// Copy copies a file within or between buckets
-func (r *rpc) Copy(req *CopyRequest, resp *CopyResponse) error {
-	return r.plugin.operations.Copy(r.plugin.ctx, req, resp)
+// MigrationRequest represents a request to migrate a prefix between buckets as an async job
+type MigrationRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	SourceBucket   string `json:"source_bucket"`
+	SourcePrefix   string `json:"source_prefix"`
+	DestBucket     string `json:"dest_bucket"`
+	DestPrefix     string `json:"dest_prefix"`
+	BandwidthLimit int64  `json:"bandwidth_limit,omitempty"` // Bytes per second, 0 for unlimited
+	WindowStart    string `json:"window_start,omitempty"`    // Local time "HH:MM" when transfers may start
+	WindowEnd      string `json:"window_end,omitempty"`      // Local time "HH:MM" when transfers pause
+	DeleteSource   bool   `json:"delete_source,omitempty"`   // Move instead of copy
 }
 
-// Move moves a file within or between buckets
-func (r *rpc) Move(req *MoveRequest, resp *MoveResponse) error {
-	return r.plugin.operations.Move(r.plugin.ctx, req, resp)
+// ReindexRequest represents a request to rebuild the object index of a bucket from a listing
+type ReindexRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket      string `json:"bucket"`
+	Prefix      string `json:"prefix,omitempty"`       // Reindex only pathnames under this prefix
+	WindowStart string `json:"window_start,omitempty"` // Local time "HH:MM" when indexing may start
+	WindowEnd   string `json:"window_end,omitempty"`   // Local time "HH:MM" when indexing pauses
 }
 
-// GetMetadata retrieves file metadata
-func (r *rpc) GetMetadata(req *GetMetadataRequest, resp *GetMetadataResponse) error {
-	return r.plugin.operations.GetMetadata(r.plugin.ctx, req, resp)
+// VerifyChainRequest represents a request to verify the hash chain of an audit bucket
+type VerifyChainRequest struct {
+	Caller
+	Deadline
+
+	Bucket string `json:"bucket"`
 }
 
-// SetVisibility changes file visibility (ACL)
-func (r *rpc) SetVisibility(req *SetVisibilityRequest, resp *SetVisibilityResponse) error {
-	return r.plugin.operations.SetVisibility(r.plugin.ctx, req, resp)
+// VerifyChainResponse reports the integrity of an audit chain
+type VerifyChainResponse struct {
+	Valid        bool           `json:"valid"`
+	Objects      int64          `json:"objects"`       // Chained objects read
+	HeadSequence int64          `json:"head_sequence"` // Sequence of the last object according to the head
+	HeadHash     string         `json:"head_hash,omitempty"`
+	Problems     []ChainProblem `json:"problems,omitempty"` // At most 1000, ordered by detection
+	ProblemCount int            `json:"problem_count"`
 }
 
-// GetPublicURL generates a public or presigned URL for a file
-func (r *rpc) GetPublicURL(req *GetPublicURLRequest, resp *GetPublicURLResponse) error {
-	return r.plugin.operations.GetPublicURL(r.plugin.ctx, req, resp)
+// ChainProblem describes a break of an audit chain
+type ChainProblem struct {
+	Sequence int64  `json:"sequence,omitempty"`
+	Pathname string `json:"pathname,omitempty"`
+	Reason   string `json:"reason"`
 }
 
-// ListObjects lists objects in a bucket with optional filtering
-func (r *rpc) ListObjects(req *ListObjectsRequest, resp *ListObjectsResponse) error {
-	return r.plugin.operations.ListObjects(r.plugin.ctx, req, resp)
+// BucketReportRequest represents a request for the storage report of a bucket or prefix
+type BucketReportRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket       string `json:"bucket"`
+	Prefix       string `json:"prefix,omitempty"`
+	Top          int    `json:"top,omitempty"`           // Number of largest objects (default: 10, max: 1000)
+	ContentTypes bool   `json:"content_types,omitempty"` // StartBucketReport only: HeadObject every object for its content type
+}
+
+// BucketReport summarizes the storage used by a bucket or prefix
+type BucketReport struct {
+	Bucket       string                 `json:"bucket"`
+	Prefix       string                 `json:"prefix,omitempty"`
+	Source       string                 `json:"source"` // "index" or "listing"
+	Objects      int64                  `json:"objects"`
+	Bytes        int64                  `json:"bytes"`
+	Largest      []ReportObject         `json:"largest"`                 // Largest objects first
+	ContentTypes map[string]ReportUsage `json:"content_types,omitempty"` // Omitted by listings without content_types
+	Prefixes     map[string]ReportUsage `json:"prefixes"`                // By first path segment below prefix, "" for objects directly under it
+	GeneratedAt  int64                  `json:"generated_at"`            // Unix timestamp
+}
+
+// ReportObject is an object listed in a bucket report
+type ReportObject struct {
+	Pathname    string `json:"pathname"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// ReportUsage counts the objects and bytes of a group in a bucket report
+type ReportUsage struct {
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// DuplicateScanRequest represents a request to find objects with identical content under a prefix
+type DuplicateScanRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix,omitempty"`
+	Compare   string `json:"compare,omitempty"`    // "etag" (default) or "checksum" (reads objects sharing a size)
+	Action    string `json:"action,omitempty"`     // "report" (default), "delete" or "dedup"
+	MinSize   int64  `json:"min_size,omitempty"`   // Ignore smaller objects
+	MaxGroups int    `json:"max_groups,omitempty"` // Groups returned in the report (default: 1000)
+}
+
+// DuplicateReport is the result of a duplicate scan job
+type DuplicateReport struct {
+	Bucket           string           `json:"bucket"`
+	Prefix           string           `json:"prefix,omitempty"`
+	Compare          string           `json:"compare"`
+	Action           string           `json:"action"`
+	Groups           []DuplicateGroup `json:"groups"` // Largest savings first
+	GroupCount       int              `json:"group_count"`
+	Truncated        bool             `json:"truncated"` // More than max_groups groups were found
+	DuplicateObjects int64            `json:"duplicate_objects"`
+	PotentialSavings int64            `json:"potential_savings"`  // Bytes stored more than once
+	Deleted          int64            `json:"deleted,omitempty"`  // Duplicates deleted by action "delete"
+	Replaced         int64            `json:"replaced,omitempty"` // Objects replaced with pointers by action "dedup"
+}
+
+// DuplicateGroup lists objects with identical content
+type DuplicateGroup struct {
+	Checksum  string   `json:"checksum"` // ETag or hex sha256, per compare
+	Size      int64    `json:"size"`
+	Pathnames []string `json:"pathnames"` // Sorted; action "delete" keeps the first
+	Savings   int64    `json:"savings"`
+}
+
+// VerifyRequest represents a request to verify the integrity of the objects under a prefix
+type VerifyRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix,omitempty"`
+	Mode   string `json:"mode,omitempty"` // "metadata" (default) or "content" (downloads every object)
+}
+
+// VerifyReport is the result of a verification job
+type VerifyReport struct {
+	Bucket         string          `json:"bucket"`
+	Prefix         string          `json:"prefix,omitempty"`
+	Mode           string          `json:"mode"`
+	Objects        int64           `json:"objects"`
+	Bytes          int64           `json:"bytes"`      // Content read in mode "content"
+	Verified       int64           `json:"verified"`   // Objects matching a recorded value
+	Unverified     int64           `json:"unverified"` // Objects without a recorded value to compare with
+	Corrupted      []VerifyProblem `json:"corrupted"`
+	Missing        []VerifyProblem `json:"missing"`
+	CorruptedCount int64           `json:"corrupted_count"`
+	MissingCount   int64           `json:"missing_count"`
+	Truncated      bool            `json:"truncated"` // More than 1000 problems were found
+}
+
+// VerifyProblem is a corrupted or missing object found by a verification job
+type VerifyProblem struct {
+	Pathname string `json:"pathname"`
+	Reason   string `json:"reason"`
+}
+
+// ExportRequest represents a request to bundle objects with a signed manifest, by pathnames or by prefix and suffix
+type ExportRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket     string   `json:"bucket"`
+	Pathnames  []string `json:"pathnames,omitempty"`   // Exported objects; cannot be combined with prefix or suffix
+	Prefix     string   `json:"prefix,omitempty"`      // Export every object under prefix
+	Suffix     string   `json:"suffix,omitempty"`      // Only export objects ending with suffix (e.g., ".pdf")
+	DestBucket string   `json:"dest_bucket,omitempty"` // Default: bucket
+	DestPrefix string   `json:"dest_prefix"`           // Receives manifest.json, manifest.json.sig and the objects; must end with '/'
+	Format     string   `json:"format,omitempty"`      // "objects" (default, copies under <dest_prefix>objects/) or "zip"
+	Reference  string   `json:"reference,omitempty"`   // Case or request reference recorded in the manifest
+}
+
+// ExportResult is the result of an export job
+// InventoryRequest represents a request to list a prefix in the S3 Inventory report layout
+type InventoryRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket     string   `json:"bucket"`
+	Prefix     string   `json:"prefix,omitempty"`      // List only objects under prefix
+	DestBucket string   `json:"dest_bucket,omitempty"` // Default: bucket
+	DestPrefix string   `json:"dest_prefix"`           // Receives <source bucket>/<id>/...; must end with '/'
+	ID         string   `json:"id,omitempty"`          // Inventory configuration ID in the layout (default: "rr-inventory")
+	Format     string   `json:"format,omitempty"`      // "csv" (default); ORC and Parquet are not supported
+	Fields     []string `json:"fields,omitempty"`      // Optional fields (default: Size, LastModifiedDate, ETag, StorageClass)
+}
+
+// InventoryResult is the outcome of an inventory job, returned by GetJob once it completed
+type InventoryResult struct {
+	Manifest string   `json:"manifest"` // Pathname of manifest.json in the destination bucket
+	Checksum string   `json:"checksum"` // Pathname of manifest.checksum, the hex MD5 of the manifest
+	Files    []string `json:"files"`    // Pathnames of the gzipped CSV data files
+	Objects  int64    `json:"objects"`
+	Bytes    int64    `json:"bytes"`
+}
+
+type ExportResult struct {
+	Manifest  string   `json:"manifest"`          // Pathname of manifest.json in the destination bucket
+	Signature string   `json:"signature"`         // Pathname of the hex HMAC-SHA256 of the manifest
+	Archive   string   `json:"archive,omitempty"` // Pathname of the zip for format "zip"
+	Objects   int      `json:"objects"`
+	Missing   []string `json:"missing,omitempty"` // Requested pathnames that did not exist
+}
+
+// DedupGCRequest represents a request to garbage-collect unreferenced blobs of a dedup bucket
+type DedupGCRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket      string `json:"bucket"`
+	GracePeriod string `json:"grace_period,omitempty"` // Keep blobs newer than this, e.g. "1h" (default: 1h)
+}
+
+// PublishDirectoryRequest represents a request to publish a local directory tree to a prefix
+type PublishDirectoryRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket        string `json:"bucket"`
+	Prefix        string `json:"prefix"`                   // Target prefix, e.g. "site/"
+	SourceDir     string `json:"source_dir"`               // Local directory inside a configured publish root
+	DeleteRemoved bool   `json:"delete_removed,omitempty"` // Delete objects under the prefix with no local file
+}
+
+// ExtractArchiveRequest represents a request to extract a zip/tar archive into objects under a prefix
+type ExtractArchiveRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket      string `json:"bucket,omitempty"`      // Bucket of the archive object
+	Pathname    string `json:"pathname,omitempty"`    // Archive object; exclusive with local_path
+	LocalPath   string `json:"local_path,omitempty"`  // Local archive inside a configured archive root
+	Format      string `json:"format,omitempty"`      // "zip", "tar" or "tar.gz" (default: detected from the name)
+	DestBucket  string `json:"dest_bucket"`           // Bucket receiving the entries
+	DestPrefix  string `json:"dest_prefix"`           // Prefix prepended to entry paths
+	Concurrency int    `json:"concurrency,omitempty"` // Parallel zip entry uploads (default: archives.concurrency)
+}
+
+// CreateArchiveRequest represents a request to pack all objects under a prefix into an archive
+type CreateArchiveRequest struct {
+	Caller
+	Deadline
+	Completion
+
+	Bucket       string `json:"bucket"`                  // Bucket of the objects to pack
+	Prefix       string `json:"prefix"`                  // Prefix of the objects; entry names are relative to it
+	Format       string `json:"format,omitempty"`        // "zip" or "tar.gz" (default: detected from the destination name)
+	DestBucket   string `json:"dest_bucket,omitempty"`   // Bucket receiving the archive (default: bucket)
+	DestPathname string `json:"dest_pathname,omitempty"` // Archive object; exclusive with local_path
+	LocalPath    string `json:"local_path,omitempty"`    // Local file inside a configured archive root
+}
+
+// StartJobResponse represents the response from starting an async job
+type StartJobResponse struct {
+	JobID string `json:"job_id"`
+}
+
+// JobRequest identifies an async job
+type JobRequest struct {
+	Caller
+	Deadline
+
+	JobID string `json:"job_id"`
+}
+
+// JobInfo represents the state and progress of an async job
+type JobInfo struct {
+	ID               string   `json:"id"`
+	Type             string   `json:"type"`
+	Status           string   `json:"status"`
+	Message          string   `json:"message,omitempty"`
+	Checkpoint       string   `json:"checkpoint,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	ObjectsProcessed int64    `json:"objects_processed"`
+	BytesProcessed   int64    `json:"bytes_processed"`
+	ObjectsFailed    int64    `json:"objects_failed"`
+	Failures         []string `json:"failures,omitempty"`
+	Result           any      `json:"result,omitempty"` // Outcome of jobs producing one, e.g. a *BucketReport
+	CreatedAt        int64    `json:"created_at"`
+	FinishedAt       int64    `json:"finished_at,omitempty"`
+}
+
+// ListJobsRequest represents the request to list async jobs
+type ListJobsRequest struct {
+	Caller
+	Deadline
+}
+
+// ListJobsResponse represents all known async jobs
+type ListJobsResponse struct {
+	Jobs []JobInfo `json:"jobs"`
+}
+
+// CancelJobResponse represents the response from cancelling an async job
+type CancelJobResponse struct {
+	Success bool `json:"success"`
+}
+
+// JobHistoryRequest filters the summaries of finished jobs
+type JobHistoryRequest struct {
+	Caller
+	Deadline
+
+	Type   string `json:"type,omitempty"`   // e.g., "migration"
+	Status string `json:"status,omitempty"` // completed, failed, cancelled or interrupted
+	Limit  int    `json:"limit,omitempty"`  // Default: 100, max: 1000
+}
+
+// JobHistoryResponse lists finished jobs, newest first
+type JobHistoryResponse struct {
+	Jobs []JobSummary `json:"jobs"`
+}
+
+// StartMultipartUploadRequest represents a request to start a client-driven multipart upload
+type StartMultipartUploadRequest struct {
+	Caller
+	Deadline
+
+	Bucket      string            `json:"bucket"`
+	Pathname    string            `json:"pathname"`
+	ContentType string            `json:"content_type,omitempty"`
+	Config      map[string]string `json:"config,omitempty"`
+	Visibility  string            `json:"visibility,omitempty"`
+	Size        int64             `json:"size,omitempty"` // Expected object size; sizes the recommended part_size
+	Transfer                      // part_size overrides the recommended part size; concurrency is client-side
+}
+
+// MultipartUploadRequest identifies a multipart upload
+type MultipartUploadRequest struct {
+	Caller
+	Deadline
+
+	UploadID string `json:"upload_id"`
+}
+
+// MultipartPartInfo represents a single uploaded part
+type MultipartPartInfo struct {
+	PartNumber int32  `json:"part_number"`
+	ETag       string `json:"etag"`
+	Size       int64  `json:"size"`
+}
+
+// MultipartUploadInfo represents the state of a multipart upload
+type MultipartUploadInfo struct {
+	UploadID       string              `json:"upload_id"`
+	Bucket         string              `json:"bucket"`
+	Pathname       string              `json:"pathname"`
+	Parts          []MultipartPartInfo `json:"parts"`
+	UploadedBytes  int64               `json:"uploaded_bytes"`
+	NextPartNumber int32               `json:"next_part_number"`
+	PartSize       int64               `json:"part_size,omitempty"` // Recommended part size for the bucket
+	CreatedAt      int64               `json:"created_at"`
+	Resumable      bool                `json:"resumable,omitempty"` // Parts are appended to a resumable session, in order
+}
+
+// UploadPartRequest represents a single part of a multipart upload
+type UploadPartRequest struct {
+	Caller
+	Deadline
+
+	UploadID   string `json:"upload_id"`
+	PartNumber int32  `json:"part_number,omitempty"` // 0 uses the next part number
+	Content    []byte `json:"content"`
+}
+
+// UploadPartResponse represents the response from uploading a part
+type UploadPartResponse struct {
+	PartNumber     int32  `json:"part_number"`
+	ETag           string `json:"etag"`
+	NextPartNumber int32  `json:"next_part_number"`
+	UploadedBytes  int64  `json:"uploaded_bytes,omitempty"` // Bytes held by a resumable upload; continue from here
+}
+
+// ListMultipartUploadsRequest represents a request to list tracked multipart uploads
+type ListMultipartUploadsRequest struct {
+	Caller
+	Deadline
+
+	Bucket string `json:"bucket,omitempty"` // Optional bucket filter
+}
+
+// ListMultipartUploadsResponse represents all tracked multipart uploads
+type ListMultipartUploadsResponse struct {
+	Uploads []MultipartUploadInfo `json:"uploads"`
+}
+
+// ListObjectsRequest represents a request to list objects in a bucket
+type ListObjectsRequest struct {
+	Caller
+	Deadline
+	Compression
+
+	Bucket            string `json:"bucket"`
+	Prefix            string `json:"prefix,omitempty"`             // Filter by prefix
+	Delimiter         string `json:"delimiter,omitempty"`          // Delimiter for grouping (e.g., "/")
+	MaxKeys           int32  `json:"max_keys,omitempty"`           // Maximum number of keys to return (default: 1000)
+	ContinuationToken string `json:"continuation_token,omitempty"` // Token for pagination
+	DirectoryMarkers  string `json:"directory_markers,omitempty"`  // Overrides bucket setting: "include", "skip" or "as_prefix"
+}
+
+// ObjectInfo represents information about a single S3 object
+type ObjectInfo struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	LastModified int64  `json:"last_modified"` // Unix timestamp
+	ETag         string `json:"etag"`
+	StorageClass string `json:"storage_class,omitempty"`
+}
+
+// CommonPrefix represents a common prefix (directory-like structure)
+type CommonPrefix struct {
+	Prefix string `json:"prefix"`
+}
+
+// ListObjectsResponse represents the response from list objects operation
+type ListObjectsResponse struct {
+	Objects               []ObjectInfo   `json:"objects"`
+	CommonPrefixes        []CommonPrefix `json:"common_prefixes,omitempty"`
+	IsTruncated           bool           `json:"is_truncated"`
+	NextContinuationToken string         `json:"next_continuation_token,omitempty"`
+	KeyCount              int32          `json:"key_count"`
+
+	// Set instead of Objects and CommonPrefixes when the listing is gzip-compressed
+	Compressed      []byte `json:"compressed,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+}
+
+// SearchObjectsRequest represents a search of the object index; all filters must match
+type SearchObjectsRequest struct {
+	Caller
+	Deadline
+
+	Bucket      string            `json:"bucket"`
+	Prefix      string            `json:"prefix,omitempty"`
+	Suffix      string            `json:"suffix,omitempty"`       // e.g. ".pdf"
+	ContentType string            `json:"content_type,omitempty"` // Exact, or every subtype when ending with "/"
+	MinSize     int64             `json:"min_size,omitempty"`
+	MaxSize     int64             `json:"max_size,omitempty"` // 0 for unbounded
+	Metadata    map[string]string `json:"metadata,omitempty"` // User metadata values to equal
+	Tags        map[string]string `json:"tags,omitempty"`     // Tag values to equal (requires index.tags)
+	After       string            `json:"after,omitempty"`    // Return pathnames after this one (next_after of the previous page)
+	Limit       int               `json:"limit,omitempty"`    // Default and maximum: index.max_results
+}
+
+// SearchObjectsResponse represents matching indexed objects in pathname order
+type SearchObjectsResponse struct {
+	Objects   []IndexedObject `json:"objects"`
+	Truncated bool            `json:"truncated"`
+	NextAfter string          `json:"next_after,omitempty"`
+}
+
+// InvalidateCDNRequest represents a request to purge pathnames from the bucket's CDN
+type InvalidateCDNRequest struct {
+	Caller
+	Deadline
+
+	Bucket    string   `json:"bucket"`
+	Pathnames []string `json:"pathnames"` // A trailing "*" invalidates everything under the pathname
+}
+
+// InvalidateCDNResponse contains the created invalidation
+type InvalidateCDNResponse struct {
+	InvalidationID string   `json:"invalidation_id"`
+	Status         string   `json:"status"`
+	Paths          []string `json:"paths"` // Paths as submitted to the CDN
+}
+
+// SetBucketNotificationsRequest represents a request to replace the event notifications of a bucket
+type SetBucketNotificationsRequest struct {
+	Caller
+	Deadline
+
+	Bucket        string               `json:"bucket"`
+	Notifications []BucketNotification `json:"notifications"`          // Empty removes every notification
+	EventBridge   bool                 `json:"event_bridge,omitempty"` // Also send all events to Amazon EventBridge
+
+	// SkipDestinationValidation skips the AWS check that S3 may publish to every target
+	SkipDestinationValidation bool `json:"skip_destination_validation,omitempty"`
+}
+
+// SetBucketNotificationsResponse represents the response from a notification configuration change
+type SetBucketNotificationsResponse struct {
+	Success       bool `json:"success"`
+	Notifications int  `json:"notifications"` // Notifications configured
+}
+
+// SubmitBatchJobRequest represents a request to run an S3 Batch Operations job over a prefix
+type SubmitBatchJobRequest struct {
+	Caller
+	Deadline
+
+	Bucket      string            `json:"bucket"`
+	Prefix      string            `json:"prefix"`      // Objects to process (empty: whole bucket)
+	Operation   string            `json:"operation"`   // "copy", "tag" or "acl"
+	DestBucket  string            `json:"dest_bucket"` // copy: destination bucket
+	DestPrefix  string            `json:"dest_prefix"` // copy: prepended to the full source key
+	Tags        map[string]string `json:"tags"`        // tag: replaces the tag set of every object
+	Visibility  string            `json:"visibility"`  // acl: required; copy: ACL of the copies (optional)
+	Description string            `json:"description"` // Job description shown in the S3 console (optional)
+}
+
+// SubmitBatchJobResponse contains the created job
+type SubmitBatchJobResponse struct {
+	JobID            string `json:"job_id"`
+	Objects          int64  `json:"objects"`           // Objects listed in the manifest
+	ManifestPathname string `json:"manifest_pathname"` // Manifest object in the source bucket
+}
+
+// GetBatchJobRequest represents a request for the status of an S3 Batch Operations job
+type GetBatchJobRequest struct {
+	Caller
+	Deadline
+
+	Bucket string `json:"bucket"`
+	JobID  string `json:"job_id"`
+}
+
+// GetBatchJobResponse contains the status and progress of a batch job
+type GetBatchJobResponse struct {
+	JobID          string   `json:"job_id"`
+	Description    string   `json:"description"`
+	Status         string   `json:"status"` // S3 job status, e.g. "Active", "Complete", "Failed"
+	StatusReason   string   `json:"status_reason,omitempty"`
+	TotalTasks     int64    `json:"total_tasks"`
+	SucceededTasks int64    `json:"succeeded_tasks"`
+	FailedTasks    int64    `json:"failed_tasks"`
+	Failures       []string `json:"failures,omitempty"`
+	CreatedAt      string   `json:"created_at,omitempty"`
+	FinishedAt     string   `json:"finished_at,omitempty"`
+	ReportPrefix   string   `json:"report_prefix"` // Bucket prefix of the failed task report
+}
+
+// GetDerivedPathnameRequest represents a request for the pathname of a derived object
+type GetDerivedPathnameRequest struct {
+	Caller
+	Deadline
+
+	Bucket   string `json:"bucket"`
+	Pathname string `json:"pathname"` // Source object
+	Variant  string `json:"variant"`  // Variant name, e.g. "thumb_200x200.webp"
+}
+
+// GetDerivedPathnameResponse contains the pathname of a derived object
+type GetDerivedPathnameResponse struct {
+	Pathname string `json:"pathname"`
+}
+
+// AcquireFileLockRequest represents a request for an advisory lock on a pathname
+type AcquireFileLockRequest struct {
+	Caller
+	Deadline
+
+	Bucket   string `json:"bucket"`
+	Pathname string `json:"pathname"`
+	Owner    string `json:"owner,omitempty"` // Free-form holder description, reported to other callers
+	TTL      string `json:"ttl,omitempty"`   // Lease duration (default: "1m", max: "24h")
+}
+
+// FileLockResponse describes the outcome of a lock attempt
+type FileLockResponse struct {
+	Acquired  bool   `json:"acquired"`
+	Token     string `json:"token,omitempty"` // Required to release; set only when acquired
+	Owner     string `json:"owner,omitempty"` // Current holder
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// ReleaseFileLockRequest represents a request to release an advisory lock
+type ReleaseFileLockRequest struct {
+	Caller
+	Deadline
+
+	Bucket   string `json:"bucket"`
+	Pathname string `json:"pathname"`
+	Token    string `json:"token"`
+}
+
+// ReleaseFileLockResponse reports whether the lock was still held and has been released
+type ReleaseFileLockResponse struct {
+	Released bool `json:"released"`
+}
+
+// WriteTemporaryRequest represents a request to store content in the temporary upload area
+type WriteTemporaryRequest struct {
+	Caller
+	Deadline
+
+	Bucket   string            `json:"bucket"`
+	Filename string            `json:"filename,omitempty"` // Original name, used to detect the content type
+	Content  []byte            `json:"content"`
+	Config   map[string]string `json:"config,omitempty"` // Metadata kept on promotion
+}
+
+// WriteTemporaryResponse identifies a temporary upload
+type WriteTemporaryResponse struct {
+	Token     string `json:"token"`
+	Size      int64  `json:"size"`
+	Checksum  string `json:"checksum"`   // Hex sha256 of the content
+	ExpiresAt int64  `json:"expires_at"` // Unix time after which Promote fails
+}
+
+// PromoteRequest represents a request to move a temporary upload to its final pathname
+type PromoteRequest struct {
+	Caller
+	Deadline
+
+	Bucket     string `json:"bucket"`
+	Token      string `json:"token"`
+	Pathname   string `json:"pathname"`
+	Checksum   string `json:"checksum,omitempty"` // Expected hex sha256 (optional)
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// PromoteResponse represents the response from a promotion
+type PromoteResponse struct {
+	Success      bool   `json:"success"`
+	Pathname     string `json:"pathname"`
+	Size         int64  `json:"size"`
+	LastModified int64  `json:"last_modified"`
+}
+
+// OpenListingRequest represents a request to open a streaming listing cursor
+type OpenListingRequest struct {
+	Caller
+	Deadline
+
+	Bucket           string `json:"bucket"`
+	Prefix           string `json:"prefix,omitempty"`            // Filter by prefix
+	Delimiter        string `json:"delimiter,omitempty"`         // Delimiter for grouping (e.g., "/")
+	DirectoryMarkers string `json:"directory_markers,omitempty"` // Overrides bucket setting: "include", "skip" or "as_prefix"
+}
+
+// ListCursorState represents an open listing cursor
+type ListCursorState struct {
+	CursorID  string `json:"cursor_id"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+	Delimiter string `json:"delimiter,omitempty"`
+}
+
+// FetchNextRequest represents a request for the next batch of a listing cursor
+type FetchNextRequest struct {
+	Caller
+	Deadline
+	Compression
+
+	CursorID string `json:"cursor_id"`
+	MaxKeys  int    `json:"max_keys,omitempty"` // Maximum number of entries to return (default: 1000)
+}
+
+// FetchNextResponse represents a single batch of a listing cursor
+type FetchNextResponse struct {
+	Objects        []ObjectInfo   `json:"objects"`
+	CommonPrefixes []CommonPrefix `json:"common_prefixes,omitempty"`
+	KeyCount       int32          `json:"key_count"`
+	Done           bool           `json:"done"` // No entries left; the cursor may be closed
+
+	// Set instead of Objects and CommonPrefixes when the listing is gzip-compressed
+	Compressed      []byte `json:"compressed,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+}
+
+// CloseListingRequest identifies an existing listing cursor
+type CloseListingRequest struct {
+	Caller
+	Deadline
+
+	CursorID string `json:"cursor_id"`
+}
+
+// CloseListingResponse represents the response from closing a listing cursor
+type CloseListingResponse struct {
+	Success bool `json:"success"`
+}
+
+// GetConfigRequest represents the request for the effective configuration
+type GetConfigRequest struct {
+	Caller
+	Deadline
+}
+
+// ServerConfigInfo is a server configuration with credentials redacted
+type ServerConfigInfo struct {
+	Preset             string         `json:"preset"`
+	Region             string         `json:"region"`
+	Endpoint           string         `json:"endpoint,omitempty"`
+	CopySourceEncoding string         `json:"copy_source_encoding"`
+	Signing            *SigningConfig `json:"signing,omitempty"`
+	Compatibility      string         `json:"compatibility"`
+	Anonymous          bool           `json:"anonymous,omitempty"`
+	Profile            string         `json:"profile,omitempty"`
+	SSOAccountID       string         `json:"sso_account_id,omitempty"`
+	SSORoleName        string         `json:"sso_role_name,omitempty"`
+	BucketCreation     bool           `json:"allow_bucket_creation"`
+	CredentialsKey     string         `json:"credentials_key,omitempty"`
+	CredentialsSecret  string         `json:"credentials_secret,omitempty"`
+	CredentialsToken   string         `json:"credentials_token,omitempty"`
+}
+
+// BucketConfigInfo is the effective configuration of a registered bucket
+type BucketConfigInfo struct {
+	Server                  string                 `json:"server"`
+	Bucket                  string                 `json:"bucket"`
+	Prefix                  string                 `json:"prefix,omitempty"`
+	Visibility              string                 `json:"visibility"`
+	MaxConcurrentOperations int                    `json:"max_concurrent_operations"`
+	PartSize                int64                  `json:"part_size"`
+	MinPartSize             int64                  `json:"min_part_size"`
+	MaxParts                int32                  `json:"max_parts"`
+	MultipartThreshold      int64                  `json:"multipart_threshold"`
+	Concurrency             int                    `json:"concurrency"`
+	DirectoryMarkers        string                 `json:"directory_markers"`
+	Dedup                   bool                   `json:"dedup"`
+	DedupPrefix             string                 `json:"dedup_prefix,omitempty"`
+	DerivedPrefix           string                 `json:"derived_prefix,omitempty"`
+	DisableACL              bool                   `json:"disable_acl"`
+	PublicMode              string                 `json:"public_mode,omitempty"`
+	PublicPrefix            string                 `json:"public_prefix,omitempty"`
+	CDN                     *CDNConfig             `json:"cdn,omitempty"`
+	AssumeRole              *AssumeRoleConfig      `json:"assume_role,omitempty"`
+	ReadCredentials         *ReadCredentialsConfig `json:"read_credentials,omitempty"`
+	MinIO                   *MinIOSetupConfig      `json:"minio,omitempty"`
+	CreateIfMissing         bool                   `json:"create_if_missing"`
+	LockSuffix              string                 `json:"lock_suffix"`
+	Expiration              *ExpirationConfig      `json:"expiration,omitempty"`
+	Temporary               *TemporaryConfig       `json:"temporary,omitempty"`
+	WritePolicy             *WritePolicyConfig     `json:"write_policy,omitempty"`
+	TreatForbiddenAsMissing bool                   `json:"treat_forbidden_as_missing"`
+	SkipPostWriteHead       bool                   `json:"skip_post_write_head"`
+	MetadataEncoding        string                 `json:"metadata_encoding"`
+	Sharding                *ShardingConfig        `json:"sharding,omitempty"`
+	ExistsFilter            *ExistsFilterConfig    `json:"exists_filter,omitempty"`
+	Batch                   *BatchConfig           `json:"batch,omitempty"`
+	RetryBudget             *RetryBudgetConfig     `json:"retry_budget"`
+	Hedging                 *HedgingConfig         `json:"hedging,omitempty"`
+	PresignCache            *PresignCacheConfig    `json:"presign_cache,omitempty"`
+	Retention               *RetentionConfig       `json:"retention,omitempty"`
+	Audit                   *AuditConfig           `json:"audit,omitempty"`
+	DirectoryBucket         bool                   `json:"directory_bucket,omitempty"`
+	Aliases                 []string               `json:"aliases,omitempty"`
+}
+
+// GetConfigResponse contains the effective plugin configuration after defaulting and validation
+type GetConfigResponse struct {
+	Default              string                      `json:"default"`
+	MaxConcurrent        int                         `json:"max_concurrent_operations"`
+	DownloadSessionTTL   string                      `json:"download_session_ttl"`
+	ListCursorTTL        string                      `json:"list_cursor_ttl"`
+	ShutdownTimeout      string                      `json:"shutdown_timeout"`
+	MaxListResponse      int64                       `json:"max_list_response_size"`
+	CompressionThreshold int64                       `json:"compression_threshold"`
+	ErrorFormat          string                      `json:"error_format"`
+	StateDir             string                      `json:"state_dir,omitempty"`
+	NormalizePaths       bool                        `json:"normalize_paths"`
+	Interceptors         []string                    `json:"interceptors"`
+	AccessRoles          []string                    `json:"access_roles,omitempty"`
+	AlertsEnabled        bool                        `json:"alerts_enabled"`
+	DynamicServers       bool                        `json:"allow_dynamic_servers"`
+	Servers              map[string]ServerConfigInfo `json:"servers"`
+	Buckets              map[string]BucketConfigInfo `json:"buckets"`
+	Routes               map[string]*RouteConfig     `json:"routes,omitempty"`
+}
+
+// GetCapabilitiesRequest represents the request for plugin version and feature discovery
+type GetCapabilitiesRequest struct {
+	Caller
+	Deadline
+}
+
+// GetCapabilitiesResponse describes what the running plugin and each bucket's provider support
+type GetCapabilitiesResponse struct {
+	Version    string                  `json:"version"`     // Plugin release
+	APIVersion int                     `json:"api_version"` // RPC contract version
+	Operations []string                `json:"operations"`  // RPC methods exposed by the plugin
+	Options    CapabilityOptions       `json:"options"`
+	Buckets    map[string]Capabilities `json:"buckets"` // Provider capabilities per bucket
+}
+
+// GetStatusRequest represents the request for detailed plugin status
+type GetStatusRequest struct {
+	Caller
+	Deadline
+}
+
+// BucketStatus contains the health and load of a single bucket
+type BucketStatus struct {
+	Name          string `json:"name"`
+	Bucket        string `json:"bucket"`
+	Healthy       bool   `json:"healthy"`
+	InFlight      int    `json:"in_flight"`
+	Queued        int64  `json:"queued"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	RetryTokens   uint   `json:"retry_tokens"` // Tokens left in the retry budget
+	LastErrorCode string `json:"last_error_code,omitempty"`
+	LastErrorAt   int64  `json:"last_error_at,omitempty"`
+	LastSuccessAt int64  `json:"last_success_at,omitempty"`
+
+	// Capabilities are the S3 features the bucket's server supports
+	Capabilities Capabilities `json:"capabilities"`
+
+	// PayloadSizes are the size distributions of object reads and writes since registration
+	PayloadSizes []PayloadSizes `json:"payload_sizes,omitempty"`
+
+	// Quota is the last measured usage of write_policy.quota (nil without a quota)
+	Quota *QuotaStatus `json:"quota,omitempty"`
+}
+
+// QuotaStatus compares the measured usage of a bucket with its quota
+type QuotaStatus struct {
+	Quota      int64   `json:"quota"`
+	Used       int64   `json:"used"`        // Bytes at the last measurement plus writes since
+	Ratio      float64 `json:"ratio"`       // Used divided by quota
+	MeasuredAt int64   `json:"measured_at"` // 0 until the first measurement
+	Threshold  float64 `json:"threshold"`   // Highest quota_alerts threshold reached (0: none)
+}
+
+// GetStatusResponse represents the detailed plugin status
+type GetStatusResponse struct {
+	Ready          bool           `json:"ready"`
+	GlobalInFlight int            `json:"global_in_flight"`
+	GlobalQueued   int64          `json:"global_queued"`
+	GlobalLimit    int            `json:"global_limit"`
+	Leader         bool           `json:"leader"` // Runs scheduled jobs; always true without coordination
+	Buckets        []BucketStatus `json:"buckets"`
+}
+
+// RegisterBucket registers a new bucket dynamically via RPC
+// Note: The bucket must reference an existing server from configuration
+func (r *rpc) RegisterBucket(req *RegisterBucketRequest, resp *RegisterBucketResponse) error {
+	return r.intercept("RegisterBucket", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "RegisterBucket", "register_bucket", ""); err != nil {
+			return err
+		}
+		return r.registerBucket(ctx, req, resp)
+	})
+}
+
+// registerBucket validates and registers a dynamic bucket
+func (r *rpc) registerBucket(ctx context.Context, req *RegisterBucketRequest, resp *RegisterBucketResponse) error {
+	r.log.Debug("registering bucket via RPC",
+		zap.String("name", req.Name),
+		zap.String("server", req.Server),
+		zap.String("bucket", req.Bucket),
+	)
+
+	// Create bucket configuration from request
+	cfg := &BucketConfig{
+		Server:     req.Server,
+		Bucket:     req.Bucket,
+		Prefix:     req.Prefix,
+		Visibility: req.Visibility,
+	}
+
+	// Get bucket manager to access server configs
+	bucketManager := r.plugin.GetBucketManager()
+
+	// Validate configuration (this will check if server exists)
+	if err := bucketManager.ValidateBucket(cfg); err != nil {
+		resp.Success = false
+		resp.Message = "Invalid configuration: " + err.Error()
+		return NewInvalidConfigError(err.Error())
+	}
+
+	// Register bucket
+	if err := bucketManager.RegisterBucket(ctx, req.Name, cfg); err != nil {
+		resp.Success = false
+		resp.Message = "Failed to register bucket: " + err.Error()
+		return err
+	}
+
+	// Persist for restarts; the bucket stays registered even if this fails
+	if err := r.plugin.registry.AddBucket(req.Name, cfg); err != nil {
+		r.log.Error("failed to persist bucket registration", zap.String("name", req.Name), zap.Error(err))
+	}
+
+	resp.Success = true
+	resp.Message = "Bucket registered successfully"
+	return nil
+}
+
+// RegisterServer registers a new server (endpoint and credentials) dynamically via RPC
+// Note: Requires allow_dynamic_servers to be enabled in configuration
+func (r *rpc) RegisterServer(req *RegisterServerRequest, resp *RegisterServerResponse) error {
+	return r.intercept("RegisterServer", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "RegisterServer", "register_server", ""); err != nil {
+			return err
+		}
+
+		if !r.plugin.config.AllowDynamicServers {
+			resp.Message = "Dynamic servers are disabled"
+			return NewPermissionDeniedError("RegisterServer")
+		}
+
+		if req.Name == "" {
+			resp.Message = "Server name is required"
+			return NewInvalidConfigError("server name is required")
+		}
+
+		r.log.Debug("registering server via RPC",
+			zap.String("name", req.Name),
+			zap.String("region", req.Region),
+			zap.String("endpoint", req.Endpoint),
+		)
+
+		cfg := &ServerConfig{
+			Preset:             req.Preset,
+			Driver:             req.Driver,
+			Region:             req.Region,
+			Endpoint:           req.Endpoint,
+			CopySourceEncoding: req.CopySourceEncoding,
+			Signing:            req.Signing,
+			Compatibility:      req.Compatibility,
+			Credentials: ServerCredentials{
+				Key:    req.Key,
+				Secret: req.Secret,
+				Token:  req.SessionToken,
+			},
+		}
+
+		if err := r.plugin.buckets.RegisterServer(req.Name, cfg); err != nil {
+			resp.Message = "Failed to register server: " + err.Error()
+			return NewInvalidConfigError(err.Error())
+		}
+
+		// Persist for restarts; the server stays registered even if this fails
+		if err := r.plugin.registry.AddServer(req.Name, cfg); err != nil {
+			r.log.Error("failed to persist server registration", zap.String("name", req.Name), zap.Error(err))
+		}
+
+		resp.Success = true
+		resp.Message = "Server registered successfully"
+		return nil
+	})
+}
+
+// PurgeRegistrations removes persisted dynamic servers and buckets
+// Note: Runtime registrations stay active until the next restart
+func (r *rpc) PurgeRegistrations(req *PurgeRegistrationsRequest, resp *PurgeRegistrationsResponse) error {
+	return r.intercept("PurgeRegistrations", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "PurgeRegistrations", "purge_registrations", ""); err != nil {
+			return err
+		}
+
+		if r.plugin.registry == nil {
+			return NewInvalidRequestError("persist_dynamic is not enabled")
+		}
+
+		servers, buckets, err := r.plugin.registry.Purge(req.Servers, req.Buckets, req.All)
+		if err != nil {
+			return NewS3OperationError("purge registrations", err)
+		}
+
+		resp.Servers = servers
+		resp.Buckets = buckets
+		return nil
+	})
+}
+
+// ListBuckets lists all registered buckets
+func (r *rpc) ListBuckets(req *ListBucketsRequest, resp *ListBucketsResponse) error {
+	return r.intercept("ListBuckets", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "ListBuckets", "list_buckets", ""); err != nil {
+			return err
+		}
+
+		resp.Buckets = r.plugin.buckets.ListBuckets()
+		resp.Default = r.plugin.buckets.GetDefaultBucketName()
+		if req.IncludeAliases {
+			resp.Aliases = r.plugin.buckets.Aliases()
+		}
+		return nil
+	})
+}
+
+// Write uploads a file to S3
+func (r *rpc) Write(req *WriteRequest, resp *WriteResponse) error {
+	return r.intercept("Write", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.Write(ctx, req, resp)
+	})
+}
+
+// WriteFromURL downloads a remote URL into S3 without passing the content through PHP
+func (r *rpc) WriteFromURL(req *WriteFromURLRequest, resp *WriteResponse) error {
+	return r.intercept("WriteFromURL", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.WriteFromURL(ctx, req, resp)
+	})
+}
+
+// CopyExternal streams an object from another S3 account or provider into S3
+func (r *rpc) CopyExternal(req *CopyExternalRequest, resp *WriteResponse) error {
+	return r.intercept("CopyExternal", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.CopyExternal(ctx, req, resp)
+	})
+}
+
+// Read downloads a file from S3
+func (r *rpc) Read(req *ReadRequest, resp *ReadResponse) error {
+	return r.intercept("Read", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.Read(ctx, req, resp)
+	})
+}
+
+// Exists checks if a file exists in S3
+func (r *rpc) Exists(req *ExistsRequest, resp *ExistsResponse) error {
+	return r.intercept("Exists", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.Exists(ctx, req, resp)
+	})
+}
+
+// WaitUntilExists blocks until an object exists, e.g. a file dropped into the bucket by a third party
+func (r *rpc) WaitUntilExists(req *WaitRequest, resp *WaitResponse) error {
+	return r.intercept("WaitUntilExists", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.WaitUntilExists(ctx, req, resp)
+	})
+}
+
+// WaitUntilNotExists blocks until an object is gone
+func (r *rpc) WaitUntilNotExists(req *WaitRequest, resp *WaitResponse) error {
+	return r.intercept("WaitUntilNotExists", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.WaitUntilNotExists(ctx, req, resp)
+	})
+}
+
+// Delete deletes a file from S3
+func (r *rpc) Delete(req *DeleteRequest, resp *DeleteResponse) error {
+	return r.intercept("Delete", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.Delete(ctx, req, resp)
+	})
+}
+
+// Copy copies a file within or between buckets
+func (r *rpc) Copy(req *CopyRequest, resp *CopyResponse) error {
+	return r.intercept("Copy", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.Copy(ctx, req, resp)
+	})
+}
+
+// Move moves a file within or between buckets
+func (r *rpc) Move(req *MoveRequest, resp *MoveResponse) error {
+	return r.intercept("Move", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.Move(ctx, req, resp)
+	})
+}
+
+// GetMetadata retrieves file metadata
+func (r *rpc) GetMetadata(req *GetMetadataRequest, resp *GetMetadataResponse) error {
+	return r.intercept("GetMetadata", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.GetMetadata(ctx, req, resp)
+	})
+}
+
+// SetVisibility changes file visibility (ACL)
+func (r *rpc) SetVisibility(req *SetVisibilityRequest, resp *SetVisibilityResponse) error {
+	return r.intercept("SetVisibility", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.SetVisibility(ctx, req, resp)
+	})
+}
+
+// GetPublicURL generates a public or presigned URL for a file
+func (r *rpc) GetPublicURL(req *GetPublicURLRequest, resp *GetPublicURLResponse) error {
+	return r.intercept("GetPublicURL", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.GetPublicURL(ctx, req, resp)
+	})
+}
+
+// GetPresignedPost generates a presigned POST policy for browser form uploads
+func (r *rpc) GetPresignedPost(req *GetPresignedPostRequest, resp *GetPresignedPostResponse) error {
+	return r.intercept("GetPresignedPost", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.GetPresignedPost(ctx, req, resp)
+	})
+}
+
+// CreateShortLink creates a short download link served by the HTTP middleware
+func (r *rpc) CreateShortLink(req *CreateShortLinkRequest, resp *CreateShortLinkResponse) error {
+	return r.intercept("CreateShortLink", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.CreateShortLink(ctx, req, resp)
+	})
+}
+
+// DeleteShortLink revokes a short link
+func (r *rpc) DeleteShortLink(req *DeleteShortLinkRequest, resp *DeleteShortLinkResponse) error {
+	return r.intercept("DeleteShortLink", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.DeleteShortLink(ctx, req, resp)
+	})
+}
+
+// StartDownloadSession opens a resumable chunked download session
+func (r *rpc) StartDownloadSession(req *StartDownloadSessionRequest, resp *DownloadSessionState) error {
+	return r.intercept("StartDownloadSession", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartDownloadSession(ctx, req, resp)
+	})
+}
+
+// FetchDownloadChunk reads the next chunk of a download session
+func (r *rpc) FetchDownloadChunk(req *FetchDownloadChunkRequest, resp *FetchDownloadChunkResponse) error {
+	return r.intercept("FetchDownloadChunk", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.FetchDownloadChunk(ctx, req, resp)
+	})
+}
+
+// GetDownloadSession returns the state of a download session
+func (r *rpc) GetDownloadSession(req *DownloadSessionRequest, resp *DownloadSessionState) error {
+	return r.intercept("GetDownloadSession", req, resp, func(context.Context) error {
+		return r.plugin.operations.GetDownloadSession(req, resp)
+	})
+}
+
+// CloseDownloadSession discards a download session
+func (r *rpc) CloseDownloadSession(req *DownloadSessionRequest, resp *CloseDownloadSessionResponse) error {
+	return r.intercept("CloseDownloadSession", req, resp, func(context.Context) error {
+		return r.plugin.operations.CloseDownloadSession(req, resp)
+	})
+}
+
+// CompareObjects compares two objects within or between buckets
+func (r *rpc) CompareObjects(req *CompareObjectsRequest, resp *CompareObjectsResponse) error {
+	return r.intercept("CompareObjects", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.CompareObjects(ctx, req, resp)
+	})
+}
+
+// StartMigration starts an async job migrating a prefix to another bucket
+func (r *rpc) StartMigration(req *MigrationRequest, resp *StartJobResponse) error {
+	return r.intercept("StartMigration", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartMigration(ctx, req, resp)
+	})
+}
+
+// StartReindex starts an async job rebuilding the object index of a bucket
+func (r *rpc) StartReindex(req *ReindexRequest, resp *StartJobResponse) error {
+	return r.intercept("StartReindex", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartReindex(ctx, req, resp)
+	})
+}
+
+// VerifyChain checks the sequence numbers and hash links of every object in an audit bucket
+func (r *rpc) VerifyChain(req *VerifyChainRequest, resp *VerifyChainResponse) error {
+	return r.intercept("VerifyChain", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.VerifyChain(ctx, req, resp)
+	})
+}
+
+// GetBucketReport reports the largest objects and bytes per content type and prefix from the object index
+func (r *rpc) GetBucketReport(req *BucketReportRequest, resp *BucketReport) error {
+	return r.intercept("GetBucketReport", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.GetBucketReport(ctx, req, resp)
+	})
+}
+
+// StartBucketReport starts an async job computing a bucket report from a listing
+func (r *rpc) StartBucketReport(req *BucketReportRequest, resp *StartJobResponse) error {
+	return r.intercept("StartBucketReport", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartBucketReport(ctx, req, resp)
+	})
+}
+
+// StartVerify starts an async job verifying the integrity of the objects under a prefix
+func (r *rpc) StartVerify(req *VerifyRequest, resp *StartJobResponse) error {
+	return r.intercept("StartVerify", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartVerify(ctx, req, resp)
+	})
+}
+
+// StartDuplicateScan starts an async job finding, and optionally removing, duplicate objects
+func (r *rpc) StartDuplicateScan(req *DuplicateScanRequest, resp *StartJobResponse) error {
+	return r.intercept("StartDuplicateScan", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartDuplicateScan(ctx, req, resp)
+	})
+}
+
+// StartInventory starts an async job writing an S3 Inventory compatible listing of a prefix
+func (r *rpc) StartInventory(req *InventoryRequest, resp *StartJobResponse) error {
+	return r.intercept("StartInventory", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartInventory(ctx, req, resp)
+	})
+}
+
+// StartExport starts an async job bundling objects with a signed manifest
+func (r *rpc) StartExport(req *ExportRequest, resp *StartJobResponse) error {
+	return r.intercept("StartExport", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartExport(ctx, req, resp)
+	})
+}
+
+// StartDedupGC launches an async job deleting blobs no longer referenced in a dedup bucket
+func (r *rpc) StartDedupGC(req *DedupGCRequest, resp *StartJobResponse) error {
+	return r.intercept("StartDedupGC", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartDedupGC(ctx, req, resp)
+	})
+}
+
+// PublishDirectory launches an async job uploading a local directory tree with per-extension headers
+func (r *rpc) PublishDirectory(req *PublishDirectoryRequest, resp *StartJobResponse) error {
+	return r.intercept("PublishDirectory", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartPublishDirectory(ctx, req, resp)
+	})
+}
+
+// ExtractArchive launches an async job writing the entries of an archive as individual objects
+func (r *rpc) ExtractArchive(req *ExtractArchiveRequest, resp *StartJobResponse) error {
+	return r.intercept("ExtractArchive", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartExtractArchive(ctx, req, resp)
+	})
+}
+
+// CreateArchive launches an async job streaming all objects under a prefix into a zip or tar.gz
+func (r *rpc) CreateArchive(req *CreateArchiveRequest, resp *StartJobResponse) error {
+	return r.intercept("CreateArchive", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartCreateArchive(ctx, req, resp)
+	})
+}
+
+// GetJob returns the state of an async job
+func (r *rpc) GetJob(req *JobRequest, resp *JobInfo) error {
+	return r.intercept("GetJob", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "GetJob", "job_get", ""); err != nil {
+			return err
+		}
+
+		job, exists := r.plugin.jobs.Get(req.JobID)
+		if !exists {
+			// Finished jobs are dropped after a while, their summary remains
+			summary, exists := r.plugin.jobs.History().get(req.JobID)
+			if !exists {
+				return NewJobNotFoundError(req.JobID)
+			}
+			if err := r.plugin.operations.authorizeJob(ctx, req.Caller, "GetJob", "job_get", summary.Buckets); err != nil {
+				return err
+			}
+			*resp = summary.info()
+			return nil
+		}
+		if err := r.plugin.operations.authorizeJob(ctx, req.Caller, "GetJob", "job_get", job.Buckets); err != nil {
+			return err
+		}
+
+		*resp = job.Info()
+		return nil
+	})
+}
+
+// ListJobs lists all async jobs
+func (r *rpc) ListJobs(req *ListJobsRequest, resp *ListJobsResponse) error {
+	return r.intercept("ListJobs", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "ListJobs", "job_list", ""); err != nil {
+			return err
+		}
+
+		// Jobs of buckets the caller cannot access are left out
+		jobs := r.plugin.jobs.List()
+		resp.Jobs = make([]JobInfo, 0, len(jobs))
+		for _, job := range jobs {
+			if r.plugin.operations.allowsJob(req.Caller, "ListJobs", job.Buckets) {
+				resp.Jobs = append(resp.Jobs, job.Info())
+			}
+		}
+		return nil
+	})
+}
+
+// CancelJob cancels a running async job
+func (r *rpc) CancelJob(req *JobRequest, resp *CancelJobResponse) error {
+	return r.intercept("CancelJob", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "CancelJob", "job_cancel", ""); err != nil {
+			return err
+		}
+
+		job, exists := r.plugin.jobs.Get(req.JobID)
+		if !exists {
+			return NewJobNotFoundError(req.JobID)
+		}
+		if err := r.plugin.operations.authorizeJob(ctx, req.Caller, "CancelJob", "job_cancel", job.Buckets); err != nil {
+			return err
+		}
+
+		if err := r.plugin.jobs.Cancel(req.JobID); err != nil {
+			return NewJobNotFoundError(req.JobID)
+		}
+
+		resp.Success = true
+		return nil
+	})
+}
+
+// GetJobHistory lists the summaries of finished jobs, including those of previous plugin runs
+func (r *rpc) GetJobHistory(req *JobHistoryRequest, resp *JobHistoryResponse) error {
+	return r.intercept("GetJobHistory", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "GetJobHistory", "job_history", ""); err != nil {
+			return err
+		}
+
+		if req.Limit < 0 || req.Limit > maxJobHistoryResults {
+			return NewInvalidRequestError("limit must be between 0 and 1000")
+		}
+		if req.Limit == 0 {
+			req.Limit = 100
+		}
+
+		// Jobs of buckets the caller cannot access are left out
+		resp.Jobs = r.plugin.jobs.History().list(req.Type, req.Status, req.Limit, func(summary JobSummary) bool {
+			return r.plugin.operations.allowsJob(req.Caller, "GetJobHistory", summary.Buckets)
+		})
+		return nil
+	})
+}
+
+// GetJobResult returns the summary of a finished job, with its parameters and result
+func (r *rpc) GetJobResult(req *JobRequest, resp *JobSummary) error {
+	return r.intercept("GetJobResult", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "GetJobResult", "job_result", ""); err != nil {
+			return err
+		}
+
+		// A restarted job with a fixed ID has an older summary; the running one is not finished yet
+		if job, exists := r.plugin.jobs.Get(req.JobID); exists && !job.finished() {
+			if err := r.plugin.operations.authorizeJob(ctx, req.Caller, "GetJobResult", "job_result", job.Buckets); err != nil {
+				return err
+			}
+			return NewInvalidRequestError("job '" + req.JobID + "' is still running, use GetJob for its progress")
+		}
+
+		summary, exists := r.plugin.jobs.History().get(req.JobID)
+		if !exists {
+			return NewJobNotFoundError(req.JobID)
+		}
+		if err := r.plugin.operations.authorizeJob(ctx, req.Caller, "GetJobResult", "job_result", summary.Buckets); err != nil {
+			return err
+		}
+
+		*resp = summary
+		return nil
+	})
+}
+
+// StartMultipartUpload starts a client-driven multipart upload
+func (r *rpc) StartMultipartUpload(req *StartMultipartUploadRequest, resp *MultipartUploadInfo) error {
+	return r.intercept("StartMultipartUpload", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StartMultipartUpload(ctx, req, resp)
+	})
+}
+
+// UploadPart uploads a single part of a multipart upload
+func (r *rpc) UploadPart(req *UploadPartRequest, resp *UploadPartResponse) error {
+	return r.intercept("UploadPart", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.UploadPart(ctx, req, resp)
+	})
+}
+
+// GetMultipartUpload returns the state of a multipart upload
+func (r *rpc) GetMultipartUpload(req *MultipartUploadRequest, resp *MultipartUploadInfo) error {
+	return r.intercept("GetMultipartUpload", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.GetMultipartUpload(ctx, req, resp)
+	})
+}
+
+// ListMultipartUploads lists multipart uploads tracked by the plugin
+func (r *rpc) ListMultipartUploads(req *ListMultipartUploadsRequest, resp *ListMultipartUploadsResponse) error {
+	return r.intercept("ListMultipartUploads", req, resp, func(context.Context) error {
+		return r.plugin.operations.ListMultipartUploads(req, resp)
+	})
+}
+
+// CompleteMultipartUpload completes a multipart upload
+func (r *rpc) CompleteMultipartUpload(req *MultipartUploadRequest, resp *WriteResponse) error {
+	return r.intercept("CompleteMultipartUpload", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.CompleteMultipartUpload(ctx, req, resp)
+	})
+}
+
+// AbortMultipartUpload aborts a multipart upload
+func (r *rpc) AbortMultipartUpload(req *MultipartUploadRequest, resp *DeleteResponse) error {
+	return r.intercept("AbortMultipartUpload", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.AbortMultipartUpload(ctx, req, resp)
+	})
+}
+
+// ListObjects lists objects in a bucket with optional filtering
+func (r *rpc) ListObjects(req *ListObjectsRequest, resp *ListObjectsResponse) error {
+	return r.intercept("ListObjects", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.ListObjects(ctx, req, resp)
+	})
+}
+
+// SearchObjects searches the object index by metadata, tags, pathname and size
+func (r *rpc) SearchObjects(req *SearchObjectsRequest, resp *SearchObjectsResponse) error {
+	return r.intercept("SearchObjects", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.SearchObjects(ctx, req, resp)
+	})
+}
+
+// InvalidateCDN purges pathnames from the CDN serving a bucket
+func (r *rpc) InvalidateCDN(req *InvalidateCDNRequest, resp *InvalidateCDNResponse) error {
+	return r.intercept("InvalidateCDN", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.InvalidateCDN(ctx, req, resp)
+	})
+}
+
+// SetBucketNotifications replaces the event notification configuration of a bucket
+func (r *rpc) SetBucketNotifications(req *SetBucketNotificationsRequest, resp *SetBucketNotificationsResponse) error {
+	return r.intercept("SetBucketNotifications", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.SetBucketNotifications(ctx, req, resp)
+	})
+}
+
+// SubmitBatchJob creates an S3 Batch Operations job over the objects under a prefix
+func (r *rpc) SubmitBatchJob(req *SubmitBatchJobRequest, resp *SubmitBatchJobResponse) error {
+	return r.intercept("SubmitBatchJob", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.SubmitBatchJob(ctx, req, resp)
+	})
+}
+
+// GetBatchJob returns the status and progress of an S3 Batch Operations job
+func (r *rpc) GetBatchJob(req *GetBatchJobRequest, resp *GetBatchJobResponse) error {
+	return r.intercept("GetBatchJob", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.GetBatchJob(ctx, req, resp)
+	})
+}
+
+// GetDerivedPathname returns the deterministic pathname of a derived variant of an object
+func (r *rpc) GetDerivedPathname(req *GetDerivedPathnameRequest, resp *GetDerivedPathnameResponse) error {
+	return r.intercept("GetDerivedPathname", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.GetDerivedPathname(ctx, req, resp)
+	})
+}
+
+// AcquireFileLock takes an advisory lock on a pathname
+func (r *rpc) AcquireFileLock(req *AcquireFileLockRequest, resp *FileLockResponse) error {
+	return r.intercept("AcquireFileLock", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.AcquireFileLock(ctx, req, resp)
+	})
+}
+
+// ReleaseFileLock releases an advisory lock taken with AcquireFileLock
+func (r *rpc) ReleaseFileLock(req *ReleaseFileLockRequest, resp *ReleaseFileLockResponse) error {
+	return r.intercept("ReleaseFileLock", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.ReleaseFileLock(ctx, req, resp)
+	})
+}
+
+// StageWrite uploads content for a later CommitWrite or AbortWrite
+func (r *rpc) StageWrite(req *StageWriteRequest, resp *StageWriteResponse) error {
+	return r.intercept("StageWrite", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.StageWrite(ctx, req, resp)
+	})
+}
+
+// CommitWrite moves a staged write to its final pathname
+func (r *rpc) CommitWrite(req *StagedWriteRequest, resp *PromoteResponse) error {
+	return r.intercept("CommitWrite", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.CommitWrite(ctx, req, resp)
+	})
+}
+
+// AbortWrite discards a staged write
+func (r *rpc) AbortWrite(req *StagedWriteRequest, resp *AbortWriteResponse) error {
+	return r.intercept("AbortWrite", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.AbortWrite(ctx, req, resp)
+	})
+}
+
+// WriteTransaction writes a set of objects, deleting the written ones again if any write fails
+func (r *rpc) WriteTransaction(req *WriteTransactionRequest, resp *WriteTransactionResponse) error {
+	return r.intercept("WriteTransaction", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.WriteTransaction(ctx, req, resp)
+	})
+}
+
+// CanWrite checks access, read-only mode, visibility and the write policy for a prospective write
+func (r *rpc) CanWrite(req *CanWriteRequest, resp *CanWriteResponse) error {
+	return r.intercept("CanWrite", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.CanWrite(ctx, req, resp)
+	})
+}
+
+// WriteTemporary stores content under the bucket's temporary prefix for a later Promote
+func (r *rpc) WriteTemporary(req *WriteTemporaryRequest, resp *WriteTemporaryResponse) error {
+	return r.intercept("WriteTemporary", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.WriteTemporary(ctx, req, resp)
+	})
+}
+
+// Promote moves a temporary upload to its final pathname
+func (r *rpc) Promote(req *PromoteRequest, resp *PromoteResponse) error {
+	return r.intercept("Promote", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.Promote(ctx, req, resp)
+	})
+}
+
+// OpenListing opens a cursor streaming all objects under a prefix
+func (r *rpc) OpenListing(req *OpenListingRequest, resp *ListCursorState) error {
+	return r.intercept("OpenListing", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.OpenListing(ctx, req, resp)
+	})
+}
+
+// FetchNext returns the next batch of a listing cursor
+func (r *rpc) FetchNext(req *FetchNextRequest, resp *FetchNextResponse) error {
+	return r.intercept("FetchNext", req, resp, func(ctx context.Context) error {
+		return r.plugin.operations.FetchNext(ctx, req, resp)
+	})
+}
+
+// CloseListing discards a listing cursor
+func (r *rpc) CloseListing(req *CloseListingRequest, resp *CloseListingResponse) error {
+	return r.intercept("CloseListing", req, resp, func(context.Context) error {
+		return r.plugin.operations.CloseListing(req, resp)
+	})
+}
+
+// GetStatus returns per-bucket health, in-flight and queued operations and the latest error
+func (r *rpc) GetStatus(req *GetStatusRequest, resp *GetStatusResponse) error {
+	return r.intercept("GetStatus", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "GetStatus", "status", ""); err != nil {
+			return err
+		}
+
+		resp.Buckets = r.plugin.bucketStatuses()
+		resp.GlobalInFlight, resp.GlobalQueued, resp.GlobalLimit = r.plugin.buckets.GlobalUsage()
+		resp.Ready = r.plugin.ctx.Err() == nil
+		resp.Leader = r.plugin.leader.isLeader()
+		for _, bucket := range resp.Buckets {
+			if !bucket.Healthy {
+				resp.Ready = false
+			}
+		}
+		return nil
+	})
+}
+
+// GetCapabilities returns the plugin version, supported operations and options, and per-bucket provider capabilities
+func (r *rpc) GetCapabilities(req *GetCapabilitiesRequest, resp *GetCapabilitiesResponse) error {
+	return r.intercept("GetCapabilities", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "GetCapabilities", "get_capabilities", ""); err != nil {
+			return err
+		}
+
+		r.plugin.capabilities(resp)
+		return nil
+	})
+}
+
+// GetConfig returns the effective configuration with credentials redacted
+func (r *rpc) GetConfig(req *GetConfigRequest, resp *GetConfigResponse) error {
+	return r.intercept("GetConfig", req, resp, func(ctx context.Context) error {
+		if err := r.plugin.operations.authorize(ctx, req.Caller, "GetConfig", "get_config", ""); err != nil {
+			return err
+		}
+
+		r.plugin.effectiveConfig(resp)
+		return nil
+	})
 }
@@ -0,0 +1,177 @@
+package s3
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// EncryptionKeyConfig selects where the master key for persisted secrets comes from.
+// Exactly one source must be set.
+type EncryptionKeyConfig struct {
+	// Env is the name of an environment variable holding the key
+	Env string `mapstructure:"env"`
+
+	// File is a path to a file holding the key (e.g., a mounted secret)
+	File string `mapstructure:"file"`
+
+	// KMS decrypts a KMS-encrypted data key at startup
+	KMS *KMSKeyConfig `mapstructure:"kms"`
+}
+
+// KMSKeyConfig describes a data key encrypted with AWS KMS
+type KMSKeyConfig struct {
+	// Ciphertext is the base64-encoded CiphertextBlob returned by KMS Encrypt/GenerateDataKey
+	Ciphertext string `mapstructure:"ciphertext"`
+
+	// KeyID optionally pins the KMS key used for decryption
+	KeyID string `mapstructure:"key_id"`
+
+	// Server references a configured server whose region and credentials are used for KMS
+	Server string `mapstructure:"server"`
+}
+
+// Validate validates the key source selection
+func (ec *EncryptionKeyConfig) Validate(servers map[string]*ServerConfig) error {
+	sources := 0
+	if ec.Env != "" {
+		sources++
+	}
+	if ec.File != "" {
+		sources++
+	}
+	if ec.KMS != nil {
+		sources++
+		if ec.KMS.Ciphertext == "" {
+			return fmt.Errorf("kms.ciphertext is required")
+		}
+		if _, exists := servers[ec.KMS.Server]; !exists {
+			return fmt.Errorf("kms.server '%s' not found", ec.KMS.Server)
+		}
+	}
+
+	if sources != 1 {
+		return fmt.Errorf("exactly one of env, file or kms must be set")
+	}
+
+	return nil
+}
+
+// resolveEncryptionKey loads the master key from the configured source
+func resolveEncryptionKey(ctx context.Context, ec *EncryptionKeyConfig, buckets *BucketManager) (string, error) {
+	switch {
+	case ec.Env != "":
+		key := os.Getenv(ec.Env)
+		if key == "" {
+			return "", fmt.Errorf("environment variable '%s' is empty", ec.Env)
+		}
+		return key, nil
+
+	case ec.File != "":
+		data, err := os.ReadFile(ec.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read key file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+
+	default:
+		return decryptKMSKey(ctx, ec.KMS, buckets)
+	}
+}
+
+// decryptKMSKey decrypts a KMS-encrypted data key using a configured server's credentials
+func decryptKMSKey(ctx context.Context, kc *KMSKeyConfig, buckets *BucketManager) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(kc.Ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid kms.ciphertext: %w", err)
+	}
+
+	buckets.mu.RLock()
+	serverCfg, exists := buckets.servers[kc.Server]
+	buckets.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("kms.server '%s' not found", kc.Server)
+	}
+
+	awsCfg, err := buckets.createAWSConfig(ctx, serverCfg)
+	if err != nil {
+		return "", err
+	}
+
+	input := &kms.DecryptInput{CiphertextBlob: blob}
+	if kc.KeyID != "" {
+		input.KeyId = aws.String(kc.KeyID)
+	}
+
+	result, err := kms.NewFromConfig(awsCfg).Decrypt(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("kms decrypt failed: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(result.Plaintext), nil
+}
+
+// secretBox encrypts secrets persisted to local state with AES-256-GCM
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+// newSecretBox derives an AES-256 key from the configured master key
+func newSecretBox(masterKey string) (*secretBox, error) {
+	if masterKey == "" {
+		return nil, fmt.Errorf("encryption key is empty")
+	}
+
+	key := sha256.Sum256([]byte(masterKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secretBox{aead: aead}, nil
+}
+
+// seal encrypts plaintext and returns base64(nonce || ciphertext)
+func (sb *secretBox) seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, sb.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := sb.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a value produced by seal
+func (sb *secretBox) open(value string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < sb.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted value is too short")
+	}
+
+	nonce, ciphertext := sealed[:sb.aead.NonceSize()], sealed[sb.aead.NonceSize():]
+	plaintext, err := sb.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value (wrong encryption key?): %w", err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,101 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// defaultShardWidth is the number of hex characters in a shard, 16 shards
+	defaultShardWidth = 1
+
+	// maxShardWidth bounds the shard count to 4096, each listing visits every shard
+	maxShardWidth = 3
+)
+
+// ShardingConfig spreads objects over key prefixes derived from a hash of their pathname, for
+// workloads exceeding the S3 request rate of a single prefix. Objects are stored as
+// "<prefix><shard>/<pathname>"; pathnames in requests and responses never contain the shard.
+type ShardingConfig struct {
+	// Width is the number of hex characters of the shard, 1 to 3 for 16 to 4096 shards (default: 1)
+	Width int `mapstructure:"width" json:"width"`
+}
+
+// Validate validates the sharding configuration and applies defaults
+func (sc *ShardingConfig) Validate() error {
+	if sc.Width == 0 {
+		sc.Width = defaultShardWidth
+	}
+	if sc.Width < 1 || sc.Width > maxShardWidth {
+		return fmt.Errorf("sharding.width must be between 1 and %d, got %d", maxShardWidth, sc.Width)
+	}
+	return nil
+}
+
+// shard returns the shard of pathname
+func (sc *ShardingConfig) shard(pathname string) string {
+	sum := md5.Sum([]byte(pathname))
+	return hex.EncodeToString(sum[:])[:sc.Width]
+}
+
+// shards returns every shard in key order
+func (sc *ShardingConfig) shards() []string {
+	count := 1 << (4 * sc.Width)
+	shards := make([]string, count)
+	for i := range shards {
+		shards[i] = fmt.Sprintf("%0*x", sc.Width, i)
+	}
+	return shards
+}
+
+// strip removes the shard from a bucket-relative key
+func (sc *ShardingConfig) strip(key string) string {
+	if len(key) > sc.Width && key[sc.Width] == '/' {
+		return key[sc.Width+1:]
+	}
+	return key
+}
+
+// ObjectKey returns the S3 key of the object at pathname, including prefix and shard
+func (bc *BucketConfig) ObjectKey(pathname string) string {
+	if bc.Sharding == nil {
+		return bc.GetFullPath(pathname)
+	}
+	return bc.GetFullPath(bc.Sharding.shard(pathname) + "/" + pathname)
+}
+
+// pathnameOf returns the pathname of an S3 key, the inverse of ObjectKey
+func (bc *BucketConfig) pathnameOf(key string) string {
+	if bc.Prefix != "" {
+		key = strings.TrimPrefix(key, bc.Prefix)
+	}
+	if bc.Sharding != nil {
+		key = bc.Sharding.strip(key)
+	}
+	return key
+}
+
+// ObjectKey returns the S3 key of the object at pathname, including prefix and shard
+func (b *Bucket) ObjectKey(pathname string) string {
+	return b.Config.ObjectKey(pathname)
+}
+
+// listPrefix returns the S3 key prefix to list for the objects under a pathname prefix. Shards
+// spread a pathname prefix over the bucket, so sharded buckets are listed whole and callers
+// filter the listed pathnames.
+func (b *Bucket) listPrefix(prefix string) string {
+	if b.Config.Sharding != nil {
+		return b.GetFullPath("")
+	}
+	return b.GetFullPath(prefix)
+}
+
+// requireUnsharded rejects features that need all objects under a pathname prefix to share a key prefix
+func (b *Bucket) requireUnsharded(feature string) *S3Error {
+	if b.Config.Sharding == nil {
+		return nil
+	}
+	return NewInvalidRequestError(fmt.Sprintf("%s is not available on sharded bucket '%s'", feature, b.Name))
+}
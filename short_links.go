@@ -0,0 +1,607 @@
+package s3
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// shortLinkStateDir is the sub-directory of state_dir holding short links
+	shortLinkStateDir = "short_links"
+
+	// defaultShortLinkPath is the URL path prefix served by the HTTP middleware
+	defaultShortLinkPath = "/dl/"
+
+	// defaultShortLinkTTL is the validity of links created without expires_in
+	defaultShortLinkTTL = 24 * time.Hour
+
+	// defaultShortLinkMaxTTL bounds expires_in
+	defaultShortLinkMaxTTL = 30 * 24 * time.Hour
+)
+
+// ShortLinksConfig enables CreateShortLink and the HTTP middleware resolving <path><token>
+// to a streamed object, so end users see short links instead of presigned URLs
+type ShortLinksConfig struct {
+	// Path is the URL path prefix served by the middleware (default: "/dl/")
+	Path string `mapstructure:"path"`
+
+	// BaseURL is prepended to the path in returned links, e.g. "https://app.example.com" (optional)
+	BaseURL string `mapstructure:"base_url"`
+
+	// DefaultTTL is the validity of links created without expires_in (default: 24h)
+	DefaultTTL time.Duration `mapstructure:"default_ttl"`
+
+	// MaxTTL bounds expires_in (default: 720h)
+	MaxTTL time.Duration `mapstructure:"max_ttl"`
+}
+
+// Validate validates the short link configuration and applies defaults
+func (sc *ShortLinksConfig) Validate() error {
+	if sc.Path == "" {
+		sc.Path = defaultShortLinkPath
+	}
+	if !strings.HasPrefix(sc.Path, "/") || !strings.HasSuffix(sc.Path, "/") {
+		return fmt.Errorf("path must start and end with '/', got '%s'", sc.Path)
+	}
+	sc.BaseURL = strings.TrimSuffix(sc.BaseURL, "/")
+
+	if sc.DefaultTTL <= 0 {
+		sc.DefaultTTL = defaultShortLinkTTL
+	}
+	if sc.MaxTTL <= 0 {
+		sc.MaxTTL = defaultShortLinkMaxTTL
+	}
+	if sc.DefaultTTL > sc.MaxTTL {
+		return fmt.Errorf("default_ttl must not exceed max_ttl (%s)", sc.MaxTTL)
+	}
+
+	return nil
+}
+
+// ShortLinkManager keeps short links and persists them under state_dir
+type ShortLinkManager struct {
+	// Map of token to link
+	links map[string]*shortLink
+
+	// Directory for persisted links (empty disables persistence)
+	dir string
+
+	// Logger
+	log *zap.Logger
+
+	// Mutex for thread-safe access
+	mu sync.Mutex
+}
+
+// shortLink is the persisted mapping of a token to an object and its constraints
+type shortLink struct {
+	Token        string `json:"token"`
+	Bucket       string `json:"bucket"`
+	Pathname     string `json:"pathname"`
+	Filename     string `json:"filename,omitempty"`  // Offered download name (Content-Disposition)
+	SourceIP     string `json:"source_ip,omitempty"` // CIDR clients must come from
+	MaxDownloads int64  `json:"max_downloads,omitempty"`
+	Downloads    int64  `json:"downloads"`
+	CreatedAt    int64  `json:"created_at"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// NewShortLinkManager creates a new short link manager persisting links under stateDir
+func NewShortLinkManager(stateDir string, log *zap.Logger) *ShortLinkManager {
+	sm := &ShortLinkManager{
+		links: make(map[string]*shortLink),
+		log:   log,
+	}
+	if stateDir != "" {
+		sm.dir = filepath.Join(stateDir, shortLinkStateDir)
+	}
+	return sm
+}
+
+// Load restores links persisted by a previous plugin run, dropping expired ones
+func (sm *ShortLinkManager) Load() {
+	if sm.dir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(sm.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			sm.log.Warn("failed to read short links", zap.String("dir", sm.dir), zap.Error(err))
+		}
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := time.Now().Unix()
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(sm.dir, entry.Name()))
+		if err != nil {
+			sm.log.Warn("failed to read short link", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		link := &shortLink{}
+		if err := json.Unmarshal(data, link); err != nil {
+			sm.log.Warn("invalid short link", zap.String("file", entry.Name()), zap.Error(err))
+			continue
+		}
+
+		if link.ExpiresAt <= now {
+			sm.removeFileLocked(link.Token)
+			continue
+		}
+		sm.links[link.Token] = link
+	}
+
+	if len(sm.links) > 0 {
+		sm.log.Info("restored short links", zap.Int("count", len(sm.links)))
+	}
+}
+
+// add stores and persists a new link under a fresh token
+func (sm *ShortLinkManager) add(link *shortLink) error {
+	token, err := newShortLinkToken()
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.pruneLocked()
+
+	link.Token = token
+	sm.links[token] = link
+	return sm.persistLocked(link)
+}
+
+// get retrieves a link by token
+func (sm *ShortLinkManager) get(token string) (*shortLink, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	link, exists := sm.links[token]
+	return link, exists
+}
+
+// claim checks the constraints of the link of token for a client at ip. The returned status is
+// http.StatusOK or the status answering the request.
+func (sm *ShortLinkManager) claim(token string, ip net.IP) (shortLink, int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	link, exists := sm.links[token]
+	if !exists || link.ExpiresAt <= time.Now().Unix() {
+		return shortLink{}, http.StatusNotFound
+	}
+
+	if link.SourceIP != "" {
+		_, network, err := net.ParseCIDR(link.SourceIP)
+		if err != nil || ip == nil || !network.Contains(ip) {
+			return shortLink{}, http.StatusForbidden
+		}
+	}
+
+	if link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads {
+		return shortLink{}, http.StatusGone
+	}
+
+	return *link, http.StatusOK
+}
+
+// countDownload counts one download of the link of token. It re-checks the link, as concurrent
+// requests may have used up max_downloads since they were claimed.
+func (sm *ShortLinkManager) countDownload(token string) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	link, exists := sm.links[token]
+	if !exists || link.ExpiresAt <= time.Now().Unix() {
+		return http.StatusNotFound
+	}
+
+	if link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads {
+		return http.StatusGone
+	}
+
+	link.Downloads++
+	if err := sm.persistLocked(link); err != nil {
+		sm.log.Warn("failed to persist short link", zap.String("token", token), zap.Error(err))
+	}
+	return http.StatusOK
+}
+
+// remove deletes the link of token, reporting whether it existed
+func (sm *ShortLinkManager) remove(token string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, exists := sm.links[token]; !exists {
+		return false
+	}
+	delete(sm.links, token)
+	sm.removeFileLocked(token)
+	return true
+}
+
+// pruneLocked drops expired links; caller must hold sm.mu
+func (sm *ShortLinkManager) pruneLocked() {
+	now := time.Now().Unix()
+	for token, link := range sm.links {
+		if link.ExpiresAt <= now {
+			delete(sm.links, token)
+			sm.removeFileLocked(token)
+		}
+	}
+}
+
+// persistLocked writes the link to disk; caller must hold sm.mu
+func (sm *ShortLinkManager) persistLocked(link *shortLink) error {
+	if sm.dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(sm.dir, 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a crash never leaves a truncated file behind
+	tmp := filepath.Join(sm.dir, link.Token+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(sm.dir, link.Token+".json"))
+}
+
+// removeFileLocked deletes the persisted link; caller must hold sm.mu
+func (sm *ShortLinkManager) removeFileLocked(token string) {
+	if sm.dir == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(sm.dir, token+".json")); err != nil && !os.IsNotExist(err) {
+		sm.log.Warn("failed to remove short link", zap.String("token", token), zap.Error(err))
+	}
+}
+
+// newShortLinkToken returns a random URL-safe token of 96 bits
+func newShortLinkToken() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CreateShortLink stores a short link to an object, served by the HTTP middleware
+func (o *Operations) CreateShortLink(ctx context.Context, req *CreateShortLinkRequest, resp *CreateShortLinkResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	cfg := o.plugin.config.ShortLinks
+	if cfg == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("short links are not enabled, configure short_links")
+	}
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	if err := o.route(&req.Bucket, req.Pathname, "short_link_create"); err != nil {
+		return err
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "CreateShortLink", "short_link_create", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	ttl := time.Duration(req.ExpiresIn) * time.Second
+	if ttl == 0 {
+		ttl = cfg.DefaultTTL
+	}
+	if ttl < 0 || ttl > cfg.MaxTTL || req.MaxDownloads < 0 {
+		o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("expires_in must be between 0 and %d seconds and max_downloads must not be negative", int64(cfg.MaxTTL.Seconds())))
+	}
+
+	var sourceCIDR string
+	if req.SourceIP != "" {
+		sourceCIDR, err = parseSourceIP(req.SourceIP)
+		if err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return NewInvalidRequestError(err.Error())
+		}
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	exists, err := objectExists(ctx, bucket, bucket.ObjectKey(req.Pathname))
+	bucket.Release()
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("head object", err)
+	}
+	if !exists {
+		o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrFileNotFound)
+		return NewFileNotFoundError(req.Pathname)
+	}
+
+	now := time.Now()
+	link := &shortLink{
+		Bucket:       bucket.Name,
+		Pathname:     req.Pathname,
+		Filename:     req.Filename,
+		SourceIP:     sourceCIDR,
+		MaxDownloads: req.MaxDownloads,
+		CreatedAt:    now.Unix(),
+		ExpiresAt:    now.Add(ttl).Unix(),
+	}
+	if err := o.plugin.shortLinks.add(link); err != nil {
+		o.log.Error("failed to store short link",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInternal)
+		return NewS3Error(ErrInternal, "Failed to store short link", err.Error())
+	}
+
+	resp.Token = link.Token
+	resp.URL = cfg.BaseURL + cfg.Path + link.Token
+	resp.ExpiresAt = link.ExpiresAt
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "short_link_create", "success")
+	return nil
+}
+
+// DeleteShortLink revokes a short link
+func (o *Operations) DeleteShortLink(ctx context.Context, req *DeleteShortLinkRequest, resp *DeleteShortLinkResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	if o.plugin.shortLinks == nil {
+		return NewInvalidRequestError("short links are not enabled, configure short_links")
+	}
+
+	link, exists := o.plugin.shortLinks.get(req.Token)
+	if !exists {
+		return nil
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "DeleteShortLink", "short_link_delete", link.Bucket, link.Pathname); err != nil {
+		return err
+	}
+
+	resp.Success = o.plugin.shortLinks.remove(req.Token)
+	o.plugin.metrics.RecordOperation(link.Bucket, "short_link_delete", "success")
+	return nil
+}
+
+// Middleware serves short links under short_links.path when the plugin is listed in the
+// middleware of the RoadRunner HTTP plugin; other requests pass through
+func (p *Plugin) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.shortLinks == nil || !strings.HasPrefix(r.URL.Path, p.config.ShortLinks.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p.operations.serveShortLink(w, r, strings.TrimPrefix(r.URL.Path, p.config.ShortLinks.Path))
+	})
+}
+
+// serveShortLink streams the object of a short link, passing Range requests on to S3 unless the
+// link limits its downloads
+func (o *Operations) serveShortLink(w http.ResponseWriter, r *http.Request, token string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := o.plugin.TrackOperation(); err != nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer o.plugin.CompleteOperation()
+
+	link, status := o.plugin.shortLinks.claim(token, clientIP(r))
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(link.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(link.Bucket, "short_link", "error")
+		o.plugin.metrics.RecordError(link.Bucket, ErrBucketNotFound)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	ctx := r.Context()
+	if err := bucket.Acquire(ctx); err != nil {
+		o.plugin.metrics.RecordOperation(link.Bucket, "short_link", "error")
+		o.plugin.metrics.RecordError(link.Bucket, asS3Error("acquire bucket slot", err).Code)
+		// A client that went away gets no response
+		if ctx.Err() == nil {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		}
+		return
+	}
+	defer bucket.Release()
+
+	// Pointer objects of dedup buckets are read from their immutable blob
+	source := link.Pathname
+	if bucket.Config.Dedup {
+		source, err = o.dedupSource(ctx, bucket, link.Pathname)
+		if err != nil {
+			o.writeShortLinkError(w, link, err)
+			return
+		}
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.ObjectKey(source)),
+	}
+	// Ranges of a limited link would each be a partial download that cannot be told apart from a
+	// resumed one, so the whole object is always served and every GET counts
+	limited := link.MaxDownloads > 0
+	if rng := r.Header.Get("Range"); rng != "" && !limited {
+		input.Range = aws.String(rng)
+	}
+
+	result, err := bucket.Reader().GetObject(ctx, input)
+	if err != nil {
+		o.writeShortLinkError(w, link, err)
+		return
+	}
+	defer result.Body.Close()
+
+	// Every GET S3 answered counts; HEAD requests never count
+	contentRange := aws.ToString(result.ContentRange)
+	if r.Method == http.MethodGet {
+		if status := o.plugin.shortLinks.countDownload(token); status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", contentTypeOf(result.ContentType))
+	if limited {
+		header.Set("Accept-Ranges", "none")
+	} else {
+		header.Set("Accept-Ranges", "bytes")
+	}
+	if result.ContentLength != nil {
+		header.Set("Content-Length", strconv.FormatInt(*result.ContentLength, 10))
+	}
+	if etag := aws.ToString(result.ETag); etag != "" {
+		header.Set("ETag", etag)
+	}
+	if result.LastModified != nil {
+		header.Set("Last-Modified", result.LastModified.UTC().Format(http.TimeFormat))
+	}
+	filename := link.Filename
+	if filename == "" {
+		filename = path.Base(link.Pathname)
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	status = http.StatusOK
+	if contentRange != "" {
+		header.Set("Content-Range", contentRange)
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodHead {
+		o.plugin.metrics.RecordOperation(link.Bucket, "short_link", "success")
+		return
+	}
+
+	if _, err := io.Copy(w, result.Body); err != nil {
+		o.log.Debug("short link download interrupted",
+			zap.String("bucket", link.Bucket),
+			zap.String("pathname", link.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(link.Bucket, "short_link", "error")
+		return
+	}
+	o.plugin.metrics.RecordOperation(link.Bucket, "short_link", "success")
+}
+
+// writeShortLinkError answers a short link request S3 failed for
+func (o *Operations) writeShortLinkError(w http.ResponseWriter, link shortLink, err error) {
+	o.plugin.metrics.RecordOperation(link.Bucket, "short_link", "error")
+
+	var nsk *types.NoSuchKey
+	var nf *types.NotFound
+	if errors.As(err, &nsk) || errors.As(err, &nf) {
+		o.plugin.metrics.RecordError(link.Bucket, ErrFileNotFound)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		http.Error(w, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	o.log.Error("failed to serve short link",
+		zap.String("bucket", link.Bucket),
+		zap.String("pathname", link.Pathname),
+		zap.Error(err),
+	)
+	o.plugin.metrics.RecordError(link.Bucket, ErrS3Operation)
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
+
+// clientIP returns the address of the client of r; RoadRunner's proxy_ip_parser middleware
+// rewrites RemoteAddr for requests arriving through trusted proxies
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
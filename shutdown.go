@@ -0,0 +1,121 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultShutdownTimeout is how long in-flight operations may drain before they are aborted
+	defaultShutdownTimeout = 30 * time.Second
+
+	// abortUploadTimeout bounds a single AbortMultipartUpload issued after cancellation
+	abortUploadTimeout = 10 * time.Second
+)
+
+// shutdownReport collects multipart uploads aborted while the plugin stops
+type shutdownReport struct {
+	aborted []string
+	failed  []string
+	mu      sync.Mutex
+}
+
+func (sr *shutdownReport) record(bucket, key string, err error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if err != nil {
+		sr.failed = append(sr.failed, bucket+"/"+key)
+		return
+	}
+	sr.aborted = append(sr.aborted, bucket+"/"+key)
+}
+
+// log writes a summary of aborted uploads, if any
+func (sr *shutdownReport) log(log *zap.Logger) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if len(sr.aborted) == 0 && len(sr.failed) == 0 {
+		return
+	}
+
+	log.Warn("multipart uploads aborted on shutdown",
+		zap.Int("aborted", len(sr.aborted)),
+		zap.Strings("uploads", sr.aborted),
+		zap.Int("abort_failures", len(sr.failed)),
+		zap.Strings("failed_uploads", sr.failed),
+	)
+}
+
+// abortUploadDetached aborts an upload with a context detached from ctx's cancellation
+func (o *Operations) abortUploadDetached(ctx context.Context, bucket *Bucket, key, uploadID string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortUploadTimeout)
+	defer cancel()
+
+	_, err := bucket.Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket.Config.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		o.log.Warn("failed to abort multipart upload",
+			zap.String("bucket", bucket.Name),
+			zap.String("key", key),
+			zap.String("upload_id", uploadID),
+			zap.Error(err),
+		)
+	}
+
+	if o.plugin.isShuttingDown() {
+		o.plugin.shutdown.record(bucket.Name, key, err)
+	}
+	return err
+}
+
+// abortInterruptedUpload aborts the multipart upload behind a failed upload manager call when
+// ctx was cancelled (shutdown timeout, deadline_ms, cancelled job). The upload manager's own
+// abort uses the cancelled context and fails, which would leave billed parts behind.
+func (o *Operations) abortInterruptedUpload(ctx context.Context, bucket *Bucket, key string, err error) {
+	if ctx.Err() == nil {
+		return
+	}
+
+	var failure manager.MultiUploadFailure
+	if !errors.As(err, &failure) || failure.UploadID() == "" {
+		return
+	}
+
+	_ = o.abortUploadDetached(ctx, bucket, key, failure.UploadID())
+}
+
+// abortVolatileUploads aborts client-driven multipart uploads that are not persisted under
+// state_dir: they cannot be resumed after a restart, so their parts would only accrue cost
+func (o *Operations) abortVolatileUploads(ctx context.Context) {
+	if o.plugin.uploads.dir != "" {
+		return
+	}
+
+	for _, upload := range o.plugin.uploads.list() {
+		bucket, err := o.plugin.buckets.GetBucket(upload.Bucket)
+		if err != nil {
+			continue
+		}
+
+		if upload.resumable() {
+			err = o.abortResumableDetached(ctx, bucket, upload)
+		} else {
+			err = o.abortUploadDetached(ctx, bucket, upload.Key, upload.S3UploadID)
+		}
+		if err == nil {
+			o.plugin.uploads.remove(upload.ID)
+		}
+	}
+}
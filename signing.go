@@ -0,0 +1,136 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyauth "github.com/aws/smithy-go/auth"
+	smithyendpoints "github.com/aws/smithy-go/endpoints"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	// SignatureV4 signs requests with AWS Signature Version 4 (the SDK default)
+	SignatureV4 = "v4"
+
+	// SignatureNone sends requests unsigned, for gateways that authenticate by other means
+	SignatureNone = "none"
+)
+
+// SigningConfig overrides how requests to an S3-compatible server are signed
+type SigningConfig struct {
+	// Version is the signature version: "v4" (default) or "none" for anonymous requests
+	Version string `mapstructure:"version" json:"version,omitempty"`
+
+	// UnsignedPayload signs headers only and sends UNSIGNED-PAYLOAD instead of the body hash
+	UnsignedPayload bool `mapstructure:"unsigned_payload" json:"unsigned_payload,omitempty"`
+
+	// Region overrides the region used in the signature (defaults to the server region)
+	Region string `mapstructure:"region" json:"region,omitempty"`
+
+	// Name overrides the service name used in the signature (defaults to "s3")
+	Name string `mapstructure:"name" json:"name,omitempty"`
+}
+
+// Validate validates the signing configuration
+func (sc *SigningConfig) Validate() error {
+	switch sc.Version {
+	case "":
+		sc.Version = SignatureV4
+	case SignatureV4, SignatureNone:
+	case "v2":
+		return fmt.Errorf("signing.version 'v2' is not supported by the AWS SDK, use 'v4' or 'none'")
+	default:
+		return fmt.Errorf("signing.version must be '%s' or '%s', got '%s'", SignatureV4, SignatureNone, sc.Version)
+	}
+
+	if sc.Version == SignatureNone && (sc.UnsignedPayload || sc.Region != "" || sc.Name != "") {
+		return fmt.Errorf("signing.unsigned_payload, signing.region and signing.name require signing.version '%s'", SignatureV4)
+	}
+
+	return nil
+}
+
+// anonymous reports whether requests are sent without a signature
+func (sc *SigningConfig) anonymous() bool {
+	return sc != nil && sc.Version == SignatureNone
+}
+
+// apply configures an S3 client to sign requests as described by the configuration
+func (sc *SigningConfig) apply(o *s3.Options) {
+	if sc == nil {
+		return
+	}
+
+	if sc.anonymous() {
+		o.Credentials = aws.AnonymousCredentials{}
+		return
+	}
+
+	if sc.UnsignedPayload {
+		o.APIOptions = append(o.APIOptions, v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)
+	}
+
+	if sc.Region != "" || sc.Name != "" {
+		o.EndpointResolverV2 = &signingEndpointResolver{
+			EndpointResolverV2: o.EndpointResolverV2,
+			signing:            sc,
+		}
+	}
+}
+
+// signingEndpointResolver overrides the signing region and name of resolved endpoints;
+// the SDK takes them from the endpoint auth schemes, which win over any client option
+type signingEndpointResolver struct {
+	s3.EndpointResolverV2
+	signing *SigningConfig
+}
+
+// ResolveEndpoint resolves the endpoint and rewrites the signer properties of its auth schemes
+func (r *signingEndpointResolver) ResolveEndpoint(ctx context.Context, params s3.EndpointParameters) (smithyendpoints.Endpoint, error) {
+	endpoint, err := r.EndpointResolverV2.ResolveEndpoint(ctx, params)
+	if err != nil {
+		return endpoint, err
+	}
+
+	options, _ := smithyauth.GetAuthOptions(&endpoint.Properties)
+	for _, option := range options {
+		if r.signing.Region != "" {
+			smithyhttp.SetSigV4SigningRegion(&option.SignerProperties, r.signing.Region)
+			smithyhttp.SetSigV4ASigningRegions(&option.SignerProperties, []string{r.signing.Region})
+		}
+		if r.signing.Name != "" {
+			smithyhttp.SetSigV4SigningName(&option.SignerProperties, r.signing.Name)
+			smithyhttp.SetSigV4ASigningName(&option.SignerProperties, r.signing.Name)
+		}
+	}
+
+	return endpoint, nil
+}
+
+// readOnlyAPI reports whether an S3 API operation only reads data
+func readOnlyAPI(api string) bool {
+	return strings.HasPrefix(api, "Get") || strings.HasPrefix(api, "Head") ||
+		strings.HasPrefix(api, "List") || api == "SelectObjectContent"
+}
+
+// readOnlyMiddleware rejects requests that would modify a bucket mounted without credentials,
+// before they reach the network; it covers every path including copy and migration targets
+func readOnlyMiddleware(bucket string) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("S3ReadOnlyMount",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				if api := awsmiddleware.GetOperationName(ctx); !readOnlyAPI(api) {
+					return middleware.InitializeOutput{}, middleware.Metadata{},
+						fmt.Errorf("bucket '%s' is mounted read-only (anonymous server), %s is not allowed", bucket, api)
+				}
+				return next.HandleInitialize(ctx, in)
+			}), middleware.Before)
+	}
+}
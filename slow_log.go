@@ -0,0 +1,211 @@
+package s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// defaultSlowLogThreshold applies when neither slow_log.threshold nor slow_operation_threshold is set
+	defaultSlowLogThreshold = time.Second
+
+	// defaultSlowLogMaxPerSecond bounds the entries written per second
+	defaultSlowLogMaxPerSecond = 100
+)
+
+// SlowLogConfig writes operations over a threshold to a dedicated JSON log file, one entry per
+// operation with its key, sizes, connection phase timings and the AWS request ID
+type SlowLogConfig struct {
+	// Path of the log file; entries are appended (required)
+	Path string `mapstructure:"path" json:"path"`
+
+	// Threshold is the total duration at which an operation is logged
+	// (default: slow_operation_threshold, or 1s when that is unset)
+	Threshold time.Duration `mapstructure:"threshold" json:"threshold"`
+
+	// SampleRate is the fraction of slow operations written, 0 to 1 (default: 1)
+	SampleRate float64 `mapstructure:"sample_rate" json:"sample_rate"`
+
+	// MaxPerSecond caps the entries written per second; the rest are counted and reported
+	// in the next entry (default: 100)
+	MaxPerSecond int `mapstructure:"max_per_second" json:"max_per_second"`
+
+	// HashKeys logs a SHA-256 prefix of object keys instead of the keys themselves (default: false)
+	HashKeys bool `mapstructure:"hash_keys" json:"hash_keys"`
+}
+
+// Validate validates the slow log configuration and applies defaults
+func (sc *SlowLogConfig) Validate(slowOperationThreshold time.Duration) error {
+	if sc.Path == "" {
+		return fmt.Errorf("slow_log.path is required")
+	}
+
+	if sc.Threshold == 0 {
+		sc.Threshold = slowOperationThreshold
+	}
+	if sc.Threshold == 0 {
+		sc.Threshold = defaultSlowLogThreshold
+	}
+	if sc.Threshold < 0 {
+		return fmt.Errorf("slow_log.threshold must not be negative")
+	}
+
+	if sc.SampleRate == 0 {
+		sc.SampleRate = 1
+	}
+	if sc.SampleRate < 0 || sc.SampleRate > 1 {
+		return fmt.Errorf("slow_log.sample_rate must be between 0 and 1, got %g", sc.SampleRate)
+	}
+
+	if sc.MaxPerSecond == 0 {
+		sc.MaxPerSecond = defaultSlowLogMaxPerSecond
+	}
+	if sc.MaxPerSecond < 0 {
+		return fmt.Errorf("slow_log.max_per_second must not be negative")
+	}
+
+	return nil
+}
+
+// slowLog writes slow operations to their own file, bypassing the plugin logger
+type slowLog struct {
+	config *SlowLogConfig
+	log    *zap.Logger
+	file   *os.File
+
+	// Entries written in the current second and those dropped by the cap since the last entry
+	mu      sync.Mutex
+	second  int64
+	written int
+	dropped int
+}
+
+// newSlowLog opens the slow log file; a nil config disables the slow log
+func newSlowLog(sc *SlowLogConfig) (*slowLog, error) {
+	if sc == nil {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sc.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create slow log directory: %w", err)
+	}
+	file, err := os.OpenFile(sc.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open slow log: %w", err)
+	}
+
+	encoder := zap.NewProductionEncoderConfig()
+	encoder.TimeKey = "time"
+	encoder.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoder.EncodeDuration = zapcore.MillisDurationEncoder
+	encoder.LevelKey = ""
+	encoder.CallerKey = ""
+	encoder.StacktraceKey = ""
+	encoder.MessageKey = ""
+
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoder), zapcore.AddSync(file), zapcore.InfoLevel)
+
+	return &slowLog{config: sc, log: zap.New(core), file: file}, nil
+}
+
+// admit applies sampling and the per-second cap, returning whether to write an entry and
+// how many capped entries precede it
+func (l *slowLog) admit() (bool, int) {
+	if l.config.SampleRate < 1 && rand.Float64() >= l.config.SampleRate {
+		return false, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now := time.Now().Unix(); now != l.second {
+		l.second, l.written = now, 0
+	}
+	if l.written >= l.config.MaxPerSecond {
+		l.dropped++
+		return false, 0
+	}
+
+	l.written++
+	dropped := l.dropped
+	l.dropped = 0
+	return true, dropped
+}
+
+// write logs the operation when it reached the threshold and passes sampling
+func (l *slowLog) write(operation string, caller *CallerMetadata, stats *operationStats, duration time.Duration, err error) {
+	if l == nil || duration < l.config.Threshold {
+		return
+	}
+
+	ok, dropped := l.admit()
+	if !ok {
+		return
+	}
+
+	stats.mu.Lock()
+	fields := []zap.Field{
+		zap.String("operation", operation),
+		zap.String("bucket", stats.bucket),
+		zap.Duration("duration_ms", duration),
+		zap.Duration("queue_wait_ms", stats.queueWait),
+		zap.Duration("s3_time_ms", duration-stats.queueWait),
+		zap.Int("requests", stats.requests),
+		zap.Int64("bytes_sent", stats.sent),
+		zap.Int64("bytes_received", stats.received),
+	}
+	if stats.key != "" {
+		if l.config.HashKeys {
+			sum := sha256.Sum256([]byte(stats.key))
+			fields = append(fields, zap.String("key_hash", hex.EncodeToString(sum[:8])))
+		} else {
+			fields = append(fields, zap.String("key", stats.key))
+		}
+	}
+	for _, phase := range []string{PhaseDNS, PhaseConnect, PhaseTLS, PhaseTTFB} {
+		if d, ok := stats.phases[phase]; ok {
+			fields = append(fields, zap.Duration(phase+"_ms", d))
+		}
+	}
+	if stats.requestID != "" {
+		fields = append(fields, zap.String("request_id", stats.requestID))
+	}
+	stats.mu.Unlock()
+
+	status := "success"
+	if err != nil {
+		status = "error"
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) {
+			fields = append(fields, zap.String("error_code", string(s3Err.Code)))
+		}
+	}
+	fields = append(fields, zap.String("status", status))
+
+	fields = append(fields, caller.fields()...)
+
+	if dropped > 0 {
+		fields = append(fields, zap.Int("dropped_before", dropped))
+	}
+
+	l.log.Info("", fields...)
+}
+
+// close flushes and closes the slow log file
+func (l *slowLog) close() error {
+	if l == nil {
+		return nil
+	}
+	_ = l.log.Sync()
+	return l.file.Close()
+}
@@ -0,0 +1,190 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	"go.uber.org/zap"
+)
+
+// operationStatsKey is the context key carrying per-operation timing
+type operationStatsKey struct{}
+
+// operationStats accumulates timing of a single RPC operation
+type operationStats struct {
+	// Bucket the operation waited on last
+	bucket string
+
+	// Total time spent waiting for concurrency slots
+	queueWait time.Duration
+
+	// Object key and AWS request ID of the last S3 request
+	key       string
+	requestID string
+
+	// Number of S3 requests, bytes sent and received across them
+	requests int
+	sent     int64
+	received int64
+
+	// Connection phase durations summed across requests
+	phases map[string]time.Duration
+
+	mu sync.Mutex
+}
+
+// withOperationStats attaches fresh operation stats to ctx
+func withOperationStats(ctx context.Context) (context.Context, *operationStats) {
+	stats := &operationStats{}
+	return context.WithValue(ctx, operationStatsKey{}, stats), stats
+}
+
+// operationStatsFrom returns the stats attached to ctx, or nil
+func operationStatsFrom(ctx context.Context) *operationStats {
+	stats, _ := ctx.Value(operationStatsKey{}).(*operationStats)
+	return stats
+}
+
+// addQueueWait records time spent waiting for a slot of the given bucket
+func (s *operationStats) addQueueWait(bucket string, wait time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bucket = bucket
+	s.queueWait += wait
+}
+
+// addRequest records an S3 request of the operation
+func (s *operationStats) addRequest(key, requestID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if key != "" {
+		s.key = key
+	}
+	if requestID != "" {
+		s.requestID = requestID
+	}
+}
+
+// addTransfer records the body sizes of an HTTP exchange; unknown sizes are negative
+func (s *operationStats) addTransfer(sent, received int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent += max(sent, 0)
+	s.received += max(received, 0)
+}
+
+// addPhase records the duration of a connection phase
+func (s *operationStats) addPhase(phase string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.phases == nil {
+		s.phases = make(map[string]time.Duration)
+	}
+	s.phases[phase] += d
+}
+
+// snapshot returns the bucket and accumulated queue wait
+func (s *operationStats) snapshot() (string, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bucket, s.queueWait
+}
+
+// operationStatsMiddleware attributes each S3 request to the operation tracked in its context,
+// with the object key and AWS request ID for the slow log
+func operationStatsMiddleware() func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("S3OperationStats",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleInitialize(ctx, in)
+
+				if stats := operationStatsFrom(ctx); stats != nil {
+					requestID, _ := awsmiddleware.GetRequestIDMetadata(metadata)
+					var respErr *awshttp.ResponseError
+					if requestID == "" && errors.As(err, &respErr) {
+						requestID = respErr.ServiceRequestID()
+					}
+					stats.addRequest(requestKey(in.Parameters), requestID)
+				}
+
+				return out, metadata, err
+			}), middleware.After)
+	}
+}
+
+// requestKey returns the object key, or listing prefix, of an S3 request
+func requestKey(params any) string {
+	switch p := params.(type) {
+	case *s3.GetObjectInput:
+		return aws.ToString(p.Key)
+	case *s3.HeadObjectInput:
+		return aws.ToString(p.Key)
+	case *s3.PutObjectInput:
+		return aws.ToString(p.Key)
+	case *s3.DeleteObjectInput:
+		return aws.ToString(p.Key)
+	case *s3.CopyObjectInput:
+		return aws.ToString(p.Key)
+	case *s3.CreateMultipartUploadInput:
+		return aws.ToString(p.Key)
+	case *s3.UploadPartInput:
+		return aws.ToString(p.Key)
+	case *s3.UploadPartCopyInput:
+		return aws.ToString(p.Key)
+	case *s3.CompleteMultipartUploadInput:
+		return aws.ToString(p.Key)
+	case *s3.AbortMultipartUploadInput:
+		return aws.ToString(p.Key)
+	case *s3.PutObjectAclInput:
+		return aws.ToString(p.Key)
+	case *s3.GetObjectTaggingInput:
+		return aws.ToString(p.Key)
+	case *s3.PutObjectTaggingInput:
+		return aws.ToString(p.Key)
+	case *s3.ListObjectsV2Input:
+		return aws.ToString(p.Prefix)
+	default:
+		return ""
+	}
+}
+
+// reportSlowOperation logs and counts an operation whose queue wait or total duration
+// reached the configured slow_operation_threshold, and writes it to the slow log when it
+// reached slow_log.threshold
+func (p *Plugin) reportSlowOperation(operation string, caller *CallerMetadata, stats *operationStats, duration time.Duration, err error) {
+	p.slowLog.write(operation, caller, stats, duration, err)
+
+	threshold := p.config.SlowOperationThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	bucket, queueWait := stats.snapshot()
+	if duration < threshold && queueWait < threshold {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("operation", operation),
+		zap.String("bucket", bucket),
+		zap.Duration("duration", duration),
+		zap.Duration("queue_wait", queueWait),
+		zap.Duration("s3_time", duration-queueWait),
+		zap.Duration("threshold", threshold),
+	}
+	p.log.Warn("slow operation", append(fields, caller.fields()...)...)
+
+	p.metrics.RecordSlowOperation(bucket, operation)
+}
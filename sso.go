@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+// SSOConfig obtains temporary credentials through AWS IAM Identity Center (SSO),
+// using the token cached by `aws sso login` in ~/.aws/sso/cache
+type SSOConfig struct {
+	// StartURL is the AWS access portal URL (e.g., "https://my-sso-portal.awsapps.com/start")
+	StartURL string `mapstructure:"start_url"`
+
+	// Region is the IAM Identity Center region (defaults to the server region)
+	Region string `mapstructure:"region"`
+
+	// AccountID is the AWS account to access
+	AccountID string `mapstructure:"account_id"`
+
+	// RoleName is the permission set role assumed in the account
+	RoleName string `mapstructure:"role_name"`
+
+	// SessionName is the sso-session used with `aws sso login --sso-session`. With it, expired
+	// access tokens are refreshed automatically; without it, the legacy token keyed by start URL
+	// is used until it expires.
+	SessionName string `mapstructure:"session_name"`
+}
+
+// Validate validates the SSO configuration
+func (sc *SSOConfig) Validate() error {
+	if sc.StartURL == "" {
+		return fmt.Errorf("sso.start_url is required")
+	}
+
+	if sc.AccountID == "" {
+		return fmt.Errorf("sso.account_id is required")
+	}
+
+	if sc.RoleName == "" {
+		return fmt.Errorf("sso.role_name is required")
+	}
+
+	return nil
+}
+
+// provider returns a cached credentials provider exchanging the SSO access token for role credentials
+func (sc *SSOConfig) provider(awsCfg aws.Config) (aws.CredentialsProvider, error) {
+	ssoCfg := awsCfg.Copy()
+	if sc.Region != "" {
+		ssoCfg.Region = sc.Region
+	}
+
+	var opts []func(*ssocreds.Options)
+	if sc.SessionName != "" {
+		tokenPath, err := ssocreds.StandardCachedTokenFilepath(sc.SessionName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate sso token cache: %w", err)
+		}
+
+		opts = append(opts, func(o *ssocreds.Options) {
+			o.SSOTokenProvider = ssocreds.NewSSOTokenProvider(ssooidc.NewFromConfig(ssoCfg), tokenPath)
+		})
+	}
+
+	provider := ssocreds.New(sso.NewFromConfig(ssoCfg), sc.AccountID, sc.RoleName, sc.StartURL, opts...)
+
+	// The cache refreshes credentials shortly before they expire and serializes token refreshes
+	return aws.NewCredentialsCache(provider), nil
+}
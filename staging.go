@@ -0,0 +1,240 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// stagedPathnameMetadata is the user metadata key of a staged write holding its escaped final pathname
+	stagedPathnameMetadata = "staged-pathname"
+
+	// stagedVisibilityMetadata is the user metadata key of a staged write holding its requested visibility
+	stagedVisibilityMetadata = "staged-visibility"
+)
+
+// StageWrite uploads content to the temporary area bound to its final pathname; CommitWrite moves it
+// into place and AbortWrite discards it. Staged writes never committed are removed by the lifecycle rule.
+func (o *Operations) StageWrite(ctx context.Context, req *StageWriteRequest, resp *StageWriteResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "stage_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "stage_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "StageWrite", "stage_write", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	tc := bucket.Config.Temporary
+	if tc == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "stage_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("bucket '%s' does not have temporary uploads enabled", req.Bucket))
+	}
+	if strings.HasPrefix(req.Pathname, tc.Prefix) {
+		o.plugin.metrics.RecordOperation(req.Bucket, "stage_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return NewInvalidPathnameError(req.Pathname, "pathname is inside the temporary upload prefix")
+	}
+
+	// Reject early what the commit would write anyway
+	if err := o.enforcePolicy(ctx, bucket, "stage_write", req.Pathname, int64(len(req.Content))); err != nil {
+		return err
+	}
+
+	metadata, err := bucket.Config.encodeMetadata(req.Config)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "stage_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+	if metadata == nil {
+		metadata = make(map[string]string, 2)
+	}
+	// Metadata travels in HTTP headers, which only carry ASCII
+	metadata[stagedPathnameMetadata] = escapeKeyPath(req.Pathname)
+	if req.Visibility != "" {
+		metadata[stagedVisibilityMetadata] = req.Visibility
+	}
+
+	// Acquire semaphore
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	token, sum, err := o.storeTemporary(ctx, bucket, req.Content, o.detectContentType(req.Pathname, req.Content), metadata)
+	if err != nil {
+		o.log.Error("failed to stage write",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "stage_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("upload", err)
+	}
+
+	resp.Token = token
+	resp.Pathname = req.Pathname
+	resp.Size = int64(len(req.Content))
+	resp.Checksum = sum
+	resp.ExpiresAt = time.Now().Add(tc.TTL).Unix()
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "stage_write", "success")
+
+	o.log.Debug("write staged",
+		zap.String("bucket", req.Bucket),
+		zap.String("pathname", req.Pathname),
+		zap.String("token", token),
+	)
+
+	return nil
+}
+
+// CommitWrite moves a staged write to the pathname it was staged for
+func (o *Operations) CommitWrite(ctx context.Context, req *StagedWriteRequest, resp *PromoteResponse) error {
+	pathname, visibility, err := o.stagedWrite(ctx, req, "commit_write")
+	if err != nil {
+		return err
+	}
+
+	// Check access; the promotion below runs pre-authorized
+	if err := o.authorizeIn(ctx, req.Caller, "CommitWrite", "commit_write", req.Bucket, pathname); err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, authorizedKey{}, true)
+
+	return o.promote(ctx, &PromoteRequest{
+		Bucket:     req.Bucket,
+		Token:      req.Token,
+		Pathname:   pathname,
+		Visibility: visibility,
+	}, resp, true)
+}
+
+// AbortWrite deletes a staged write; aborting an unknown or already removed token is not an error
+func (o *Operations) AbortWrite(ctx context.Context, req *StagedWriteRequest, resp *AbortWriteResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	pathname, _, err := o.stagedWrite(ctx, req, "abort_write")
+	if err != nil {
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) && s3Err.Code == ErrUploadNotFound {
+			return nil
+		}
+		return err
+	}
+
+	// Check access
+	if err := o.authorizeIn(ctx, req.Caller, "AbortWrite", "abort_write", req.Bucket, pathname); err != nil {
+		return err
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "abort_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Acquire semaphore
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	if _, err := bucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.GetFullPath(bucket.Config.Temporary.Prefix + req.Token)),
+	}); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "abort_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("delete", err)
+	}
+
+	resp.Aborted = true
+	resp.Pathname = pathname
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "abort_write", "success")
+	return nil
+}
+
+// stagedWrite returns the final pathname and visibility a token was staged for
+func (o *Operations) stagedWrite(ctx context.Context, req *StagedWriteRequest, metricOp string) (string, string, error) {
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, metricOp, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return "", "", NewBucketNotFoundError(req.Bucket)
+	}
+
+	if bucket.Config.Temporary == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, metricOp, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return "", "", NewInvalidRequestError(fmt.Sprintf("bucket '%s' does not have temporary uploads enabled", req.Bucket))
+	}
+	if !isTemporaryToken(req.Token) {
+		o.plugin.metrics.RecordOperation(req.Bucket, metricOp, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return "", "", NewInvalidRequestError("token must be a token returned by StageWrite")
+	}
+
+	if err := bucket.Acquire(ctx); err != nil {
+		return "", "", err
+	}
+	head, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.GetFullPath(bucket.Config.Temporary.Prefix + req.Token)),
+	})
+	bucket.Release()
+	if err != nil {
+		var nsk *types.NoSuchKey
+		var nf *types.NotFound
+		if errors.As(err, &nsk) || errors.As(err, &nf) {
+			o.plugin.metrics.RecordOperation(req.Bucket, metricOp, "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrUploadNotFound)
+			return "", "", NewUploadNotFoundError(req.Token)
+		}
+		o.plugin.metrics.RecordOperation(req.Bucket, metricOp, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return "", "", NewS3OperationError("head staged write", err)
+	}
+
+	escaped, staged := head.Metadata[stagedPathnameMetadata]
+	pathname, err := url.PathUnescape(escaped)
+	if !staged || err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, metricOp, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return "", "", NewInvalidRequestError("token belongs to a temporary upload, use Promote")
+	}
+
+	return pathname, head.Metadata[stagedVisibilityMetadata], nil
+}
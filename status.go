@@ -0,0 +1,221 @@
+package s3
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/api/v4/plugins/v1/status"
+)
+
+const (
+	// healthSlots is the number of slots covering the health window
+	healthSlots = 6
+
+	// healthSlot is the duration of one health slot; the window spans healthSlots of them
+	healthSlot = 10 * time.Second
+
+	// healthErrorRate is the share of operations failing with storage errors in the window
+	// from which a bucket is reported unhealthy
+	healthErrorRate = 0.5
+
+	// healthMinOperations is the minimum number of operations in the window before the error rate applies
+	healthMinOperations = 5
+)
+
+// healthTracker counts the outcomes of recent operations per registered bucket
+type healthTracker struct {
+	buckets map[string]*bucketHealth
+	mu      sync.RWMutex
+}
+
+// bucketHealth holds the latest outcome timestamps of a bucket and its operation counts over the window
+type bucketHealth struct {
+	lastSuccess   time.Time
+	lastError     time.Time
+	lastErrorCode ErrorCode
+
+	slots [healthSlots]healthCount
+}
+
+// healthCount counts operations and storage errors within one health slot
+type healthCount struct {
+	index  int64
+	ops    int64
+	errors int64
+}
+
+// newHealthTracker creates an empty health tracker
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		buckets: make(map[string]*bucketHealth),
+	}
+}
+
+// recordOperation records the outcome of an operation
+func (ht *healthTracker) recordOperation(bucket, result string) {
+	if ht == nil {
+		return
+	}
+
+	now := time.Now()
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+	h := ht.getLocked(bucket)
+	h.slot(now).ops++
+	if result == "success" {
+		h.lastSuccess = now
+	}
+}
+
+// recordError records the latest error of a bucket
+func (ht *healthTracker) recordError(bucket string, code ErrorCode) {
+	if ht == nil {
+		return
+	}
+
+	now := time.Now()
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+	h := ht.getLocked(bucket)
+	h.lastError = now
+	h.lastErrorCode = code
+	// Client-side errors (validation, not found, access) do not degrade a bucket
+	if code == ErrS3Operation {
+		h.slot(now).errors++
+	}
+}
+
+// get returns a copy of the bucket health
+func (ht *healthTracker) get(bucket string) bucketHealth {
+	ht.mu.RLock()
+	defer ht.mu.RUnlock()
+
+	if h, exists := ht.buckets[bucket]; exists {
+		return *h
+	}
+	return bucketHealth{}
+}
+
+func (ht *healthTracker) getLocked(bucket string) *bucketHealth {
+	h, exists := ht.buckets[bucket]
+	if !exists {
+		h = &bucketHealth{}
+		ht.buckets[bucket] = h
+	}
+	return h
+}
+
+// healthIndex returns the index of the health slot holding t
+func healthIndex(t time.Time) int64 {
+	return t.UnixNano() / int64(healthSlot)
+}
+
+// slot returns the counts of the slot holding now, resetting it when it held an older slot
+func (h *bucketHealth) slot(now time.Time) *healthCount {
+	index := healthIndex(now)
+	slot := &h.slots[index%healthSlots]
+	if slot.index != index {
+		*slot = healthCount{index: index}
+	}
+	return slot
+}
+
+// errorRate returns the operations in the window ending at now and the share that failed with storage errors
+func (h bucketHealth) errorRate(now time.Time) (int64, float64) {
+	current := healthIndex(now)
+	var ops, errs int64
+	for _, slot := range h.slots {
+		if slot.index > current-healthSlots && slot.index <= current {
+			ops += slot.ops
+			errs += slot.errors
+		}
+	}
+	if ops == 0 {
+		return 0, 0
+	}
+	return ops, float64(errs) / float64(ops)
+}
+
+// degraded reports whether storage errors reached healthErrorRate of the operations in the window.
+// A bucket without recent operations is not degraded.
+func (h bucketHealth) degraded(now time.Time) bool {
+	ops, rate := h.errorRate(now)
+	return ops >= healthMinOperations && rate >= healthErrorRate
+}
+
+// Status implements the RoadRunner status Checker: the plugin is alive while it is not stopping
+func (p *Plugin) Status() (*status.Status, error) {
+	if p.ctx == nil || p.ctx.Err() != nil {
+		return &status.Status{Code: http.StatusServiceUnavailable}, nil
+	}
+
+	return &status.Status{Code: http.StatusOK}, nil
+}
+
+// Ready implements the RoadRunner status Readiness: ready unless stopping or a bucket is degraded
+func (p *Plugin) Ready() (*status.Status, error) {
+	if p.ctx == nil || p.ctx.Err() != nil {
+		return &status.Status{Code: http.StatusServiceUnavailable}, nil
+	}
+
+	for _, bucket := range p.bucketStatuses() {
+		if !bucket.Healthy {
+			return &status.Status{Code: http.StatusServiceUnavailable}, nil
+		}
+	}
+
+	return &status.Status{Code: http.StatusOK}, nil
+}
+
+// bucketStatuses builds the per-bucket status detail, ordered by bucket name
+func (p *Plugin) bucketStatuses() []BucketStatus {
+	names := p.buckets.ListBuckets()
+	sort.Strings(names)
+
+	now := time.Now()
+	statuses := make([]BucketStatus, 0, len(names))
+	for _, name := range names {
+		bucket, err := p.buckets.GetBucket(name)
+		if err != nil {
+			// Removed concurrently
+			continue
+		}
+
+		h := p.health.get(name)
+		s := BucketStatus{
+			Name:          name,
+			Bucket:        bucket.Config.Bucket,
+			Healthy:       !h.degraded(now),
+			InFlight:      bucket.InFlight(),
+			Queued:        bucket.Queued(),
+			RetryTokens:   bucket.retries.Remaining(),
+			MaxConcurrent: bucket.Config.MaxConcurrentOperations,
+			LastErrorCode: string(h.lastErrorCode),
+			Capabilities:  bucket.Capabilities,
+			PayloadSizes:  bucket.payloads.snapshot(),
+		}
+		if !h.lastError.IsZero() {
+			s.LastErrorAt = h.lastError.Unix()
+		}
+		if !h.lastSuccess.IsZero() {
+			s.LastSuccessAt = h.lastSuccess.Unix()
+		}
+		if wp := bucket.Config.WritePolicy; wp != nil && wp.Quota > 0 {
+			used, measuredAt, threshold := bucket.usage.snapshot()
+			s.Quota = &QuotaStatus{
+				Quota:     wp.Quota,
+				Used:      used,
+				Ratio:     float64(used) / float64(wp.Quota),
+				Threshold: threshold,
+			}
+			if !measuredAt.IsZero() {
+				s.Quota.MeasuredAt = measuredAt.Unix()
+			}
+		}
+		statuses = append(statuses, s)
+	}
+
+	return statuses
+}
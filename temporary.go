@@ -0,0 +1,363 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultTemporaryPrefix is the bucket-relative prefix holding temporary uploads
+	defaultTemporaryPrefix = ".tmp/"
+
+	// defaultTemporaryTTL is how long a temporary upload can be promoted
+	defaultTemporaryTTL = 24 * time.Hour
+
+	// temporaryHashMetadata is the user metadata key of a temporary upload holding its sha256
+	temporaryHashMetadata = "temporary-sha256"
+)
+
+// TemporaryConfig enables WriteTemporary/Promote and StageWrite/CommitWrite/AbortWrite. Temporary
+// uploads live under Prefix and are deleted by a lifecycle rule the plugin installs at registration
+// when they are never promoted.
+type TemporaryConfig struct {
+	// Prefix is the bucket-relative prefix of temporary uploads (default: ".tmp/")
+	Prefix string `mapstructure:"prefix" json:"prefix"`
+
+	// TTL is how long an upload can be promoted; the lifecycle rule removes it
+	// within a day after TTL rounded up to whole days (default: 24h)
+	TTL time.Duration `mapstructure:"ttl" json:"ttl"`
+}
+
+// Validate validates the temporary upload configuration and applies defaults
+func (tc *TemporaryConfig) Validate() error {
+	if tc.Prefix == "" {
+		tc.Prefix = defaultTemporaryPrefix
+	}
+	if !strings.HasSuffix(tc.Prefix, "/") || strings.HasPrefix(tc.Prefix, "/") {
+		return fmt.Errorf("temporary.prefix must be relative and end with '/', got '%s'", tc.Prefix)
+	}
+
+	if tc.TTL == 0 {
+		tc.TTL = defaultTemporaryTTL
+	}
+	if tc.TTL < 0 {
+		return fmt.Errorf("temporary.ttl must be positive")
+	}
+
+	return nil
+}
+
+// lifecycleDays returns the lifecycle expiration period covering the TTL
+func (tc *TemporaryConfig) lifecycleDays() int32 {
+	return int32(math.Ceil(tc.TTL.Hours() / 24))
+}
+
+// checkTemporaryPathname rejects pathnames inside the temporary upload prefix, whose objects are
+// only written by WriteTemporary and StageWrite and expire with the lifecycle rule
+func (b *Bucket) checkTemporaryPathname(pathname string) *S3Error {
+	if tc := b.Config.Temporary; tc != nil && strings.HasPrefix(pathname, tc.Prefix) {
+		return NewInvalidPathnameError(pathname, "pathname is inside the temporary upload prefix")
+	}
+	return nil
+}
+
+// isTemporaryToken reports whether token has the shape of an id returned by WriteTemporary
+func isTemporaryToken(token string) bool {
+	if len(token) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// WriteTemporary stores content under the temporary prefix and returns a token for Promote
+func (o *Operations) WriteTemporary(ctx context.Context, req *WriteTemporaryRequest, resp *WriteTemporaryResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_temporary", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "WriteTemporary", "write_temporary", bucket.Name); err != nil {
+		return err
+	}
+
+	tc := bucket.Config.Temporary
+	if tc == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_temporary", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("bucket '%s' does not have temporary uploads enabled", req.Bucket))
+	}
+
+	metadata, err := bucket.Config.encodeMetadata(req.Config)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_temporary", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+	req.Config = metadata
+
+	// Acquire semaphore
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	token, sum, err := o.storeTemporary(ctx, bucket, req.Content, o.detectContentType(req.Filename, req.Content), req.Config)
+	if err != nil {
+		o.log.Error("failed to write temporary upload",
+			zap.String("bucket", req.Bucket),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_temporary", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("upload", err)
+	}
+
+	resp.Token = token
+	resp.Size = int64(len(req.Content))
+	resp.Checksum = sum
+	resp.ExpiresAt = time.Now().Add(tc.TTL).Unix()
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "write_temporary", "success")
+
+	o.log.Debug("temporary upload stored",
+		zap.String("bucket", req.Bucket),
+		zap.String("token", token),
+		zap.Int64("size", resp.Size),
+	)
+
+	return nil
+}
+
+// storeTemporary uploads content under a new token in the temporary prefix, recording its sha256
+// next to metadata. The caller holds the bucket semaphore.
+func (o *Operations) storeTemporary(ctx context.Context, bucket *Bucket, content []byte, contentType string, metadata map[string]string) (string, string, error) {
+	token, err := newRandomID()
+	if err != nil {
+		return "", "", err
+	}
+
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+
+	stored := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		stored[k] = v
+	}
+	stored[temporaryHashMetadata] = sum
+
+	uploader := bucket.NewUploader(int64(len(content)))
+	key := bucket.GetFullPath(bucket.Config.Temporary.Prefix + token)
+	if _, err := uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket.Config.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String(contentType),
+		Metadata:    stored,
+	}); err != nil {
+		o.abortInterruptedUpload(ctx, bucket, key, err)
+		return "", "", err
+	}
+
+	return token, sum, nil
+}
+
+// Promote moves a temporary upload to its final pathname after verifying its checksum
+func (o *Operations) Promote(ctx context.Context, req *PromoteRequest, resp *PromoteResponse) error {
+	return o.promote(ctx, req, resp, false)
+}
+
+// promote copies a temporary upload or, when staged is set, a staged write to req.Pathname
+func (o *Operations) promote(ctx context.Context, req *PromoteRequest, resp *PromoteResponse, staged bool) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	op := "promote"
+	if staged {
+		op = "commit_write"
+	}
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "Promote", op, bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	// The copy would replace the target without extending the audit chain
+	if err := bucket.requireAppendOnly("Promote"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+	if err := bucket.checkBlobPathname(req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	tc := bucket.Config.Temporary
+	if tc == nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("bucket '%s' does not have temporary uploads enabled", req.Bucket))
+	}
+	if !isTemporaryToken(req.Token) {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("token must be a token returned by WriteTemporary")
+	}
+	if strings.HasPrefix(req.Pathname, tc.Prefix) {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return NewInvalidPathnameError(req.Pathname, "pathname is inside the temporary upload prefix")
+	}
+
+	tempKey := bucket.GetFullPath(tc.Prefix + req.Token)
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	head, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(tempKey),
+	})
+	bucket.Release()
+	if err != nil {
+		var nsk *types.NoSuchKey
+		var nf *types.NotFound
+		if errors.As(err, &nsk) || errors.As(err, &nf) {
+			o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrUploadNotFound)
+			return NewUploadNotFoundError(req.Token)
+		}
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("head temporary upload", err)
+	}
+
+	// The lifecycle rule runs daily, so uploads past their TTL may still exist
+	if time.Since(aws.ToTime(head.LastModified)) > tc.TTL {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrUploadNotFound)
+		return NewUploadNotFoundError(req.Token)
+	}
+
+	// Staged writes are bound to their pathname
+	if _, isStaged := head.Metadata[stagedPathnameMetadata]; isStaged != staged {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("token belongs to a staged write, use CommitWrite")
+	}
+
+	if req.Checksum != "" && !strings.EqualFold(req.Checksum, head.Metadata[temporaryHashMetadata]) {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrChecksumMismatch)
+		return NewChecksumMismatchError(req.Pathname)
+	}
+
+	// The policy applies to the final pathname, and quota usage may have grown since the upload
+	if err := o.enforcePolicy(ctx, bucket, op, req.Pathname, aws.ToInt64(head.ContentLength)); err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string, len(head.Metadata))
+	for k, v := range head.Metadata {
+		if k != temporaryHashMetadata && k != stagedPathnameMetadata && k != stagedVisibilityMetadata {
+			metadata[k] = v
+		}
+	}
+
+	// Acquire semaphore
+	if err := bucket.Acquire(ctx); err != nil {
+		return err
+	}
+	defer bucket.Release()
+
+	// A single CopyObject makes the final object appear at once, with exactly the verified content
+	destKey := bucket.ObjectKey(req.Pathname)
+	if _, err := bucket.Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket.Config.Bucket),
+		Key:               aws.String(destKey),
+		CopySource:        aws.String(buildCopySource(bucket.Config.Bucket, tempKey, bucket.ServerConfig.CopySourceEncoding)),
+		CopySourceIfMatch: head.ETag,
+		ACL:               bucket.ObjectACL(req.Visibility),
+		ContentType:       head.ContentType,
+		Metadata:          metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	}); err != nil {
+		o.log.Error("failed to promote temporary upload",
+			zap.String("bucket", req.Bucket),
+			zap.String("token", req.Token),
+			zap.String("pathname", req.Pathname),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("copy", err)
+	}
+
+	bucket.filter.add(destKey)
+	o.invalidateDerived(ctx, bucket, req.Pathname)
+	o.plugin.index.update(bucket, req.Pathname)
+
+	// A leftover temporary object is harmless, the lifecycle rule removes it
+	if _, err := bucket.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(tempKey),
+	}); err != nil {
+		o.log.Warn("failed to delete promoted temporary upload",
+			zap.String("bucket", req.Bucket),
+			zap.String("token", req.Token),
+			zap.Error(err),
+		)
+	}
+
+	resp.Success = true
+	resp.Pathname = req.Pathname
+	resp.Size = aws.ToInt64(head.ContentLength)
+	resp.LastModified = time.Now().Unix()
+
+	o.plugin.metrics.RecordOperation(req.Bucket, op, "success")
+
+	o.log.Debug("temporary upload promoted",
+		zap.String("bucket", req.Bucket),
+		zap.String("token", req.Token),
+		zap.String("pathname", req.Pathname),
+	)
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxTransactionObjects bounds the number of objects written by one WriteTransaction
+	maxTransactionObjects = 100
+
+	// transactionRollbackTimeout bounds the rollback, which outlives a cancelled request
+	transactionRollbackTimeout = 30 * time.Second
+)
+
+const (
+	// TransactionWritten marks an object stored by a committed transaction
+	TransactionWritten = "written"
+
+	// TransactionFailed marks the object whose write aborted the transaction
+	TransactionFailed = "failed"
+
+	// TransactionRolledBack marks an object deleted again after a failure
+	TransactionRolledBack = "rolled_back"
+
+	// TransactionRollbackFailed marks an object that could not be deleted after a failure
+	TransactionRollbackFailed = "rollback_failed"
+
+	// TransactionOverwritten marks a replaced object; its previous content cannot be restored
+	TransactionOverwritten = "overwritten"
+
+	// TransactionSkipped marks an object not attempted because an earlier write failed
+	TransactionSkipped = "skipped"
+)
+
+// WriteTransaction writes a set of objects and deletes the ones already written when any write fails
+func (o *Operations) WriteTransaction(ctx context.Context, req *WriteTransactionRequest, resp *WriteTransactionResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	if len(req.Objects) == 0 || len(req.Objects) > maxTransactionObjects {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError(fmt.Sprintf("objects must contain between 1 and %d entries", maxTransactionObjects))
+	}
+
+	pathnames := make([]string, len(req.Objects))
+	seen := make(map[string]bool, len(req.Objects))
+	for i := range req.Objects {
+		if err := o.validatePathname(&req.Objects[i].Pathname); err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+			return err
+		}
+		pathname := req.Objects[i].Pathname
+		if seen[pathname] {
+			o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+			return NewInvalidRequestError(fmt.Sprintf("pathname '%s' appears more than once", pathname))
+		}
+		seen[pathname] = true
+		pathnames[i] = pathname
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access once for the whole set; the writes and rollback deletes below run pre-authorized
+	if err := o.authorize(ctx, req.Caller, "WriteTransaction", "write_transaction", bucket.Name, pathnames...); err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, authorizedKey{}, true)
+
+	// A rollback would delete from the audit chain
+	if err := bucket.requireAppendOnly("WriteTransaction"); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "error")
+		o.plugin.metrics.RecordError(req.Bucket, err.Code)
+		return err
+	}
+
+	existed, err := o.existingObjects(ctx, bucket, pathnames)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("head object", err)
+	}
+
+	// Rolling back an overwrite would delete the previous content, so it must be asked for
+	if !req.Overwrite {
+		for _, pathname := range pathnames {
+			if existed[pathname] {
+				o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "error")
+				o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+				return NewInvalidRequestError(fmt.Sprintf("'%s' already exists, set overwrite to replace existing objects", pathname))
+			}
+		}
+	}
+
+	resp.Results = make([]TransactionResult, len(req.Objects))
+	for i, obj := range req.Objects {
+		resp.Results[i] = TransactionResult{Pathname: obj.Pathname, Status: TransactionSkipped}
+	}
+
+	failed := -1
+	for i, obj := range req.Objects {
+		writeResp := &WriteResponse{}
+		// The transaction is tracked as a whole, so shutdown cannot stop it between objects
+		err := o.write(ctx, &WriteRequest{
+			Bucket:     req.Bucket,
+			Pathname:   obj.Pathname,
+			Content:    obj.Content,
+			Config:     obj.Config,
+			Visibility: obj.Visibility,
+		}, writeResp)
+		if err != nil {
+			resp.Results[i].Status = TransactionFailed
+			resp.Results[i].Error = asS3Error("write", err)
+			resp.Error = resp.Results[i].Error
+			failed = i
+			break
+		}
+
+		resp.Results[i].Status = TransactionWritten
+		resp.Results[i].Size = writeResp.Size
+	}
+
+	if failed < 0 {
+		resp.Committed = true
+		o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "success")
+		return nil
+	}
+
+	// Undo in reverse order, best-effort: a failed delete does not stop the rollback.
+	// A write failing because the request was cancelled must still be rolled back.
+	rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), transactionRollbackTimeout)
+	defer cancel()
+
+	for i := failed - 1; i >= 0; i-- {
+		result := &resp.Results[i]
+		if existed[result.Pathname] {
+			result.Status = TransactionOverwritten
+			continue
+		}
+
+		if err := o.delete(rollbackCtx, &DeleteRequest{Bucket: req.Bucket, Pathname: result.Pathname}, &DeleteResponse{}); err != nil {
+			loggerFor(ctx, o.log).Error("failed to roll back transaction write",
+				zap.String("bucket", req.Bucket),
+				zap.String("pathname", result.Pathname),
+				zap.Error(err),
+			)
+			result.Status = TransactionRollbackFailed
+			result.Error = asS3Error("delete", err)
+			continue
+		}
+		result.Status = TransactionRolledBack
+	}
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "write_transaction", "error")
+	o.plugin.metrics.RecordError(req.Bucket, resp.Error.Code)
+
+	o.log.Warn("write transaction rolled back",
+		zap.String("bucket", req.Bucket),
+		zap.String("failed_pathname", req.Objects[failed].Pathname),
+		zap.Int("written", failed),
+	)
+
+	return nil
+}
+
+// existingObjects reports which pathnames already hold an object
+func (o *Operations) existingObjects(ctx context.Context, bucket *Bucket, pathnames []string) (map[string]bool, error) {
+	if err := bucket.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer bucket.Release()
+
+	existed := make(map[string]bool, len(pathnames))
+	for _, pathname := range pathnames {
+		exists, err := objectExists(ctx, bucket, bucket.ObjectKey(pathname))
+		if err != nil {
+			return nil, err
+		}
+		existed[pathname] = exists
+	}
+
+	return existed, nil
+}
+
+// asS3Error returns err as a structured error, wrapping unstructured errors as failures of operation
+func asS3Error(operation string, err error) *S3Error {
+	var s3Err *S3Error
+	if errors.As(err, &s3Err) {
+		return s3Err
+	}
+	return NewS3OperationError(operation, err)
+}
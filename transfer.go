@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// maxPartSize is the S3 limit for a single multipart part
+	maxPartSize = 5 * 1024 * 1024 * 1024
+
+	// maxTransferConcurrency bounds per-request upload goroutines
+	maxTransferConcurrency = 64
+
+	// UploadMethodSingle sends an object with one PutObject request
+	UploadMethodSingle = "single"
+
+	// UploadMethodMultipart splits an object into parts with a multipart upload
+	UploadMethodMultipart = "multipart"
+)
+
+// Transfer is embedded in upload requests to override bucket transfer defaults for a single
+// upload, e.g. bigger parts and more concurrency for nightly backups. Zero values keep the defaults.
+type Transfer struct {
+	PartSize     int64  `json:"part_size,omitempty"`
+	Concurrency  int    `json:"concurrency,omitempty"`
+	StorageClass string `json:"storage_class,omitempty"` // e.g. "STANDARD_IA", "GLACIER_IR"
+}
+
+// validate checks the overrides against S3 limits
+func (t Transfer) validate() error {
+	if t.PartSize != 0 && (t.PartSize < manager.MinUploadPartSize || t.PartSize > maxPartSize) {
+		return NewInvalidRequestError(fmt.Sprintf("part_size must be between %d and %d bytes", manager.MinUploadPartSize, int64(maxPartSize)))
+	}
+
+	if t.Concurrency < 0 || t.Concurrency > maxTransferConcurrency {
+		return NewInvalidRequestError(fmt.Sprintf("concurrency must be between 1 and %d", maxTransferConcurrency))
+	}
+
+	if t.StorageClass != "" && !slices.Contains(types.StorageClass("").Values(), types.StorageClass(t.StorageClass)) {
+		return NewInvalidRequestError(fmt.Sprintf("unsupported storage_class '%s'", t.StorageClass))
+	}
+
+	return nil
+}
+
+// tune applies the overrides to an uploader for an object of size bytes (negative when unknown).
+// The part size never drops below what keeps a known-size object within the bucket's max_parts.
+func (t Transfer) tune(u *manager.Uploader, bucket *Bucket, size int64) {
+	if t.PartSize > 0 && bucket.Capabilities.Multipart {
+		u.PartSize = t.PartSize
+		if size >= 0 {
+			u.PartSize = max(t.PartSize, bucket.Config.requiredPartSize(size))
+		}
+	}
+	if t.Concurrency > 0 {
+		u.Concurrency = t.Concurrency
+	}
+}
@@ -0,0 +1,326 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// verifyJobType identifies integrity verification jobs
+	verifyJobType = "verify"
+
+	// VerifyModeMetadata compares object metadata with the recorded values without reading content
+	VerifyModeMetadata = "metadata"
+
+	// VerifyModeContent also downloads every object and compares its digest with the stored checksum
+	VerifyModeContent = "content"
+
+	// maxVerifyProblems bounds the corrupted and missing objects listed in a verification report
+	maxVerifyProblems = 1000
+)
+
+// StartVerify launches a job checking the objects under a prefix against their recorded values:
+// the object index, dedup pointers and, in content mode, the checksums stored by S3. Run it
+// periodically to detect bit rot and objects changed or removed behind the plugin's back.
+func (o *Operations) StartVerify(ctx context.Context, req *VerifyRequest, resp *StartJobResponse) error {
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "verify", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, "StartVerify", "verify", bucket.Name, req.Prefix); err != nil {
+		return err
+	}
+
+	switch {
+	case strings.Contains(req.Prefix, ".."):
+		err = NewInvalidRequestError("prefix cannot contain '..'")
+	case req.Mode == "":
+		req.Mode = VerifyModeMetadata
+	case req.Mode != VerifyModeMetadata && req.Mode != VerifyModeContent:
+		err = NewInvalidRequestError(fmt.Sprintf("mode must be '%s' or '%s'", VerifyModeMetadata, VerifyModeContent))
+	}
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "verify", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	verify := *req
+	verify.Caller = Caller{}
+
+	job, err := o.plugin.jobs.Start(ctx, verifyJobType, "", []string{bucket.Name}, req, func(ctx context.Context, job *Job) error {
+		report, err := o.runVerify(ctx, job, bucket, &verify)
+		if report != nil {
+			job.SetResult(report)
+		}
+		return err
+	})
+	if err != nil {
+		return asS3Error("start verify", err)
+	}
+
+	resp.JobID = job.ID
+	return nil
+}
+
+// runVerify checks every listed object, then reports indexed objects that were not listed as missing
+func (o *Operations) runVerify(ctx context.Context, job *Job, bucket *Bucket, req *VerifyRequest) (*VerifyReport, error) {
+	report := &VerifyReport{
+		Bucket:    bucket.Name,
+		Prefix:    req.Prefix,
+		Mode:      req.Mode,
+		Corrupted: []VerifyProblem{},
+		Missing:   []VerifyProblem{},
+	}
+
+	// Indexed objects are ticked off as they are listed; the rest no longer exist
+	var indexed map[string]*IndexedObject
+	if o.plugin.index.indexes(bucket) {
+		indexed = make(map[string]*IndexedObject)
+		o.plugin.index.each(bucket.Name, req.Prefix, func(obj *IndexedObject) {
+			copied := *obj
+			indexed[obj.Pathname] = &copied
+		})
+	}
+
+	job.SetStatus(JobRunning, "verifying objects")
+	err := o.walkObjects(ctx, bucket, bucket.listPrefix(req.Prefix), func(obj types.Object) error {
+		key := aws.ToString(obj.Key)
+		pathname := bucket.Config.pathnameOf(key)
+		if !strings.HasPrefix(pathname, req.Prefix) || isDirectoryMarker(key, obj.Size) {
+			return nil
+		}
+
+		recorded := indexed[pathname]
+		delete(indexed, pathname)
+
+		problem, verified, read, err := o.verifyObject(ctx, bucket, key, pathname, recorded, req.Mode)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			job.AddFailure(pathname, err)
+			return nil
+		}
+
+		report.Objects++
+		report.Bytes += read
+		switch {
+		case problem != "":
+			report.addCorrupted(pathname, problem)
+		case verified:
+			report.Verified++
+		default:
+			report.Unverified++
+		}
+
+		job.AddProgress(read)
+		job.SetCheckpoint(key)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for pathname := range indexed {
+		report.addMissing(pathname, "indexed but not stored")
+	}
+
+	o.plugin.metrics.RecordOperation(bucket.Name, "verify", "success")
+	loggerFor(ctx, o.log).Info("verification completed",
+		zap.String("job", job.ID),
+		zap.String("bucket", bucket.Name),
+		zap.String("prefix", req.Prefix),
+		zap.String("mode", req.Mode),
+		zap.Int64("objects", report.Objects),
+		zap.Int64("corrupted", report.CorruptedCount),
+		zap.Int64("missing", report.MissingCount),
+	)
+	if report.CorruptedCount > 0 || report.MissingCount > 0 {
+		loggerFor(ctx, o.log).Warn("verification found damaged objects",
+			zap.String("job", job.ID),
+			zap.String("bucket", bucket.Name),
+			zap.Int64("corrupted", report.CorruptedCount),
+			zap.Int64("missing", report.MissingCount),
+		)
+	}
+
+	return report, nil
+}
+
+// verifyObject checks one object. It returns why the object is corrupted (empty when it is not),
+// whether any recorded value was compared, and the bytes read.
+func (o *Operations) verifyObject(ctx context.Context, bucket *Bucket, key, pathname string, recorded *IndexedObject, mode string) (string, bool, int64, error) {
+	if err := bucket.Acquire(ctx); err != nil {
+		return "", false, 0, err
+	}
+	defer bucket.Release()
+
+	head, err := bucket.Reader().HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucket.Config.Bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if exists, err := headExists(bucket, err); !exists {
+		// Deleted since it was listed; the listing is the reference for missing objects
+		return "", false, 0, err
+	}
+
+	verified := false
+	etag := aws.ToString(head.ETag)
+
+	if recorded != nil && recorded.ETag != "" {
+		if recorded.ETag != etag {
+			return fmt.Sprintf("etag %s differs from indexed %s", etag, recorded.ETag), true, 0, nil
+		}
+		verified = true
+	}
+
+	// Pointers of dedup buckets are only as good as the blob they point to
+	if sum, size := dedupPointer(head.Metadata); sum != "" && bucket.Config.Dedup {
+		blob, err := bucket.Reader().HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket.Config.Bucket),
+			Key:    aws.String(bucket.GetFullPath(dedupBlobPath(bucket.Config.DedupPrefix, sum))),
+		})
+		exists, err := headExists(bucket, err)
+		switch {
+		case err != nil:
+			return "", false, 0, err
+		case !exists:
+			return "dedup blob " + sum + " is missing", true, 0, nil
+		case aws.ToInt64(blob.ContentLength) != size:
+			return fmt.Sprintf("dedup blob %s has %d bytes, pointer records %d", sum, aws.ToInt64(blob.ContentLength), size), true, 0, nil
+		}
+		return "", true, 0, nil
+	}
+
+	if mode != VerifyModeContent {
+		return "", verified, 0, nil
+	}
+
+	// Content is compared with the strongest recorded digest: a full-object checksum, the name
+	// of a dedup blob, or the MD5 ETag of an unencrypted single-part upload
+	algorithm, expected := fullObjectChecksum(head)
+	var digest hash.Hash
+	switch algorithm {
+	case "SHA256":
+		digest = sha256.New()
+	case "SHA1":
+		digest = sha1.New()
+	case "CRC32":
+		digest = crc32.NewIEEE()
+	case "CRC32C":
+		digest = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	}
+
+	var blobSum string
+	if bucket.Config.Dedup && strings.HasPrefix(pathname, bucket.Config.DedupPrefix) {
+		blobSum = path.Base(pathname)
+	}
+	md5ETag := !isMultipartETag(etag) && head.ServerSideEncryption != types.ServerSideEncryptionAwsKms &&
+		head.ServerSideEncryption != types.ServerSideEncryptionAwsKmsDsse && head.SSECustomerAlgorithm == nil
+	if digest == nil && blobSum == "" && !md5ETag {
+		return "", verified, 0, nil
+	}
+
+	result, err := bucket.Reader().GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(bucket.Config.Bucket),
+		Key:     aws.String(key),
+		IfMatch: head.ETag,
+	})
+	if err != nil {
+		return "", false, 0, err
+	}
+	defer result.Body.Close()
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	writers := []io.Writer{md5Hash, sha256Hash}
+	if digest != nil {
+		writers = append(writers, digest)
+	}
+	read, err := io.Copy(io.MultiWriter(writers...), result.Body)
+	if err != nil {
+		return "", false, read, err
+	}
+
+	switch {
+	case digest != nil:
+		if actual := base64.StdEncoding.EncodeToString(digest.Sum(nil)); actual != expected {
+			return fmt.Sprintf("%s %s differs from stored %s", strings.ToLower(algorithm), actual, expected), true, read, nil
+		}
+	case blobSum != "":
+		if actual := hex.EncodeToString(sha256Hash.Sum(nil)); actual != blobSum {
+			return "sha256 " + actual + " differs from blob name", true, read, nil
+		}
+	default:
+		if actual := hex.EncodeToString(md5Hash.Sum(nil)); actual != strings.Trim(etag, `"`) {
+			return "md5 " + actual + " differs from etag " + etag, true, read, nil
+		}
+	}
+
+	return "", true, read, nil
+}
+
+// fullObjectChecksum returns the checksum S3 stores for the whole content of an object, if any.
+// Composite checksums of multipart uploads cover parts and cannot be recomputed from the content.
+func fullObjectChecksum(head *s3.HeadObjectOutput) (string, string) {
+	if head.ChecksumType != types.ChecksumTypeFullObject && head.ChecksumType != "" {
+		return "", ""
+	}
+
+	candidates := []struct {
+		name  string
+		value *string
+	}{
+		{"SHA256", head.ChecksumSHA256},
+		{"SHA1", head.ChecksumSHA1},
+		{"CRC32C", head.ChecksumCRC32C},
+		{"CRC32", head.ChecksumCRC32},
+	}
+	for _, c := range candidates {
+		if value := aws.ToString(c.value); value != "" && !strings.Contains(value, "-") {
+			return c.name, value
+		}
+	}
+
+	return "", ""
+}
+
+// addCorrupted records a corrupted object, listing up to maxVerifyProblems
+func (r *VerifyReport) addCorrupted(pathname, reason string) {
+	r.CorruptedCount++
+	if len(r.Corrupted)+len(r.Missing) < maxVerifyProblems {
+		r.Corrupted = append(r.Corrupted, VerifyProblem{Pathname: pathname, Reason: reason})
+	} else {
+		r.Truncated = true
+	}
+}
+
+// addMissing records a missing object, listing up to maxVerifyProblems
+func (r *VerifyReport) addMissing(pathname, reason string) {
+	r.MissingCount++
+	if len(r.Corrupted)+len(r.Missing) < maxVerifyProblems {
+		r.Missing = append(r.Missing, VerifyProblem{Pathname: pathname, Reason: reason})
+	} else {
+		r.Truncated = true
+	}
+}
@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// PublicModePrefix treats objects under public_prefix as public; a bucket policy grants anonymous read on it
+	PublicModePrefix = "prefix"
+
+	// PublicModePresign serves "public" URLs as long-lived presigned URLs
+	PublicModePresign = "presign"
+
+	// maxPresignTTL is the longest validity SigV4 presigned URLs support
+	maxPresignTTL = 7 * 24 * time.Hour
+)
+
+// ObjectACL returns the canned ACL for a requested visibility ("public", "private", a canned ACL,
+// or empty for the bucket default). It is empty when the bucket has ACLs disabled, which omits the header.
+func (b *Bucket) ObjectACL(visibility string) types.ObjectCannedACL {
+	if b.ACLDisabled() {
+		return ""
+	}
+
+	switch visibility {
+	case "":
+		return types.ObjectCannedACL(b.GetVisibility())
+	case "public":
+		return types.ObjectCannedACLPublicRead
+	case "private":
+		return types.ObjectCannedACLPrivate
+	default:
+		return types.ObjectCannedACL(visibility)
+	}
+}
+
+// emulatedVisibility returns the visibility of pathname in a bucket with ACLs disabled
+func (bc *BucketConfig) emulatedVisibility(pathname string) string {
+	if bc.PublicMode == PublicModePrefix {
+		if strings.HasPrefix(pathname, bc.PublicPrefix) {
+			return "public"
+		}
+		return "private"
+	}
+	return bc.Visibility
+}
+
+// checkEmulatedVisibility reports whether visibility can be applied to pathname without ACLs.
+// In prefix mode visibility follows the pathname, so only the matching value is accepted;
+// in presign mode every object is served through presigned URLs and any value is accepted.
+func (bc *BucketConfig) checkEmulatedVisibility(pathname, visibility string) *S3Error {
+	if bc.PublicMode != PublicModePrefix || bc.emulatedVisibility(pathname) == visibility {
+		return nil
+	}
+
+	if visibility == "public" {
+		return NewInvalidRequestError("bucket has ACLs disabled: only objects under '" + bc.PublicPrefix + "' can be public")
+	}
+	return NewInvalidRequestError("bucket has ACLs disabled: objects under '" + bc.PublicPrefix + "' are always public")
+}
@@ -0,0 +1,174 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultWaiterMaxWait and maxWaiterMaxWait bound how long a waiter blocks
+	defaultWaiterMaxWait = 30 * time.Second
+	maxWaiterMaxWait     = 15 * time.Minute
+
+	// defaultWaiterInterval and minWaiterInterval bound the delay between two checks
+	defaultWaiterInterval = time.Second
+	minWaiterInterval     = 100 * time.Millisecond
+)
+
+// semaphoreHeadClient holds the bucket semaphore only during each HeadObject of a waiter,
+// so a long wait does not occupy a slot between checks
+type semaphoreHeadClient struct {
+	bucket *Bucket
+}
+
+func (c semaphoreHeadClient) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if err := c.bucket.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.bucket.Release()
+	return c.bucket.Client.HeadObject(ctx, params, optFns...)
+}
+
+// WaitUntilExists blocks until an object exists or max_wait expires
+func (o *Operations) WaitUntilExists(ctx context.Context, req *WaitRequest, resp *WaitResponse) error {
+	return o.waitUntil(ctx, req, resp, true)
+}
+
+// WaitUntilNotExists blocks until an object is gone or max_wait expires
+func (o *Operations) WaitUntilNotExists(ctx context.Context, req *WaitRequest, resp *WaitResponse) error {
+	return o.waitUntil(ctx, req, resp, false)
+}
+
+// waitUntil runs the SDK object waiter for the requested state. Expiry of max_wait is a result,
+// not an error; HeadObject failures other than "missing" end the wait with an error.
+func (o *Operations) waitUntil(ctx context.Context, req *WaitRequest, resp *WaitResponse, exists bool) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	operation, op := "WaitUntilExists", "wait_exists"
+	if !exists {
+		operation, op = "WaitUntilNotExists", "wait_not_exists"
+	}
+
+	// Validate request
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidPathname)
+		return err
+	}
+
+	maxWait, interval, err := req.durations()
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return err
+	}
+
+	if err := o.route(&req.Bucket, req.Pathname, op); err != nil {
+		return err
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access
+	if err := o.authorize(ctx, req.Caller, operation, op, bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	// Missing objects are classified like Exists, including treat_forbidden_as_missing
+	var failure error
+	retryable := func(_ context.Context, _ *s3.HeadObjectInput, _ *s3.HeadObjectOutput, err error) (bool, error) {
+		resp.Attempts++
+		found, err := headExists(bucket, err)
+		if err != nil {
+			failure = err
+			return false, err
+		}
+		return found != exists, nil
+	}
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.ObjectKey(req.Pathname)),
+	}
+	client := semaphoreHeadClient{bucket: bucket}
+
+	start := time.Now()
+	if exists {
+		err = s3.NewObjectExistsWaiter(client, func(w *s3.ObjectExistsWaiterOptions) {
+			w.MinDelay, w.MaxDelay, w.Retryable = interval, interval, retryable
+		}).Wait(ctx, input, maxWait)
+	} else {
+		err = s3.NewObjectNotExistsWaiter(client, func(w *s3.ObjectNotExistsWaiterOptions) {
+			w.MinDelay, w.MaxDelay, w.Retryable = interval, interval, retryable
+		}).Wait(ctx, input, maxWait)
+	}
+	resp.WaitedMS = time.Since(start).Milliseconds()
+
+	// A failed check is only a failure of the wait while the wait itself has time left
+	if err != nil && (ctx.Err() != nil || (failure != nil && !errors.Is(failure, context.DeadlineExceeded))) {
+		o.log.Error("failed to wait for object",
+			zap.String("bucket", req.Bucket),
+			zap.String("pathname", req.Pathname),
+			zap.Bool("exists", exists),
+			zap.Error(err),
+		)
+		o.plugin.metrics.RecordOperation(req.Bucket, op, "error")
+		if isForbidden(err) {
+			o.plugin.metrics.RecordError(req.Bucket, ErrPermissionDenied)
+			return NewS3AccessDeniedError("head object", err)
+		}
+		o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+		return NewS3OperationError("head object", err)
+	}
+
+	resp.Satisfied = err == nil
+	o.plugin.metrics.RecordOperation(req.Bucket, op, "success")
+
+	o.log.Debug("object wait finished",
+		zap.String("bucket", req.Bucket),
+		zap.String("pathname", req.Pathname),
+		zap.Bool("exists", exists),
+		zap.Bool("satisfied", resp.Satisfied),
+		zap.Int("attempts", resp.Attempts),
+		zap.Int64("waited_ms", resp.WaitedMS),
+	)
+
+	return nil
+}
+
+// durations parses max_wait and interval, applying defaults
+func (req *WaitRequest) durations() (time.Duration, time.Duration, error) {
+	maxWait, interval := defaultWaiterMaxWait, defaultWaiterInterval
+
+	if req.MaxWait != "" {
+		d, err := time.ParseDuration(req.MaxWait)
+		if err != nil || d <= 0 || d > maxWaiterMaxWait {
+			return 0, 0, NewInvalidRequestError(fmt.Sprintf("max_wait must be a duration between 0 and %s", maxWaiterMaxWait))
+		}
+		maxWait = d
+	}
+
+	if req.Interval != "" {
+		d, err := time.ParseDuration(req.Interval)
+		if err != nil || d < minWaiterInterval || d > maxWait {
+			return 0, 0, NewInvalidRequestError(fmt.Sprintf("interval must be a duration between %s and max_wait", minWaiterInterval))
+		}
+		interval = d
+	}
+
+	return maxWait, min(interval, maxWait), nil
+}
@@ -0,0 +1,402 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultQuotaRefresh is how long a measured bucket usage is trusted
+	defaultQuotaRefresh = 5 * time.Minute
+
+	// quotaCheckInterval is how often usage is compared with write_policy.quota_alerts
+	quotaCheckInterval = 30 * time.Second
+)
+
+// defaultQuotaAlerts are the shares of the quota that raise an alert when reached
+var defaultQuotaAlerts = []float64{0.8, 0.9, 1}
+
+// WritePolicyConfig restricts what Write accepts for a bucket. CanWrite evaluates the same rules
+// without transferring content.
+type WritePolicyConfig struct {
+	// AllowedExtensions limits writes to these file extensions, case-insensitive (optional)
+	AllowedExtensions []string `mapstructure:"allowed_extensions" json:"allowed_extensions,omitempty"`
+
+	// DeniedExtensions rejects these file extensions, case-insensitive (optional)
+	DeniedExtensions []string `mapstructure:"denied_extensions" json:"denied_extensions,omitempty"`
+
+	// MaxSize is the largest accepted object in bytes (0: unlimited)
+	MaxSize int64 `mapstructure:"max_size" json:"max_size,omitempty"`
+
+	// Quota caps the total bytes stored under the bucket prefix (0: unlimited)
+	Quota int64 `mapstructure:"quota" json:"quota,omitempty"`
+
+	// QuotaRefresh is how often the usage is re-measured by listing the bucket (default: 5m)
+	QuotaRefresh time.Duration `mapstructure:"quota_refresh" json:"quota_refresh,omitempty"`
+
+	// QuotaAlerts are shares of the quota, 0 to 1, that log, count and send an alert when the
+	// usage reaches them (default: [0.8, 0.9, 1])
+	QuotaAlerts []float64 `mapstructure:"quota_alerts" json:"quota_alerts,omitempty"`
+}
+
+// Validate validates the write policy and applies defaults
+func (wp *WritePolicyConfig) Validate() error {
+	if wp.MaxSize < 0 || wp.Quota < 0 {
+		return fmt.Errorf("write_policy.max_size and write_policy.quota must not be negative")
+	}
+
+	if wp.QuotaRefresh == 0 {
+		wp.QuotaRefresh = defaultQuotaRefresh
+	}
+	if wp.QuotaRefresh < 0 {
+		return fmt.Errorf("write_policy.quota_refresh must be positive")
+	}
+
+	if wp.Quota > 0 && len(wp.QuotaAlerts) == 0 {
+		wp.QuotaAlerts = slices.Clone(defaultQuotaAlerts)
+	}
+	for _, threshold := range wp.QuotaAlerts {
+		if threshold <= 0 || threshold > 1 {
+			return fmt.Errorf("write_policy.quota_alerts must be between 0 and 1, got %g", threshold)
+		}
+	}
+	slices.Sort(wp.QuotaAlerts)
+
+	for i, ext := range wp.AllowedExtensions {
+		wp.AllowedExtensions[i] = normalizeExtension(ext)
+	}
+	for i, ext := range wp.DeniedExtensions {
+		wp.DeniedExtensions[i] = normalizeExtension(ext)
+	}
+
+	return nil
+}
+
+// normalizeExtension lowercases an extension and strips its leading dot
+func normalizeExtension(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// bucketUsage caches the bytes stored under a bucket prefix for quota checks
+type bucketUsage struct {
+	mu         sync.Mutex
+	bytes      int64
+	measuredAt time.Time
+
+	// Highest quota_alerts threshold reached at the last check (0: none)
+	alerted float64
+}
+
+// add accounts for bytes written since the last measurement
+func (u *bucketUsage) add(n int64) {
+	u.mu.Lock()
+	u.bytes += n
+	u.mu.Unlock()
+}
+
+// snapshot returns the cached usage, the time it was measured and the highest threshold reached
+func (u *bucketUsage) snapshot() (int64, time.Time, float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.bytes, u.measuredAt, u.alerted
+}
+
+// usage returns the bytes stored under the bucket prefix, listing the bucket when the cached value is stale.
+// It acquires the bucket semaphore, so the caller must not hold it.
+func (o *Operations) usage(ctx context.Context, bucket *Bucket) (int64, error) {
+	u := &bucket.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !u.measuredAt.IsZero() && time.Since(u.measuredAt) < bucket.Config.WritePolicy.QuotaRefresh {
+		return u.bytes, nil
+	}
+
+	var total int64
+	err := o.walkObjects(ctx, bucket, bucket.GetFullPath(""), func(obj types.Object) error {
+		total += aws.ToInt64(obj.Size)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	u.bytes = total
+	u.measuredAt = time.Now()
+	return total, nil
+}
+
+// watchQuotas compares the usage of every bucket with a quota against its quota_alerts thresholds
+// each quotaCheckInterval, measuring it again once quota_refresh elapsed
+func (o *Operations) watchQuotas(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(quotaCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, name := range o.plugin.buckets.ListBuckets() {
+				bucket, err := o.plugin.buckets.GetBucket(name)
+				if err != nil || bucket.Config.WritePolicy == nil || bucket.Config.WritePolicy.Quota <= 0 {
+					continue
+				}
+
+				used, err := o.usage(ctx, bucket)
+				if err != nil {
+					if ctx.Err() == nil {
+						o.log.Warn("failed to measure bucket usage",
+							zap.String("bucket", bucket.Name),
+							zap.Error(err),
+						)
+					}
+					continue
+				}
+				o.checkQuotaAlerts(ctx, wg, bucket, used)
+			}
+		}
+	}()
+}
+
+// checkQuotaAlerts emits an event when the usage reached a higher threshold than at the last check,
+// or dropped below the threshold reached then
+func (o *Operations) checkQuotaAlerts(ctx context.Context, wg *sync.WaitGroup, bucket *Bucket, used int64) {
+	wp := bucket.Config.WritePolicy
+	ratio := float64(used) / float64(wp.Quota)
+	o.plugin.metrics.SetQuotaUsage(bucket.Name, ratio)
+
+	var level float64
+	for _, threshold := range wp.QuotaAlerts {
+		if ratio >= threshold {
+			level = threshold
+		}
+	}
+
+	u := &bucket.usage
+	u.mu.Lock()
+	previous := u.alerted
+	u.alerted = level
+	u.mu.Unlock()
+
+	// Every instance measures, only the coordination leader alerts
+	if level == previous || !o.plugin.leader.isLeader() {
+		return
+	}
+
+	event := AlertEvent{
+		Event:     QuotaThresholdCrossed,
+		Bucket:    bucket.Name,
+		Threshold: level,
+		Used:      used,
+		Quota:     wp.Quota,
+		Timestamp: time.Now().Unix(),
+	}
+	fields := []zap.Field{
+		zap.String("bucket", bucket.Name),
+		zap.Int64("used", used),
+		zap.Int64("quota", wp.Quota),
+		zap.Float64("ratio", ratio),
+	}
+
+	if level > previous {
+		o.log.Warn("quota threshold crossed", append(fields, zap.Float64("threshold", level))...)
+		o.plugin.metrics.RecordQuotaAlert(bucket.Name, level)
+	} else {
+		event.Event = QuotaThresholdResolved
+		event.Threshold = previous
+		o.log.Info("quota threshold resolved", append(fields, zap.Float64("threshold", previous))...)
+	}
+
+	if o.plugin.metrics != nil {
+		o.plugin.metrics.alerts.deliver(ctx, wg, event)
+	}
+}
+
+// policyViolations evaluates the bucket write policy for an object of size bytes at pathname.
+// It acquires the bucket semaphore for quota checks, so the caller must not hold it.
+func (o *Operations) policyViolations(ctx context.Context, bucket *Bucket, pathname string, size int64) []*S3Error {
+	wp := bucket.Config.WritePolicy
+	if wp == nil {
+		return nil
+	}
+
+	var violations []*S3Error
+	ext := normalizeExtension(path.Ext(pathname))
+	if len(wp.AllowedExtensions) > 0 && !slices.Contains(wp.AllowedExtensions, ext) {
+		violations = append(violations, NewS3Error(ErrInvalidRequest, "File extension is not allowed", "extension: "+ext))
+	}
+	if slices.Contains(wp.DeniedExtensions, ext) {
+		violations = append(violations, NewS3Error(ErrInvalidRequest, "File extension is denied", "extension: "+ext))
+	}
+
+	if wp.MaxSize > 0 && size > wp.MaxSize {
+		violations = append(violations, NewS3Error(ErrInvalidRequest, "File is too large", fmt.Sprintf("size: %d, max_size: %d", size, wp.MaxSize)))
+	}
+
+	if wp.Quota > 0 {
+		used, err := o.usage(ctx, bucket)
+		if err != nil {
+			o.log.Warn("failed to measure bucket usage",
+				zap.String("bucket", bucket.Name),
+				zap.Error(err),
+			)
+			violations = append(violations, NewS3OperationError("measure usage", err))
+		} else if used+size > wp.Quota {
+			violations = append(violations, NewQuotaExceededError(bucket.Name, used, wp.Quota))
+		}
+	}
+
+	return violations
+}
+
+// enforcePolicy fails with the first write policy violation of an object of size bytes at pathname,
+// recorded as a failure of op. Like policyViolations, it must be called without the semaphore.
+func (o *Operations) enforcePolicy(ctx context.Context, bucket *Bucket, op, pathname string, size int64) error {
+	violations := o.policyViolations(ctx, bucket, pathname, size)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	o.plugin.metrics.RecordOperation(bucket.Name, op, "error")
+	o.plugin.metrics.RecordError(bucket.Name, violations[0].Code)
+	return violations[0]
+}
+
+// contentSize returns the size of the content stored at pathname, following dedup pointers.
+// It acquires the bucket semaphore, so the caller must not hold it.
+func (o *Operations) contentSize(ctx context.Context, bucket *Bucket, pathname string) (int64, error) {
+	if err := bucket.Acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer bucket.Release()
+
+	head, err := bucket.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket.Config.Bucket),
+		Key:    aws.String(bucket.ObjectKey(pathname)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if sum, size := dedupPointer(head.Metadata); sum != "" && bucket.Config.Dedup {
+		return size, nil
+	}
+	return aws.ToInt64(head.ContentLength), nil
+}
+
+// CanWrite evaluates whether a Write of the given size and visibility would be accepted,
+// reporting every reason it would not instead of failing on the first one
+func (o *Operations) CanWrite(ctx context.Context, req *CanWriteRequest, resp *CanWriteResponse) error {
+	if err := o.plugin.TrackOperation(); err != nil {
+		return err
+	}
+	defer o.plugin.CompleteOperation()
+
+	// An invalid pathname is a reason, not a failure of the check
+	var denials []*S3Error
+	validPathname := true
+	if err := o.validatePathname(&req.Pathname); err != nil {
+		validPathname = false
+		var s3Err *S3Error
+		if !errors.As(err, &s3Err) {
+			s3Err = NewInvalidPathnameError(req.Pathname, err.Error())
+		}
+		denials = append(denials, s3Err)
+	}
+
+	if validPathname {
+		if err := o.route(&req.Bucket, req.Pathname, "can_write"); err != nil {
+			return err
+		}
+	}
+
+	bucket, err := o.plugin.buckets.GetBucket(req.Bucket)
+	if err != nil {
+		o.plugin.metrics.RecordOperation(req.Bucket, "can_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrBucketNotFound)
+		return NewBucketNotFoundError(req.Bucket)
+	}
+
+	// Check access to the check itself; write access is reported as a reason below
+	if err := o.authorize(ctx, req.Caller, "CanWrite", "can_write", bucket.Name, req.Pathname); err != nil {
+		return err
+	}
+
+	if req.Size < 0 {
+		o.plugin.metrics.RecordOperation(req.Bucket, "can_write", "error")
+		o.plugin.metrics.RecordError(req.Bucket, ErrInvalidRequest)
+		return NewInvalidRequestError("size must not be negative")
+	}
+
+	if !o.plugin.access.Allows(req.Caller, "Write", bucket.Name, req.Pathname) {
+		denials = append(denials, NewPermissionDeniedError("Write"))
+	}
+	if slices.Contains(o.plugin.interceptors.Names(), InterceptorReadOnly) || bucket.ServerConfig.Anonymous {
+		denials = append(denials, NewS3Error(ErrPermissionDenied, "Storage is read-only", "bucket: "+bucket.Name))
+	}
+
+	if err := bucket.checkBlobPathname(req.Pathname); err != nil {
+		denials = append(denials, err)
+	}
+	if tc := bucket.Config.Temporary; tc != nil && strings.HasPrefix(req.Pathname, tc.Prefix) {
+		denials = append(denials, NewInvalidPathnameError(req.Pathname, "pathname is inside the temporary upload prefix"))
+	}
+	if ac := bucket.Config.Audit; ac != nil && req.Pathname == ac.HeadPathname {
+		denials = append(denials, NewInvalidPathnameError(req.Pathname, "pathname is the audit chain head"))
+	}
+
+	switch req.Visibility {
+	case "", "public", "private":
+		// With ACLs disabled in prefix mode, visibility follows the pathname
+		if req.Visibility != "" && bucket.ACLDisabled() && bucket.Config.PublicMode == PublicModePrefix &&
+			bucket.Config.emulatedVisibility(req.Pathname) != req.Visibility {
+			denials = append(denials, NewS3Error(ErrInvalidVisibility,
+				fmt.Sprintf("visibility is determined by public_prefix '%s'", bucket.Config.PublicPrefix), req.Visibility))
+		}
+	default:
+		denials = append(denials, NewS3Error(ErrInvalidVisibility, "visibility must be 'public' or 'private'", req.Visibility))
+	}
+
+	denials = append(denials, o.policyViolations(ctx, bucket, req.Pathname, req.Size)...)
+
+	if validPathname {
+		if err := bucket.Acquire(ctx); err != nil {
+			return err
+		}
+		exists, err := objectExists(ctx, bucket, bucket.ObjectKey(req.Pathname))
+		bucket.Release()
+		if err != nil {
+			o.plugin.metrics.RecordOperation(req.Bucket, "can_write", "error")
+			o.plugin.metrics.RecordError(req.Bucket, ErrS3Operation)
+			return NewS3OperationError("head object", err)
+		}
+		resp.Exists = exists
+		if exists && bucket.Config.Audit != nil {
+			denials = append(denials, NewS3Error(ErrPermissionDenied, "Audit buckets are write-once", "pathname: "+req.Pathname))
+		}
+	}
+
+	resp.Allowed = len(denials) == 0
+	resp.Reasons = denials
+
+	o.plugin.metrics.RecordOperation(req.Bucket, "can_write", "success")
+	return nil
+}